@@ -0,0 +1,12 @@
+//go:build chaos
+
+// chaos_flag.go - The hidden --chaos flag, built with -tags chaos.
+package main
+
+import "github.com/spf13/pflag"
+
+func init() {
+	scanFlagHooks = append(scanFlagHooks, func(fs *pflag.FlagSet) {
+		fs.StringVar(&chaosSpec, "chaos", "", "inject delays and failures, e.g. seed=42,cmd=0.1,http=0.05,dns=0.05,write=0.02,delay=500ms")
+	})
+}
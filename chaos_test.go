@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// TestPipelineUnderChaos runs stub stages that make commands, HTTP requests,
+// DNS lookups and file writes through the shared hooks under several chaos
+// seeds, and checks the invariants a run must keep however its operations
+// fail: every stage ends in a terminal state, summary.json parses, no
+// goroutine outlives the run and the run finishes in time.
+func TestPipelineUnderChaos(t *testing.T) {
+	savedResult, savedScope, savedOffline := scanResult, scope, offlineMode
+	defer func() { scanResult, scope, offlineMode = savedResult, savedScope, savedOffline }()
+	scope, offlineMode = nil, false
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+
+	terminal := map[string]bool{"completed": true, "completed-with-warnings": true, "completed-with-errors": true, "failed-validation": true, "failed": true, "skipped": true}
+	stages := map[string]func(outDir string){
+		"commands": func(outDir string) {
+			for i := 0; i < 5; i++ {
+				if _, err := utils.RunCommandTimeout(5*time.Second, "true"); err != nil {
+					AppendLog("[!] " + err.Error())
+				}
+			}
+		},
+		"HTTP": func(outDir string) {
+			client, err := newHTTPClient(false)
+			if err != nil {
+				panic(err)
+			}
+			for i := 0; i < 5; i++ {
+				resp, err := client.Get(srv.URL)
+				if err != nil {
+					AppendLog("[!] " + err.Error())
+					continue
+				}
+				ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+		},
+		"DNS": func(outDir string) {
+			for i := 0; i < 3; i++ {
+				ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+				if _, err := newResolver().LookupHost(ctx, "localhost"); err != nil {
+					AppendLog("[!] " + err.Error())
+				}
+				cancel()
+			}
+		},
+		"writes": func(outDir string) {
+			for i := 0; i < 5; i++ {
+				if err := utils.WriteLines([]string{"a", "b"}, filepath.Join(outDir, fmt.Sprintf("out%d.txt", i))); err != nil {
+					AppendLog("[!] " + err.Error())
+				}
+			}
+		},
+	}
+	order := []string{"commands", "HTTP", "DNS", "writes"}
+
+	for _, seed := range []int64{1, 2, 3, 42, 1337} {
+		before := runtime.NumGoroutine()
+		utils.EnableChaos(utils.ChaosConfig{Seed: seed, Delay: 2 * time.Millisecond, Rates: map[string]float64{
+			utils.ChaosCommand: 0.3, utils.ChaosHTTP: 0.3, utils.ChaosDNS: 0.3, utils.ChaosWrite: 0.3,
+		}})
+		scanResult = ScanResult{}
+		outDir := t.TempDir()
+		done := make(chan error, 1)
+		go func() {
+			for _, name := range order {
+				run := stages[name]
+				runStage(name, outDir, false, func() { run(outDir) }, nil)
+			}
+			// Writing the summary may be failed too; the run retries once,
+			// as a crashing run's last write would.
+			err := utils.PersistResults(scanResult, outDir)
+			if err != nil {
+				err = utils.PersistResults(scanResult, outDir)
+			}
+			done <- err
+		}()
+		var err error
+		select {
+		case err = <-done:
+		case <-time.After(30 * time.Second):
+			t.Fatalf("seed %d: run did not finish within 30s", seed)
+		}
+		utils.DisableChaos()
+
+		if len(scanResult.Stages) != len(order) {
+			t.Errorf("seed %d: %d stage statuses for %d stages", seed, len(scanResult.Stages), len(order))
+		}
+		for _, st := range scanResult.Stages {
+			if !terminal[st.Status] {
+				t.Errorf("seed %d: stage %s ended %q", seed, st.Stage, st.Status)
+			}
+		}
+		if err == nil {
+			if _, rec, err := utils.LoadSummary(outDir); err != nil || rec != nil {
+				t.Errorf("seed %d: summary.json unreadable: %v %v", seed, err, rec)
+			}
+		}
+
+		srv.CloseClientConnections()
+		http.DefaultTransport.(*http.Transport).CloseIdleConnections()
+		deadline := time.Now().Add(5 * time.Second)
+		for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if n := runtime.NumGoroutine(); n > before {
+			buf := make([]byte, 1<<16)
+			t.Errorf("seed %d: %d goroutine(s) left over:\n%s", seed, n-before, buf[:runtime.Stack(buf, true)])
+		}
+	}
+}
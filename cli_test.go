@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// executeCLI runs the command line args with the scan replaced by a stub
+// and returns the target it was asked to scan, or "" when no scan ran,
+// and what the command printed.
+func executeCLI(t *testing.T, args ...string) (string, string, error) {
+	t.Helper()
+	saved := scanFunc
+	// Registering the flags again puts every flag variable back to its default.
+	t.Cleanup(func() {
+		scanFunc = saved
+		newRootCmd()
+	})
+	var scanned string
+	scanFunc = func(target string) { scanned = target }
+	root := newRootCmd()
+	var out bytes.Buffer
+	root.SetOut(&out)
+	root.SetErr(&out)
+	root.SetArgs(compatArgs(root, args))
+	err := root.Execute()
+	return scanned, out.String(), err
+}
+
+// writeConfig writes an env file and returns its path.
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// unsetEnv unsets key for the test, restoring it afterwards.
+func unsetEnv(t *testing.T, key string) {
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+}
+
+func TestCLIScanInvocation(t *testing.T) {
+	config := writeConfig(t, t.TempDir(), "empty.env", "")
+	type opts struct {
+		offline, failFast, noTUI bool
+		out, baseline, from      string
+	}
+	current := func() opts {
+		return opts{offlineMode, failFast, noTUI, outRoot, baselineDir, waybackFrom}
+	}
+	defaults := opts{out: "."}
+	tests := []struct {
+		name   string
+		args   []string
+		target string
+		want   opts
+	}{
+		{"bare target", []string{"example.com"}, "example.com", defaults},
+		{"flags first", []string{"--offline", "--fail-fast", "example.com"}, "example.com", opts{offline: true, failFast: true, out: "."}},
+		{"flags after the target", []string{"example.com", "--offline", "--wayback-from", "2024"}, "example.com", opts{offline: true, out: ".", from: "2024"}},
+		{"single-dash flags", []string{"-offline", "-fail-fast=true", "-wayback-from=2024", "example.com"}, "example.com", opts{offline: true, failFast: true, out: ".", from: "2024"}},
+		{"persistent flags", []string{"--out", "runs", "--no-tui", "-O", "scans", "example.com"}, "example.com", opts{noTUI: true, out: "scans"}},
+		{"baseline", []string{"--baseline", "old_run", "example.com"}, "example.com", opts{out: ".", baseline: "old_run"}},
+		{"no target", nil, "", defaults},
+	}
+	for _, tt := range tests {
+		target, _, err := executeCLI(t, append([]string{"--config", config}, tt.args...)...)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if target != tt.target {
+			t.Errorf("%s: scanned %q, want %q", tt.name, target, tt.target)
+		}
+		if got := current(); got != tt.want {
+			t.Errorf("%s: options %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"example.com", "example.org"},
+		{"--no-such-flag", "example.com"},
+		{"-no-such-flag", "example.com"},
+	} {
+		if target, _, err := executeCLI(t, append([]string{"--config", config}, args...)...); err == nil || target != "" {
+			t.Errorf("%q: scanned %q, err = %v; want an error and no scan", args, target, err)
+		}
+	}
+}
+
+func TestCompatArgs(t *testing.T) {
+	root := newRootCmd()
+	defer newRootCmd()
+	tests := []struct{ args, want []string }{
+		{[]string{"-offline", "example.com"}, []string{"--offline", "example.com"}},
+		{[]string{"-postgres=dsn", "reprocess", "-baseline", "a", "b"}, []string{"--postgres=dsn", "reprocess", "--baseline", "a", "b"}},
+		{[]string{"debug-bundle", "-o", "x.tar.gz", "run"}, []string{"debug-bundle", "-o", "x.tar.gz", "run"}},
+		{[]string{"-unknown", "example.com"}, []string{"-unknown", "example.com"}},
+		{[]string{"--", "-offline"}, []string{"--", "-offline"}},
+	}
+	for _, tt := range tests {
+		if got := compatArgs(root, tt.args); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("compatArgs(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+// TestCLIPrecedence checks where settings come from: a flag beats the
+// environment, which beats the profile, which beats the config.
+func TestCLIPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	config := writeConfig(t, dir, "recon.env", "POSTGRES_DSN=config\nMAX_LINE_BYTES=100\n")
+	writeConfig(t, dir, "recon.env.internal", "POSTGRES_DSN=profile\n")
+	tests := []struct {
+		name string
+		env  string
+		args []string
+		want string
+	}{
+		{"config", "", []string{"--config", config}, "config"},
+		{"profile over config", "", []string{"--config", config, "--profile", "internal"}, "profile"},
+		{"environment over profile", "env", []string{"--config", config, "--profile", "internal"}, "env"},
+		{"flag over environment", "env", []string{"--config", config, "--profile", "internal", "--postgres", "flag"}, "flag"},
+		{"flag after the target", "", []string{"--config", config, "example.com", "--postgres", "flag"}, "flag"},
+	}
+	for _, tt := range tests {
+		unsetEnv(t, "POSTGRES_DSN")
+		unsetEnv(t, "MAX_LINE_BYTES")
+		if tt.env != "" {
+			os.Setenv("POSTGRES_DSN", tt.env)
+		}
+		args := tt.args
+		if args[len(args)-1] != "flag" {
+			args = append(args, "example.com")
+		}
+		if _, _, err := executeCLI(t, args...); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := configuredDSN(); got != tt.want {
+			t.Errorf("%s: DSN %q, want %q", tt.name, got, tt.want)
+		}
+		// The profile only overrides what it sets.
+		if got := os.Getenv("MAX_LINE_BYTES"); got != "100" {
+			t.Errorf("%s: MAX_LINE_BYTES %q, want the config's", tt.name, got)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"--config", filepath.Join(dir, "missing.env"), "example.com"},
+		{"--config", config, "--profile", "missing", "example.com"},
+	} {
+		if target, _, err := executeCLI(t, args...); err == nil || target != "" {
+			t.Errorf("%q: scanned %q, err = %v; want an error and no scan", args, target, err)
+		}
+	}
+}
+
+// TestCLIRunPrecedence checks where the settings of `recon run` come from:
+// a flag beats the scan definition, which beats the environment, which
+// beats the profile, which beats the config.
+func TestCLIRunPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	config := writeConfig(t, dir, "recon.env", "POSTGRES_DSN=config\nSCOPE_DENY=config.example.com\nMAX_LINE_BYTES=100\nHOOK=https://hooks.example.com/config\n")
+	writeConfig(t, dir, "recon.env.internal", "SCOPE_ALLOW=internal.example.com\n")
+	writeConfig(t, dir, "recon.env.ci", "SCOPE_ALLOW=ci.example.com\n")
+	file := writeConfig(t, dir, "scan.yaml", `targets: [example.com, example.org]
+profile: internal
+scope:
+  deny: [file.example.com]
+notify:
+  - webhook: ${HOOK}
+settings:
+  POSTGRES_DSN: file
+`)
+	t.Cleanup(func() { scanDefinition, recordedDefinition = nil, nil })
+	tests := []struct {
+		name                   string
+		env                    map[string]string
+		args                   []string
+		dsn, deny, allow, hook string
+		overridden             []string
+	}{
+		{"definition over config", nil, nil, "file", "file.example.com", "internal.example.com", "https://hooks.example.com/config", nil},
+		{"definition over environment", map[string]string{"SCOPE_DENY": "env.example.com", "HOOK": "https://hooks.example.com/env"}, nil,
+			"file", "file.example.com", "internal.example.com", "https://hooks.example.com/env", nil},
+		{"flags over definition", nil, []string{"--profile", "ci", "--postgres", "flag"}, "flag", "file.example.com", "ci.example.com", "https://hooks.example.com/config", []string{"--profile", "--postgres"}},
+	}
+	for _, tt := range tests {
+		for _, key := range []string{"POSTGRES_DSN", "SCOPE_DENY", "SCOPE_ALLOW", "MAX_LINE_BYTES", "HOOK"} {
+			unsetEnv(t, key)
+		}
+		for k, v := range tt.env {
+			os.Setenv(k, v)
+		}
+		args := append([]string{"--config", config, "run", "-f", file}, tt.args...)
+		target, _, err := executeCLI(t, args...)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		// Every target is scanned, in order.
+		if target != "example.org" {
+			t.Errorf("%s: last target scanned %q", tt.name, target)
+		}
+		if got := configuredDSN(); got != tt.dsn {
+			t.Errorf("%s: DSN %q, want %q", tt.name, got, tt.dsn)
+		}
+		if got := os.Getenv("SCOPE_DENY"); got != tt.deny {
+			t.Errorf("%s: SCOPE_DENY %q, want %q", tt.name, got, tt.deny)
+		}
+		if got := os.Getenv("SCOPE_ALLOW"); got != tt.allow {
+			t.Errorf("%s: SCOPE_ALLOW %q, want %q", tt.name, got, tt.allow)
+		}
+		// What the definition leaves alone still comes from the config.
+		if got := os.Getenv("MAX_LINE_BYTES"); got != "100" {
+			t.Errorf("%s: MAX_LINE_BYTES %q, want the config's", tt.name, got)
+		}
+		if got := scanDefinition.Notify[0].Webhook; got != tt.hook {
+			t.Errorf("%s: webhook %q, want %q", tt.name, got, tt.hook)
+		}
+		// The run records the references, not the values.
+		if rec := recordedDefinition; rec.Notify[0].Webhook != "${HOOK}" || !reflect.DeepEqual(rec.Overridden, tt.overridden) || rec.Profile != strings.TrimSuffix(tt.allow, ".example.com") {
+			t.Errorf("%s: recorded %+v", tt.name, rec)
+		}
+	}
+
+	bad := writeConfig(t, dir, "bad.yaml", "targets: [example.com]\nstages:\n  skip: [fuzing]\n")
+	target, _, err := executeCLI(t, "--config", config, "run", "-f", bad)
+	if err == nil || target != "" || !strings.Contains(err.Error(), `line 3: unknown stage "fuzing" in stages.skip; did you mean "fuzzing"?`) {
+		t.Errorf("invalid definition: scanned %q, err = %v", target, err)
+	}
+}
+
+func TestDefinitionSkips(t *testing.T) {
+	t.Cleanup(func() { scanDefinition = nil })
+	tests := []struct {
+		stages       types.ScanStages
+		fuzz, nuclei bool
+	}{
+		{types.ScanStages{}, false, false},
+		{types.ScanStages{Skip: []string{"fuzzing"}}, true, false},
+		{types.ScanStages{Only: []string{"fuzzing"}}, false, true},
+	}
+	for _, tt := range tests {
+		scanDefinition = &ScanDefinition{Stages: tt.stages}
+		if definitionSkips("fuzzing") != tt.fuzz || definitionSkips("nuclei scanning") != tt.nuclei {
+			t.Errorf("%+v: fuzzing skipped %v, nuclei %v", tt.stages, definitionSkips("fuzzing"), definitionSkips("nuclei scanning"))
+		}
+	}
+	scanDefinition = nil
+	if definitionSkips("fuzzing") {
+		t.Error("skipped without a scan definition")
+	}
+}
+
+func TestCLISubcommands(t *testing.T) {
+	root := newRootCmd()
+	defer newRootCmd()
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"example.com"}, "recon"},
+		{[]string{"--offline", "example.com"}, "recon"},
+		{[]string{"run", "-f", "scan.yaml", "--yes"}, "run"},
+		{[]string{"replay", "--verify", "run"}, "replay"},
+		{[]string{"--out", "runs", "reprocess", "run"}, "reprocess"},
+		{[]string{"import-nessus", "run", "scan.nessus"}, "import-nessus"},
+		{[]string{"debug-bundle", "-o", "x.tar.gz", "run"}, "debug-bundle"},
+		{[]string{"kb", "export", "-o", "x.tar.gz", "example.com"}, "export"},
+		{[]string{"kb", "import", "x.tar.gz"}, "import"},
+	}
+	for _, tt := range tests {
+		cmd, _, err := root.Find(tt.args)
+		if err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		if cmd.Name() != tt.want {
+			t.Errorf("%q runs %q, want %q", tt.args, cmd.Name(), tt.want)
+		}
+	}
+
+	config := writeConfig(t, t.TempDir(), "empty.env", "")
+	_, out, err := executeCLI(t, "--config", config, "help")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"run", "replay", "debug-bundle", "import-nessus", "reprocess", "kb", "completion", "--no-tui", "--offline"} {
+		if !strings.Contains(out, name) {
+			t.Errorf("help does not list %s:\n%s", name, out)
+		}
+	}
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		if _, out, err := executeCLI(t, "--config", config, "completion", shell); err != nil || !strings.Contains(out, "recon") {
+			t.Errorf("%s completion: %v\n%s", shell, err, out)
+		}
+	}
+}
+
+func TestCLICompletion(t *testing.T) {
+	runs := t.TempDir()
+	for _, d := range []string{"example.com_20261001_120000", "example.org_20261002_120000", "wordlists"} {
+		if err := os.Mkdir(filepath.Join(runs, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(t, runs, "example.net_20261003_120000", "not a directory")
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"reprocess", ""}, []string{"example.com_20261001_120000", "example.org_20261002_120000"}},
+		{[]string{"replay", "example.c"}, []string{"example.com_20261001_120000"}},
+		{[]string{"debug-bundle", ""}, []string{"example.com_20261001_120000", "example.org_20261002_120000"}},
+		{[]string{"import-nessus", "example.o"}, []string{"example.org_20261002_120000"}},
+		{[]string{"reprocess", "--baseline", ""}, []string{"example.com_20261001_120000", "example.org_20261002_120000"}},
+		{[]string{"--baseline", "example.org"}, []string{"example.org_20261002_120000"}},
+		{[]string{"kb", "show", ""}, nil},
+	}
+	for _, tt := range tests {
+		_, out, err := executeCLI(t, append([]string{"__complete", "--out", runs}, tt.args...)...)
+		if err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			if line != "" && !strings.HasPrefix(line, ":") && !strings.HasPrefix(line, "Completion ended") {
+				got = append(got, line)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q completes %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestResolveRunDir(t *testing.T) {
+	defer newRootCmd()
+	outRoot = t.TempDir()
+	run := filepath.Join(outRoot, "example.com_20261001_120000")
+	if err := os.Mkdir(run, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct{ dir, want string }{
+		{"example.com_20261001_120000", run},
+		{run, run},
+		{"example.org_20261001_120000", "example.org_20261001_120000"},
+		{".", "."},
+		{"", ""},
+	} {
+		if got := resolveRunDir(tt.dir); got != tt.want {
+			t.Errorf("resolveRunDir(%q) = %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+}
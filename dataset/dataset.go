@@ -0,0 +1,254 @@
+// dataset/dataset.go - Indexed, sortable and filterable rows for the TUI's long lists.
+package dataset
+
+import (
+	"sort"
+	"strings"
+)
+
+// Column is an order a Dataset can be sorted in. Less compares rows a and
+// b by their index in the caller's data.
+type Column struct {
+	Name string
+	Less func(a, b int) bool
+}
+
+// Dataset is a sorted and filtered view onto n rows the caller holds,
+// addressed by their index. Every sort order is computed once, on first
+// use, and kept; a filter query that extends the previous one only
+// rechecks the rows that matched it, and deleting back to a query typed
+// before restores its matches without a rescan. Reading a window of the view is
+// proportional to the window, not to n.
+//
+// A Dataset is not safe for concurrent use.
+type Dataset struct {
+	n       int
+	text    func(i int) string
+	columns []Column
+
+	// search holds every row's text lower-cased, built on the first Filter.
+	search []string
+	// orders holds each column's sort order once computed.
+	orders [][]int32
+
+	sortCol int
+	desc    bool
+	query   string
+	// hits are the rows matching query, in index order; matched marks
+	// them. Both are nil without a query.
+	hits    []int32
+	matched []bool
+	// narrowed holds the queries the current one extends and their hits,
+	// shortest first.
+	narrowed []filtered
+	// view is the rows shown, in order; nil means every row, in index
+	// order.
+	view []int32
+}
+
+// filtered is a query and the rows that matched it.
+type filtered struct {
+	query string
+	hits  []int32
+}
+
+// New returns a Dataset over n rows in index order. text returns the text
+// of row i that Filter searches; columns are the orders SortBy accepts.
+func New(n int, text func(i int) string, columns ...Column) *Dataset {
+	return &Dataset{
+		n:       n,
+		text:    text,
+		columns: columns,
+		orders:  make([][]int32, len(columns)),
+		sortCol: -1,
+	}
+}
+
+// Total is the number of rows, filtered out or not.
+func (d *Dataset) Total() int {
+	return d.n
+}
+
+// Len is the number of rows the view shows.
+func (d *Dataset) Len() int {
+	if d.view == nil {
+		return d.n
+	}
+	return len(d.view)
+}
+
+// Row returns the index of the row at position pos of the view.
+func (d *Dataset) Row(pos int) int {
+	if d.view == nil {
+		return pos
+	}
+	return int(d.view[pos])
+}
+
+// Window returns the indexes of at most count rows from position top of
+// the view on.
+func (d *Dataset) Window(top, count int) []int {
+	if top < 0 {
+		top = 0
+	}
+	end := top + count
+	if end > d.Len() {
+		end = d.Len()
+	}
+	var rows []int
+	for pos := top; pos < end; pos++ {
+		rows = append(rows, d.Row(pos))
+	}
+	return rows
+}
+
+// Columns returns the names of the orders SortBy accepts.
+func (d *Dataset) Columns() []string {
+	names := make([]string, len(d.columns))
+	for i, c := range d.columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// Sort returns the column the view is sorted by, -1 for index order, and
+// whether it is reversed.
+func (d *Dataset) Sort() (int, bool) {
+	return d.sortCol, d.desc
+}
+
+// SortBy orders the view by column col, or by index when col is out of
+// range, reversed when desc is set.
+func (d *Dataset) SortBy(col int, desc bool) {
+	if col < 0 || col >= len(d.columns) {
+		col = -1
+	}
+	if col == d.sortCol && desc == d.desc {
+		return
+	}
+	d.sortCol, d.desc = col, desc
+	d.rebuild()
+}
+
+// order returns the rows sorted by the current column, computing the order
+// the first time the column is used.
+func (d *Dataset) order() []int32 {
+	if d.sortCol < 0 {
+		return nil
+	}
+	if d.orders[d.sortCol] == nil {
+		order := make([]int32, d.n)
+		for i := range order {
+			order[i] = int32(i)
+		}
+		less := d.columns[d.sortCol].Less
+		// Rows that compare equal stay in index order.
+		sort.Slice(order, func(i, j int) bool {
+			a, b := int(order[i]), int(order[j])
+			if less(a, b) {
+				return true
+			}
+			return !less(b, a) && a < b
+		})
+		d.orders[d.sortCol] = order
+	}
+	return d.orders[d.sortCol]
+}
+
+// Query returns the filter in force.
+func (d *Dataset) Query() string {
+	return d.query
+}
+
+// Filter narrows the view to the rows whose text contains query, ignoring
+// case. An empty query shows every row.
+func (d *Dataset) Filter(query string) {
+	query = strings.ToLower(query)
+	if query == d.query {
+		return
+	}
+	switch {
+	case query == "":
+		d.hits, d.matched, d.narrowed = nil, nil, nil
+	case d.query != "" && strings.Contains(query, d.query):
+		// Whatever matches the longer query matched the shorter one.
+		d.narrowed = append(d.narrowed, filtered{d.query, d.hits})
+		kept := d.hits[:0:0]
+		for _, i := range d.hits {
+			if strings.Contains(d.search[i], query) {
+				kept = append(kept, i)
+			} else {
+				d.matched[i] = false
+			}
+		}
+		d.hits = kept
+	case d.restore(query):
+	default:
+		if d.search == nil {
+			d.search = make([]string, d.n)
+			for i := range d.search {
+				d.search[i] = strings.ToLower(d.text(i))
+			}
+		}
+		d.hits, d.narrowed = nil, nil
+		d.matched = make([]bool, d.n)
+		for i, s := range d.search {
+			if strings.Contains(s, query) {
+				d.hits = append(d.hits, int32(i))
+				d.matched[i] = true
+			}
+		}
+	}
+	d.query = query
+	d.rebuild()
+}
+
+// restore brings back the hits of query if the current query was narrowed
+// from it, dropping the longer queries in between.
+func (d *Dataset) restore(query string) bool {
+	for k := len(d.narrowed) - 1; k >= 0; k-- {
+		if d.narrowed[k].query != query {
+			continue
+		}
+		d.hits = d.narrowed[k].hits
+		d.narrowed = d.narrowed[:k]
+		d.matched = make([]bool, d.n)
+		for _, i := range d.hits {
+			d.matched[i] = true
+		}
+		return true
+	}
+	return false
+}
+
+// rebuild recomputes the view from the sort order and the filter.
+func (d *Dataset) rebuild() {
+	order := d.order()
+	switch {
+	case order == nil && d.matched == nil:
+		d.view = nil
+		if d.desc {
+			d.view = make([]int32, d.n)
+			for pos := range d.view {
+				d.view[pos] = int32(d.n - 1 - pos)
+			}
+		}
+		return
+	case order == nil:
+		d.view = append(make([]int32, 0, len(d.hits)), d.hits...)
+	case d.matched == nil:
+		d.view = append(make([]int32, 0, len(order)), order...)
+	default:
+		d.view = make([]int32, 0, len(d.hits))
+		for _, i := range order {
+			if d.matched[i] {
+				d.view = append(d.view, i)
+			}
+		}
+	}
+	if d.desc {
+		for l, r := 0, len(d.view)-1; l < r; l, r = l+1, r-1 {
+			d.view[l], d.view[r] = d.view[r], d.view[l]
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package dataset
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// hosts is a small fixture: hostnames with a status code each.
+var hosts = []struct {
+	name   string
+	status int
+}{
+	{"www.example.com", 200},
+	{"api.example.com", 401},
+	{"Admin.example.com", 200},
+	{"mail.example.com", 0},
+	{"dev-api.example.com", 500},
+	{"static.example.com", 200},
+	{"legacy.example.com", 301},
+}
+
+func newHosts() *Dataset {
+	return New(len(hosts), func(i int) string { return fmt.Sprintf("%s %d", hosts[i].name, hosts[i].status) },
+		Column{"host", func(a, b int) bool { return strings.ToLower(hosts[a].name) < strings.ToLower(hosts[b].name) }},
+		Column{"status", func(a, b int) bool { return hosts[a].status < hosts[b].status }},
+	)
+}
+
+// reference computes the view the slow way: filter, stable sort, reverse.
+func reference(col int, desc bool, query string) []int {
+	d := newHosts()
+	var rows []int
+	for i := range hosts {
+		if strings.Contains(strings.ToLower(d.text(i)), strings.ToLower(query)) {
+			rows = append(rows, i)
+		}
+	}
+	if col >= 0 {
+		less := d.columns[col].Less
+		sort.SliceStable(rows, func(i, j int) bool { return less(rows[i], rows[j]) })
+	}
+	if desc {
+		for l, r := 0, len(rows)-1; l < r; l, r = l+1, r-1 {
+			rows[l], rows[r] = rows[r], rows[l]
+		}
+	}
+	return rows
+}
+
+// view returns every row d shows, in order.
+func view(d *Dataset) []int {
+	var rows []int
+	for pos := 0; pos < d.Len(); pos++ {
+		rows = append(rows, d.Row(pos))
+	}
+	return rows
+}
+
+func TestDataset(t *testing.T) {
+	tests := []struct {
+		name  string
+		col   int
+		desc  bool
+		query string
+		want  []int
+	}{
+		{"index order", -1, false, "", []int{0, 1, 2, 3, 4, 5, 6}},
+		{"reversed", -1, true, "", []int{6, 5, 4, 3, 2, 1, 0}},
+		{"by host, ignoring case", 0, false, "", []int{2, 1, 4, 6, 3, 5, 0}},
+		{"by status, ties in index order", 1, false, "", []int{3, 0, 2, 5, 6, 1, 4}},
+		{"by status, reversed", 1, true, "", []int{4, 1, 6, 5, 2, 0, 3}},
+		{"filtered", -1, false, "API", []int{1, 4}},
+		{"filtered on status text", 1, false, "200", []int{0, 2, 5}},
+		{"filtered and sorted", 0, true, "a", []int{0, 5, 3, 6, 4, 1, 2}},
+		{"nothing matches", 0, false, "nope", nil},
+		{"out-of-range column is index order", 5, false, "", []int{0, 1, 2, 3, 4, 5, 6}},
+	}
+	for _, tt := range tests {
+		d := newHosts()
+		d.SortBy(tt.col, tt.desc)
+		d.Filter(tt.query)
+		if got := view(d); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+		if d.Total() != len(hosts) {
+			t.Errorf("%s: Total %d", tt.name, d.Total())
+		}
+	}
+}
+
+// TestDatasetSequences applies sorts and filters one after another, as
+// keystrokes would, and checks each view against the reference; narrowing,
+// deleting back to an earlier query and replacing the query take different
+// paths.
+func TestDatasetSequences(t *testing.T) {
+	steps := []struct {
+		col   int
+		desc  bool
+		query string
+	}{
+		{-1, false, "e"}, {-1, false, "ex"}, {1, false, "ex"}, {1, false, "exa"},
+		{0, true, "exa"}, {0, true, "api"}, {0, true, "ap"}, {1, true, ""},
+		{-1, true, "200"}, {-1, true, "2000"}, {0, false, "2000"}, {0, false, ""},
+		{1, false, "mail"}, {-1, false, "MAIL.EXAMPLE"},
+		{-1, false, "d"}, {-1, false, "de"}, {-1, false, "dev"}, {1, true, "de"},
+		{1, true, "d"}, {1, true, "de"}, {-1, false, "d"}, {-1, false, ""}, {-1, false, "de"},
+	}
+	d := newHosts()
+	for i, s := range steps {
+		d.SortBy(s.col, s.desc)
+		d.Filter(s.query)
+		want := reference(s.col, s.desc, s.query)
+		if s.col >= len(d.columns) {
+			want = reference(-1, s.desc, s.query)
+		}
+		if got := view(d); !reflect.DeepEqual(got, want) {
+			t.Errorf("step %d %+v: %v, want %v", i, s, got, want)
+		}
+		if col, desc := d.Sort(); col != s.col || desc != s.desc || d.Query() != strings.ToLower(s.query) {
+			t.Errorf("step %d: Sort %d, %v, Query %q", i, col, desc, d.Query())
+		}
+	}
+}
+
+func TestDatasetWindow(t *testing.T) {
+	d := newHosts()
+	d.SortBy(0, false)
+	tests := []struct {
+		top, count int
+		want       []int
+	}{
+		{0, 3, []int{2, 1, 4}},
+		{5, 3, []int{5, 0}},
+		{-2, 2, []int{2, 1}},
+		{7, 3, nil},
+		{2, 0, nil},
+	}
+	for _, tt := range tests {
+		if got := d.Window(tt.top, tt.count); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Window(%d, %d) = %v, want %v", tt.top, tt.count, got, tt.want)
+		}
+	}
+	if got := d.Columns(); !reflect.DeepEqual(got, []string{"host", "status"}) {
+		t.Errorf("Columns() = %q", got)
+	}
+}
+
+func TestViewport(t *testing.T) {
+	tests := []struct {
+		name   string
+		start  Viewport
+		move   func(v *Viewport, length int)
+		length int
+		want   int
+	}{
+		{"page down", Viewport{0, 10}, func(v *Viewport, n int) { v.PageDown(n) }, 100, 9},
+		{"page down at the end", Viewport{85, 10}, func(v *Viewport, n int) { v.PageDown(n) }, 100, 90},
+		{"page up", Viewport{50, 10}, func(v *Viewport, n int) { v.PageUp(n) }, 100, 41},
+		{"page up at the top", Viewport{3, 10}, func(v *Viewport, n int) { v.PageUp(n) }, 100, 0},
+		{"one-row pages", Viewport{5, 1}, func(v *Viewport, n int) { v.PageDown(n) }, 100, 6},
+		{"scroll", Viewport{5, 10}, func(v *Viewport, n int) { v.Scroll(-2, n) }, 100, 3},
+		{"home", Viewport{50, 10}, func(v *Viewport, n int) { v.Home() }, 100, 0},
+		{"end", Viewport{0, 10}, func(v *Viewport, n int) { v.End(n) }, 100, 90},
+		{"end of a short view", Viewport{0, 10}, func(v *Viewport, n int) { v.End(n) }, 4, 0},
+		{"view shrank under the viewport", Viewport{80, 10}, func(v *Viewport, n int) { v.Clamp(n) }, 30, 20},
+		{"empty view", Viewport{5, 10}, func(v *Viewport, n int) { v.Clamp(n) }, 0, 0},
+	}
+	for _, tt := range tests {
+		v := tt.start
+		tt.move(&v, tt.length)
+		if v.Top != tt.want {
+			t.Errorf("%s: top %d, want %d", tt.name, v.Top, tt.want)
+		}
+	}
+}
+
+// synthetic returns a Dataset over n generated URLs, sortable by URL and
+// by a pseudo-random size.
+func synthetic(n int) *Dataset {
+	urls := make([]string, n)
+	sizes := make([]int, n)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("https://host%d.example.com/path/%d/item?id=%d", i%997, i, i*7919%n)
+		sizes[i] = i * 2654435761 % 100003
+	}
+	return New(n, func(i int) string { return urls[i] },
+		Column{"url", func(a, b int) bool { return urls[a] < urls[b] }},
+		Column{"size", func(a, b int) bool { return sizes[a] < sizes[b] }},
+	)
+}
+
+// BenchmarkKeystroke measures the work behind one keystroke on 500k rows
+// once the indexes are built: a page move and the window to draw, under a
+// sort and a filter. It should stay well under 10ms.
+func BenchmarkKeystroke(b *testing.B) {
+	const rows, height = 500000, 50
+	d := synthetic(rows)
+	d.SortBy(1, false)
+	d.Filter("host1")
+	v := Viewport{Height: height}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%2 == 0 {
+			v.PageDown(d.Len())
+		} else {
+			v.End(d.Len())
+		}
+		if w := d.Window(v.Top, v.Height); len(w) == 0 {
+			b.Fatal("empty window")
+		}
+	}
+}
+
+// BenchmarkFilterNarrowing measures typing one more character of a filter
+// on 500k rows, which refines the current matches.
+func BenchmarkFilterNarrowing(b *testing.B) {
+	d := synthetic(500000)
+	d.SortBy(0, false)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		d.Filter("host1")
+		b.StartTimer()
+		d.Filter("host12")
+	}
+}
+
+// BenchmarkFilterDeleting measures deleting the last character of a filter
+// on 500k rows, which restores the shorter query's matches.
+func BenchmarkFilterDeleting(b *testing.B) {
+	d := synthetic(500000)
+	d.SortBy(0, false)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		d.Filter("host1")
+		d.Filter("host12")
+		b.StartTimer()
+		d.Filter("host1")
+	}
+}
+
+// BenchmarkSortToggle measures switching between two sort orders already
+// computed on 500k rows.
+func BenchmarkSortToggle(b *testing.B) {
+	d := synthetic(500000)
+	d.SortBy(0, false)
+	d.SortBy(1, false)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.SortBy(i%2, i%4 >= 2)
+	}
+}
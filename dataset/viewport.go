@@ -0,0 +1,54 @@
+package dataset
+
+// Viewport is the part of a view on screen: Height rows from position Top.
+// Every move is constant time whatever the length of the view.
+type Viewport struct {
+	Top    int
+	Height int
+}
+
+// Clamp keeps the viewport within a view of length rows, filling the
+// screen when there are enough rows to.
+func (v *Viewport) Clamp(length int) {
+	if v.Top > length-v.Height {
+		v.Top = length - v.Height
+	}
+	if v.Top < 0 {
+		v.Top = 0
+	}
+}
+
+// Scroll moves the viewport by delta rows, down when positive.
+func (v *Viewport) Scroll(delta, length int) {
+	v.Top += delta
+	v.Clamp(length)
+}
+
+// PageDown moves the viewport one screen down.
+func (v *Viewport) PageDown(length int) {
+	v.Scroll(v.page(), length)
+}
+
+// PageUp moves the viewport one screen up.
+func (v *Viewport) PageUp(length int) {
+	v.Scroll(-v.page(), length)
+}
+
+// Home moves the viewport to the first row.
+func (v *Viewport) Home() {
+	v.Top = 0
+}
+
+// End moves the viewport to show the last rows.
+func (v *Viewport) End(length int) {
+	v.Top = length
+	v.Clamp(length)
+}
+
+// page is how far a page moves: a screen, keeping one row of context.
+func (v *Viewport) page() int {
+	if v.Height > 1 {
+		return v.Height - 1
+	}
+	return 1
+}
@@ -0,0 +1,234 @@
+// exporters/defectdojo.go - DefectDojo "Generic Findings Import" export and API upload.
+package exporters
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// DefectDojoReport is the top-level document of the Generic Findings Import format.
+type DefectDojoReport struct {
+	Findings []DefectDojoFinding `json:"findings"`
+}
+
+// DefectDojoFinding is a single finding in the Generic Findings Import format.
+type DefectDojoFinding struct {
+	Title            string               `json:"title"`
+	Severity         string               `json:"severity"`
+	Description      string               `json:"description"`
+	Date             string               `json:"date"`
+	Active           bool                 `json:"active"`
+	Verified         bool                 `json:"verified"`
+	FalsePositive    bool                 `json:"false_p"`
+	Duplicate        bool                 `json:"duplicate"`
+	UniqueIDFromTool string               `json:"unique_id_from_tool"`
+	VulnIDFromTool   string               `json:"vuln_id_from_tool,omitempty"`
+	CVSSv3           string               `json:"cvssv3,omitempty"`
+	CVSSv3Score      float64              `json:"cvssv3_score,omitempty"`
+	CWE              int                  `json:"cwe,omitempty"`
+	Mitigation       string               `json:"mitigation,omitempty"`
+	Tags             []string             `json:"tags,omitempty"`
+	Endpoints        []DefectDojoEndpoint `json:"endpoints,omitempty"`
+}
+
+// DefectDojoEndpoint describes the affected URL of a finding.
+type DefectDojoEndpoint struct {
+	Protocol string `json:"protocol,omitempty"`
+	Host     string `json:"host"`
+	Port     int    `json:"port,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Query    string `json:"query,omitempty"`
+}
+
+// DefectDojoConfig holds the parameters for a direct API upload.
+type DefectDojoConfig struct {
+	URL          string
+	APIKey       string
+	EngagementID string
+	TestTitle    string
+}
+
+// defectDojoScanType is the parser DefectDojo applies to our export.
+const defectDojoScanType = "Generic Findings Import"
+
+// Fingerprint returns a stable identifier for a finding so repeated imports deduplicate.
+func Fingerprint(v types.VulnerabilityResult) string {
+	return utils.FindingFingerprint(v)
+}
+
+// endpointFromURL splits a finding URL into DefectDojo's endpoint fields.
+func endpointFromURL(raw string) (DefectDojoEndpoint, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return DefectDojoEndpoint{}, false
+	}
+	ep := DefectDojoEndpoint{
+		Protocol: u.Scheme,
+		Host:     u.Hostname(),
+		Path:     u.Path,
+		Query:    u.RawQuery,
+	}
+	if p, err := strconv.Atoi(u.Port()); err == nil {
+		ep.Port = p
+	}
+	return ep, true
+}
+
+// defectDojoSeverity rates v for DefectDojo, which has no level for a
+// CVSS score of None: such findings are Info.
+func defectDojoSeverity(v types.VulnerabilityResult) string {
+	if s := utils.FindingSeverity(v); s != "None" {
+		return s
+	}
+	return "Info"
+}
+
+// tlsFindings converts testssl.sh results, which the scan keeps apart from
+// the other findings, into testssl-finding vulnerability results.
+func tlsFindings(tls []types.TLSResult) []types.VulnerabilityResult {
+	var vulns []types.VulnerabilityResult
+	for _, t := range tls {
+		vulns = append(vulns, types.VulnerabilityResult{
+			URL:      "https://" + t.Host,
+			Issue:    "testssl " + t.ID,
+			Type:     "testssl-finding",
+			Severity: t.Severity,
+			Detail:   t.Finding,
+		})
+	}
+	return vulns
+}
+
+// BuildDefectDojoReport converts vulnerability results and testssl.sh
+// results into a Generic Findings Import document. The issue type goes to
+// vuln_id_from_tool, and findings without a CWE of their own get the
+// type's. A finding is active unless it is tentative and verified when it
+// is confirmed; one triaged a false positive or duplicate is neither, as
+// DefectDojo rejects those combinations.
+func BuildDefectDojoReport(vulns []types.VulnerabilityResult, tls []types.TLSResult, now time.Time) DefectDojoReport {
+	report := DefectDojoReport{Findings: []DefectDojoFinding{}}
+	for _, v := range append(append([]types.VulnerabilityResult(nil), vulns...), tlsFindings(tls)...) {
+		confidence := utils.FindingConfidence(v)
+		dismissed := v.Disposition == utils.DispositionFalsePositive || v.Disposition == utils.DispositionDuplicate
+		finding := DefectDojoFinding{
+			Title:            fmt.Sprintf("%s at %s", v.Issue, v.URL),
+			Severity:         defectDojoSeverity(v),
+			Description:      fmt.Sprintf("**Issue:** %s\n\n**URL:** %s\n\n**Confidence:** %s\n\n**Evidence:**\n\n```\n%s\n```", v.Issue, v.URL, confidence, v.Detail),
+			Date:             now.Format("2006-01-02"),
+			Active:           confidence != utils.ConfidenceTentative && !dismissed,
+			Verified:         confidence == utils.ConfidenceConfirmed && !dismissed,
+			FalsePositive:    v.Disposition == utils.DispositionFalsePositive,
+			Duplicate:        v.Disposition == utils.DispositionDuplicate,
+			UniqueIDFromTool: Fingerprint(v),
+			VulnIDFromTool:   v.Type,
+			CVSSv3:           v.Vector,
+			CVSSv3Score:      v.Score,
+			CWE:              v.CWE,
+			Tags:             utils.TagsMatching(v.Tags, "*"),
+		}
+		if t, ok := utils.LookupIssueType(v.Type); ok {
+			finding.Mitigation = t.Remediation
+			if finding.CWE == 0 {
+				finding.CWE = t.CWE
+			}
+		}
+		if v.Disposition != "" {
+			finding.Description += fmt.Sprintf("\n\n**Triage:** %s", v.Disposition)
+			if v.TriageNote != "" {
+				finding.Description += " (" + v.TriageNote + ")"
+			}
+		}
+		if ep, ok := endpointFromURL(v.URL); ok {
+			finding.Endpoints = []DefectDojoEndpoint{ep}
+		}
+		report.Findings = append(report.Findings, finding)
+	}
+	return report
+}
+
+// WriteDefectDojo writes the findings and testssl.sh results of result to
+// defectdojo.json in outDir and returns its path.
+func WriteDefectDojo(result types.ScanResult, outDir string) (string, error) {
+	data, err := json.MarshalIndent(BuildDefectDojoReport(result.VulnURLs, result.TLS, time.Now()), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(outDir, "defectdojo.json")
+	return path, ioutil.WriteFile(path, data, 0644)
+}
+
+// DefectDojoConfigFromEnv reads the upload settings from the environment.
+// ok is false when no DefectDojo instance is configured.
+func DefectDojoConfigFromEnv() (cfg DefectDojoConfig, ok bool) {
+	cfg = DefectDojoConfig{
+		URL:          strings.TrimRight(os.Getenv("DEFECTDOJO_URL"), "/"),
+		APIKey:       os.Getenv("DEFECTDOJO_API_KEY"),
+		EngagementID: os.Getenv("DEFECTDOJO_ENGAGEMENT_ID"),
+		TestTitle:    os.Getenv("DEFECTDOJO_TEST_TITLE"),
+	}
+	return cfg, cfg.URL != "" && cfg.APIKey != "" && cfg.EngagementID != ""
+}
+
+// UploadDefectDojo imports the export file through DefectDojo's /api/v2/import-scan/ endpoint.
+func UploadDefectDojo(client *http.Client, cfg DefectDojoConfig, reportPath string) error {
+	data, err := ioutil.ReadFile(reportPath)
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	fields := map[string]string{
+		"scan_type":  defectDojoScanType,
+		"engagement": cfg.EngagementID,
+		"active":     "true",
+		"verified":   "false",
+	}
+	if cfg.TestTitle != "" {
+		fields["test_title"] = cfg.TestTitle
+	}
+	for k, v := range fields {
+		if err := w.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+	part, err := w.CreateFormFile("file", filepath.Base(reportPath))
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, cfg.URL+"/api/v2/import-scan/", &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+cfg.APIKey)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("DefectDojo import failed (%d): %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package exporters
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+func TestBuildDefectDojoReport(t *testing.T) {
+	t.Setenv("SEVERITY_FROM_CVSS", "")
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		v    types.VulnerabilityResult
+		want DefectDojoFinding
+	}{
+		{
+			name: "taxonomy severity, CWE and remediation",
+			v:    types.VulnerabilityResult{URL: "https://app.example.com:8443/item?id=1", Issue: "SQL Injection", Type: "sqli", Detail: "id=1' AND SLEEP(5)--"},
+			want: DefectDojoFinding{
+				Title: "SQL Injection at https://app.example.com:8443/item?id=1", Severity: "High", Active: true,
+				VulnIDFromTool: "sqli", CWE: 89,
+				Endpoints: []DefectDojoEndpoint{{Protocol: "https", Host: "app.example.com", Port: 8443, Path: "/item", Query: "id=1"}},
+			},
+		},
+		{
+			name: "tool severity wins, tool CWE kept",
+			v:    types.VulnerabilityResult{URL: "https://www.example.com/", Issue: "Template match", Type: "nuclei-finding", Severity: "critical", CWE: 22},
+			want: DefectDojoFinding{Title: "Template match at https://www.example.com/", Severity: "Critical", Active: true, VulnIDFromTool: "nuclei-finding", CWE: 22,
+				Endpoints: []DefectDojoEndpoint{{Protocol: "https", Host: "www.example.com", Path: "/"}}},
+		},
+		{
+			name: "informational and unknown severities",
+			v:    types.VulnerabilityResult{URL: "https://www.example.com/", Issue: "Scan info", Type: "nessus-plugin", Severity: "informational"},
+			want: DefectDojoFinding{Title: "Scan info at https://www.example.com/", Severity: "Info", Active: true, VulnIDFromTool: "nessus-plugin",
+				Endpoints: []DefectDojoEndpoint{{Protocol: "https", Host: "www.example.com", Path: "/"}}},
+		},
+		{
+			name: "unregistered type",
+			v:    types.VulnerabilityResult{URL: "not a url", Issue: "Odd", Type: "no-such-type"},
+			want: DefectDojoFinding{Title: "Odd at not a url", Severity: "Info", Active: true, VulnIDFromTool: "no-such-type"},
+		},
+		{
+			name: "confirmed",
+			v:    types.VulnerabilityResult{URL: "http://api.example.com/q", Issue: "XSS", Type: "xss", Confidence: utils.ConfidenceConfirmed},
+			want: DefectDojoFinding{Title: "XSS at http://api.example.com/q", Severity: "Medium", Active: true, Verified: true, VulnIDFromTool: "xss", CWE: 79,
+				Endpoints: []DefectDojoEndpoint{{Protocol: "http", Host: "api.example.com", Path: "/q"}}},
+		},
+		{
+			name: "firm",
+			v:    types.VulnerabilityResult{URL: "http://api.example.com/q", Issue: "XSS", Type: "xss", Confidence: utils.ConfidenceFirm},
+			want: DefectDojoFinding{Title: "XSS at http://api.example.com/q", Severity: "Medium", Active: true, VulnIDFromTool: "xss", CWE: 79,
+				Endpoints: []DefectDojoEndpoint{{Protocol: "http", Host: "api.example.com", Path: "/q"}}},
+		},
+		{
+			name: "tentative",
+			v:    types.VulnerabilityResult{URL: "http://api.example.com/q", Issue: "Reflected Parameter", Type: "reflected-parameter", Confidence: utils.ConfidenceTentative},
+			want: DefectDojoFinding{Title: "Reflected Parameter at http://api.example.com/q", Severity: "Low", VulnIDFromTool: "reflected-parameter", CWE: 79,
+				Endpoints: []DefectDojoEndpoint{{Protocol: "http", Host: "api.example.com", Path: "/q"}}},
+		},
+		{
+			// The triage disposition is a note; the confidence sets the flags.
+			name: "triaged confirmed, tentative",
+			v: types.VulnerabilityResult{URL: "http://api.example.com/q", Issue: "Reflected Parameter", Type: "reflected-parameter", Confidence: utils.ConfidenceTentative,
+				Disposition: utils.DispositionConfirmed, TriageNote: "alert(1) fires"},
+			want: DefectDojoFinding{Title: "Reflected Parameter at http://api.example.com/q", Severity: "Low", VulnIDFromTool: "reflected-parameter", CWE: 79,
+				Endpoints: []DefectDojoEndpoint{{Protocol: "http", Host: "api.example.com", Path: "/q"}}},
+		},
+		{
+			name: "false positive",
+			v:    types.VulnerabilityResult{URL: "http://api.example.com/q", Issue: "XSS", Type: "xss", Confidence: utils.ConfidenceConfirmed, Disposition: utils.DispositionFalsePositive},
+			want: DefectDojoFinding{Title: "XSS at http://api.example.com/q", Severity: "Medium", FalsePositive: true, VulnIDFromTool: "xss", CWE: 79,
+				Endpoints: []DefectDojoEndpoint{{Protocol: "http", Host: "api.example.com", Path: "/q"}}},
+		},
+		{
+			name: "duplicate",
+			v:    types.VulnerabilityResult{URL: "http://api.example.com/q", Issue: "XSS", Type: "xss", Disposition: utils.DispositionDuplicate},
+			want: DefectDojoFinding{Title: "XSS at http://api.example.com/q", Severity: "Medium", Duplicate: true, VulnIDFromTool: "xss", CWE: 79,
+				Endpoints: []DefectDojoEndpoint{{Protocol: "http", Host: "api.example.com", Path: "/q"}}},
+		},
+		{
+			name: "CVSS and tags",
+			v: types.VulnerabilityResult{URL: "https://www.example.com/", Issue: "Exposed Git", Type: "exposed-git", Score: 7.5,
+				Vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N", Tags: map[string][]string{"env": {"prod"}, "owner": {"web"}}},
+			want: DefectDojoFinding{Title: "Exposed Git at https://www.example.com/", Severity: "High", Active: true, VulnIDFromTool: "exposed-git", CWE: 527,
+				CVSSv3: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N", CVSSv3Score: 7.5, Tags: []string{"env:prod", "owner:web"},
+				Endpoints: []DefectDojoEndpoint{{Protocol: "https", Host: "www.example.com", Path: "/"}}},
+		},
+	}
+	for _, tt := range tests {
+		report := BuildDefectDojoReport([]types.VulnerabilityResult{tt.v}, nil, now)
+		if len(report.Findings) != 1 {
+			t.Errorf("%s: %d findings", tt.name, len(report.Findings))
+			continue
+		}
+		got := report.Findings[0]
+		if got.Date != "2026-10-15" || got.UniqueIDFromTool != utils.FindingFingerprint(tt.v) {
+			t.Errorf("%s: date %q, unique ID %q", tt.name, got.Date, got.UniqueIDFromTool)
+		}
+		if !strings.Contains(got.Description, tt.v.Detail) || !strings.Contains(got.Description, tt.v.TriageNote) ||
+			!strings.Contains(got.Description, "**Confidence:** "+utils.FindingConfidence(tt.v)) {
+			t.Errorf("%s: description lacks the evidence or triage note:\n%s", tt.name, got.Description)
+		}
+		want := tt.want
+		if it, ok := utils.LookupIssueType(tt.v.Type); ok {
+			want.Mitigation = it.Remediation
+		}
+		got.Date, got.UniqueIDFromTool, got.Description = "", "", ""
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got, want)
+		}
+	}
+}
+
+func TestBuildDefectDojoReportSeverities(t *testing.T) {
+	for _, tt := range []struct {
+		severity, cvss string
+		score          float64
+		want           string
+	}{
+		{"HIGH", "", 0, "High"},
+		{"medium", "", 0, "Medium"},
+		{"Low", "", 0, "Low"},
+		{"info", "", 0, "Info"},
+		{"unknown", "", 0, "Info"},
+		{"low", "true", 9.8, "Critical"},
+		{"", "true", 5.3, "Medium"},
+		{"critical", "false", 3.1, "Critical"},
+	} {
+		t.Setenv("SEVERITY_FROM_CVSS", tt.cvss)
+		v := types.VulnerabilityResult{URL: "https://www.example.com/", Issue: "x", Type: "nuclei-finding", Severity: tt.severity, Score: tt.score}
+		if got := BuildDefectDojoReport([]types.VulnerabilityResult{v}, nil, time.Now()).Findings[0].Severity; got != tt.want {
+			t.Errorf("severity %q, score %v (SEVERITY_FROM_CVSS=%q): %s, want %s", tt.severity, tt.score, tt.cvss, got, tt.want)
+		}
+	}
+}
+
+func TestBuildDefectDojoReportSchema(t *testing.T) {
+	tls := []types.TLSResult{{Host: "www.example.com:443", ID: "SWEET32", Severity: "LOW", Finding: "uses 64 bit block ciphers"}}
+	report := BuildDefectDojoReport(nil, tls, time.Now())
+	if len(report.Findings) != 1 {
+		t.Fatalf("%d findings from one testssl.sh result", len(report.Findings))
+	}
+	if f := report.Findings[0]; f.Title != "testssl SWEET32 at https://www.example.com:443" || f.Severity != "Low" || f.VulnIDFromTool != "testssl-finding" {
+		t.Errorf("testssl.sh finding %+v", f)
+	}
+
+	// An empty report is still a document DefectDojo accepts.
+	data, err := json.Marshal(BuildDefectDojoReport(nil, nil, time.Now()))
+	if err != nil || string(data) != `{"findings":[]}` {
+		t.Errorf("empty report %s, %v", data, err)
+	}
+
+	data, err = json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Findings []map[string]interface{} `json:"findings"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"title", "severity", "description", "date", "active", "verified", "false_p", "duplicate", "unique_id_from_tool", "vuln_id_from_tool", "endpoints"} {
+		if _, ok := doc.Findings[0][key]; !ok {
+			t.Errorf("finding has no %q: %s", key, data)
+		}
+	}
+}
+
+func TestDefectDojoConfigFromEnv(t *testing.T) {
+	for _, tt := range []struct {
+		url, key, engagement string
+		want                 bool
+	}{
+		{"https://dojo.example.com/", "token", "7", true},
+		{"", "token", "7", false},
+		{"https://dojo.example.com", "", "7", false},
+		{"https://dojo.example.com", "token", "", false},
+	} {
+		t.Setenv("DEFECTDOJO_URL", tt.url)
+		t.Setenv("DEFECTDOJO_API_KEY", tt.key)
+		t.Setenv("DEFECTDOJO_ENGAGEMENT_ID", tt.engagement)
+		cfg, ok := DefectDojoConfigFromEnv()
+		if ok != tt.want {
+			t.Errorf("%+v: ok = %v, want %v", tt, ok, tt.want)
+		}
+		if strings.HasSuffix(cfg.URL, "/") {
+			t.Errorf("URL %q keeps its trailing slash", cfg.URL)
+		}
+	}
+}
+
+func TestUploadDefectDojo(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "defectdojo.json")
+	if err := ioutil.WriteFile(report, []byte(`{"findings":[]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"imported", http.StatusCreated, `{"test": 12}`, ""},
+		{"validation error", http.StatusBadRequest, `{"engagement": ["Invalid pk \"7\" - object does not exist."]}` + "\n", `DefectDojo import failed (400): {"engagement": ["Invalid pk \"7\" - object does not exist."]}`},
+	}
+	for _, tt := range tests {
+		var fields map[string]string
+		var file string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || r.URL.Path != "/api/v2/import-scan/" || r.Header.Get("Authorization") != "Token secret" {
+				http.Error(w, "unexpected request "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+				return
+			}
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			fields = map[string]string{}
+			for k, v := range r.MultipartForm.Value {
+				fields[k] = v[0]
+			}
+			if f, _, err := r.FormFile("file"); err == nil {
+				data, _ := ioutil.ReadAll(f)
+				file = string(data)
+			}
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+		cfg := DefectDojoConfig{URL: srv.URL, APIKey: "secret", EngagementID: "7", TestTitle: "recon example.com"}
+		err := UploadDefectDojo(srv.Client(), cfg, report)
+		srv.Close()
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+		}
+		want := map[string]string{"scan_type": "Generic Findings Import", "engagement": "7", "active": "true", "verified": "false", "test_title": "recon example.com"}
+		if !reflect.DeepEqual(fields, want) || file != `{"findings":[]}` {
+			t.Errorf("%s: uploaded fields %v, file %q", tt.name, fields, file)
+		}
+	}
+
+	if err := UploadDefectDojo(http.DefaultClient, DefectDojoConfig{URL: "http://127.0.0.1:0"}, filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("uploaded a missing report")
+	}
+}
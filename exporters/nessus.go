@@ -0,0 +1,171 @@
+// exporters/nessus.go - Target lists for Nessus and OpenVAS, and import of .nessus results.
+package exporters
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// nessusPolicy is the minimal .nessus document Nessus reads a target list
+// from: a policy whose TARGET server preference lists the targets.
+type nessusPolicy struct {
+	XMLName     xml.Name           `xml:"NessusClientData_v2"`
+	PolicyName  string             `xml:"Policy>policyName"`
+	Preferences []nessusPreference `xml:"Policy>Preferences>ServerPreferences>preference"`
+}
+
+type nessusPreference struct {
+	Name  string `xml:"name"`
+	Value string `xml:"value"`
+}
+
+// NessusTargets returns the deduplicated addresses and hostnames of every
+// resolved subdomain, addresses first, each group sorted.
+func NessusTargets(result types.ScanResult) []string {
+	var ips, names []string
+	for _, s := range result.Subdomains {
+		resolved := false
+		for _, ip := range append(append([]string{s.IP}, s.IPs...), s.IPv6...) {
+			if ip != "" {
+				ips = append(ips, ip)
+				resolved = true
+			}
+		}
+		if resolved {
+			names = append(names, utils.NormalizeHostname(s.Hostname))
+		}
+	}
+	ips, names = utils.UniqueStrings(ips), utils.UniqueStrings(names)
+	sort.Strings(ips)
+	sort.Strings(names)
+	return append(ips, names...)
+}
+
+// WriteNessusTargets writes the target list as nessus_targets.txt, one
+// target per line for OpenVAS and Nessus' own list import, and as
+// nessus_targets.nessus.
+func WriteNessusTargets(result types.ScanResult, outDir string) error {
+	targets := NessusTargets(result)
+	if err := utils.WriteLines(targets, filepath.Join(outDir, "nessus_targets.txt")); err != nil {
+		return err
+	}
+	doc := nessusPolicy{
+		PolicyName:  "recon targets",
+		Preferences: []nessusPreference{{Name: "TARGET", Value: strings.Join(targets, ",")}},
+	}
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(xml.Header), data...)
+	return ioutil.WriteFile(filepath.Join(outDir, "nessus_targets.nessus"), append(data, '\n'), 0644)
+}
+
+// nessusSeverities names Nessus' numeric severities.
+var nessusSeverities = []string{"info", "low", "medium", "high", "critical"}
+
+// ImportNessus turns .nessus plugin results into findings with Source
+// "nessus", of type nessus-plugin with the plugin's CWE. Hosts are mapped back onto the subdomains by address, or by
+// name when Nessus scanned a hostname; a finding is reported on the first
+// matching hostname and names the others in its detail. Findings on hosts
+// the scan does not know are kept, tagged state:unmatched, and those hosts
+// are returned in unmatched. Informational results are left out unless
+// includeInfo is set.
+func ImportNessus(hosts []parsers.NessusHost, result types.ScanResult, includeInfo bool) (vulns []types.VulnerabilityResult, unmatched []string) {
+	byIP := make(map[string][]string)
+	known := make(map[string]bool)
+	for _, s := range result.Subdomains {
+		name := utils.NormalizeHostname(s.Hostname)
+		known[name] = true
+		for _, ip := range append(append([]string{s.IP}, s.IPs...), s.IPv6...) {
+			if ip != "" {
+				byIP[ip] = append(byIP[ip], name)
+			}
+		}
+	}
+	seen := make(map[string]bool)
+	for _, h := range hosts {
+		names := utils.UniqueStrings(byIP[h.IP])
+		sort.Strings(names)
+		if len(names) == 0 {
+			for _, n := range []string{h.FQDN, h.Name} {
+				if n = utils.NormalizeHostname(n); known[n] {
+					names = []string{n}
+					break
+				}
+			}
+		}
+		host := h.Name
+		if len(names) > 0 {
+			host = names[0]
+		} else if h.FQDN != "" {
+			host = h.FQDN
+		}
+		matched := len(names) > 0
+		if !matched {
+			unmatched = append(unmatched, host)
+		}
+		for _, it := range h.Items {
+			if it.Severity <= 0 && !includeInfo {
+				continue
+			}
+			v := types.VulnerabilityResult{
+				URL:      host,
+				Issue:    it.PluginName,
+				Type:     "nessus-plugin",
+				CWE:      it.CWE,
+				Detail:   it.Output,
+				Vector:   it.CVSS3,
+				Score:    it.CVSS3Score,
+				Severity: nessusSeverity(it),
+				Source:   "nessus",
+			}
+			if it.Port > 0 {
+				proto := it.Protocol
+				if proto == "" {
+					proto = "tcp"
+				}
+				v.URL = proto + "://" + net.JoinHostPort(host, strconv.Itoa(it.Port))
+			}
+			if v.Detail == "" {
+				v.Detail = it.Synopsis
+			}
+			v.Detail = fmt.Sprintf("Nessus plugin %s: %s", it.PluginID, v.Detail)
+			if len(names) > 1 {
+				v.Detail += "\nAlso served from " + h.IP + ": " + strings.Join(names[1:], ", ")
+			}
+			if !matched {
+				v.Tags = utils.AddTag(v.Tags, utils.TagState+":unmatched")
+			}
+			if fp := utils.FindingFingerprint(v); !seen[fp] {
+				seen[fp] = true
+				vulns = append(vulns, v)
+			}
+		}
+	}
+	return vulns, utils.UniqueStrings(unmatched)
+}
+
+// nessusSeverity prefers the risk factor's name over the numeric rating.
+func nessusSeverity(it parsers.NessusItem) string {
+	switch rf := strings.ToLower(it.RiskFactor); rf {
+	case "none":
+		return "info"
+	case "low", "medium", "high", "critical":
+		return rf
+	}
+	if it.Severity >= 0 && it.Severity < len(nessusSeverities) {
+		return nessusSeverities[it.Severity]
+	}
+	return ""
+}
@@ -0,0 +1,112 @@
+package exporters
+
+import (
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// nessusFixture has two hosts this run resolved, one it never saw, an
+// informational result and CWEs given as <cwe> and as a cross reference.
+const nessusFixture = `<?xml version="1.0" ?>
+<NessusClientData_v2>
+<Report name="recon">
+<ReportHost name="203.0.113.10">
+<HostProperties>
+<tag name="host-ip">203.0.113.10</tag>
+<tag name="host-fqdn">www.example.com</tag>
+</HostProperties>
+<ReportItem port="443" svc_name="www" protocol="tcp" severity="2" pluginID="42873" pluginName="SSL Medium Strength Cipher Suites Supported (SWEET32)">
+<risk_factor>Medium</risk_factor>
+<synopsis>The remote service supports the use of medium strength SSL ciphers.</synopsis>
+<plugin_output>Medium Strength Ciphers : DES-CBC3-SHA</plugin_output>
+<cvss3_base_score>7.5</cvss3_base_score>
+<cvss3_vector>CVSS:3.0/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N</cvss3_vector>
+<cwe>327</cwe>
+</ReportItem>
+<ReportItem port="0" svc_name="general" protocol="tcp" severity="0" pluginID="19506" pluginName="Nessus Scan Information">
+<risk_factor>None</risk_factor>
+<plugin_output>Nessus version : 10.7.1</plugin_output>
+</ReportItem>
+</ReportHost>
+<ReportHost name="api.example.com">
+<HostProperties>
+<tag name="host-ip">203.0.113.20</tag>
+</HostProperties>
+<ReportItem port="80" svc_name="www" protocol="tcp" severity="3" pluginID="98115" pluginName="Cross-Site Scripting">
+<risk_factor>High</risk_factor>
+<synopsis>Reflected XSS.</synopsis>
+<xref>OWASP:A7</xref>
+<xref>CWE:79</xref>
+</ReportItem>
+</ReportHost>
+<ReportHost name="198.51.100.7">
+<ReportItem port="22" svc_name="ssh" protocol="tcp" severity="1" pluginID="70658" pluginName="SSH Server CBC Mode Ciphers Enabled">
+<risk_factor>Low</risk_factor>
+<plugin_output>aes128-cbc</plugin_output>
+</ReportItem>
+</ReportHost>
+</Report>
+</NessusClientData_v2>`
+
+func TestImportNessus(t *testing.T) {
+	hosts, err := parsers.ParseNessus([]byte(nessusFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := types.ScanResult{Subdomains: []types.SubdomainResult{
+		{Hostname: "www.example.com", IP: "203.0.113.10"},
+		{Hostname: "api.example.com", IPs: []string{"203.0.113.20"}},
+	}}
+	type want struct {
+		url, severity string
+		cwe           int
+		unmatched     bool
+	}
+	tests := []struct {
+		name        string
+		includeInfo bool
+		want        map[string]want
+	}{
+		{"without info", false, map[string]want{
+			"SSL Medium Strength Cipher Suites Supported (SWEET32)": {"tcp://www.example.com:443", "medium", 327, false},
+			"Cross-Site Scripting":                {"tcp://api.example.com:80", "high", 79, false},
+			"SSH Server CBC Mode Ciphers Enabled": {"tcp://198.51.100.7:22", "low", 0, true},
+		}},
+		{"with info", true, map[string]want{
+			"SSL Medium Strength Cipher Suites Supported (SWEET32)": {"tcp://www.example.com:443", "medium", 327, false},
+			"Nessus Scan Information":                               {"www.example.com", "info", 0, false},
+			"Cross-Site Scripting":                                  {"tcp://api.example.com:80", "high", 79, false},
+			"SSH Server CBC Mode Ciphers Enabled":                   {"tcp://198.51.100.7:22", "low", 0, true},
+		}},
+	}
+	for _, tt := range tests {
+		vulns, unmatched := ImportNessus(hosts, result, tt.includeInfo)
+		if len(unmatched) != 1 || unmatched[0] != "198.51.100.7" {
+			t.Errorf("%s: unmatched %v", tt.name, unmatched)
+		}
+		if len(vulns) != len(tt.want) {
+			t.Errorf("%s: %d finding(s), want %d: %+v", tt.name, len(vulns), len(tt.want), vulns)
+		}
+		for _, v := range vulns {
+			w, ok := tt.want[v.Issue]
+			if !ok {
+				t.Errorf("%s: unexpected finding %q", tt.name, v.Issue)
+				continue
+			}
+			if v.URL != w.url || v.Severity != w.severity || v.CWE != w.cwe || v.Source != "nessus" || v.Type != "nessus-plugin" {
+				t.Errorf("%s: %q = %+v, want %+v", tt.name, v.Issue, v, w)
+			}
+			if got := utils.HasTag(v.Tags, utils.TagState+":unmatched"); got != w.unmatched {
+				t.Errorf("%s: %q unmatched tag %v, want %v", tt.name, v.Issue, got, w.unmatched)
+			}
+		}
+		// The imported findings survive the taxonomy, keeping their CWEs.
+		kept, unclassified := utils.IngestFindings(vulns)
+		if len(kept) != len(vulns) || len(unclassified) != 0 {
+			t.Errorf("%s: unclassified by the taxonomy: %v", tt.name, unclassified)
+		}
+	}
+}
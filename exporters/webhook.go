@@ -0,0 +1,76 @@
+// exporters/webhook.go - Finding notifications posted to webhooks once a scan completes.
+package exporters
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// Notification is the JSON document posted to a webhook. Text summarizes
+// it for chat webhooks (Slack, Mattermost) that show only that field.
+type Notification struct {
+	Text     string                `json:"text"`
+	Target   string                `json:"target"`
+	Run      string                `json:"run"`
+	Findings []NotificationFinding `json:"findings"`
+}
+
+// NotificationFinding is one finding of a notification.
+type NotificationFinding struct {
+	Type     string `json:"type"`
+	Severity string `json:"severity"`
+	Issue    string `json:"issue"`
+	URL      string `json:"url"`
+}
+
+// BuildNotification summarizes vulns, most severe first, for target's run.
+func BuildNotification(target, run string, vulns []types.VulnerabilityResult) Notification {
+	n := Notification{Target: target, Run: run, Findings: []NotificationFinding{}}
+	counts := make(map[string]int)
+	for _, v := range utils.SortBySeverity(vulns) {
+		sev := utils.FindingSeverity(v)
+		counts[sev]++
+		n.Findings = append(n.Findings, NotificationFinding{Type: v.Type, Severity: sev, Issue: v.Issue, URL: v.URL})
+	}
+	var parts []string
+	for _, sev := range []string{"Critical", "High", "Medium", "Low", "Info"} {
+		if counts[sev] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[sev], strings.ToLower(sev)))
+		}
+	}
+	n.Text = fmt.Sprintf("recon %s (%s): %d finding(s)", target, run, len(vulns))
+	if len(parts) > 0 {
+		n.Text += ": " + strings.Join(parts, ", ")
+	}
+	return n
+}
+
+// PostNotification posts n to webhook. The webhook URL often holds its
+// secret, so errors leave it out.
+func PostNotification(client *http.Client, webhook string, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		if ue, ok := err.(*url.Error); ok {
+			err = ue.Err
+		}
+		return fmt.Errorf("webhook request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("webhook answered %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
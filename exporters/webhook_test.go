@@ -0,0 +1,58 @@
+package exporters
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestBuildNotification(t *testing.T) {
+	vulns := []types.VulnerabilityResult{
+		{Type: "xss", Issue: "XSS", URL: "https://example.com/b"},
+		{Type: "sqli", Issue: "SQL Injection", URL: "https://example.com/a"},
+		{Type: "xss", Issue: "XSS", URL: "https://example.com/c"},
+	}
+	n := BuildNotification("example.com", "example.com_20240101_000000", vulns)
+	if n.Text != "recon example.com (example.com_20240101_000000): 3 finding(s): 1 high, 2 medium" {
+		t.Errorf("text %q", n.Text)
+	}
+	if len(n.Findings) != 3 || n.Findings[0].Type != "sqli" || n.Findings[0].Severity != "High" {
+		t.Errorf("findings %+v", n.Findings)
+	}
+}
+
+func TestPostNotification(t *testing.T) {
+	var got Notification
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s with %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+		w.Write([]byte("no_team"))
+	}))
+	defer srv.Close()
+
+	n := BuildNotification("example.com", "run", []types.VulnerabilityResult{{Type: "sqli", URL: "https://example.com/a"}})
+	if err := PostNotification(srv.Client(), srv.URL+"/hooks/secret-token", n); err != nil {
+		t.Fatal(err)
+	}
+	if got.Target != "example.com" || len(got.Findings) != 1 {
+		t.Errorf("posted %+v", got)
+	}
+
+	status = http.StatusNotFound
+	if err := PostNotification(srv.Client(), srv.URL+"/hooks/secret-token", n); err == nil || err.Error() != "webhook answered 404: no_team" {
+		t.Errorf("error %v", err)
+	}
+	// A failed request does not repeat the URL and the token in it.
+	srv.Close()
+	if err := PostNotification(srv.Client(), srv.URL+"/hooks/secret-token", n); err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("error %v", err)
+	}
+}
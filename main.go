@@ -2,100 +2,269 @@
 // Author: Auto-generated by ChatGPT for Victor
 //
 // This tool performs:
-//   1. Subdomain enumeration using assetfinder and amass (with good default args)
+//   1. Subdomain enumeration using assetfinder, amass and subfinder (with good default args)
 //   2. Live host checking via simple DNS lookup
-//   3. URL scanning using hakrawler, gau, and waybackurls with sensible defaults
-//   4. Fuzzing using ffuf (with a given wordlist)
+//   3. URL scanning using hakrawler or katana, gospider, gau, and waybackurls with sensible defaults
+//   4. Fuzzing using ffuf or gobuster (with a given wordlist)
 //   5. Vulnerability scanning via sqlmap, dalfox, kxss, corsy (with improved output parsing)
 //   6. API enrichment (e.g. Shodan)
 //   7. A TUI (using tview) with tabs (Subdomains, Vulnerabilities, FFUF results, Console, Report)
 //   8. A proxy toggle activated by pressing 'p' (default proxy: http://127.0.0.1:8080)
 //   9. No execution can be triggered from the UI – it’s purely for display.
+//  10. An offline mode (--offline) that skips every network-dependent stage.
+//  11. A triage mode ('t' on the Vulnerabilities tab) that records finding dispositions.
+//  12. An authorization gate: aggressive stages run only for authorized engagements.
 // All configuration (API keys, etc.) is loaded via a .env file.
 package main
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/joho/godotenv"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+
+	"github.com/MKlolbullen/Goforgold2/dataset"
+	"github.com/MKlolbullen/Goforgold2/exporters"
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/scanners"
+	"github.com/MKlolbullen/Goforgold2/store"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
 )
 
 // ---------- Data Structures ----------
 
-type ScanResult struct {
-	Subdomains      []SubdomainResult    `json:"subdomains"`
-	VulnURLs        []VulnerabilityResult `json:"vuln_urls"`
-	FfufEntries     []FfufResult          `json:"ffuf_entries"`
-	AllURLs         []string              `json:"all_urls"`
-	LogLines        []string              `json:"log_lines"`
-	FinalReport     string                `json:"final_report"`
-	Running         bool                  `json:"running"`
-	ProxyEnabled    bool                  `json:"proxy_enabled"`
-}
+// The scan result and its parts are declared in package types, which the
+// scanners, parsers and exporters import.
+type (
+	ScanResult          = types.ScanResult
+	ResponseSample      = types.ResponseSample
+	ResponseAnomaly     = types.ResponseAnomaly
+	StageCoverage       = types.StageCoverage
+	LiveHost            = types.LiveHost
+	HostTiming          = types.HostTiming
+	PhaseTiming         = types.PhaseTiming
+	SecretFinding       = types.SecretFinding
+	SecretLocation      = types.SecretLocation
+	Technology          = types.Technology
+	WebTechnologies     = types.WebTechnologies
+	SecurityTxt         = types.SecurityTxt
+	HumansTxt           = types.HumansTxt
+	SaaSService         = types.SaaSService
+	ProviderUsage       = types.ProviderUsage
+	Authorization       = types.Authorization
+	ScanDefinition      = types.ScanDefinition
+	TLSPosture          = types.TLSPosture
+	TLSResult           = types.TLSResult
+	EndpointMethods     = types.EndpointMethods
+	EndpointParameters  = types.EndpointParameters
+	URLRecord           = types.URLRecord
+	APIRequestTemplate  = types.APIRequestTemplate
+	InventoryEntry      = types.InventoryEntry
+	InventoryMatch      = types.InventoryMatch
+	Reconciliation      = types.Reconciliation
+	StageStatus         = types.StageStatus
+	SubdomainResult     = types.SubdomainResult
+	DNSRecord           = types.DNSRecord
+	PortService         = types.PortService
+	ScannedHost         = types.ScannedHost
+	HostPorts           = types.HostPorts
+	VulnerabilityResult = types.VulnerabilityResult
+	SourceStats         = types.SourceStats
+	ShodanHost          = types.ShodanHost
+	FfufResult          = types.FfufResult
+)
+
+var (
+	scanResult ScanResult
+	scanMu     sync.Mutex
+	// offlineMode disables every stage that needs network access.
+	offlineMode bool
+	// inventoryFile is the expected-asset inventory CSV to reconcile against.
+	inventoryFile string
+	// apiTemplatesFile holds captured API requests whose JSON bodies are
+	// tested for injection (--api-templates).
+	apiTemplatesFile string
+	// keepToolDirs keeps the per-run tool HOME directory after the scan.
+	keepToolDirs bool
+	// scope is the engagement scope every tool's output is filtered through.
+	scope *utils.ScopeFilter
+	// socksAddr is the SOCKS5 bastion target traffic is routed through (--socks5).
+	socksAddr string
+	// socksDNS chooses where hostnames resolve in SOCKS5 mode: local or remote.
+	socksDNS string
+	// socks is the bastion dialer; nil unless --socks5 is set.
+	socks *utils.SOCKS5Dialer
+	// iAmAuthorized asks for an interactive confirmation when no authorization is configured.
+	iAmAuthorized bool
+	// passiveOnly refuses every aggressive stage; set unless the run is authorized.
+	passiveOnly bool
+	// triageFile overrides the per-target triage store location.
+	triageFile string
+	// debugMode enables the pprof listener and threshold snapshots (--debug).
+	debugMode bool
+	// failFast stops the run at the first stage that fails validation (--fail-fast).
+	failFast bool
+	// failedFast names the stage whose failed validation stopped the run.
+	failedFast string
+	// waybackFrom and waybackTo limit archived URLs to captures in a date
+	// range (--wayback-from, --wayback-to) as CDX timestamp prefixes.
+	waybackFrom, waybackTo string
+	// baselineDir is an earlier run directory whose findings are not new (--baseline).
+	baselineDir string
+	// baseline marks what the baseline run did not find; nil without --baseline.
+	baseline *utils.Baseline
+	// keepArtifacts keeps repositories cloned for secret scanning (--keep-artifacts).
+	keepArtifacts bool
+	// verifySecrets checks the secrets found with their providers (--verify-secrets).
+	verifySecrets bool
+	// authHeaders are sent to fetch pages as a logged-in user (--auth-header).
+	authHeaders []string
+	// assumeYes starts the run without waiting for the pre-flight confirmation (--yes).
+	assumeYes bool
+	// includePrivate probes hosts that resolve only to private or reserved
+	// addresses, for engagements run from inside the network (--include-private).
+	includePrivate bool
+	// includeParked scans origins serving parked domain or default pages
+	// like any other (--include-parked).
+	includeParked bool
+	// adaptiveWordlist adds a second fuzzing pass with a wordlist mined from
+	// the run's own data (--adaptive-wordlist).
+	adaptiveWordlist bool
+	// scanDefinition is the scan.yaml a `recon run -f` run follows, with its
+	// variables interpolated; nil for other runs.
+	scanDefinition *ScanDefinition
+	// recordedDefinition is scanDefinition as the run records it, with the
+	// ${VAR} references in place of their values.
+	recordedDefinition *ScanDefinition
+	// chaosSpec injects delays and failures (--chaos, only in builds tagged chaos).
+	chaosSpec string
+	// scanWindow restricts aggressive stages to a daily time range; nil runs them any time.
+	scanWindow *utils.ScanWindow
+	// tagRules are the TAG_RULES that label hosts by name.
+	tagRules []utils.TagRule
+	// postgresDSN is the database finished runs are saved to as well as
+	// their directory (--postgres, default POSTGRES_DSN); empty saves none.
+	postgresDSN string
+	// runStores are where the run is saved once finished.
+	runStores []store.Store
+	// triage holds finding dispositions across runs.
+	triage *utils.TriageStore
+	// kb is the target's knowledge base, which owns the cross-run stores.
+	kb *utils.KnowledgeBase
+	// runEvents is the run's events.jsonl, which recon watch follows.
+	runEvents *utils.EventLog
+	// emittedFindings are the fingerprints of the findings already in
+	// runEvents.
+	emittedFindings = make(map[string]bool)
+	// hostTimings times the phases of every request the shared HTTP
+	// client sends, per host.
+	hostTimings = utils.NewTimingRecorder()
+	// logger receives every log line. The TUI console shows its ring of the
+	// latest 5000; summary.json gets the whole log from scan.log.
+	logger = utils.NewLogger(4096, 5000)
+)
 
-type SubdomainResult struct {
-	Hostname string   `json:"hostname"`
-	IP       string   `json:"ip"`
-	Ports    []int    `json:"ports"`
+// Limits applied to Shodan API responses.
+const (
+	shodanMaxBody = 4 << 20
+	shodanTimeout = 30 * time.Second
+)
+
+// shodanAPI is the Shodan API base URL; tests point it at a fake.
+var shodanAPI = "https://api.shodan.io"
+
+// aggressiveStages actively attack or heavily probe the target and only run
+// when the engagement is authorized. Every stage is either here or in
+// passiveStages; a new stage must be added to one of them.
+var aggressiveStages = map[string]bool{
+	"takeover checks":                true,
+	"takeover fingerprint checks":    true,
+	"port scanning":                  true,
+	"alternative port probing":       true,
+	"technology fingerprinting":      true,
+	"TLS posture":                    true,
+	"URL scanning":                   true,
+	"JavaScript endpoint extraction": true,
+	"testssl checks":                 true,
+	"exposure checks":                true,
+	"repository secret scanning":     true,
+	"WAF detection":                  true,
+	"fuzzing":                        true,
+	"HTTP method checks":             true,
+	"response handling checks":       true,
+	"endpoint discovery":             true,
+	"parameter discovery":            true,
+	"CRLF injection checks":          true,
+	"request smuggling checks":       true,
+	"nuclei scanning":                true,
+	"nikto scanning":                 true,
+	"WordPress scanning":             true,
+	"JSON body injection checks":     true,
+	"vulnerability scanning":         true,
 }
 
-type VulnerabilityResult struct {
-	URL    string `json:"url"`
-	Issue  string `json:"issue"`
-	Detail string `json:"detail"`
+// passiveStages only use public sources, light requests any visitor makes
+// or the run's own data, and run without an authorization.
+var passiveStages = map[string]bool{
+	"subdomain enumeration":     true,
+	"live host checking":        true,
+	"HTTP probing":              true,
+	"disclosure metadata":       true,
+	"inventory reconciliation":  true,
+	"SPA route extraction":      true,
+	"URL verification":          true,
+	"secret scanning":           true,
+	"response anomaly analysis": true,
+	"Shodan enrichment":         true,
 }
 
-type FfufResult struct {
-	Path   string `json:"path"`
-	Status int    `json:"status"`
-	Size   int    `json:"size"`
+// pipelineStages lists the stages in the order the scan runs them, for the
+// pre-flight summary; keep it in step with the runStage calls in main.
+var pipelineStages = []string{
+	"subdomain enumeration", "live host checking", "takeover checks",
+	"takeover fingerprint checks", "HTTP probing", "disclosure metadata", "port scanning",
+	"alternative port probing", "WAF detection", "technology fingerprinting",
+	"TLS posture", "testssl checks", "exposure checks",
+	"repository secret scanning", "inventory reconciliation", "URL scanning",
+	"JavaScript endpoint extraction", "SPA route extraction", "URL verification", "secret scanning", "fuzzing",
+	"response anomaly analysis", "HTTP method checks", "response handling checks", "endpoint discovery",
+	"parameter discovery", "CRLF injection checks", "request smuggling checks", "nuclei scanning",
+	"nikto scanning", "WordPress scanning", "JSON body injection checks",
+	"vulnerability scanning", "Shodan enrichment",
 }
 
-var (
-	scanResult ScanResult
-	scanMu     sync.Mutex
-)
+// errOfflineDial is returned by the network guard when a dial is attempted in offline mode.
+var errOfflineDial = errors.New("network access attempted in offline mode")
 
 // ---------- Utility Functions ----------
 
-// AppendLog safely appends a line to the scan log.
+// AppendLog queues a line for the scan log without blocking the caller.
+// Logging has its own path so tool output never contends with scanMu.
 func AppendLog(line string) {
-	scanMu.Lock()
-	defer scanMu.Unlock()
-	scanResult.LogLines = append(scanResult.LogLines, line)
+	logger.Log(line)
 }
 
 // WriteLines writes a slice of strings to a file.
 func WriteLines(lines []string, filePath string) error {
-	f, err := os.Create(filePath)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	for _, l := range lines {
-		_, err = f.WriteString(l + "\n")
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return utils.WriteLines(lines, filePath)
 }
 
 // uniqueStrings returns unique elements from a slice.
@@ -112,106 +281,471 @@ func uniqueStrings(input []string) []string {
 }
 
 // RunCommand executes an external command and returns its output.
+// Tools run isolated under outDir/raw/<tool> once the tool sandbox is set up.
 func RunCommand(name string, args ...string) (string, error) {
-	cmd := exec.Command(name, args...)
-	out, err := cmd.CombinedOutput()
-	return string(out), err
+	return utils.RunCommand(name, args...)
+}
+
+// skipOffline logs and reports whether a network-dependent stage must be skipped.
+func skipOffline(stage string) bool {
+	if !offlineMode {
+		return false
+	}
+	AppendLog("[*] Offline mode: skipping " + stage + " (requires network access)")
+	return true
+}
+
+// definitionSkips reports whether the scan definition leaves stage out,
+// by skipping it or by naming only other stages.
+func definitionSkips(stage string) bool {
+	if scanDefinition == nil {
+		return false
+	}
+	only := len(scanDefinition.Stages.Only) > 0
+	names := scanDefinition.Stages.Skip
+	if only {
+		names = scanDefinition.Stages.Only
+	}
+	for _, name := range names {
+		if name == stage {
+			return !only
+		}
+	}
+	return only
+}
+
+// recordStage appends a stage status to the scan result.
+func recordStage(status StageStatus) {
+	scanMu.Lock()
+	scanResult.Stages = append(scanResult.Stages, status)
+	counts := utils.RunCounts{Subdomains: len(scanResult.Subdomains), LiveHosts: len(scanResult.LiveHosts),
+		URLs: len(scanResult.AllURLs), Findings: len(scanResult.VulnURLs)}
+	var found []VulnerabilityResult
+	for _, v := range scanResult.VulnURLs {
+		if fp := utils.FindingFingerprint(v); !emittedFindings[fp] {
+			emittedFindings[fp] = true
+			found = append(found, v)
+		}
+	}
+	scanMu.Unlock()
+	// Watchers follow the run through its events.
+	events := []utils.RunEvent{{Kind: utils.EventStage, Stage: &status}, {Kind: utils.EventCounts, Counts: &counts}}
+	for i := range found {
+		events = append(events, utils.RunEvent{Kind: utils.EventFinding, Finding: &found[i]})
+	}
+	for _, ev := range events {
+		if err := runEvents.Write(ev); err != nil {
+			AppendLog("[!] events.jsonl: " + err.Error())
+			break
+		}
+	}
+}
+
+// runStage runs a pipeline stage, then validates its output and records the outcome.
+// Network-dependent stages are recorded as skipped in offline mode.
+func runStage(name, outDir string, needsNetwork bool, run func(), validate utils.StageValidator) {
+	if failedFast != "" {
+		recordStage(StageStatus{Stage: name, Status: "skipped", Messages: []string{"fail-fast: " + failedFast + " failed validation"}})
+		return
+	}
+	if definitionSkips(name) {
+		recordStage(StageStatus{Stage: name, Status: "skipped", Messages: []string{"scan definition"}})
+		return
+	}
+	if needsNetwork && skipOffline(name) {
+		recordStage(StageStatus{Stage: name, Status: "skipped", Messages: []string{"offline mode"}})
+		return
+	}
+	if aggressiveStages[name] && passiveOnly {
+		AppendLog("[!] Skipping " + name + ": active scanning is not authorized for this run")
+		recordStage(StageStatus{Stage: name, Status: "skipped", Messages: []string{"not authorized"}})
+		return
+	}
+	// Aggressive stages keep to the scan window: they wait for it to open,
+	// and their tools and worker jobs wait again whenever it closes.
+	if aggressiveStages[name] && scanWindow != nil {
+		utils.EnterScanWindow(scanWindow, name, AppendLog)
+		utils.WaitScanWindow(name)
+	}
+	// A panic in the stage or its workers fails the stage, not the run.
+	func() {
+		defer utils.RecoverPanic()
+		run()
+	}()
+	utils.LeaveScanWindow()
+	logger.Flush()
+	// Later stages pace themselves by the timings recorded so far.
+	if timings := hostTimings.Summary(); len(timings) > 0 {
+		scanMu.Lock()
+		scanResult.HostTimings = timings
+		scanMu.Unlock()
+	}
+	status := StageStatus{Stage: name, Status: "completed"}
+	for _, p := range utils.TakeScanWindowPauses() {
+		status.Messages = append(status.Messages, p.String())
+	}
+	// Oversized output lines were kept truncated; surface them per tool.
+	if truncated := utils.TakeTruncations(); len(truncated) > 0 {
+		status.Status = "completed-with-warnings"
+		status.Messages = append(status.Messages, truncated...)
+		for _, msg := range truncated {
+			AppendLog("[!] " + name + ": " + msg)
+		}
+	}
+	// Tools that cannot be held to the scope still report what they
+	// wandered into; drop those findings before anything reads them.
+	if scope != nil {
+		scanMu.Lock()
+		var dropped map[string]int
+		scanResult.VulnURLs, dropped = scope.FilterFindings(scanResult.VulnURLs)
+		scanMu.Unlock()
+		if msg := utils.DropSummary(dropped); msg != "" {
+			AppendLog("[*] " + name + ": " + msg)
+			status.Messages = append(status.Messages, msg)
+		}
+	}
+	// Every finding should carry a registered taxonomy ID; the others are
+	// kept, tagged unclassified, and flagged on the stage.
+	scanMu.Lock()
+	var unclassified []string
+	scanResult.VulnURLs, unclassified = utils.IngestFindings(scanResult.VulnURLs)
+	scanMu.Unlock()
+	if len(unclassified) > 0 {
+		status.Status = "completed-with-warnings"
+		for _, msg := range unclassified {
+			AppendLog("[!] " + name + ": " + msg)
+		}
+		status.Messages = append(status.Messages, unclassified...)
+	}
+	if validate != nil {
+		scanMu.Lock()
+		warnings, err := validate(&scanResult, outDir)
+		scanMu.Unlock()
+		if err != nil {
+			status.Status = "failed-validation"
+			warnings = append(warnings, err.Error())
+			if failFast {
+				failedFast = name
+				AppendLog("[!] Fail-fast: skipping the remaining stages after " + name + " failed validation")
+			}
+		} else if len(warnings) > 0 {
+			status.Status = "completed-with-warnings"
+		}
+		for _, msg := range warnings {
+			AppendLog("[!] " + name + " validation: " + msg)
+		}
+		status.Messages = append(status.Messages, warnings...)
+	}
+	// Tools killed at a resource limit left their results incomplete.
+	if breaches := utils.TakeLimitBreaches(); len(breaches) > 0 {
+		if status.Status != "failed-validation" {
+			status.Status = "completed-with-errors"
+		}
+		for _, msg := range breaches {
+			AppendLog("[!] " + name + ": " + msg)
+		}
+		status.Messages = append(status.Messages, breaches...)
+	}
+	if recovered := utils.TakePanics(); len(recovered) > 0 {
+		status.Status = "failed"
+		status.Messages = append(status.Messages, crashMessages(name, outDir, recovered)...)
+	}
+	recordStage(status)
+}
+
+// crashMessages logs the panics a stage raised, writes their crash report
+// and returns the messages for the stage status. Headless runs have no TUI
+// to flag the failure, so the report path goes to stderr as well.
+func crashMessages(name, outDir string, recovered []utils.Panic) []string {
+	// Workers failing on every job repeat one panic; report it once.
+	var (
+		msgs  []string
+		count = make(map[string]int)
+	)
+	for _, p := range recovered {
+		if count[p.Value]++; count[p.Value] == 1 {
+			msgs = append(msgs, "panic: "+p.Value)
+		}
+	}
+	for i, msg := range msgs {
+		if n := count[strings.TrimPrefix(msg, "panic: ")]; n > 1 {
+			msgs[i] = fmt.Sprintf("%s (%d times)", msg, n)
+		}
+		AppendLog("[!] " + name + " " + msgs[i])
+	}
+	logger.Flush()
+	path, err := utils.WriteCrashReport(outDir, name, recovered, logger.Lines())
+	if err != nil {
+		AppendLog("[!] Could not write the crash report for " + name + ": " + err.Error())
+		return msgs
+	}
+	AppendLog("[!] Crash report for " + name + " written to " + path)
+	if !utils.IsTerminal(os.Stdout) {
+		fmt.Fprintf(os.Stderr, "Stage %s failed; crash report: %s\n", name, path)
+	}
+	return append(msgs, "crash report: "+path)
+}
+
+// offlineDial refuses every connection attempt so accidental network use fails loudly.
+func offlineDial(ctx context.Context, network, addr string) (net.Conn, error) {
+	AppendLog(fmt.Sprintf("[!] Blocked %s dial to %s: offline mode", network, addr))
+	return nil, errOfflineDial
+}
+
+// errRemoteDNS is returned by the resolver when hostnames must resolve through the bastion.
+var errRemoteDNS = errors.New("local DNS disabled: hostnames resolve through the SOCKS5 bastion")
+
+// newResolver returns the shared DNS resolver, DNS_RESOLVER's when set; in
+// offline mode every lookup is refused, and with --socks-dns remote local
+// lookups are refused too.
+func newResolver() *net.Resolver {
+	if offlineMode {
+		return &net.Resolver{PreferGo: true, Dial: offlineDial}
+	}
+	if socks != nil && socks.RemoteDNS {
+		return &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, errRemoteDNS
+		}}
+	}
+	return utils.ChaosResolver(scanners.DNSResolver())
 }
 
 // newHTTPClient returns an HTTP client; if proxyEnabled is true, it routes via the proxy.
+// In offline mode the client refuses to dial anything, proxy included. With
+// a SOCKS5 bastion every connection goes through it and the HTTP proxy
+// toggle is ignored.
 func newHTTPClient(proxyEnabled bool) (*http.Client, error) {
+	client, err := baseHTTPClient(proxyEnabled)
+	if err != nil {
+		return nil, err
+	}
+	return utils.ChaosClient(hostTimings.Client(client)), nil
+}
+
+// baseHTTPClient builds the client newHTTPClient hands out.
+func baseHTTPClient(proxyEnabled bool) (*http.Client, error) {
+	if offlineMode {
+		return &http.Client{Transport: &http.Transport{DialContext: offlineDial}}, nil
+	}
+	// Custom transports only negotiate h2 when asked to.
+	if socks != nil {
+		return &http.Client{Transport: &http.Transport{DialContext: socks.DialContext, ForceAttemptHTTP2: true}}, nil
+	}
 	if proxyEnabled {
 		proxyURL, err := url.Parse("http://127.0.0.1:8080")
 		if err != nil {
 			return nil, err
 		}
-		transport := &http.Transport{Proxy: http.ProxyURL(proxyURL)}
+		transport := &http.Transport{Proxy: http.ProxyURL(proxyURL), ForceAttemptHTTP2: true}
 		return &http.Client{Transport: transport}, nil
 	}
-	return http.DefaultClient, nil
-}
-
-// ---------- Parsing Functions for Python Tools ----------
-
-// ParseSqlmapOutput extracts SQLi findings from sqlmap output.
-func ParseSqlmapOutput(output string) []VulnerabilityResult {
-	var results []VulnerabilityResult
-	scanner := bufio.NewScanner(strings.NewReader(output))
-	re := regexp.MustCompile(`(http[s]?://[^\s]+)`)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "is vulnerable") {
-			match := re.FindStringSubmatch(line)
-			if len(match) > 1 {
-				results = append(results, VulnerabilityResult{
-					URL:    match[1],
-					Issue:  "SQL Injection",
-					Detail: line,
-				})
-			}
-		}
-	}
-	return results
+	// Origins that answered over HTTP/3 are reached that way (h3 builds).
+	return scanners.PreferProtocols(http.DefaultClient, &scanResult), nil
 }
 
-// ParseDalfoxOutput extracts XSS findings from dalfox output.
-func ParseDalfoxOutput(output string) []VulnerabilityResult {
-	var results []VulnerabilityResult
-	scanner := bufio.NewScanner(strings.NewReader(output))
-	re := regexp.MustCompile(`(http[s]?://[^\s]+)`)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "[POC]") {
-			match := re.FindStringSubmatch(line)
-			if len(match) > 1 {
-				results = append(results, VulnerabilityResult{
-					URL:    match[1],
-					Issue:  "XSS",
-					Detail: line,
-				})
+// buildPreflight plans the run from its settings without sending anything:
+// the stages that will run, what the active ones cost, and how traffic
+// leaves the machine.
+func buildPreflight(target string, authorization *Authorization) utils.Preflight {
+	p := utils.Preflight{
+		Target:        utils.NormalizeHostname(target),
+		Scope:         scope,
+		Authorization: utils.FormatAuthorization(authorization),
+		Proxy:         "none, direct connections",
+	}
+	if socks != nil {
+		p.Proxy = fmt.Sprintf("SOCKS5 %s (DNS: %s)", socks.Addr, socksDNS)
+	}
+	rate, delay := scanners.WAFSettings()
+	p.Rate = fmt.Sprintf("tool defaults; behind a WAF ffuf at %d request(s)/s, other tools %ds apart", rate, delay)
+	if scanWindow != nil {
+		p.Rate += "; aggressive stages only within " + scanWindow.String()
+	}
+	for _, name := range pipelineStages {
+		st := utils.PlannedStage{Name: name, Aggressive: aggressiveStages[name]}
+		switch {
+		case definitionSkips(name):
+			st.Skipped = "scan definition"
+		case name == "inventory reconciliation":
+			if inventoryFile == "" {
+				st.Skipped = "no --inventory"
 			}
+		case name == "response anomaly analysis":
+			// Compares what earlier stages fetched; runs offline too.
+		case name == "JSON body injection checks" && apiTemplatesFile == "":
+			st.Skipped = "no --api-templates"
+		case name == "port scanning" && !scanners.PortScanEnabled():
+			st.Skipped = "PORT_SCAN=false"
+		case name == "Shodan enrichment" && os.Getenv("SHODAN_API_KEY") == "":
+			st.Skipped = "no SHODAN_API_KEY"
+		case offlineMode:
+			st.Skipped = "offline mode"
+		case st.Aggressive && passiveOnly:
+			st.Skipped = "not authorized"
+		}
+		p.Stages = append(p.Stages, st)
+	}
+	if offlineMode {
+		return p
+	}
+	if !passiveOnly {
+		if n, err := scanners.FuzzRequestEstimate(); err == nil {
+			p.Estimates = append(p.Estimates, fmt.Sprintf("fuzzing: about %d request(s) to the target's web root", n))
+		} else {
+			p.Estimates = append(p.Estimates, "fuzzing: unknown, wordlist unreadable: "+err.Error())
+		}
+		if adaptiveWordlist {
+			p.Estimates = append(p.Estimates, "adaptive fuzzing: one more request per generated word (ADAPTIVE_WORDLIST_MAX)")
+		}
+		p.Estimates = append(p.Estimates, fmt.Sprintf("exposure checks: up to %d request(s) per subdomain", scanners.ExposureRequestsPerHost()))
+	}
+	if os.Getenv("SHODAN_API_KEY") != "" {
+		line := "Shodan: one host lookup per public IPv4 address, spending no query credits"
+		if budget := utils.QueryBudget("SHODAN_QUERY_BUDGET"); budget >= 0 {
+			line += fmt.Sprintf(", at most %d", budget)
 		}
+		p.Estimates = append(p.Estimates, line)
 	}
-	return results
+	return p
 }
 
 // ---------- Scanning Pipeline Functions ----------
 
-// EnumerateSubdomains runs assetfinder and amass to find subdomains.
+// EnumerateSubdomains runs assetfinder, amass and subfinder to find
+// subdomains, recording on each which of them found it.
 func EnumerateSubdomains(target, chaosKey, outDir string) {
 	AppendLog("[*] Starting subdomain enumeration...")
+	found := make(map[string][]string)
+	runtimes := make(map[string]time.Duration)
 	// Run assetfinder with default args.
-	assetOut, err := RunCommand("assetfinder", target)
+	start := time.Now()
+	assetOut, err := RunCommand("assetfinder", "--subs-only", target)
+	runtimes["assetfinder"] = time.Since(start)
 	if err != nil {
 		AppendLog("[!] assetfinder error: " + err.Error())
 	}
+	found["assetfinder"] = utils.ReadLines("assetfinder", assetOut)
 	// Run amass in passive mode.
+	start = time.Now()
 	amassOut, err := RunCommand("amass", "enum", "-d", target, "-passive", "-norecursive", "-noalts", "-timeout", "60")
+	runtimes["amass"] = time.Since(start)
 	if err != nil {
 		AppendLog("[!] amass error: " + err.Error())
 	}
-	allSubs := append(strings.Split(assetOut, "\n"), strings.Split(amassOut, "\n")...)
+	found["amass"] = utils.ReadLines("amass", amassOut)
+	// Run subfinder, which names the passive source of every result.
+	start = time.Now()
+	subfinderHosts, subfinderSources, err := scanners.RunSubfinder(target, AppendLog)
+	runtimes["subfinder"] = time.Since(start)
+	if err != nil {
+		AppendLog("[!] subfinder error: " + err.Error())
+	}
+	found["subfinder"] = subfinderHosts
+	enumSources := []string{"assetfinder", "amass", "subfinder"}
+	// Drop out-of-scope names regardless of what the tools returned.
+	for _, src := range enumSources {
+		kept, dropped := scope.FilterHosts(found[src])
+		found[src] = kept
+		if dropped > 0 {
+			AppendLog(fmt.Sprintf("[*] %s: dropped %d out-of-scope name(s)", src, dropped))
+		}
+	}
+	var allSubs []string
+	for _, src := range enumSources {
+		allSubs = append(allSubs, found[src]...)
+	}
 	allSubs = uniqueStrings(allSubs)
+	sources := scanners.SubdomainSources(enumSources, found, subfinderSources)
+	resolveHosts(allSubs)
 	for _, s := range allSubs {
 		if s != "" {
-			// For demo purposes, assign a dummy IP and ports.
-			scanResult.Subdomains = append(scanResult.Subdomains, SubdomainResult{
-				Hostname: s,
-				IP:       "192.0.2.1",
-				Ports:    []int{80, 443},
-			})
+			// Ports come from the port scan.
+			sub := SubdomainResult{Hostname: s, Ports: []int{}, Source: sources[s]}
+			scanners.ApplyDNSRecord(&sub, dnsRecords[utils.NormalizeHostname(s)])
+			scanResult.Subdomains = append(scanResult.Subdomains, sub)
 			AppendLog("[*] Discovered subdomain: " + s)
 		}
 	}
 	WriteLines(allSubs, filepath.Join(outDir, "subdomains.txt"))
+	// Summarize what each source contributed.
+	stats := utils.ComputeSourceStats(enumSources, found, runtimes)
+	scanMu.Lock()
+	scanResult.SourceStats = stats
+	scanMu.Unlock()
+	AppendLog("[*] Subdomain sources:")
+	for _, line := range utils.FormatSourceStats(stats) {
+		AppendLog("    " + line)
+	}
+}
+
+// dnsRecords holds the DNS records of every name looked up this run, by
+// normalized name, empty for names that did not resolve, so no name is
+// resolved twice.
+var dnsRecords = make(map[string]DNSRecord)
+
+// resolveHosts looks up the hosts not in dnsRecords yet and records them
+// there. When the SOCKS5 bastion resolves names nothing is looked up
+// locally.
+func resolveHosts(hosts []string) {
+	if socks != nil && socks.RemoteDNS {
+		return
+	}
+	var pending []string
+	for _, h := range hosts {
+		if h = utils.NormalizeHostname(h); h != "" {
+			if _, done := dnsRecords[h]; !done {
+				pending = append(pending, h)
+			}
+		}
+	}
+	if pending = uniqueStrings(pending); len(pending) == 0 {
+		return
+	}
+	AppendLog(fmt.Sprintf("[*] Resolving %d name(s)...", len(pending)))
+	for h, rec := range scanners.ResolveHosts(newResolver(), pending, AppendLog) {
+		dnsRecords[h] = rec
+	}
 }
 
-// CheckLiveHosts verifies which subdomains are live.
+// CheckLiveHosts records every subdomain's addresses and CNAME chain,
+// reusing what enumeration resolved and looking up only names added
+// since, and writes the hosts with an address to live_hosts.txt. CNAMEs
+// leaving the scope are logged as takeover candidates. Hosts that resolve
+// only to private or reserved addresses are tagged state:internal and,
+// unless --include-private is set, left out of live_hosts.txt so nothing
+// probes them.
 func CheckLiveHosts(outDir string) {
 	AppendLog("[*] Checking live hosts...")
-	var live []string
+	var hosts []string
 	for _, s := range scanResult.Subdomains {
-		if isHostAlive(s.Hostname) {
+		hosts = append(hosts, s.Hostname)
+	}
+	resolveHosts(hosts)
+	var live []string
+	for i := range scanResult.Subdomains {
+		s := &scanResult.Subdomains[i]
+		var alive bool
+		// Names only resolve at the bastion; all we learn is whether they connect.
+		if socks != nil && socks.RemoteDNS {
+			alive = isHostAlive(s.Hostname)
+		} else if rec, ok := dnsRecords[utils.NormalizeHostname(s.Hostname)]; ok {
+			scanners.ApplyDNSRecord(s, rec)
+			alive = len(rec.A)+len(rec.AAAA) > 0
+			if class, internal := utils.InternalAddresses(append(append([]string{}, rec.A...), rec.AAAA...)); internal {
+				s.Tags = utils.AddTag(s.Tags, utils.TagState+":internal")
+				AppendLog(fmt.Sprintf("[!] %s resolves only to %s addresses (%s): leaked internal hostname", s.Hostname, class, s.IP))
+			}
+			for _, c := range rec.CNAME {
+				if !scope.HostInScope(c) {
+					AppendLog(fmt.Sprintf("[!] %s is a CNAME to third-party %s (takeover candidate)", s.Hostname, c))
+					s.Tags = utils.AddTag(s.Tags, utils.TagParty+":third")
+					break
+				}
+			}
+		}
+		if alive && !utils.ProbeHost(*s) {
+			AppendLog("[*] Not probing internal host " + s.Hostname + " (use --include-private for internal engagements)")
+		} else if alive {
 			live = append(live, s.Hostname)
 			AppendLog("[*] Live: " + s.Hostname)
 		}
@@ -219,57 +753,191 @@ func CheckLiveHosts(outDir string) {
 	WriteLines(live, filepath.Join(outDir, "live_hosts.txt"))
 }
 
-// isHostAlive checks if the host resolves.
+// isHostAlive checks if the host resolves. When the bastion resolves
+// names, it instead checks whether a web port is reachable through it.
 func isHostAlive(host string) bool {
-	_, err := net.LookupIP(host)
-	return err == nil
+	if socks != nil && socks.RemoteDNS {
+		for _, port := range []string{"443", "80"} {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			conn, err := socks.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+			cancel()
+			if err == nil {
+				conn.Close()
+				return true
+			}
+		}
+		return false
+	}
+	ips, err := newResolver().LookupIP(context.Background(), "ip", host)
+	if err != nil {
+		return false
+	}
+	var addrs []string
+	for _, ip := range ips {
+		addrs = append(addrs, ip.String())
+	}
+	if class, internal := utils.InternalAddresses(addrs); internal {
+		AppendLog(fmt.Sprintf("[!] %s resolves only to %s addresses: leaked internal hostname", host, class))
+		return includePrivate
+	}
+	return true
 }
 
-// RunURLScan runs additional URL discovery tools: hakrawler, gau, and waybackurls.
-func RunURLScan(target, outDir string) {
-	AppendLog("[*] Running URL scanning tools (hakrawler, gau, waybackurls)...")
-	urlSet := make(map[string]struct{})
+// addInScopeURLs adds the URLs in a tool's output to urlSet. Out-of-scope
+// URLs are dropped even when the tool itself could not be constrained.
+func addInScopeURLs(tool, output string, urlSet map[string]struct{}) {
+	addInScopeURLList(tool, utils.ReadLines(tool, output), urlSet)
+}
 
-	// Run hakrawler with default args.
-	hakOut, err := RunCommand("hakrawler", "-url", "http://"+target, "-depth", "2", "-plain")
-	if err == nil {
-		scanner := bufio.NewScanner(strings.NewReader(hakOut))
-		for scanner.Scan() {
-			line := scanner.Text()
-			if line != "" {
-				urlSet[line] = struct{}{}
-			}
-		}
-	} else {
-		AppendLog("[!] hakrawler error: " + err.Error())
+// addInScopeURLList is addInScopeURLs for output that was already split
+// into URLs.
+func addInScopeURLList(tool string, urls []string, urlSet map[string]struct{}) {
+	kept, dropped := scope.FilterURLs(urls)
+	for _, u := range kept {
+		urlSet[u] = struct{}{}
 	}
+	if dropped > 0 {
+		AppendLog(fmt.Sprintf("[*] %s: dropped %d out-of-scope URL(s)", tool, dropped))
+	}
+}
 
-	// Run gau with default args.
-	gauOut, err := RunCommand("gau", "--subs", target)
-	if err == nil {
-		scanner := bufio.NewScanner(strings.NewReader(gauOut))
-		for scanner.Scan() {
-			line := scanner.Text()
-			if line != "" {
-				urlSet[line] = struct{}{}
-			}
+// RunURLScan runs additional URL discovery tools: the configured crawler
+// (hakrawler or katana), gospider, gau, and the Wayback Machine, side by
+// side. The archive is read through waybackurls unless it is missing or a
+// capture date range is set, in which case the CDX API is queried directly
+// and each URL is recorded with its capture time. Crawled endpoints that take parameters are also written to
+// params_urls.txt for the vulnerability stage, JavaScript files gospider
+// found to js_files.txt, and the subdomains it found join the scan.
+func RunURLScan(target, outDir string) {
+	crawler, depth := scanners.CrawlSettings()
+	wayback := "waybackurls"
+	if _, err := exec.LookPath("waybackurls"); err != nil || waybackFrom != "" || waybackTo != "" {
+		wayback = "wayback CDX API"
+	}
+	AppendLog(fmt.Sprintf("[*] Running URL scanning tools (%s, gospider, gau, %s)...", crawler, wayback))
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		urlSet   = make(map[string]struct{})
+		paramSet = make(map[string]struct{})
+		jsFiles  []string
+		subs     []string
+		archived []URLRecord
+	)
+	addParams := func(params []string) {
+		kept, _ := scope.FilterURLs(params)
+		for _, u := range kept {
+			paramSet[u] = struct{}{}
 		}
+	}
+
+	// Crawling is active, so it needs authorization. Web services on
+	// alternative ports are crawled as origins of their own.
+	var bases []string
+	if passiveOnly {
+		AppendLog("[*] Skipping " + crawler + " and gospider: active crawling is not authorized for this run")
 	} else {
-		AppendLog("[!] gau error: " + err.Error())
+		if web, ok := scanners.WebTarget(&scanResult, target); ok {
+			bases = append(bases, web)
+		} else {
+			AppendLog("[*] Skipping crawl of " + target + ": it did not answer HTTP")
+		}
+		bases = append(bases, scanners.AltOrigins(&scanResult)...)
+	}
+	if len(bases) > 0 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			defer utils.RecoverPanic()
+			for _, base := range bases {
+				found, params, err := scanners.Crawl(crawler, base, depth)
+				mu.Lock()
+				if err != nil {
+					AppendLog("[!] " + crawler + " error on " + base + ": " + err.Error())
+				}
+				addInScopeURLList(crawler, found, urlSet)
+				addParams(params)
+				mu.Unlock()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			defer utils.RecoverPanic()
+			for _, base := range bases {
+				out, err := scanners.Gospider(base, depth)
+				mu.Lock()
+				if err != nil {
+					AppendLog("[!] gospider error on " + base + ": " + err.Error())
+				}
+				addInScopeURLList("gospider", out.URLs, urlSet)
+				addParams(out.Forms)
+				jsFiles = append(jsFiles, out.JSFiles...)
+				subs = append(subs, out.Subdomains...)
+				mu.Unlock()
+			}
+		}()
 	}
 
-	// Run waybackurls.
-	waybackOut, err := RunCommand("bash", "-c", fmt.Sprintf("echo %s | waybackurls", target))
-	if err == nil {
-		scanner := bufio.NewScanner(strings.NewReader(waybackOut))
-		for scanner.Scan() {
-			line := scanner.Text()
-			if line != "" {
-				urlSet[line] = struct{}{}
+	// Archive sources.
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer utils.RecoverPanic()
+		gauOut, err := RunCommand("gau", "--subs", target)
+		mu.Lock()
+		defer mu.Unlock()
+		if err == nil {
+			addInScopeURLs("gau", gauOut, urlSet)
+		} else {
+			AppendLog("[!] gau error: " + err.Error())
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		defer utils.RecoverPanic()
+		if wayback != "waybackurls" {
+			client, _ := newHTTPClient(scanResult.ProxyEnabled)
+			q := scanners.WaybackQuery(target, waybackFrom, waybackTo)
+			err := scanners.NewCDXClient(client).Fetch(q, func(records []URLRecord) {
+				urls := make([]string, len(records))
+				for i, r := range records {
+					urls[i] = r.URL
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				addInScopeURLList("wayback", urls, urlSet)
+				archived = append(archived, records...)
+			}, AppendLog)
+			if err != nil {
+				AppendLog("[!] wayback CDX error: " + err.Error())
 			}
+			return
+		}
+		waybackOut, err := RunCommand("bash", "-c", fmt.Sprintf("echo %s | waybackurls", target))
+		mu.Lock()
+		defer mu.Unlock()
+		if err == nil {
+			addInScopeURLs("waybackurls", waybackOut, urlSet)
+		} else {
+			AppendLog("[!] waybackurls error: " + err.Error())
+		}
+	}()
+	wg.Wait()
+
+	// JavaScript files are kept for later analysis.
+	jsFiles, _ = scope.FilterURLs(uniqueStrings(jsFiles))
+	WriteLines(jsFiles, filepath.Join(outDir, "js_files.txt"))
+	// Subdomains found while crawling join the scan; checking them before
+	// the merge keeps their URLs from being dropped as unanswered.
+	subs, dropped := scope.FilterHosts(uniqueStrings(subs))
+	if dropped > 0 {
+		AppendLog(fmt.Sprintf("[*] gospider: dropped %d out-of-scope name(s)", dropped))
+	}
+	if len(subs) > 0 {
+		client, _ := newHTTPClient(scanResult.ProxyEnabled)
+		if added := scanners.AddDiscoveredHosts(client, outDir, subs, isHostAlive, &scanResult, AppendLog); len(added) > 0 {
+			AppendLog(fmt.Sprintf("[*] gospider added %d subdomain(s)", len(added)))
 		}
-	} else {
-		AppendLog("[!] waybackurls error: " + err.Error())
 	}
 
 	// Merge results.
@@ -278,52 +946,133 @@ func RunURLScan(target, outDir string) {
 		urls = append(urls, u)
 	}
 	urls = uniqueStrings(urls)
+	// Archived URLs of hosts that no longer serve HTTP are not worth scanning.
+	urls, dead := scanners.FilterAnswered(&scanResult, urls)
+	if dead > 0 {
+		AppendLog(fmt.Sprintf("[*] Dropped %d URL(s) on hosts that did not answer HTTP", dead))
+	}
 	scanResult.AllURLs = urls
 	WriteLines(urls, filepath.Join(outDir, "urls.txt"))
+	// Capture times of the archived URLs that survived the filters.
+	kept := make(map[string]bool, len(urls))
+	for _, u := range urls {
+		kept[u] = true
+	}
+	scanResult.URLRecords = nil
+	for _, r := range archived {
+		if kept[r.URL] {
+			scanResult.URLRecords = append(scanResult.URLRecords, r)
+		}
+	}
+	var params []string
+	for _, u := range urls {
+		if _, ok := paramSet[u]; ok {
+			params = append(params, u)
+		}
+	}
+	WriteLines(params, filepath.Join(outDir, "params_urls.txt"))
+	if len(params) > 0 {
+		AppendLog(fmt.Sprintf("[*] %d crawled endpoint(s) take parameters", len(params)))
+	}
 	AppendLog(fmt.Sprintf("[*] URL scan complete, found %d URLs", len(urls)))
 }
 
-// RunFuzzing runs ffuf for fuzzing endpoints.
+// RunFuzzing runs the configured fuzzers for fuzzing endpoints and merges
+// their results, then, with --adaptive-wordlist, fuzzes again with a
+// generated wordlist.
 func RunFuzzing(target, outDir string) {
-	AppendLog("[*] Running ffuf fuzzing...")
-	ffufOut := filepath.Join(outDir, "ffuf_results.json")
-	_, err := RunCommand("ffuf",
-		"-w", "/usr/share/seclists/Discovery/Web-Content/api/api-endpoints-res.txt:FUZZ",
-		"-u", fmt.Sprintf("http://%s/FUZZ", target),
-		"-of", "json", "-o", ffufOut)
-	if err != nil {
-		AppendLog("[!] ffuf error: " + err.Error())
-		return
+	scanners.RunFuzzing(target, outDir, &scanResult, scanResult.WAFDetected, AppendLog)
+	if adaptiveWordlist {
+		scanners.RunAdaptiveFuzzing(target, outDir, &scanResult, scanResult.WAFDetected, AppendLog)
 	}
-	// For demo, assume ffuf found one entry.
-	scanResult.FfufEntries = []FfufResult{{Path: "/admin", Status: 200, Size: 512}}
-	AppendLog("[*] ffuf fuzzing completed.")
 }
 
-// RunPreVulnTools runs JSFINDER, ParamSpider, and ParamWizard.
+// RunPreVulnTools runs JSFINDER, ParamSpider, and ParamWizard. The endpoints
+// JSFinder extracts from JavaScript and the URLs with parameters ParamSpider
+// mines from the archives join the scan, and so do the in-scope subdomains
+// JSFinder finds.
 func RunPreVulnTools(target, outDir string) {
 	AppendLog("[*] Running JSFINDER, ParamSpider, and ParamWizard...")
-	epFile := filepath.Join(outDir, "endpoints.txt")
-	_ = RunCommand("JSFinder", "-u", target, "-o", epFile)
-	_ = RunCommand("paramspider", "--domain", target, "--level", "2")
-	_ = RunCommand("paramwizard", "-t", target)
+	subs := scanners.RunJSFinder(target, outDir, &scanResult, scope.URLInScope, AppendLog)
+	subs, dropped := scope.FilterHosts(subs)
+	if dropped > 0 {
+		AppendLog(fmt.Sprintf("[*] JSFinder: dropped %d out-of-scope name(s)", dropped))
+	}
+	if len(subs) > 0 {
+		client, _ := newHTTPClient(scanResult.ProxyEnabled)
+		if added := scanners.AddDiscoveredHosts(client, outDir, subs, isHostAlive, &scanResult, AppendLog); len(added) > 0 {
+			AppendLog(fmt.Sprintf("[*] JSFinder added %d subdomain(s)", len(added)))
+		}
+	}
+	scanners.RunParamSpider(target, outDir, &scanResult, scope.URLInScope, AppendLog)
+	if _, err := RunCommand("paramwizard", "-t", target); err != nil {
+		AppendLog("[!] paramwizard error: " + err.Error())
+	}
 	AppendLog("[*] Pre-vulnerability endpoint discovery complete.")
 }
 
-// RunVulnerabilityScans runs sqlmap, dalfox, etc.
-func RunVulnerabilityScans(target, outDir string) {
+// RunVulnerabilityScans runs sqlmap, dalfox, kxss, corsy, etc. sqlmap and
+// dalfox slow down when wafDetected is set.
+func RunVulnerabilityScans(target, outDir string, wafDetected bool) {
 	AppendLog("[*] Starting vulnerability scanning...")
-	// Run sqlmap.
-	sqlOut, err := RunCommand("sqlmap", "-u", target, "--batch")
-	if err == nil {
-		sqlVulns := ParseSqlmapOutput(sqlOut)
-		scanResult.VulnURLs = append(scanResult.VulnURLs, sqlVulns...)
-	}
-	// Run dalfox.
-	dalfoxOut, err := RunCommand("dalfox", "url", target)
-	if err == nil {
-		xssVulns := ParseDalfoxOutput(dalfoxOut)
+	if wafDetected {
+		AppendLog("[*] A WAF was detected: sqlmap and dalfox run with a delay between requests")
+	}
+	sqlmapWAF, dalfoxWAF := scanners.WAFArgs("sqlmap", wafDetected), scanners.WAFArgs("dalfox", wafDetected)
+	if web, ok := scanners.WebTarget(&scanResult, target); ok {
+		// Run sqlmap.
+		sqlOut, err := RunCommand("sqlmap", append([]string{"-u", web, "--batch"}, sqlmapWAF...)...)
+		if err == nil {
+			sqlVulns := parsers.ParseSqlmapOutput(sqlOut, web)
+			scanResult.VulnURLs = append(scanResult.VulnURLs, sqlVulns...)
+		}
+		// Run dalfox.
+		xssVulns, err := scanners.RunDalfox(filepath.Join(outDir, "dalfox.json"), append([]string{"url", web}, dalfoxWAF...)...)
+		scanResult.VulnURLs = append(scanResult.VulnURLs, xssVulns...)
+		if err != nil {
+			AppendLog("[!] dalfox error: " + err.Error())
+		}
+	} else {
+		AppendLog("[*] Skipping sqlmap and dalfox: " + target + " did not answer HTTP")
+	}
+	// Run sqlmap and dalfox against the archived URLs ParamSpider found,
+	// then the parameters Arjun discovered.
+	paramURLs := uniqueStrings(append(append([]string{}, scanResult.ParameterizedURLs...), scanners.ParameterizedURLs(scanResult.Parameters)...))
+	paramURLs, walled := scanners.PreferReachable(&scanResult, paramURLs)
+	if walled > 0 {
+		AppendLog(fmt.Sprintf("[*] Skipping %d URL(s) with parameters behind a login wall", walled))
+	}
+	if len(paramURLs) > 0 {
+		listFile := filepath.Join(outDir, "injection_urls.txt")
+		WriteLines(paramURLs, listFile)
+		AppendLog(fmt.Sprintf("[*] Testing %d URL(s) with discovered parameters...", len(paramURLs)))
+		if sqlOut, err := RunCommand("sqlmap", append([]string{"-m", listFile, "--batch"}, sqlmapWAF...)...); err == nil {
+			scanResult.VulnURLs = append(scanResult.VulnURLs, parsers.ParseSqlmapOutput(sqlOut, "")...)
+		} else {
+			AppendLog("[!] sqlmap error: " + err.Error())
+		}
+		xssVulns, err := scanners.RunDalfox(filepath.Join(outDir, "dalfox_params.json"), append([]string{"file", listFile}, dalfoxWAF...)...)
 		scanResult.VulnURLs = append(scanResult.VulnURLs, xssVulns...)
+		if err != nil {
+			AppendLog("[!] dalfox error: " + err.Error())
+		}
+	}
+	// Run kxss over the discovered URLs.
+	if len(scanResult.AllURLs) > 0 {
+		kxssOut, err := utils.RunCommandInput("kxss", strings.Join(scanResult.AllURLs, "\n")+"\n")
+		if err == nil {
+			reflected := parsers.ParseKxssOutput(kxssOut)
+			scanResult.VulnURLs = append(scanResult.VulnURLs, reflected...)
+			AppendLog(fmt.Sprintf("[*] kxss found %d reflected parameter(s)", len(reflected)))
+		} else {
+			AppendLog("[!] kxss error: " + err.Error())
+		}
+	}
+	// Run corsy against the hosts that answered HTTP.
+	runCorsy(outDir)
+	// Attach CVSS vectors from the per-type templates.
+	for i := range scanResult.VulnURLs {
+		utils.ApplyCVSS(&scanResult.VulnURLs[i])
 	}
 	AppendLog("[*] Vulnerability scanning complete.")
 	// Save vulnerabilities.
@@ -332,211 +1081,2154 @@ func RunVulnerabilityScans(target, outDir string) {
 	_ = ioutil.WriteFile(vulnFile, data, 0644)
 }
 
+// runCorsy checks every host that answered HTTP for CORS misconfigurations with corsy.
+func runCorsy(outDir string) {
+	targets, err := scanners.WebTargets(outDir, &scanResult)
+	if err != nil {
+		AppendLog("[!] corsy skipped: " + err.Error())
+		return
+	}
+	if len(targets) == 0 {
+		return
+	}
+	targetFile := filepath.Join(outDir, "corsy_targets.txt")
+	corsyFile := filepath.Join(outDir, "corsy.json")
+	if err := WriteLines(targets, targetFile); err != nil {
+		AppendLog("[!] corsy error: " + err.Error())
+		return
+	}
+	if _, err := RunCommand("corsy", "-i", targetFile, "-o", corsyFile); err != nil {
+		AppendLog("[!] corsy error: " + err.Error())
+		return
+	}
+	out, err := ioutil.ReadFile(corsyFile)
+	if err != nil {
+		AppendLog("[!] corsy produced no output: " + err.Error())
+		return
+	}
+	vulns, err := parsers.ParseCorsyOutput(out)
+	if err != nil {
+		AppendLog("[!] " + err.Error())
+		return
+	}
+	scanResult.VulnURLs = append(scanResult.VulnURLs, vulns...)
+	AppendLog(fmt.Sprintf("[*] corsy found %d CORS misconfiguration(s)", len(vulns)))
+}
+
 // EnrichWithShodan performs Shodan lookups for discovered live hosts.
 func EnrichWithShodan(apiKey, outDir string) {
 	AppendLog("[*] Starting Shodan enrichment...")
 	var ips []string
 	for _, s := range scanResult.Subdomains {
-		ipsFound, err := net.LookupIP(s.Hostname)
-		if err != nil {
+		// Internal hosts and addresses never leave for a third party.
+		if utils.IsInternalHost(s) {
 			continue
 		}
-		for _, ip := range ipsFound {
-			if ip.To4() != nil {
-				ips = append(ips, ip.String())
+		for _, ip := range s.IPs {
+			if utils.ClassifyIP(ip) == "" {
+				ips = append(ips, ip)
 			}
 		}
 	}
 	ips = uniqueStrings(ips)
-	var allData []interface{}
+
+	// Cap lookups to the run budget. Host lookups spend no query credits,
+	// but the credits left are recorded before and after for the report.
+	usage := ProviderUsage{Provider: "shodan", Estimated: utils.EstimateQueries("shodan", len(ips))}
+	remaining := -1
+	if credits, err := ShodanCredits(apiKey); err == nil {
+		usage.CreditsBefore = &credits
+		remaining = credits
+	} else {
+		AppendLog("[!] Could not read Shodan quota: " + err.Error())
+	}
+	budget := utils.QueryBudget("SHODAN_QUERY_BUDGET")
+	if budget >= 0 {
+		usage.Budget = budget
+	}
+	allowed, warnings := utils.PlanQueries("shodan", len(ips), budget, remaining)
+	for _, w := range warnings {
+		AppendLog("[!] " + w)
+	}
+	usage.Warnings = warnings
+	if allowed < len(ips) {
+		ips = ips[:allowed]
+	}
+
+	allData := []ShodanHost{}
 	for _, ip := range ips {
 		data, err := ShodanLookup(ip, apiKey)
-		if err == nil && data != nil {
-			allData = append(allData, data)
-			AppendLog(fmt.Sprintf("[*] Shodan for %s: %v", ip, data["ports"]))
+		usage.Queries++
+		if err != nil {
+			AppendLog("[!] " + err.Error())
+			continue
 		}
+		allData = append(allData, data)
+		AppendLog(fmt.Sprintf("[*] Shodan for %s: %v", ip, data.Ports))
 	}
+	if credits, err := ShodanCredits(apiKey); err == nil {
+		usage.CreditsAfter = &credits
+	}
+	scanMu.Lock()
+	scanResult.APIUsage = append(scanResult.APIUsage, usage)
+	scanMu.Unlock()
 	// Save enrichment data.
 	_ = ioutil.WriteFile(filepath.Join(outDir, "enrichment.json"),
 		mustMarshal(allData), 0644)
-	AppendLog("[*] Shodan enrichment complete.")
+	AppendLog(fmt.Sprintf("[*] Shodan enrichment complete (%d queries).", usage.Queries))
 }
 
-// ShodanLookup queries Shodan API.
-func ShodanLookup(ip, apiKey string) (map[string]interface{}, error) {
-	if apiKey == "" {
-		return nil, errors.New("no Shodan API key provided")
+// shodanAPIInfo is the subset of Shodan's /api-info response the tool uses.
+type shodanAPIInfo struct {
+	QueryCredits int    `json:"query_credits"`
+	Plan         string `json:"plan"`
+}
+
+// ShodanCredits returns the query credits left on the Shodan account.
+func ShodanCredits(apiKey string) (int, error) {
+	client, err := newHTTPClient(false)
+	if err != nil {
+		return 0, err
 	}
-	url := fmt.Sprintf("https://api.shodan.io/shodan/host/%s?key=%s", ip, apiKey)
-	resp, err := http.Get(url)
+	ctx, cancel := context.WithTimeout(context.Background(), shodanTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, shodanAPI+"/api-info?key="+url.QueryEscape(apiKey), nil)
 	if err != nil {
-		return nil, err
+		return 0, utils.RequestError("shodan", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, utils.RequestError("shodan", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Shodan error: %s", string(body))
+		return 0, &utils.ProviderError{Provider: "shodan", Err: fmt.Errorf("api-info HTTP %d", resp.StatusCode)}
 	}
-	var data map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&data)
-	return data, err
+	var info shodanAPIInfo
+	err = utils.DecodeProviderJSON("shodan", resp.Body, shodanMaxBody, &info)
+	return info.QueryCredits, err
 }
 
-func mustMarshal(v interface{}) []byte {
-	b, err := json.MarshalIndent(v, "", "  ")
+// ReconcileInventory compares discovered subdomains with the inventory CSV
+// and writes reconciliation.json.
+func ReconcileInventory(inventoryPath, outDir string) {
+	AppendLog("[*] Reconciling discovered assets with inventory " + inventoryPath + "...")
+	entries, err := utils.LoadInventory(inventoryPath)
 	if err != nil {
-		return []byte("{}")
+		AppendLog("[!] Inventory error: " + err.Error())
+		return
+	}
+	var hosts []string
+	scanMu.Lock()
+	for _, s := range scanResult.Subdomains {
+		hosts = append(hosts, s.Hostname)
+	}
+	scanMu.Unlock()
+	rec := utils.Reconcile(entries, hosts)
+	scanMu.Lock()
+	scanResult.Reconciliation = &rec
+	scanMu.Unlock()
+	for _, h := range rec.Unknown {
+		AppendLog("[!] Shadow asset (not in inventory): " + h)
+	}
+	AppendLog(fmt.Sprintf("[*] Inventory: %d matched, %d unknown, %d unseen", len(rec.Matched), len(rec.Unknown), len(rec.Unseen)))
+	_ = ioutil.WriteFile(filepath.Join(outDir, "reconciliation.json"), mustMarshal(rec), 0644)
+}
+
+// AnalyzeResponseAnomalies compares the answers of hosts serving the same
+// application path by path, records the hosts that stand out as info
+// findings and writes them to response_anomalies.json.
+func AnalyzeResponseAnomalies(outDir string) {
+	AppendLog("[*] Comparing responses across hosts serving the same application...")
+	scanMu.Lock()
+	samples := utils.ResponseSamples(scanResult)
+	scanMu.Unlock()
+	anomalies, skipped := utils.FindResponseAnomalies(samples, utils.AnomalyThresholdsFromEnv())
+	if skipped > 0 {
+		AppendLog(fmt.Sprintf("[*] Response anomalies: %d path(s) answered by too few hosts of one application to judge", skipped))
+	}
+	for _, a := range anomalies {
+		AppendLog(fmt.Sprintf("[!] Response anomaly: %s%s %s", a.Sample.Host, a.Sample.Path, a.Reason))
+	}
+	scanMu.Lock()
+	scanResult.ResponseAnomalies = anomalies
+	scanResult.VulnURLs = append(scanResult.VulnURLs, utils.AnomalyFindings(anomalies)...)
+	scanMu.Unlock()
+	AppendLog(fmt.Sprintf("[*] Response anomalies: %d across %d sample(s)", len(anomalies), len(samples)))
+	_ = ioutil.WriteFile(filepath.Join(outDir, "response_anomalies.json"), mustMarshal(anomalies), 0644)
+}
+
+// ShodanLookup queries Shodan API. The response is size-capped and decoded
+// into a typed struct; malformed responses yield a *utils.ProviderError.
+func ShodanLookup(ip, apiKey string) (ShodanHost, error) {
+	var data ShodanHost
+	if apiKey == "" {
+		return data, errors.New("no Shodan API key provided")
+	}
+	client, err := newHTTPClient(false)
+	if err != nil {
+		return data, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shodanTimeout)
+	defer cancel()
+	u := fmt.Sprintf("%s/shodan/host/%s?key=%s", shodanAPI, url.PathEscape(ip), url.QueryEscape(apiKey))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return data, utils.RequestError("shodan", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return data, utils.RequestError("shodan", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return data, &utils.ProviderError{Provider: "shodan", Err: fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))}
+	}
+	err = utils.DecodeProviderJSON("shodan", resp.Body, shodanMaxBody, &data)
+	return data, err
+}
+
+func mustMarshal(v interface{}) []byte {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return []byte("{}")
+	}
+	return b
+}
+
+// buildFinalReport renders the final report text. The caller must hold scanMu.
+// reportBannerLen is how much of a banner the report's services show.
+const reportBannerLen = 80
+
+// shortBanner cuts a service banner to reportBannerLen characters.
+func shortBanner(banner string) string {
+	if r := []rune(banner); len(r) > reportBannerLen {
+		return string(r[:reportBannerLen]) + "..."
+	}
+	return banner
+}
+
+// exposedSecretsSection lists the secrets of every detector, most severe
+// class first, each with where it was found. Only masked values are shown.
+func exposedSecretsSection(secrets []SecretFinding) string {
+	sorted := append([]SecretFinding(nil), secrets...)
+	utils.SortSecrets(sorted)
+	verified := 0
+	for _, s := range sorted {
+		if s.Verification == utils.SecretVerified {
+			verified++
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nExposed Secrets (%d, %d verified):\n", len(sorted), verified)
+	for _, s := range sorted {
+		fmt.Fprintf(&b, "  %-8s %-11s %s  %s, %s\n", utils.SecretClassSeverity(s.Class), s.Class, s.Masked, s.Verification, s.Confidence)
+		for _, loc := range s.Locations {
+			where := loc.Artifact
+			if loc.Location != "" {
+				where += " " + loc.Location
+			}
+			fmt.Fprintf(&b, "      %s (%s)\n", where, loc.Detector)
+		}
+	}
+	return b.String()
+}
+
+// slowestHostsShown is how many hosts the report's slowest hosts lists.
+const slowestHostsShown = 10
+
+// slowestHosts returns the n hosts with the slowest time to first byte at
+// the 95th percentile, slowest first.
+func slowestHosts(timings []HostTiming, n int) []HostTiming {
+	var timed []HostTiming
+	for _, ht := range timings {
+		if ht.TTFB.Count > 0 {
+			timed = append(timed, ht)
+		}
+	}
+	sort.SliceStable(timed, func(i, j int) bool { return timed[i].TTFB.P95MS > timed[j].TTFB.P95MS })
+	if len(timed) > n {
+		timed = timed[:n]
+	}
+	return timed
+}
+
+// formatMS formats a phase timing in milliseconds; a phase no request went
+// through, such as TLS over plain HTTP, shows as "-".
+func formatMS(ms float64, count int) string {
+	switch {
+	case count == 0:
+		return "-"
+	case ms < 10:
+		return fmt.Sprintf("%.1fms", ms)
+	}
+	return fmt.Sprintf("%.0fms", ms)
+}
+
+func buildFinalReport(target string) string {
+	var b strings.Builder
+	b.WriteString("Final report for " + target + " generated at " + time.Now().Format(time.RFC1123) + "\n")
+	b.WriteString("Authorization: " + utils.FormatAuthorization(scanResult.Authorization) + "\n")
+	if len(scanResult.Stages) > 0 {
+		b.WriteString("\nStages:\n")
+		for _, st := range scanResult.Stages {
+			b.WriteString(fmt.Sprintf("  %-24s %s\n", st.Stage, st.Status))
+			for _, msg := range st.Messages {
+				b.WriteString("    - " + msg + "\n")
+			}
+		}
+	}
+	if rec := scanResult.Reconciliation; rec != nil {
+		b.WriteString(fmt.Sprintf("\nInventory reconciliation: %d matched, %d shadow, %d unseen\n", len(rec.Matched), len(rec.Unknown), len(rec.Unseen)))
+		if len(rec.Unknown) > 0 {
+			b.WriteString("  Shadow assets (discovered, not in inventory):\n")
+			for _, h := range rec.Unknown {
+				b.WriteString("    ! " + h + "\n")
+			}
+		}
+		if len(rec.Unseen) > 0 {
+			b.WriteString("  Inventoried but not seen:\n")
+			for _, e := range rec.Unseen {
+				b.WriteString("    - " + e.Hostname + "\n")
+			}
+		}
+	}
+	if baseline != nil {
+		var newHosts []string
+		newURLs, newFindings := 0, 0
+		for _, sub := range scanResult.Subdomains {
+			if baseline.NewHost(sub.Hostname) {
+				newHosts = append(newHosts, sub.Hostname)
+			}
+		}
+		for _, u := range scanResult.AllURLs {
+			if baseline.NewURL(u) {
+				newURLs++
+			}
+		}
+		for _, v := range scanResult.VulnURLs {
+			if baseline.NewFinding(v) {
+				newFindings++
+			}
+		}
+		b.WriteString(fmt.Sprintf("\nSince baseline %s: %d new subdomain(s), %d new URL(s), %d new finding(s)\n",
+			baseline.Dir, len(newHosts), newURLs, newFindings))
+		for _, h := range newHosts {
+			b.WriteString("    + " + h + "\n")
+		}
+	}
+	var internal []SubdomainResult
+	for _, sub := range scanResult.Subdomains {
+		if utils.IsInternalHost(sub) {
+			internal = append(internal, sub)
+		}
+	}
+	if len(internal) > 0 {
+		b.WriteString(fmt.Sprintf("\nInternal exposure (%d): public DNS or crawl data leaks these internal hostnames\n", len(internal)))
+		if !includePrivate {
+			b.WriteString("  (not probed; use --include-private for internal engagements)\n")
+		}
+		for _, sub := range internal {
+			addrs := append(append([]string{sub.IP}, sub.IPs...), sub.IPv6...)
+			b.WriteString(fmt.Sprintf("  %s -> %s (%s)\n", sub.Hostname, strings.Join(utils.UniqueStrings(addrs), ", "), utils.ClassifyIP(sub.IP)))
+		}
+	}
+	if len(scanResult.ResponseAnomalies) > 0 {
+		b.WriteString(fmt.Sprintf("\nPer-host anomalies (%d): hosts answering unlike the rest of their application\n", len(scanResult.ResponseAnomalies)))
+		for _, a := range scanResult.ResponseAnomalies {
+			b.WriteString(fmt.Sprintf("  %s%s: %s\n", a.Sample.Host, a.Sample.Path, a.Reason))
+		}
+	}
+	if len(scanResult.SecurityTxt)+len(scanResult.HumansTxt)+len(scanResult.SaaS) > 0 {
+		b.WriteString("\nDisclosure & metadata:\n")
+		for _, txt := range scanResult.SecurityTxt {
+			b.WriteString("  " + txt.URL)
+			if txt.Signed {
+				b.WriteString(" (signed)")
+			}
+			if txt.Expired {
+				b.WriteString(" EXPIRED " + txt.Expires)
+			} else if txt.Expires != "" {
+				b.WriteString(" expires " + txt.Expires)
+			}
+			b.WriteString("\n    Contact: " + strings.Join(txt.Contacts, ", ") + "\n")
+			if len(txt.Policy) > 0 {
+				b.WriteString("    Policy:  " + strings.Join(txt.Policy, ", ") + "\n")
+			}
+		}
+		if len(scanResult.SecurityTxt) == 0 {
+			b.WriteString("  No security.txt published\n")
+		}
+		for _, h := range scanResult.HumansTxt {
+			b.WriteString(fmt.Sprintf("  %s (%d line(s))\n", h.URL, len(h.Lines)))
+		}
+		if len(scanResult.SaaS) > 0 {
+			var services []string
+			for _, s := range scanResult.SaaS {
+				services = append(services, s.Service)
+			}
+			b.WriteString("  Services in DNS TXT: " + strings.Join(utils.UniqueStrings(services), ", ") + "\n")
+		}
+	}
+	var placeholders []LiveHost
+	for _, lh := range scanResult.LiveHosts {
+		if lh.Placeholder != "" {
+			placeholders = append(placeholders, lh)
+		}
+	}
+	if len(placeholders) > 0 {
+		b.WriteString(fmt.Sprintf("\nParked & default pages (%d): dead ends, not worth crawling or fuzzing\n", len(placeholders)))
+		if !includeParked {
+			b.WriteString("  (left out of aggressive stages; use --include-parked to scan them)\n")
+		}
+		for _, lh := range placeholders {
+			b.WriteString(fmt.Sprintf("  %s [%d] %s (%s)\n", lh.URL, lh.StatusCode, lh.Placeholder, utils.FormatTags(lh.Tags)))
+		}
+	}
+	var web []LiveHost
+	for _, lh := range scanResult.LiveHosts {
+		if lh.URL != "" {
+			web = append(web, lh)
+		}
+	}
+	if len(web) > 0 {
+		b.WriteString(fmt.Sprintf("\nWeb services (%d):\n", len(web)))
+		for _, lh := range web {
+			b.WriteString(fmt.Sprintf("  %-5d %s [%d] %s", lh.Port, lh.URL, lh.StatusCode, lh.Title))
+			if len(lh.Protocols) > 0 {
+				b.WriteString(" (" + strings.Join(lh.Protocols, ", ") + ")")
+			}
+			if len(lh.Technologies) > 0 {
+				b.WriteString(" - " + utils.FormatTechnologies(lh.Technologies))
+			}
+			if lh.WAF != "" {
+				b.WriteString(" [WAF: " + lh.WAF + "]")
+			}
+			b.WriteString("\n")
+		}
+	}
+	var services []string
+	for _, sub := range scanResult.Subdomains {
+		for _, p := range sub.Services {
+			line := fmt.Sprintf("  %s:%d/%s %s", sub.Hostname, p.Port, p.Protocol, p.Service)
+			if p.Product != "" {
+				line += " - " + strings.TrimSpace(p.Product+" "+p.Version)
+			}
+			if p.Banner != "" {
+				line += fmt.Sprintf(" %q", shortBanner(p.Banner))
+			}
+			services = append(services, strings.TrimRight(line, " "))
+		}
+	}
+	if len(services) > 0 {
+		b.WriteString(fmt.Sprintf("\nServices (%d):\n", len(services)))
+		b.WriteString(strings.Join(services, "\n") + "\n")
+	}
+	if slowest := slowestHosts(scanResult.HostTimings, slowestHostsShown); len(slowest) > 0 {
+		b.WriteString("\nSlowest hosts (by time to first byte, p95; median DNS / connect / TLS / TTFB):\n")
+		for _, ht := range slowest {
+			b.WriteString(fmt.Sprintf("  %-40s p95 %-9s %s / %s / %s / %s (%d request(s))\n", ht.Host,
+				formatMS(ht.TTFB.P95MS, ht.TTFB.Count), formatMS(ht.DNS.MedianMS, ht.DNS.Count), formatMS(ht.Connect.MedianMS, ht.Connect.Count),
+				formatMS(ht.TLS.MedianMS, ht.TLS.Count), formatMS(ht.TTFB.MedianMS, ht.TTFB.Count), ht.Requests))
+		}
+	}
+	// Group hosts and findings by tag.
+	tagHosts := make(map[string][]string)
+	tagFindings := make(map[string]int)
+	for _, sub := range scanResult.Subdomains {
+		for _, t := range utils.TagsMatching(sub.Tags, "*") {
+			tagHosts[t] = append(tagHosts[t], sub.Hostname)
+		}
+	}
+	for _, v := range scanResult.VulnURLs {
+		for _, t := range utils.TagsMatching(v.Tags, "*") {
+			tagFindings[t]++
+		}
+	}
+	if len(tagHosts) > 0 {
+		var tags []string
+		for t := range tagHosts {
+			tags = append(tags, t)
+		}
+		sort.Strings(tags)
+		b.WriteString("\nAssets by tag:\n")
+		for _, t := range tags {
+			b.WriteString(fmt.Sprintf("  %s: %d host(s), %d finding(s): %s\n", t, len(tagHosts[t]), tagFindings[t], strings.Join(tagHosts[t], ", ")))
+		}
+	}
+	if len(scanResult.TLSPosture) > 0 {
+		counts := make(map[string]int)
+		for _, p := range scanResult.TLSPosture {
+			for _, v := range p.Versions {
+				counts[v]++
+			}
+		}
+		b.WriteString(fmt.Sprintf("\nTLS posture (%d services):\n", len(scanResult.TLSPosture)))
+		for _, v := range []string{"TLS1.0", "TLS1.1", "TLS1.2", "TLS1.3"} {
+			b.WriteString(fmt.Sprintf("  %-7s accepted by %d service(s)\n", v, counts[v]))
+		}
+	}
+	if len(scanResult.TLS) > 0 {
+		b.WriteString(fmt.Sprintf("\nTLS findings (%d):\n", len(scanResult.TLS)))
+		for _, t := range scanResult.TLS {
+			b.WriteString(fmt.Sprintf("  %-8s %s %s: %s\n", t.Severity, t.Host, t.ID, t.Finding))
+		}
+	}
+	b.WriteString(pageHygieneSection(scanResult.VulnURLs))
+	if len(scanResult.Methods) > 0 {
+		b.WriteString(fmt.Sprintf("\nAllowed HTTP methods (%d endpoints):\n", len(scanResult.Methods)))
+		for _, m := range scanResult.Methods {
+			b.WriteString(fmt.Sprintf("  %s: %s\n", m.URL, strings.Join(m.Allowed, ", ")))
+		}
+	}
+	if len(scanResult.Coverage) > 0 {
+		b.WriteString("\nCoverage:\n")
+		b.WriteString(fmt.Sprintf("  %-26s %6s %9s %9s  %s\n", "Stage", "Known", "Attempted", "Completed", "Skipped"))
+		for _, c := range scanResult.Coverage {
+			var reasons []string
+			for reason, n := range c.Skipped {
+				reasons = append(reasons, fmt.Sprintf("%d %s", n, reason))
+			}
+			sort.Strings(reasons)
+			b.WriteString(fmt.Sprintf("  %-26s %6d %9d %9d  %s\n", c.Stage, c.Known, c.Attempted, c.Completed, strings.Join(reasons, ", ")))
+		}
+	}
+	if len(scanResult.Secrets) > 0 {
+		b.WriteString(exposedSecretsSection(scanResult.Secrets))
+	}
+	if len(scanResult.VulnURLs) > 0 {
+		counts := make(map[string]int)
+		for _, v := range scanResult.VulnURLs {
+			counts[v.Disposition]++
+		}
+		b.WriteString(fmt.Sprintf("\nFindings (%d): %d confirmed, %d false-positive, %d duplicate, %d needs-retest, %d untriaged\n",
+			len(scanResult.VulnURLs), counts[utils.DispositionConfirmed], counts[utils.DispositionFalsePositive],
+			counts[utils.DispositionDuplicate], counts[utils.DispositionNeedsRetest], counts[""]))
+		for _, v := range utils.SortBySeverity(scanResult.VulnURLs) {
+			disposition := v.Disposition
+			if disposition == "" {
+				disposition = "untriaged"
+			}
+			issue := v.Issue
+			if v.CWE > 0 {
+				issue += fmt.Sprintf(" (CWE-%d)", v.CWE)
+			}
+			b.WriteString(fmt.Sprintf("  %-8s %-14s %s at %s\n", utils.FindingSeverity(v), disposition, issue, v.URL))
+		}
+	}
+	if len(scanResult.APIUsage) > 0 {
+		b.WriteString("\nAPI usage:\n")
+		for _, u := range scanResult.APIUsage {
+			line := fmt.Sprintf("  %-8s %d queries (estimated %d)", u.Provider, u.Queries, u.Estimated)
+			if u.CreditsBefore != nil && u.CreditsAfter != nil {
+				line += fmt.Sprintf(", credits %d -> %d (consumed %d)", *u.CreditsBefore, *u.CreditsAfter, *u.CreditsBefore-*u.CreditsAfter)
+			}
+			b.WriteString(line + "\n")
+			for _, w := range u.Warnings {
+				b.WriteString("    ! " + w + "\n")
+			}
+		}
+	}
+	if len(scanResult.SourceStats) > 0 {
+		b.WriteString("\nSubdomain sources:\n")
+		for _, line := range utils.FormatSourceStats(scanResult.SourceStats) {
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// reproCommand suggests a command that reproduces a finding by hand.
+func reproCommand(v VulnerabilityResult) string {
+	switch v.Issue {
+	case "SQL Injection":
+		if param := strings.SplitN(v.Parameter, " (", 2)[0]; param != "" {
+			return fmt.Sprintf("sqlmap -u '%s' -p '%s' --batch", v.URL, param)
+		}
+		return fmt.Sprintf("sqlmap -u '%s' --batch", v.URL)
+	case "XSS":
+		return fmt.Sprintf("dalfox url '%s'", v.URL)
+	case "Weak TLS Protocol", "Weak TLS Cipher":
+		if u, err := url.Parse(v.URL); err == nil {
+			return fmt.Sprintf("openssl s_client -connect %s:443 -servername %s", u.Hostname(), u.Hostname())
+		}
+	}
+	return fmt.Sprintf("curl -sk -i '%s'", v.URL)
+}
+
+// ---------- TUI Implementation using tview ----------
+
+// centered places p in the middle of the screen at the given size.
+func centered(p tview.Primitive, width, height int) tview.Primitive {
+	return tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(p, height, 1, true).
+			AddItem(nil, 0, 1, false), width, 1, true).
+		AddItem(nil, 0, 1, false)
+}
+
+// keyName converts a key event into the name the keymap binds it under.
+func keyName(event *tcell.EventKey) string {
+	switch event.Key() {
+	case tcell.KeyRune:
+		return string(event.Rune())
+	case tcell.KeyEscape:
+		return "Esc"
+	case tcell.KeyEnter:
+		return "Enter"
+	case tcell.KeyUp:
+		return "Up"
+	case tcell.KeyDown:
+		return "Down"
+	case tcell.KeyPgUp:
+		return "PgUp"
+	case tcell.KeyPgDn:
+		return "PgDn"
+	case tcell.KeyHome:
+		return "Home"
+	case tcell.KeyEnd:
+		return "End"
+	}
+	return ""
+}
+
+// pagedView shows a dataset one screen at a time: a draw formats only the
+// rows in view, so a list of hundreds of thousands of rows costs no more to
+// display or scroll than a screenful. Rows are not wrapped; long ones
+// scroll sideways.
+type pagedView struct {
+	*tview.TextView
+	title string
+
+	mu     sync.Mutex
+	data   *dataset.Dataset
+	render func(row int) string
+	port   dataset.Viewport
+}
+
+func newPagedView(title string) *pagedView {
+	p := &pagedView{TextView: tview.NewTextView(), title: title}
+	p.SetDynamicColors(true).SetWrap(false)
+	p.SetBorder(true).SetTitle(title)
+	return p
+}
+
+// SetData replaces the rows shown, keeping the sort and filter in force.
+// render formats row i; it runs on the UI goroutine.
+func (p *pagedView) SetData(d *dataset.Dataset, render func(row int) string) {
+	p.mu.Lock()
+	old := p.data
+	p.mu.Unlock()
+	if old != nil {
+		// Sorting a long list takes a while; do it before taking the lock
+		// the UI draws under.
+		col, desc := old.Sort()
+		d.SortBy(col, desc)
+		d.Filter(old.Query())
+	}
+	p.mu.Lock()
+	p.data, p.render = d, render
+	p.mu.Unlock()
+}
+
+// Draw formats the rows in view, then draws them.
+func (p *pagedView) Draw(screen tcell.Screen) {
+	_, _, _, height := p.GetInnerRect()
+	var text strings.Builder
+	title := p.title
+	p.mu.Lock()
+	if p.data != nil {
+		p.port.Height = height
+		p.port.Clamp(p.data.Len())
+		for _, row := range p.data.Window(p.port.Top, p.port.Height) {
+			text.WriteString(p.render(row))
+			text.WriteByte('\n')
+		}
+		title = fmt.Sprintf("%s (%s)", p.title, p.status())
+	}
+	p.mu.Unlock()
+	p.SetTitle(title)
+	p.SetText(text.String())
+	p.TextView.Draw(screen)
+}
+
+// status summarizes the position, sort and filter for the title.
+func (p *pagedView) status() string {
+	n := p.data.Len()
+	status := fmt.Sprintf("%d", n)
+	if n > 0 {
+		last := p.port.Top + p.port.Height
+		if last > n {
+			last = n
+		}
+		status = fmt.Sprintf("%d-%d of %d", p.port.Top+1, last, n)
+	}
+	if q := p.data.Query(); q != "" {
+		status += fmt.Sprintf(" matching %q of %d", q, p.data.Total())
+	}
+	if col, desc := p.data.Sort(); col >= 0 {
+		status += " by " + p.data.Columns()[col]
+		if desc {
+			status += ", reversed"
+		}
+	} else if desc {
+		status += " reversed"
+	}
+	return tview.Escape(status)
+}
+
+// Move applies a viewport move, given the length of the view.
+func (p *pagedView) Move(move func(port *dataset.Viewport, length int)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.data != nil {
+		move(&p.port, p.data.Len())
+	}
+}
+
+// CycleSort sorts by the next column, back to discovery order after the
+// last; reverse flips the current order instead.
+func (p *pagedView) CycleSort(reverse bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.data == nil {
+		return
+	}
+	col, desc := p.data.Sort()
+	if reverse {
+		desc = !desc
+	} else if col++; col >= len(p.data.Columns()) {
+		col = -1
+	}
+	p.data.SortBy(col, desc)
+	p.port.Home()
+}
+
+// Filter shows only the rows containing query.
+func (p *pagedView) Filter(query string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.data != nil {
+		p.data.Filter(query)
+		p.port.Home()
+	}
+}
+
+// Query returns the filter in force.
+func (p *pagedView) Query() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.data == nil {
+		return ""
+	}
+	return p.data.Query()
+}
+
+// subdomainRows snapshots the subdomains for the Subdomains tab, with what
+// the HTTP probe found on each, and counts those the baseline run did not
+// have. The caller holds scanMu.
+func subdomainRows() (*dataset.Dataset, func(row int) string, int) {
+	subs := append([]SubdomainResult(nil), scanResult.Subdomains...)
+	probes := make(map[string]LiveHost)
+	altWeb := make(map[string][]string)
+	for _, lh := range scanResult.LiveHosts {
+		if lh.Port != 0 && lh.Port != 80 && lh.Port != 443 {
+			altWeb[lh.Hostname] = append(altWeb[lh.Hostname], fmt.Sprintf("%s [%d]", lh.URL, lh.StatusCode))
+			continue
+		}
+		probes[lh.Hostname] = lh
+	}
+	timings := make(map[string]HostTiming)
+	for _, ht := range scanResult.HostTimings {
+		timings[ht.Host] = ht
+	}
+	isNew := make([]bool, len(subs))
+	newSubs := 0
+	for i, sub := range subs {
+		if baseline.NewHost(sub.Hostname) {
+			isNew[i] = true
+			newSubs++
+		}
+	}
+	render := func(i int) string {
+		sub := subs[i]
+		var b strings.Builder
+		if isNew[i] {
+			b.WriteString(newMarker)
+		}
+		ip := sub.IP
+		if ip == "" {
+			ip = "[gray]unresolved[white]"
+		} else if len(sub.IPs) > 1 {
+			ip += fmt.Sprintf(" (+%d)", len(sub.IPs)-1)
+		}
+		fmt.Fprintf(&b, "%s - IP: %s | Ports: %v", sub.Hostname, ip, sub.Ports)
+		if len(sub.CNAME) > 0 {
+			fmt.Fprintf(&b, " | CNAME: %s", strings.Join(sub.CNAME, " -> "))
+		}
+		if len(sub.Tags) > 0 {
+			fmt.Fprintf(&b, " [yellow]%s[white]", tview.Escape(utils.FormatTags(sub.Tags)))
+		}
+		if lh, ok := probes[sub.Hostname]; ok && lh.URL != "" {
+			fmt.Fprintf(&b, " | [green]%d[white] %s %q len=%d %s",
+				lh.StatusCode, lh.URL, tview.Escape(lh.Title), lh.ContentLength, tview.Escape(lh.Server))
+			if len(lh.Protocols) > 0 {
+				fmt.Fprintf(&b, " [gray]%s[white]", strings.Join(lh.Protocols, ","))
+			}
+			if len(lh.Technologies) > 0 {
+				fmt.Fprintf(&b, " | [blue]%s[white]", tview.Escape(utils.FormatTechnologies(lh.Technologies)))
+			}
+			if lh.WAF != "" {
+				fmt.Fprintf(&b, " | [red]WAF: %s[white]", tview.Escape(lh.WAF))
+			}
+		} else if ok {
+			b.WriteString(" | [gray]no HTTP answer[white]")
+		}
+		if alt := altWeb[sub.Hostname]; len(alt) > 0 {
+			fmt.Fprintf(&b, " | web on: %s", strings.Join(alt, ", "))
+		}
+		if ht, ok := timings[sub.Hostname]; ok && ht.TTFB.Count > 0 {
+			fmt.Fprintf(&b, " | [gray]dns %s connect %s tls %s ttfb %s (p95 %s)[white]",
+				formatMS(ht.DNS.MedianMS, ht.DNS.Count), formatMS(ht.Connect.MedianMS, ht.Connect.Count),
+				formatMS(ht.TLS.MedianMS, ht.TLS.Count), formatMS(ht.TTFB.MedianMS, ht.TTFB.Count), formatMS(ht.TTFB.P95MS, ht.TTFB.Count))
+		}
+		return b.String()
+	}
+	text := func(i int) string {
+		sub := subs[i]
+		fields := []string{sub.Hostname, sub.IP, strings.Join(sub.IPs, " "), strings.Join(sub.IPv6, " "), strings.Join(sub.CNAME, " "), utils.FormatTags(sub.Tags), sub.Source}
+		if lh, ok := probes[sub.Hostname]; ok {
+			fields = append(fields, lh.URL, lh.Title, lh.Server, utils.FormatTechnologies(lh.Technologies), lh.WAF)
+		}
+		return strings.Join(append(fields, altWeb[sub.Hostname]...), " ")
+	}
+	// Hosts without an HTTP answer sort after those with one.
+	status := func(i int) int {
+		if lh, ok := probes[subs[i].Hostname]; ok && lh.StatusCode > 0 {
+			return lh.StatusCode
+		}
+		return 1000
+	}
+	// Hosts no request timed sort after the others.
+	phase := func(pick func(HostTiming) PhaseTiming, p95 bool) func(a, b int) bool {
+		value := func(i int) float64 {
+			pt := pick(timings[subs[i].Hostname])
+			switch {
+			case pt.Count == 0:
+				return math.Inf(1)
+			case p95:
+				return pt.P95MS
+			}
+			return pt.MedianMS
+		}
+		return func(a, b int) bool { return value(a) < value(b) }
+	}
+	d := dataset.New(len(subs), text,
+		dataset.Column{Name: "hostname", Less: func(a, b int) bool { return subs[a].Hostname < subs[b].Hostname }},
+		dataset.Column{Name: "IP", Less: func(a, b int) bool { return subs[a].IP < subs[b].IP }},
+		dataset.Column{Name: "HTTP status", Less: func(a, b int) bool { return status(a) < status(b) }},
+		dataset.Column{Name: "DNS", Less: phase(func(ht HostTiming) PhaseTiming { return ht.DNS }, false)},
+		dataset.Column{Name: "connect", Less: phase(func(ht HostTiming) PhaseTiming { return ht.Connect }, false)},
+		dataset.Column{Name: "TLS", Less: phase(func(ht HostTiming) PhaseTiming { return ht.TLS }, false)},
+		dataset.Column{Name: "TTFB", Less: phase(func(ht HostTiming) PhaseTiming { return ht.TTFB }, false)},
+		dataset.Column{Name: "TTFB p95", Less: phase(func(ht HostTiming) PhaseTiming { return ht.TTFB }, true)},
+	)
+	return d, render, newSubs
+}
+
+// vulnRows snapshots the findings for the Vulnerabilities tab and counts
+// those the baseline run did not have. The caller holds scanMu.
+func vulnRows() (*dataset.Dataset, func(row int) string, int) {
+	vulns := append([]VulnerabilityResult(nil), scanResult.VulnURLs...)
+	isNew := make([]bool, len(vulns))
+	newVulns := 0
+	for i, v := range vulns {
+		if baseline.NewFinding(v) {
+			isNew[i] = true
+			newVulns++
+		}
+	}
+	render := func(i int) string {
+		v := vulns[i]
+		disposition := ""
+		if v.Disposition != "" {
+			disposition = " [green]" + v.Disposition + "[-]"
+		}
+		// Confirmed takeovers are claimable and verified secrets usable
+		// right now; make them stand out.
+		color := "yellow"
+		if utils.IsConfirmedTakeover(v) || utils.IsVerifiedSecret(v) {
+			color = "red"
+		}
+		marker := ""
+		if isNew[i] {
+			marker = newMarker
+		}
+		if v.Score > 0 {
+			return fmt.Sprintf("%s[%s::b]%s[-:-:-] (CVSS %.1f): %s%s", marker, color, v.Issue, v.Score, v.URL, disposition)
+		}
+		return fmt.Sprintf("%s[%s::b]%s[-:-:-]: %s%s", marker, color, v.Issue, v.URL, disposition)
+	}
+	text := func(i int) string {
+		v := vulns[i]
+		return strings.Join([]string{utils.FindingSeverity(v), v.Issue, v.URL, v.Disposition, utils.FormatTags(v.Tags)}, " ")
+	}
+	d := dataset.New(len(vulns), text,
+		dataset.Column{Name: "severity", Less: func(a, b int) bool { return utils.SeverityLess(vulns[a], vulns[b]) }},
+		dataset.Column{Name: "issue", Less: func(a, b int) bool { return vulns[a].Issue < vulns[b].Issue }},
+		dataset.Column{Name: "URL", Less: func(a, b int) bool { return vulns[a].URL < vulns[b].URL }},
+	)
+	return d, render, newVulns
+}
+
+// ffufRows snapshots the fuzzing results for the FFUF tab. The caller
+// holds scanMu.
+func ffufRows() (*dataset.Dataset, func(row int) string) {
+	entries := append([]FfufResult(nil), scanResult.FfufEntries...)
+	render := func(i int) string {
+		f := entries[i]
+		line := fmt.Sprintf("%s (Status: %d, Size: %d, Words: %d, Lines: %d)", f.Path, f.Status, f.Size, f.Words, f.Lines)
+		if f.RedirectLocation != "" {
+			line += " -> " + tview.Escape(f.RedirectLocation)
+		}
+		return line
+	}
+	text := func(i int) string {
+		f := entries[i]
+		return fmt.Sprintf("%s %d %s", f.Path, f.Status, f.RedirectLocation)
+	}
+	d := dataset.New(len(entries), text,
+		dataset.Column{Name: "path", Less: func(a, b int) bool { return entries[a].Path < entries[b].Path }},
+		dataset.Column{Name: "status", Less: func(a, b int) bool { return entries[a].Status < entries[b].Status }},
+		dataset.Column{Name: "size", Less: func(a, b int) bool { return entries[a].Size < entries[b].Size }},
+	)
+	return d, render
+}
+
+// tutorialMarker is the file recording that the first-run tutorial was
+// dismissed; empty when there is no user config directory.
+func tutorialMarker() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "recon-tool", "tutorial_dismissed")
+}
+
+// newMarker flags subdomains and findings the --baseline run did not have;
+// "[NEW[]" is tview's escape for a literal "[NEW]".
+const newMarker = "[green::b][NEW[][-:-:-] "
+
+func startTUI(outDir, target string) {
+	app := tview.NewApplication()
+
+	// Console log view (75% height)
+	consoleView := tview.NewTextView().SetDynamicColors(true).
+		SetWrap(true).SetChangedFunc(func() { app.Draw() })
+	consoleView.SetBorder(true).SetTitle("Console Output")
+
+	// Tab views for Subdomains, Vulnerabilities, FFUF results, and Final
+	// Report. The first three can run to hundreds of thousands of rows and
+	// are paged.
+	subdomainsView := newPagedView("Subdomains")
+	vulnsView := newPagedView("Vulnerable URLs")
+	ffufView := newPagedView("FFUF Results")
+	pagedViews := map[string]*pagedView{"Subdomains": subdomainsView, "Vulnerabilities": vulnsView, "FFUF": ffufView}
+	tlsView := tview.NewTextView().SetDynamicColors(true)
+	tlsView.SetBorder(true).SetTitle("TLS Findings")
+	reportView := tview.NewTextView().SetDynamicColors(true)
+	reportView.SetBorder(true).SetTitle("Final Report")
+	// Proxy status view.
+	proxyView := tview.NewTextView().SetDynamicColors(true)
+	proxyView.SetBorder(true).SetTitle("Proxy Status")
+	updateProxyView := func(enabled bool) {
+		if enabled {
+			proxyView.SetText("[green::b]Proxy Active: http://127.0.0.1:8080")
+		} else {
+			proxyView.SetText("[red::b]Proxy Disabled")
+		}
+	}
+	updateProxyView(false)
+	// Triage view and the note prompt shown over it.
+	triageView := tview.NewTextView().SetDynamicColors(true).SetWrap(true)
+	triageView.SetBorder(true).SetTitle("Triage")
+	noteInput := tview.NewInputField().SetLabel("Note: ")
+	noteInput.SetBorder(true).SetTitle("Triage note (Enter saves, Esc cancels)")
+	notePrompt := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(nil, 0, 1, false).
+		AddItem(noteInput, 3, 0, true).
+		AddItem(nil, 0, 1, false)
+	// Filter prompt for the paged views.
+	filterInput := tview.NewInputField().SetLabel("Filter: ")
+	filterInput.SetBorder(true).SetTitle("Filter (Enter keeps, Esc restores)")
+	filterPrompt := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(nil, 0, 1, false).
+		AddItem(filterInput, 3, 0, true)
+
+	// Pages for switching between tabs.
+	pages := tview.NewPages()
+	pages.AddPage("Subdomains", subdomainsView, true, true)
+	pages.AddPage("Vulnerabilities", vulnsView, true, false)
+	pages.AddPage("FFUF", ffufView, true, false)
+	pages.AddPage("TLS", tlsView, true, false)
+	pages.AddPage("Report", reportView, true, false)
+	pages.AddPage("Proxy", proxyView, true, false)
+	pages.AddPage("Triage", triageView, true, false)
+	pages.AddPage("Note", notePrompt, true, false)
+	pages.AddPage("Filter", filterPrompt, true, false)
+	// Help overlay and first-run tutorial.
+	helpView := tview.NewTextView().SetDynamicColors(true)
+	helpView.SetBorder(true).SetTitle("Keybindings (? or Esc closes)")
+	tutorialView := tview.NewTextView().SetDynamicColors(true).SetWrap(true)
+	tutorialView.SetBorder(true).SetTitle("Welcome")
+	pages.AddPage("Help", centered(helpView, 72, 30), true, false)
+	pages.AddPage("Tutorial", centered(tutorialView, 72, 12), true, false)
+	// Popup for stages whose output failed validation.
+	validationView := tview.NewTextView().SetDynamicColors(true).SetWrap(true)
+	validationView.SetBorder(true).SetTitle("Validation failed (Esc closes)").SetBorderColor(tcell.ColorRed)
+	pages.AddPage("Validation", centered(validationView, 80, 16), true, false)
+
+	// Tab menu at the top.
+	tabMenu := tview.NewTextView().SetDynamicColors(true)
+	tabMenu.SetTextAlign(tview.AlignCenter)
+
+	// Layout: tab menu on top, pages in center, console at bottom.
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(tabMenu, 3, 1, false).
+		AddItem(pages, 0, 3, true).
+		AddItem(consoleView, 0, 1, false)
+	layout.SetBorder(true).SetTitle(" Recon Tool ").SetTitleAlign(tview.AlignCenter)
+
+	// keymap is the single source of keybindings: input is dispatched
+	// through it and the help overlay is generated from it.
+	keymap := utils.NewKeymap()
+
+	// Triage state. The queue is a snapshot ordered most severe first; it is
+	// only touched from the UI goroutine. triageVersion counts recorded
+	// dispositions, under scanMu, so the Vulnerabilities tab shows them.
+	var (
+		triageQueue   []VulnerabilityResult
+		triageIdx     int
+		triageMode    bool
+		noteMode      bool
+		triageVersion int
+	)
+	renderTriage := func() {
+		triageView.Clear()
+		if len(triageQueue) == 0 {
+			triageView.SetText("No findings to triage. Press Esc to return.")
+			return
+		}
+		v := triageQueue[triageIdx]
+		rec, ok := triage.Lookup(v)
+		if !ok {
+			rec.Disposition = "untriaged"
+		}
+		fmt.Fprintf(triageView, "[white::b]Finding %d of %d[-:-:-]\n\n", triageIdx+1, len(triageQueue))
+		fmt.Fprintf(triageView, "Severity:    %s\n", utils.FindingSeverity(v))
+		fmt.Fprintf(triageView, "Confidence:  %s\n", utils.FindingConfidence(v))
+		fmt.Fprintf(triageView, "Issue:       %s\n", tview.Escape(v.Issue))
+		fmt.Fprintf(triageView, "URL:         %s\n", tview.Escape(v.URL))
+		if v.Score > 0 {
+			fmt.Fprintf(triageView, "CVSS:        %.1f %s\n", v.Score, v.Vector)
+		}
+		if v.CWE > 0 {
+			fmt.Fprintf(triageView, "CWE:         CWE-%d\n", v.CWE)
+		}
+		fmt.Fprintf(triageView, "Disposition: [yellow::b]%s[-:-:-]\n", rec.Disposition)
+		if rec.Note != "" {
+			fmt.Fprintf(triageView, "Note:        %s\n", tview.Escape(rec.Note))
+		}
+		fmt.Fprintf(triageView, "\n[white::b]Evidence[-:-:-]\n%s\n", tview.Escape(v.Detail))
+		if t, ok := utils.LookupIssueType(v.Type); ok {
+			fmt.Fprintf(triageView, "\n[white::b]Remediation[-:-:-]\n%s\n", tview.Escape(t.Remediation))
+		}
+		fmt.Fprintf(triageView, "\n[white::b]Reproduce[-:-:-]\n%s\n", tview.Escape(reproCommand(v)))
+		fmt.Fprintf(triageView, "\n[green]%s[white] confirmed | [green]%s[white] false-positive | [green]%s[white] duplicate | [green]%s[white] needs-retest | [green]%s[white] note | [green]%s/%s[white] next/prev | [green]%s[white] leave",
+			keymap.Keys("triage.confirm"), keymap.Keys("triage.false-positive"), keymap.Keys("triage.duplicate"),
+			keymap.Keys("triage.retest"), keymap.Keys("triage.note"), keymap.Keys("triage.next"),
+			keymap.Keys("triage.prev"), keymap.Keys("triage.leave"))
+	}
+	startTriage := func() {
+		scanMu.Lock()
+		triageQueue = utils.SortBySeverity(scanResult.VulnURLs)
+		scanMu.Unlock()
+		// Resume at the first finding nobody has reviewed yet.
+		triageIdx = 0
+		for i, v := range triageQueue {
+			if _, ok := triage.Lookup(v); !ok {
+				triageIdx = i
+				break
+			}
+		}
+		triageMode = true
+		renderTriage()
+		pages.SwitchToPage("Triage")
+	}
+	recordTriage := func(disposition, note string) bool {
+		v := triageQueue[triageIdx]
+		if err := triage.Record(v, disposition, note); err != nil {
+			AppendLog("[!] Triage save error: " + err.Error())
+			return false
+		}
+		scanMu.Lock()
+		triage.Apply(scanResult.VulnURLs)
+		triageVersion++
+		scanMu.Unlock()
+		AppendLog(fmt.Sprintf("[*] Triaged %s at %s as %s", v.Issue, v.URL, disposition))
+		return true
+	}
+	// leaveTriage refreshes the run's exports once the pipeline has written
+	// them, so dispositions recorded after the scan are not lost.
+	leaveTriage := func() {
+		triageMode = false
+		pages.SwitchToPage("Vulnerabilities")
+		scanMu.Lock()
+		defer scanMu.Unlock()
+		if scanResult.Running {
+			return
+		}
+		scanResult.FinalReport = buildFinalReport(target)
+		if err := triage.WriteRun(filepath.Join(outDir, "triage.json"), scanResult.VulnURLs); err != nil {
+			AppendLog("[!] Triage export error: " + err.Error())
+		}
+		if _, err := exporters.WriteDefectDojo(scanResult, outDir); err != nil {
+			AppendLog("[!] DefectDojo export error: " + err.Error())
+		}
+		if err := saveRun(runStores, filepath.Base(outDir), scanResult); err != nil {
+			AppendLog("[!] Summary export error: " + err.Error())
+		}
+	}
+	noteInput.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			// A note on an untriaged finding marks it for retesting.
+			disposition := utils.DispositionNeedsRetest
+			if rec, ok := triage.Lookup(triageQueue[triageIdx]); ok {
+				disposition = rec.Disposition
+			}
+			recordTriage(disposition, strings.TrimSpace(noteInput.GetText()))
+		}
+		noteMode = false
+		pages.HidePage("Note")
+		app.SetFocus(pages)
+		renderTriage()
+	})
+
+	// Filter prompt state: the view being filtered and its filter before.
+	var (
+		filterMode   bool
+		filterTarget *pagedView
+		filterBefore string
+	)
+	filterInput.SetChangedFunc(func(text string) {
+		if filterTarget != nil {
+			filterTarget.Filter(text)
+		}
+	})
+	filterInput.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape && filterTarget != nil {
+			filterTarget.Filter(filterBefore)
+		}
+		filterMode, filterTarget = false, nil
+		pages.HidePage("Filter")
+		app.SetFocus(pages)
+	})
+	// frontList returns the paged view on the front page, if any.
+	frontList := func() *pagedView {
+		name, _ := pages.GetFrontPage()
+		return pagedViews[name]
+	}
+	// listMove wraps a viewport move as an action on the front paged view.
+	listMove := func(move func(port *dataset.Viewport, length int)) func() {
+		return func() {
+			if p := frontList(); p != nil {
+				p.Move(move)
+			}
+		}
+	}
+
+	// Help overlay, tutorial and validation popup state.
+	var (
+		helpMode       bool
+		tutorialMode   bool
+		tutorialStep   int
+		validationMode bool
+	)
+	var tutorialSteps []string
+	renderTutorial := func() {
+		tutorialView.SetText(fmt.Sprintf("%s\n\n[gray](%d/%d) %s next, %s dismiss for good",
+			tutorialSteps[tutorialStep], tutorialStep+1, len(tutorialSteps),
+			keymap.Keys("tutorial.next"), keymap.Keys("tutorial.dismiss")))
+	}
+	dismissTutorial := func() {
+		tutorialMode = false
+		pages.HidePage("Tutorial")
+		if marker := tutorialMarker(); marker != "" {
+			if err := os.MkdirAll(filepath.Dir(marker), 0755); err == nil {
+				_ = ioutil.WriteFile(marker, []byte(time.Now().Format(time.RFC3339)+"\n"), 0644)
+			}
+		}
+	}
+	// triageStep wraps a triage action so it is a no-op without findings.
+	triageStep := func(action func()) func() {
+		return func() {
+			if len(triageQueue) > 0 {
+				action()
+			}
+		}
+	}
+	setDisposition := func(d string) func() {
+		return triageStep(func() {
+			rec, _ := triage.Lookup(triageQueue[triageIdx])
+			if recordTriage(d, rec.Note) && triageIdx < len(triageQueue)-1 {
+				triageIdx++
+			}
+			renderTriage()
+		})
+	}
+
+	keymap.Bind("tab.subdomains", "Global", "Show the Subdomains tab", []string{"1"}, func() { pages.SwitchToPage("Subdomains") })
+	keymap.Bind("tab.vulns", "Global", "Show the Vulnerabilities tab", []string{"2"}, func() { pages.SwitchToPage("Vulnerabilities") })
+	keymap.Bind("tab.ffuf", "Global", "Show the FFUF tab", []string{"3"}, func() { pages.SwitchToPage("FFUF") })
+	keymap.Bind("tab.report", "Global", "Show the Report tab", []string{"4"}, func() { pages.SwitchToPage("Report") })
+	keymap.Bind("tab.proxy", "Global", "Show the Proxy tab", []string{"5"}, func() { pages.SwitchToPage("Proxy") })
+	keymap.Bind("tab.tls", "Global", "Show the TLS tab", []string{"6"}, func() { pages.SwitchToPage("TLS") })
+	keymap.Bind("proxy.toggle", "Global", "Toggle the HTTP proxy", []string{"p", "P"}, func() {
+		scanMu.Lock()
+		scanResult.ProxyEnabled = !scanResult.ProxyEnabled
+		enabled := scanResult.ProxyEnabled
+		scanMu.Unlock()
+		updateProxyView(enabled)
+		AppendLog(fmt.Sprintf("[*] Proxy enabled: %v", enabled))
+	})
+	keymap.Bind("help.show", "Global", "Show this help", []string{"?"}, func() {
+		helpView.SetText(tview.Escape(keymap.HelpText()))
+		helpMode = true
+		pages.ShowPage("Help")
+	})
+	keymap.Bind("list.down", "Lists", "Scroll down a row", []string{"Down"}, listMove(func(port *dataset.Viewport, n int) { port.Scroll(1, n) }))
+	keymap.Bind("list.up", "Lists", "Scroll up a row", []string{"Up"}, listMove(func(port *dataset.Viewport, n int) { port.Scroll(-1, n) }))
+	keymap.Bind("list.page-down", "Lists", "Scroll down a page", []string{"PgDn"}, listMove((*dataset.Viewport).PageDown))
+	keymap.Bind("list.page-up", "Lists", "Scroll up a page", []string{"PgUp"}, listMove((*dataset.Viewport).PageUp))
+	keymap.Bind("list.top", "Lists", "Jump to the top", []string{"Home"}, listMove(func(port *dataset.Viewport, _ int) { port.Home() }))
+	keymap.Bind("list.bottom", "Lists", "Jump to the bottom", []string{"End"}, listMove((*dataset.Viewport).End))
+	keymap.Bind("list.sort", "Lists", "Sort by the next column", []string{"s"}, func() {
+		if p := frontList(); p != nil {
+			p.CycleSort(false)
+		}
+	})
+	keymap.Bind("list.reverse", "Lists", "Reverse the order", []string{"S"}, func() {
+		if p := frontList(); p != nil {
+			p.CycleSort(true)
+		}
+	})
+	keymap.Bind("list.filter", "Lists", "Filter the rows", []string{"/"}, func() {
+		if filterTarget = frontList(); filterTarget == nil {
+			return
+		}
+		filterBefore = filterTarget.Query()
+		filterInput.SetText(filterBefore)
+		filterMode = true
+		pages.ShowPage("Filter")
+		app.SetFocus(filterInput)
+	})
+	keymap.Bind("triage.start", "Vulnerabilities", "Triage findings, most severe first", []string{"t", "T"}, startTriage)
+	keymap.Bind("triage.confirm", "Triage", "Mark confirmed", []string{"c"}, setDisposition(utils.DispositionConfirmed))
+	keymap.Bind("triage.false-positive", "Triage", "Mark false positive", []string{"f"}, setDisposition(utils.DispositionFalsePositive))
+	keymap.Bind("triage.duplicate", "Triage", "Mark duplicate", []string{"d"}, setDisposition(utils.DispositionDuplicate))
+	keymap.Bind("triage.retest", "Triage", "Mark needs retest", []string{"r"}, setDisposition(utils.DispositionNeedsRetest))
+	keymap.Bind("triage.note", "Triage", "Add or edit a note", []string{"n"}, triageStep(func() {
+		rec, _ := triage.Lookup(triageQueue[triageIdx])
+		noteInput.SetText(rec.Note)
+		noteMode = true
+		pages.ShowPage("Note")
+		app.SetFocus(noteInput)
+	}))
+	keymap.Bind("triage.next", "Triage", "Next finding", []string{"j"}, triageStep(func() {
+		if triageIdx < len(triageQueue)-1 {
+			triageIdx++
+		}
+		renderTriage()
+	}))
+	keymap.Bind("triage.prev", "Triage", "Previous finding", []string{"k"}, triageStep(func() {
+		if triageIdx > 0 {
+			triageIdx--
+		}
+		renderTriage()
+	}))
+	keymap.Bind("triage.leave", "Triage", "Leave triage", []string{"Esc"}, leaveTriage)
+	keymap.Bind("help.close", "Help", "Close this help", []string{"?", "Esc"}, func() {
+		helpMode = false
+		pages.HidePage("Help")
+	})
+	keymap.Bind("tutorial.next", "Tutorial", "Next tutorial step", []string{"Enter"}, func() {
+		if tutorialStep++; tutorialStep >= len(tutorialSteps) {
+			dismissTutorial()
+			return
+		}
+		renderTutorial()
+	})
+	keymap.Bind("tutorial.dismiss", "Tutorial", "Dismiss the tutorial", []string{"Esc"}, dismissTutorial)
+	keymap.Bind("validation.close", "Validation", "Close the validation popup", []string{"Esc", "Enter"}, func() {
+		validationMode = false
+		pages.HidePage("Validation")
+	})
+	if err := keymap.Remap(os.Getenv("KEYMAP")); err != nil {
+		AppendLog("[!] KEYMAP ignored: " + err.Error())
+	}
+
+	// With a baseline the Subdomains and Vulns tabs count what is new.
+	// Stages that panicked, and a pause for the scan window with its
+	// projected end, are flagged above the tabs.
+	renderTabMenu := func(newSubs, newVulns int, failed []string) {
+		delta := func(n int) string {
+			if baseline == nil {
+				return ""
+			}
+			return fmt.Sprintf(" [yellow](+%d new)[white]", n)
+		}
+		var alert string
+		if len(failed) > 0 {
+			alert = fmt.Sprintf("[white:red:b] STAGE FAILED: %s - crash report in %s [-:-:-]\n",
+				tview.Escape(strings.Join(failed, ", ")), filepath.Join(outDir, "debug"))
+		}
+		if p := utils.ScanWindowPause(); p != nil {
+			alert += fmt.Sprintf("[black:yellow:b] PAUSED: %s outside the scan window %s; resuming at %s (in %s) [-:-:-]\n",
+				tview.Escape(p.Stage), tview.Escape(p.Window), p.Resume.Format("Mon 15:04 MST"),
+				time.Until(p.Resume).Round(time.Minute))
+		}
+		tabMenu.SetText(alert + fmt.Sprintf("[white::b]Tabs: [green]%s[white] Subdomains%s | [green]%s[white] Vulns%s | [green]%s[white] FFUF | [green]%s[white] Report | [green]%s[white] Proxy | [green]%s[white] TLS | [green]%s[white] Triage (Vulns) | [green]%s[white] Help",
+			keymap.Keys("tab.subdomains"), delta(newSubs), keymap.Keys("tab.vulns"), delta(newVulns), keymap.Keys("tab.ffuf"), keymap.Keys("tab.report"),
+			keymap.Keys("tab.proxy"), keymap.Keys("tab.tls"), keymap.Keys("triage.start"), keymap.Keys("help.show")))
+	}
+	renderTabMenu(0, 0, nil)
+	tutorialSteps = []string{
+		"[white::b]Welcome to Recon Tool.[-:-:-] The scan runs on its own; this UI only displays its progress and results.",
+		fmt.Sprintf("Switch tabs with %s (Subdomains), %s (Vulnerabilities), %s (FFUF), %s (Report), %s (Proxy) and %s (TLS). The console at the bottom shows the live scan log.",
+			keymap.Keys("tab.subdomains"), keymap.Keys("tab.vulns"), keymap.Keys("tab.ffuf"), keymap.Keys("tab.report"), keymap.Keys("tab.proxy"), keymap.Keys("tab.tls")),
+		fmt.Sprintf("Long lists page with %s/%s and %s/%s; %s sorts them by the next column, %s reverses the order and %s filters the rows.",
+			keymap.Keys("list.page-up"), keymap.Keys("list.page-down"), keymap.Keys("list.top"), keymap.Keys("list.bottom"),
+			keymap.Keys("list.sort"), keymap.Keys("list.reverse"), keymap.Keys("list.filter")),
+		fmt.Sprintf("Press %s to route the tool's own HTTP requests through the proxy at http://127.0.0.1:8080; the Proxy tab shows its state.", keymap.Keys("proxy.toggle")),
+		fmt.Sprintf("On the Vulnerabilities tab press %s to triage findings one by one. Dispositions carry over to later runs.", keymap.Keys("triage.start")),
+		fmt.Sprintf("Press %s at any time to list every keybinding.", keymap.Keys("help.show")),
+	}
+	if marker := tutorialMarker(); marker != "" {
+		if _, err := os.Stat(marker); os.IsNotExist(err) {
+			tutorialMode = true
+			renderTutorial()
+			pages.ShowPage("Tutorial")
+		}
+	}
+
+	// Dispatch keys through the keymap. Overlays and triage capture every
+	// key; the note prompt receives them unfiltered.
+	layout.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if noteMode || filterMode {
+			return event
+		}
+		var contexts []string
+		switch {
+		case tutorialMode:
+			contexts = []string{"Tutorial"}
+		case validationMode:
+			contexts = []string{"Validation"}
+		case helpMode:
+			contexts = []string{"Help"}
+		case triageMode:
+			contexts = []string{"Triage"}
+		default:
+			contexts = []string{"Global"}
+			name, _ := pages.GetFrontPage()
+			if pagedViews[name] != nil {
+				contexts = []string{"Lists", "Global"}
+			}
+			if name == "Vulnerabilities" {
+				contexts = []string{"Vulnerabilities", "Lists", "Global"}
+			}
+		}
+		if keymap.Dispatch(contexts, keyName(event)) || tutorialMode || validationMode || helpMode || triageMode {
+			return nil
+		}
+		return event
+	})
+
+	// Periodically update the views with scan data, while the scan runs
+	// too, so new rows and their baseline markers show up as they come in.
+	// The paged views are rebuilt only when their rows changed, since that
+	// re-sorts them; the end of a scan always counts as a change.
+	go func() {
+		var (
+			subsShown, vulnsShown, ffufShown string
+			newSubs, newVulns                int
+			scans                            int
+			running                          bool
+			failed                           []string
+			failedShown                      string
+			pauseShown                       string
+			invalidShown                     int
+		)
+		for {
+			// A stage that panicked is flagged while the scan still runs;
+			// one whose output failed validation pops up with its messages.
+			var invalid []string
+			scanMu.Lock()
+			failed = failed[:0]
+			for _, st := range scanResult.Stages {
+				switch st.Status {
+				case "failed":
+					failed = append(failed, st.Stage)
+				case "failed-validation":
+					invalid = append(invalid, fmt.Sprintf("[red::b]%s[-:-:-]\n  %s", tview.Escape(st.Stage), tview.Escape(strings.Join(st.Messages, "\n  "))))
+				}
+			}
+			scanMu.Unlock()
+			if len(invalid) > invalidShown {
+				invalidShown = len(invalid)
+				text := strings.Join(invalid, "\n\n")
+				if failFast {
+					text += "\n\n[yellow]--fail-fast: the remaining stages are skipped.[-]"
+				}
+				app.QueueUpdateDraw(func() {
+					validationView.SetText(text)
+					validationMode = true
+					pages.ShowPage("Validation")
+				})
+			}
+			pause := ""
+			if p := utils.ScanWindowPause(); p != nil {
+				pause = p.String() + time.Until(p.Resume).Round(time.Minute).String()
+			}
+			if shown := strings.Join(failed, "\n"); shown != failedShown || pause != pauseShown {
+				failedShown, pauseShown = shown, pause
+				renderTabMenu(newSubs, newVulns, failed)
+			}
+			if scanResult.Running {
+				if !running {
+					scans++
+				}
+				running = true
+			} else {
+				running = false
+			}
+			// Snapshot the rows under scanMu; sorting them for the views
+			// happens after.
+			var updates []func()
+			scanMu.Lock()
+			if shown := fmt.Sprint(scans, running, len(scanResult.Subdomains), len(scanResult.LiveHosts)); shown != subsShown {
+				subsShown = shown
+				d, render, n := subdomainRows()
+				newSubs = n
+				updates = append(updates, func() { subdomainsView.SetData(d, render) })
+			}
+			if shown := fmt.Sprint(scans, running, len(scanResult.VulnURLs), triageVersion); shown != vulnsShown {
+				vulnsShown = shown
+				d, render, n := vulnRows()
+				newVulns = n
+				updates = append(updates, func() { vulnsView.SetData(d, render) })
+			}
+			if shown := fmt.Sprint(scans, running, len(scanResult.FfufEntries)); shown != ffufShown {
+				ffufShown = shown
+				d, render := ffufRows()
+				updates = append(updates, func() { ffufView.SetData(d, render) })
+			}
+			if !running {
+				// Update TLS view.
+				tlsView.Clear()
+				for _, t := range scanResult.TLS {
+					color := "yellow"
+					if t.Severity != "MEDIUM" {
+						color = "red"
+					}
+					fmt.Fprintf(tlsView, "[%s::b]%-8s[-:-:-] %s %s: %s\n", color, t.Severity, t.Host, t.ID, tview.Escape(t.Finding))
+				}
+				// Update report view.
+				reportView.SetText(scanResult.FinalReport)
+			}
+			if baseline != nil && len(updates) > 0 {
+				renderTabMenu(newSubs, newVulns, failed)
+			}
+			scanMu.Unlock()
+			for _, update := range updates {
+				update()
+			}
+			time.Sleep(2 * time.Second)
+		}
+	}()
+
+	// Update console view continuously.
+	go func() {
+		for {
+			consoleView.Clear()
+			for _, line := range logger.Lines() {
+				// Colorize lines containing 'vulnerable' or 'error'
+				if strings.Contains(strings.ToLower(line), "vulnerable") || strings.Contains(strings.ToLower(line), "error") {
+					fmt.Fprintf(consoleView, "[red::b]%s[-:-:-]\n", line)
+				} else {
+					fmt.Fprintln(consoleView, line)
+				}
+			}
+			time.Sleep(1 * time.Second)
+		}
+	}()
+
+	if err := app.SetRoot(layout, true).EnableMouse(true).Run(); err != nil {
+		panic(err)
+	}
+}
+
+// ---------- Subcommands ----------
+
+// runReplay implements `recon replay <rundir> [--verify|--plan-only]`: it
+// checks a previous run's commands.jsonl against the current environment.
+// A plan-only replay writes replay_report.md into the run directory; a
+// verify replay re-executes the commands in a fresh replay_<timestamp>
+// directory inside it, with the report, and leaves the run's own artifacts
+// untouched.
+func runReplay(runDir string, verify, planOnly bool) {
+	if abs, err := filepath.Abs(runDir); err == nil {
+		runDir = abs
+	}
+	execute := verify && !planOnly
+	records, err := utils.LoadCommandRecords(filepath.Join(runDir, "commands.jsonl"))
+	if err != nil {
+		fmt.Println("Failed to load command manifest:", err)
+		return
+	}
+	reportDir, replayDir := runDir, ""
+	if execute {
+		replayDir = filepath.Join(runDir, "replay_"+time.Now().Format("20060102_150405"))
+		if err := os.Mkdir(replayDir, 0755); err != nil {
+			fmt.Println("Failed to create replay directory:", err)
+			return
+		}
+		reportDir = replayDir
+		fmt.Println("Re-executing into", replayDir)
+	}
+	var results []utils.ReplayResult
+	for _, rec := range records {
+		res := utils.ReplayCommand(rec, runDir, replayDir)
+		fmt.Printf("[%s] %s %s\n", res.Status, rec.Tool, strings.Join(rec.Args, " "))
+		results = append(results, res)
+	}
+	reportPath := filepath.Join(reportDir, "replay_report.md")
+	if err := utils.WriteReplayReport(reportPath, results, execute); err != nil {
+		fmt.Println("Failed to write replay report:", err)
+		return
+	}
+	fmt.Println("Replay report written to", reportPath)
+}
+
+// runKBShow implements `recon kb show <target>`: it summarizes a target's
+// knowledge base without scanning.
+func runKBShow(target string) {
+	kb, err := utils.OpenKnowledgeBase(target)
+	if err != nil {
+		fmt.Println("Failed to open knowledge base:", err)
+		return
+	}
+	fmt.Printf("Knowledge base for %s: %s\n", kb.Target, kb.Dir)
+	runs, err := kb.Runs()
+	if err != nil {
+		fmt.Println("Failed to read runs:", err)
+	}
+	fmt.Printf("Runs: %d\n", len(runs))
+	// The most recent runs, newest first.
+	for i := len(runs) - 1; i >= 0 && i >= len(runs)-5; i-- {
+		r := runs[i]
+		fmt.Printf("  %s  %d subdomain(s), %d URL(s), %d finding(s)  %s\n",
+			r.Started.Format("2006-01-02 15:04"), r.Subdomains, r.URLs, r.Findings, r.Dir)
+	}
+	triageStore, err := utils.LoadTriageStore(kb.Path(utils.KBTriage))
+	if err != nil {
+		fmt.Println("Failed to read triage store:", err)
+		return
+	}
+	counts := triageStore.Counts()
+	var parts []string
+	total := 0
+	for _, d := range []string{utils.DispositionConfirmed, utils.DispositionFalsePositive, utils.DispositionDuplicate, utils.DispositionNeedsRetest} {
+		if counts[d] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[d], d))
+			total += counts[d]
+		}
+	}
+	fmt.Printf("Triage: %d disposition(s)", total)
+	if len(parts) > 0 {
+		fmt.Print(": " + strings.Join(parts, ", "))
+	}
+	fmt.Println()
+}
+
+// runKBExport implements `recon kb export [-o file.tar.gz] <target>`, which
+// packs a target's knowledge base for another machine.
+func runKBExport(target, dest string) {
+	kb, err := utils.OpenKnowledgeBase(target)
+	if err != nil {
+		fmt.Println("Failed to open knowledge base:", err)
+		return
+	}
+	if dest == "" {
+		dest = kb.Target + "_kb.tar.gz"
+	}
+	stores, err := kb.Export(dest)
+	if err != nil {
+		fmt.Println("Failed to export knowledge base:", err)
+		return
+	}
+	for _, s := range stores {
+		fmt.Println("  " + s)
+	}
+	fmt.Println("Knowledge base exported to", dest)
+}
+
+// runKBImport implements `recon kb import <file.tar.gz>`, merging an
+// exported knowledge base into this machine's.
+func runKBImport(file string) {
+	kb, stores, err := utils.ImportKnowledgeBase(file)
+	if err != nil {
+		fmt.Println("Failed to import knowledge base:", err)
+		return
+	}
+	for _, s := range stores {
+		fmt.Println("  merged " + s)
+	}
+	fmt.Printf("Knowledge base for %s imported into %s\n", kb.Target, kb.Dir)
+}
+
+// runDebugBundle implements `recon debug-bundle [-o file] <rundir>`: it
+// packages the run's debug snapshots, scan log, command manifest and summary
+// into one archive for bug reports, with secret values from the environment
+// redacted.
+func runDebugBundle(runDir, dest string) {
+	if dest == "" {
+		dest = filepath.Join(runDir, "debug_bundle.tar.gz")
+	}
+	names, err := utils.WriteDebugBundle(runDir, dest, utils.SecretValues())
+	if err != nil {
+		fmt.Println("Failed to write debug bundle:", err)
+		return
+	}
+	for _, name := range names {
+		fmt.Println("  " + name)
+	}
+	fmt.Println("Debug bundle written to", dest)
+}
+
+// runImportNessus merges the results of a Nessus scan of the exported
+// targets into a finished run: summary.json, vulnerabilities.json, the
+// DefectDojo export and the report.
+func runImportNessus(runDir, nessusFile string, withInfo bool) {
+	result, rec, err := utils.LoadSummary(runDir)
+	if err != nil {
+		fmt.Println("Failed to read run summary:", err)
+		return
+	}
+	if rec != nil {
+		fmt.Print(rec)
+		// The summary is rewritten below; keep the damaged one.
+		if err := os.Rename(rec.File, rec.File+".damaged"); err != nil {
+			fmt.Println("Failed to set the damaged summary aside:", err)
+			return
+		}
+	}
+	data, err := ioutil.ReadFile(nessusFile)
+	if err != nil {
+		fmt.Println("Failed to read Nessus results:", err)
+		return
+	}
+	hosts, err := parsers.ParseNessus(data)
+	if err != nil {
+		fmt.Println("Failed to parse Nessus results:", err)
+		return
+	}
+	rules, err := utils.TagRulesFromEnv()
+	if err != nil {
+		fmt.Println("Invalid tag rules:", err)
+		return
+	}
+	vulns, unmatched := exporters.ImportNessus(hosts, result, withInfo)
+	have := make(map[string]bool, len(result.VulnURLs))
+	for _, v := range result.VulnURLs {
+		have[utils.FindingFingerprint(v)] = true
+	}
+	added := 0
+	for _, v := range vulns {
+		if fp := utils.FindingFingerprint(v); !have[fp] {
+			have[fp] = true
+			result.VulnURLs = append(result.VulnURLs, v)
+			added++
+		}
+	}
+	utils.TagAssets(&result, rules)
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("\nImported from Nessus (%s): %d finding(s) on %d host(s)\n", filepath.Base(nessusFile), added, len(hosts)))
+	for _, h := range unmatched {
+		b.WriteString("    ? " + h + " (not found by this run)\n")
+	}
+	result.FinalReport += b.String()
+	stores, err := openStores(filepath.Dir(filepath.Clean(runDir)))
+	if err != nil {
+		fmt.Println("Failed to open the run stores:", err)
+		return
+	}
+	defer closeStores(stores)
+	if err := saveRun(stores, filepath.Base(filepath.Clean(runDir)), result); err != nil {
+		fmt.Println("Failed to save the run:", err)
+		return
+	}
+	out, _ := json.MarshalIndent(result.VulnURLs, "", "  ")
+	if err := ioutil.WriteFile(filepath.Join(runDir, "vulnerabilities.json"), out, 0644); err != nil {
+		fmt.Println("Failed to write vulnerabilities.json:", err)
+	}
+	if _, err := exporters.WriteDefectDojo(result, runDir); err != nil {
+		fmt.Println("DefectDojo export error:", err)
+	}
+	fmt.Printf("Imported %d Nessus finding(s) into %s\n", added, runDir)
+	if len(unmatched) > 0 {
+		fmt.Printf("%d host(s) not found by this run, tagged state:unmatched: %s\n", len(unmatched), strings.Join(unmatched, ", "))
 	}
-	return b
 }
 
-// ---------- TUI Implementation using tview ----------
+// reparsedOutputs are the raw tool outputs a run keeps under fixed names,
+// with the parser that turns each into findings.
+var reparsedOutputs = []struct {
+	file  string
+	parse func(data []byte) ([]VulnerabilityResult, error)
+}{
+	{"nuclei.jsonl", func(data []byte) ([]VulnerabilityResult, error) { return parsers.ParseNucleiOutput(string(data)) }},
+	{"dalfox.json", parsers.ParseDalfoxJSON},
+	{"corsy.json", parsers.ParseCorsyOutput},
+	{"subjack.json", parsers.ParseSubjackJSON},
+}
 
-func startTUI(outDir, target string) {
-	app := tview.NewApplication()
+// runReprocess implements `recon reprocess [--baseline rundir] <rundir>`
+// for air-gapped analysis of collected data. It runs in offline mode, so
+// the network guards refuse any dial, and writes its results to a fresh
+// reprocess_<timestamp> directory inside the run.
+func runReprocess(runDir string) {
+	offlineMode = true
+	outDir := filepath.Join(runDir, "reprocess_"+time.Now().Format("20060102_150405"))
+	if err := os.Mkdir(outDir, 0755); err != nil {
+		fmt.Println("Failed to create output directory:", err)
+		return
+	}
+	if err := reprocessRun(runDir, outDir); err != nil {
+		fmt.Println("Reprocessing failed:", err)
+		return
+	}
+	fmt.Println("Reprocessed results written to", outDir)
+}
 
-	// Console log view (75% height)
-	consoleView := tview.NewTextView().SetDynamicColors(true).
-		SetWrap(true).SetChangedFunc(func() { app.Draw() })
-	consoleView.SetBorder(true).SetTitle("Console Output")
+// reprocessRun loads the run in runDir, re-parses the tool outputs it kept
+// with the current parsers, re-classifies every finding (taxonomy, CVSS,
+// asset tags), rebuilds the report with the diff against baselineDir when
+// set, and writes summary.json, vulnerabilities.json and the DefectDojo
+// export to outDir. Nothing in runDir is modified.
+func reprocessRun(runDir, outDir string) error {
+	result, rec, err := utils.LoadSummary(runDir)
+	if err != nil {
+		return err
+	}
+	rules, err := utils.TagRulesFromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid tag rules: %v", err)
+	}
+	if baselineDir != "" {
+		if baseline, err = utils.LoadBaseline(baselineDir); err != nil {
+			return err
+		}
+	}
 
-	// Tab views for Subdomains, Vulnerabilities, FFUF results, and Final Report.
-	subdomainsView := tview.NewTextView().SetDynamicColors(true)
-	subdomainsView.SetBorder(true).SetTitle("Subdomains")
-	vulnsView := tview.NewTextView().SetDynamicColors(true)
-	vulnsView.SetBorder(true).SetTitle("Vulnerable URLs")
-	ffufView := tview.NewTextView().SetDynamicColors(true)
-	ffufView.SetBorder(true).SetTitle("FFUF Results")
-	reportView := tview.NewTextView().SetDynamicColors(true)
-	reportView.SetBorder(true).SetTitle("Final Report")
-	// Proxy status view.
-	proxyView := tview.NewTextView().SetDynamicColors(true)
-	proxyView.SetBorder(true).SetTitle("Proxy Status")
-	updateProxyView := func(enabled bool) {
-		if enabled {
-			proxyView.SetText("[green::b]Proxy Active: http://127.0.0.1:8080")
-		} else {
-			proxyView.SetText("[red::b]Proxy Disabled")
+	have := make(map[string]bool, len(result.VulnURLs))
+	for _, v := range result.VulnURLs {
+		have[utils.FindingFingerprint(v)] = true
+	}
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("\nReprocessed offline from %s:\n", runDir))
+	recoveries := []*utils.SummaryRecovery{rec}
+	if baseline != nil {
+		recoveries = append(recoveries, baseline.Recovery)
+	}
+	for _, r := range recoveries {
+		if r == nil {
+			continue
+		}
+		fmt.Print(r)
+		for _, line := range r.Lines() {
+			b.WriteString("    ! " + line + "\n")
 		}
 	}
-	updateProxyView(false)
+	for _, o := range reparsedOutputs {
+		data, err := ioutil.ReadFile(filepath.Join(runDir, o.file))
+		if err != nil {
+			continue
+		}
+		vulns, err := o.parse(data)
+		if err != nil {
+			b.WriteString(fmt.Sprintf("    ! %s: %v\n", o.file, err))
+		}
+		added := 0
+		for _, v := range vulns {
+			if fp := utils.FindingFingerprint(v); !have[fp] {
+				have[fp] = true
+				result.VulnURLs = append(result.VulnURLs, v)
+				added++
+			}
+		}
+		b.WriteString(fmt.Sprintf("  %-14s %d finding(s), %d not in the summary\n", o.file, len(vulns), added))
+	}
+	// Raw outputs predate the post-filter; hold them to the run's scope.
+	if runScope, err := utils.ScopeFromFiles(runDir); err == nil {
+		var dropped map[string]int
+		result.VulnURLs, dropped = runScope.FilterFindings(result.VulnURLs)
+		if msg := utils.DropSummary(dropped); msg != "" {
+			b.WriteString("    ! " + msg + "\n")
+		}
+	}
+	var unclassified []string
+	result.VulnURLs, unclassified = utils.IngestFindings(result.VulnURLs)
+	for _, msg := range unclassified {
+		b.WriteString("    ! " + msg + "\n")
+	}
+	utils.TagAssets(&result, rules)
+	for i := range result.VulnURLs {
+		utils.ApplyCVSS(&result.VulnURLs[i])
+	}
 
-	// Pages for switching between tabs.
-	pages := tview.NewPages()
-	pages.AddPage("Subdomains", subdomainsView, true, true)
-	pages.AddPage("Vulnerabilities", vulnsView, true, false)
-	pages.AddPage("FFUF", ffufView, true, false)
-	pages.AddPage("Report", reportView, true, false)
-	pages.AddPage("Proxy", proxyView, true, false)
+	// The reprocessed run is saved next to the run, which names the target.
+	root := filepath.Dir(filepath.Clean(runDir))
+	runID, err := filepath.Rel(root, outDir)
+	if err != nil {
+		return err
+	}
+	runID = filepath.ToSlash(runID)
+	target := store.RunTarget(runID)
+	scanMu.Lock()
+	scanResult = result
+	scanResult.FinalReport = buildFinalReport(target) + b.String()
+	result = scanResult
+	scanMu.Unlock()
 
-	// Tab menu at the top.
-	tabMenu := tview.NewTextView().SetDynamicColors(true)
-	tabMenu.SetText("[white::b]Tabs: [green]1[white] Subdomains | [green]2[white] Vulns | [green]3[white] FFUF | [green]4[white] Report | [green]5[white] Proxy")
-	tabMenu.SetTextAlign(tview.AlignCenter)
+	stores, err := openStores(root)
+	if err != nil {
+		return err
+	}
+	defer closeStores(stores)
+	if err := saveRun(stores, runID, result); err != nil {
+		return err
+	}
+	out, _ := json.MarshalIndent(result.VulnURLs, "", "  ")
+	if err := ioutil.WriteFile(filepath.Join(outDir, "vulnerabilities.json"), out, 0644); err != nil {
+		return err
+	}
+	_, err = exporters.WriteDefectDojo(result, outDir)
+	return err
+}
 
-	// Layout: tab menu on top, pages in center, console at bottom.
-	layout := tview.NewFlex().SetDirection(tview.FlexRow).
-		AddItem(tabMenu, 3, 1, false).
-		AddItem(pages, 0, 3, true).
-		AddItem(consoleView, 0, 1, false)
-	layout.SetBorder(true).SetTitle(" Recon Tool ").SetTitleAlign(tview.AlignCenter)
+// openStores returns the stores runs under root are saved to: their run
+// directories and, with --postgres or POSTGRES_DSN set, Postgres.
+func openStores(root string) ([]store.Store, error) {
+	stores := []store.Store{store.NewFileStore(root)}
+	dsn := configuredDSN()
+	if dsn == "" {
+		return stores, nil
+	}
+	pg, err := store.OpenPostgres(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return append(stores, pg), nil
+}
 
-	// Keybindings for tab switching and proxy toggle.
-	layout.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		switch event.Rune() {
-		case '1':
-			pages.SwitchToPage("Subdomains")
-		case '2':
-			pages.SwitchToPage("Vulnerabilities")
-		case '3':
-			pages.SwitchToPage("FFUF")
-		case '4':
-			pages.SwitchToPage("Report")
-		case '5':
-			pages.SwitchToPage("Proxy")
-		case 'p', 'P':
-			// Toggle proxy status.
-			scanMu.Lock()
-			scanResult.ProxyEnabled = !scanResult.ProxyEnabled
-			scanMu.Unlock()
-			updateProxyView(scanResult.ProxyEnabled)
-			AppendLog(fmt.Sprintf("[*] Proxy enabled: %v", scanResult.ProxyEnabled))
-		}
-		return event
-	})
+// configuredDSN returns the Postgres connection string: --postgres, or else
+// POSTGRES_DSN.
+func configuredDSN() string {
+	if postgresDSN != "" {
+		return postgresDSN
+	}
+	return os.Getenv("POSTGRES_DSN")
+}
 
-	// Periodically update the views with scan data.
-	go func() {
-		for {
-			if !scanResult.Running {
-				// Update subdomains view.
-				subdomainsView.Clear()
-				scanMu.Lock()
-				for _, sub := range scanResult.Subdomains {
-					fmt.Fprintf(subdomainsView, "%s - IP: %s | Ports: %v\n", sub.Hostname, sub.IP, sub.Ports)
-				}
-				// Update vulnerabilities view.
-				vulnsView.Clear()
-				for _, v := range scanResult.VulnURLs {
-					fmt.Fprintf(vulnsView, "[yellow::b]%s[-:-:-]: %s\n", v.Issue, v.URL)
-				}
-				// Update FFUF view.
-				ffufView.Clear()
-				for _, f := range scanResult.FfufEntries {
-					fmt.Fprintf(ffufView, "%s (Status: %d, Size: %d)\n", f.Path, f.Status, f.Size)
-				}
-				// Update report view.
-				reportView.SetText(scanResult.FinalReport)
-				scanMu.Unlock()
-			}
-			time.Sleep(2 * time.Second)
+// pageHygieneSection counts the mixed content and insecure form findings
+// per host; it is empty when there are none.
+func pageHygieneSection(vulns []VulnerabilityResult) string {
+	counts := scanners.MixedContentCounts(vulns)
+	if len(counts) == 0 {
+		return ""
+	}
+	hosts := make([]string, 0, len(counts))
+	for h := range counts {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	var b strings.Builder
+	b.WriteString("\nPage hygiene (HTTPS pages loading or posting over HTTP):\n")
+	for _, h := range hosts {
+		b.WriteString(fmt.Sprintf("  %s: %d mixed content element(s), %d insecure form(s)\n", h, counts[h]["mixed-content"], counts[h]["insecure-form-action"]))
+	}
+	return b.String()
+}
+
+// notifyRoutes posts the findings each of the scan definition's notify
+// routes asks for to its webhook. A route without matching findings is
+// not notified.
+func notifyRoutes(target, run string) {
+	client, err := newHTTPClient(scanResult.ProxyEnabled)
+	if err != nil {
+		AppendLog("[!] Notification error: " + err.Error())
+		return
+	}
+	for i, route := range scanDefinition.Notify {
+		vulns := utils.NotifyFindings(route, scanResult.VulnURLs)
+		if len(vulns) == 0 {
+			continue
 		}
-	}()
+		if err := exporters.PostNotification(client, route.Webhook, exporters.BuildNotification(target, run, vulns)); err != nil {
+			AppendLog(fmt.Sprintf("[!] Notification %d error: %v", i+1, err))
+			continue
+		}
+		AppendLog(fmt.Sprintf("[*] Notified webhook %d of %d finding(s).", i+1, len(vulns)))
+	}
+}
 
-	// Update console view continuously.
-	go func() {
-		for {
-			consoleView.Clear()
-			scanMu.Lock()
-			for _, line := range scanResult.LogLines {
-				// Colorize lines containing 'vulnerable' or 'error'
-				if strings.Contains(strings.ToLower(line), "vulnerable") || strings.Contains(strings.ToLower(line), "error") {
-					fmt.Fprintf(consoleView, "[red::b]%s[-:-:-]\n", line)
-				} else {
-					fmt.Fprintln(consoleView, line)
-				}
-			}
-			scanMu.Unlock()
-			time.Sleep(1 * time.Second)
+// saveRun saves result as the run id to every store, the run directory
+// first; a store failing does not keep the run from the others.
+func saveRun(stores []store.Store, id string, result ScanResult) error {
+	var errs []string
+	for _, st := range stores {
+		if err := st.SaveRun(id, store.RunTarget(id), result); err != nil {
+			errs = append(errs, err.Error())
 		}
-	}()
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
 
-	if err := app.SetRoot(layout, true).EnableMouse(true).Run(); err != nil {
-		panic(err)
+func closeStores(stores []store.Store) {
+	for _, st := range stores {
+		st.Close()
 	}
 }
 
 // ---------- Main Pipeline ----------
 
 func main() {
-	// Load .env variables.
-	godotenv.Load()
+	root := newRootCmd()
+	root.SetArgs(compatArgs(root, os.Args[1:]))
+	if err := root.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
 
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: recon <target-domain>")
+// runScan runs the pipeline against target, with the options the command
+// line set.
+func runScan(target string) {
+	if noTUI {
+		logger.Echo(os.Stdout)
+	}
+	utils.IncludeInternal(includePrivate)
+	utils.IncludeParked(includeParked)
+	hostTimings.Reset()
+	for _, d := range []*string{&waybackFrom, &waybackTo} {
+		if *d == "" {
+			continue
+		}
+		ts, err := scanners.CDXDate(*d)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		*d = ts
+	}
+	if n, err := strconv.Atoi(os.Getenv("MAX_LINE_BYTES")); err == nil && n > 0 {
+		utils.MaxLineBytes = n
+	}
+	started := time.Now()
+	timestamp := started.Format("20060102_150405")
+	if err := os.MkdirAll(outRoot, 0755); err != nil {
+		fmt.Println("Failed to create output directory:", err)
 		return
 	}
-	target := os.Args[1]
-	timestamp := time.Now().Format("20060102_150405")
-	outDir := filepath.Join(".", target+"_"+timestamp)
+	outDir := filepath.Join(outRoot, target+"_"+timestamp)
 	if err := os.Mkdir(outDir, 0755); err != nil {
 		fmt.Println("Failed to create output directory:", err)
 		return
 	}
+	// Tools run in their own working directories, so output paths must be absolute.
+	if abs, err := filepath.Abs(outDir); err == nil {
+		outDir = abs
+	}
+	// Materialize the scope so tools and operators can consume it. Hosts
+	// the tag rules give a SCOPE_DENY_TAGS tag are out of scope too.
+	rules, err := utils.TagRulesFromEnv()
+	if err != nil {
+		fmt.Println("Invalid tag rules:", err)
+		return
+	}
+	tagRules = rules
+	// Runs are saved to their directory, and to Postgres when configured.
+	if runStores, err = openStores(filepath.Dir(outDir)); err != nil {
+		fmt.Println("Failed to open the run stores:", err)
+		return
+	}
+	defer closeStores(runStores)
+	scope = utils.ScopeFromEnv(target)
+	scope.TagRules = tagRules
+	if err := scope.WriteFiles(outDir); err != nil {
+		fmt.Println("Failed to write scope files:", err)
+		return
+	}
+	// Mirror the scan log to disk.
+	if err := logger.SetOutput(filepath.Join(outDir, "scan.log")); err != nil {
+		fmt.Println("Failed to open scan log:", err)
+		return
+	}
+	// Stage, count and finding events for recon watch.
+	if runEvents, err = utils.OpenEventLog(filepath.Join(outDir, "events.jsonl")); err != nil {
+		fmt.Println("Failed to open event log:", err)
+		return
+	}
+	defer runEvents.Close()
+	emittedFindings = make(map[string]bool)
+	// Troubleshooting aids; nothing listens unless --debug is set.
+	stopDebug := func() {}
+	if debugMode {
+		debugDir := filepath.Join(outDir, "debug")
+		if err := os.MkdirAll(debugDir, 0755); err != nil {
+			fmt.Println("Failed to create debug directory:", err)
+			return
+		}
+		addr := os.Getenv("DEBUG_ADDR")
+		if addr == "" {
+			addr = "127.0.0.1:6060"
+		}
+		ln, err := utils.StartPprof(addr)
+		if err != nil {
+			fmt.Println("Failed to start pprof listener:", err)
+			return
+		}
+		AppendLog("[*] Debug mode: pprof listening on http://" + ln.Addr().String() + "/debug/pprof/")
+		monitor := &utils.DebugMonitor{Dir: debugDir, Thresholds: utils.DebugThresholdsFromEnv(), Logger: logger}
+		stop := make(chan struct{})
+		go monitor.Run(5*time.Second, stop, AppendLog)
+		stopDebug = func() { close(stop) }
+	}
+	// Failure injection for resilience testing; --chaos exists only in
+	// builds tagged chaos.
+	if chaosSpec != "" {
+		cfg, err := utils.ParseChaosSpec(chaosSpec)
+		if err != nil {
+			fmt.Println("Invalid chaos spec:", err)
+			return
+		}
+		utils.EnableChaos(cfg)
+		AppendLog("[!] Chaos mode: injecting failures (" + cfg.String() + ")")
+	}
+	// Isolate external tools: per-tool working dirs, sanitized env, temporary HOME.
+	sandbox, err := utils.SetupToolSandbox(outDir, strings.Split(os.Getenv("TOOL_ENV_ALLOW"), ","))
+	if err != nil {
+		fmt.Println("Failed to set up tool directories:", err)
+		return
+	}
+	// Route target traffic through the SOCKS5 bastion. Split-horizon DNS is
+	// common internally, so where names resolve must be chosen explicitly.
+	if socksAddr != "" {
+		if socksDNS != "local" && socksDNS != "remote" {
+			fmt.Println("--socks5 requires --socks-dns local or --socks-dns remote")
+			return
+		}
+		if socks, err = utils.ParseSOCKS5(socksAddr, socksDNS == "remote"); err != nil {
+			fmt.Println("Invalid SOCKS5 proxy:", err)
+			return
+		}
+		scanners.DialContext = socks.DialContext
+		scanners.HTTP3Allowed = false
+		AppendLog(fmt.Sprintf("[*] SOCKS5 mode: routing target traffic through %s (DNS: %s)", socks.Addr, socksDNS))
+		if disabled := utils.EnableSOCKS(socks); len(disabled) > 0 {
+			AppendLog("[!] SOCKS5 mode: disabled tools without SOCKS support: " + strings.Join(disabled, ", "))
+		}
+	}
+	// Dispositions and the run index live in the target's knowledge base so
+	// they carry over between runs.
+	if kb, err = utils.OpenKnowledgeBase(target); err != nil {
+		fmt.Println("Failed to open knowledge base:", err)
+		return
+	}
+	if triageFile == "" {
+		triageFile = kb.Path(utils.KBTriage)
+	}
+	if triage, err = utils.LoadTriageStore(triageFile); err != nil {
+		fmt.Println("Failed to load triage store:", err)
+		return
+	}
+	// What an earlier run already found is not marked new.
+	if baselineDir != "" {
+		if baseline, err = utils.LoadBaseline(baselineDir); err != nil {
+			fmt.Println("Failed to load baseline:", err)
+			return
+		}
+		if baseline.Recovery != nil {
+			for _, line := range baseline.Recovery.Lines() {
+				AppendLog("[!] Baseline " + line)
+			}
+		}
+		hosts, urls, findings := baseline.Size()
+		AppendLog(fmt.Sprintf("[*] Baseline %s: %d subdomain(s), %d URL(s), %d finding(s)", baselineDir, hosts, urls, findings))
+	}
+
+	// Aggressive stages need a recorded authorization: the AUTH_* settings or
+	// a typed confirmation of the target. Anything else runs passively.
+	auth, authorized, err := utils.AuthorizationFromEnv()
+	if err != nil {
+		fmt.Println("Invalid authorization settings:", err)
+		return
+	}
+	auth.ConfirmedAt = time.Now()
+	if !authorized && iAmAuthorized {
+		fmt.Printf("Type the target (%s) to confirm you are authorized to actively scan it: ", target)
+		if utils.ConfirmTarget(os.Stdin, target) {
+			auth = Authorization{Source: "interactive", ConfirmedAt: time.Now()}
+			authorized = true
+		} else {
+			fmt.Println("Confirmation did not match the target.")
+		}
+	}
+	var authorization *Authorization
+	if authorized {
+		if current, why := utils.AuthorizationCurrent(auth, time.Now()); !current {
+			auth.Downgraded = why
+		}
+		authorization = &auth
+	}
+	passiveOnly = authorization == nil || authorization.Downgraded != ""
+	if scanWindow, err = utils.ScanWindowFromEnv(); err != nil {
+		fmt.Println("Invalid scan window:", err)
+		return
+	}
+	// Show what is about to happen. At a terminal the run waits for a
+	// go-ahead unless --yes is set; headless runs print it and carry on.
+	preflight := buildPreflight(target, authorization).Render()
+	fmt.Print(preflight)
+	if !assumeYes && utils.IsTerminal(os.Stdin) && utils.IsTerminal(os.Stdout) {
+		fmt.Print("Proceed? [y/N]: ")
+		if !utils.ConfirmProceed(os.Stdin) {
+			fmt.Println("Aborted; nothing was sent to the target.")
+			stopDebug()
+			sandbox.Cleanup()
+			os.RemoveAll(outDir)
+			return
+		}
+	}
 
 	// Initialize global scan state.
 	scanMu.Lock()
-	scanResult = ScanResult{Running: true, LogLines: []string{}, ProxyEnabled: false}
+	scanResult = ScanResult{Running: true, LogLines: []string{}, ProxyEnabled: false, Authorization: authorization, Preflight: preflight, ScanDefinition: recordedDefinition}
 	scanMu.Unlock()
 
 	// Run scanning pipeline concurrently.
@@ -545,32 +3237,289 @@ func main() {
 	go func() {
 		defer wg.Done()
 		AppendLog("========== Starting Scan ==========")
+		if offlineMode {
+			AppendLog("[*] Offline mode enabled: network access is disabled for this run.")
+		}
+		if passiveOnly {
+			AppendLog("[!] ==================================================================")
+			AppendLog("[!] PASSIVE MODE: " + utils.FormatAuthorization(authorization))
+			AppendLog("[!] Aggressive stages will be skipped.")
+			AppendLog("[!] ==================================================================")
+		} else {
+			AppendLog("[*] Authorization: " + utils.FormatAuthorization(authorization))
+			if scanWindow != nil {
+				AppendLog("[*] Aggressive stages only run within the scan window " + scanWindow.String())
+			}
+		}
+		AppendLog("[*] Tool limits: " + utils.ToolLimitsFor("").Describe(utils.DetectLimitCapabilities()))
 		// Subdomain enumeration using assetfinder and amass.
-		EnumerateSubdomains(target, os.Getenv("PDCHAOS_KEY"), outDir)
+		runStage("subdomain enumeration", outDir, true, func() {
+			EnumerateSubdomains(target, os.Getenv("PDCHAOS_KEY"), outDir)
+		}, utils.ValidateEnumeration(target))
 		// Live host checking.
-		CheckLiveHosts(outDir)
-		// URL scanning using hakrawler, gau, and waybackurls.
-		RunURLScan(target, outDir)
+		runStage("live host checking", outDir, true, func() {
+			CheckLiveHosts(outDir)
+		}, nil)
+		// Dangling DNS records that can be claimed.
+		runStage("takeover checks", outDir, true, func() {
+			client, _ := newHTTPClient(scanResult.ProxyEnabled)
+			resolver := newResolver()
+			tv := &scanners.TakeoverVerifier{Client: client, LookupTXT: resolver.LookupTXT, LookupCNAME: resolver.LookupCNAME}
+			scanners.RunTakeoverChecks(outDir, &scanResult, tv, AppendLog)
+		}, nil)
+		// Built-in takeover fingerprints and dangling CNAMEs, without tools.
+		runStage("takeover fingerprint checks", outDir, true, func() {
+			client, _ := newHTTPClient(scanResult.ProxyEnabled)
+			resolver := newResolver()
+			tv := &scanners.TakeoverVerifier{Client: client, LookupTXT: resolver.LookupTXT, LookupCNAME: resolver.LookupCNAME, LookupHost: resolver.LookupHost}
+			scanners.RunTakeoverFingerprints(outDir, &scanResult, tv, AppendLog)
+		}, nil)
+		// HTTP probing: only hosts that answer feed the web stages.
+		runStage("HTTP probing", outDir, true, func() {
+			client, _ := newHTTPClient(scanResult.ProxyEnabled)
+			scanners.RunHTTPProbe(client, target, outDir, &scanResult, AppendLog)
+		}, nil)
+		// Disclosure contacts and the third parties the target's DNS names.
+		runStage("disclosure metadata", outDir, true, func() {
+			client, _ := newHTTPClient(scanResult.ProxyEnabled)
+			hosts := scanners.RunDisclosureChecks(client, target, outDir, &scanResult, newResolver().LookupTXT, AppendLog)
+			if hosts, _ = scope.FilterHosts(hosts); len(hosts) > 0 {
+				if added := scanners.AddDiscoveredHosts(client, outDir, hosts, isHostAlive, &scanResult, AppendLog); len(added) > 0 {
+					AppendLog(fmt.Sprintf("[*] security.txt added %d subdomain(s)", len(added)))
+				}
+			}
+		}, nil)
+		// Open ports and services with nmap or the native connect scan.
+		if scanners.PortScanEnabled() {
+			runStage("port scanning", outDir, true, func() {
+				scanners.RunPortScan(outDir, &scanResult, newResolver().LookupHost, AppendLog)
+			}, nil)
+		} else {
+			AppendLog("[*] Skipping port scanning: disabled by PORT_SCAN=false")
+			recordStage(StageStatus{Stage: "port scanning", Status: "skipped", Messages: []string{"PORT_SCAN=false"}})
+		}
+		// Web services on the alternative ports the port scan found open.
+		runStage("alternative port probing", outDir, true, func() {
+			client, _ := newHTTPClient(scanResult.ProxyEnabled)
+			scanners.RunAltPortProbe(client, outDir, &scanResult, AppendLog)
+		}, nil)
+		// WAFs in front of the web services; later stages slow down behind one.
+		runStage("WAF detection", outDir, true, func() {
+			scanners.RunWafw00f(outDir, &scanResult, AppendLog)
+		}, nil)
+		// Technology fingerprints of the web services with whatweb.
+		runStage("technology fingerprinting", outDir, true, func() {
+			scanners.RunWhatweb(outDir, &scanResult, !passiveOnly, AppendLog)
+		}, nil)
+		// TLS protocol and cipher posture.
+		runStage("TLS posture", outDir, true, func() {
+			scanMu.Lock()
+			targets := scanners.TLSTargets(scanResult)
+			scanMu.Unlock()
+			postures, findings := scanners.RunTLSPosture(outDir, targets, AppendLog)
+			scanMu.Lock()
+			scanResult.TLSPosture = postures
+			scanResult.VulnURLs = append(scanResult.VulnURLs, findings...)
+			scanMu.Unlock()
+		}, nil)
+		// TLS hygiene of HTTPS hosts with testssl.sh.
+		runStage("testssl checks", outDir, true, func() {
+			scanners.RunTestssl(outDir, &scanResult, AppendLog)
+		}, nil)
+		// Exposed version control and CI metadata.
+		runStage("exposure checks", outDir, true, func() {
+			client, _ := newHTTPClient(scanResult.ProxyEnabled)
+			scanners.RunExposureChecks(client, &scanResult, AppendLog)
+		}, nil)
+		// Secrets in the history of exposed Git repositories.
+		runStage("repository secret scanning", outDir, true, func() {
+			var verifyClient *http.Client
+			if verifySecrets {
+				verifyClient, _ = newHTTPClient(scanResult.ProxyEnabled)
+			}
+			scanners.RunGitleaks(outDir, &scanResult, keepArtifacts, verifyClient, AppendLog)
+		}, nil)
+		// Inventory reconciliation.
+		if inventoryFile != "" {
+			runStage("inventory reconciliation", outDir, false, func() {
+				ReconcileInventory(inventoryFile, outDir)
+			}, nil)
+		}
+		// URL scanning using the crawlers, gau, and waybackurls.
+		runStage("URL scanning", outDir, true, func() {
+			RunURLScan(target, outDir)
+		}, utils.ValidateURLs)
+		// Endpoints referenced by the JavaScript files found while crawling.
+		runStage("JavaScript endpoint extraction", outDir, true, func() {
+			scanners.RunLinkFinder(outDir, &scanResult, scope.URLInScope, AppendLog)
+		}, nil)
+		// Client-side routes defined in the single-page app bundles.
+		runStage("SPA route extraction", outDir, true, func() {
+			client, _ := newHTTPClient(scanResult.ProxyEnabled)
+			scanners.RunSPARoutes(client, outDir, &scanResult, scope.URLInScope, AppendLog)
+		}, nil)
+		// Login walls, public pages and pages only shown to the
+		// configured credentials.
+		runStage("URL verification", outDir, true, func() {
+			client, _ := newHTTPClient(scanResult.ProxyEnabled)
+			headers, err := scanners.ParseAuthHeaders(authHeaders)
+			if err != nil {
+				AppendLog("[!] " + err.Error() + "; classifying without credentials")
+			}
+			scanners.RunAuthStateChecks(client, &scanResult, scope.URLInScope, headers, AppendLog)
+		}, nil)
+		// Secrets in the JavaScript files found while crawling.
+		runStage("secret scanning", outDir, true, func() {
+			client, _ := newHTTPClient(scanResult.ProxyEnabled)
+			scanners.RunSecretScan(client, outDir, verifySecrets, &scanResult, AppendLog)
+		}, nil)
 		// Fuzzing with ffuf.
-		RunFuzzing(target, outDir)
+		runStage("fuzzing", outDir, true, func() {
+			RunFuzzing(target, outDir)
+		}, utils.ValidateFuzzing)
+		// Hosts of one application answering a path unlike the others.
+		runStage("response anomaly analysis", outDir, false, func() {
+			AnalyzeResponseAnomalies(outDir)
+		}, nil)
+		// Allowed HTTP methods on a sample of discovered endpoints.
+		runStage("HTTP method checks", outDir, true, func() {
+			client, _ := newHTTPClient(scanResult.ProxyEnabled)
+			scanners.RunMethodChecks(client, &scanResult, scope.URLInScope, AppendLog)
+		}, nil)
+		// Reflected file download and content-type handling of API endpoints.
+		runStage("response handling checks", outDir, true, func() {
+			client, _ := newHTTPClient(scanResult.ProxyEnabled)
+			scanners.RunResponseHandlingChecks(client, &scanResult, scope.URLInScope, AppendLog)
+		}, nil)
 		// Pre-vulnerability endpoint discovery.
-		RunPreVulnTools(target, outDir)
+		runStage("endpoint discovery", outDir, true, func() {
+			RunPreVulnTools(target, outDir)
+		}, nil)
+		// Hidden parameters of discovered URLs with Arjun.
+		runStage("parameter discovery", outDir, true, func() {
+			scanners.RunArjun(outDir, &scanResult, scope.URLInScope, AppendLog)
+		}, nil)
+		// CRLF injection in URLs that take parameters, with crlfuzz.
+		runStage("CRLF injection checks", outDir, true, func() {
+			scanners.RunCrlfuzz(outDir, &scanResult, scope.URLInScope, AppendLog)
+		}, nil)
+		// Request smuggling indicators on the hosts behind a WAF or CDN.
+		runStage("request smuggling checks", outDir, true, func() {
+			scanners.RunSmugglingChecks(&scanResult, scope.URLInScope, AppendLog)
+		}, nil)
+		// Template-based scanning with nuclei.
+		runStage("nuclei scanning", outDir, true, func() {
+			scanners.RunNuclei(outDir, &scanResult, AppendLog)
+		}, nil)
+		// Optional web server checks with nikto.
+		runStage("nikto scanning", outDir, true, func() {
+			scanners.RunNikto(outDir, &scanResult, AppendLog)
+		}, nil)
+		// wpscan for hosts that look like WordPress.
+		runStage("WordPress scanning", outDir, true, func() {
+			client, _ := newHTTPClient(scanResult.ProxyEnabled)
+			scanners.RunWPScan(client, outDir, &scanResult, AppendLog)
+		}, nil)
+		// sqlmap and reflection checks in the JSON bodies of captured API
+		// requests.
+		if apiTemplatesFile != "" {
+			runStage("JSON body injection checks", outDir, true, func() {
+				client, _ := newHTTPClient(scanResult.ProxyEnabled)
+				scanners.RunJSONInjection(apiTemplatesFile, outDir, client, &scanResult, scope.URLInScope, scanResult.WAFDetected, AppendLog)
+			}, nil)
+		}
 		// Vulnerability scanning.
-		RunVulnerabilityScans(target, outDir)
+		runStage("vulnerability scanning", outDir, true, func() {
+			RunVulnerabilityScans(target, outDir, scanResult.WAFDetected)
+		}, utils.ValidateVulnerabilities)
 		// API enrichment: Shodan.
 		if key := os.Getenv("SHODAN_API_KEY"); key != "" {
-			EnrichWithShodan(key, outDir)
+			runStage("Shodan enrichment", outDir, true, func() {
+				EnrichWithShodan(key, outDir)
+			}, nil)
 		}
 		// Finalize report.
 		scanMu.Lock()
 		scanResult.Running = false
-		scanResult.FinalReport = "Final report for " + target + " generated at " + time.Now().Format(time.RFC1123)
+		utils.TagAssets(&scanResult, tagRules)
+		if n := scanners.AnnotateAuthState(&scanResult); n > 0 {
+			AppendLog(fmt.Sprintf("[*] Marked %d finding(s) with the authentication state of their page.", n))
+		}
+		// Score every finding now its host's access tags are known.
+		for i := range scanResult.VulnURLs {
+			utils.ApplyCVSS(&scanResult.VulnURLs[i])
+		}
+		if n := triage.Apply(scanResult.VulnURLs); n > 0 {
+			AppendLog(fmt.Sprintf("[*] Carried over %d triage disposition(s) from earlier runs.", n))
+		}
+		if scanDefinition != nil {
+			if n := utils.ApplySeverityOverrides(scanResult.VulnURLs, scanDefinition.Severity.Overrides); n > 0 {
+				AppendLog(fmt.Sprintf("[*] Rated %d finding(s) by the scan definition's severity overrides.", n))
+			}
+			if n := utils.ApplySuppressions(scanResult.VulnURLs, scanDefinition.Suppressions); n > 0 {
+				AppendLog(fmt.Sprintf("[*] Marked %d finding(s) false positive as the scan definition suppresses them.", n))
+			}
+		}
+		scanResult.FinalReport = buildFinalReport(target)
 		scanMu.Unlock()
+		if keepToolDirs {
+			AppendLog("[*] Keeping tool home directory: " + sandbox.HomeDir)
+		} else if err := sandbox.Cleanup(); err != nil {
+			AppendLog("[!] Failed to remove tool home directory: " + err.Error())
+		}
 		AppendLog("========== Scan Complete ==========")
-		// Persist results.
-		utils.PersistResults(scanResult, outDir)
+		stopDebug()
+		// Persist results, with the whole log rather than the TUI's ring.
+		history, logErr := logger.History()
+		if logErr != nil {
+			AppendLog("[!] Reading scan.log: " + logErr.Error() + "; keeping the latest lines only")
+		}
+		scanMu.Lock()
+		scanResult.LogLines = history
+		scanMu.Unlock()
+		if err := saveRun(runStores, filepath.Base(outDir), scanResult); err != nil {
+			AppendLog("[!] Saving the run: " + err.Error())
+		}
+		if err := triage.WriteRun(filepath.Join(outDir, "triage.json"), scanResult.VulnURLs); err != nil {
+			AppendLog("[!] Triage export error: " + err.Error())
+		}
+		err := kb.RecordRun(utils.KBRun{
+			Dir:        outDir,
+			Started:    started,
+			Finished:   time.Now(),
+			Subdomains: len(scanResult.Subdomains),
+			URLs:       len(scanResult.AllURLs),
+			Findings:   len(scanResult.VulnURLs),
+		})
+		if err != nil {
+			AppendLog("[!] Knowledge base error: " + err.Error())
+		}
+		// Target list for the vulnerability assessment team's scanners.
+		if err := exporters.WriteNessusTargets(scanResult, outDir); err != nil {
+			AppendLog("[!] Nessus target export error: " + err.Error())
+		}
+		// Export findings for DefectDojo and optionally upload them.
+		ddFile, err := exporters.WriteDefectDojo(scanResult, outDir)
+		if err != nil {
+			AppendLog("[!] DefectDojo export error: " + err.Error())
+		} else if cfg, ok := exporters.DefectDojoConfigFromEnv(); ok && !skipOffline("DefectDojo upload") {
+			client, _ := newHTTPClient(scanResult.ProxyEnabled)
+			if err := exporters.UploadDefectDojo(client, cfg, ddFile); err != nil {
+				AppendLog("[!] DefectDojo upload error: " + err.Error())
+			} else {
+				AppendLog("[*] Findings imported into DefectDojo engagement " + cfg.EngagementID)
+			}
+		}
+		if scanDefinition != nil && len(scanDefinition.Notify) > 0 && !skipOffline("notifications") {
+			notifyRoutes(target, filepath.Base(outDir))
+		}
+		logger.Flush()
 	}()
-	// Launch TUI.
+	// Launch TUI, or follow the log on stdout without one.
+	if noTUI {
+		wg.Wait()
+		return
+	}
 	startTUI(outDir, target)
 	wg.Wait()
 }
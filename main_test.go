@@ -0,0 +1,595 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/exporters"
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/utils"
+	"github.com/gdamore/tcell/v2"
+)
+
+// TestStagesClassified keeps every pipeline stage in exactly one of
+// aggressiveStages and passiveStages, so a new stage cannot slip past the
+// authorization gate unclassified.
+func TestStagesClassified(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, name := range pipelineStages {
+		if seen[name] {
+			t.Errorf("stage %q listed twice in pipelineStages", name)
+		}
+		seen[name] = true
+		if aggressiveStages[name] == passiveStages[name] {
+			t.Errorf("stage %q: aggressive %v, passive %v; want exactly one", name, aggressiveStages[name], passiveStages[name])
+		}
+	}
+	for _, m := range []map[string]bool{aggressiveStages, passiveStages} {
+		for name := range m {
+			if !seen[name] {
+				t.Errorf("classified stage %q is not in pipelineStages", name)
+			}
+		}
+	}
+}
+
+// TestRunStageDropsOutOfScopeFindings feeds a stage fake tool output with a
+// finding on a host outside the scope and checks it never reaches the scan
+// result, while the stage status names what was dropped.
+func TestRunStageDropsOutOfScopeFindings(t *testing.T) {
+	savedScope, savedResult := scope, scanResult
+	defer func() { scope, scanResult = savedScope, savedResult }()
+	scope = utils.NewScopeFilter("example.com", nil, []string{"legacy.example.com"})
+	scanResult = ScanResult{}
+
+	output := `{"template-id":"exposed-panel","info":{"name":"Exposed Panel","severity":"high"},"matched-at":"https://admin.example.com/login"}
+{"template-id":"exposed-panel","info":{"name":"Exposed Panel","severity":"high"},"matched-at":"https://cdn.thirdparty.io/login"}
+{"template-id":"git-config","info":{"name":"Git Config","severity":"medium"},"matched-at":"https://legacy.example.com/.git/config"}
+`
+	runStage("nuclei scanning", t.TempDir(), false, func() {
+		findings, err := parsers.ParseNucleiOutput(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanMu.Lock()
+		scanResult.VulnURLs = append(scanResult.VulnURLs, findings...)
+		scanMu.Unlock()
+	}, nil)
+
+	if len(scanResult.VulnURLs) != 1 || scanResult.VulnURLs[0].URL != "https://admin.example.com/login" {
+		t.Errorf("findings after the stage: %+v", scanResult.VulnURLs)
+	}
+	if len(scanResult.Stages) != 1 {
+		t.Fatalf("stages: %+v", scanResult.Stages)
+	}
+	found := false
+	for _, msg := range scanResult.Stages[0].Messages {
+		found = found || strings.HasPrefix(msg, "2 out-of-scope finding(s) dropped: ")
+	}
+	if !found {
+		t.Errorf("stage messages %q lack the dropped count", scanResult.Stages[0].Messages)
+	}
+}
+
+// TestRunStageFailFast checks that with --fail-fast a failed validation
+// skips the stages after it, and that without it they still run.
+func TestRunStageFailFast(t *testing.T) {
+	savedFailFast, savedFailedFast, savedResult := failFast, failedFast, scanResult
+	defer func() { failFast, failedFast, scanResult = savedFailFast, savedFailedFast, savedResult }()
+	invalid := func(*ScanResult, string) ([]string, error) { return nil, errors.New("urls.txt unreadable") }
+
+	for _, ff := range []bool{false, true} {
+		failFast, failedFast, scanResult = ff, "", ScanResult{}
+		ran := 0
+		runStage("URL scanning", t.TempDir(), false, func() { ran++ }, invalid)
+		runStage("JavaScript endpoint extraction", t.TempDir(), false, func() { ran++ }, nil)
+
+		want := []string{"failed-validation", "completed"}
+		if ff {
+			want[1] = "skipped"
+		}
+		var got []string
+		for _, st := range scanResult.Stages {
+			got = append(got, st.Status)
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("fail-fast %v: statuses %v, want %v", ff, got, want)
+		}
+		if wantRan := map[bool]int{false: 2, true: 1}[ff]; ran != wantRan {
+			t.Errorf("fail-fast %v: %d stage(s) ran, want %d", ff, ran, wantRan)
+		}
+		if ff && scanResult.Stages[1].Messages[0] != "fail-fast: URL scanning failed validation" {
+			t.Errorf("skip message %q", scanResult.Stages[1].Messages)
+		}
+	}
+}
+
+// TestEnrichWithShodanUsage runs the Shodan enrichment against a fake API
+// and checks the lookups the budget allows and the usage accounted for.
+func TestEnrichWithShodanUsage(t *testing.T) {
+	savedAPI, savedResult, savedOffline := shodanAPI, scanResult, offlineMode
+	defer func() { shodanAPI, scanResult, offlineMode = savedAPI, savedResult, savedOffline }()
+	offlineMode = false
+
+	tests := []struct {
+		name           string
+		credits        []int // answered by /api-info in turn; nil fails it
+		budget         string
+		lookups        int
+		before, after  int
+		warnings       int
+		unknownCredits bool
+	}{
+		{"no budget", []int{100, 100}, "", 3, 100, 100, 0, false},
+		{"over budget", []int{100, 100}, "2", 2, 100, 100, 1, false},
+		// Host lookups cost no query credits, so none left stops nothing.
+		{"no query credits", []int{0, 0}, "", 3, 0, 0, 0, false},
+		{"quota unreadable", nil, "", 3, 0, 0, 0, true},
+	}
+	for _, tt := range tests {
+		var lookups, info int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("key") != "k" {
+				http.Error(w, "bad key", http.StatusUnauthorized)
+				return
+			}
+			switch {
+			case r.URL.Path == "/api-info":
+				if tt.credits == nil {
+					http.Error(w, "unavailable", http.StatusServiceUnavailable)
+					return
+				}
+				fmt.Fprintf(w, `{"query_credits": %d, "plan": "dev"}`, tt.credits[info])
+				info++
+			case strings.HasPrefix(r.URL.Path, "/shodan/host/"):
+				lookups++
+				fmt.Fprintf(w, `{"ip_str": %q, "ports": [443]}`, strings.TrimPrefix(r.URL.Path, "/shodan/host/"))
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		shodanAPI = srv.URL
+		t.Setenv("SHODAN_QUERY_BUDGET", tt.budget)
+		scanResult = ScanResult{Subdomains: []SubdomainResult{
+			{Hostname: "www.example.com", IPs: []string{"8.8.8.8", "1.1.1.1"}},
+			{Hostname: "api.example.com", IPs: []string{"1.1.1.1", "9.9.9.9"}},
+			// Internal and documentation addresses never go to Shodan.
+			{Hostname: "intranet.example.com", IPs: []string{"10.0.0.5"}},
+			{Hostname: "docs.example.com", IPs: []string{"203.0.113.10"}},
+		}}
+		EnrichWithShodan("k", t.TempDir())
+		srv.Close()
+
+		if lookups != tt.lookups {
+			t.Errorf("%s: %d lookup(s), want %d", tt.name, lookups, tt.lookups)
+		}
+		if len(scanResult.APIUsage) != 1 {
+			t.Errorf("%s: usage %+v", tt.name, scanResult.APIUsage)
+			continue
+		}
+		u := scanResult.APIUsage[0]
+		if u.Provider != "shodan" || u.Estimated != 3 || u.Queries != tt.lookups || len(u.Warnings) != tt.warnings {
+			t.Errorf("%s: usage %+v", tt.name, u)
+		}
+		if tt.unknownCredits {
+			if u.CreditsBefore != nil || u.CreditsAfter != nil {
+				t.Errorf("%s: credits recorded without a quota", tt.name)
+			}
+		} else if u.CreditsBefore == nil || *u.CreditsBefore != tt.before || u.CreditsAfter == nil || *u.CreditsAfter != tt.after {
+			t.Errorf("%s: credits before %v, after %v", tt.name, u.CreditsBefore, u.CreditsAfter)
+		}
+	}
+}
+
+// TestBaselineMarkers builds the Subdomains and Vulnerabilities rows
+// against a baseline and checks what is marked [NEW] and counted on the
+// tabs, as the rows are rebuilt while a scan runs.
+func TestBaselineMarkers(t *testing.T) {
+	savedResult, savedBaseline := scanResult, baseline
+	defer func() { scanResult, baseline = savedResult, savedBaseline }()
+
+	dir := t.TempDir()
+	prev := ScanResult{
+		Subdomains: []SubdomainResult{{Hostname: "www.example.com"}, {Hostname: "api.example.com"}},
+		VulnURLs:   []VulnerabilityResult{{URL: "https://api.example.com/item?id=1", Issue: "SQL Injection", Parameter: "id (GET)"}},
+	}
+	if err := utils.PersistResults(prev, dir); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if baseline, err = utils.LoadBaseline(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// The scan is under way: one old host and one new so far.
+	scanResult = ScanResult{
+		Running:    true,
+		Subdomains: []SubdomainResult{{Hostname: "www.example.com"}, {Hostname: "dev.example.com"}},
+	}
+	d, render, n := subdomainRows()
+	if n != 1 {
+		t.Errorf("%d new subdomain(s), want 1", n)
+	}
+	for i := 0; i < d.Len(); i++ {
+		host := scanResult.Subdomains[d.Row(i)].Hostname
+		if marked := strings.HasPrefix(render(d.Row(i)), newMarker); marked != (host == "dev.example.com") {
+			t.Errorf("%s: marked new %v", host, marked)
+		}
+	}
+
+	scanResult.Subdomains = append(scanResult.Subdomains, SubdomainResult{Hostname: "API.example.com."}, SubdomainResult{Hostname: "mail.example.com"})
+	scanResult.VulnURLs = []VulnerabilityResult{
+		{URL: "https://api.example.com/item?id=1", Issue: "SQL Injection", Parameter: "id (GET)"},
+		{URL: "https://api.example.com/item?id=1", Issue: "SQL Injection", Parameter: "cat (GET)"},
+	}
+	if _, _, n := subdomainRows(); n != 2 {
+		t.Errorf("%d new subdomain(s), want 2", n)
+	}
+	d, render, n = vulnRows()
+	if n != 1 {
+		t.Errorf("%d new finding(s), want 1", n)
+	}
+	if strings.HasPrefix(render(0), newMarker) || !strings.HasPrefix(render(1), newMarker) {
+		t.Errorf("findings rendered %q, %q", render(0), render(1))
+	}
+
+	// Without a baseline nothing is marked.
+	baseline = nil
+	if _, render, n := vulnRows(); n != 0 || strings.HasPrefix(render(1), newMarker) {
+		t.Errorf("without a baseline: %d new, %q", n, render(1))
+	}
+}
+
+// TestRunStageRecoversPanic runs a stage that panics, directly and in its
+// workers, and checks the stage fails with a crash report while the
+// pipeline carries on with the next stage.
+func TestRunStageRecoversPanic(t *testing.T) {
+	savedResult := scanResult
+	defer func() { scanResult = savedResult }()
+	scanResult = ScanResult{}
+	outDir := t.TempDir()
+
+	runStage("Shodan enrichment", outDir, false, func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				utils.Guard(func() { panic("worker: nil host") })
+			}()
+		}
+		wg.Wait()
+		var m map[string]int
+		m["lookups"]++
+	}, nil)
+	ran := false
+	runStage("response anomaly analysis", outDir, false, func() { ran = true }, nil)
+
+	if !ran {
+		t.Error("the stage after the panic did not run")
+	}
+	if len(scanResult.Stages) != 2 || scanResult.Stages[0].Status != "failed" || scanResult.Stages[1].Status != "completed" {
+		t.Fatalf("stages: %+v", scanResult.Stages)
+	}
+	msgs := scanResult.Stages[0].Messages
+	if len(msgs) != 3 || msgs[0] != "panic: worker: nil host (2 times)" ||
+		!strings.HasPrefix(msgs[1], "panic: assignment to entry in nil map") || !strings.HasPrefix(msgs[2], "crash report: ") {
+		t.Fatalf("messages: %q", msgs)
+	}
+	report, err := ioutil.ReadFile(strings.TrimPrefix(msgs[2], "crash report: "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(report), "Shodan enrichment") || !strings.Contains(string(report), "TestRunStageRecoversPanic") {
+		t.Errorf("crash report lacks the stage or the stack:\n%s", report)
+	}
+	if p := utils.TakePanics(); len(p) != 0 {
+		t.Errorf("panics left over for the next stage: %v", p)
+	}
+}
+
+// TestFinalReportAuthorization checks the report states the run's
+// authorization, including a downgrade to passive stages.
+func TestFinalReportAuthorization(t *testing.T) {
+	savedResult := scanResult
+	defer func() { scanResult = savedResult }()
+
+	for _, auth := range []*Authorization{
+		nil,
+		{EngagementRef: "ENG-42", AuthorizedBy: "J. Doe", ValidUntil: "2026-09-30", Source: "config", Downgraded: "authorization expired 2026-09-30"},
+	} {
+		scanResult = ScanResult{Authorization: auth}
+		want := "Authorization: " + utils.FormatAuthorization(auth) + "\n"
+		if report := buildFinalReport("example.com"); !strings.Contains(report, want) {
+			t.Errorf("report lacks %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestKeyName(t *testing.T) {
+	tests := []struct {
+		event *tcell.EventKey
+		want  string
+	}{
+		{tcell.NewEventKey(tcell.KeyRune, 't', tcell.ModNone), "t"},
+		{tcell.NewEventKey(tcell.KeyRune, 'T', tcell.ModShift), "T"},
+		{tcell.NewEventKey(tcell.KeyRune, '?', tcell.ModNone), "?"},
+		{tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone), "Esc"},
+		{tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone), "Enter"},
+		{tcell.NewEventKey(tcell.KeyPgDn, 0, tcell.ModNone), "PgDn"},
+		{tcell.NewEventKey(tcell.KeyHome, 0, tcell.ModNone), "Home"},
+		// Keys the keymap cannot bind dispatch nothing.
+		{tcell.NewEventKey(tcell.KeyTab, 0, tcell.ModNone), ""},
+		{tcell.NewEventKey(tcell.KeyCtrlC, 0, tcell.ModCtrl), ""},
+	}
+	for _, tt := range tests {
+		if got := keyName(tt.event); got != tt.want {
+			t.Errorf("keyName(%s) = %q, want %q", tt.event.Name(), got, tt.want)
+		}
+	}
+}
+
+func TestBuildPreflight(t *testing.T) {
+	savedScope, savedOffline, savedPassive := scope, offlineMode, passiveOnly
+	savedInventory, savedTemplates, savedSocks, savedWindow := inventoryFile, apiTemplatesFile, socks, scanWindow
+	defer func() {
+		scope, offlineMode, passiveOnly = savedScope, savedOffline, savedPassive
+		inventoryFile, apiTemplatesFile, socks, scanWindow = savedInventory, savedTemplates, savedSocks, savedWindow
+	}()
+	wordlist := filepath.Join(t.TempDir(), "words.txt")
+	if err := ioutil.WriteFile(wordlist, []byte("admin\nlogin\napi\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WORDLIST", wordlist)
+	t.Setenv("FUZZER", "ffuf,gobuster")
+	t.Setenv("FUZZ_EXTENSIONS", "php")
+	t.Setenv("PORT_SCAN", "false")
+	t.Setenv("SHODAN_API_KEY", "")
+	scope = utils.NewScopeFilter("example.com", nil, nil)
+	socks, scanWindow = nil, nil
+	inventoryFile, apiTemplatesFile = "", "templates.json"
+
+	skipped := func(p utils.Preflight) map[string]string {
+		reasons := make(map[string]string)
+		for _, st := range p.Stages {
+			reasons[st.Name] = st.Skipped
+		}
+		return reasons
+	}
+
+	offlineMode, passiveOnly = false, false
+	p := buildPreflight("HTTPS://Example.com/", nil)
+	if p.Target != "example.com" || len(p.Stages) != len(pipelineStages) {
+		t.Fatalf("preflight %+v", p)
+	}
+	reasons := skipped(p)
+	for stage, want := range map[string]string{
+		"inventory reconciliation":   "no --inventory",
+		"port scanning":              "PORT_SCAN=false",
+		"Shodan enrichment":          "no SHODAN_API_KEY",
+		"JSON body injection checks": "",
+		"fuzzing":                    "",
+	} {
+		if reasons[stage] != want {
+			t.Errorf("%s skipped %q, want %q", stage, reasons[stage], want)
+		}
+	}
+	// Three words, each also with .php, for both backends.
+	if len(p.Estimates) == 0 || !strings.Contains(p.Estimates[0], "about 12 request(s)") {
+		t.Errorf("estimates %q", p.Estimates)
+	}
+
+	// Unauthorized: aggressive stages are skipped, passive ones are not,
+	// and nothing is estimated for the target.
+	passiveOnly = true
+	p = buildPreflight("example.com", nil)
+	reasons = skipped(p)
+	if reasons["fuzzing"] != "not authorized" || reasons["subdomain enumeration"] != "" || len(p.Estimates) != 0 {
+		t.Errorf("passive preflight %+v", p)
+	}
+
+	// Offline, only the stages that need no network run.
+	offlineMode, passiveOnly = true, false
+	t.Setenv("SHODAN_API_KEY", "key")
+	inventoryFile = "inventory.csv"
+	p = buildPreflight("example.com", nil)
+	reasons = skipped(p)
+	if reasons["subdomain enumeration"] != "offline mode" || reasons["inventory reconciliation"] != "" ||
+		reasons["response anomaly analysis"] != "" || len(p.Estimates) != 0 {
+		t.Errorf("offline preflight %+v", p)
+	}
+}
+
+func TestFinalReportPlaceholders(t *testing.T) {
+	savedResult, savedInclude := scanResult, includeParked
+	defer func() { scanResult, includeParked = savedResult, savedInclude }()
+
+	scanResult = ScanResult{LiveHosts: []LiveHost{
+		{Hostname: "old.example.com", URL: "https://old.example.com", Port: 443, StatusCode: 200, Placeholder: "Sedo parking", Tags: map[string][]string{"state": {"parked"}}},
+		{Hostname: "www.example.com", URL: "https://www.example.com", Port: 443, StatusCode: 200, Title: "Acme"},
+	}}
+	for _, include := range []bool{false, true} {
+		includeParked = include
+		report := buildFinalReport("example.com")
+		if !strings.Contains(report, "Parked & default pages (1)") || !strings.Contains(report, "  https://old.example.com [200] Sedo parking (state:parked)\n") {
+			t.Errorf("include %v: report lacks the placeholder section:\n%s", include, report)
+		}
+		if hint := strings.Contains(report, "--include-parked"); hint == include {
+			t.Errorf("include %v: --include-parked hint shown %v", include, hint)
+		}
+	}
+}
+
+func TestHostTimingsReport(t *testing.T) {
+	saved := scanResult
+	defer func() { scanResult = saved }()
+
+	timing := func(host string, ttfb, p95 float64) HostTiming {
+		return HostTiming{Host: host, Requests: 4,
+			Connect: PhaseTiming{Count: 1, MedianMS: 12.5, P95MS: 12.5},
+			TTFB:    PhaseTiming{Count: 4, MedianMS: ttfb, P95MS: p95}}
+	}
+	scanResult = ScanResult{
+		Subdomains: []SubdomainResult{{Hostname: "a.example.com"}, {Hostname: "b.example.com"}, {Hostname: "c.example.com"}, {Hostname: "d.example.com"}},
+		HostTimings: []HostTiming{
+			timing("a.example.com", 80, 150),
+			timing("b.example.com", 900, 2400.4),
+			timing("c.example.com", 3.25, 6),
+			// Connected, never answered.
+			{Host: "d.example.com", Requests: 1, Connect: PhaseTiming{Count: 1, MedianMS: 8, P95MS: 8}},
+		},
+	}
+	report := buildFinalReport("example.com")
+	want := "  b.example.com                            p95 2400ms    - / 12ms / - / 900ms (4 request(s))\n" +
+		"  a.example.com                            p95 150ms     - / 12ms / - / 80ms (4 request(s))\n" +
+		"  c.example.com                            p95 6.0ms     - / 12ms / - / 3.2ms (4 request(s))\n"
+	if !strings.Contains(report, "\nSlowest hosts (by time to first byte, p95;") || !strings.Contains(report, want) || strings.Contains(report, "  d.example.com") {
+		t.Errorf("report lacks the slowest hosts:\n%s", report)
+	}
+
+	var many []HostTiming
+	for i := 0; i < slowestHostsShown+5; i++ {
+		many = append(many, timing(fmt.Sprintf("h%02d.example.com", i), float64(i), float64(i)))
+	}
+	if got := slowestHosts(many, slowestHostsShown); len(got) != slowestHostsShown || got[0].Host != "h14.example.com" {
+		t.Errorf("slowestHosts kept %d, first %+v", len(got), got[0])
+	}
+
+	// The Subdomains table sorts by TTFB, hosts without one last.
+	d, render, _ := subdomainRows()
+	col := -1
+	for i, name := range d.Columns() {
+		if name == "TTFB p95" {
+			col = i
+		}
+	}
+	if col < 0 {
+		t.Fatalf("no TTFB p95 column in %v", d.Columns())
+	}
+	d.SortBy(col, false)
+	var order []string
+	for i := 0; i < d.Len(); i++ {
+		order = append(order, scanResult.Subdomains[d.Row(i)].Hostname)
+	}
+	if strings.Join(order, " ") != "c.example.com a.example.com b.example.com d.example.com" {
+		t.Errorf("sorted by TTFB p95: %v", order)
+	}
+	if row := render(1); !strings.Contains(row, "dns - connect 12ms tls - ttfb 900ms (p95 2400ms)") {
+		t.Errorf("row %q", row)
+	}
+	if row := render(3); strings.Contains(row, "ttfb") {
+		t.Errorf("row of a host that never answered %q", row)
+	}
+}
+
+func TestFinalReportServices(t *testing.T) {
+	saved := scanResult
+	defer func() { scanResult = saved }()
+
+	scanResult = ScanResult{Subdomains: []SubdomainResult{{Hostname: "mx.example.com", Services: []PortService{
+		{Port: 22, Protocol: "tcp", State: "open", Service: "ssh", Banner: "SSH-2.0-OpenSSH_9.6", Product: "OpenSSH", Version: "9.6"},
+		{Port: 25, Protocol: "tcp", State: "open", Service: "smtp", Banner: "220 mx.example.com ESMTP Postfix " + strings.Repeat("250-X ", 20), Product: "Postfix"},
+		{Port: 443, Protocol: "tcp", State: "open"},
+	}}}}
+	report := buildFinalReport("example.com")
+	want := "\nServices (3):\n" +
+		"  mx.example.com:22/tcp ssh - OpenSSH 9.6 \"SSH-2.0-OpenSSH_9.6\"\n" +
+		"  mx.example.com:25/tcp smtp - Postfix \"220 mx.example.com ESMTP Postfix 250-X 250-X 250-X 250-X 250-X 250-X 250-X 250-X...\"\n" +
+		"  mx.example.com:443/tcp\n"
+	if !strings.Contains(report, want) {
+		t.Errorf("report lacks the services:\n%s", report)
+	}
+}
+
+func TestFinalReportPageHygiene(t *testing.T) {
+	saved := scanResult
+	defer func() { scanResult = saved }()
+
+	scanResult = ScanResult{VulnURLs: []VulnerabilityResult{
+		{Type: "mixed-content", URL: "https://www.example.com/"},
+		{Type: "mixed-content", URL: "https://www.example.com/shop"},
+		{Type: "insecure-form-action", URL: "https://login.example.com/"},
+	}}
+	want := "\nPage hygiene (HTTPS pages loading or posting over HTTP):\n" +
+		"  login.example.com: 0 mixed content element(s), 1 insecure form(s)\n" +
+		"  www.example.com: 2 mixed content element(s), 0 insecure form(s)\n"
+	if report := buildFinalReport("example.com"); !strings.Contains(report, want) {
+		t.Errorf("report lacks the page hygiene section:\n%s", report)
+	}
+	if section := pageHygieneSection(nil); section != "" {
+		t.Errorf("section without findings: %q", section)
+	}
+}
+
+// TestExposedSecretsArtifacts runs raw secrets through the detectors'
+// parsers and checks that the report, summary.json and the DefectDojo
+// export carry them masked only.
+func TestExposedSecretsArtifacts(t *testing.T) {
+	saved := scanResult
+	defer func() { scanResult = saved }()
+
+	awsKey := "AKIA" + "Q3TZ7LWN2P5RXK8M"
+	ghToken := "gh" + "p_" + "r8Kd2mQx7ZtL4vWb9NcY3hFs6JpG1aUe5oTi"
+	password := "hunter2"
+	truffle := `{"DetectorName":"AWS","Verified":false,"Raw":"` + awsKey + `","SourceMetadata":{"Data":{"Filesystem":{"file":"https://a.example.com/main.js","line":3}}}}` + "\n" +
+		`{"DetectorName":"Github","Verified":true,"Raw":"` + ghToken + `","SourceMetadata":{"Data":{"Filesystem":{"file":"https://a.example.com/app.js","line":1}}}}`
+	jsFindings, jsSecrets, err := parsers.ParseTrufflehogOutput(truffle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitleaks := `[{"RuleID":"aws-access-token","File":"deploy.sh","StartLine":9,"Commit":"3f786850e387","Secret":"` + awsKey + `"},` +
+		`{"RuleID":"generic-password","File":"config.ini","StartLine":2,"Secret":"` + password + `"}]`
+	repoFindings, repoSecrets, err := parsers.ParseGitleaksReport([]byte(gitleaks), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range repoSecrets {
+		repoSecrets[i].Locations[0].Artifact = "https://b.example.com/.git/#" + repoSecrets[i].Locations[0].Artifact
+	}
+	scanResult = ScanResult{VulnURLs: append(jsFindings, repoFindings...)}
+	scanResult.Secrets = utils.MergeSecrets(utils.MergeSecrets(nil, jsSecrets...), repoSecrets...)
+
+	report := buildFinalReport("example.com")
+	want := "\nExposed Secrets (3, 1 verified):\n" +
+		"  Critical aws         AKIA********XK8M (20 chars)  unverified, firm\n" +
+		"      https://a.example.com/main.js line 3 (trufflehog AWS)\n" +
+		"      https://b.example.com/.git/#deploy.sh line 9 at commit 3f786850e387 (gitleaks aws-access-token)\n" +
+		"  High     github      ghp_********5oTi (40 chars)  verified, confirmed\n" +
+		"      https://a.example.com/app.js line 1 (trufflehog Github)\n" +
+		"  Medium   generic     ******** (7 chars)  unverified, firm\n" +
+		"      https://b.example.com/.git/#config.ini line 2 (gitleaks generic-password)\n"
+	if !strings.Contains(report, want) {
+		t.Errorf("report lacks the secrets:\n%s", report)
+	}
+	scanResult.FinalReport = report
+
+	dir := t.TempDir()
+	if err := utils.PersistResults(scanResult, dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := exporters.WriteDefectDojo(scanResult, dir); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) < 2 {
+		t.Fatalf("artifacts %q", files)
+	}
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, raw := range []string{awsKey, ghToken, password} {
+			if strings.Contains(string(data), raw) {
+				t.Errorf("%s carries the raw secret %q", filepath.Base(f), raw)
+			}
+		}
+	}
+}
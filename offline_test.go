@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// canary is a TCP listener that counts the connections made to it.
+type canary struct {
+	ln       net.Listener
+	accepted int32
+}
+
+func newCanary(t *testing.T) *canary {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &canary{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&c.accepted, 1)
+			conn.Close()
+		}
+	}()
+	return c
+}
+
+// connections returns how many connections reached the canary before this
+// call. It connects once itself and waits for that connection to be
+// accepted, so any earlier one has been counted by then.
+func (c *canary) connections(t *testing.T) int {
+	t.Helper()
+	before := atomic.LoadInt32(&c.accepted)
+	conn, err := net.Dial("tcp", c.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	for deadline := time.Now().Add(5 * time.Second); atomic.LoadInt32(&c.accepted) == before; {
+		if time.Now().After(deadline) {
+			t.Fatal("canary self-check connection never accepted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return int(atomic.LoadInt32(&c.accepted)) - 1
+}
+
+func writeJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestReprocessOffline reprocesses a fixture run and diffs it against a
+// baseline run in offline mode. Every host and URL in the fixtures points
+// at a canary listener, which must see no connection.
+func TestReprocessOffline(t *testing.T) {
+	c := newCanary(t)
+	defer c.ln.Close()
+	origin := "http://" + c.ln.Addr().String()
+
+	savedOffline, savedBaselineDir, savedBaseline, savedResult := offlineMode, baselineDir, baseline, scanResult
+	defer func() {
+		offlineMode, baselineDir, baseline, scanResult = savedOffline, savedBaselineDir, savedBaseline, savedResult
+	}()
+
+	root := t.TempDir()
+	baseDir := filepath.Join(root, "example.com_20261001_120000")
+	runDir := filepath.Join(root, "example.com_20261008_120000")
+	for _, d := range []string{baseDir, runDir} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := VulnerabilityResult{URL: origin + "/item?id=1", Issue: "SQL Injection", Type: "sqli"}
+	writeJSON(t, filepath.Join(baseDir, "summary.json"), ScanResult{
+		Subdomains: []SubdomainResult{{Hostname: "www.example.com", IP: "127.0.0.1"}},
+		VulnURLs:   []VulnerabilityResult{old},
+	})
+	writeJSON(t, filepath.Join(runDir, "summary.json"), ScanResult{
+		Subdomains: []SubdomainResult{
+			{Hostname: "www.example.com", IP: "127.0.0.1", IPs: []string{"127.0.0.1"}},
+			{Hostname: "app.example.com", IP: "127.0.0.1", IPs: []string{"127.0.0.1"}},
+		},
+		LiveHosts: []LiveHost{{Hostname: "app.example.com", URL: origin}},
+		AllURLs:   []string{origin + "/", origin + "/item?id=1"},
+		VulnURLs:  []VulnerabilityResult{old},
+	})
+	nuclei := `{"template-id":"exposed-panel","info":{"name":"Exposed Admin Panel","severity":"high"},"matched-at":"` + origin + `/admin"}` + "\n" +
+		"[INF] banner line\n"
+	if err := ioutil.WriteFile(filepath.Join(runDir, "nuclei.jsonl"), []byte(nuclei), 0644); err != nil {
+		t.Fatal(err)
+	}
+	summaryBefore, _ := ioutil.ReadFile(filepath.Join(runDir, "summary.json"))
+
+	offlineMode = true
+	baselineDir = baseDir
+	outDir := filepath.Join(runDir, "reprocess_test")
+	if err := os.Mkdir(outDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := reprocessRun(runDir, outDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// The shared client and resolver refuse to dial while offline.
+	client, err := newHTTPClient(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(origin + "/"); !errors.Is(err, errOfflineDial) {
+		t.Errorf("offline GET: err = %v, want %v", err, errOfflineDial)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := newResolver().LookupHost(ctx, "app.example.com"); err == nil {
+		t.Error("offline lookup succeeded")
+	}
+
+	if n := c.connections(t); n != 0 {
+		t.Errorf("canary saw %d connection(s) in offline mode", n)
+	}
+
+	var result ScanResult
+	data, err := ioutil.ReadFile(filepath.Join(outDir, "summary.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.VulnURLs) != 2 {
+		t.Fatalf("%d findings after reprocessing, want the summary's and nuclei's: %+v", len(result.VulnURLs), result.VulnURLs)
+	}
+	for _, v := range result.VulnURLs {
+		if v.CWE == 0 && v.Type == "sqli" {
+			t.Errorf("finding not re-classified: %+v", v)
+		}
+	}
+	for _, want := range []string{
+		"Final report for example.com",
+		"Since baseline " + baseDir + ": 1 new subdomain(s), 2 new URL(s), 1 new finding(s)",
+		"nuclei.jsonl   1 finding(s), 1 not in the summary",
+	} {
+		if !strings.Contains(result.FinalReport, want) {
+			t.Errorf("report lacks %q:\n%s", want, result.FinalReport)
+		}
+	}
+	for _, f := range []string{"vulnerabilities.json", "defectdojo.json"} {
+		if _, err := os.Stat(filepath.Join(outDir, f)); err != nil {
+			t.Error(err)
+		}
+	}
+	if after, _ := ioutil.ReadFile(filepath.Join(runDir, "summary.json")); !bytes.Equal(after, summaryBefore) {
+		t.Error("reprocessing modified the run's summary.json")
+	}
+}
+
+// TestReprocessDamagedSummary reprocesses a run whose summary.json was cut
+// off in its findings and checks the findings come back from
+// vulnerabilities.json and the report says what was recovered and lost.
+func TestReprocessDamagedSummary(t *testing.T) {
+	savedOffline, savedBaselineDir, savedBaseline, savedResult := offlineMode, baselineDir, baseline, scanResult
+	defer func() {
+		offlineMode, baselineDir, baseline, scanResult = savedOffline, savedBaselineDir, savedBaseline, savedResult
+	}()
+	offlineMode, baselineDir, baseline = true, "", nil
+
+	runDir := filepath.Join(t.TempDir(), "example.com_20261008_120000")
+	if err := os.Mkdir(runDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	vulns := []VulnerabilityResult{{URL: "https://app.example.com/item?id=1", Issue: "SQL Injection", Type: "sqli"}}
+	summary, _ := json.MarshalIndent(ScanResult{
+		Subdomains: []SubdomainResult{{Hostname: "app.example.com", IP: "192.0.2.10"}},
+		VulnURLs:   vulns,
+	}, "", "  ")
+	cut := bytes.Index(summary, []byte(`"SQL Injection"`))
+	if err := ioutil.WriteFile(filepath.Join(runDir, "summary.json"), summary[:cut], 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeJSON(t, filepath.Join(runDir, "vulnerabilities.json"), vulns)
+
+	outDir := filepath.Join(runDir, "reprocess_test")
+	if err := os.Mkdir(outDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := reprocessRun(runDir, outDir); err != nil {
+		t.Fatal(err)
+	}
+	if len(scanResult.Subdomains) != 1 || len(scanResult.VulnURLs) != 1 || scanResult.VulnURLs[0].CWE == 0 {
+		t.Errorf("salvaged %d subdomain(s), findings %+v", len(scanResult.Subdomains), scanResult.VulnURLs)
+	}
+	for _, want := range []string{
+		"    ! " + filepath.Join(runDir, "summary.json") + " is damaged (unexpected end of JSON input); going on with what could be salvaged\n",
+		"    ! recovered: subdomains (1)\n",
+		"    ! rebuilt: vuln_urls (1, from vulnerabilities.json)\n",
+		"    ! lost: ffuf_entries, all_urls, ",
+	} {
+		if !strings.Contains(scanResult.FinalReport, want) {
+			t.Errorf("report lacks %q:\n%s", want, scanResult.FinalReport)
+		}
+	}
+}
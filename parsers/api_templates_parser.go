@@ -0,0 +1,58 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// ParseAPITemplates reads captured API requests: a JSON array of
+// {method, url, headers, body} objects or one such object per line.
+// Requests without an absolute http or https URL are counted as malformed
+// and skipped; a missing method is taken as POST.
+func ParseAPITemplates(data []byte) ([]types.APIRequestTemplate, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, errors.New("API templates: file is empty")
+	}
+	var raw []types.APIRequestTemplate
+	bad := 0
+	if trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &raw); err != nil {
+			return nil, fmt.Errorf("API templates: %v", err)
+		}
+	} else {
+		utils.ForEachLine("API templates", string(trimmed), func(line string, _ bool) {
+			if line = strings.TrimSpace(line); line == "" {
+				return
+			}
+			var t types.APIRequestTemplate
+			if err := json.Unmarshal([]byte(line), &t); err != nil {
+				bad++
+				return
+			}
+			raw = append(raw, t)
+		})
+	}
+	var templates []types.APIRequestTemplate
+	for _, t := range raw {
+		if u, err := url.Parse(t.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			bad++
+			continue
+		}
+		if t.Method = strings.ToUpper(strings.TrimSpace(t.Method)); t.Method == "" {
+			t.Method = "POST"
+		}
+		templates = append(templates, t)
+	}
+	if bad > 0 {
+		return templates, fmt.Errorf("API templates: %d malformed request(s) skipped", bad)
+	}
+	return templates, nil
+}
@@ -0,0 +1,37 @@
+package parsers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAPITemplates(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		methods []string
+		err     string
+	}{
+		{"array", `[{"method":"post","url":"https://api.example.com/login","body":{"user":"a"}},{"url":"http://api.example.com/search","body":"{\"q\":\"x\"}"}]`,
+			[]string{"POST", "POST"}, ""},
+		{"one per line", "{\"method\":\"PUT\",\"url\":\"https://api.example.com/users/1\",\"body\":{}}\n\n{\"method\":\"patch \",\"url\":\"https://api.example.com/users/2\"}\n",
+			[]string{"PUT", "PATCH"}, ""},
+		{"malformed lines", "{\"url\":\"https://api.example.com/a\"}\nnot json\n{\"url\":\"/relative\"}\n{\"url\":\"ftp://files.example.com/\"}\n",
+			[]string{"POST"}, "3 malformed request(s) skipped"},
+		{"empty", "  \n", nil, "file is empty"},
+		{"broken array", `[{"url":`, nil, "API templates"},
+	}
+	for _, tt := range tests {
+		got, err := ParseAPITemplates([]byte(tt.data))
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+		var methods []string
+		for _, tmpl := range got {
+			methods = append(methods, tmpl.Method)
+		}
+		if strings.Join(methods, ",") != strings.Join(tt.methods, ",") {
+			t.Errorf("%s: methods %q, want %q", tt.name, methods, tt.methods)
+		}
+	}
+}
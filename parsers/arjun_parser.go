@@ -0,0 +1,48 @@
+package parsers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// arjunTarget is one value of Arjun's -oJ output. Arjun 2 writes an object
+// per URL; older releases map each URL straight to its parameter list.
+type arjunTarget struct {
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+}
+
+// ParseArjunJSON reads Arjun's -oJ output, a map of URL to the parameters
+// found, sorted by URL. URLs without parameters are left out.
+func ParseArjunJSON(data []byte) ([]types.EndpointParameters, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	var results []types.EndpointParameters
+	bad := 0
+	for u, v := range raw {
+		var params []string
+		if err := json.Unmarshal(v, &params); err != nil {
+			var t arjunTarget
+			if err := json.Unmarshal(v, &t); err != nil {
+				bad++
+				continue
+			}
+			params = t.Params
+		}
+		if len(params) == 0 {
+			continue
+		}
+		sort.Strings(params)
+		results = append(results, types.EndpointParameters{URL: u, Params: params})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].URL < results[j].URL })
+	if bad > 0 {
+		return results, fmt.Errorf("arjun output: %d malformed target(s) skipped", bad)
+	}
+	return results, nil
+}
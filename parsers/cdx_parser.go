@@ -0,0 +1,53 @@
+package parsers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// cdxTimestamp is the layout of Wayback Machine capture timestamps.
+const cdxTimestamp = "20060102150405"
+
+// ParseCDXPage reads one page of Wayback CDX output requested with
+// fl=timestamp,original and showResumeKey=true: a "timestamp url" line per
+// capture and, when more pages follow, a blank line and the resume key.
+// Malformed lines are counted and returned as an error alongside the
+// parsed records.
+func ParseCDXPage(body string) (records []types.URLRecord, resumeKey string, err error) {
+	bad := 0
+	afterBlank := false
+	utils.ForEachLine("wayback", body, func(line string, _ bool) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			afterBlank = true
+			return
+		}
+		if afterBlank {
+			resumeKey = line
+			return
+		}
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			bad++
+			return
+		}
+		captured, err := time.Parse(cdxTimestamp, parts[0])
+		if err != nil {
+			bad++
+			return
+		}
+		records = append(records, types.URLRecord{
+			URL:      strings.TrimSpace(parts[1]),
+			Source:   "wayback",
+			Captured: captured.UTC(),
+		})
+	})
+	if bad > 0 {
+		return records, resumeKey, fmt.Errorf("wayback CDX output: %d malformed line(s) skipped", bad)
+	}
+	return records, resumeKey, nil
+}
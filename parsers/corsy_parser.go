@@ -0,0 +1,52 @@
+package parsers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// corsyFinding is one entry of Corsy's JSON output, keyed by origin.
+type corsyFinding struct {
+	Class       string `json:"class"`
+	Description string `json:"description"`
+	Severity    string `json:"severity"`
+	ACAO        string `json:"acao header"`
+	ACAC        string `json:"acac header"`
+}
+
+// ParseCorsyOutput converts Corsy's JSON output (origin -> finding) into
+// CORS misconfiguration results, ordered by origin.
+func ParseCorsyOutput(data []byte) ([]types.VulnerabilityResult, error) {
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil, errors.New("corsy output is empty")
+	}
+	var findings map[string]corsyFinding
+	if err := json.Unmarshal(data, &findings); err != nil {
+		return nil, fmt.Errorf("corsy output: %v", err)
+	}
+	origins := make([]string, 0, len(findings))
+	for origin := range findings {
+		origins = append(origins, origin)
+	}
+	sort.Strings(origins)
+	var results []types.VulnerabilityResult
+	for _, origin := range origins {
+		f := findings[origin]
+		detail := f.Class + ": " + f.Description
+		if f.ACAO != "" {
+			detail += fmt.Sprintf(" (ACAO: %s, ACAC: %s)", f.ACAO, f.ACAC)
+		}
+		results = append(results, types.VulnerabilityResult{
+			URL:    origin,
+			Issue:  "CORS Misconfiguration",
+			Type:   "cors-misconfiguration",
+			Detail: detail,
+		})
+	}
+	return results, nil
+}
@@ -0,0 +1,52 @@
+package parsers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestParseCorsyOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []types.VulnerabilityResult
+		wantErr string
+	}{
+		{name: "empty", data: " \n", wantErr: "corsy output is empty"},
+		{name: "not JSON", data: "[*] Corsy v1.0\n", wantErr: "corsy output:"},
+		{name: "wrong shape", data: `["https://example.com"]`, wantErr: "corsy output:"},
+		{name: "nothing found", data: "{}"},
+		{
+			name: "ordered by origin, headers when present",
+			data: `{
+  "https://www.example.com": {"class": "origin reflected", "description": "This host allows any origin to make requests to it.", "severity": "high", "acao header": "https://evil.com", "acac header": "true"},
+  "https://api.example.com": {"class": "null origin allowed", "description": "This host allows requests from 'null' origin.", "severity": "high"}
+}`,
+			want: []types.VulnerabilityResult{
+				{URL: "https://api.example.com", Issue: "CORS Misconfiguration", Type: "cors-misconfiguration",
+					Detail: "null origin allowed: This host allows requests from 'null' origin."},
+				{URL: "https://www.example.com", Issue: "CORS Misconfiguration", Type: "cors-misconfiguration",
+					Detail: "origin reflected: This host allows any origin to make requests to it. (ACAO: https://evil.com, ACAC: true)"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseCorsyOutput([]byte(tt.data))
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+}
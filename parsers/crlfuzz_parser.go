@@ -0,0 +1,109 @@
+package parsers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// crlfuzzResult is one line of crlfuzz output written as JSON.
+type crlfuzzResult struct {
+	URL     string `json:"url"`
+	Target  string `json:"target"`
+	Payload string `json:"payload"`
+}
+
+// crlfuzzSplit separates a vulnerable URL crlfuzz built into the target it
+// was given and the payload it appended. Targets are matched longest
+// first; a URL matching none is cut at the last slash before its first
+// escape.
+func crlfuzzSplit(u string, targets []string) (base, payload string) {
+	for _, t := range targets {
+		t = strings.TrimSuffix(t, "/")
+		if t != "" && strings.HasPrefix(u, t) && len(u) > len(t) {
+			return t, strings.TrimPrefix(u[len(t):], "/")
+		}
+	}
+	cut := strings.IndexAny(u, `%\`)
+	if cut < 0 {
+		return u, ""
+	}
+	if i := strings.LastIndex(u[:cut], "/"); i > strings.Index(u, "://")+2 {
+		cut = i
+	}
+	return u[:cut], strings.TrimPrefix(u[cut:], "/")
+}
+
+// ParseCrlfuzzOutput reads crlfuzz results, "[VLN] <url>" lines, bare URLs
+// as written by -o, or JSON objects, into "CRLF Injection" findings. The
+// findings for one target collapse into one listing every payload that
+// worked. targets are the URLs crlfuzz was given. Lines that are neither
+// results nor crlfuzz's own [ERR] and status messages are counted as
+// malformed.
+func ParseCrlfuzzOutput(output string, targets []string) ([]types.VulnerabilityResult, error) {
+	sorted := append([]string(nil), targets...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	payloads := make(map[string][]string)
+	var order []string
+	bad := 0
+	add := func(base, payload string) {
+		if _, ok := payloads[base]; !ok {
+			order = append(order, base)
+		}
+		for _, p := range payloads[base] {
+			if p == payload {
+				return
+			}
+		}
+		if payload != "" {
+			payloads[base] = append(payloads[base], payload)
+		}
+	}
+	utils.ForEachLine("crlfuzz", output, func(line string, _ bool) {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "{"):
+			var r crlfuzzResult
+			if err := json.Unmarshal([]byte(line), &r); err != nil || r.URL == "" {
+				bad++
+				return
+			}
+			if r.Target != "" && r.Payload != "" {
+				add(strings.TrimSuffix(r.Target, "/"), r.Payload)
+				return
+			}
+			add(crlfuzzSplit(r.URL, sorted))
+		case strings.HasPrefix(line, "[VLN]"):
+			add(crlfuzzSplit(strings.TrimSpace(strings.TrimPrefix(line, "[VLN]")), sorted))
+		case strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://"):
+			add(crlfuzzSplit(line, sorted))
+		case strings.HasPrefix(line, "["):
+			// [ERR], [INF] and similar status lines.
+		default:
+			bad++
+		}
+	})
+	var results []types.VulnerabilityResult
+	for _, base := range order {
+		v := types.VulnerabilityResult{
+			URL:    base,
+			Issue:  "CRLF Injection",
+			Type:   "crlf-injection",
+			Detail: "crlfuzz injected a header",
+		}
+		if ps := payloads[base]; len(ps) > 0 {
+			v.Payload = ps[0]
+			v.Detail = fmt.Sprintf("crlfuzz injected a header with %d payload(s): %s", len(ps), strings.Join(ps, ", "))
+		}
+		results = append(results, v)
+	}
+	if bad > 0 {
+		return results, fmt.Errorf("crlfuzz output: %d malformed line(s) skipped", bad)
+	}
+	return results, nil
+}
@@ -1,29 +1,140 @@
 package parsers
 
 import (
-	"bufio"
+	"encoding/json"
+	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
-	"recon-tool/main"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
 )
 
-func ParseDalfoxOutput(output string) []main.VulnerabilityResult {
-	var results []main.VulnerabilityResult
-	scanner := bufio.NewScanner(strings.NewReader(output))
+// ParseDalfoxOutput extracts XSS findings from the [POC] lines of dalfox's
+// plain output, for releases without JSON output.
+func ParseDalfoxOutput(output string) []types.VulnerabilityResult {
+	var results []types.VulnerabilityResult
 	re := regexp.MustCompile(`(http[s]?://[^\s]+)`)
-	for scanner.Scan() {
-		line := scanner.Text()
+	typeRe := regexp.MustCompile(`\[POC\]\[([VRG])\]`)
+	utils.ForEachLine("dalfox", output, func(line string, truncated bool) {
 		if strings.Contains(line, "[POC]") {
 			match := re.FindStringSubmatch(line)
 			if len(match) > 1 {
-				results = append(results, main.VulnerabilityResult{
-					URL:    match[1],
-					Issue:  "XSS",
-					Detail: line,
+				if truncated {
+					line += " [truncated]"
+				}
+				pocType := ""
+				if m := typeRe.FindStringSubmatch(line); m != nil {
+					pocType = m[1]
+				}
+				results = append(results, types.VulnerabilityResult{
+					URL:        match[1],
+					Issue:      "XSS",
+					Type:       "xss",
+					Detail:     line,
+					Confidence: dalfoxConfidence(pocType),
 				})
 			}
 		}
-	}
+	})
 	return results
 }
+
+// dalfoxPoC is one entry of dalfox's --format json output. Type is V for
+// a payload verified to execute, R for a reflected one and G for a grep
+// pattern match.
+type dalfoxPoC struct {
+	Type       string `json:"type"`
+	InjectType string `json:"inject_type"`
+	Method     string `json:"method"`
+	Data       string `json:"data"`
+	Param      string `json:"param"`
+	Payload    string `json:"payload"`
+	CWE        string `json:"cwe"`
+	Severity   string `json:"severity"`
+	Message    string `json:"message_str"`
+}
+
+// dalfoxSeverity ranks a PoC by how sure dalfox is: a verified payload
+// keeps dalfox's own rating, a reflection is medium and a grep match low.
+func dalfoxSeverity(p dalfoxPoC) string {
+	switch p.Type {
+	case "V":
+		if p.Severity != "" {
+			return strings.ToLower(p.Severity)
+		}
+		return "high"
+	case "R":
+		return "medium"
+	}
+	return "low"
+}
+
+// dalfoxConfidence rates a PoC type: a payload seen executing is confirmed,
+// a reflection firm and a grep match tentative.
+func dalfoxConfidence(typ string) string {
+	switch typ {
+	case "V":
+		return utils.ConfidenceConfirmed
+	case "R":
+		return utils.ConfidenceFirm
+	}
+	return utils.ConfidenceTentative
+}
+
+// ParseDalfoxJSON converts dalfox --format json output into findings: XSS
+// for verified and reflected payloads, "Dalfox Grep Match" for pattern
+// matches, which are not injections. It accepts both the plain array of
+// PoCs and the --report document that carries them under "pocs". URL is
+// the PoC URL dalfox built.
+func ParseDalfoxJSON(data []byte) ([]types.VulnerabilityResult, error) {
+	var pocs []dalfoxPoC
+	if err := json.Unmarshal(data, &pocs); err != nil {
+		var report struct {
+			PoCs []dalfoxPoC `json:"pocs"`
+		}
+		if err2 := json.Unmarshal(data, &report); err2 != nil {
+			return nil, fmt.Errorf("dalfox output: %v", err)
+		}
+		pocs = report.PoCs
+	}
+	var results []types.VulnerabilityResult
+	bad := 0
+	for _, p := range pocs {
+		if p.Data == "" || p.Type == "" {
+			bad++
+			continue
+		}
+		v := types.VulnerabilityResult{
+			URL:        p.Data,
+			Issue:      "XSS",
+			Type:       "xss",
+			Severity:   dalfoxSeverity(p),
+			Confidence: dalfoxConfidence(p.Type),
+			Technique:  p.InjectType,
+			Payload:    p.Payload,
+		}
+		if p.Param != "" {
+			v.Parameter = p.Param
+			if p.Method != "" {
+				v.Parameter += " (" + p.Method + ")"
+			}
+		}
+		if n, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(p.CWE), "CWE-")); err == nil {
+			v.CWE = n
+		}
+		v.Detail = strings.TrimSpace(fmt.Sprintf("dalfox [%s] %s", p.Type, p.Message))
+		if p.Message == "" {
+			v.Detail = strings.TrimSpace(fmt.Sprintf("dalfox [%s] %s", p.Type, p.InjectType))
+		}
+		if p.Type == "G" {
+			v.Issue, v.Type = "Dalfox Grep Match", "dalfox-grep"
+		}
+		results = append(results, v)
+	}
+	if bad > 0 {
+		return results, fmt.Errorf("dalfox output: %d malformed PoC(s) skipped", bad)
+	}
+	return results, nil
+}
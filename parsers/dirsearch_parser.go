@@ -0,0 +1,69 @@
+package parsers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// dirsearchEntry is one result of a dirsearch --format json report. Since
+// 0.4.3 entries carry the full URL; older releases group entries under
+// their base URL and give the path instead.
+type dirsearchEntry struct {
+	URL           string `json:"url"`
+	Path          string `json:"path"`
+	Status        int    `json:"status"`
+	ContentLength int    `json:"content-length"`
+	Redirect      string `json:"redirect"`
+}
+
+// ParseDirsearchReport reads a dirsearch JSON report into FfufResult
+// entries, in either layout. Like ffuf's FUZZ value, Path carries no
+// leading slash. Malformed entries are counted and returned as an error
+// alongside the parsed results.
+func ParseDirsearchReport(data []byte) ([]types.FfufResult, error) {
+	var doc struct {
+		Results []json.RawMessage `json:"results"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("dirsearch report: %v", err)
+	}
+	var results []types.FfufResult
+	bad := 0
+	add := func(base string, e dirsearchEntry) {
+		if e.Status == 0 {
+			bad++
+			return
+		}
+		r := gobusterResult(e.Path, e.Status, e.ContentLength, e.Redirect)
+		if e.URL != "" {
+			r = gobusterResult(e.URL, e.Status, e.ContentLength, e.Redirect)
+		} else if base != "" {
+			r.URL = strings.TrimSuffix(base, "/") + "/" + r.Path
+		}
+		results = append(results, r)
+	}
+	for _, raw := range doc.Results {
+		var e dirsearchEntry
+		if err := json.Unmarshal(raw, &e); err == nil && (e.URL != "" || e.Path != "") {
+			add("", e)
+			continue
+		}
+		var grouped map[string][]dirsearchEntry
+		if err := json.Unmarshal(raw, &grouped); err != nil {
+			bad++
+			continue
+		}
+		for base, entries := range grouped {
+			for _, e := range entries {
+				add(base, e)
+			}
+		}
+	}
+	if bad > 0 {
+		return results, fmt.Errorf("dirsearch report: %d malformed result(s) skipped", bad)
+	}
+	return results, nil
+}
@@ -0,0 +1,82 @@
+package parsers
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestParseDirsearchReport(t *testing.T) {
+	tests := []struct {
+		name    string
+		report  string
+		want    []types.FfufResult
+		wantErr bool
+	}{
+		{"no results", `{"info": {"args": "dirsearch -u https://www.example.com"}, "results": []}`, nil, false},
+		{
+			"0.4.3 layout with full URLs",
+			`{"info": {"time": "2024-05-01 10:00:00"}, "results": [
+{"url": "https://www.example.com/admin/", "status": 301, "content-length": 0, "content-type": "text/html", "redirect": "https://www.example.com/admin/login"},
+{"url": "https://www.example.com/.env", "status": 200, "content-length": 412, "content-type": "text/plain", "redirect": ""},
+{"url": "https://www.example.com/api", "status": 429, "content-length": 17, "redirect": ""}
+]}`,
+			[]types.FfufResult{
+				{Path: "admin/", Status: 301, URL: "https://www.example.com/admin/", RedirectLocation: "https://www.example.com/admin/login"},
+				{Path: ".env", Status: 200, Size: 412, URL: "https://www.example.com/.env"},
+				{Path: "api", Status: 429, Size: 17, URL: "https://www.example.com/api"},
+			},
+			false,
+		},
+		{
+			"older layout grouped by base URL",
+			`{"time": "Wed May  1 10:00:00 2024", "results": [
+{"https://www.example.com/": [
+{"status": 403, "path": "/server-status", "content-length": 280, "redirect": null},
+{"status": 200, "path": "robots.txt", "content-length": 68, "redirect": null}
+]}
+]}`,
+			[]types.FfufResult{
+				{Path: "server-status", Status: 403, Size: 280, URL: "https://www.example.com/server-status"},
+				{Path: "robots.txt", Status: 200, Size: 68, URL: "https://www.example.com/robots.txt"},
+			},
+			false,
+		},
+		{
+			"entries without a status are counted, the rest kept",
+			`{"results": [
+{"url": "https://www.example.com/backup.zip", "status": 200, "content-length": 1024},
+{"url": "https://www.example.com/half"},
+"not an entry"
+]}`,
+			[]types.FfufResult{{Path: "backup.zip", Status: 200, Size: 1024, URL: "https://www.example.com/backup.zip"}},
+			true,
+		},
+		{"not a report", `<html>`, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDirsearchReport([]byte(tt.report))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+
+	// Grouped entries of several base URLs all come through.
+	got, err := ParseDirsearchReport([]byte(`{"results": [{"https://a.example.com": [{"status": 200, "path": "x"}], "https://b.example.com/": [{"status": 200, "path": "/y"}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for _, r := range got {
+		urls = append(urls, r.URL)
+	}
+	sort.Strings(urls)
+	if want := []string{"https://a.example.com/x", "https://b.example.com/y"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("grouped URLs %q, want %q", urls, want)
+	}
+}
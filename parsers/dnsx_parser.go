@@ -0,0 +1,55 @@
+package parsers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// dnsxEntry is one line of dnsx -json output.
+type dnsxEntry struct {
+	Host       string   `json:"host"`
+	A          []string `json:"a"`
+	AAAA       []string `json:"aaaa"`
+	CNAME      []string `json:"cname"`
+	StatusCode string   `json:"status_code"`
+}
+
+// ParseDnsxOutput converts dnsx -json output into DNS records, one per
+// host that resolved. Hosts answered with an error status or without any
+// record are left out; malformed JSON lines are counted and returned as an
+// error alongside the parsed records.
+func ParseDnsxOutput(output string) ([]types.DNSRecord, error) {
+	var records []types.DNSRecord
+	bad := 0
+	utils.ForEachLine("dnsx", output, func(line string, _ bool) {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			return
+		}
+		var e dnsxEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			bad++
+			return
+		}
+		if e.Host == "" || (e.StatusCode != "" && e.StatusCode != "NOERROR") {
+			return
+		}
+		if len(e.A)+len(e.AAAA)+len(e.CNAME) == 0 {
+			return
+		}
+		records = append(records, types.DNSRecord{
+			Host:  utils.NormalizeHostname(e.Host),
+			A:     e.A,
+			AAAA:  e.AAAA,
+			CNAME: e.CNAME,
+		})
+	})
+	if bad > 0 {
+		return records, fmt.Errorf("dnsx output: %d malformed JSON line(s) skipped", bad)
+	}
+	return records, nil
+}
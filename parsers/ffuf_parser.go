@@ -0,0 +1,115 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// ffufEntry is one element of the "results" array ffuf writes with -of json.
+type ffufEntry struct {
+	Input  map[string]string `json:"input"`
+	Status int               `json:"status"`
+	Length int               `json:"length"`
+	Words  int               `json:"words"`
+	Lines  int               `json:"lines"`
+	URL    string            `json:"url"`
+	// RedirectLocation is set on 3xx results.
+	RedirectLocation string `json:"redirectlocation,omitempty"`
+}
+
+// toFfufResult converts an entry, using the FUZZ keyword value as the path.
+func (e ffufEntry) toFfufResult() types.FfufResult {
+	path, ok := e.Input["FUZZ"]
+	if !ok {
+		if u, err := url.Parse(e.URL); err == nil {
+			path = u.Path
+		}
+	}
+	return types.FfufResult{
+		Path:             path,
+		Status:           e.Status,
+		Size:             e.Length,
+		Words:            e.Words,
+		Lines:            e.Lines,
+		URL:              e.URL,
+		RedirectLocation: e.RedirectLocation,
+	}
+}
+
+// MarshalFfufResults renders results as an ffuf JSON output document, so
+// results from other fuzzers read back through ParseFfufOutput.
+func MarshalFfufResults(results []types.FfufResult) ([]byte, error) {
+	doc := struct {
+		Results []ffufEntry `json:"results"`
+	}{Results: []ffufEntry{}}
+	for _, r := range results {
+		doc.Results = append(doc.Results, ffufEntry{
+			Input:            map[string]string{"FUZZ": strings.TrimPrefix(r.Path, "/")},
+			Status:           r.Status,
+			Length:           r.Size,
+			Words:            r.Words,
+			Lines:            r.Lines,
+			URL:              r.URL,
+			RedirectLocation: r.RedirectLocation,
+		})
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// ParseFfufOutput reads ffuf's JSON output document. An empty file yields
+// no results. The results array is decoded entry by entry, so when ffuf was
+// killed mid-write the complete entries are returned along with an error.
+func ParseFfufOutput(data []byte) ([]types.FfufResult, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, errors.New("ffuf output: not a JSON object")
+	}
+	var results []types.FfufResult
+	truncated := func(err error) ([]types.FfufResult, error) {
+		return results, fmt.Errorf("ffuf output truncated after %d result(s): %v", len(results), err)
+	}
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return truncated(err)
+		}
+		if key, _ := keyTok.(string); key != "results" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return truncated(err)
+			}
+			continue
+		}
+		tok, err := dec.Token()
+		if err != nil {
+			return truncated(err)
+		}
+		if tok == nil {
+			// "results": null when nothing matched.
+			continue
+		}
+		if tok != json.Delim('[') {
+			return results, errors.New("ffuf output: results is not an array")
+		}
+		for dec.More() {
+			var e ffufEntry
+			if err := dec.Decode(&e); err != nil {
+				return truncated(err)
+			}
+			results = append(results, e.toFfufResult())
+		}
+		if _, err := dec.Token(); err != nil {
+			return truncated(err)
+		}
+	}
+	return results, nil
+}
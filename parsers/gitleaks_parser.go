@@ -0,0 +1,91 @@
+package parsers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// gitleaksFinding is the subset of a gitleaks --report-format json entry
+// the tool uses. Secret is read only to be redacted; it is never stored.
+type gitleaksFinding struct {
+	RuleID      string `json:"RuleID"`
+	Description string `json:"Description"`
+	File        string `json:"File"`
+	StartLine   int    `json:"StartLine"`
+	Commit      string `json:"Commit"`
+	Secret      string `json:"Secret"`
+}
+
+// ParseGitleaksReport converts a gitleaks detect JSON report into "Exposed
+// Secret" findings whose URL is the file within the repository; the caller
+// prefixes the repository's URL. Findings are deduplicated on rule and
+// file, keeping the first commit gitleaks reported. Every match is also
+// returned as a secret finding located at the file, line and commit; with
+// verify set, each secret is checked with it and only its outcome kept.
+func ParseGitleaksReport(data []byte, verify func(class, secret string) string) ([]types.VulnerabilityResult, []types.SecretFinding, error) {
+	var findings []gitleaksFinding
+	if err := json.Unmarshal(data, &findings); err != nil {
+		return nil, nil, fmt.Errorf("gitleaks report: %v", err)
+	}
+	var results []types.VulnerabilityResult
+	var secrets []types.SecretFinding
+	seen := make(map[string]bool)
+	outcomes := make(map[string]string)
+	for _, f := range findings {
+		if f.RuleID == "" {
+			continue
+		}
+		commit := f.Commit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		var where []string
+		if f.StartLine > 0 {
+			where = append(where, fmt.Sprintf("line %d", f.StartLine))
+		}
+		if commit != "" {
+			where = append(where, "at commit "+commit)
+		}
+		place := strings.Join(append([]string{f.File}, where...), " ")
+		class := utils.SecretClass(f.RuleID)
+		confidence, verification := utils.ConfidenceFirm, utils.SecretUnverified
+		if verify != nil {
+			// A secret committed once shows up in many commits; it is
+			// checked once.
+			if outcome, ok := outcomes[f.Secret]; ok {
+				verification = outcome
+			} else {
+				verification = verify(class, f.Secret)
+				outcomes[f.Secret] = verification
+			}
+		}
+		loc := types.SecretLocation{Artifact: f.File, Location: strings.Join(where, " "), Detector: "gitleaks " + f.RuleID}
+		secrets = utils.MergeSecrets(secrets, utils.NewSecretFinding(class, f.Secret, loc, confidence, verification))
+		if seen[f.RuleID+"|"+f.File] {
+			continue
+		}
+		seen[f.RuleID+"|"+f.File] = true
+		detail := fmt.Sprintf("gitleaks rule %s matched %s in %s", f.RuleID, utils.MaskSecret(f.Secret), place)
+		if f.Description != "" {
+			detail += " (" + f.Description + ")"
+		}
+		results = append(results, types.VulnerabilityResult{
+			URL:      f.File,
+			Issue:    "Exposed Secret: " + f.RuleID,
+			Type:     "exposed-secret",
+			Detail:   detail,
+			Severity: "high",
+		})
+		if verification == utils.SecretVerified {
+			i := len(results) - 1
+			results[i].Severity = "critical"
+			results[i].Tags = utils.AddTag(results[i].Tags, utils.TagState+":verified")
+			results[i].Detail += "; verified live"
+		}
+	}
+	return results, secrets, nil
+}
@@ -0,0 +1,69 @@
+package parsers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+func TestParseGitleaksReport(t *testing.T) {
+	report, _ := json.Marshal([]map[string]interface{}{
+		{"RuleID": "aws-access-token", "File": "deploy.sh", "StartLine": 9, "Commit": "3f786850e387550fdab836ed7e6dc881de23001b", "Secret": testAWSKey},
+		// The same key in an older commit.
+		{"RuleID": "aws-access-token", "File": "deploy.sh", "StartLine": 4, "Commit": "9a1b2c3d4e5f60718293a4b5c6d7e8f901234567", "Secret": testAWSKey},
+		{"RuleID": "github-pat", "File": "ci/env", "StartLine": 1, "Secret": testGitHubToken, "Description": "GitHub Personal Access Token"},
+		{"RuleID": "", "File": "ignored", "Secret": "whatever"},
+	})
+	var checked []string
+	verify := func(class, secret string) string {
+		checked = append(checked, class)
+		if secret == testGitHubToken {
+			return utils.SecretVerified
+		}
+		return utils.SecretRejected
+	}
+	findings, secrets, err := ParseGitleaksReport(report, verify)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("%d finding(s): %+v", len(findings), findings)
+	}
+	if f := findings[0]; f.URL != "deploy.sh" || f.Issue != "Exposed Secret: aws-access-token" || f.Severity != "high" ||
+		f.Detail != "gitleaks rule aws-access-token matched AKIA********XK8M (20 chars) in deploy.sh line 9 at commit 3f786850e387" {
+		t.Errorf("AWS finding %+v", f)
+	}
+	if f := findings[1]; !utils.IsVerifiedSecret(f) || f.Severity != "critical" ||
+		f.Detail != "gitleaks rule github-pat matched ghp_********5oTi (40 chars) in ci/env line 1 (GitHub Personal Access Token); verified live" {
+		t.Errorf("GitHub finding %+v", f)
+	}
+	// Each secret is checked once, however many commits hold it.
+	if strings.Join(checked, ",") != "aws,github" {
+		t.Errorf("verified %q", checked)
+	}
+	if len(secrets) != 2 {
+		t.Fatalf("%d secret(s): %+v", len(secrets), secrets)
+	}
+	aws := secrets[0]
+	if aws.Class != "aws" || aws.Verification != utils.SecretRejected || aws.Confidence != utils.ConfidenceFirm ||
+		len(aws.Locations) != 2 || aws.Locations[1] != (types.SecretLocation{Artifact: "deploy.sh", Location: "line 4 at commit 9a1b2c3d4e5f", Detector: "gitleaks aws-access-token"}) {
+		t.Errorf("AWS secret %+v", aws)
+	}
+	if gh := secrets[1]; gh.Class != "github" || gh.Verification != utils.SecretVerified || gh.Confidence != utils.ConfidenceConfirmed {
+		t.Errorf("GitHub secret %+v", gh)
+	}
+	assertNoRawSecrets(t, "gitleaks findings", findings, testAWSKey, testGitHubToken)
+	assertNoRawSecrets(t, "gitleaks secrets", secrets, testAWSKey, testGitHubToken)
+
+	// Without verification nothing is checked.
+	_, secrets, _ = ParseGitleaksReport(report, nil)
+	if secrets[1].Verification != utils.SecretUnverified || secrets[1].Confidence != utils.ConfidenceFirm {
+		t.Errorf("unverified GitHub secret %+v", secrets[1])
+	}
+	if _, _, err := ParseGitleaksReport([]byte("not json"), nil); err == nil || !strings.HasPrefix(err.Error(), "gitleaks report: ") {
+		t.Errorf("malformed report: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package parsers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// gobusterLineRe matches gobuster dir result lines such as
+// `/admin                (Status: 301) [Size: 178] [--> http://host/admin/]`.
+// Older releases omit the size and redirect, and -e prints full URLs.
+var gobusterLineRe = regexp.MustCompile(`^(\S+)\s+\(Status:\s*(\d+)\)(?:\s*\[Size:\s*(\d+)\])?(?:\s*\[-->\s*([^\]]+?)\s*\])?`)
+
+// gobusterJSONEntry is one JSON line of gobuster output. Field names vary
+// between forks, so the common spellings are all accepted.
+type gobusterJSONEntry struct {
+	Path     string `json:"path"`
+	URL      string `json:"url"`
+	Status   int    `json:"status"`
+	Size     int    `json:"size"`
+	Length   int    `json:"length"`
+	Redirect string `json:"redirect"`
+	Location string `json:"location"`
+}
+
+// gobusterResult builds an FfufResult from a gobuster path, which may be a
+// full URL when gobuster ran with -e. Like ffuf's FUZZ value, Path carries
+// no leading slash.
+func gobusterResult(path string, status, size int, redirect string) types.FfufResult {
+	r := types.FfufResult{Path: path, Status: status, Size: size, RedirectLocation: redirect}
+	if u, err := url.Parse(path); err == nil && u.Scheme != "" && u.Host != "" {
+		r.URL = path
+		r.Path = u.Path
+	}
+	r.Path = strings.TrimPrefix(r.Path, "/")
+	return r
+}
+
+// ParseGobusterOutput reads gobuster dir output, either its plain result
+// lines or JSON lines, into FfufResult entries. Progress and banner lines
+// are skipped; malformed JSON lines are counted and returned as an error
+// alongside the parsed results.
+func ParseGobusterOutput(output string) ([]types.FfufResult, error) {
+	var results []types.FfufResult
+	bad := 0
+	utils.ForEachLine("gobuster", output, func(line string, _ bool) {
+		line = strings.TrimSpace(ansiRe.ReplaceAllString(line, ""))
+		if strings.HasPrefix(line, "{") {
+			var e gobusterJSONEntry
+			if err := json.Unmarshal([]byte(line), &e); err != nil {
+				bad++
+				return
+			}
+			path := e.Path
+			if path == "" {
+				path = e.URL
+			}
+			if path == "" || e.Status == 0 {
+				return
+			}
+			size := e.Size
+			if size == 0 {
+				size = e.Length
+			}
+			redirect := e.Redirect
+			if redirect == "" {
+				redirect = e.Location
+			}
+			results = append(results, gobusterResult(path, e.Status, size, redirect))
+			return
+		}
+		m := gobusterLineRe.FindStringSubmatch(line)
+		if m == nil {
+			return
+		}
+		status, _ := strconv.Atoi(m[2])
+		size, _ := strconv.Atoi(m[3])
+		results = append(results, gobusterResult(m[1], status, size, m[4]))
+	})
+	if bad > 0 {
+		return results, fmt.Errorf("gobuster output: %d malformed JSON line(s) skipped", bad)
+	}
+	return results, nil
+}
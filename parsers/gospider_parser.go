@@ -0,0 +1,86 @@
+package parsers
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// gospiderLineRe matches gospider's prefixed output lines such as
+// `[url] - [code-200] - https://host/` or
+// `[linkfinder] - [from: https://host/app.js] - /api/v1`.
+var gospiderLineRe = regexp.MustCompile(`^\[([a-z0-9-]+)\] - (.+)$`)
+
+// GospiderOutput is what ParseGospiderOutput extracts from a gospider run.
+type GospiderOutput struct {
+	// URLs are the pages, forms and links found, JavaScript files included.
+	URLs []string
+	// JSFiles are the JavaScript files found.
+	JSFiles []string
+	// Forms are the form targets found.
+	Forms []string
+	// Subdomains are the hostnames gospider reported as subdomains.
+	Subdomains []string
+}
+
+// gospiderValue returns the value of a gospider line after its tag: the
+// last " - " field, resolved against the "[from: ...]" source when it is a
+// relative link.
+func gospiderValue(rest string) string {
+	fields := strings.Split(rest, " - ")
+	value := strings.TrimSpace(fields[len(fields)-1])
+	for _, f := range fields[:len(fields)-1] {
+		if src := strings.TrimPrefix(strings.TrimSpace(f), "[from: "); src != f {
+			base, err := url.Parse(strings.TrimSuffix(src, "]"))
+			ref, err2 := url.Parse(value)
+			if err == nil && err2 == nil {
+				value = base.ResolveReference(ref).String()
+			}
+		}
+	}
+	return value
+}
+
+// absoluteHTTP reports whether raw is an absolute http or https URL.
+func absoluteHTTP(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// ParseGospiderOutput reads gospider's prefixed output lines. [url],
+// [href], [form], [robots], [sitemap], [linkfinder] and similar lines give
+// URLs; [javascript] lines give JavaScript files and [subdomains] lines
+// hostnames. Links that do not resolve to an absolute URL are dropped.
+func ParseGospiderOutput(output string) GospiderOutput {
+	var out GospiderOutput
+	utils.ForEachLine("gospider", output, func(line string, _ bool) {
+		m := gospiderLineRe.FindStringSubmatch(strings.TrimSpace(ansiRe.ReplaceAllString(line, "")))
+		if m == nil {
+			return
+		}
+		value := gospiderValue(m[2])
+		if m[1] == "subdomains" {
+			if h := utils.NormalizeHostname(value); h != "" {
+				out.Subdomains = append(out.Subdomains, h)
+			}
+			return
+		}
+		if !absoluteHTTP(value) {
+			return
+		}
+		out.URLs = append(out.URLs, value)
+		switch m[1] {
+		case "javascript":
+			out.JSFiles = append(out.JSFiles, value)
+		case "form", "upload-form":
+			out.Forms = append(out.Forms, value)
+		}
+	})
+	out.URLs = utils.UniqueStrings(out.URLs)
+	out.JSFiles = utils.UniqueStrings(out.JSFiles)
+	out.Forms = utils.UniqueStrings(out.Forms)
+	out.Subdomains = utils.UniqueStrings(out.Subdomains)
+	return out
+}
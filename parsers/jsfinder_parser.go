@@ -0,0 +1,83 @@
+package parsers
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// jsfinderSectionRe matches the headers JSFinder prints before its lists,
+// `Find 12 URL:` and `Find 3 Subdomain:`.
+var jsfinderSectionRe = regexp.MustCompile(`(?i)^find \d+ (url|subdomain)s?:?$`)
+
+// jsfinderHostRe matches a bare hostname.
+var jsfinderHostRe = regexp.MustCompile(`^(?i)[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+
+// JSFinderOutput is what ParseJSFinderOutput extracts from a JSFinder run.
+type JSFinderOutput struct {
+	// URLs are the absolute http and https URLs found.
+	URLs []string
+	// Paths are the relative endpoints found, still to be resolved
+	// against a host.
+	Paths []string
+	// Subdomains are the hostnames JSFinder reported as subdomains.
+	Subdomains []string
+}
+
+// ParseJSFinderOutput reads JSFinder's console output, a "Find N URL:"
+// list followed by a "Find N Subdomain:" list. Lines of the URL list are
+// sorted into absolute URLs and relative paths; protocol-relative URLs are
+// taken as https and other schemes (mailto:, data:, javascript:) skipped.
+// Lines outside the lists are JSFinder's progress and error messages.
+// Entries that are neither a URL nor a path, or not a hostname in the
+// subdomain list, are counted as malformed.
+func ParseJSFinderOutput(output string) (JSFinderOutput, error) {
+	var out JSFinderOutput
+	section := ""
+	bad := 0
+	utils.ForEachLine("JSFinder", output, func(line string, _ bool) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return
+		}
+		if m := jsfinderSectionRe.FindStringSubmatch(line); m != nil {
+			section = strings.ToLower(m[1])
+			return
+		}
+		switch section {
+		case "url":
+			u, err := url.Parse(line)
+			switch {
+			case err != nil || strings.ContainsAny(line, " \t"):
+				bad++
+			case absoluteHTTP(line):
+				out.URLs = append(out.URLs, line)
+			case u.Scheme == "" && u.Host != "":
+				// Protocol-relative, as in //cdn.example.com/app.js.
+				out.URLs = append(out.URLs, "https:"+line)
+			case u.Scheme == "" && u.Host == "" && u.Path != "":
+				out.Paths = append(out.Paths, line)
+			case u.Scheme != "":
+				// Not a web endpoint.
+			default:
+				bad++
+			}
+		case "subdomain":
+			if h := utils.NormalizeHostname(line); jsfinderHostRe.MatchString(h) {
+				out.Subdomains = append(out.Subdomains, h)
+			} else {
+				bad++
+			}
+		}
+	})
+	out.URLs = utils.UniqueStrings(out.URLs)
+	out.Paths = utils.UniqueStrings(out.Paths)
+	out.Subdomains = utils.UniqueStrings(out.Subdomains)
+	if bad > 0 {
+		return out, fmt.Errorf("JSFinder output: %d malformed line(s) skipped", bad)
+	}
+	return out, nil
+}
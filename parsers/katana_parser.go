@@ -0,0 +1,67 @@
+package parsers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// katanaEntry is one line of katana -jsonl output. Tag and Attribute name
+// the HTML element and attribute the endpoint was found in, such as
+// "form" and "action".
+type katanaEntry struct {
+	Request struct {
+		Method    string `json:"method"`
+		Endpoint  string `json:"endpoint"`
+		Tag       string `json:"tag"`
+		Attribute string `json:"attribute"`
+	} `json:"request"`
+}
+
+// katanaTakesInput reports whether a crawled endpoint accepts parameters:
+// it is a form target, is requested with a method other than GET, or has a
+// query string.
+func katanaTakesInput(e katanaEntry) bool {
+	if strings.EqualFold(e.Request.Tag, "form") {
+		return true
+	}
+	if m := e.Request.Method; m != "" && !strings.EqualFold(m, "GET") {
+		return true
+	}
+	u, err := url.Parse(e.Request.Endpoint)
+	return err == nil && u.RawQuery != ""
+}
+
+// ParseKatanaOutput reads katana -jsonl output into the endpoints crawled
+// and, of those, the ones that take parameters (see katanaTakesInput).
+// Lines that are not JSON are skipped; malformed JSON lines are counted and
+// returned as an error alongside the parsed endpoints.
+func ParseKatanaOutput(output string) (urls, params []string, err error) {
+	bad := 0
+	utils.ForEachLine("katana", output, func(line string, _ bool) {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			return
+		}
+		var e katanaEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			bad++
+			return
+		}
+		if e.Request.Endpoint == "" {
+			return
+		}
+		urls = append(urls, e.Request.Endpoint)
+		if katanaTakesInput(e) {
+			params = append(params, e.Request.Endpoint)
+		}
+	})
+	urls, params = utils.UniqueStrings(urls), utils.UniqueStrings(params)
+	if bad > 0 {
+		return urls, params, fmt.Errorf("katana output: %d malformed JSON line(s) skipped", bad)
+	}
+	return urls, params, nil
+}
@@ -0,0 +1,52 @@
+package parsers
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// kxssLineRe matches kxss result lines such as
+// `URL: https://x.test/?q=1 Param: q Unfiltered: [" < >]`.
+var kxssLineRe = regexp.MustCompile(`URL:\s*(\S+)\s+Param:\s*(\S+)\s+Unfiltered:\s*\[([^\]]*)\]`)
+
+// ParseKxssOutput extracts reflected parameters from kxss output. Repeated
+// URL and parameter pairs are collapsed into one result whose Detail lists
+// every unfiltered character seen.
+func ParseKxssOutput(output string) []types.VulnerabilityResult {
+	var results []types.VulnerabilityResult
+	index := make(map[string]int)
+	chars := make(map[string][]string)
+	utils.ForEachLine("kxss", output, func(line string, _ bool) {
+		m := kxssLineRe.FindStringSubmatch(line)
+		if m == nil {
+			return
+		}
+		key := m[1] + "|" + m[2]
+		i, seen := index[key]
+		if !seen {
+			i = len(results)
+			index[key] = i
+			results = append(results, types.VulnerabilityResult{URL: m[1], Issue: "Reflected Parameter", Type: "reflected-parameter", Parameter: m[2], Confidence: utils.ConfidenceTentative})
+		}
+		for _, c := range strings.Fields(m[3]) {
+			if !containsChar(chars[key], c) {
+				chars[key] = append(chars[key], c)
+			}
+		}
+		results[i].Detail = "Param: " + m[2] + " Unfiltered: [" + strings.Join(chars[key], " ") + "]"
+	})
+	return results
+}
+
+// containsChar reports whether list contains c.
+func containsChar(list []string, c string) bool {
+	for _, v := range list {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
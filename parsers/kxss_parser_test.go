@@ -0,0 +1,45 @@
+package parsers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestParseKxssOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []types.VulnerabilityResult
+	}{
+		{"empty", "", nil},
+		{"banner and noise only", "kxss v1.0\n\nnot a result line\n", nil},
+		{
+			"one reflection",
+			`URL: https://example.com/search?q=1 Param: q Unfiltered: [" < >]` + "\n",
+			[]types.VulnerabilityResult{{URL: "https://example.com/search?q=1", Issue: "Reflected Parameter", Type: "reflected-parameter", Confidence: "tentative", Parameter: "q", Detail: `Param: q Unfiltered: [" < >]`}},
+		},
+		{
+			"repeats collapse, characters merge in order",
+			`URL: https://example.com/search?q=1&p=2 Param: q Unfiltered: [<]
+URL: https://example.com/search?q=1&p=2 Param: p Unfiltered: ["]
+URL: https://example.com/search?q=1&p=2 Param: q Unfiltered: [> <]
+`,
+			[]types.VulnerabilityResult{
+				{URL: "https://example.com/search?q=1&p=2", Issue: "Reflected Parameter", Type: "reflected-parameter", Confidence: "tentative", Parameter: "q", Detail: "Param: q Unfiltered: [< >]"},
+				{URL: "https://example.com/search?q=1&p=2", Issue: "Reflected Parameter", Type: "reflected-parameter", Confidence: "tentative", Parameter: "p", Detail: `Param: p Unfiltered: ["]`},
+			},
+		},
+		{
+			"nothing unfiltered",
+			"URL: https://example.com/?id=7 Param: id Unfiltered: []\n",
+			[]types.VulnerabilityResult{{URL: "https://example.com/?id=7", Issue: "Reflected Parameter", Type: "reflected-parameter", Confidence: "tentative", Parameter: "id", Detail: "Param: id Unfiltered: []"}},
+		},
+	}
+	for _, tt := range tests {
+		if got := ParseKxssOutput(tt.output); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package parsers
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// TestParsersMultiMBLine feeds tool output with a single line of several
+// megabytes through the parsers: under the cap it parses whole, over it
+// the rest of the output still parses and the truncation is counted for
+// the tool.
+func TestParsersMultiMBLine(t *testing.T) {
+	saved := utils.MaxLineBytes
+	defer func() { utils.MaxLineBytes = saved }()
+	utils.TakeTruncations()
+
+	// A nuclei result whose extractor captured a 4MB response body.
+	extracted := strings.Repeat("A", 4<<20)
+	huge := fmt.Sprintf(`{"template-id":"exposed-backup","info":{"name":"Exposed Backup","severity":"high"},"matched-at":"https://www.example.com/backup.sql","extracted-results":[%q]}`, extracted)
+	small := `{"template-id":"git-config","info":{"name":"Git Config","severity":"medium"},"matched-at":"https://www.example.com/.git/config"}`
+	output := small + "\n" + huge + "\n" + small + "\n"
+
+	// The default cap keeps the line whole.
+	vulns, err := ParseNucleiOutput(output)
+	if err != nil || len(vulns) != 3 || !strings.HasSuffix(vulns[1].Detail, "; extracted: "+extracted) {
+		t.Fatalf("under the cap: %d finding(s), %v", len(vulns), err)
+	}
+	if got := utils.TakeTruncations(); len(got) != 0 {
+		t.Errorf("under the cap: %q", got)
+	}
+
+	// Over the cap the line cannot parse; the lines after it still do.
+	utils.MaxLineBytes = 1 << 20
+	vulns, err = ParseNucleiOutput(output)
+	if len(vulns) != 2 || err == nil || err.Error() != "nuclei output: 1 malformed JSON line(s) skipped" {
+		t.Errorf("over the cap: %d finding(s), %v", len(vulns), err)
+	}
+
+	// dalfox keeps a truncated [POC] line, flagged in the detail.
+	poc := "[POC][V][GET][inHTML-URL] https://www.example.com/search?q=%3Csvg%20onload%3Dalert(1)%3E " + strings.Repeat("B", 3<<20)
+	xss := ParseDalfoxOutput("[*] Using single target mode\n" + poc + "\n[POC][R][GET] https://www.example.com/?p=x\n")
+	if len(xss) != 2 || xss[0].URL != "https://www.example.com/search?q=%3Csvg%20onload%3Dalert(1)%3E" ||
+		xss[0].Detail != poc[:1<<20]+" [truncated]" || xss[1].URL != "https://www.example.com/?p=x" {
+		t.Errorf("dalfox: %d finding(s)", len(xss))
+	}
+
+	// kxss cuts a runaway URL off before its parameter; the next line parses.
+	kxss := ParseKxssOutput("URL: https://www.example.com/?q=" + strings.Repeat("C", 2<<20) + " Param: q Unfiltered: [<]\n" +
+		"URL: https://www.example.com/?p=1 Param: p Unfiltered: [\" <]\n")
+	if len(kxss) != 1 || kxss[0].URL != "https://www.example.com/?p=1" {
+		t.Errorf("kxss: %+v", kxss)
+	}
+
+	want := []string{
+		"dalfox: 1 line(s) truncated at 1048576 bytes",
+		"kxss: 1 line(s) truncated at 1048576 bytes",
+		"nuclei: 1 line(s) truncated at 1048576 bytes",
+	}
+	if got := utils.TakeTruncations(); !reflect.DeepEqual(got, want) {
+		t.Errorf("TakeTruncations() = %q, want %q", got, want)
+	}
+}
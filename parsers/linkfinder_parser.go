@@ -0,0 +1,39 @@
+package parsers
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// linkfinderMIMERe matches the MIME types LinkFinder's path regex picks up
+// from Content-Type strings in scripts, such as application/json.
+var linkfinderMIMERe = regexp.MustCompile(`^(?i)(application|text|image|audio|video|font|multipart)/[a-z0-9.+-]+$`)
+
+// ParseLinkFinderOutput reads the endpoints LinkFinder prints with -o cli,
+// one per line, in order and without duplicates. "Running against:"
+// headers and MIME types mistaken for paths are skipped. When LinkFinder
+// could not read its input it prints its usage and an "Error:" line, which
+// is returned as the error.
+func ParseLinkFinderOutput(output string) ([]string, error) {
+	var endpoints []string
+	var failure string
+	utils.ForEachLine("LinkFinder", output, func(line string, _ bool) {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "", strings.HasPrefix(line, "Running against:"), strings.HasPrefix(line, "Usage:"):
+		case strings.HasPrefix(line, "Error:"):
+			failure = line
+		case linkfinderMIMERe.MatchString(line), strings.ContainsAny(line, " \t"):
+		default:
+			endpoints = append(endpoints, line)
+		}
+	})
+	endpoints = utils.UniqueStrings(endpoints)
+	if failure != "" {
+		return endpoints, errors.New("LinkFinder: " + strings.TrimSpace(strings.TrimPrefix(failure, "Error:")))
+	}
+	return endpoints, nil
+}
@@ -0,0 +1,51 @@
+package parsers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLinkFinderOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    []string
+		wantErr string
+	}{
+		{"empty", "", nil, ""},
+		{
+			"endpoints in order, repeats dropped",
+			`Running against: https://www.example.com/static/app.js
+
+/api/v1/users
+https://cdn.example.com/lib.js
+./partials/header.html
+/api/v1/users
+user/profile?id=
+`,
+			[]string{"/api/v1/users", "https://cdn.example.com/lib.js", "./partials/header.html", "user/profile?id="},
+			"",
+		},
+		{
+			"MIME types and prose are not endpoints",
+			"application/json\ntext/html\nimage/svg+xml\n/upload\nsome words here\n",
+			[]string{"/upload"},
+			"",
+		},
+		{
+			"unreadable input",
+			"Usage: linkfinder.py [-h] -i INPUT [-o OUTPUT]\nError: invalid input defined or SSL error: HTTP Error 404: Not Found\n",
+			nil,
+			"LinkFinder: invalid input defined or SSL error: HTTP Error 404: Not Found",
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseLinkFinderOutput(tt.output)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
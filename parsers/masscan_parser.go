@@ -0,0 +1,65 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// masscanRecord is one element of masscan's -oJ output.
+type masscanRecord struct {
+	IP    string `json:"ip"`
+	Ports []struct {
+		Port    int    `json:"port"`
+		Proto   string `json:"proto"`
+		Status  string `json:"status"`
+		Service struct {
+			Name string `json:"name"`
+		} `json:"service"`
+	} `json:"ports"`
+}
+
+// ParseMasscanJSON reads masscan's -oJ output. masscan writes one record
+// per open port and its array is not always valid JSON (a trailing comma
+// before "]", a missing "]" when interrupted, or no brackets at all when
+// nothing was found), so records are decoded one at a time with the
+// brackets and separating commas skipped. Records for the same IP are
+// merged into one host.
+func ParseMasscanJSON(data []byte) ([]types.ScannedHost, error) {
+	data = bytes.TrimSpace(data)
+	data = bytes.TrimPrefix(data, []byte("["))
+	data = bytes.TrimSuffix(data, []byte("]"))
+	var hosts []types.ScannedHost
+	index := make(map[string]int)
+	for {
+		data = bytes.TrimLeft(data, " \t\r\n,")
+		if len(data) == 0 {
+			return hosts, nil
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		var rec masscanRecord
+		if err := dec.Decode(&rec); err != nil {
+			return hosts, fmt.Errorf("masscan output truncated after %d host(s): %v", len(hosts), err)
+		}
+		data = data[dec.InputOffset():]
+		if rec.IP == "" {
+			continue
+		}
+		i, seen := index[rec.IP]
+		if !seen {
+			i = len(hosts)
+			index[rec.IP] = i
+			hosts = append(hosts, types.ScannedHost{Address: rec.IP})
+		}
+		for _, p := range rec.Ports {
+			hosts[i].Ports = append(hosts[i].Ports, types.PortService{
+				Port:     p.Port,
+				Protocol: p.Proto,
+				State:    p.Status,
+				Service:  p.Service.Name,
+			})
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package parsers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// naabuRecord is one line of naabu -json output. Older releases only
+// report ip and port; newer ones add host and protocol.
+type naabuRecord struct {
+	Host     string `json:"host"`
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+}
+
+// ParseNaabuOutput converts naabu -json output into scanned hosts, merging
+// the one-port-per-line records of each IP. naabu only reports open ports;
+// the protocol defaults to tcp. Malformed JSON lines are counted and
+// returned as an error alongside the parsed hosts.
+func ParseNaabuOutput(output string) ([]types.ScannedHost, error) {
+	var hosts []types.ScannedHost
+	index := make(map[string]int)
+	bad := 0
+	utils.ForEachLine("naabu", output, func(line string, _ bool) {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			return
+		}
+		var rec naabuRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			bad++
+			return
+		}
+		ip := rec.IP
+		if ip == "" {
+			ip = rec.Host
+		}
+		if ip == "" || rec.Port <= 0 {
+			return
+		}
+		proto := strings.ToLower(rec.Protocol)
+		if proto == "" {
+			proto = "tcp"
+		}
+		i, seen := index[ip]
+		if !seen {
+			i = len(hosts)
+			index[ip] = i
+			hosts = append(hosts, types.ScannedHost{Address: ip})
+		}
+		hosts[i].Ports = append(hosts[i].Ports, types.PortService{Port: rec.Port, Protocol: proto, State: "open"})
+	})
+	if bad > 0 {
+		return hosts, fmt.Errorf("naabu output: %d malformed JSON line(s) skipped", bad)
+	}
+	return hosts, nil
+}
@@ -0,0 +1,76 @@
+package parsers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestParseNaabuOutput(t *testing.T) {
+	open := func(port int, proto string) types.PortService {
+		return types.PortService{Port: port, Protocol: proto, State: "open"}
+	}
+	tests := []struct {
+		name    string
+		output  string
+		want    []types.ScannedHost
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{"banner only", "\n                  __\n  ___  ___  ___ _/ /  __ __\n[INF] Running CONNECT scan with non root privileges\n", nil, false},
+		{
+			"ip and port records, merged per IP",
+			`{"ip":"192.0.2.10","port":443,"timestamp":"2024-05-01T10:00:00Z"}
+{"ip":"192.0.2.11","port":80,"timestamp":"2024-05-01T10:00:00Z"}
+{"ip":"192.0.2.10","port":80,"timestamp":"2024-05-01T10:00:01Z"}
+`,
+			[]types.ScannedHost{
+				{Address: "192.0.2.10", Ports: []types.PortService{open(443, "tcp"), open(80, "tcp")}},
+				{Address: "192.0.2.11", Ports: []types.PortService{open(80, "tcp")}},
+			},
+			false,
+		},
+		{
+			"newer records with host and protocol",
+			`{"host":"www.example.com","ip":"192.0.2.10","port":53,"protocol":"UDP","tls":false}
+{"host":"www.example.com","ip":"192.0.2.10","port":443,"protocol":"tcp","tls":true}
+`,
+			[]types.ScannedHost{{Address: "192.0.2.10", Ports: []types.PortService{open(53, "udp"), open(443, "tcp")}}},
+			false,
+		},
+		{
+			"host without ip",
+			`{"host":"192.0.2.20","port":8080}` + "\n",
+			[]types.ScannedHost{{Address: "192.0.2.20", Ports: []types.PortService{open(8080, "tcp")}}},
+			false,
+		},
+		{
+			"records without a port or address are skipped",
+			`{"ip":"192.0.2.10","port":0}
+{"port":443}
+{"ip":"192.0.2.10","port":22}
+`,
+			[]types.ScannedHost{{Address: "192.0.2.10", Ports: []types.PortService{open(22, "tcp")}}},
+			false,
+		},
+		{
+			"malformed lines are counted, the rest kept",
+			`{"ip":"192.0.2.10","port":22}
+{"ip":"192.0.2.10","port":"ssh"}
+{"ip":"192.0.2.10",
+`,
+			[]types.ScannedHost{{Address: "192.0.2.10", Ports: []types.PortService{open(22, "tcp")}}},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseNaabuOutput(tt.output)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+}
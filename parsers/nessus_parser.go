@@ -0,0 +1,122 @@
+package parsers
+
+import (
+	"encoding/xml"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// NessusHost is one ReportHost of a .nessus (NessusClientData_v2) export.
+// Name is how the scan addressed the host; IP and FQDN come from its
+// HostProperties when Nessus recorded them.
+type NessusHost struct {
+	Name  string
+	IP    string
+	FQDN  string
+	Items []NessusItem
+}
+
+// NessusItem is one plugin result on a host.
+type NessusItem struct {
+	PluginID   string
+	PluginName string
+	Port       int
+	Protocol   string
+	Service    string
+	// Severity is Nessus' 0 (informational) to 4 (critical) rating and
+	// RiskFactor its name: None, Low, Medium, High or Critical.
+	Severity   int
+	RiskFactor string
+	Synopsis   string
+	Output     string
+	CVSS3Score float64
+	CVSS3      string
+	// CWE is the plugin's first <cwe> entry, or its first CWE cross
+	// reference; 0 when it names none.
+	CWE int
+}
+
+type nessusDoc struct {
+	Hosts []struct {
+		Name       string `xml:"name,attr"`
+		Properties []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"HostProperties>tag"`
+		Items []struct {
+			Port       int      `xml:"port,attr"`
+			Service    string   `xml:"svc_name,attr"`
+			Protocol   string   `xml:"protocol,attr"`
+			Severity   int      `xml:"severity,attr"`
+			PluginID   string   `xml:"pluginID,attr"`
+			PluginName string   `xml:"pluginName,attr"`
+			RiskFactor string   `xml:"risk_factor"`
+			Synopsis   string   `xml:"synopsis"`
+			Output     string   `xml:"plugin_output"`
+			CVSS3Score float64  `xml:"cvss3_base_score"`
+			CVSS3      string   `xml:"cvss3_vector"`
+			CWE        []string `xml:"cwe"`
+			Xref       []string `xml:"xref"`
+		} `xml:"ReportItem"`
+	} `xml:"Report>ReportHost"`
+}
+
+// ParseNessus reads the hosts and plugin results of a .nessus export.
+func ParseNessus(data []byte) ([]NessusHost, error) {
+	var doc nessusDoc
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var hosts []NessusHost
+	for _, h := range doc.Hosts {
+		host := NessusHost{Name: strings.TrimSpace(h.Name)}
+		for _, p := range h.Properties {
+			switch p.Name {
+			case "host-ip":
+				host.IP = strings.TrimSpace(p.Value)
+			case "host-fqdn":
+				host.FQDN = strings.TrimSpace(p.Value)
+			}
+		}
+		if host.IP == "" && net.ParseIP(host.Name) != nil {
+			host.IP = host.Name
+		}
+		for _, it := range h.Items {
+			host.Items = append(host.Items, NessusItem{
+				PluginID:   it.PluginID,
+				PluginName: strings.TrimSpace(it.PluginName),
+				Port:       it.Port,
+				Protocol:   it.Protocol,
+				Service:    it.Service,
+				Severity:   it.Severity,
+				RiskFactor: strings.TrimSpace(it.RiskFactor),
+				Synopsis:   strings.TrimSpace(it.Synopsis),
+				Output:     strings.TrimSpace(it.Output),
+				CVSS3Score: it.CVSS3Score,
+				CVSS3:      strings.TrimSpace(it.CVSS3),
+				CWE:        nessusCWE(it.CWE, it.Xref),
+			})
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+// nessusCWE returns the first CWE of a plugin's <cwe> entries, falling
+// back to <xref>CWE:n</xref> cross references.
+func nessusCWE(cwes, xrefs []string) int {
+	for _, c := range cwes {
+		if n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(c), "CWE-")); err == nil && n > 0 {
+			return n
+		}
+	}
+	for _, x := range xrefs {
+		if id := strings.TrimSpace(x); strings.HasPrefix(strings.ToUpper(id), "CWE:") {
+			if n, err := strconv.Atoi(strings.TrimSpace(id[4:])); err == nil && n > 0 {
+				return n
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,156 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// niktoHost is one host of nikto's JSON export (-Format json). Older
+// releases write a single object, newer ones an array of them.
+type niktoHost struct {
+	Host            string `json:"host"`
+	Port            string `json:"port"`
+	Vulnerabilities []struct {
+		ID         string `json:"id"`
+		OSVDB      string `json:"OSVDB"`
+		References string `json:"references"`
+		Method     string `json:"method"`
+		URL        string `json:"url"`
+		Msg        string `json:"msg"`
+	} `json:"vulnerabilities"`
+}
+
+// niktoInfoPrefixes start the messages of nikto's banner and header
+// observations, which describe the server rather than a weakness.
+var niktoInfoPrefixes = []string{
+	"Server:", "Retrieved ", "Uncommon header", "No CGI Directories found",
+	"Root page / redirects to", "Allowed HTTP Methods", "Cookie ",
+}
+
+// niktoInformational reports whether a nikto item is informational: its
+// 999xxx test ids cover headers and banners, and some generic tests print
+// observations only.
+func niktoInformational(id, msg string) bool {
+	if strings.HasPrefix(id, "999") {
+		return true
+	}
+	for _, p := range niktoInfoPrefixes {
+		if strings.HasPrefix(msg, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// niktoResult builds the finding for one nikto item, joining uri with base
+// (scheme://host[:port]) unless nikto already reported a full URL.
+func niktoResult(base, id, ref, method, uri, msg string) types.VulnerabilityResult {
+	issue, typ := "Nikto Finding", "nikto-finding"
+	if niktoInformational(id, msg) {
+		issue, typ = "Nikto Informational", "nikto-informational"
+	}
+	detail := msg
+	var tags []string
+	if id != "" {
+		tags = append(tags, "test "+id)
+	}
+	if ref != "" && ref != "0" && ref != "OSVDB-0" {
+		if _, err := strconv.Atoi(ref); err == nil {
+			ref = "OSVDB-" + ref
+		}
+		tags = append(tags, ref)
+	}
+	if method != "" {
+		tags = append(tags, method)
+	}
+	if len(tags) > 0 {
+		detail = "[" + strings.Join(tags, ", ") + "] " + msg
+	}
+	full := uri
+	if !strings.HasPrefix(uri, "http://") && !strings.HasPrefix(uri, "https://") {
+		if !strings.HasPrefix(uri, "/") {
+			uri = "/" + uri
+		}
+		full = strings.TrimSuffix(base, "/") + uri
+	}
+	return types.VulnerabilityResult{
+		URL:    full,
+		Issue:  issue,
+		Type:   typ,
+		Detail: detail,
+	}
+}
+
+// ParseNiktoOutput reads nikto's JSON or CSV export of a scan of base.
+// The format is detected from the first non-blank byte.
+func ParseNiktoOutput(data []byte, base string) ([]types.VulnerabilityResult, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+	if trimmed[0] == '{' || trimmed[0] == '[' {
+		return parseNiktoJSON(trimmed, base)
+	}
+	return parseNiktoCSV(trimmed, base)
+}
+
+func parseNiktoJSON(data []byte, base string) ([]types.VulnerabilityResult, error) {
+	var hosts []niktoHost
+	if data[0] == '{' {
+		var h niktoHost
+		if err := json.Unmarshal(data, &h); err != nil {
+			return nil, fmt.Errorf("nikto JSON output: %v", err)
+		}
+		hosts = append(hosts, h)
+	} else if err := json.Unmarshal(data, &hosts); err != nil {
+		return nil, fmt.Errorf("nikto JSON output: %v", err)
+	}
+	var results []types.VulnerabilityResult
+	for _, h := range hosts {
+		for _, v := range h.Vulnerabilities {
+			ref := v.References
+			if ref == "" {
+				ref = v.OSVDB
+			}
+			results = append(results, niktoResult(base, v.ID, ref, v.Method, v.URL, v.Msg))
+		}
+	}
+	return results, nil
+}
+
+// parseNiktoCSV reads rows of host, ip, port, reference, method, uri and
+// message. The version banner and header rows are skipped.
+func parseNiktoCSV(data []byte, base string) ([]types.VulnerabilityResult, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	var results []types.VulnerabilityResult
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return results, fmt.Errorf("nikto CSV output: %v", err)
+		}
+		if len(rec) < 7 {
+			continue
+		}
+		if _, err := strconv.Atoi(rec[2]); err != nil {
+			continue
+		}
+		results = append(results, niktoResult(base, "", rec[3], rec[4], rec[5], rec[6]))
+	}
+	if results == nil && !bytes.Contains(data, []byte(",")) {
+		return nil, errors.New("nikto output: neither JSON nor CSV")
+	}
+	return results, nil
+}
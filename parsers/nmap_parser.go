@@ -0,0 +1,81 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// nmapHost is one <host> element of nmap's -oX output.
+type nmapHost struct {
+	TimedOut  string `xml:"timedout,attr"`
+	Addresses []struct {
+		Addr     string `xml:"addr,attr"`
+		AddrType string `xml:"addrtype,attr"`
+	} `xml:"address"`
+	Hostnames []struct {
+		Name string `xml:"name,attr"`
+	} `xml:"hostnames>hostname"`
+	Ports []struct {
+		Protocol string `xml:"protocol,attr"`
+		PortID   int    `xml:"portid,attr"`
+		State    struct {
+			State string `xml:"state,attr"`
+		} `xml:"state"`
+		Service struct {
+			Name string `xml:"name,attr"`
+		} `xml:"service"`
+	} `xml:"ports>port"`
+}
+
+// toScannedHost converts a host element, taking its IP address (not its MAC).
+func (h nmapHost) toScannedHost() types.ScannedHost {
+	host := types.ScannedHost{TimedOut: h.TimedOut == "true"}
+	for _, a := range h.Addresses {
+		if a.AddrType == "ipv4" || a.AddrType == "ipv6" {
+			host.Address = a.Addr
+			break
+		}
+	}
+	for _, n := range h.Hostnames {
+		host.Hostnames = append(host.Hostnames, n.Name)
+	}
+	for _, p := range h.Ports {
+		host.Ports = append(host.Ports, types.PortService{
+			Port:     p.PortID,
+			Protocol: p.Protocol,
+			State:    p.State.State,
+			Service:  p.Service.Name,
+		})
+	}
+	return host
+}
+
+// ParseNmapXML reads nmap's XML output. Host elements are decoded one at a
+// time, so when nmap was killed mid-write the complete hosts are returned
+// along with an error.
+func ParseNmapXML(data []byte) ([]types.ScannedHost, error) {
+	var hosts []types.ScannedHost
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return hosts, nil
+		}
+		if err != nil {
+			return hosts, fmt.Errorf("nmap output truncated after %d host(s): %v", len(hosts), err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "host" {
+			continue
+		}
+		var h nmapHost
+		if err := dec.DecodeElement(&h, &start); err != nil {
+			return hosts, fmt.Errorf("nmap output truncated after %d host(s): %v", len(hosts), err)
+		}
+		hosts = append(hosts, h.toScannedHost())
+	}
+}
@@ -0,0 +1,85 @@
+package parsers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// nucleiResult is the subset of a nuclei JSONL result line the tool uses.
+type nucleiResult struct {
+	TemplateID string `json:"template-id"`
+	Info       struct {
+		Name           string `json:"name"`
+		Severity       string `json:"severity"`
+		Classification struct {
+			CWEID []string `json:"cwe-id"`
+		} `json:"classification"`
+	} `json:"info"`
+	MatcherName      string   `json:"matcher-name"`
+	MatchedAt        string   `json:"matched-at"`
+	Host             string   `json:"host"`
+	ExtractedResults []string `json:"extracted-results"`
+}
+
+// nucleiCWE returns the first CWE a template is classified under; templates
+// list them as "cwe-79" strings.
+func nucleiCWE(ids []string) int {
+	for _, id := range ids {
+		id = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(id)), "cwe-")
+		if n, err := strconv.Atoi(id); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// ParseNucleiOutput converts nuclei -jsonl output into vulnerability results.
+// Lines that are not JSON (banners, progress) are skipped; malformed JSON
+// lines are counted and returned as an error alongside the parsed results.
+func ParseNucleiOutput(output string) ([]types.VulnerabilityResult, error) {
+	var results []types.VulnerabilityResult
+	bad := 0
+	utils.ForEachLine("nuclei", output, func(line string, _ bool) {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			return
+		}
+		var r nucleiResult
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			bad++
+			return
+		}
+		name := r.Info.Name
+		if name == "" {
+			name = r.TemplateID
+		}
+		target := r.MatchedAt
+		if target == "" {
+			target = r.Host
+		}
+		detail := "template: " + r.TemplateID
+		if r.MatcherName != "" {
+			detail += "; matcher: " + r.MatcherName
+		}
+		if len(r.ExtractedResults) > 0 {
+			detail += "; extracted: " + strings.Join(r.ExtractedResults, ", ")
+		}
+		results = append(results, types.VulnerabilityResult{
+			URL:      target,
+			Issue:    fmt.Sprintf("[%s] %s", strings.ToLower(r.Info.Severity), name),
+			Type:     "nuclei-template",
+			CWE:      nucleiCWE(r.Info.Classification.CWEID),
+			Detail:   detail,
+			Severity: r.Info.Severity,
+		})
+	})
+	if bad > 0 {
+		return results, fmt.Errorf("nuclei output: %d malformed JSON line(s) skipped", bad)
+	}
+	return results, nil
+}
@@ -0,0 +1,55 @@
+package parsers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// ParamSpiderPlaceholder is the value ParamSpider puts in place of every
+// parameter value it mines from the archives.
+const ParamSpiderPlaceholder = "FUZZ"
+
+// paramSpiderValue replaces the placeholder so the URLs can be handed to
+// sqlmap and dalfox as they are, matching the value set for Arjun's
+// parameters.
+const paramSpiderValue = "1"
+
+// ParseParamSpiderOutput reads a ParamSpider results file, one URL per line,
+// into the distinct URLs that take parameters, in file order. Placeholder
+// values are replaced with a benign one; the order and encoding of the
+// query are otherwise kept. Lines that are not http(s) URLs with a query
+// are counted as malformed.
+func ParseParamSpiderOutput(output string) ([]string, error) {
+	seen := make(map[string]bool)
+	var urls []string
+	bad := 0
+	utils.ForEachLine("paramspider", output, func(line string, _ bool) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return
+		}
+		u, err := url.Parse(line)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery == "" {
+			bad++
+			return
+		}
+		pairs := strings.Split(u.RawQuery, "&")
+		for i, p := range pairs {
+			if name := strings.TrimSuffix(p, "="+ParamSpiderPlaceholder); name != p {
+				pairs[i] = name + "=" + paramSpiderValue
+			}
+		}
+		u.RawQuery = strings.Join(pairs, "&")
+		if s := u.String(); !seen[s] {
+			seen[s] = true
+			urls = append(urls, s)
+		}
+	})
+	if bad > 0 {
+		return urls, fmt.Errorf("paramspider output: %d malformed line(s) skipped", bad)
+	}
+	return urls, nil
+}
@@ -0,0 +1,85 @@
+package parsers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	pgpSignedHeader = "-----BEGIN PGP SIGNED MESSAGE-----"
+	pgpSignature    = "-----BEGIN PGP SIGNATURE-----"
+)
+
+// ParseSecurityTxt reads a security.txt file per RFC 9116. Field names
+// are case-insensitive, comments and unknown fields are skipped, and an
+// OpenPGP cleartext signature around the file is stripped: the armor
+// headers, the dash-escaping of the body and the signature block. The file
+// is Expired when its Expires date is before now. Content without a
+// Contact field, such as a catch-all HTML page, is an error.
+func ParseSecurityTxt(data string, now time.Time) (types.SecurityTxt, error) {
+	var txt types.SecurityTxt
+	if strings.HasPrefix(strings.TrimSpace(data), "<") {
+		return txt, errors.New("security.txt: HTML instead of text")
+	}
+	inHeaders, inSignature := false, false
+	utils.ForEachLine("security.txt", data, func(line string, _ bool) {
+		line = strings.TrimRight(line, "\r")
+		switch {
+		case inSignature:
+			return
+		case line == pgpSignedHeader:
+			txt.Signed, inHeaders = true, true
+			return
+		case inHeaders:
+			// Armor headers such as "Hash: SHA256" end at the first blank line.
+			inHeaders = strings.TrimSpace(line) != ""
+			return
+		case line == pgpSignature:
+			inSignature = true
+			return
+		}
+		if txt.Signed {
+			line = strings.TrimPrefix(line, "- ")
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			return
+		}
+		i := strings.Index(line, ":")
+		if i <= 0 {
+			return
+		}
+		value := strings.TrimSpace(line[i+1:])
+		switch strings.ToLower(line[:i]) {
+		case "contact":
+			txt.Contacts = append(txt.Contacts, value)
+		case "policy":
+			txt.Policy = append(txt.Policy, value)
+		case "encryption":
+			txt.Encryption = append(txt.Encryption, value)
+		case "acknowledgments", "acknowledgements":
+			txt.Acknowledgments = append(txt.Acknowledgments, value)
+		case "hiring":
+			txt.Hiring = append(txt.Hiring, value)
+		case "canonical":
+			txt.Canonical = append(txt.Canonical, value)
+		case "preferred-languages":
+			txt.PreferredLanguages = value
+		case "expires":
+			if txt.Expires == "" {
+				txt.Expires = value
+			}
+		}
+	})
+	if len(txt.Contacts) == 0 {
+		return txt, errors.New("security.txt: no Contact field")
+	}
+	if t, err := time.Parse(time.RFC3339, txt.Expires); err == nil && t.Before(now) {
+		txt.Expired = true
+	}
+	return txt, nil
+}
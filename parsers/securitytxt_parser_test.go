@@ -0,0 +1,86 @@
+package parsers
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// TestParseSecurityTxt runs the parser over security.txt files as sites
+// serve them, signed and unsigned, and over what catch-all pages return.
+func TestParseSecurityTxt(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		file string
+		want types.SecurityTxt
+		err  string
+	}{
+		{"plain.txt", types.SecurityTxt{
+			Contacts:           []string{"mailto:security@example.com", "https://example.com/security/report"},
+			Policy:             []string{"https://example.com/disclosure-policy"},
+			Encryption:         []string{"https://example.com/pgp-key.txt"},
+			Acknowledgments:    []string{"https://example.com/hall-of-fame"},
+			Hiring:             []string{"https://example.com/jobs/security"},
+			Canonical:          []string{"https://example.com/.well-known/security.txt"},
+			PreferredLanguages: "en, sv",
+			Expires:            "2031-12-31T23:00:00.000Z",
+		}, ""},
+		// The signature block is not read, though it contains a colon line.
+		{"signed.txt", types.SecurityTxt{
+			Contacts:        []string{"mailto:psirt@example.org", "tel:+1-201-555-0123"},
+			Acknowledgments: []string{"https://www.example.org/thanks"},
+			Canonical:       []string{"https://www.example.org/.well-known/security.txt"},
+			Expires:         "2020-03-01T00:00:00Z",
+			Expired:         true,
+			Signed:          true,
+		}, ""},
+		{"html.txt", types.SecurityTxt{}, "HTML instead of text"},
+		{"no_contact.txt", types.SecurityTxt{
+			Policy:  []string{"https://example.net/policy"},
+			Expires: "2030-01-01T00:00:00Z",
+		}, "no Contact field"},
+	}
+	for _, tt := range tests {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", "securitytxt", tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseSecurityTxt(string(data), now)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: error %v, want %q", tt.file, err, tt.err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestParseSecurityTxtExpires(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		expires string
+		expired bool
+	}{
+		{"Expires: 2026-09-30T23:59:59Z", true},
+		{"Expires: 2026-10-01T02:00:00+02:00", false},
+		{"Expires: 2026-10-01T00:00:01Z", false},
+		// Only a date the RFC allows counts; anything else is kept as is.
+		{"Expires: Tue, 01 Sep 2026 00:00:00 GMT", false},
+		// The first Expires field wins.
+		{"Expires: 2020-01-01T00:00:00Z\nExpires: 2030-01-01T00:00:00Z", true},
+	}
+	for _, tt := range tests {
+		txt, err := ParseSecurityTxt("Contact: mailto:security@example.com\n"+tt.expires+"\n", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if txt.Expired != tt.expired {
+			t.Errorf("%q: Expired = %v", tt.expires, txt.Expired)
+		}
+	}
+}
@@ -1,29 +1,133 @@
 package parsers
 
 import (
-	"bufio"
+	"fmt"
 	"regexp"
 	"strings"
 
-	"recon-tool/main" // adjust import as needed
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
 )
 
-func ParseSqlmapOutput(output string) []main.VulnerabilityResult {
-	var results []main.VulnerabilityResult
-	scanner := bufio.NewScanner(strings.NewReader(output))
-	re := regexp.MustCompile(`(http[s]?://[^\s]+)`)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "is vulnerable") {
-			match := re.FindStringSubmatch(line)
-			if len(match) > 1 {
-				results = append(results, main.VulnerabilityResult{
-					URL:    match[1],
-					Issue:  "SQL Injection",
-					Detail: line,
-				})
+var (
+	sqlmapURLRe        = regexp.MustCompile(`(?:^(?:GET|POST|PUT|PATCH|DELETE) |testing URL ')(https?://[^\s']+)`)
+	sqlmapParamRe      = regexp.MustCompile(`^Parameter: (.+) \((.+)\)$`)
+	sqlmapVulnerableRe = regexp.MustCompile(`(\S+) parameter '([^']+)' is vulnerable`)
+)
+
+// sqlmapInjection is one injection point while its block is being read.
+type sqlmapInjection struct {
+	url, param, place string
+	techniques        []string
+	payloads          []string
+	dbms              string
+}
+
+// ParseSqlmapOutput extracts SQLi findings from sqlmap output, one per
+// injectable parameter. target is the URL given to -u; with -m the URL is
+// taken from the output. The parameter, techniques and payloads come from
+// the injection point blocks sqlmap prints, the DBMS from its "back-end
+// DBMS" line; a parameter reported vulnerable without a block, as when
+// sqlmap is stopped early, still yields a finding.
+func ParseSqlmapOutput(output, target string) []types.VulnerabilityResult {
+	var points []*sqlmapInjection
+	var cur *sqlmapInjection
+	flagged := make(map[string]string)
+	var flaggedOrder []string
+	current, first := target, 0
+	utils.ForEachLine("sqlmap", output, func(line string, truncated bool) {
+		line = strings.TrimSpace(line)
+		if m := sqlmapURLRe.FindStringSubmatch(line); m != nil && m[1] != current {
+			current, first, cur = m[1], len(points), nil
+			return
+		}
+		if m := sqlmapParamRe.FindStringSubmatch(line); m != nil {
+			cur = &sqlmapInjection{url: current, param: m[1], place: m[2]}
+			points = append(points, cur)
+			return
+		}
+		if m := sqlmapVulnerableRe.FindStringSubmatch(line); m != nil {
+			key := current + "|" + m[2]
+			if _, ok := flagged[key]; !ok {
+				flaggedOrder = append(flaggedOrder, key)
+			}
+			flagged[key] = m[1]
+			return
+		}
+		switch {
+		case line == "---":
+			cur = nil
+		case cur != nil && strings.HasPrefix(line, "Type: "):
+			cur.techniques = append(cur.techniques, strings.TrimPrefix(line, "Type: "))
+			cur.payloads = append(cur.payloads, "")
+		case cur != nil && strings.HasPrefix(line, "Payload: ") && len(cur.payloads) > 0:
+			payload := strings.TrimPrefix(line, "Payload: ")
+			if truncated {
+				payload += " [truncated]"
+			}
+			cur.payloads[len(cur.payloads)-1] = payload
+		case strings.HasPrefix(line, "back-end DBMS: "):
+			for _, pt := range points[first:] {
+				if pt.dbms == "" {
+					pt.dbms = strings.TrimPrefix(line, "back-end DBMS: ")
+				}
+			}
+		}
+	})
+
+	var results []types.VulnerabilityResult
+	seen := make(map[string]bool)
+	for _, pt := range points {
+		key := pt.url + "|" + pt.param
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		var steps []string
+		for i, t := range pt.techniques {
+			if pt.payloads[i] != "" {
+				t += ": " + pt.payloads[i]
+			}
+			steps = append(steps, t)
+		}
+		detail := fmt.Sprintf("%s parameter '%s' is injectable", pt.place, pt.param)
+		if len(steps) > 0 {
+			detail += " (" + strings.Join(steps, "; ") + ")"
+		}
+		if pt.dbms != "" {
+			detail += "; back-end DBMS: " + pt.dbms
+		}
+		v := types.VulnerabilityResult{
+			URL:       pt.url,
+			Issue:     "SQL Injection",
+			Type:      "sqli",
+			Detail:    detail,
+			Parameter: pt.param + " (" + pt.place + ")",
+			Technique: strings.Join(pt.techniques, ", "),
+			// sqlmap reports a parameter injectable once a technique worked.
+			Confidence: utils.ConfidenceConfirmed,
+		}
+		for _, payload := range pt.payloads {
+			if payload != "" {
+				v.Payload = payload
+				break
 			}
 		}
+		results = append(results, v)
+	}
+	for _, key := range flaggedOrder {
+		if seen[key] {
+			continue
+		}
+		parts := strings.SplitN(key, "|", 2)
+		results = append(results, types.VulnerabilityResult{
+			URL:        parts[0],
+			Issue:      "SQL Injection",
+			Type:       "sqli",
+			Detail:     fmt.Sprintf("%s parameter '%s' is vulnerable", flagged[key], parts[1]),
+			Parameter:  parts[1] + " (" + flagged[key] + ")",
+			Confidence: utils.ConfidenceFirm,
+		})
 	}
 	return results
 }
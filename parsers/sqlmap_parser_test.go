@@ -0,0 +1,108 @@
+package parsers
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// TestParseSqlmapOutput runs the parser over sqlmap transcripts captured
+// from -u and -m runs.
+func TestParseSqlmapOutput(t *testing.T) {
+	tests := []struct {
+		transcript string
+		target     string
+		want       []types.VulnerabilityResult
+	}{
+		{"get_union.txt", "http://www.example.com/item.php?id=1", []types.VulnerabilityResult{{
+			URL:   "http://www.example.com/item.php?id=1",
+			Issue: "SQL Injection",
+			Type:  "sqli",
+			Detail: "GET parameter 'id' is injectable (boolean-based blind: id=1 AND 7591=7591; " +
+				"time-based blind: id=1 AND (SELECT 1286 FROM (SELECT(SLEEP(5)))bMzq); " +
+				"UNION query: id=1 UNION ALL SELECT NULL,CONCAT(0x7170627171,0x4f6c6d,0x716a6b7671),NULL-- -); back-end DBMS: MySQL >= 5.0.12",
+			Parameter:  "id (GET)",
+			Technique:  "boolean-based blind, time-based blind, UNION query",
+			Payload:    "id=1 AND 7591=7591",
+			Confidence: "confirmed",
+		}}},
+		{"multiple_targets.txt", "", []types.VulnerabilityResult{
+			{
+				URL:   "http://shop.example.com/item.php?id=4&cat=2",
+				Issue: "SQL Injection",
+				Type:  "sqli",
+				Detail: "GET parameter 'id' is injectable (error-based: id=4 AND 4811=CAST((CHR(113)||CHR(120)||CHR(106)||CHR(122)||CHR(113))||" +
+					"(SELECT (CASE WHEN (4811=4811) THEN 1 ELSE 0 END))::text||(CHR(113)||CHR(98)||CHR(118)||CHR(122)||CHR(113)) AS NUMERIC)&cat=2); back-end DBMS: PostgreSQL",
+				Parameter: "id (GET)",
+				Technique: "error-based",
+				Payload: "id=4 AND 4811=CAST((CHR(113)||CHR(120)||CHR(106)||CHR(122)||CHR(113))||" +
+					"(SELECT (CASE WHEN (4811=4811) THEN 1 ELSE 0 END))::text||(CHR(113)||CHR(98)||CHR(118)||CHR(122)||CHR(113)) AS NUMERIC)&cat=2",
+				Confidence: "confirmed",
+			},
+			{
+				URL:        "http://shop.example.com/item.php?id=4&cat=2",
+				Issue:      "SQL Injection",
+				Type:       "sqli",
+				Detail:     "GET parameter 'cat' is injectable (boolean-based blind: id=4&cat=2 AND 3305=3305); back-end DBMS: PostgreSQL",
+				Parameter:  "cat (GET)",
+				Technique:  "boolean-based blind",
+				Payload:    "id=4&cat=2 AND 3305=3305",
+				Confidence: "confirmed",
+			},
+			{
+				URL:        "http://api.example.com/login",
+				Issue:      "SQL Injection",
+				Type:       "sqli",
+				Detail:     "POST parameter 'user' is injectable (stacked queries: user=a';WAITFOR DELAY '0:0:5'--&pass=b); back-end DBMS: Microsoft SQL Server 2019",
+				Parameter:  "user (POST)",
+				Technique:  "stacked queries",
+				Payload:    "user=a';WAITFOR DELAY '0:0:5'--&pass=b",
+				Confidence: "confirmed",
+			},
+		}},
+		{"aborted.txt", "http://www.example.com/search?q=x", []types.VulnerabilityResult{{
+			URL:        "http://www.example.com/search?q=x",
+			Issue:      "SQL Injection",
+			Type:       "sqli",
+			Detail:     "GET parameter 'q' is vulnerable",
+			Parameter:  "q (GET)",
+			Confidence: "firm",
+		}}},
+		{"resumed.txt", "http://www.example.com/products/1*?sort=name", []types.VulnerabilityResult{
+			{
+				URL:        "http://www.example.com/products/1*?sort=name",
+				Issue:      "SQL Injection",
+				Type:       "sqli",
+				Detail:     "URI parameter '#1*' is injectable (boolean-based blind: http://www.example.com/products/1 AND 5530=5530); back-end DBMS: SQLite",
+				Parameter:  "#1* (URI)",
+				Technique:  "boolean-based blind",
+				Payload:    "http://www.example.com/products/1 AND 5530=5530",
+				Confidence: "confirmed",
+			},
+			{
+				URL:        "http://www.example.com/products/1*?sort=name",
+				Issue:      "SQL Injection",
+				Type:       "sqli",
+				Detail:     "GET parameter 'sort' is injectable (time-based blind: sort=name AND 4478=LIKE(CHAR(65,66,67,68,69,70,71),UPPER(HEX(RANDOMBLOB(500000000/2))))); back-end DBMS: SQLite",
+				Parameter:  "sort (GET)",
+				Technique:  "time-based blind",
+				Payload:    "sort=name AND 4478=LIKE(CHAR(65,66,67,68,69,70,71),UPPER(HEX(RANDOMBLOB(500000000/2))))",
+				Confidence: "confirmed",
+			},
+		}},
+		{"not_injectable.txt", "http://www.example.com/?id=1", nil},
+	}
+	for _, tt := range tests {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", "sqlmap", tt.transcript))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ParseSqlmapOutput(string(data), tt.target)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.transcript, got, tt.want)
+		}
+	}
+}
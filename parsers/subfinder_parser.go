@@ -0,0 +1,55 @@
+package parsers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// subfinderEntry is one line of subfinder's -oJ output. With -cs every
+// source that found the name is listed under sources.
+type subfinderEntry struct {
+	Host    string   `json:"host"`
+	Source  string   `json:"source"`
+	Sources []string `json:"sources"`
+}
+
+// ParseSubfinderOutput reads subfinder's JSON lines into the hostnames
+// found, sorted, and the passive sources that reported each of them.
+// Lines that are not JSON or lack a host are counted as malformed.
+func ParseSubfinderOutput(output string) ([]string, map[string][]string, error) {
+	sources := make(map[string][]string)
+	bad := 0
+	utils.ForEachLine("subfinder", output, func(line string, _ bool) {
+		if line = strings.TrimSpace(line); line == "" {
+			return
+		}
+		var e subfinderEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			bad++
+			return
+		}
+		host := utils.NormalizeHostname(e.Host)
+		if host == "" {
+			bad++
+			return
+		}
+		if e.Source != "" {
+			e.Sources = append(e.Sources, e.Source)
+		}
+		sources[host] = utils.UniqueStrings(append(sources[host], e.Sources...))
+	})
+	hosts := make([]string, 0, len(sources))
+	for host := range sources {
+		sort.Strings(sources[host])
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	if bad > 0 {
+		return hosts, sources, fmt.Errorf("subfinder output: %d malformed line(s) skipped", bad)
+	}
+	return hosts, sources, nil
+}
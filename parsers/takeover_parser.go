@@ -0,0 +1,88 @@
+package parsers
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// ansiRe matches the color and line-erase escapes subzy, gobuster and
+// wafw00f decorate their output with.
+var ansiRe = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// subzyLineRe matches subzy result lines such as
+// `[ VULNERABLE ]  -  sub.example.com  [ GitHub ]`.
+var subzyLineRe = regexp.MustCompile(`(?i)^\[\s*([a-z ]+?)\s*\]\s*-\s*(\S+)(?:\s*\[\s*([^\]]*?)\s*\])?`)
+
+// takeoverDetailRe matches the Detail takeoverResult and the fingerprint
+// checks write.
+var takeoverDetailRe = regexp.MustCompile(`^Service: (.*) \((?:subzy|subjack|fingerprint)\)`)
+
+// takeoverResult builds the finding for a subdomain whose CNAME target can
+// be claimed on service.
+func takeoverResult(host, service, tool string) types.VulnerabilityResult {
+	if service == "" {
+		service = "unknown service"
+	}
+	return types.VulnerabilityResult{
+		URL:    utils.OriginURL("https", host, 0),
+		Issue:  "Subdomain Takeover",
+		Type:   "subdomain-takeover",
+		Detail: fmt.Sprintf("Service: %s (%s)", service, tool),
+	}
+}
+
+// TakeoverService returns the service a takeover finding names, as the
+// checker reported it; empty when it did not say.
+func TakeoverService(v types.VulnerabilityResult) string {
+	m := takeoverDetailRe.FindStringSubmatch(v.Detail)
+	if m == nil || m[1] == "unknown service" {
+		return ""
+	}
+	return m[1]
+}
+
+// ParseSubzyOutput extracts takeover findings from subzy's plain output.
+// Only "VULNERABLE" lines are reported; "NOT VULNERABLE", "EDGE CASE" and
+// HTTP error lines are not.
+func ParseSubzyOutput(output string) []types.VulnerabilityResult {
+	var results []types.VulnerabilityResult
+	utils.ForEachLine("subzy", output, func(line string, _ bool) {
+		m := subzyLineRe.FindStringSubmatch(strings.TrimSpace(ansiRe.ReplaceAllString(line, "")))
+		if m == nil || !strings.EqualFold(m[1], "vulnerable") {
+			return
+		}
+		results = append(results, takeoverResult(m[2], m[3], "subzy"))
+	})
+	return results
+}
+
+// subjackEntry is one element of subjack's -o results.json.
+type subjackEntry struct {
+	Subdomain  string `json:"subdomain"`
+	Vulnerable bool   `json:"vulnerable"`
+	Service    string `json:"service"`
+}
+
+// ParseSubjackJSON extracts takeover findings from subjack's JSON output,
+// skipping entries it did not mark vulnerable.
+func ParseSubjackJSON(data []byte) ([]types.VulnerabilityResult, error) {
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil, nil
+	}
+	var entries []subjackEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("subjack output: %v", err)
+	}
+	var results []types.VulnerabilityResult
+	for _, e := range entries {
+		if e.Vulnerable && e.Subdomain != "" {
+			results = append(results, takeoverResult(e.Subdomain, e.Service, "subjack"))
+		}
+	}
+	return results, nil
+}
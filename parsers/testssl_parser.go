@@ -0,0 +1,71 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// testsslEntry is one element of testssl.sh's flat --jsonfile output.
+type testsslEntry struct {
+	ID       string `json:"id"`
+	IP       string `json:"ip"`
+	Port     string `json:"port"`
+	Severity string `json:"severity"`
+	CVE      string `json:"cve"`
+	Finding  string `json:"finding"`
+}
+
+// testsslReported are the severities worth reporting; OK, INFO, LOW and
+// the scanner's own DEBUG, WARN and FATAL entries are dropped.
+var testsslReported = map[string]bool{"MEDIUM": true, "HIGH": true, "CRITICAL": true}
+
+// ParseTestsslJSON extracts the MEDIUM, HIGH and CRITICAL findings from a
+// testssl.sh --jsonfile report of host. testssl.sh appends to the array as
+// it goes, so a run that was killed leaves it unterminated; entries are
+// decoded one at a time and those before the cut are kept. The "ip" field
+// reads "hostname/address"; its hostname part wins over host when present,
+// and a port other than 443 is kept with it.
+func ParseTestsslJSON(data []byte, host string) ([]types.TLSResult, error) {
+	data = bytes.TrimSpace(data)
+	data = bytes.TrimPrefix(data, []byte("["))
+	data = bytes.TrimSuffix(data, []byte("]"))
+	var results []types.TLSResult
+	for {
+		data = bytes.TrimLeft(data, " \t\r\n,")
+		if len(data) == 0 {
+			return results, nil
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		var e testsslEntry
+		if err := dec.Decode(&e); err != nil {
+			return results, fmt.Errorf("testssl.sh output truncated: %v", err)
+		}
+		data = data[dec.InputOffset():]
+		severity := strings.ToUpper(e.Severity)
+		if !testsslReported[severity] {
+			continue
+		}
+		h := host
+		if name := strings.SplitN(e.IP, "/", 2)[0]; name != "" {
+			h = name
+			if e.Port != "" && e.Port != "443" {
+				h = net.JoinHostPort(name, e.Port)
+			}
+		}
+		finding := e.Finding
+		if e.CVE != "" {
+			finding += " (" + e.CVE + ")"
+		}
+		results = append(results, types.TLSResult{
+			Host:     h,
+			ID:       e.ID,
+			Severity: severity,
+			Finding:  finding,
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package parsers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// trufflehogResult is the subset of a trufflehog --json result line the
+// tool uses. Raw is read only to be redacted; it is never stored.
+type trufflehogResult struct {
+	DetectorName   string `json:"DetectorName"`
+	Verified       bool   `json:"Verified"`
+	Raw            string `json:"Raw"`
+	Redacted       string `json:"Redacted"`
+	SourceMetadata struct {
+		Data struct {
+			Filesystem struct {
+				File string `json:"file"`
+				Line int    `json:"line"`
+			} `json:"Filesystem"`
+		} `json:"Data"`
+	} `json:"SourceMetadata"`
+}
+
+// ParseTrufflehogOutput converts trufflehog filesystem --json output into
+// "Exposed Secret" findings whose URL is the scanned file; the caller maps
+// files back to where they were downloaded from. Findings are deduplicated
+// on detector and file, and one is verified when any of its matches is.
+// Verified secrets are rated critical and tagged state:verified, the others
+// high. Lines that are not JSON (progress, log output) are skipped;
+// malformed JSON lines are counted and returned as an error alongside the
+// parsed results. Every match is also returned as a secret finding located
+// at the file and line.
+func ParseTrufflehogOutput(output string) ([]types.VulnerabilityResult, []types.SecretFinding, error) {
+	var results []types.VulnerabilityResult
+	var secrets []types.SecretFinding
+	index := make(map[string]int)
+	matches := make(map[string]int)
+	bad := 0
+	utils.ForEachLine("trufflehog", output, func(line string, truncated bool) {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			return
+		}
+		var r trufflehogResult
+		if truncated || json.Unmarshal([]byte(line), &r) != nil {
+			bad++
+			return
+		}
+		if r.DetectorName == "" {
+			return
+		}
+		file := r.SourceMetadata.Data.Filesystem.File
+		secret := r.Raw
+		if secret == "" {
+			secret = r.Redacted
+		}
+		loc := types.SecretLocation{Artifact: file, Detector: "trufflehog " + r.DetectorName}
+		if n := r.SourceMetadata.Data.Filesystem.Line; n > 0 {
+			loc.Location = fmt.Sprintf("line %d", n)
+		}
+		confidence, verification := utils.ConfidenceFirm, utils.SecretUnverified
+		if r.Verified {
+			confidence, verification = utils.ConfidenceConfirmed, utils.SecretVerified
+		}
+		secrets = utils.MergeSecrets(secrets, utils.NewSecretFinding(utils.SecretClass(r.DetectorName), secret, loc, confidence, verification))
+		key := r.DetectorName + "|" + file
+		matches[key]++
+		if i, seen := index[key]; seen {
+			if r.Verified && !utils.IsVerifiedSecret(results[i]) {
+				results[i].Severity = "critical"
+				results[i].Tags = utils.AddTag(results[i].Tags, utils.TagState+":verified")
+			}
+			return
+		}
+		detail := fmt.Sprintf("trufflehog %s detector matched %s", r.DetectorName, utils.MaskSecret(secret))
+		if n := r.SourceMetadata.Data.Filesystem.Line; n > 0 {
+			detail += fmt.Sprintf(" on line %d", n)
+		}
+		v := types.VulnerabilityResult{
+			URL:      file,
+			Issue:    "Exposed Secret: " + r.DetectorName,
+			Type:     "exposed-secret",
+			Detail:   detail,
+			Severity: "high",
+		}
+		if r.Verified {
+			v.Severity = "critical"
+			v.Tags = utils.AddTag(v.Tags, utils.TagState+":verified")
+		}
+		index[key] = len(results)
+		results = append(results, v)
+	})
+	for key, i := range index {
+		if n := matches[key]; n > 1 {
+			results[i].Detail += fmt.Sprintf(" (%d matches in the file)", n)
+		}
+		if utils.IsVerifiedSecret(results[i]) {
+			results[i].Detail += "; verified live by trufflehog"
+		}
+	}
+	if bad > 0 {
+		return results, secrets, fmt.Errorf("trufflehog output: %d malformed line(s) skipped", bad)
+	}
+	return results, secrets, nil
+}
@@ -0,0 +1,83 @@
+package parsers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// Raw secrets of the parser tests, put together from pieces so the
+// repository itself does not trip secret scanners.
+var (
+	testAWSKey      = "AKIA" + "Q3TZ7LWN2P5RXK8M"
+	testGitHubToken = "gh" + "p_" + "r8Kd2mQx7ZtL4vWb9NcY3hFs6JpG1aUe5oTi"
+)
+
+func trufflehogLine(detector, raw, file string, line int, verified bool) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"DetectorName": detector,
+		"Verified":     verified,
+		"Raw":          raw,
+		"SourceMetadata": map[string]interface{}{"Data": map[string]interface{}{
+			"Filesystem": map[string]interface{}{"file": file, "line": line},
+		}},
+	})
+	return string(data)
+}
+
+// assertNoRawSecrets fails when any of raw appears in v marshalled to JSON.
+func assertNoRawSecrets(t *testing.T, what string, v interface{}, raw ...string) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range raw {
+		if strings.Contains(string(data), r) {
+			t.Errorf("%s carries the raw secret %q", what, r)
+		}
+	}
+}
+
+func TestParseTrufflehogOutput(t *testing.T) {
+	output := strings.Join([]string{
+		`🐷🔑🐷  TruffleHog. Unearth your secrets. 🐷🔑🐷`,
+		trufflehogLine("AWS", testAWSKey, "/run/js/0000-main.js", 3, false),
+		trufflehogLine("AWS", testAWSKey, "/run/js/0000-main.js", 40, false),
+		trufflehogLine("Github", testGitHubToken, "/run/js/0001-app.js", 1, true),
+		trufflehogLine("Github", testGitHubToken, "/run/js/0002-vendor.js", 7, false),
+		`{"DetectorName": "AWS", "Raw": "trunc`,
+	}, "\n")
+	findings, secrets, err := ParseTrufflehogOutput(output)
+	if err == nil || err.Error() != "trufflehog output: 1 malformed line(s) skipped" {
+		t.Errorf("error %v", err)
+	}
+	if len(findings) != 3 {
+		t.Fatalf("%d finding(s): %+v", len(findings), findings)
+	}
+	if f := findings[0]; f.URL != "/run/js/0000-main.js" || f.Issue != "Exposed Secret: AWS" || f.Severity != "high" ||
+		f.Detail != "trufflehog AWS detector matched AKIA********XK8M (20 chars) on line 3 (2 matches in the file)" {
+		t.Errorf("AWS finding %+v", f)
+	}
+	if f := findings[1]; !utils.IsVerifiedSecret(f) || f.Severity != "critical" || !strings.HasSuffix(f.Detail, "; verified live by trufflehog") {
+		t.Errorf("verified finding %+v", f)
+	}
+
+	// The secrets are one per value, located at every match.
+	if len(secrets) != 2 {
+		t.Fatalf("%d secret(s): %+v", len(secrets), secrets)
+	}
+	aws, gh := secrets[0], secrets[1]
+	if aws.Class != "aws" || aws.Masked != "AKIA********XK8M (20 chars)" || aws.Verification != utils.SecretUnverified || aws.Confidence != utils.ConfidenceFirm ||
+		len(aws.Locations) != 2 || aws.Locations[1] != (types.SecretLocation{Artifact: "/run/js/0000-main.js", Location: "line 40", Detector: "trufflehog AWS"}) {
+		t.Errorf("AWS secret %+v", aws)
+	}
+	if gh.Class != "github" || gh.Verification != utils.SecretVerified || gh.Confidence != utils.ConfidenceConfirmed || len(gh.Locations) != 2 {
+		t.Errorf("GitHub secret %+v", gh)
+	}
+	assertNoRawSecrets(t, "trufflehog findings", findings, testAWSKey, testGitHubToken)
+	assertNoRawSecrets(t, "trufflehog secrets", secrets, testAWSKey, testGitHubToken)
+}
@@ -0,0 +1,36 @@
+package parsers
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+var (
+	// wafBehindRe matches "The site https://example.com is behind
+	// Cloudflare (Cloudflare Inc.) WAF."
+	wafBehindRe = regexp.MustCompile(`The site (\S+) is behind (.+?)(?: WAF)?\.?$`)
+	// wafGenericRe matches the verdict of wafw00f's generic detection,
+	// which sees a WAF without naming it.
+	wafGenericRe = regexp.MustCompile(`The site (\S+) seems to be behind a WAF`)
+)
+
+// ParseWafw00fOutput maps every URL wafw00f found behind a WAF to the WAF's
+// name, "Generic WAF" when only the generic detection fired. URLs are
+// keyed without a trailing slash; sites without a WAF are left out.
+func ParseWafw00fOutput(output string) map[string]string {
+	wafs := make(map[string]string)
+	utils.ForEachLine("wafw00f", output, func(line string, _ bool) {
+		line = strings.TrimSpace(ansiRe.ReplaceAllString(line, ""))
+		if m := wafBehindRe.FindStringSubmatch(line); m != nil {
+			wafs[strings.TrimSuffix(m[1], "/")] = strings.TrimSpace(m[2])
+		} else if m := wafGenericRe.FindStringSubmatch(line); m != nil {
+			url := strings.TrimSuffix(m[1], "/")
+			if _, named := wafs[url]; !named {
+				wafs[url] = "Generic WAF"
+			}
+		}
+	})
+	return wafs
+}
@@ -0,0 +1,142 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// whatwebRecord is one element of whatweb's --log-json output.
+type whatwebRecord struct {
+	Target  string                            `json:"target"`
+	Plugins map[string]map[string]interface{} `json:"plugins"`
+}
+
+// whatwebDescriptive lists plugins that describe the page or its headers
+// rather than the software behind it; HTTPServer repeats the Server
+// header the HTTP probe already records.
+var whatwebDescriptive = map[string]bool{
+	"Country": true, "IP": true, "Title": true, "HTTPServer": true,
+	"RedirectLocation": true, "UncommonHeaders": true, "Email": true,
+	"Cookies": true, "HttpOnly": true, "Script": true, "HTML5": true,
+	"Frame": true, "PasswordField": true, "Meta-Author": true,
+	"Meta-Refresh-Redirect": true, "Open-Graph-Protocol": true,
+	"X-Frame-Options": true, "X-XSS-Protection": true, "X-UA-Compatible": true,
+	"Strict-Transport-Security": true, "Content-Language": true,
+}
+
+// whatwebValue normalizes one value of a plugin field. whatweb emits
+// numbers as well as strings, and some plugins wrap versions in brackets
+// or nested arrays.
+func whatwebValue(v interface{}) string {
+	var s string
+	switch t := v.(type) {
+	case string:
+		s = t
+	case []interface{}:
+		var parts []string
+		for _, e := range t {
+			if p := whatwebValue(e); p != "" {
+				parts = append(parts, p)
+			}
+		}
+		s = strings.Join(parts, " ")
+	case nil:
+		return ""
+	default:
+		s = fmt.Sprint(t)
+	}
+	return strings.TrimSpace(strings.Trim(strings.TrimSpace(s), `[]"'`))
+}
+
+// whatwebField joins the distinct normalized values of a plugin field,
+// usually an array.
+func whatwebField(field interface{}) string {
+	values, ok := field.([]interface{})
+	if !ok {
+		values = []interface{}{field}
+	}
+	var out []string
+	for _, v := range values {
+		if s := whatwebValue(v); s != "" {
+			out = append(out, s)
+		}
+	}
+	return strings.Join(utils.UniqueStrings(out), ", ")
+}
+
+// ParseWhatwebJSON reads whatweb's --log-json output into the stack of
+// each target, plugins sorted by name and descriptive plugins left out.
+// whatweb writes a JSON array that is left unterminated when the run is
+// interrupted, so records are decoded one at a time like masscan's. A
+// target whatweb reported more than once, such as after a redirect to
+// itself, has its plugins merged.
+func ParseWhatwebJSON(data []byte) ([]types.WebTechnologies, error) {
+	data = bytes.TrimSpace(data)
+	data = bytes.TrimPrefix(data, []byte("["))
+	data = bytes.TrimSuffix(data, []byte("]"))
+	var results []types.WebTechnologies
+	var err error
+	index := make(map[string]int)
+	for {
+		data = bytes.TrimLeft(data, " \t\r\n,")
+		if len(data) == 0 {
+			break
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		var rec whatwebRecord
+		if derr := dec.Decode(&rec); derr != nil {
+			err = fmt.Errorf("whatweb output truncated after %d target(s): %v", len(results), derr)
+			break
+		}
+		data = data[dec.InputOffset():]
+		if rec.Target == "" {
+			continue
+		}
+		i, seen := index[rec.Target]
+		if !seen {
+			i = len(results)
+			index[rec.Target] = i
+			results = append(results, types.WebTechnologies{URL: rec.Target, Technologies: []types.Technology{}})
+		}
+		for name, fields := range rec.Plugins {
+			if whatwebDescriptive[name] {
+				continue
+			}
+			tech := types.Technology{
+				Name:    name,
+				Version: whatwebField(fields["version"]),
+				String:  whatwebField(fields["string"]),
+			}
+			results[i].Technologies = mergeTechnology(results[i].Technologies, tech)
+		}
+	}
+	for _, r := range results {
+		sort.Slice(r.Technologies, func(a, b int) bool {
+			return strings.ToLower(r.Technologies[a].Name) < strings.ToLower(r.Technologies[b].Name)
+		})
+	}
+	return results, err
+}
+
+// mergeTechnology adds tech to techs unless a plugin of the same name is
+// already there, filling in its missing version and string.
+func mergeTechnology(techs []types.Technology, tech types.Technology) []types.Technology {
+	for i := range techs {
+		if techs[i].Name == tech.Name {
+			if techs[i].Version == "" {
+				techs[i].Version = tech.Version
+			}
+			if techs[i].String == "" {
+				techs[i].String = tech.String
+			}
+			return techs
+		}
+	}
+	return append(techs, tech)
+}
@@ -0,0 +1,196 @@
+package parsers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// wpscanVuln is one known vulnerability of a component. wpscan only
+// reports these when it has an API token.
+type wpscanVuln struct {
+	Title      string `json:"title"`
+	FixedIn    string `json:"fixed_in"`
+	References struct {
+		CVE      []string `json:"cve"`
+		URL      []string `json:"url"`
+		WPVulnDB []string `json:"wpvulndb"`
+	} `json:"references"`
+}
+
+// wpscanComponent is the core version, a plugin or a theme.
+type wpscanComponent struct {
+	Slug     string `json:"slug"`
+	Location string `json:"location"`
+	Version  *struct {
+		Number string `json:"number"`
+	} `json:"version"`
+	Vulnerabilities []wpscanVuln `json:"vulnerabilities"`
+}
+
+// wpscanReport is the subset of wpscan --format json the tool uses.
+type wpscanReport struct {
+	ScanAborted         string `json:"scan_aborted"`
+	InterestingFindings []struct {
+		URL                string          `json:"url"`
+		ToS                string          `json:"to_s"`
+		InterestingEntries []string        `json:"interesting_entries"`
+		References         json.RawMessage `json:"references"`
+	} `json:"interesting_findings"`
+	Version *struct {
+		Number          string       `json:"number"`
+		Vulnerabilities []wpscanVuln `json:"vulnerabilities"`
+	} `json:"version"`
+	MainTheme *wpscanComponent           `json:"main_theme"`
+	Plugins   map[string]wpscanComponent `json:"plugins"`
+	Themes    map[string]wpscanComponent `json:"themes"`
+	Users     map[string]struct {
+		ID int `json:"id"`
+	} `json:"users"`
+}
+
+// wpscanVulnDetail lists each vulnerability's title, fix version and
+// references on its own line.
+func wpscanVulnDetail(vulns []wpscanVuln) string {
+	var lines []string
+	for _, v := range vulns {
+		line := v.Title
+		if v.FixedIn != "" {
+			line += " (fixed in " + v.FixedIn + ")"
+		}
+		var refs []string
+		for _, c := range v.References.CVE {
+			refs = append(refs, "CVE-"+strings.TrimPrefix(c, "CVE-"))
+		}
+		for _, id := range v.References.WPVulnDB {
+			refs = append(refs, "https://wpscan.com/vulnerability/"+id)
+		}
+		refs = append(refs, v.References.URL...)
+		if len(refs) > 0 {
+			line += "; refs: " + strings.Join(refs, ", ")
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wpscanComponentResult builds one finding for a component with known
+// vulnerabilities, named by kind, slug and version.
+func wpscanComponentResult(base, kind string, c wpscanComponent) types.VulnerabilityResult {
+	issue := "WordPress " + kind + " " + c.Slug
+	if c.Version != nil && c.Version.Number != "" {
+		issue += " " + c.Version.Number
+	}
+	target := c.Location
+	if target == "" {
+		target = base
+	}
+	return types.VulnerabilityResult{
+		URL:    target,
+		Issue:  issue,
+		Type:   "wordpress-vulnerable-component",
+		Detail: wpscanVulnDetail(c.Vulnerabilities),
+	}
+}
+
+// ParseWPScanJSON converts a wpscan --format json report of base into
+// findings: one per interesting finding, one per core version, plugin or
+// theme with known vulnerabilities, and one listing the enumerated users.
+func ParseWPScanJSON(data []byte, base string) ([]types.VulnerabilityResult, error) {
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil, nil
+	}
+	var r wpscanReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("wpscan JSON output: %v", err)
+	}
+	if r.ScanAborted != "" {
+		return nil, errors.New("wpscan aborted: " + r.ScanAborted)
+	}
+	var results []types.VulnerabilityResult
+	for _, f := range r.InterestingFindings {
+		detail := f.ToS
+		if len(f.InterestingEntries) > 0 {
+			detail += "; " + strings.Join(f.InterestingEntries, "; ")
+		}
+		var refs map[string][]string
+		if json.Unmarshal(f.References, &refs) == nil {
+			for _, u := range refs["url"] {
+				detail += "; ref: " + u
+			}
+		}
+		target := f.URL
+		if target == "" {
+			target = base
+		}
+		results = append(results, types.VulnerabilityResult{
+			URL:    target,
+			Issue:  "WordPress Interesting Finding",
+			Type:   "wordpress-interesting-finding",
+			Detail: detail,
+		})
+	}
+	if r.Version != nil && len(r.Version.Vulnerabilities) > 0 {
+		results = append(results, types.VulnerabilityResult{
+			URL:    base,
+			Issue:  "WordPress core " + r.Version.Number,
+			Type:   "wordpress-vulnerable-component",
+			Detail: wpscanVulnDetail(r.Version.Vulnerabilities),
+		})
+	}
+	// The main theme is usually listed again under themes when themes are
+	// enumerated; report it once.
+	themes := make(map[string]wpscanComponent)
+	if r.MainTheme != nil && r.MainTheme.Slug != "" {
+		themes[r.MainTheme.Slug] = *r.MainTheme
+	}
+	for slug, t := range r.Themes {
+		if t.Slug == "" {
+			t.Slug = slug
+		}
+		if _, seen := themes[t.Slug]; !seen {
+			themes[t.Slug] = t
+		}
+	}
+	for _, kind := range []struct {
+		name  string
+		items map[string]wpscanComponent
+	}{{"plugin", r.Plugins}, {"theme", themes}} {
+		slugs := make([]string, 0, len(kind.items))
+		for slug := range kind.items {
+			slugs = append(slugs, slug)
+		}
+		sort.Strings(slugs)
+		for _, slug := range slugs {
+			c := kind.items[slug]
+			if len(c.Vulnerabilities) == 0 {
+				continue
+			}
+			if c.Slug == "" {
+				c.Slug = slug
+			}
+			results = append(results, wpscanComponentResult(base, kind.name, c))
+		}
+	}
+	if len(r.Users) > 0 {
+		var users []string
+		for name, u := range r.Users {
+			if u.ID > 0 {
+				name = fmt.Sprintf("%s (id %d)", name, u.ID)
+			}
+			users = append(users, name)
+		}
+		sort.Strings(users)
+		results = append(results, types.VulnerabilityResult{
+			URL:    base,
+			Issue:  "WordPress User Enumeration",
+			Type:   "wordpress-user-enumeration",
+			Detail: "Users: " + strings.Join(users, ", "),
+		})
+	}
+	return results, nil
+}
@@ -0,0 +1,147 @@
+// scanners/arjun_scanner.go - Hidden parameter discovery with Arjun.
+package scanners
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// defaultArjunMaxURLs bounds how many URLs Arjun is run against; it sends
+// hundreds of requests per URL.
+const defaultArjunMaxURLs = 100
+
+// skipURLCap is the coverage skip reason for URLs beyond ARJUN_MAX_URLS.
+const skipURLCap = "over URL cap"
+
+// staticExts are file types that take no parameters worth guessing.
+var staticExts = map[string]bool{
+	".css": true, ".js": true, ".map": true, ".png": true, ".jpg": true, ".jpeg": true,
+	".gif": true, ".svg": true, ".ico": true, ".webp": true, ".woff": true, ".woff2": true,
+	".ttf": true, ".eot": true, ".pdf": true, ".zip": true, ".mp4": true,
+}
+
+// arjunMaxURLs reads ARJUN_MAX_URLS.
+func arjunMaxURLs() int {
+	if n, err := strconv.Atoi(os.Getenv("ARJUN_MAX_URLS")); err == nil && n > 0 {
+		return n
+	}
+	return defaultArjunMaxURLs
+}
+
+// arjunTargets picks up to max in-scope URLs without a query string from
+// the ffuf hits and crawled URLs, distinct by scheme, host and path and
+// leaving out static files. The returned coverage counts every candidate
+// as attempted or skipped with a reason.
+func arjunTargets(result *types.ScanResult, inScope func(string) bool, max int) ([]string, *types.StageCoverage) {
+	var targets []string
+	cov := &types.StageCoverage{Stage: "parameter discovery", Skipped: make(map[string]int)}
+	seen := make(map[string]bool)
+	add := func(raw string) {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" || u.RawQuery != "" || staticExts[strings.ToLower(path.Ext(u.Path))] {
+			return
+		}
+		endpoint := u.Scheme + "://" + u.Host + u.EscapedPath()
+		if seen[endpoint] {
+			return
+		}
+		seen[endpoint] = true
+		cov.Known++
+		switch {
+		case !inScope(raw):
+			cov.Skipped[skipOutOfScope]++
+		case len(targets) >= max:
+			cov.Skipped[skipURLCap]++
+		default:
+			targets = append(targets, endpoint)
+			cov.Attempted++
+		}
+	}
+	for _, f := range result.FfufEntries {
+		add(f.URL)
+	}
+	for _, u := range result.AllURLs {
+		add(u)
+	}
+	return targets, cov
+}
+
+// RunArjun runs Arjun against up to ARJUN_MAX_URLS discovered URLs that
+// have no query string yet, records the parameters it finds on
+// result.Parameters and writes them to parameters.json.
+func RunArjun(outDir string, result *types.ScanResult, inScope func(string) bool, logFn func(string)) {
+	if _, err := exec.LookPath("arjun"); err != nil {
+		logFn("[!] arjun not found in PATH; skipping parameter discovery")
+		return
+	}
+	targets, cov := arjunTargets(result, inScope, arjunMaxURLs())
+	defer func() { result.Coverage = append(result.Coverage, *cov) }()
+	if len(targets) == 0 {
+		logFn("[*] No URLs without parameters to run Arjun against.")
+		return
+	}
+	if n := cov.Skipped[skipURLCap]; n > 0 {
+		logFn(fmt.Sprintf("[*] Arjun limited to %d URL(s); %d more skipped (ARJUN_MAX_URLS)", len(targets), n))
+	}
+	logFn(fmt.Sprintf("[*] Running Arjun against %d URL(s)...", len(targets)))
+	input := filepath.Join(outDir, "arjun_targets.txt")
+	if err := utils.WriteLines(targets, input); err != nil {
+		logFn("[!] arjun skipped: " + err.Error())
+		return
+	}
+	report := filepath.Join(outDir, "arjun.json")
+	_, runErr := utils.RunCommand("arjun", "-i", input, "-oJ", report)
+	data, err := ioutil.ReadFile(report)
+	if err != nil {
+		if runErr != nil {
+			err = runErr
+		}
+		logFn("[!] arjun error: " + err.Error())
+		return
+	}
+	if runErr == nil {
+		cov.Completed = cov.Attempted
+	}
+	params, err := parsers.ParseArjunJSON(data)
+	if err != nil {
+		logFn("[!] " + err.Error())
+	}
+	for _, p := range params {
+		logFn(fmt.Sprintf("[*] Parameters of %s: %s", p.URL, strings.Join(p.Params, ", ")))
+	}
+	result.Parameters = params
+	out, _ := json.MarshalIndent(params, "", "  ")
+	_ = ioutil.WriteFile(filepath.Join(outDir, "parameters.json"), out, 0644)
+	logFn(fmt.Sprintf("[*] Parameter discovery complete, %d URL(s) with parameters.", len(params)))
+}
+
+// ParameterizedURLs turns discovered parameters into URLs that set each of
+// them, for injection testing.
+func ParameterizedURLs(params []types.EndpointParameters) []string {
+	var urls []string
+	for _, p := range params {
+		u, err := url.Parse(p.URL)
+		if err != nil {
+			continue
+		}
+		q := u.Query()
+		for _, name := range p.Params {
+			q.Set(name, "1")
+		}
+		u.RawQuery = q.Encode()
+		urls = append(urls, u.String())
+	}
+	return urls
+}
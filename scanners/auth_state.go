@@ -0,0 +1,433 @@
+// scanners/auth_state.go - Whether discovered pages sit behind a login, and what that means for later stages.
+package scanners
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/bits"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// Authentication states of a page: it asks for a login, anyone can read
+// it, or it only shows its content to the configured credentials.
+const (
+	AuthLoginWall     = "login-wall"
+	AuthPublic        = "public"
+	AuthAuthenticated = "authenticated"
+)
+
+const (
+	defaultAuthStateMaxURLs = 200
+	// authStatePerHost bounds the pages classified per host.
+	authStatePerHost = 20
+	// skipLoginWall is the coverage skip reason of endpoints behind a login.
+	skipLoginWall = "behind a login wall"
+)
+
+// Access tags the authentication state puts on findings; CVSS scoring
+// reads the authenticated one.
+var (
+	unauthenticatedTag = utils.TagAccess + ":unauthenticated"
+	authenticatedTag   = utils.TagAccess + ":authenticated"
+)
+
+// authStateMaxURLs reads AUTH_STATE_MAX_URLS, how many pages are
+// classified in a run.
+func authStateMaxURLs() int {
+	if n, err := strconv.Atoi(os.Getenv("AUTH_STATE_MAX_URLS")); err == nil && n > 0 {
+		return n
+	}
+	return defaultAuthStateMaxURLs
+}
+
+// ParseAuthHeaders turns "Name: value" lines (--auth-header) into the
+// headers of an authenticated request.
+func ParseAuthHeaders(lines []string) (http.Header, error) {
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	h := make(http.Header)
+	for _, line := range lines {
+		i := strings.Index(line, ":")
+		if i <= 0 || strings.TrimSpace(line[i+1:]) == "" || strings.ContainsAny(line[:i], " \t") {
+			return nil, fmt.Errorf("auth header %q is not \"Name: value\"", line)
+		}
+		h.Add(line[:i], strings.TrimSpace(line[i+1:]))
+	}
+	return h, nil
+}
+
+var (
+	// formRe matches a form and its content.
+	formRe = regexp.MustCompile(`(?is)<form\b[^>]*>(.*?)</form>`)
+	// passwordInputRe matches a password field.
+	passwordInputRe = regexp.MustCompile(`(?i)<input\b[^>]*\btype\s*=\s*["']?password\b`)
+	// loginPathRe matches the paths of login pages and identity providers.
+	loginPathRe = regexp.MustCompile(`(?i)(^|/)(log-?in|log-?on|sign-?in|signin|users/sign_in|auth|authenticate|sso|saml2?|cas/login|oauth2?/authorize|wp-login\.php|login\.(php|aspx|jsp|html?))(/|$)`)
+	// loginHostRe matches the hosts of identity providers.
+	loginHostRe = regexp.MustCompile(`(?i)^(login|signin|sso|auth|accounts|idp)\.`)
+)
+
+// HasLoginForm reports whether body holds a login form: a form with one
+// password field. Sign-up and password change forms, which repeat it, do
+// not count. Pages without form elements, as single-page apps render
+// them, count when they have a single password field.
+func HasLoginForm(body []byte) bool {
+	forms := formRe.FindAllSubmatch(body, -1)
+	if len(forms) == 0 {
+		return len(passwordInputRe.FindAll(body, 2)) == 1
+	}
+	for _, f := range forms {
+		if len(passwordInputRe.FindAll(f[1], 2)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// LoginRedirect reports whether location, resolved against page, leads to
+// a login page or an identity provider.
+func LoginRedirect(page, location string) bool {
+	if location == "" {
+		return false
+	}
+	loc, err := url.Parse(location)
+	if err != nil {
+		return false
+	}
+	if base, err := url.Parse(page); err == nil {
+		loc = base.ResolveReference(loc)
+	}
+	return loginPathRe.MatchString(loc.Path) || loginHostRe.MatchString(loc.Hostname())
+}
+
+// ClassifyAuthPage classifies the unauthenticated answer to page: a 401,
+// or a 403 asking for credentials with WWW-Authenticate, a redirect to a
+// login page and a page with a login form are login walls; other answers
+// below 400 are public. Other errors say nothing and return "". reason
+// says what decided, or gives the status.
+func ClassifyAuthPage(page string, status int, header http.Header, body []byte) (state, reason string) {
+	switch {
+	case status == http.StatusUnauthorized:
+		if challenge := header.Get("WWW-Authenticate"); challenge != "" {
+			return AuthLoginWall, "401 with WWW-Authenticate: " + strings.Fields(challenge)[0]
+		}
+		return AuthLoginWall, "401"
+	case status == http.StatusForbidden && header.Get("WWW-Authenticate") != "":
+		return AuthLoginWall, "403 with WWW-Authenticate: " + strings.Fields(header.Get("WWW-Authenticate"))[0]
+	case status >= 300 && status < 400:
+		if LoginRedirect(page, header.Get("Location")) {
+			return AuthLoginWall, fmt.Sprintf("%d to %s", status, header.Get("Location"))
+		}
+		return AuthPublic, fmt.Sprintf("%d", status)
+	case status >= 400:
+		return "", fmt.Sprintf("%d", status)
+	case HasLoginForm(body):
+		return AuthLoginWall, "login form"
+	}
+	return AuthPublic, fmt.Sprintf("%d", status)
+}
+
+// authPage is one fetch of a page, normalized for comparison.
+type authPage struct {
+	status   int
+	location string
+	page     soft404Page
+	header   http.Header
+	body     []byte
+}
+
+func fetchAuthPage(client *http.Client, raw string, header http.Header) (authPage, error) {
+	req, err := http.NewRequest(http.MethodGet, raw, nil)
+	if err != nil {
+		return authPage{}, err
+	}
+	for name, values := range header {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return authPage{}, err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, httpProbeMaxBody))
+	return authPage{
+		status:   resp.StatusCode,
+		location: resp.Header.Get("Location"),
+		page:     newSoft404Page(resp.StatusCode, body, ""),
+		header:   resp.Header,
+		body:     body,
+	}, nil
+}
+
+// AuthenticatedDiffers reports whether the authenticated fetch of a page
+// shows content the unauthenticated one did not: the anonymous visitor was
+// stopped at a login wall or an error the credentials got past, or both
+// got a page and the pages differ beyond what changes between loads.
+func AuthenticatedDiffers(raw string, anon, authed authPage, anonState string) bool {
+	if authed.status >= 400 {
+		return false
+	}
+	authedState, _ := ClassifyAuthPage(raw, authed.status, authed.header, authed.body)
+	if authedState == AuthLoginWall {
+		return false
+	}
+	if anonState == AuthLoginWall {
+		return true
+	}
+	if anon.status != authed.status {
+		return true
+	}
+	if anon.status >= 300 {
+		return anon.location != authed.location
+	}
+	return bits.OnesCount64(anon.page.hash^authed.page.hash) > soft404MaxDistance
+}
+
+// classifyURL fetches raw without credentials and, when authHeaders are
+// set, with them, and returns its authentication state and the page as
+// fetched without credentials.
+func classifyURL(client *http.Client, raw string, authHeaders http.Header) (state, reason string, anon authPage, err error) {
+	anon, err = fetchAuthPage(client, raw, nil)
+	if err != nil {
+		return "", "", anon, err
+	}
+	state, reason = ClassifyAuthPage(raw, anon.status, anon.header, anon.body)
+	if len(authHeaders) == 0 {
+		return state, reason, anon, nil
+	}
+	authed, err := fetchAuthPage(client, raw, authHeaders)
+	if err == nil && AuthenticatedDiffers(raw, anon, authed, state) {
+		return AuthAuthenticated, fmt.Sprintf("%s without credentials, %d with them", reason, authed.status), anon, nil
+	}
+	return state, reason, anon, nil
+}
+
+// authEndpoint is the key pages are classified under: scheme, host and
+// path.
+func authEndpoint(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	return strings.ToLower(u.Scheme+"://"+u.Host) + p
+}
+
+// authStates maps the endpoints of the classified pages to their state.
+func authStates(result *types.ScanResult) map[string]string {
+	states := make(map[string]string)
+	for _, lh := range result.LiveHosts {
+		if lh.Auth != "" && lh.URL != "" {
+			states[authEndpoint(lh.URL)] = lh.Auth
+		}
+	}
+	for _, r := range result.URLRecords {
+		if r.Auth != "" {
+			states[authEndpoint(r.URL)] = r.Auth
+		}
+	}
+	return states
+}
+
+// AuthStateOf returns the authentication state of the page at raw, or ""
+// when it was not classified. A host's root page says nothing of its
+// other pages.
+func AuthStateOf(result *types.ScanResult, raw string) string {
+	return authStateIn(authStates(result), raw)
+}
+
+func authStateIn(states map[string]string, raw string) string {
+	return states[authEndpoint(raw)]
+}
+
+// RunAuthStateChecks classifies the probed hosts' root pages and up to
+// AUTH_STATE_MAX_URLS discovered URLs as login walls, public or, when
+// authHeaders are configured and the page differs with them,
+// authenticated. The state is stored on the LiveHost and the URLRecord;
+// URLs without a record, found by the crawlers, get one with source
+// crawl.
+func RunAuthStateChecks(client *http.Client, result *types.ScanResult, inScope func(string) bool, authHeaders http.Header, logFn func(string)) {
+	logFn("[*] Classifying pages as login walls, public or authenticated...")
+	probe := noRedirectClient(client)
+	maxURLs := authStateMaxURLs()
+	cov := &types.StageCoverage{Stage: "URL verification", Skipped: make(map[string]int)}
+	sample := make(map[string][]*url.URL)
+	seen := make(map[string]bool)
+	add := func(raw string) {
+		key := authEndpoint(raw)
+		if key == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		u, _ := url.Parse(raw)
+		cov.Known++
+		switch {
+		case !inScope(raw):
+			cov.Skipped[skipOutOfScope]++
+		case len(sample[u.Host]) >= authStatePerHost || cov.Attempted >= maxURLs:
+			cov.Skipped[skipPerHostCap]++
+		default:
+			sample[u.Host] = append(sample[u.Host], u)
+			cov.Attempted++
+		}
+	}
+	for _, lh := range result.LiveHosts {
+		if lh.URL != "" {
+			add(strings.TrimSuffix(lh.URL, "/") + "/")
+		}
+	}
+	for _, u := range result.AllURLs {
+		add(u)
+	}
+
+	var mu sync.Mutex
+	states := make(map[string]string)
+	counts := make(map[string]int)
+	// The pages fetched are checked for mixed content on the way; an
+	// element repeated across a host's pages is reported once.
+	var mixed []types.VulnerabilityResult
+	mixedSeen := make(map[string]bool)
+	forEachEndpoint(sample, cov, func(u *url.URL) bool {
+		state, reason, anon, err := classifyURL(probe, u.String(), authHeaders)
+		if err != nil {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if anon.status >= 200 && anon.status < 300 {
+			for _, v := range MixedContentFindings(u.String(), anon.body) {
+				if key := u.Host + "\x00" + v.Detail; !mixedSeen[key] {
+					mixedSeen[key] = true
+					mixed = append(mixed, v)
+				}
+			}
+		}
+		if state != "" {
+			states[authEndpoint(u.String())] = state
+			counts[state]++
+			if state == AuthLoginWall && (u.Path == "" || u.Path == "/") {
+				logFn(fmt.Sprintf("[*] %s is behind a login wall (%s)", u.Host, reason))
+			}
+		}
+		return true
+	})
+
+	for i := range result.LiveHosts {
+		lh := &result.LiveHosts[i]
+		if lh.URL != "" {
+			lh.Auth = states[authEndpoint(strings.TrimSuffix(lh.URL, "/")+"/")]
+		}
+	}
+	recorded := make(map[string]bool)
+	for i := range result.URLRecords {
+		r := &result.URLRecords[i]
+		key := authEndpoint(r.URL)
+		recorded[key] = true
+		if s, ok := states[key]; ok {
+			r.Auth = s
+		}
+	}
+	for _, u := range result.AllURLs {
+		key := authEndpoint(u)
+		if s, ok := states[key]; ok && !recorded[key] {
+			recorded[key] = true
+			result.URLRecords = append(result.URLRecords, types.URLRecord{URL: u, Source: "crawl", Auth: s})
+		}
+	}
+	result.Coverage = append(result.Coverage, *cov)
+	result.VulnURLs = append(result.VulnURLs, mixed...)
+	logFn(fmt.Sprintf("[*] URL verification complete: %d public, %d authenticated, %d behind a login wall.",
+		counts[AuthPublic], counts[AuthAuthenticated], counts[AuthLoginWall]))
+	if len(mixed) > 0 {
+		logFn(fmt.Sprintf("[!] %d insecure element(s) on HTTPS pages: mixed content or forms posting over HTTP.", len(mixed)))
+	}
+}
+
+// splitByAuthState splits urls into the pages found public or
+// authenticated, those not classified and those behind a login wall,
+// keeping their order.
+func splitByAuthState(states map[string]string, urls []string) (reachable, unknown, walled []string) {
+	for _, u := range urls {
+		switch authStateIn(states, u) {
+		case AuthLoginWall:
+			walled = append(walled, u)
+		case AuthPublic, AuthAuthenticated:
+			reachable = append(reachable, u)
+		default:
+			unknown = append(unknown, u)
+		}
+	}
+	return reachable, unknown, walled
+}
+
+// PreferReachable orders urls for the active checks: public and
+// authenticated pages first, then those not classified. Pages behind a
+// login wall are left out, since every request to them only hits the
+// login; skipped counts them.
+func PreferReachable(result *types.ScanResult, urls []string) (ordered []string, skipped int) {
+	reachable, unknown, walled := splitByAuthState(authStates(result), urls)
+	return append(reachable, unknown...), len(walled)
+}
+
+// dropLoginRedirects leaves out the fuzzing hits that only redirect to a
+// login page: they are the login wall, not endpoints.
+func dropLoginRedirects(entries []types.FfufResult) (kept []types.FfufResult, dropped int) {
+	for _, e := range entries {
+		if e.Status >= 300 && e.Status < 400 && LoginRedirect(e.URL, e.RedirectLocation) {
+			dropped++
+			continue
+		}
+		kept = append(kept, e)
+	}
+	return kept, dropped
+}
+
+// AnnotateAuthState marks each finding on a classified page: public ones
+// are reachable unauthenticated, tagged access:unauthenticated, and those
+// behind a login or only shown with credentials are tagged
+// access:authenticated, which lowers their CVSS score. Findings already
+// carrying an access tag are left alone.
+func AnnotateAuthState(result *types.ScanResult) int {
+	states := authStates(result)
+	if len(states) == 0 {
+		return 0
+	}
+	annotated := 0
+	for i := range result.VulnURLs {
+		v := &result.VulnURLs[i]
+		if utils.HasTag(v.Tags, unauthenticatedTag) || utils.HasTag(v.Tags, authenticatedTag) {
+			continue
+		}
+		switch authStateIn(states, v.URL) {
+		case AuthPublic:
+			v.Tags = utils.AddTag(v.Tags, unauthenticatedTag)
+			if v.Detail == "" {
+				v.Detail = "reachable unauthenticated"
+			} else {
+				v.Detail = strings.TrimSuffix(v.Detail, ".") + " (reachable unauthenticated)"
+			}
+		case AuthLoginWall, AuthAuthenticated:
+			v.Tags = utils.AddTag(v.Tags, authenticatedTag)
+		default:
+			continue
+		}
+		annotated++
+	}
+	return annotated
+}
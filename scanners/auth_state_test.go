@@ -0,0 +1,296 @@
+package scanners
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+func readAuthFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "auth", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestHasLoginForm(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    bool
+	}{
+		{"login.html", true},
+		// Sign-up forms repeat the password field.
+		{"signup.html", false},
+		// Single-page apps render fields without a form element.
+		{"spa_login.html", true},
+		{"article.html", false},
+		{"dashboard.html", false},
+	}
+	for _, tt := range tests {
+		if got := HasLoginForm(readAuthFixture(t, tt.fixture)); got != tt.want {
+			t.Errorf("HasLoginForm(%s) = %v, want %v", tt.fixture, got, tt.want)
+		}
+	}
+}
+
+func TestLoginRedirect(t *testing.T) {
+	const page = "https://app.example.com/account/billing"
+	tests := []struct {
+		location string
+		want     bool
+	}{
+		{"/login?next=%2Faccount%2Fbilling", true},
+		{"https://app.example.com/users/sign_in", true},
+		{"../auth/", true},
+		{"/wp-login.php?redirect_to=x", true},
+		{"https://login.microsoftonline.com/common/oauth2/authorize?client_id=1", true},
+		{"https://accounts.example.com/o/oauth2/auth", true},
+		{"/account/billing/", false},
+		{"/blog/login-tips-for-admins", false},
+		{"https://www.example.com/", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := LoginRedirect(page, tt.location); got != tt.want {
+			t.Errorf("LoginRedirect(%q) = %v, want %v", tt.location, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyAuthPage(t *testing.T) {
+	const page = "https://app.example.com/reports"
+	login, article := readAuthFixture(t, "login.html"), readAuthFixture(t, "article.html")
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		body   []byte
+		state  string
+		reason string
+	}{
+		{"basic auth", 401, http.Header{"Www-Authenticate": {`Basic realm="admin"`}}, nil, AuthLoginWall, "401 with WWW-Authenticate: Basic"},
+		{"bare 401", 401, nil, nil, AuthLoginWall, "401"},
+		{"403 challenge", 403, http.Header{"Www-Authenticate": {`Bearer realm="api"`}}, nil, AuthLoginWall, "403 with WWW-Authenticate: Bearer"},
+		{"plain 403", 403, nil, nil, "", "403"},
+		{"not found", 404, nil, nil, "", "404"},
+		{"redirect to login", 302, http.Header{"Location": {"/login?next=/reports"}}, nil, AuthLoginWall, "302 to /login?next=/reports"},
+		{"other redirect", 301, http.Header{"Location": {"/reports/"}}, nil, AuthPublic, "301"},
+		{"login form", 200, nil, login, AuthLoginWall, "login form"},
+		{"article", 200, nil, article, AuthPublic, "200"},
+	}
+	for _, tt := range tests {
+		header := tt.header
+		if header == nil {
+			header = http.Header{}
+		}
+		state, reason := ClassifyAuthPage(page, tt.status, header, tt.body)
+		if state != tt.state || reason != tt.reason {
+			t.Errorf("%s: %q (%s), want %q (%s)", tt.name, state, reason, tt.state, tt.reason)
+		}
+	}
+}
+
+// authFixtureServer serves the fixtures, showing some pages differently
+// to requests carrying the session cookie.
+func authFixtureServer(t *testing.T) *httptest.Server {
+	login, article, dashboard := readAuthFixture(t, "login.html"), readAuthFixture(t, "article.html"), readAuthFixture(t, "dashboard.html")
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authed := r.Header.Get("Cookie") == "session=valid"
+		switch r.URL.Path {
+		case "/":
+			w.Write(article)
+		case "/login":
+			w.Write(login)
+		case "/account":
+			if !authed {
+				http.Redirect(w, r, "/login?next=/account", http.StatusFound)
+				return
+			}
+			w.Write(dashboard)
+		case "/profile":
+			// The same address greets visitors and shows members their data.
+			if authed {
+				w.Write(dashboard)
+				return
+			}
+			w.Write(article)
+		case "/admin":
+			if !authed {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			w.Write(dashboard)
+		case "/news":
+			// Only the time changes between loads.
+			fmt.Fprintf(w, "%s<p>Rendered at %d</p>", article, time.Now().UnixNano())
+		case "/basic":
+			w.Header().Set("WWW-Authenticate", `Basic realm="ops"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestClassifyURL(t *testing.T) {
+	srv := authFixtureServer(t)
+	defer srv.Close()
+	client := noRedirectClient(srv.Client())
+	creds := http.Header{"Cookie": {"session=valid"}}
+	tests := []struct {
+		path         string
+		anon, authed string
+		authedReason string
+	}{
+		{"/", AuthPublic, AuthPublic, ""},
+		{"/login", AuthLoginWall, AuthLoginWall, ""},
+		{"/account", AuthLoginWall, AuthAuthenticated, "302 to /login?next=/account without credentials, 200 with them"},
+		{"/profile", AuthPublic, AuthAuthenticated, "200 without credentials, 200 with them"},
+		{"/admin", "", AuthAuthenticated, "403 without credentials, 200 with them"},
+		{"/news", AuthPublic, AuthPublic, ""},
+		// The credentials do not fit this realm.
+		{"/basic", AuthLoginWall, AuthLoginWall, ""},
+		{"/missing", "", "", ""},
+	}
+	for _, tt := range tests {
+		state, _, _, err := classifyURL(client, srv.URL+tt.path, nil)
+		if err != nil || state != tt.anon {
+			t.Errorf("%s without credentials: %q, %v; want %q", tt.path, state, err, tt.anon)
+		}
+		state, reason, _, err := classifyURL(client, srv.URL+tt.path, creds)
+		if err != nil || state != tt.authed || (tt.authedReason != "" && reason != tt.authedReason) {
+			t.Errorf("%s with credentials: %q (%s), %v; want %q (%s)", tt.path, state, reason, err, tt.authed, tt.authedReason)
+		}
+	}
+}
+
+func TestParseAuthHeaders(t *testing.T) {
+	h, err := ParseAuthHeaders([]string{"Cookie: session=valid", "authorization:  Bearer abc "})
+	if err != nil || h.Get("Cookie") != "session=valid" || h.Get("Authorization") != "Bearer abc" {
+		t.Errorf("headers %v, %v", h, err)
+	}
+	if h, err := ParseAuthHeaders(nil); h != nil || err != nil {
+		t.Errorf("no headers: %v, %v", h, err)
+	}
+	for _, bad := range []string{"Cookie", ": value", "Cookie:", "Bad Name: x"} {
+		if _, err := ParseAuthHeaders([]string{bad}); err == nil {
+			t.Errorf("ParseAuthHeaders(%q) accepted", bad)
+		}
+	}
+}
+
+func TestRunAuthStateChecks(t *testing.T) {
+	srv := authFixtureServer(t)
+	defer srv.Close()
+	result := &types.ScanResult{
+		LiveHosts: []types.LiveHost{{Hostname: "127.0.0.1", URL: srv.URL}},
+		AllURLs:   []string{srv.URL + "/account", srv.URL + "/login?next=/", srv.URL + "/admin", srv.URL + "/missing", "https://out.of.scope/x"},
+		URLRecords: []types.URLRecord{
+			{URL: srv.URL + "/account", Source: "linkfinder", Referrer: srv.URL + "/static/app.js"},
+		},
+	}
+	inScope := func(u string) bool { return strings.HasPrefix(u, srv.URL) }
+	var logs []string
+	RunAuthStateChecks(srv.Client(), result, inScope, http.Header{"Cookie": {"session=valid"}}, func(s string) { logs = append(logs, s) })
+
+	if result.LiveHosts[0].Auth != AuthPublic {
+		t.Errorf("host state %q", result.LiveHosts[0].Auth)
+	}
+	var got []string
+	for _, r := range result.URLRecords {
+		got = append(got, strings.TrimPrefix(r.URL, srv.URL)+" "+r.Source+" "+r.Auth)
+	}
+	want := []string{"/account linkfinder authenticated", "/login?next=/ crawl login-wall", "/admin crawl authenticated"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("records %q, want %q", got, want)
+	}
+	cov := result.Coverage[len(result.Coverage)-1]
+	if cov.Stage != "URL verification" || cov.Known != 6 || cov.Attempted != 5 || cov.Completed != 5 || cov.Skipped[skipOutOfScope] != 1 {
+		t.Errorf("coverage %+v", cov)
+	}
+	if last := logs[len(logs)-1]; last != "[*] URL verification complete: 1 public, 2 authenticated, 1 behind a login wall." {
+		t.Errorf("summary %q", last)
+	}
+}
+
+func TestPreferReachableAndSampling(t *testing.T) {
+	result := &types.ScanResult{
+		FfufEntries: []types.FfufResult{{URL: "https://a.example.com/login"}},
+		AllURLs:     []string{"https://a.example.com/unknown", "https://a.example.com/news?id=1", "https://a.example.com/account?tab=2"},
+		URLRecords: []types.URLRecord{
+			{URL: "https://a.example.com/login", Auth: AuthLoginWall},
+			{URL: "https://a.example.com/news?id=1", Auth: AuthPublic},
+			{URL: "https://a.example.com/account", Auth: AuthAuthenticated},
+		},
+	}
+	ordered, skipped := PreferReachable(result, append([]string{"https://a.example.com/login"}, result.AllURLs...))
+	want := []string{"https://a.example.com/news?id=1", "https://a.example.com/account?tab=2", "https://a.example.com/unknown"}
+	if !reflect.DeepEqual(ordered, want) || skipped != 1 {
+		t.Errorf("ordered %q, skipped %d", ordered, skipped)
+	}
+
+	sample, cov := sampleEndpoints("test", result, func(string) bool { return true }, nil)
+	var sampled []string
+	for _, u := range sample["a.example.com"] {
+		sampled = append(sampled, u.String())
+	}
+	if !reflect.DeepEqual(sampled, want) || cov.Skipped[skipLoginWall] != 1 || cov.Known != 4 {
+		t.Errorf("sampled %q, coverage %+v", sampled, cov)
+	}
+}
+
+func TestDropLoginRedirects(t *testing.T) {
+	entries := []types.FfufResult{
+		{URL: "https://a.example.com/admin", Status: 302, RedirectLocation: "/login?next=/admin"},
+		{URL: "https://a.example.com/docs", Status: 301, RedirectLocation: "/docs/"},
+		{URL: "https://a.example.com/api", Status: 200},
+	}
+	kept, dropped := dropLoginRedirects(entries)
+	if dropped != 1 || len(kept) != 2 || kept[0].URL != "https://a.example.com/docs" {
+		t.Errorf("kept %+v, dropped %d", kept, dropped)
+	}
+}
+
+func TestAnnotateAuthState(t *testing.T) {
+	result := &types.ScanResult{
+		URLRecords: []types.URLRecord{
+			{URL: "https://a.example.com/search", Auth: AuthPublic},
+			{URL: "https://a.example.com/account", Auth: AuthAuthenticated},
+			{URL: "https://a.example.com/admin", Auth: AuthLoginWall},
+		},
+		VulnURLs: []types.VulnerabilityResult{
+			{URL: "https://a.example.com/search?q=x", Type: "xss", Detail: "payload reflected."},
+			{URL: "https://a.example.com/account", Type: "xss"},
+			{URL: "https://a.example.com/admin", Type: "sqli"},
+			{URL: "https://a.example.com/other", Type: "sqli", Detail: "unknown page"},
+		},
+	}
+	if n := AnnotateAuthState(result); n != 3 {
+		t.Errorf("annotated %d", n)
+	}
+	v := result.VulnURLs
+	if !utils.HasTag(v[0].Tags, "access:unauthenticated") || v[0].Detail != "payload reflected (reachable unauthenticated)" {
+		t.Errorf("public finding %+v", v[0])
+	}
+	if !utils.HasTag(v[1].Tags, "access:authenticated") || !utils.HasTag(v[2].Tags, "access:authenticated") || v[1].Detail != "" {
+		t.Errorf("authenticated findings %+v", v[1:3])
+	}
+	if v[3].Tags != nil || v[3].Detail != "unknown page" {
+		t.Errorf("unclassified finding %+v", v[3])
+	}
+	// A second pass changes nothing.
+	if n := AnnotateAuthState(result); n != 0 || result.VulnURLs[0].Detail != "payload reflected (reachable unauthenticated)" {
+		t.Errorf("second pass annotated %d: %+v", n, result.VulnURLs[0])
+	}
+}
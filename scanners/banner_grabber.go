@@ -0,0 +1,305 @@
+// scanners/banner_grabber.go - Banners and versions of the non-HTTP services the port scan found.
+package scanners
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	// bannerMaxBytes is the most read from a service.
+	bannerMaxBytes = 1024
+	// defaultBannerTimeout bounds each grab, connecting included.
+	defaultBannerTimeout = 5 * time.Second
+	// bannerIdle is how long a service that has started talking may pause
+	// before its banner counts as complete.
+	bannerIdle = 300 * time.Millisecond
+	// bannerWorkers is how many services are grabbed at once.
+	bannerWorkers = 20
+	// bannerEHLOName is the name the SMTP probe greets with.
+	bannerEHLOName = "scanner.invalid"
+)
+
+// bannerProbe is what is sent to a service to get its banner.
+type bannerProbe int
+
+const (
+	// probeRead sends nothing: SSH, FTP, POP3, IMAP, MySQL and most
+	// line protocols announce themselves first.
+	probeRead bannerProbe = iota
+	// probeSMTP reads the greeting and sends EHLO, whose reply lists the
+	// server's extensions.
+	probeSMTP
+	// probeTLS completes a TLS handshake, as implicit-TLS services expect,
+	// and reads what the service sends over it.
+	probeTLS
+)
+
+func (p bannerProbe) String() string {
+	switch p {
+	case probeSMTP:
+		return "smtp"
+	case probeTLS:
+		return "tls"
+	}
+	return "read"
+}
+
+// smtpPorts are where mail servers take plaintext SMTP.
+var smtpPorts = []int{25, 587, 2525}
+
+// implicitTLSPorts are services that start with a TLS handshake: SMTPS,
+// LDAPS, FTPS, IMAPS and POP3S.
+var implicitTLSPorts = []int{465, 636, 990, 993, 995}
+
+// bannerProbeFor returns the probe for a service on port.
+func bannerProbeFor(port int) bannerProbe {
+	switch {
+	case containsInt(smtpPorts, port):
+		return probeSMTP
+	case containsInt(implicitTLSPorts, port):
+		return probeTLS
+	}
+	return probeRead
+}
+
+// bannerPort reports whether the open port p is grabbed: TCP services the
+// HTTP probes do not already cover.
+func bannerPort(p types.PortService, web []int) bool {
+	if p.State != "open" || (p.Protocol != "" && p.Protocol != "tcp") {
+		return false
+	}
+	return p.Port != 80 && p.Port != 443 && !containsInt(web, p.Port) && !httpService(p.Service)
+}
+
+// bannerSettings reads BANNER_GRAB, which set to false turns banner
+// grabbing off, and BANNER_TIMEOUT, the bound on each grab (a Go duration
+// such as "5s").
+func bannerSettings() (enabled bool, timeout time.Duration) {
+	timeout = defaultBannerTimeout
+	if d, err := time.ParseDuration(os.Getenv("BANNER_TIMEOUT")); err == nil && d > 0 {
+		timeout = d
+	}
+	return !strings.EqualFold(strings.TrimSpace(os.Getenv("BANNER_GRAB")), "false"), timeout
+}
+
+// readBanner reads from conn until bannerMaxBytes are in, the service
+// closes or pauses for bannerIdle after talking, or deadline passes.
+func readBanner(conn net.Conn, deadline time.Time) []byte {
+	var buf bytes.Buffer
+	chunk := make([]byte, bannerMaxBytes)
+	conn.SetReadDeadline(deadline)
+	for buf.Len() < bannerMaxBytes {
+		n, err := conn.Read(chunk[:bannerMaxBytes-buf.Len()])
+		buf.Write(chunk[:n])
+		if err != nil {
+			break
+		}
+		if idle := time.Now().Add(bannerIdle); idle.Before(deadline) {
+			conn.SetReadDeadline(idle)
+		}
+	}
+	return buf.Bytes()
+}
+
+// grabBanner connects to addr through DialContext, runs probe and returns
+// what the service sent, at most bannerMaxBytes. A service that accepts
+// the connection but sends nothing has an empty banner.
+func grabBanner(ctx context.Context, addr string, probe bannerProbe, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	conn, err := DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(deadline)
+	switch probe {
+	case probeTLS:
+		host, _, _ := net.SplitHostPort(addr)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host, InsecureSkipVerify: true})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, fmt.Errorf("TLS handshake: %v", err)
+		}
+		conn = tlsConn
+	case probeSMTP:
+		greeting := readBanner(conn, deadline)
+		if len(greeting) == 0 || len(greeting) == bannerMaxBytes {
+			return greeting, nil
+		}
+		if _, err := conn.Write([]byte("EHLO " + bannerEHLOName + "\r\n")); err != nil {
+			return greeting, nil
+		}
+		reply := readBanner(conn, deadline)
+		if len(greeting)+len(reply) > bannerMaxBytes {
+			reply = reply[:bannerMaxBytes-len(greeting)]
+		}
+		return append(greeting, reply...), nil
+	}
+	return readBanner(conn, deadline), nil
+}
+
+// SanitizeBanner makes a raw banner safe to log, store and show: control
+// characters, line breaks included, and invalid UTF-8 become spaces, and
+// runs of spaces collapse to one.
+func SanitizeBanner(raw []byte) string {
+	var b strings.Builder
+	for len(raw) > 0 {
+		r, size := utf8.DecodeRune(raw)
+		raw = raw[size:]
+		if r == utf8.RuneError || unicode.IsControl(r) || !unicode.IsPrint(r) {
+			r = ' '
+		}
+		b.WriteRune(r)
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// bannerVersions name the product behind a banner and capture its version,
+// where the banner gives one.
+var bannerVersions = []struct {
+	product string
+	re      *regexp.Regexp
+}{
+	{"OpenSSH", regexp.MustCompile(`^SSH-[\d.]+-OpenSSH_([\w.]+)`)},
+	{"Dropbear", regexp.MustCompile(`^SSH-[\d.]+-dropbear_([\w.]+)`)},
+	{"libssh", regexp.MustCompile(`^SSH-[\d.]+-libssh[_-]([\w.]+)`)},
+	{"Cisco SSH", regexp.MustCompile(`^SSH-[\d.]+-Cisco-([\w.]+)`)},
+	{"Exim", regexp.MustCompile(`\bExim ([\d.]+)`)},
+	{"Postfix", regexp.MustCompile(`\bESMTP Postfix\b()`)},
+	{"Sendmail", regexp.MustCompile(`\bSendmail ([\d.]+)`)},
+	{"Microsoft ESMTP", regexp.MustCompile(`Microsoft ESMTP MAIL Service(?:, Version: ([\d.]+))?`)},
+	{"vsftpd", regexp.MustCompile(`\(vsFTPd ([\d.]+)\)`)},
+	{"ProFTPD", regexp.MustCompile(`\bProFTPD ([\d.]+\w*)`)},
+	{"Pure-FTPd", regexp.MustCompile(`\bPure-FTPd\b()`)},
+	{"FileZilla Server", regexp.MustCompile(`\bFileZilla Server(?: version)? ([\d.]+\w*)`)},
+	{"Dovecot", regexp.MustCompile(`\bDovecot\b()`)},
+	{"Cyrus IMAP", regexp.MustCompile(`\bCyrus IMAP v?([\d.]+)`)},
+	{"Courier", regexp.MustCompile(`\bCourier-(?:IMAP|POP3)\b()`)},
+}
+
+// mysqlVersion matches the start of a MySQL or MariaDB server version.
+var mysqlVersion = regexp.MustCompile(`^\d+\.\d+\.\d+`)
+
+// mysqlHandshake returns the server version from a MySQL initial handshake
+// packet: a three-byte length, sequence 0, protocol 10 and the version up
+// to a NUL.
+func mysqlHandshake(raw []byte) (string, bool) {
+	if len(raw) < 6 || raw[3] != 0 || raw[4] != 10 {
+		return "", false
+	}
+	end := bytes.IndexByte(raw[5:], 0)
+	if end <= 0 {
+		return "", false
+	}
+	version := string(raw[5 : 5+end])
+	if !mysqlVersion.MatchString(version) {
+		return "", false
+	}
+	return version, true
+}
+
+// ParseBanner returns the sanitized banner and, when a known product sent
+// it, the product and its version (empty when the banner has none).
+func ParseBanner(raw []byte) (banner, product, version string) {
+	if v, ok := mysqlHandshake(raw); ok {
+		banner = SanitizeBanner([]byte(v))
+		if i := strings.Index(v, "-MariaDB"); i >= 0 {
+			// MariaDB reports 5.5.5-<version> to old clients.
+			return banner, "MariaDB", strings.TrimPrefix(v[:i], "5.5.5-")
+		}
+		if i := strings.IndexAny(v, "-+"); i >= 0 {
+			v = v[:i]
+		}
+		return banner, "MySQL", v
+	}
+	banner = SanitizeBanner(raw)
+	for _, bv := range bannerVersions {
+		if m := bv.re.FindStringSubmatch(banner); m != nil {
+			return banner, bv.product, m[1]
+		}
+	}
+	return banner, "", ""
+}
+
+// grabBanners grabs the banner of every non-HTTP TCP port the port scan
+// found open, once per address, and records it with the product and
+// version it names on the port. It returns how many ports answered.
+func grabBanners(scans []types.HostPorts, timeout time.Duration, logFn func(string)) int {
+	type grab struct {
+		raw []byte
+		err error
+	}
+	web := webPorts()
+	grabs := make(map[string]*grab)
+	var addrs []string
+	for _, scan := range scans {
+		for _, p := range scan.Ports {
+			if scan.IP == "" || !bannerPort(p, web) {
+				continue
+			}
+			if addr := net.JoinHostPort(scan.IP, strconv.Itoa(p.Port)); grabs[addr] == nil {
+				grabs[addr] = &grab{}
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		return 0
+	}
+	logFn(fmt.Sprintf("[*] Grabbing the banners of %d non-HTTP service(s)...", len(addrs)))
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < bannerWorkers && i < len(addrs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := range jobs {
+				g := grabs[addr]
+				utils.Guard(func() {
+					_, port, _ := net.SplitHostPort(addr)
+					n, _ := strconv.Atoi(port)
+					g.raw, g.err = grabBanner(context.Background(), addr, bannerProbeFor(n), timeout)
+				})
+			}
+		}()
+	}
+	for _, addr := range addrs {
+		jobs <- addr
+	}
+	close(jobs)
+	wg.Wait()
+
+	answered := 0
+	for _, addr := range addrs {
+		if g := grabs[addr]; g.err == nil && len(g.raw) > 0 {
+			answered++
+		}
+	}
+	for i := range scans {
+		for j := range scans[i].Ports {
+			p := &scans[i].Ports[j]
+			g := grabs[net.JoinHostPort(scans[i].IP, strconv.Itoa(p.Port))]
+			if g == nil || g.err != nil || len(g.raw) == 0 || !bannerPort(*p, web) {
+				continue
+			}
+			p.Banner, p.Product, p.Version = ParseBanner(g.raw)
+		}
+	}
+	return answered
+}
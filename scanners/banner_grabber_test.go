@@ -0,0 +1,250 @@
+package scanners
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// fakeService accepts connections on a local port and hands each to
+// handle, closing it after; the returned address is closed with the test.
+func fakeService(t *testing.T, ln net.Listener, handle func(net.Conn)) string {
+	t.Helper()
+	if ln == nil {
+		var err error
+		if ln, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// writeLines writes lines to conn, CRLF-terminated.
+func writeLines(conn net.Conn, lines ...string) {
+	conn.Write([]byte(strings.Join(lines, "\r\n") + "\r\n"))
+}
+
+// mysqlGreeting is a MySQL initial handshake packet announcing version.
+func mysqlGreeting(version string) []byte {
+	payload := append([]byte{10}, version...)
+	payload = append(payload, 0, 8, 0, 0, 0, 'a', '\x01', 'b', 0x7f, 'c', 0)
+	return append([]byte{byte(len(payload)), 0, 0, 0}, payload...)
+}
+
+func TestBannerProbeFor(t *testing.T) {
+	tests := []struct {
+		port  int
+		probe bannerProbe
+	}{
+		{22, probeRead},
+		{21, probeRead},
+		{3306, probeRead},
+		{25, probeSMTP},
+		{587, probeSMTP},
+		{2525, probeSMTP},
+		{465, probeTLS},
+		{636, probeTLS},
+		{993, probeTLS},
+		{995, probeTLS},
+		{6379, probeRead},
+	}
+	for _, tt := range tests {
+		if got := bannerProbeFor(tt.port); got != tt.probe {
+			t.Errorf("bannerProbeFor(%d) = %s, want %s", tt.port, got, tt.probe)
+		}
+	}
+
+	web := []int{8080, 8443}
+	for _, tt := range []struct {
+		port types.PortService
+		grab bool
+	}{
+		{types.PortService{Port: 22, Protocol: "tcp", State: "open", Service: "ssh"}, true},
+		{types.PortService{Port: 5432, State: "open"}, true},
+		{types.PortService{Port: 80, Protocol: "tcp", State: "open"}, false},
+		{types.PortService{Port: 443, Protocol: "tcp", State: "open"}, false},
+		{types.PortService{Port: 8080, Protocol: "tcp", State: "open"}, false},
+		{types.PortService{Port: 9200, Protocol: "tcp", State: "open", Service: "http-alt"}, false},
+		{types.PortService{Port: 53, Protocol: "udp", State: "open", Service: "domain"}, false},
+		{types.PortService{Port: 23, Protocol: "tcp", State: "filtered"}, false},
+	} {
+		if got := bannerPort(tt.port, web); got != tt.grab {
+			t.Errorf("bannerPort(%+v) = %v", tt.port, got)
+		}
+	}
+}
+
+func TestSanitizeBanner(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"ssh", "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6\r\n", "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6"},
+		{"multi-line", "220-mail.example.com ESMTP\r\n220 ready\r\n", "220-mail.example.com ESMTP 220 ready"},
+		{"terminal escapes", "220 \x1b[31mred\x1b[0m\x07 bell", "220 [31mred [0m bell"},
+		{"NUL and DEL", "a\x00b\x7fc", "a b c"},
+		{"invalid UTF-8", "caf\xc3 \xff\xfe ok", "caf ok"},
+		{"unicode kept", "Grüß Gott ✓", "Grüß Gott ✓"},
+		{"C1 controls and line separators", "x\u0085y z", "x y z"},
+		{"only controls", "\r\n\t\x00", ""},
+	}
+	for _, tt := range tests {
+		if got := SanitizeBanner([]byte(tt.raw)); got != tt.want {
+			t.Errorf("%s: SanitizeBanner = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseBanner(t *testing.T) {
+	tests := []struct {
+		raw              string
+		product, version string
+	}{
+		{"SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6\r\n", "OpenSSH", "8.9p1"},
+		{"SSH-2.0-dropbear_2022.83\r\n", "Dropbear", "2022.83"},
+		{"SSH-2.0-Go\r\n", "", ""},
+		{"220 mx.example.com ESMTP Exim 4.96 Mon, 12 Feb 2024 10:00:00 +0000\r\n", "Exim", "4.96"},
+		{"220 mail.example.com ESMTP Postfix (Ubuntu)\r\n", "Postfix", ""},
+		{"220 (vsFTPd 3.0.5)\r\n", "vsftpd", "3.0.5"},
+		{"220 ProFTPD 1.3.8b Server (Debian) [::ffff:10.0.0.5]\r\n", "ProFTPD", "1.3.8b"},
+		{"220-FileZilla Server 1.8.1\r\n", "FileZilla Server", "1.8.1"},
+		{"* OK [CAPABILITY IMAP4rev1 SASL-IR LOGIN-REFERRALS] Dovecot (Ubuntu) ready.\r\n", "Dovecot", ""},
+		{string(mysqlGreeting("8.0.36-0ubuntu0.22.04.1")), "MySQL", "8.0.36"},
+		{string(mysqlGreeting("5.5.5-10.11.6-MariaDB-0+deb12u1")), "MariaDB", "10.11.6"},
+		{"\x05\x00\x00\x00\x0anot-a-version\x00", "", ""},
+		{"+OK POP3 ready\r\n", "", ""},
+	}
+	for _, tt := range tests {
+		banner, product, version := ParseBanner([]byte(tt.raw))
+		if product != tt.product || version != tt.version {
+			t.Errorf("ParseBanner(%q) = %q %q, want %q %q", tt.raw, product, version, tt.product, tt.version)
+		}
+		if strings.ContainsAny(banner, "\r\n\x00") {
+			t.Errorf("ParseBanner(%q) banner %q not sanitized", tt.raw, banner)
+		}
+	}
+	if banner, _, _ := ParseBanner(mysqlGreeting("8.0.36")); banner != "8.0.36" {
+		t.Errorf("MySQL banner %q, want the server version", banner)
+	}
+}
+
+func TestGrabBanner(t *testing.T) {
+	ssh := fakeService(t, nil, func(conn net.Conn) {
+		writeLines(conn, "SSH-2.0-OpenSSH_9.6")
+		// SSH waits for the client's identification.
+		time.Sleep(2 * time.Second)
+	})
+	smtp := fakeService(t, nil, func(conn net.Conn) {
+		writeLines(conn, "220 mx.example.com ESMTP Exim 4.97")
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		if line != "EHLO "+bannerEHLOName+"\r\n" {
+			writeLines(conn, "500 unexpected "+strings.TrimSpace(line))
+			return
+		}
+		writeLines(conn, "250-mx.example.com Hello", "250-SIZE 52428800", "250 STARTTLS")
+		time.Sleep(2 * time.Second)
+	})
+	tlsLn, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{testCertificate(t)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	imaps := fakeService(t, tlsLn, func(conn net.Conn) {
+		writeLines(conn, "* OK [CAPABILITY IMAP4rev1] Dovecot ready.")
+		time.Sleep(2 * time.Second)
+	})
+	silent := fakeService(t, nil, func(conn net.Conn) { time.Sleep(2 * time.Second) })
+	flood := fakeService(t, nil, func(conn net.Conn) {
+		conn.Write([]byte(strings.Repeat("A", 5000)))
+	})
+	closedLn, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed := closedLn.Addr().String()
+	closedLn.Close()
+
+	tests := []struct {
+		name  string
+		addr  string
+		probe bannerProbe
+		want  string
+		err   string
+	}{
+		{"banner first", ssh, probeRead, "SSH-2.0-OpenSSH_9.6", ""},
+		{"smtp", smtp, probeSMTP, "220 mx.example.com ESMTP Exim 4.97 250-mx.example.com Hello 250-SIZE 52428800 250 STARTTLS", ""},
+		{"implicit TLS", imaps, probeTLS, "* OK [CAPABILITY IMAP4rev1] Dovecot ready.", ""},
+		// Plaintext read of a TLS service: it waits for a ClientHello.
+		{"TLS service read in plaintext", imaps, probeRead, "", ""},
+		{"TLS probe of a plaintext service", ssh, probeTLS, "", "TLS handshake"},
+		{"silent", silent, probeRead, "", ""},
+		{"flood", flood, probeRead, strings.Repeat("A", bannerMaxBytes), ""},
+		{"closed", closed, probeRead, "", "refused"},
+	}
+	for _, tt := range tests {
+		start := time.Now()
+		raw, err := grabBanner(context.Background(), tt.addr, tt.probe, time.Second)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+		if got := SanitizeBanner(raw); got != tt.want {
+			t.Errorf("%s: banner %q, want %q", tt.name, got, tt.want)
+		}
+		// Services that keep the connection open end the read at the
+		// deadline at most.
+		if elapsed := time.Since(start); elapsed > 1500*time.Millisecond {
+			t.Errorf("%s: took %v", tt.name, elapsed)
+		}
+	}
+}
+
+func TestGrabBanners(t *testing.T) {
+	ssh := fakeService(t, nil, func(conn net.Conn) {
+		writeLines(conn, "SSH-2.0-OpenSSH_8.4p1 Debian-5+deb11u3")
+		time.Sleep(time.Second)
+	})
+	_, port, _ := net.SplitHostPort(ssh)
+	sshPort, _ := strconv.Atoi(port)
+	t.Setenv("WEB_PORTS", "")
+	scans := []types.HostPorts{
+		{Hostname: "a.example.com", IP: "127.0.0.1", Ports: []types.PortService{
+			{Port: 80, Protocol: "tcp", State: "open"},
+			{Port: sshPort, Protocol: "tcp", State: "open", Service: "ssh"},
+		}},
+		// Another name on the same address shares the grab.
+		{Hostname: "b.example.com", IP: "127.0.0.1", Ports: []types.PortService{{Port: sshPort, Protocol: "tcp", State: "open"}}},
+		{Hostname: "c.example.com", Ports: []types.PortService{{Port: 22, Protocol: "tcp", State: "open"}}},
+	}
+	var logs []string
+	if n := grabBanners(scans, time.Second, func(s string) { logs = append(logs, s) }); n != 1 {
+		t.Errorf("%d service(s) answered, want 1", n)
+	}
+	for _, p := range []types.PortService{scans[0].Ports[1], scans[1].Ports[0]} {
+		if p.Banner != "SSH-2.0-OpenSSH_8.4p1 Debian-5+deb11u3" || p.Product != "OpenSSH" || p.Version != "8.4p1" {
+			t.Errorf("port %+v", p)
+		}
+	}
+	if scans[0].Ports[0].Banner != "" || scans[2].Ports[0].Banner != "" {
+		t.Errorf("grabbed an HTTP port or a host without an address: %+v", scans)
+	}
+	if len(logs) != 1 || !strings.Contains(logs[0], "1 non-HTTP service(s)") {
+		t.Errorf("logs %q", logs)
+	}
+}
@@ -0,0 +1,121 @@
+// scanners/crawl_scanner.go - Active crawling with hakrawler, katana and gospider.
+package scanners
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// defaultCrawlDepth is the link depth crawlers stop at when CRAWL_DEPTH is
+// not set.
+const defaultCrawlDepth = 2
+
+// CrawlSettings reads CRAWLER ("hakrawler", the default, or "katana") and
+// CRAWL_DEPTH, which both crawlers honor.
+func CrawlSettings() (crawler string, depth int) {
+	crawler = "hakrawler"
+	if strings.EqualFold(os.Getenv("CRAWLER"), "katana") {
+		crawler = "katana"
+	}
+	depth = defaultCrawlDepth
+	if n, err := strconv.Atoi(os.Getenv("CRAWL_DEPTH")); err == nil && n > 0 {
+		depth = n
+	}
+	return crawler, depth
+}
+
+// Crawl crawls base with crawler down to depth and returns the URLs found.
+// katana also reports which of them take parameters; hakrawler's plain
+// output does not tell, so params is nil for it.
+func Crawl(crawler, base string, depth int) (urls, params []string, err error) {
+	d := strconv.Itoa(depth)
+	if crawler == "katana" {
+		out, runErr := utils.RunCommand("katana", "-u", base, "-d", d, "-jsonl", "-silent", "-fs", "rdn")
+		urls, params, err = parsers.ParseKatanaOutput(out)
+		if runErr != nil {
+			err = runErr
+		}
+		return urls, params, err
+	}
+	out, err := utils.RunCommand("hakrawler", "-url", base, "-depth", d, "-plain", "-scope", "subs")
+	if err != nil {
+		return nil, nil, err
+	}
+	return utils.ReadLines("hakrawler", out), nil, nil
+}
+
+// Gospider crawls base with gospider down to depth, following subdomains
+// and links in JavaScript, sitemaps and robots.txt.
+func Gospider(base string, depth int) (parsers.GospiderOutput, error) {
+	out, err := utils.RunCommand("gospider", "-s", base, "-d", strconv.Itoa(depth), "--subs", "--sitemap")
+	return parsers.ParseGospiderOutput(out), err
+}
+
+// AddDiscoveredHosts adds the hosts a crawler found that are not yet in
+// result.Subdomains, appending them to subdomains.txt. Those alive() accepts
+// are appended to live_hosts.txt and, when the HTTP probe ran, probed so
+// their URLs are kept by FilterAnswered. It returns the hosts added.
+func AddDiscoveredHosts(client *http.Client, outDir string, hosts []string, alive func(string) bool, result *types.ScanResult, logFn func(string)) []string {
+	known := make(map[string]bool)
+	for _, s := range result.Subdomains {
+		known[utils.NormalizeHostname(s.Hostname)] = true
+	}
+	var added, live []string
+	for _, h := range hosts {
+		if known[h] {
+			continue
+		}
+		known[h] = true
+		added = append(added, h)
+		result.Subdomains = append(result.Subdomains, types.SubdomainResult{Hostname: h, Ports: []int{}})
+		logFn("[*] Discovered subdomain while crawling: " + h)
+		if alive(h) {
+			live = append(live, h)
+			logFn("[*] Live: " + h)
+		}
+	}
+	_ = appendLines(added, filepath.Join(outDir, "subdomains.txt"))
+	_ = appendLines(live, filepath.Join(outDir, "live_hosts.txt"))
+	if len(live) == 0 || result.LiveHosts == nil {
+		return added
+	}
+	probe := noRedirectClient(client)
+	sigs := placeholderSignaturesFromEnv(logFn)
+	for _, h := range live {
+		lh := ProbeHTTP(probe, h, sigs)
+		result.LiveHosts = append(result.LiveHosts, lh)
+		if lh.URL == "" {
+			logFn(fmt.Sprintf("[*] No HTTP answer from %s: %s", h, lh.Error))
+			continue
+		}
+		logFn(fmt.Sprintf("[*] HTTP %s [%d] %q %s", lh.URL, lh.StatusCode, lh.Title, lh.Server))
+		if lh.Placeholder != "" {
+			logFn(placeholderNote(lh))
+		}
+	}
+	tagPlaceholderHosts(result)
+	writeProbeFiles(outDir, result)
+	return added
+}
+
+// appendLines appends lines to the file at path, creating it if needed.
+func appendLines(lines []string, path string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(strings.Join(lines, "\n") + "\n")
+	return err
+}
@@ -0,0 +1,102 @@
+// scanners/crlfuzz_scanner.go - CRLF injection checks of parameterized URLs with crlfuzz.
+package scanners
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const defaultCrlfuzzConcurrency = 10
+
+// crlfuzzConcurrency reads CRLFUZZ_CONCURRENCY, how many requests crlfuzz
+// has in flight. Behind a WAF it sends one at a time.
+func crlfuzzConcurrency(wafDetected bool) int {
+	if wafDetected {
+		return 1
+	}
+	if n, err := strconv.Atoi(os.Getenv("CRLFUZZ_CONCURRENCY")); err == nil && n > 0 {
+		return n
+	}
+	return defaultCrlfuzzConcurrency
+}
+
+// crlfuzzTargets returns the in-scope URLs that take parameters: those
+// Arjun discovered and the crawled URLs with a query, one per scheme, host,
+// path and set of parameter names.
+func crlfuzzTargets(result *types.ScanResult, inScope func(string) bool) []string {
+	seen := make(map[string]bool)
+	var targets []string
+	for _, raw := range append(ParameterizedURLs(result.Parameters), result.AllURLs...) {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" || u.RawQuery == "" || !inScope(raw) {
+			continue
+		}
+		var names []string
+		for name := range u.Query() {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		key := u.Scheme + "://" + u.Host + u.EscapedPath() + "?" + strings.Join(names, "&")
+		if !seen[key] {
+			seen[key] = true
+			targets = append(targets, raw)
+		}
+	}
+	return targets
+}
+
+// RunCrlfuzz tests the URLs that take parameters for CRLF injection with
+// crlfuzz and merges the findings into result, one per URL with every
+// payload that worked. The findings are written to crlfuzz.json.
+func RunCrlfuzz(outDir string, result *types.ScanResult, inScope func(string) bool, logFn func(string)) {
+	if _, err := exec.LookPath("crlfuzz"); err != nil {
+		logFn("[!] crlfuzz not found in PATH; skipping CRLF injection checks")
+		return
+	}
+	targets := crlfuzzTargets(result, inScope)
+	if len(targets) == 0 {
+		logFn("[*] No URLs with parameters to test for CRLF injection.")
+		return
+	}
+	input := filepath.Join(outDir, "crlfuzz_targets.txt")
+	if err := utils.WriteLines(targets, input); err != nil {
+		logFn("[!] crlfuzz error: " + err.Error())
+		return
+	}
+	concurrency := crlfuzzConcurrency(result.WAFDetected)
+	logFn(fmt.Sprintf("[*] Testing %d URL(s) for CRLF injection, concurrency %d...", len(targets), concurrency))
+	output := filepath.Join(outDir, "crlfuzz.txt")
+	out, runErr := utils.RunCommand("crlfuzz", "-l", input, "-c", strconv.Itoa(concurrency), "-s", "-o", output)
+	if data, err := ioutil.ReadFile(output); err == nil {
+		out = string(data)
+	}
+	findings, err := parsers.ParseCrlfuzzOutput(out, targets)
+	if runErr != nil {
+		logFn("[!] crlfuzz error: " + runErr.Error())
+	}
+	if err != nil {
+		logFn("[!] " + err.Error())
+	}
+	for _, f := range findings {
+		logFn("[!] CRLF injection: " + f.URL)
+	}
+	result.VulnURLs = append(result.VulnURLs, findings...)
+	if findings == nil {
+		findings = []types.VulnerabilityResult{}
+	}
+	data, _ := json.MarshalIndent(findings, "", "  ")
+	_ = ioutil.WriteFile(filepath.Join(outDir, "crlfuzz.json"), data, 0644)
+	logFn(fmt.Sprintf("[*] CRLF injection checks complete, %d finding(s).", len(findings)))
+}
@@ -0,0 +1,63 @@
+// scanners/dalfox_scanner.go - Runs dalfox and reads its findings
+package scanners
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+var (
+	dalfoxVersionRe = regexp.MustCompile(`v?(\d+)\.(\d+)\.\d+`)
+	dalfoxJSONOnce  sync.Once
+	dalfoxJSON      bool
+)
+
+// dalfoxHasJSON reports whether the installed dalfox writes the JSON report
+// RunDalfox reads; --report arrived in dalfox 2.9.0. The version is probed
+// once per run.
+func dalfoxHasJSON() bool {
+	dalfoxJSONOnce.Do(func() {
+		out, _ := utils.RunCommand("dalfox", "version")
+		m := dalfoxVersionRe.FindStringSubmatch(out)
+		if m == nil {
+			return
+		}
+		major, _ := strconv.Atoi(m[1])
+		minor, _ := strconv.Atoi(m[2])
+		dalfoxJSON = major > 2 || major == 2 && minor >= 9
+	})
+	return dalfoxJSON
+}
+
+// RunDalfox runs dalfox with args, such as "url" and a URL or "file" and a
+// list, and parses its findings. Releases with JSON output write their
+// report to outFile; older ones fall back to the [POC] lines of the plain
+// output.
+func RunDalfox(outFile string, args ...string) ([]types.VulnerabilityResult, error) {
+	if !dalfoxHasJSON() {
+		out, err := utils.RunCommand("dalfox", args...)
+		if err != nil {
+			return nil, err
+		}
+		return parsers.ParseDalfoxOutput(out), nil
+	}
+	args = append(args, "--format", "json", "--report", "-o", outFile, "--no-color", "--silence")
+	out, runErr := utils.RunCommand("dalfox", args...)
+	data, err := ioutil.ReadFile(outFile)
+	if err != nil || len(strings.TrimSpace(string(data))) == 0 {
+		// Without -o support for reports dalfox prints the JSON instead.
+		data = []byte(out)
+	}
+	vulns, err := parsers.ParseDalfoxJSON(data)
+	if err != nil && runErr != nil {
+		err = runErr
+	}
+	return vulns, err
+}
@@ -0,0 +1,150 @@
+// scanners/disclosure_scanner.go - security.txt, humans.txt and DNS TXT metadata.
+package scanners
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	// humansTxtMaxLines is how much of a humans.txt file is kept.
+	humansTxtMaxLines = 20
+	txtLookupTimeout  = 10 * time.Second
+)
+
+// securityTxtPaths are where RFC 9116 places security.txt, the legacy root
+// location last.
+var securityTxtPaths = []string{"/.well-known/security.txt", "/security.txt"}
+
+// mainOrigins returns the base URLs of the target and its www host that
+// answered HTTP.
+func mainOrigins(result *types.ScanResult, target string) []string {
+	var origins []string
+	for _, h := range []string{target, "www." + target} {
+		if u, ok := WebTarget(result, h); ok {
+			origins = append(origins, u)
+		}
+	}
+	return utils.UniqueStrings(origins)
+}
+
+// FetchSecurityTxt returns the first security.txt base serves, trying the
+// RFC 9116 location before the legacy one.
+func FetchSecurityTxt(client *http.Client, base string, now time.Time) (types.SecurityTxt, bool) {
+	for _, p := range securityTxtPaths {
+		body, ok := fetchLimited(client, base+p)
+		if !ok {
+			continue
+		}
+		if txt, err := parsers.ParseSecurityTxt(string(body), now); err == nil {
+			txt.URL = base + p
+			return txt, true
+		}
+	}
+	return types.SecurityTxt{}, false
+}
+
+// FetchHumansTxt returns the first lines of base's humans.txt, skipping
+// HTML served in its place.
+func FetchHumansTxt(client *http.Client, base string) (types.HumansTxt, bool) {
+	body, ok := fetchLimited(client, base+"/humans.txt")
+	if !ok {
+		return types.HumansTxt{}, false
+	}
+	text := strings.TrimSpace(string(body))
+	if text == "" || strings.HasPrefix(text, "<") {
+		return types.HumansTxt{}, false
+	}
+	h := types.HumansTxt{URL: base + "/humans.txt"}
+	for _, line := range utils.ReadLines("humans.txt", text) {
+		if line = strings.TrimSpace(line); line != "" && len(h.Lines) < humansTxtMaxLines {
+			h.Lines = append(h.Lines, line)
+		}
+	}
+	return h, true
+}
+
+// securityTxtHosts returns the hostnames security.txt points to: those of
+// its URLs and email contacts.
+func securityTxtHosts(txt types.SecurityTxt) []string {
+	var values []string
+	for _, vs := range [][]string{txt.Contacts, txt.Policy, txt.Encryption, txt.Acknowledgments, txt.Hiring, txt.Canonical} {
+		values = append(values, vs...)
+	}
+	var hosts []string
+	for _, v := range values {
+		if u, err := url.Parse(v); err == nil && u.Hostname() != "" {
+			hosts = append(hosts, utils.NormalizeHostname(u.Hostname()))
+			continue
+		}
+		if a, err := mail.ParseAddress(strings.TrimPrefix(v, "mailto:")); err == nil {
+			hosts = append(hosts, utils.NormalizeHostname(a.Address[strings.LastIndex(a.Address, "@")+1:]))
+		}
+	}
+	return utils.UniqueStrings(hosts)
+}
+
+// RunDisclosureChecks harvests the metadata the target publishes about
+// itself: security.txt and humans.txt from the main origins and the third
+// parties named in its DNS TXT records. An expired security.txt is an info
+// finding. The results are written to disclosure.json, and the hostnames
+// security.txt refers to are returned so in-scope ones can join the scan.
+func RunDisclosureChecks(client *http.Client, target, outDir string, result *types.ScanResult, lookupTXT func(ctx context.Context, name string) ([]string, error), logFn func(string)) []string {
+	logFn("[*] Collecting security.txt, humans.txt and DNS TXT metadata...")
+	var hosts []string
+	now := time.Now()
+	for _, base := range mainOrigins(result, target) {
+		if txt, ok := FetchSecurityTxt(client, base, now); ok {
+			result.SecurityTxt = append(result.SecurityTxt, txt)
+			hosts = append(hosts, securityTxtHosts(txt)...)
+			logFn(fmt.Sprintf("[*] security.txt at %s: contact %s", txt.URL, strings.Join(txt.Contacts, ", ")))
+			if txt.Expired {
+				result.VulnURLs = append(result.VulnURLs, types.VulnerabilityResult{
+					URL:      txt.URL,
+					Issue:    "Expired security.txt",
+					Type:     "expired-security-txt",
+					Detail:   "security.txt expired on " + txt.Expires,
+					Severity: "info",
+				})
+			}
+		}
+		if h, ok := FetchHumansTxt(client, base); ok {
+			result.HumansTxt = append(result.HumansTxt, h)
+			logFn("[*] humans.txt at " + h.URL)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), txtLookupTimeout)
+	records, err := lookupTXT(ctx, target)
+	cancel()
+	if err != nil {
+		logFn("[!] TXT lookup for " + target + " failed: " + err.Error())
+	}
+	for _, rec := range records {
+		for _, service := range utils.ClassifyTXT(rec) {
+			result.SaaS = append(result.SaaS, types.SaaSService{Service: service, Domain: target, Record: rec})
+		}
+	}
+	if len(result.SaaS) > 0 {
+		logFn(fmt.Sprintf("[*] DNS TXT records name %d third-party service(s)", len(result.SaaS)))
+	}
+	out, _ := json.MarshalIndent(map[string]interface{}{
+		"security_txt": result.SecurityTxt,
+		"humans_txt":   result.HumansTxt,
+		"saas":         result.SaaS,
+	}, "", "  ")
+	_ = ioutil.WriteFile(filepath.Join(outDir, "disclosure.json"), out, 0644)
+	logFn("[*] Disclosure metadata collection complete.")
+	return utils.UniqueStrings(hosts)
+}
@@ -0,0 +1,180 @@
+// scanners/dns_resolver.go - Bulk resolution of subdomains with dnsx or a native worker pool.
+package scanners
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	dnsLookupTimeout  = 10 * time.Second
+	defaultDNSWorkers = 50
+	defaultDNSTimeout = 5 * time.Second
+)
+
+// DNSSettings reads DNS_RESOLVER, the server names are resolved with
+// instead of the system's (host or host:port, port 53 by default),
+// DNS_WORKERS, how many lookups run at once, and DNS_TIMEOUT, the bound on
+// each lookup (a Go duration such as "5s").
+func DNSSettings() (server string, workers int, timeout time.Duration) {
+	if server = strings.TrimSpace(os.Getenv("DNS_RESOLVER")); server != "" {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+		}
+	}
+	workers, timeout = defaultDNSWorkers, defaultDNSTimeout
+	if n, err := strconv.Atoi(os.Getenv("DNS_WORKERS")); err == nil && n > 0 {
+		workers = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("DNS_TIMEOUT")); err == nil && d > 0 {
+		timeout = d
+	}
+	return server, workers, timeout
+}
+
+// DNSResolver returns the resolver DNS_RESOLVER names, or the system's.
+func DNSResolver() *net.Resolver {
+	server, _, _ := DNSSettings()
+	if server == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, server)
+	}}
+}
+
+// RunDnsx resolves hosts in bulk with dnsx, asking for A, AAAA and CNAME
+// records. It fails when dnsx is missing or cannot run, so callers can fall
+// back to ResolveNative; malformed output lines are only logged.
+func RunDnsx(hosts []string, logFn func(string)) ([]types.DNSRecord, error) {
+	if _, err := exec.LookPath("dnsx"); err != nil {
+		return nil, err
+	}
+	server, workers, _ := DNSSettings()
+	args := []string{"-json", "-a", "-aaaa", "-cname", "-resp", "-silent", "-t", strconv.Itoa(workers)}
+	if server != "" {
+		args = append(args, "-r", server)
+	}
+	out, err := utils.RunCommandInput("dnsx", strings.Join(hosts, "\n")+"\n", args...)
+	if err != nil {
+		return nil, err
+	}
+	records, err := parsers.ParseDnsxOutput(out)
+	if err != nil {
+		logFn("[!] " + err.Error())
+	}
+	return records, nil
+}
+
+// ResolveNative resolves host with r into the record shape dnsx produces,
+// giving up after timeout. Go only reports the end of a CNAME chain, so
+// CNAME holds at most one name. ok is false when host has neither
+// addresses nor a CNAME.
+func ResolveNative(r *net.Resolver, host string, timeout time.Duration) (rec types.DNSRecord, ok bool) {
+	host = utils.NormalizeHostname(host)
+	rec.Host = host
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if addrs, err := r.LookupIPAddr(ctx, host); err == nil {
+		for _, a := range addrs {
+			if a.IP.To4() != nil {
+				rec.A = append(rec.A, a.IP.String())
+			} else {
+				rec.AAAA = append(rec.AAAA, a.IP.String())
+			}
+		}
+	}
+	if cname, err := r.LookupCNAME(ctx, host); err == nil {
+		if cname = utils.NormalizeHostname(cname); cname != "" && cname != host {
+			rec.CNAME = []string{cname}
+		}
+	}
+	return rec, len(rec.A)+len(rec.AAAA)+len(rec.CNAME) > 0
+}
+
+// ResolveAll resolves hosts with r, workers at a time, each lookup bounded
+// by timeout. It returns the records of the hosts that resolved, in the
+// order of hosts.
+func ResolveAll(r *net.Resolver, hosts []string, workers int, timeout time.Duration) []types.DNSRecord {
+	results := make([]types.DNSRecord, len(hosts))
+	resolved := make([]bool, len(hosts))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				utils.Guard(func() {
+					results[i], resolved[i] = ResolveNative(r, hosts[i], timeout)
+				})
+			}
+		}()
+	}
+	for i := range hosts {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	var records []types.DNSRecord
+	for i, rec := range results {
+		if resolved[i] {
+			records = append(records, rec)
+		}
+	}
+	return records
+}
+
+// ResolveHosts resolves hosts in bulk with dnsx when it is installed and
+// otherwise with native lookups through r, as DNSSettings configures. Every
+// host gets a record, by normalized name, empty when it did not resolve.
+func ResolveHosts(r *net.Resolver, hosts []string, logFn func(string)) map[string]types.DNSRecord {
+	_, workers, timeout := DNSSettings()
+	records, err := RunDnsx(hosts, logFn)
+	if err != nil {
+		logFn("[*] dnsx unavailable (" + err.Error() + "); resolving natively")
+		records = ResolveAll(r, hosts, workers, timeout)
+	}
+	byHost := make(map[string]types.DNSRecord, len(hosts))
+	for _, h := range hosts {
+		if h = utils.NormalizeHostname(h); h != "" {
+			byHost[h] = types.DNSRecord{Host: h}
+		}
+	}
+	for _, rec := range records {
+		byHost[rec.Host] = rec
+	}
+	resolved := 0
+	for _, rec := range byHost {
+		if len(rec.A)+len(rec.AAAA) > 0 {
+			resolved++
+		}
+	}
+	logFn(fmt.Sprintf("[*] %d of %d name(s) resolved", resolved, len(byHost)))
+	return byHost
+}
+
+// ApplyDNSRecord sets s's addresses and CNAME chain from rec. IP is the
+// first A record, or the first AAAA record without any, and stays empty
+// when the name did not resolve.
+func ApplyDNSRecord(s *types.SubdomainResult, rec types.DNSRecord) {
+	s.IPs, s.IPv6, s.CNAME = rec.A, rec.AAAA, rec.CNAME
+	s.IP = ""
+	if len(rec.A) > 0 {
+		s.IP = rec.A[0]
+	} else if len(rec.AAAA) > 0 {
+		s.IP = rec.AAAA[0]
+	}
+}
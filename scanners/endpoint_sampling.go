@@ -0,0 +1,124 @@
+// scanners/endpoint_sampling.go - Bounded, paced sampling of discovered endpoints.
+package scanners
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	// endpointSamplePerHost bounds how many endpoints active checks probe per host.
+	endpointSamplePerHost = 5
+	// endpointProbePause spaces out requests to the same host.
+	endpointProbePause = 200 * time.Millisecond
+	// endpointProbeWorkers is how many hosts are probed at once.
+	endpointProbeWorkers = 10
+	endpointTimeout      = 10 * time.Second
+)
+
+// Skip reasons recorded in the coverage of sampled stages.
+const (
+	skipOutOfScope = "out of scope"
+	skipPerHostCap = "over per-host cap"
+)
+
+// sampleEndpoints picks up to endpointSamplePerHost in-scope endpoints per
+// host from the ffuf hits and crawled URLs, distinct by scheme, host and
+// path. keep, when set, further restricts which URLs qualify. Pages the
+// URL verification found public or authenticated come first and pages
+// behind a login wall are skipped. The first URL seen for a path is kept
+// along with its query. The returned coverage counts every qualifying
+// endpoint as attempted or skipped with a reason.
+func sampleEndpoints(stage string, result *types.ScanResult, inScope func(string) bool, keep func(*url.URL) bool) (map[string][]*url.URL, *types.StageCoverage) {
+	sample := make(map[string][]*url.URL)
+	cov := &types.StageCoverage{Stage: stage, Skipped: make(map[string]int)}
+	states := authStates(result)
+	seen := make(map[string]bool)
+	add := func(raw string) {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" || (keep != nil && !keep(u)) {
+			return
+		}
+		endpoint := u.Scheme + "://" + u.Host + u.EscapedPath()
+		if seen[endpoint] {
+			return
+		}
+		seen[endpoint] = true
+		cov.Known++
+		switch {
+		case !inScope(raw):
+			cov.Skipped[skipOutOfScope]++
+		case authStateIn(states, raw) == AuthLoginWall:
+			cov.Skipped[skipLoginWall]++
+		case len(sample[u.Host]) >= endpointSamplePerHost:
+			cov.Skipped[skipPerHostCap]++
+		default:
+			sample[u.Host] = append(sample[u.Host], u)
+			cov.Attempted++
+		}
+	}
+	var candidates []string
+	for _, f := range result.FfufEntries {
+		candidates = append(candidates, f.URL)
+	}
+	reachable, unknown, walled := splitByAuthState(states, append(candidates, result.AllURLs...))
+	for _, raw := range append(append(reachable, unknown...), walled...) {
+		add(raw)
+	}
+	return sample, cov
+}
+
+// forEachEndpoint calls fn for every sampled endpoint and counts those fn
+// reports answered as completed in cov. Up to endpointProbeWorkers hosts
+// are probed at once, one request at a time each and endpointProbePause
+// apart.
+func forEachEndpoint(sample map[string][]*url.URL, cov *types.StageCoverage, fn func(*url.URL) bool) {
+	hosts := make(chan []*url.URL)
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for w := 0; w < endpointProbeWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for endpoints := range hosts {
+				for i, u := range endpoints {
+					if i > 0 {
+						time.Sleep(endpointProbePause)
+					}
+					utils.Guard(func() {
+						if fn(u) {
+							mu.Lock()
+							cov.Completed++
+							mu.Unlock()
+						}
+					})
+				}
+			}
+		}()
+	}
+	for _, endpoints := range sample {
+		hosts <- endpoints
+	}
+	close(hosts)
+	wg.Wait()
+}
+
+// noRedirectClient copies client so that it reports redirects instead of
+// following them, with a timeout if client has none.
+func noRedirectClient(client *http.Client) *http.Client {
+	probe := *client
+	probe.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	if probe.Timeout == 0 {
+		probe.Timeout = endpointTimeout
+	}
+	return &probe
+}
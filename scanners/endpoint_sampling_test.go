@@ -0,0 +1,77 @@
+package scanners
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// TestForEachEndpointBounded checks that many hosts share the fixed worker
+// pool, that every endpoint is visited once, and that one host's
+// endpoints are never probed at the same time.
+func TestForEachEndpointBounded(t *testing.T) {
+	tests := []struct{ hosts, perHost int }{
+		{0, 0},
+		{1, 3},
+		{endpointProbeWorkers, 1},
+		{5 * endpointProbeWorkers, 2},
+	}
+	for _, tt := range tests {
+		sample := make(map[string][]*url.URL)
+		for h := 0; h < tt.hosts; h++ {
+			host := fmt.Sprintf("h%d.example.com", h)
+			for p := 0; p < tt.perHost; p++ {
+				sample[host] = append(sample[host], &url.URL{Scheme: "https", Host: host, Path: fmt.Sprintf("/p%d", p)})
+			}
+		}
+		var (
+			running, peak int32
+			mu            sync.Mutex
+			visited       = make(map[string]int)
+			busy          = make(map[string]bool)
+		)
+		cov := &types.StageCoverage{}
+		forEachEndpoint(sample, cov, func(u *url.URL) bool {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			mu.Lock()
+			if n > peak {
+				peak = n
+			}
+			if busy[u.Host] {
+				t.Errorf("%d hosts: %s probed concurrently", tt.hosts, u.Host)
+			}
+			busy[u.Host] = true
+			visited[u.String()]++
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			busy[u.Host] = false
+			mu.Unlock()
+			return u.Path != "/p1"
+		})
+		if peak > endpointProbeWorkers {
+			t.Errorf("%d hosts: %d probes at once, want at most %d", tt.hosts, peak, endpointProbeWorkers)
+		}
+		if len(visited) != tt.hosts*tt.perHost {
+			t.Errorf("%d hosts: visited %d endpoint(s), want %d", tt.hosts, len(visited), tt.hosts*tt.perHost)
+		}
+		for u, n := range visited {
+			if n != 1 {
+				t.Errorf("%d hosts: %s visited %d times", tt.hosts, u, n)
+			}
+		}
+		want := tt.hosts * tt.perHost
+		if tt.perHost > 1 {
+			want -= tt.hosts
+		}
+		if cov.Completed != want {
+			t.Errorf("%d hosts: %d completed, want %d", tt.hosts, cov.Completed, want)
+		}
+	}
+}
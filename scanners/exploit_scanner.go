@@ -3,10 +3,10 @@ package scanners
 
 import (
 	"fmt"
-	"recon-tool/main"
+	"github.com/MKlolbullen/Goforgold2/types"
 )
 
 // RunExploitTool is a stub. Exploit execution is disabled in the UI.
-func RunExploitTool(tool string, target string, result *main.ScanResult, logFn func(string)) {
+func RunExploitTool(tool string, target string, result *types.ScanResult, logFn func(string)) {
 	logFn(fmt.Sprintf("[*] Exploit tool %s execution skipped (disabled from UI).", tool))
 }
@@ -0,0 +1,234 @@
+// scanners/exposure_scanner.go - Exposed version control and CI metadata checks.
+package scanners
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	exposureMaxBody = 64 * 1024
+	exposureWorkers = 10
+	exposureTimeout = 10 * time.Second
+)
+
+// exposureCheck is a sensitive path plus the content signature that
+// confirms it, so catch-all 200 pages are not reported.
+type exposureCheck struct {
+	Path      string
+	Issue     string
+	Type      string
+	Signature *regexp.Regexp
+}
+
+var exposureChecks = []exposureCheck{
+	{"/.git/HEAD", "Exposed Git Repository", "exposed-git", regexp.MustCompile(`^(ref: refs/\S+|[0-9a-f]{40})\s*$`)},
+	{"/.svn/entries", "Exposed SVN Metadata", "exposed-svn", regexp.MustCompile(`^(\d+\s*\n|<\?xml[^>]*>\s*<wc-entries)`)},
+	{"/.svn/wc.db", "Exposed SVN Metadata", "exposed-svn", regexp.MustCompile(`^SQLite format 3\x00`)},
+	{"/.hg/requires", "Exposed Mercurial Repository", "exposed-hg", regexp.MustCompile(`(?m)^(revlogv1|store|fncache|dotencode)\s*$`)},
+	{"/Jenkinsfile", "Exposed Jenkinsfile", "exposed-jenkinsfile", regexp.MustCompile(`(?m)^\s*(pipeline|node)\s*(\(|\{)`)},
+	{"/.gitlab-ci.yml", "Exposed GitLab CI Config", "exposed-gitlab-ci", regexp.MustCompile(`(?m)^\s*(stages|script|before_script|image):`)},
+	{"/.circleci/config.yml", "Exposed CircleCI Config", "exposed-circleci", regexp.MustCompile(`(?m)^(version:\s*[0-9.]+|jobs:|workflows:)`)},
+}
+
+// ExposureRequestsPerHost is the most requests the exposure checks send to
+// one host: every check over HTTPS and HTTP, and the .git/config follow-up.
+func ExposureRequestsPerHost() int {
+	return 2*len(exposureChecks) + 1
+}
+
+// gitRemoteRe extracts remote URLs from a .git/config file.
+var gitRemoteRe = regexp.MustCompile(`(?m)^\s*url\s*=\s*(\S+)`)
+
+// fetchLimited GETs url without following redirects and returns at most
+// exposureMaxBody bytes of a 200 response body.
+func fetchLimited(client *http.Client, url string) ([]byte, bool) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, false
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, exposureMaxBody))
+	return body, err == nil
+}
+
+// redactRemote hides the credentials in a git remote URL, often an access
+// token as in https://token@github.com/org/repo.git or
+// ?private_token=..., by dropping the userinfo and the query. scp-style
+// remotes (git@host:path) carry none and are kept as they are.
+func redactRemote(remote string) string {
+	u, err := url.Parse(remote)
+	if err != nil || u.Scheme == "" {
+		return string(utils.Redact([]byte(remote), nil))
+	}
+	if u.User != nil {
+		u.User = url.User("redacted")
+	}
+	u.RawQuery, u.ForceQuery = "", false
+	return u.String()
+}
+
+// remoteCredential returns the credential a git remote URL carries: the
+// password of its userinfo, a token-length user name as in
+// https://token@github.com/org/repo.git, or a token query parameter.
+func remoteCredential(remote string) (string, bool) {
+	u, err := url.Parse(remote)
+	if err != nil || u.Scheme == "" {
+		return "", false
+	}
+	if u.User != nil {
+		if p, ok := u.User.Password(); ok && p != "" {
+			return p, true
+		}
+		if name := u.User.Username(); len(name) >= 20 {
+			return name, true
+		}
+	}
+	for _, k := range []string{"private_token", "access_token", "token"} {
+		if v := u.Query().Get(k); v != "" {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// CheckExposures probes one base URL (scheme://host) for every exposure
+// check. A confirmed .git/HEAD triggers a single follow-up fetch of
+// .git/config to record remote URLs, without their credentials, which are
+// returned masked as secrets; nothing else of the repository is read. Hits
+// that look like the origin's soft-404 page are not reported but counted
+// in notFound.
+func CheckExposures(client *http.Client, base string, soft404 *types.Soft404) (findings []types.VulnerabilityResult, secrets []types.SecretFinding, notFound int) {
+	for _, c := range exposureChecks {
+		body, ok := fetchLimited(client, base+c.Path)
+		if !ok || !c.Signature.Match(body) {
+			continue
+		}
+		if MatchesSoft404(soft404, http.StatusOK, body, c.Path) {
+			notFound++
+			continue
+		}
+		detail := fmt.Sprintf("%s matched %s signature", c.Path, c.Issue)
+		if c.Path == "/.git/HEAD" {
+			if cfg, ok := fetchLimited(client, base+"/.git/config"); ok {
+				var remotes []string
+				for _, m := range gitRemoteRe.FindAllSubmatch(cfg, -1) {
+					remote := redactRemote(string(m[1]))
+					remotes = append(remotes, remote)
+					if cred, ok := remoteCredential(string(m[1])); ok {
+						host := remote
+						if u, err := url.Parse(remote); err == nil {
+							host = u.Host
+						}
+						loc := types.SecretLocation{Artifact: base + "/.git/config", Location: "remote " + remote, Detector: "exposure git-remote"}
+						secrets = utils.MergeSecrets(secrets, utils.NewSecretFinding(utils.SecretClass(host), cred, loc, utils.ConfidenceFirm, utils.SecretUnverified))
+					}
+				}
+				if len(remotes) > 0 {
+					detail += "; remotes: " + strings.Join(remotes, ", ")
+				}
+			}
+		}
+		findings = append(findings, types.VulnerabilityResult{
+			URL:        base + c.Path,
+			Issue:      c.Issue,
+			Type:       c.Type,
+			Detail:     detail,
+			Confidence: utils.ConfidenceConfirmed,
+		})
+	}
+	return findings, secrets, notFound
+}
+
+// RunExposureChecks checks every subdomain but internal ones over HTTPS,
+// falling back to HTTP, and every web service found on an alternative port
+// for exposed version control and CI metadata.
+func RunExposureChecks(client *http.Client, result *types.ScanResult, logFn func(string)) {
+	logFn("[*] Checking for exposed version control and CI metadata...")
+	noRedirect := *client
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	if noRedirect.Timeout == 0 {
+		noRedirect.Timeout = exposureTimeout
+	}
+	// Each job lists the base URLs to try for one origin, in order.
+	jobs := make(chan []string)
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		findings []types.VulnerabilityResult
+		secrets  []types.SecretFinding
+		notFound = make(map[string]int)
+	)
+	for i := 0; i < exposureWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for bases := range jobs {
+				utils.Guard(func() {
+					var found []types.VulnerabilityResult
+					var leaked []types.SecretFinding
+					for _, base := range bases {
+						var soft int
+						found, leaked, soft = CheckExposures(&noRedirect, base, Soft404For(result, base))
+						mu.Lock()
+						if soft > 0 {
+							notFound[base] += soft
+						}
+						mu.Unlock()
+						if len(found) > 0 {
+							break
+						}
+					}
+					mu.Lock()
+					findings = append(findings, found...)
+					secrets = append(secrets, leaked...)
+					mu.Unlock()
+				})
+			}
+		}()
+	}
+	for _, s := range result.Subdomains {
+		if !utils.ProbeHost(s) {
+			continue
+		}
+		jobs <- []string{utils.OriginURL("https", s.Hostname, 0), utils.OriginURL("http", s.Hostname, 0)}
+	}
+	for _, base := range AltOrigins(result) {
+		jobs <- []string{base}
+	}
+	close(jobs)
+	wg.Wait()
+	bases := make([]string, 0, len(notFound))
+	for base := range notFound {
+		bases = append(bases, base)
+	}
+	sort.Strings(bases)
+	for _, base := range bases {
+		logFn(soft404Note(base, "exposure check", notFound[base]))
+	}
+	for _, f := range findings {
+		logFn(fmt.Sprintf("[!] %s: %s", f.Issue, f.URL))
+	}
+	if len(secrets) > 0 {
+		logFn(fmt.Sprintf("[!] %d credential(s) in exposed Git remote URLs", len(secrets)))
+	}
+	result.VulnURLs = append(result.VulnURLs, findings...)
+	result.Secrets = utils.MergeSecrets(result.Secrets, secrets...)
+	logFn(fmt.Sprintf("[*] Exposure checks complete, %d finding(s).", len(findings)))
+}
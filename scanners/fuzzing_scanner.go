@@ -1,43 +1,307 @@
-// /scanners/fuzzing_scanner.go - Fuzzing with ffuf using a wordlist.
+// /scanners/fuzzing_scanner.go - Fuzzing with ffuf, gobuster or dirsearch using a wordlist.
 package scanners
 
 import (
-	"bufio"
-	"encoding/json"
+	"bytes"
+	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
-	"recon-tool/main"
-	"recon-tool/utils"
+	"sort"
+	"strconv"
 	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
 )
 
-// RunFuzzing runs ffuf with a default wordlist to find hidden endpoints.
-func RunFuzzing(target, outDir string, result *main.ScanResult, logFn func(string)) {
-	logFn("[*] Running ffuf fuzzing...")
-	ffufOut := filepath.Join(outDir, "ffuf_results.json")
-	// Execute ffuf with default parameters.
-	_, err := utils.RunCommand("ffuf",
-		"-w", "/usr/share/seclists/Discovery/Web-Content/api/api-endpoints-res.txt:FUZZ",
-		"-u", "http://"+target+"/FUZZ",
-		"-of", "json", "-o", ffufOut)
+// defaultWordlist is the fuzzing wordlist used when WORDLIST is not set.
+const defaultWordlist = "/usr/share/seclists/Discovery/Web-Content/api/api-endpoints-res.txt"
+
+// fuzzBackends are the content discovery tools FUZZER may name.
+var fuzzBackends = []string{"ffuf", "gobuster", "dirsearch"}
+
+// fuzzSettings reads FUZZER, a comma-separated list of backends run one
+// after another ("ffuf", the default, "gobuster" and "dirsearch"), and
+// WORDLIST and FUZZ_EXTENSIONS (e.g. "php,bak"), which every backend uses.
+func fuzzSettings() (fuzzers []string, wordlist string, extensions []string) {
+	for _, f := range strings.Split(strings.ToLower(os.Getenv("FUZZER")), ",") {
+		if f = strings.TrimSpace(f); containsString(fuzzBackends, f) && !containsString(fuzzers, f) {
+			fuzzers = append(fuzzers, f)
+		}
+	}
+	if len(fuzzers) == 0 {
+		fuzzers = []string{"ffuf"}
+	}
+	wordlist = defaultWordlist
+	if w := os.Getenv("WORDLIST"); w != "" {
+		wordlist = w
+	}
+	for _, e := range strings.Split(os.Getenv("FUZZ_EXTENSIONS"), ",") {
+		if e = strings.TrimPrefix(strings.TrimSpace(e), "."); e != "" {
+			extensions = append(extensions, e)
+		}
+	}
+	return fuzzers, wordlist, extensions
+}
+
+// FuzzRequestEstimate returns how many requests the fuzzing stage sends to
+// the target: every word with each extension variant, once per backend.
+func FuzzRequestEstimate() (int, error) {
+	fuzzers, wordlist, extensions := fuzzSettings()
+	data, err := ioutil.ReadFile(wordlist)
 	if err != nil {
-		logFn("[!] ffuf error: " + err.Error())
+		return 0, err
+	}
+	words := bytes.Count(data, []byte("\n"))
+	return words * (1 + len(extensions)) * len(fuzzers), nil
+}
+
+// extensionArgs returns the arguments that make fuzzer also try each word
+// with extensions appended.
+func extensionArgs(fuzzer string, extensions []string) []string {
+	if len(extensions) == 0 {
+		return nil
+	}
+	switch fuzzer {
+	case "ffuf":
+		return []string{"-e", "." + strings.Join(extensions, ",.")}
+	case "gobuster":
+		return []string{"-x", strings.Join(extensions, ",")}
+	case "dirsearch":
+		return []string{"-e", strings.Join(extensions, ",")}
+	}
+	return nil
+}
+
+// mergeFfufEntries adds the entries of src that dst lacks, comparing path
+// and status, and returns dst and how many were added.
+func mergeFfufEntries(dst, src []types.FfufResult) ([]types.FfufResult, int) {
+	type key struct {
+		path   string
+		status int
+	}
+	seen := make(map[key]bool, len(dst))
+	for _, e := range dst {
+		seen[key{e.Path, e.Status}] = true
+	}
+	added := 0
+	for _, e := range src {
+		if k := (key{e.Path, e.Status}); !seen[k] {
+			seen[k] = true
+			dst = append(dst, e)
+			added++
+		}
+	}
+	return dst, added
+}
+
+// RunFuzzing runs each configured fuzzer with the configured wordlist to
+// find hidden endpoints, at a gentler rate when wafDetected is set, and
+// merges their entries into ffuf_results.json, leaving out those matching
+// the target's soft-404 page and those redirecting to a login page.
+// Without a WAF, a target slow to answer is fuzzed with fewer concurrent
+// requests. It is skipped when the target did not answer the HTTP probe.
+func RunFuzzing(target, outDir string, result *types.ScanResult, wafDetected bool, logFn func(string)) {
+	fuzzers, wordlist, extensions := fuzzSettings()
+	names := strings.Join(fuzzers, ", ")
+	web, ok := WebTarget(result, target)
+	if !ok {
+		logFn("[*] Skipping " + names + ": " + target + " did not answer HTTP")
+		return
+	}
+	timing := HostTimingFor(result, web)
+	if wafDetected {
+		logFn("[*] Running " + names + " fuzzing at a reduced rate: a WAF was detected...")
+	} else {
+		if slowServer(timing) {
+			logFn(slowServerNote(web, timing))
+		}
+		logFn("[*] Running " + names + " fuzzing...")
+	}
+	var merged []types.FfufResult
+	for _, fuzzer := range fuzzers {
+		var (
+			entries []types.FfufResult
+			err     error
+		)
+		extra := append(extensionArgs(fuzzer, extensions), WAFArgs(fuzzer, wafDetected)...)
+		if !wafDetected {
+			extra = append(extra, SlowServerArgs(fuzzer, timing)...)
+		}
+		switch fuzzer {
+		case "gobuster":
+			entries, err = runGobuster(outDir, web, wordlist, extra)
+		case "dirsearch":
+			entries, err = runDirsearch(outDir, web, wordlist, extra)
+		default:
+			entries, err = runFfuf(filepath.Join(outDir, "ffuf.json"), web, wordlist, extra)
+		}
+		if err != nil {
+			logFn("[!] " + err.Error())
+		}
+		limited := 0
+		for _, e := range entries {
+			if e.Status == 429 {
+				limited++
+			}
+		}
+		if limited > 0 {
+			logFn(fmt.Sprintf("[!] %s got %d rate-limited (429) answer(s); consider a lower rate or WAF_RATE/WAF_DELAY", fuzzer, limited))
+		}
+		var added int
+		merged, added = mergeFfufEntries(merged, entries)
+		logFn(fmt.Sprintf("[*] %s fuzzing completed, found %d entries, %d new", fuzzer, len(entries), added))
+	}
+	var dropped int
+	if merged, dropped = dropSoft404(merged, Soft404For(result, web)); dropped > 0 {
+		logFn(soft404Note(web, "fuzzing", dropped))
+	}
+	// Hits that only redirect to the login would have the active checks
+	// hammer the login page.
+	if merged, dropped = dropLoginRedirects(merged); dropped > 0 {
+		logFn(fmt.Sprintf("[*] Dropped %d fuzzing hit(s) on %s that only redirect to a login page", dropped, web))
+	}
+	result.FfufEntries = merged
+	out, _ := parsers.MarshalFfufResults(merged)
+	_ = ioutil.WriteFile(filepath.Join(outDir, "ffuf_results.json"), out, 0644)
+}
+
+// adaptiveWordlistMax reads ADAPTIVE_WORDLIST_MAX, the size cap of the
+// generated wordlist (default 5000).
+func adaptiveWordlistMax() int {
+	if n, err := strconv.Atoi(os.Getenv("ADAPTIVE_WORDLIST_MAX")); err == nil && n > 0 {
+		return n
+	}
+	return 5000
+}
+
+// jsRoutes extracts the routes quoted in the scripts the secret scanning
+// stage downloaded to outDir/js/, in file name order.
+func jsRoutes(outDir string) []string {
+	files, _ := filepath.Glob(filepath.Join(outDir, "js", "*"))
+	sort.Strings(files)
+	var routes []string
+	for _, f := range files {
+		if data, err := ioutil.ReadFile(f); err == nil {
+			routes = append(routes, utils.ExtractJSRoutes(data)...)
+		}
+	}
+	return routes
+}
+
+// RunAdaptiveFuzzing generates a wordlist from the run's own URLs,
+// JavaScript routes, parameter names, subdomain labels and page titles,
+// writes it to generated_wordlist.txt, and fuzzes the target a second time
+// with ffuf using it. Entries the first pass did not find are added to
+// result.FfufEntries. A target slow to answer gets a smaller wordlist.
+func RunAdaptiveFuzzing(target, outDir string, result *types.ScanResult, wafDetected bool, logFn func(string)) {
+	web, ok := WebTarget(result, target)
+	if !ok {
+		logFn("[*] Skipping adaptive fuzzing: " + target + " did not answer HTTP")
+		return
+	}
+	timing := HostTimingFor(result, web)
+	src := utils.WordlistSourcesFrom(*result)
+	src.JSRoutes = jsRoutes(outDir)
+	words := utils.GenerateWordlist(src, target, fuzzBudget(adaptiveWordlistMax(), timing))
+	wordlist := filepath.Join(outDir, "generated_wordlist.txt")
+	if err := utils.WriteLines(words, wordlist); err != nil {
+		logFn("[!] adaptive fuzzing skipped: " + err.Error())
 		return
 	}
-	// Parse ffuf results.
+	logFn(fmt.Sprintf("[*] Running ffuf with a generated wordlist of %d word(s)...", len(words)))
+	args := WAFArgs("ffuf", wafDetected)
+	if !wafDetected {
+		if slowServer(timing) {
+			logFn(slowServerNote(web, timing))
+		}
+		args = SlowServerArgs("ffuf", timing)
+	}
+	entries, err := runFfuf(filepath.Join(outDir, "ffuf_adaptive.json"), web, wordlist, args)
+	if err != nil {
+		logFn("[!] " + err.Error())
+	}
+	var dropped, added int
+	if entries, dropped = dropSoft404(entries, Soft404For(result, web)); dropped > 0 {
+		logFn(soft404Note(web, "adaptive fuzzing", dropped))
+	}
+	if entries, dropped = dropLoginRedirects(entries); dropped > 0 {
+		logFn(fmt.Sprintf("[*] Dropped %d adaptive fuzzing hit(s) on %s that only redirect to a login page", dropped, web))
+	}
+	result.FfufEntries, added = mergeFfufEntries(result.FfufEntries, entries)
+	logFn(fmt.Sprintf("[*] Adaptive fuzzing completed, found %d new entries", added))
+}
+
+// runFfuf fuzzes web with ffuf, passing it extra arguments, and parses the
+// JSON report it writes to ffufOut.
+func runFfuf(ffufOut, web, wordlist string, extra []string) ([]types.FfufResult, error) {
+	args := append([]string{
+		"-w", wordlist + ":FUZZ",
+		"-u", web + "/FUZZ",
+		"-of", "json", "-o", ffufOut}, extra...)
+	_, runErr := utils.RunCommand("ffuf", args...)
+	// ffuf may have been killed after writing part of its output; parse what is there.
 	data, err := ioutil.ReadFile(ffufOut)
 	if err != nil {
-		logFn("[!] Failed to read ffuf output: " + err.Error())
-		return
+		if runErr != nil {
+			return nil, fmt.Errorf("ffuf error: %v", runErr)
+		}
+		return nil, fmt.Errorf("failed to read ffuf output: %v", err)
+	}
+	entries, err := parsers.ParseFfufOutput(data)
+	if err == nil && runErr != nil {
+		err = fmt.Errorf("ffuf error: %v", runErr)
 	}
-	var ffufResults []main.FfufResult
-	scanner := bufio.NewScanner(strings.NewReader(string(data)))
-	for scanner.Scan() {
-		var entry main.FfufResult
-		if err := json.Unmarshal([]byte(scanner.Text()), &entry); err == nil {
-			ffufResults = append(ffufResults, entry)
+	return entries, err
+}
+
+// runGobuster fuzzes web with gobuster dir, passing it extra arguments,
+// and parses the gobuster.txt it writes.
+func runGobuster(outDir, web, wordlist string, extra []string) ([]types.FfufResult, error) {
+	gobusterOut := filepath.Join(outDir, "gobuster.txt")
+	args := append([]string{"dir",
+		"-u", web, "-w", wordlist,
+		"-q", "--no-progress", "--no-color", "-o", gobusterOut}, extra...)
+	_, runErr := utils.RunCommand("gobuster", args...)
+	data, err := ioutil.ReadFile(gobusterOut)
+	if err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("gobuster error: %v", runErr)
 		}
+		return nil, fmt.Errorf("failed to read gobuster output: %v", err)
+	}
+	entries, err := parsers.ParseGobusterOutput(string(data))
+	for i := range entries {
+		if entries[i].URL == "" {
+			entries[i].URL = strings.TrimSuffix(web, "/") + "/" + entries[i].Path
+		}
+	}
+	if err == nil && runErr != nil {
+		err = fmt.Errorf("gobuster error: %v", runErr)
+	}
+	return entries, err
+}
+
+// runDirsearch fuzzes web with dirsearch, passing it extra arguments, and
+// parses the JSON report it writes to dirsearch.json.
+func runDirsearch(outDir, web, wordlist string, extra []string) ([]types.FfufResult, error) {
+	report := filepath.Join(outDir, "dirsearch.json")
+	args := append([]string{
+		"-u", web, "-w", wordlist,
+		"--format", "json", "-o", report, "-q", "--no-color"}, extra...)
+	_, runErr := utils.RunCommand("dirsearch", args...)
+	data, err := ioutil.ReadFile(report)
+	if err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("dirsearch error: %v", runErr)
+		}
+		return nil, fmt.Errorf("failed to read dirsearch output: %v", err)
+	}
+	entries, err := parsers.ParseDirsearchReport(data)
+	if err == nil && runErr != nil {
+		err = fmt.Errorf("dirsearch error: %v", runErr)
 	}
-	result.FfufEntries = ffufResults
-	logFn(fmt.Sprintf("[*] ffuf fuzzing completed, found %d entries", len(ffufResults)))
+	return entries, err
 }
@@ -0,0 +1,49 @@
+package scanners
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestMergeFfufEntries(t *testing.T) {
+	ffuf := []types.FfufResult{{Path: "admin", Status: 301, Size: 0}, {Path: ".env", Status: 200, Size: 412}}
+	tests := []struct {
+		name  string
+		src   []types.FfufResult
+		want  []types.FfufResult
+		added int
+	}{
+		{"nothing new", []types.FfufResult{{Path: "admin", Status: 301, Size: 5, URL: "https://www.example.com/admin"}}, ffuf, 0},
+		{"new path", []types.FfufResult{{Path: "backup.zip", Status: 200}}, append(append([]types.FfufResult(nil), ffuf...), types.FfufResult{Path: "backup.zip", Status: 200}), 1},
+		{"same path, other status", []types.FfufResult{{Path: ".env", Status: 403}}, append(append([]types.FfufResult(nil), ffuf...), types.FfufResult{Path: ".env", Status: 403}), 1},
+		{"repeats within the source", []types.FfufResult{{Path: "api", Status: 429}, {Path: "api", Status: 429}},
+			append(append([]types.FfufResult(nil), ffuf...), types.FfufResult{Path: "api", Status: 429}), 1},
+	}
+	for _, tt := range tests {
+		got, added := mergeFfufEntries(append([]types.FfufResult(nil), ffuf...), tt.src)
+		if !reflect.DeepEqual(got, tt.want) || added != tt.added {
+			t.Errorf("%s: %+v, %d added; want %+v, %d", tt.name, got, added, tt.want, tt.added)
+		}
+	}
+}
+
+func TestExtensionArgs(t *testing.T) {
+	exts := []string{"php", "bak"}
+	for _, tt := range []struct {
+		fuzzer string
+		exts   []string
+		want   []string
+	}{
+		{"ffuf", exts, []string{"-e", ".php,.bak"}},
+		{"gobuster", exts, []string{"-x", "php,bak"}},
+		{"dirsearch", exts, []string{"-e", "php,bak"}},
+		{"dirsearch", nil, nil},
+		{"wfuzz", exts, nil},
+	} {
+		if got := extensionArgs(tt.fuzzer, tt.exts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("extensionArgs(%s, %q) = %q, want %q", tt.fuzzer, tt.exts, got, tt.want)
+		}
+	}
+}
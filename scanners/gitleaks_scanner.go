@@ -0,0 +1,195 @@
+// scanners/gitleaks_scanner.go - Secrets in exposed Git repositories with gitleaks.
+package scanners
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	defaultCloneTimeout = 5 * time.Minute
+	defaultCloneMaxMB   = 200
+	cloneSizeInterval   = 2 * time.Second
+)
+
+// gitleaksSettings reads GITLEAKS_CLONE_TIMEOUT (a per-repository Go
+// duration such as "5m") and GITLEAKS_MAX_REPO_MB, the size a clone is
+// stopped at.
+func gitleaksSettings() (timeout time.Duration, maxBytes int64) {
+	timeout, maxBytes = defaultCloneTimeout, defaultCloneMaxMB<<20
+	if d, err := time.ParseDuration(os.Getenv("GITLEAKS_CLONE_TIMEOUT")); err == nil && d > 0 {
+		timeout = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("GITLEAKS_MAX_REPO_MB")); err == nil && n > 0 {
+		maxBytes = int64(n) << 20
+	}
+	return timeout, maxBytes
+}
+
+// ExposedRepos returns the .git directories the exposure checks confirmed,
+// followed by those listed in GITLEAKS_REPOS (comma-separated URLs of .git
+// directories), each ending in a slash.
+func ExposedRepos(result *types.ScanResult) []string {
+	var repos []string
+	for _, v := range result.VulnURLs {
+		if v.Type == "exposed-git" && strings.HasSuffix(v.URL, "/.git/HEAD") {
+			repos = append(repos, strings.TrimSuffix(v.URL, "HEAD"))
+		}
+	}
+	for _, r := range strings.Split(os.Getenv("GITLEAKS_REPOS"), ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			repos = append(repos, strings.TrimSuffix(r, "/")+"/")
+		}
+	}
+	return utils.UniqueStrings(repos)
+}
+
+// dirSize returns the total size of the regular files under dir.
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// cloneRepo downloads the repository at repo into dir with git-dumper, which
+// rebuilds repositories whose server lacks the dumb HTTP index, or else
+// with git clone. The clone is killed after timeout or once dir grows past
+// maxBytes.
+func cloneRepo(repo, dir string, timeout time.Duration, maxBytes int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	tooLarge := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(cloneSizeInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if dirSize(dir) > maxBytes {
+					close(tooLarge)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	var err error
+	if _, lookErr := exec.LookPath("git-dumper"); lookErr == nil {
+		_, err = utils.RunCommandContext(ctx, "git-dumper", repo, dir)
+	} else {
+		_, err = utils.RunCommandContext(ctx, "git", "clone", "--quiet", "--no-checkout", repo, dir)
+	}
+	close(done)
+	select {
+	case <-tooLarge:
+		return fmt.Errorf("clone stopped at the %d MB size limit", maxBytes>>20)
+	default:
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("clone timed out after %s", timeout)
+	}
+	return err
+}
+
+// RunGitleaks clones every exposed Git repository into outDir/repos/ and
+// scans its history with gitleaks. Each finding is reported at the
+// repository URL with the file as fragment and the secret redacted, and
+// the findings are written to gitleaks.json; the secrets are merged into
+// result.Secrets. With verifyClient set, the secrets of classes providers
+// can check are verified with them. The clones and gitleaks' own reports,
+// which hold the secrets in full, are removed afterwards unless
+// keepArtifacts is set.
+func RunGitleaks(outDir string, result *types.ScanResult, keepArtifacts bool, verifyClient *http.Client, logFn func(string)) {
+	repos := ExposedRepos(result)
+	if len(repos) == 0 {
+		logFn("[*] No exposed Git repositories to scan for secrets.")
+		return
+	}
+	if _, err := exec.LookPath("gitleaks"); err != nil {
+		logFn("[!] gitleaks not found in PATH; skipping repository secret scanning")
+		return
+	}
+	reposDir := filepath.Join(outDir, "repos")
+	if err := os.MkdirAll(reposDir, 0755); err != nil {
+		logFn("[!] repository secret scanning skipped: " + err.Error())
+		return
+	}
+	if keepArtifacts {
+		logFn("[*] Keeping cloned repositories in " + reposDir)
+	} else {
+		defer os.RemoveAll(reposDir)
+	}
+	timeout, maxBytes := gitleaksSettings()
+	logFn(fmt.Sprintf("[*] Cloning %d exposed Git repositories, %s and %d MB each at most...", len(repos), timeout, maxBytes>>20))
+	var verify func(class, secret string) string
+	if verifyClient != nil {
+		verify = SecretVerifier(verifyClient)
+	}
+	var findings []types.VulnerabilityResult
+	for i, repo := range repos {
+		name := fmt.Sprintf("%02d-repo", i)
+		if u, err := url.Parse(repo); err == nil && u.Host != "" {
+			name = fmt.Sprintf("%02d-%s", i, jsNameRe.ReplaceAllString(u.Host, "_"))
+		}
+		dir := filepath.Join(reposDir, name)
+		if err := cloneRepo(repo, dir, timeout, maxBytes); err != nil {
+			logFn("[!] " + repo + ": " + err.Error())
+			continue
+		}
+		report := dir + ".gitleaks.json"
+		_, runErr := utils.RunCommand("gitleaks", "detect", "--source", dir,
+			"--report-format", "json", "--report-path", report, "--no-banner", "--exit-code", "0")
+		data, err := ioutil.ReadFile(report)
+		if err != nil {
+			if runErr != nil {
+				err = runErr
+			}
+			logFn("[!] gitleaks error on " + repo + ": " + err.Error())
+			continue
+		}
+		found, secrets, err := parsers.ParseGitleaksReport(data, verify)
+		if err != nil {
+			logFn("[!] " + err.Error())
+		}
+		for j := range found {
+			found[j].URL = repo + "#" + found[j].URL
+			logFn(fmt.Sprintf("[!] %s: %s", found[j].Issue, found[j].URL))
+		}
+		for j := range secrets {
+			for k := range secrets[j].Locations {
+				secrets[j].Locations[k].Artifact = repo + "#" + secrets[j].Locations[k].Artifact
+			}
+		}
+		result.Secrets = utils.MergeSecrets(result.Secrets, secrets...)
+		findings = append(findings, found...)
+	}
+	result.VulnURLs = append(result.VulnURLs, findings...)
+	if findings == nil {
+		findings = []types.VulnerabilityResult{}
+	}
+	out, _ := json.MarshalIndent(findings, "", "  ")
+	_ = ioutil.WriteFile(filepath.Join(outDir, "gitleaks.json"), out, 0644)
+	logFn(fmt.Sprintf("[*] Repository secret scanning complete, %d secret(s).", len(findings)))
+}
@@ -0,0 +1,24 @@
+//go:build h3
+
+// scanners/http3_probe.go - HTTP/3 support via quic-go, built with -tags h3.
+package scanners
+
+import (
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+func init() {
+	t := &http3.Transport{}
+	http3Transport = t
+	http3Probe = func(base string) bool {
+		client := noRedirectClient(&http.Client{Transport: t})
+		resp, err := client.Get(base + "/")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return true
+	}
+}
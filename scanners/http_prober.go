@@ -0,0 +1,257 @@
+// scanners/http_prober.go - HTTP probing of live hosts on ports 80 and 443.
+package scanners
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	httpProbeWorkers = 10
+	// httpProbeMaxBody caps how much of a page is read looking for its title.
+	httpProbeMaxBody = 256 << 10
+	httpTitleMax     = 100
+)
+
+var titleRe = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// pageTitle extracts the unescaped, whitespace-collapsed <title> of body.
+func pageTitle(body []byte) string {
+	m := titleRe.FindSubmatch(body)
+	if m == nil {
+		return ""
+	}
+	title := strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+	if len(title) > httpTitleMax {
+		title = title[:httpTitleMax] + "..."
+	}
+	return title
+}
+
+// ProbeHTTP requests https://host/ and, failing that, http://host/ without
+// following redirects. Any HTTP response counts as an answer; one whose
+// page matches sigs is tagged as a placeholder.
+func ProbeHTTP(client *http.Client, host string, sigs []PlaceholderSignature) types.LiveHost {
+	return probeOrigin(client, host, 0, sigs, "https", "http")
+}
+
+// probeOrigin requests / on host:port with each scheme in turn until one
+// answers, classifies its page against sigs, then fingerprints how it
+// answers paths that do not exist. Port 0 means each scheme's default port.
+func probeOrigin(client *http.Client, host string, port int, sigs []PlaceholderSignature, schemes ...string) types.LiveHost {
+	lh := types.LiveHost{Hostname: host}
+	for _, scheme := range schemes {
+		base := utils.OriginURL(scheme, host, port)
+		resp, err := client.Get(base + "/")
+		if err != nil {
+			lh.Error = err.Error()
+			continue
+		}
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, httpProbeMaxBody))
+		resp.Body.Close()
+		lh.URL = base
+		lh.Port = port
+		if port == 0 {
+			lh.Port = utils.DefaultPort(scheme)
+		}
+		lh.StatusCode = resp.StatusCode
+		lh.ContentLength = resp.ContentLength
+		if lh.ContentLength < 0 {
+			lh.ContentLength = int64(len(body))
+		}
+		lh.Title = pageTitle(body)
+		if sig, ok := ClassifyPlaceholder(sigs, lh.Title, body); ok {
+			lh.Placeholder = sig.Name
+			lh.Tags = utils.AddTag(lh.Tags, utils.TagState+":"+sig.Kind)
+		}
+		lh.Server = resp.Header.Get("Server")
+		lh.Protocols = originProtocols(lh, resp)
+		lh.Soft404 = FingerprintSoft404(client, base)
+		lh.Error = ""
+		return lh
+	}
+	return lh
+}
+
+// isAltPort reports whether lh is an origin on a port other than 80 or 443.
+func isAltPort(lh types.LiveHost) bool {
+	return lh.Port != 0 && lh.Port != 80 && lh.Port != 443
+}
+
+// AnsweredHosts maps each host that answered the HTTP probe on port 443 or
+// 80 to its base URL, leaving out placeholder pages unless
+// utils.IncludeParked was set. probed is false when no probe ran, in which
+// case callers keep their unfiltered behavior.
+func AnsweredHosts(result *types.ScanResult) (hosts map[string]string, probed bool) {
+	if result.LiveHosts == nil {
+		return nil, false
+	}
+	hosts = make(map[string]string)
+	for _, lh := range result.LiveHosts {
+		if lh.URL != "" && !isAltPort(lh) && utils.ProbeOrigin(lh) {
+			hosts[lh.Hostname] = lh.URL
+		}
+	}
+	return hosts, true
+}
+
+// AltOrigins returns the base URLs of the web services found on ports
+// other than 80 and 443, placeholder pages left out as by AnsweredHosts.
+func AltOrigins(result *types.ScanResult) []string {
+	var urls []string
+	for _, lh := range result.LiveHosts {
+		if lh.URL != "" && isAltPort(lh) && utils.ProbeOrigin(lh) {
+			urls = append(urls, lh.URL)
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// RunHTTPProbe probes target and every host in live_hosts.txt, records the
+// results on result.LiveHosts and writes http_probe.json and web_hosts.txt,
+// the base URLs of the hosts that answered.
+func RunHTTPProbe(client *http.Client, target, outDir string, result *types.ScanResult, logFn func(string)) {
+	data, err := ioutil.ReadFile(filepath.Join(outDir, "live_hosts.txt"))
+	if err != nil {
+		logFn("[!] HTTP probing skipped: " + err.Error())
+		return
+	}
+	logFn("[*] Probing live hosts over HTTP...")
+	probe := noRedirectClient(client)
+	sigs := placeholderSignaturesFromEnv(logFn)
+	hosts := make(chan string)
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		live = []types.LiveHost{}
+	)
+	for i := 0; i < httpProbeWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for h := range hosts {
+				utils.Guard(func() {
+					lh := ProbeHTTP(probe, h, sigs)
+					mu.Lock()
+					live = append(live, lh)
+					mu.Unlock()
+				})
+			}
+		}()
+	}
+	for _, h := range utils.UniqueStrings(append([]string{target}, strings.Fields(string(data))...)) {
+		hosts <- h
+	}
+	close(hosts)
+	wg.Wait()
+
+	var web []string
+	for _, lh := range live {
+		if lh.URL == "" {
+			logFn(fmt.Sprintf("[*] No HTTP answer from %s: %s", lh.Hostname, lh.Error))
+			continue
+		}
+		web = append(web, lh.URL)
+		logFn(fmt.Sprintf("[*] HTTP %s [%d] %q %s", lh.URL, lh.StatusCode, lh.Title, lh.Server))
+		if lh.Placeholder != "" {
+			logFn(placeholderNote(lh))
+		}
+	}
+	result.LiveHosts = live
+	tagPlaceholderHosts(result)
+	_ = utils.WriteLines(web, filepath.Join(outDir, "web_hosts.txt"))
+	out, _ := json.MarshalIndent(live, "", "  ")
+	_ = ioutil.WriteFile(filepath.Join(outDir, "http_probe.json"), out, 0644)
+	logFn(fmt.Sprintf("[*] HTTP probing complete, %d of %d host(s) answered.", len(web), len(live)))
+}
+
+// writeProbeFiles rewrites http_probe.json and web_hosts.txt from
+// result.LiveHosts after hosts were added to it.
+func writeProbeFiles(outDir string, result *types.ScanResult) {
+	var web []string
+	for _, lh := range result.LiveHosts {
+		if lh.URL != "" {
+			web = append(web, lh.URL)
+		}
+	}
+	_ = utils.WriteLines(web, filepath.Join(outDir, "web_hosts.txt"))
+	out, _ := json.MarshalIndent(result.LiveHosts, "", "  ")
+	_ = ioutil.WriteFile(filepath.Join(outDir, "http_probe.json"), out, 0644)
+}
+
+// WebTarget returns the URL to scan target at: its probed base URL, or
+// http://target when no probe ran. ok is false when target did not answer
+// or serves a placeholder page left out by AnsweredHosts.
+func WebTarget(result *types.ScanResult, target string) (u string, ok bool) {
+	hosts, probed := AnsweredHosts(result)
+	if !probed {
+		return utils.OriginURL("http", target, 0), true
+	}
+	u, ok = hosts[target]
+	return u, ok
+}
+
+// WebTargets returns the base URLs of every origin that answered the HTTP
+// probe, alternative ports included, or, when no probe ran, https:// URLs
+// for the hosts in live_hosts.txt. Origins outside the scope materialized
+// in outDir are left out.
+func WebTargets(outDir string, result *types.ScanResult) ([]string, error) {
+	var urls []string
+	if hosts, probed := AnsweredHosts(result); probed {
+		for _, u := range hosts {
+			urls = append(urls, u)
+		}
+		sort.Strings(urls)
+		urls = append(urls, AltOrigins(result)...)
+	} else {
+		data, err := ioutil.ReadFile(filepath.Join(outDir, "live_hosts.txt"))
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range strings.Fields(string(data)) {
+			urls = append(urls, utils.OriginURL("https", h, 0))
+		}
+	}
+	if scope, err := utils.ScopeFromFiles(outDir); err == nil {
+		urls, _ = scope.FilterURLs(urls)
+	}
+	return urls, nil
+}
+
+// FilterAnswered keeps the URLs whose host answered the HTTP probe on any
+// port; with no probe every URL is kept.
+func FilterAnswered(result *types.ScanResult, urls []string) (kept []string, dropped int) {
+	if result.LiveHosts == nil {
+		return urls, 0
+	}
+	hosts := make(map[string]bool)
+	for _, lh := range result.LiveHosts {
+		if lh.URL != "" {
+			hosts[lh.Hostname] = true
+		}
+	}
+	for _, raw := range urls {
+		if u, err := url.Parse(raw); err == nil {
+			if hosts[u.Hostname()] {
+				kept = append(kept, raw)
+				continue
+			}
+		}
+		dropped++
+	}
+	return kept, dropped
+}
@@ -0,0 +1,54 @@
+package scanners
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// TestWebTargetsScope checks that per-host tools such as nikto only get
+// the origins the materialized scope allows.
+func TestWebTargetsScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		result types.ScanResult
+		live   string
+		want   []string
+	}{
+		{
+			name: "probed",
+			result: types.ScanResult{LiveHosts: []types.LiveHost{
+				{Hostname: "www.example.com", URL: "https://www.example.com"},
+				{Hostname: "legacy.example.com", URL: "https://legacy.example.com"},
+				{Hostname: "shop.partner.io", URL: "http://shop.partner.io"},
+			}},
+			want: []string{"https://www.example.com"},
+		},
+		{
+			name: "from live_hosts.txt",
+			live: "www.example.com\nlegacy.example.com\nshop.partner.io\n",
+			want: []string{"https://www.example.com"},
+		},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		if err := utils.NewScopeFilter("example.com", nil, []string{"legacy.example.com"}).WriteFiles(dir); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "live_hosts.txt"), []byte(tt.live), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := WebTargets(dir, &tt.result)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: WebTargets = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
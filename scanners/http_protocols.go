@@ -0,0 +1,119 @@
+// scanners/http_protocols.go - HTTP protocol support of probed origins.
+package scanners
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// HTTP/3 needs quic-go and is only compiled in with the h3 build tag (see
+// http3_probe.go); in the default build both stay nil.
+var (
+	// http3Probe requests base over HTTP/3 and reports whether it answered.
+	http3Probe func(base string) bool
+	// http3Transport sends requests over HTTP/3.
+	http3Transport http.RoundTripper
+)
+
+// HTTP3Allowed gates HTTP/3 probing. main clears it in SOCKS5 mode: QUIC
+// runs over UDP, which the bastion does not carry.
+var HTTP3Allowed = true
+
+// altSvcH3 reports whether an Alt-Svc header value advertises HTTP/3.
+func altSvcH3(v string) bool {
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if strings.HasPrefix(entry, "h3=") || strings.HasPrefix(entry, "h3-") {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiatesH2 reports whether host:port selects h2 when offered over ALPN.
+func negotiatesH2(host string, port int) bool {
+	cfg := &tls.Config{ServerName: host, InsecureSkipVerify: true, NextProtos: []string{"h2", "http/1.1"}}
+	st, err := tlsHandshakeAddr(net.JoinHostPort(host, strconv.Itoa(port)), cfg)
+	return err == nil && st.NegotiatedProtocol == "h2"
+}
+
+// originProtocols lists the protocols an origin that answered the probe
+// speaks: the one resp came over, h2 when ALPN selects it, and h3 when the
+// origin advertises it in Alt-Svc and answers over it (h3 builds only).
+func originProtocols(lh types.LiveHost, resp *http.Response) []string {
+	var protos []string
+	add := func(p string) {
+		for _, have := range protos {
+			if have == p {
+				return
+			}
+		}
+		protos = append(protos, p)
+	}
+	if resp.ProtoMajor == 2 {
+		add("h2")
+	} else {
+		add("http/1.1")
+	}
+	if !strings.HasPrefix(lh.URL, "https://") {
+		return protos
+	}
+	if negotiatesH2(lh.Hostname, lh.Port) {
+		add("h2")
+	}
+	if http3Probe != nil && HTTP3Allowed && altSvcH3(resp.Header.Get("Alt-Svc")) && http3Probe(lh.URL) {
+		add("h3")
+	}
+	return protos
+}
+
+// protocolTransport sends requests for the origins in h3Origins over
+// HTTP/3 and everything else through base, whose ALPN already settles on
+// h2 wherever the origin offers it.
+type protocolTransport struct {
+	base, h3  http.RoundTripper
+	h3Origins map[string]bool
+}
+
+func (t *protocolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.h3Origins[req.URL.Scheme+"://"+req.URL.Host] {
+		resp, err := t.h3.RoundTrip(req)
+		// Only bodiless requests can be retried over TCP.
+		if err == nil || req.Body != nil {
+			return resp, err
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// PreferProtocols returns client set up to reach every origin that
+// answered the HTTP probe over HTTP/3 that way. Without HTTP/3 support, or
+// with no such origin, client is returned unchanged.
+func PreferProtocols(client *http.Client, result *types.ScanResult) *http.Client {
+	if http3Transport == nil || !HTTP3Allowed {
+		return client
+	}
+	origins := make(map[string]bool)
+	for _, lh := range result.LiveHosts {
+		for _, p := range lh.Protocols {
+			if p == "h3" {
+				origins[lh.URL] = true
+			}
+		}
+	}
+	if len(origins) == 0 {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c := *client
+	c.Transport = &protocolTransport{base: base, h3: http3Transport, h3Origins: origins}
+	return &c
+}
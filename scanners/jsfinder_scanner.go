@@ -0,0 +1,118 @@
+// scanners/jsfinder_scanner.go - Endpoints and subdomains from JavaScript with JSFinder.
+package scanners
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// urlKey identifies a URL for deduplication: scheme and host lower-cased,
+// default ports and the fragment dropped, and an empty path taken as "/".
+func urlKey(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
+		u.Host = u.Hostname()
+	}
+	u.Fragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
+
+// jsfinderOrigins returns the in-scope base URLs relative endpoints are
+// resolved against: every origin that answered the HTTP probe, or web
+// alone when no probe ran.
+func jsfinderOrigins(result *types.ScanResult, web string, inScope func(string) bool) []string {
+	hosts, probed := AnsweredHosts(result)
+	if !probed {
+		return []string{web}
+	}
+	var origins []string
+	for _, u := range hosts {
+		if inScope(u) {
+			origins = append(origins, u)
+		}
+	}
+	sort.Strings(origins)
+	return origins
+}
+
+// RunJSFinder extracts the endpoints and subdomains referenced by the
+// target's JavaScript with JSFinder. Its URL list is written to
+// endpoints.txt. Relative endpoints are resolved against every live
+// origin. The in-scope endpoints are written to js_endpoints.txt, and those
+// not already among result.AllURLs, crawled ones included, join it. The
+// subdomains JSFinder found are returned so in-scope ones can join the
+// scan.
+func RunJSFinder(target, outDir string, result *types.ScanResult, inScope func(string) bool, logFn func(string)) []string {
+	if _, err := exec.LookPath("JSFinder"); err != nil {
+		logFn("[!] JSFinder not found in PATH; skipping JavaScript endpoint extraction")
+		return nil
+	}
+	web, ok := WebTarget(result, target)
+	if !ok {
+		logFn("[*] Skipping JSFinder: " + target + " did not answer HTTP")
+		return nil
+	}
+	out, err := utils.RunCommand("JSFinder", "-u", web, "-ou", filepath.Join(outDir, "endpoints.txt"))
+	if err != nil {
+		logFn("[!] JSFinder error: " + err.Error())
+	}
+	found, err := parsers.ParseJSFinderOutput(out)
+	if err != nil {
+		logFn("[!] " + err.Error())
+	}
+	endpoints := found.URLs
+	for _, origin := range jsfinderOrigins(result, web, inScope) {
+		base, err := url.Parse(origin)
+		if err != nil {
+			continue
+		}
+		for _, p := range found.Paths {
+			if ref, err := url.Parse(p); err == nil {
+				endpoints = append(endpoints, base.ResolveReference(ref).String())
+			}
+		}
+	}
+	seen := make(map[string]bool, len(result.AllURLs))
+	for _, u := range result.AllURLs {
+		seen[urlKey(u)] = true
+	}
+	var kept []string
+	added, dropped := 0, 0
+	for _, u := range utils.UniqueStrings(endpoints) {
+		if !inScope(u) {
+			dropped++
+			continue
+		}
+		kept = append(kept, u)
+		if key := urlKey(u); !seen[key] {
+			seen[key] = true
+			result.AllURLs = append(result.AllURLs, u)
+			added++
+		}
+	}
+	if dropped > 0 {
+		logFn(fmt.Sprintf("[*] JSFinder: dropped %d out-of-scope URL(s)", dropped))
+	}
+	if err := utils.WriteLines(kept, filepath.Join(outDir, "js_endpoints.txt")); err != nil {
+		logFn("[!] JSFinder error: " + err.Error())
+	}
+	logFn(fmt.Sprintf("[*] JSFinder found %d endpoint(s) (%d relative), %d new; %d subdomain(s)",
+		len(kept), len(found.Paths), added, len(found.Subdomains)))
+	return found.Subdomains
+}
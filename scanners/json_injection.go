@@ -0,0 +1,395 @@
+// scanners/json_injection.go - Injection candidates in the JSON bodies of captured API requests.
+package scanners
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	// jsonCandidateMaxDepth is how deep into nested objects and arrays
+	// string fields are looked for; the body itself is depth 0.
+	jsonCandidateMaxDepth = 8
+	// jsonCandidateMaxFields caps the candidates built from one request.
+	jsonCandidateMaxFields = 50
+	// defaultJSONInjectionMax is JSON_INJECTION_MAX when unset.
+	defaultJSONInjectionMax = 50
+	// sqlmapMarker marks the injection point in a sqlmap request file.
+	sqlmapMarker = "*"
+)
+
+// jsonKeyRe matches object keys that can be written after a dot in a
+// JSON path; others are quoted in brackets.
+var jsonKeyRe = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$-]*$`)
+
+// httpTokenRe matches HTTP methods and header names.
+var httpTokenRe = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// JSONCandidate is one string field of a captured request's JSON body,
+// to inject into.
+type JSONCandidate struct {
+	Template types.APIRequestTemplate
+	// Path names the field from the body down, as body.filters.name or
+	// body.items[0].id.
+	Path string
+	// Original is the field's value in the captured request.
+	Original string
+
+	body interface{}
+	// at is the way to the field: object keys and array indexes.
+	at []interface{}
+}
+
+// Body returns the captured body with the field set to value.
+func (c JSONCandidate) Body(value string) ([]byte, error) {
+	return json.Marshal(setJSONField(c.body, c.at, value))
+}
+
+// setJSONField returns v with the field at path set to value, copying the
+// objects and arrays on the way so v itself is left as it was.
+func setJSONField(v interface{}, path []interface{}, value string) interface{} {
+	if len(path) == 0 {
+		return value
+	}
+	switch step := path[0].(type) {
+	case string:
+		obj := v.(map[string]interface{})
+		out := make(map[string]interface{}, len(obj))
+		for k, child := range obj {
+			out[k] = child
+		}
+		out[step] = setJSONField(obj[step], path[1:], value)
+		return out
+	case int:
+		arr := v.([]interface{})
+		out := append([]interface{}(nil), arr...)
+		out[step] = setJSONField(arr[step], path[1:], value)
+		return out
+	}
+	return v
+}
+
+// templateBody returns the JSON body of t; a body captured as a string is
+// unquoted first.
+func templateBody(t types.APIRequestTemplate) ([]byte, error) {
+	raw := bytes.TrimSpace(t.Body)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		raw = bytes.TrimSpace([]byte(s))
+	}
+	return raw, nil
+}
+
+// BuildJSONCandidates returns a candidate for every string field of t's
+// JSON body, in nested objects and arrays too, down to
+// jsonCandidateMaxDepth levels and at most jsonCandidateMaxFields of them,
+// in path order. A request without a body has none; a body that is not
+// JSON is an error.
+func BuildJSONCandidates(t types.APIRequestTemplate) ([]JSONCandidate, error) {
+	raw, err := templateBody(t)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: body: %v", t.Method, t.URL, err)
+	}
+	if raw == nil {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	// Numbers stay as written rather than turning into floats.
+	dec.UseNumber()
+	var body interface{}
+	if err := dec.Decode(&body); err != nil {
+		return nil, fmt.Errorf("%s %s: body is not JSON: %v", t.Method, t.URL, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("%s %s: body is not JSON: data after the value", t.Method, t.URL)
+	}
+	var candidates []JSONCandidate
+	var walk func(v interface{}, path string, at []interface{}, depth int)
+	walk = func(v interface{}, path string, at []interface{}, depth int) {
+		if len(candidates) >= jsonCandidateMaxFields {
+			return
+		}
+		switch v := v.(type) {
+		case string:
+			candidates = append(candidates, JSONCandidate{
+				Template: t,
+				Path:     path,
+				Original: v,
+				body:     body,
+				at:       append([]interface{}(nil), at...),
+			})
+		case map[string]interface{}:
+			if depth >= jsonCandidateMaxDepth {
+				return
+			}
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				child := path + "." + k
+				if !jsonKeyRe.MatchString(k) {
+					child = path + "[" + strconv.Quote(k) + "]"
+				}
+				walk(v[k], child, append(at, k), depth+1)
+			}
+		case []interface{}:
+			if depth >= jsonCandidateMaxDepth {
+				return
+			}
+			for i, elem := range v {
+				walk(elem, fmt.Sprintf("%s[%d]", path, i), append(at, i), depth+1)
+			}
+		}
+	}
+	walk(body, "body", nil, 0)
+	return candidates, nil
+}
+
+// JSONRequestFile renders t as an HTTP/1.1 request with body, in the form
+// sqlmap reads with -r. Host, Content-Length and Content-Type are set from
+// the request itself; a captured Content-Type is kept when it names a JSON
+// type. Headers for which keep returns false are left out.
+func JSONRequestFile(t types.APIRequestTemplate, body []byte, keep func(name, value string) bool) ([]byte, error) {
+	u, err := url.Parse(t.URL)
+	if err != nil {
+		return nil, err
+	}
+	method := t.Method
+	if method == "" {
+		method = "POST"
+	}
+	if !httpTokenRe.MatchString(method) {
+		return nil, fmt.Errorf("invalid method %q", method)
+	}
+	contentType := "application/json"
+	names := make([]string, 0, len(t.Headers))
+	for name, value := range t.Headers {
+		if !httpTokenRe.MatchString(name) || strings.ContainsAny(value, "\r\n\x00") {
+			return nil, fmt.Errorf("invalid header %q", name)
+		}
+		switch http.CanonicalHeaderKey(name) {
+		case "Host", "Content-Length", "Transfer-Encoding":
+			continue
+		case "Content-Type":
+			if strings.Contains(strings.ToLower(value), "json") {
+				contentType = value
+			}
+			continue
+		}
+		if keep == nil || keep(name, value) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\n", method, u.RequestURI())
+	fmt.Fprintf(&b, "Host: %s\r\n", u.Host)
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\r\n", name, t.Headers[name])
+	}
+	fmt.Fprintf(&b, "Content-Type: %s\r\n", contentType)
+	fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n", len(body))
+	b.Write(body)
+	return b.Bytes(), nil
+}
+
+// jsonInjectionMax reads JSON_INJECTION_MAX, how many JSON fields are
+// tested with sqlmap and the reflection check.
+func jsonInjectionMax() int {
+	if n, err := strconv.Atoi(os.Getenv("JSON_INJECTION_MAX")); err == nil && n > 0 {
+		return n
+	}
+	return defaultJSONInjectionMax
+}
+
+// attributeJSON points a finding at the JSON field it was found in.
+func attributeJSON(v types.VulnerabilityResult, c JSONCandidate) types.VulnerabilityResult {
+	v.Parameter = c.Path + " (JSON " + c.Template.Method + ")"
+	v.Detail = "JSON field " + c.Path + ": " + v.Detail
+	return v
+}
+
+// sqlmapJSON runs sqlmap against the candidate's field, marked with
+// sqlmapMarker after its captured value, through a request file in dir.
+func sqlmapJSON(c JSONCandidate, dir string, n int, wafArgs []string) ([]types.VulnerabilityResult, error) {
+	body, err := c.Body(c.Original + sqlmapMarker)
+	if err != nil {
+		return nil, err
+	}
+	// sqlmap takes every marker in the file as an injection point; headers
+	// such as Accept: */* would add their own.
+	req, err := JSONRequestFile(c.Template, body, func(_, value string) bool {
+		return !strings.Contains(value, sqlmapMarker)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if strings.Count(string(req), sqlmapMarker) != 1 {
+		return nil, errors.New("the request contains " + sqlmapMarker + " outside the field; skipped")
+	}
+	file := filepath.Join(dir, fmt.Sprintf("%03d.req", n))
+	if err := ioutil.WriteFile(file, req, 0644); err != nil {
+		return nil, err
+	}
+	args := []string{"-r", file, "--batch"}
+	if strings.HasPrefix(c.Template.URL, "https://") {
+		args = append(args, "--force-ssl")
+	}
+	out, err := utils.RunCommand("sqlmap", append(args, wafArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	var findings []types.VulnerabilityResult
+	for _, v := range parsers.ParseSqlmapOutput(out, c.Template.URL) {
+		findings = append(findings, attributeJSON(v, c))
+	}
+	return findings, nil
+}
+
+// CheckJSONReflection sends the candidate's request with a benign marker
+// in its field and reports a Content-Type mismatch when the marker comes
+// back in a response browsers would render or sniff as HTML. answered is
+// false when the request got no response.
+func CheckJSONReflection(client *http.Client, c JSONCandidate) (findings []types.VulnerabilityResult, answered bool) {
+	marker := newReflectionMarker()
+	body, err := c.Body(marker)
+	if err != nil {
+		return nil, false
+	}
+	req, err := http.NewRequest(c.Template.Method, c.Template.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, false
+	}
+	for name, value := range c.Template.Headers {
+		req.Header.Set(name, value)
+	}
+	if !strings.Contains(strings.ToLower(req.Header.Get("Content-Type")), "json") {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, false
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, rfdMaxBody))
+	h := resp.Header
+	if !reflects(respBody, marker) || strings.EqualFold(strings.TrimSpace(h.Get("X-Content-Type-Options")), "nosniff") {
+		return nil, true
+	}
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	v := types.VulnerabilityResult{URL: c.Template.URL, Issue: "Content-Type Mismatch", Type: "content-type-mismatch"}
+	switch mediaType {
+	case "text/html":
+		v.Detail = fmt.Sprintf("reflected in a text/html response without nosniff (%s)", responseEvidence(h))
+		v.Severity = "medium"
+	case "":
+		v.Detail = fmt.Sprintf("reflected in a response without Content-Type or nosniff (%s)", responseEvidence(h))
+		v.Severity = "low"
+	default:
+		return nil, true
+	}
+	return []types.VulnerabilityResult{attributeJSON(v, c)}, true
+}
+
+// RunJSONInjection tests the string fields of the JSON bodies of the
+// captured API requests in file (see parsers.ParseAPITemplates), at most
+// JSON_INJECTION_MAX of them. Each field of an in-scope request is checked
+// for reflection and then handed to sqlmap with the field marked; the
+// request files are kept in json_requests/. Findings name the field as
+// their parameter.
+func RunJSONInjection(file, outDir string, client *http.Client, result *types.ScanResult, inScope func(string) bool, wafDetected bool, logFn func(string)) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		logFn("[!] API templates: " + err.Error())
+		return
+	}
+	templates, err := parsers.ParseAPITemplates(data)
+	if err != nil {
+		logFn("[!] " + err.Error())
+	}
+	var candidates []JSONCandidate
+	dropped := 0
+	for _, t := range templates {
+		if !inScope(t.URL) {
+			dropped++
+			continue
+		}
+		cs, err := BuildJSONCandidates(t)
+		if err != nil {
+			logFn("[!] API templates: " + err.Error())
+		}
+		candidates = append(candidates, cs...)
+	}
+	if dropped > 0 {
+		logFn(fmt.Sprintf("[*] JSON injection: dropped %d out-of-scope request(s)", dropped))
+	}
+	if len(candidates) == 0 {
+		logFn("[*] No JSON body fields to test.")
+		return
+	}
+	if max := jsonInjectionMax(); len(candidates) > max {
+		logFn(fmt.Sprintf("[*] Testing %d of %d JSON field(s) (JSON_INJECTION_MAX)", max, len(candidates)))
+		candidates = candidates[:max]
+	}
+	logFn(fmt.Sprintf("[*] Testing %d JSON field(s) of %d captured request(s) for injection...", len(candidates), len(templates)-dropped))
+
+	probe := noRedirectClient(client)
+	for i, c := range candidates {
+		if i > 0 {
+			time.Sleep(endpointProbePause)
+		}
+		findings, _ := CheckJSONReflection(probe, c)
+		result.VulnURLs = append(result.VulnURLs, findings...)
+		for _, f := range findings {
+			logFn(fmt.Sprintf("[!] %s: %s %s", f.Issue, f.URL, c.Path))
+		}
+	}
+
+	if _, err := exec.LookPath("sqlmap"); err != nil {
+		logFn("[!] sqlmap not found in PATH; skipping JSON body SQL injection checks")
+		return
+	}
+	dir := filepath.Join(outDir, "json_requests")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logFn("[!] JSON injection error: " + err.Error())
+		return
+	}
+	wafArgs := WAFArgs("sqlmap", wafDetected)
+	found := 0
+	for i, c := range candidates {
+		findings, err := sqlmapJSON(c, dir, i+1, wafArgs)
+		if err != nil {
+			logFn(fmt.Sprintf("[!] sqlmap %s %s: %v", c.Template.URL, c.Path, err))
+		}
+		found += len(findings)
+		result.VulnURLs = append(result.VulnURLs, findings...)
+	}
+	logFn(fmt.Sprintf("[*] sqlmap found %d injectable JSON field(s).", found))
+}
@@ -0,0 +1,207 @@
+package scanners
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestBuildJSONCandidates(t *testing.T) {
+	tmpl := types.APIRequestTemplate{
+		Method: "POST",
+		URL:    "https://api.example.com/v1/orders/search",
+		Body: json.RawMessage(`{"filters":{"name":"shoe","price":1.50,"in stock":"yes"},` +
+			`"items":[{"sku":"A-1"},{"sku":"B-2","qty":3}],"tags":["red",null,true],"page":"2"}`),
+	}
+	candidates, err := BuildJSONCandidates(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type field struct{ path, original string }
+	var got []field
+	for _, c := range candidates {
+		got = append(got, field{c.Path, c.Original})
+	}
+	want := []field{
+		{`body.filters["in stock"]`, "yes"},
+		{"body.filters.name", "shoe"},
+		{"body.items[0].sku", "A-1"},
+		{"body.items[1].sku", "B-2"},
+		{"body.page", "2"},
+		{"body.tags[0]", "red"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("candidates %v, want %v", got, want)
+	}
+
+	// Each candidate changes its own field only; numbers keep their form.
+	body, err := candidates[3].Body("X'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(body, []byte(`{"qty":3,"sku":"X'"}`)) || !bytes.Contains(body, []byte(`{"sku":"A-1"}`)) || !bytes.Contains(body, []byte(`"price":1.50`)) {
+		t.Errorf("Body of %s: %s", candidates[3].Path, body)
+	}
+	body, _ = candidates[1].Body("X'")
+	if !bytes.Contains(body, []byte(`"name":"X'"`)) || !bytes.Contains(body, []byte(`{"qty":3,"sku":"B-2"}`)) {
+		t.Errorf("Body of %s: %s", candidates[1].Path, body)
+	}
+}
+
+func TestBuildJSONCandidatesBodies(t *testing.T) {
+	deep := `"bottom"`
+	for i := 0; i < jsonCandidateMaxDepth+2; i++ {
+		deep = `{"a":` + deep + `}`
+	}
+	var wide []string
+	for i := 0; i < jsonCandidateMaxFields+10; i++ {
+		wide = append(wide, fmt.Sprintf(`"f%03d":"v"`, i))
+	}
+	tests := []struct {
+		name  string
+		body  string
+		paths int
+		err   string
+	}{
+		{"no body", ``, 0, ""},
+		{"null body", `null`, 0, ""},
+		{"body captured as a string", `"{\"user\":\"alice\"}"`, 1, ""},
+		// A string body holds the JSON; plain text in it is not JSON.
+		{"string body without JSON", `"alice"`, 0, "body is not JSON"},
+		{"no string fields", `{"id":4,"ok":true}`, 0, ""},
+		{"too deep", deep, 0, ""},
+		{"too many fields", "{" + strings.Join(wide, ",") + "}", jsonCandidateMaxFields, ""},
+		{"form body", `user=alice&pass=x`, 0, "body is not JSON"},
+		{"two values", `{"a":"b"} {"c":"d"}`, 0, "data after the value"},
+	}
+	for _, tt := range tests {
+		tmpl := types.APIRequestTemplate{Method: "POST", URL: "https://api.example.com/login", Body: json.RawMessage(tt.body)}
+		got, err := BuildJSONCandidates(tmpl)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+		if len(got) != tt.paths {
+			t.Errorf("%s: %d candidate(s), want %d", tt.name, len(got), tt.paths)
+		}
+	}
+}
+
+func TestJSONRequestFile(t *testing.T) {
+	tmpl := types.APIRequestTemplate{
+		Method: "PUT",
+		URL:    "https://api.example.com:8443/v1/users/7?expand=roles",
+		Headers: map[string]string{
+			"Authorization":  "Bearer eyJhbGciOi",
+			"Accept":         "*/*",
+			"content-type":   "application/vnd.api+json",
+			"Host":           "evil.example.net",
+			"Content-Length": "999",
+		},
+	}
+	candidates, err := BuildJSONCandidates(types.APIRequestTemplate{Method: tmpl.Method, URL: tmpl.URL, Body: json.RawMessage(`{"role":"user"}`)})
+	if err != nil || len(candidates) != 1 {
+		t.Fatalf("candidates %v, %v", candidates, err)
+	}
+	body, _ := candidates[0].Body("user" + sqlmapMarker)
+	// As sqlmapJSON writes it: headers carrying the marker are dropped.
+	file, err := JSONRequestFile(tmpl, body, func(_, value string) bool { return !strings.Contains(value, sqlmapMarker) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(file), sqlmapMarker); n != 1 {
+		t.Errorf("%d markers in\n%s", n, file)
+	}
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(file)))
+	if err != nil {
+		t.Fatalf("request file does not parse: %v\n%s", err, file)
+	}
+	reqBody, _ := ioutil.ReadAll(req.Body)
+	if req.Method != "PUT" || req.RequestURI != "/v1/users/7?expand=roles" || req.Host != "api.example.com:8443" ||
+		req.Header.Get("Authorization") != "Bearer eyJhbGciOi" || req.Header.Get("Accept") != "" ||
+		req.Header.Get("Content-Type") != "application/vnd.api+json" || string(reqBody) != `{"role":"user*"}` {
+		t.Errorf("request file:\n%s", file)
+	}
+
+	// A Content-Type that is not JSON is replaced.
+	tmpl.Headers = map[string]string{"Content-Type": "text/plain"}
+	file, _ = JSONRequestFile(tmpl, body, nil)
+	if !bytes.Contains(file, []byte("Content-Type: application/json\r\n")) {
+		t.Errorf("request file:\n%s", file)
+	}
+	for _, bad := range []types.APIRequestTemplate{
+		{Method: "GET /admin HTTP/1.1\r\nX:", URL: tmpl.URL},
+		{Method: "POST", URL: tmpl.URL, Headers: map[string]string{"X-Forwarded-For": "1.2.3.4\r\nX-Admin: 1"}},
+		{Method: "POST", URL: tmpl.URL, Headers: map[string]string{"Bad Header": "x"}},
+		{Method: "POST", URL: "http://[::1"},
+	} {
+		if _, err := JSONRequestFile(bad, body, nil); err == nil {
+			t.Errorf("JSONRequestFile(%+v): no error", bad)
+		}
+	}
+}
+
+func TestCheckJSONReflection(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		nosniff     bool
+		echo        bool
+		severity    string
+	}{
+		{"html", "text/html; charset=utf-8", false, true, "medium"},
+		{"no content type", "", false, true, "low"},
+		{"json", "application/json", false, true, ""},
+		{"nosniff", "text/html", true, true, ""},
+		{"not reflected", "text/html", false, false, ""},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("%s: request Content-Type %q", tt.name, ct)
+			}
+			w.Header()["Content-Type"] = []string{tt.contentType}
+			if tt.nosniff {
+				w.Header().Set("X-Content-Type-Options", "nosniff")
+			}
+			if tt.echo {
+				w.Write(body)
+			} else {
+				fmt.Fprint(w, `{"ok":true}`)
+			}
+		}))
+		candidates, err := BuildJSONCandidates(types.APIRequestTemplate{
+			Method:  "POST",
+			URL:     srv.URL + "/api/search",
+			Headers: map[string]string{"Content-Type": "text/plain"},
+			Body:    json.RawMessage(`{"query":{"term":"shoe"}}`),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		findings, answered := CheckJSONReflection(srv.Client(), candidates[0])
+		srv.Close()
+		if !answered {
+			t.Errorf("%s: not answered", tt.name)
+		}
+		if tt.severity == "" {
+			if len(findings) != 0 {
+				t.Errorf("%s: findings %+v", tt.name, findings)
+			}
+			continue
+		}
+		if len(findings) != 1 || findings[0].Severity != tt.severity || findings[0].Parameter != "body.query.term (JSON POST)" ||
+			!strings.HasPrefix(findings[0].Detail, "JSON field body.query.term: ") {
+			t.Errorf("%s: findings %+v", tt.name, findings)
+		}
+	}
+}
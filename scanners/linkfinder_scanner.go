@@ -0,0 +1,202 @@
+// scanners/linkfinder_scanner.go - Endpoints referenced by collected JavaScript with LinkFinder.
+package scanners
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	defaultLinkFinderMaxFiles = 100
+	defaultLinkFinderWorkers  = 4
+	linkFinderTimeout         = 2 * time.Minute
+)
+
+// linkFinderSettings reads LINKFINDER_MAX_FILES, how many JavaScript files
+// are analyzed, and LINKFINDER_WORKERS, how many LinkFinder processes run
+// at once.
+func linkFinderSettings() (maxFiles, workers int) {
+	maxFiles, workers = defaultLinkFinderMaxFiles, defaultLinkFinderWorkers
+	if n, err := strconv.Atoi(os.Getenv("LINKFINDER_MAX_FILES")); err == nil && n > 0 {
+		maxFiles = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("LINKFINDER_WORKERS")); err == nil && n > 0 {
+		workers = n
+	}
+	return maxFiles, workers
+}
+
+// linkFinderBinary returns the installed name of LinkFinder; a checkout
+// runs as linkfinder.py.
+func linkFinderBinary() (string, bool) {
+	for _, name := range []string{"linkfinder", "linkfinder.py"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// jsFileURLs returns the in-scope JavaScript files the URL scan collected:
+// those in js_files.txt and the URLs whose path ends in .js.
+func jsFileURLs(outDir string, result *types.ScanResult, inScope func(string) bool) []string {
+	var files []string
+	if data, err := ioutil.ReadFile(filepath.Join(outDir, "js_files.txt")); err == nil {
+		files = strings.Fields(string(data))
+	}
+	for _, raw := range result.AllURLs {
+		if u, err := url.Parse(raw); err == nil && strings.EqualFold(path.Ext(u.Path), ".js") {
+			files = append(files, raw)
+		}
+	}
+	var kept []string
+	for _, f := range utils.UniqueStrings(files) {
+		if absoluteHTTP(f) && inScope(f) {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// absoluteHTTP reports whether raw is an absolute http or https URL.
+func absoluteHTTP(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// resolveJSEndpoint turns an endpoint found in the script at jsURL into an
+// absolute URL. Absolute URLs are kept as they are; anything else is
+// resolved against the script's origin, since scripts build their requests
+// from the page's origin rather than their own path.
+func resolveJSEndpoint(jsURL, endpoint string) (string, bool) {
+	js, err := url.Parse(jsURL)
+	if err != nil {
+		return "", false
+	}
+	ref, err := url.Parse(endpoint)
+	if err != nil {
+		return "", false
+	}
+	if ref.Scheme != "" {
+		return endpoint, absoluteHTTP(endpoint)
+	}
+	if ref.Host == "" && !strings.HasPrefix(ref.Path, "/") {
+		ref.Path = "/" + strings.TrimPrefix(ref.Path, "./")
+	}
+	origin := &url.URL{Scheme: js.Scheme, Host: js.Host, Path: "/"}
+	return origin.ResolveReference(ref).String(), true
+}
+
+// RunLinkFinder extracts the endpoints referenced by the JavaScript files
+// the URL scan collected, at most LINKFINDER_MAX_FILES of them and a few
+// at a time. In-scope endpoints not already known join result.AllURLs and
+// are recorded in result.URLRecords with the script they came from as
+// Referrer. Every endpoint found is written to linkfinder_endpoints.txt,
+// tab-separated from its script.
+func RunLinkFinder(outDir string, result *types.ScanResult, inScope func(string) bool, logFn func(string)) {
+	bin, ok := linkFinderBinary()
+	if !ok {
+		logFn("[!] LinkFinder not found in PATH; skipping JavaScript endpoint extraction")
+		return
+	}
+	files := jsFileURLs(outDir, result, inScope)
+	if len(files) == 0 {
+		logFn("[*] No JavaScript files to analyze with LinkFinder.")
+		return
+	}
+	maxFiles, workers := linkFinderSettings()
+	if len(files) > maxFiles {
+		logFn(fmt.Sprintf("[*] Analyzing %d of %d JavaScript file(s) (LINKFINDER_MAX_FILES)", maxFiles, len(files)))
+		files = files[:maxFiles]
+	}
+	logFn(fmt.Sprintf("[*] Running LinkFinder against %d JavaScript file(s), %d at a time...", len(files), workers))
+
+	jobs := make(chan string)
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		records []types.URLRecord
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for js := range jobs {
+				utils.Guard(func() {
+					out, runErr := utils.RunCommandTimeout(linkFinderTimeout, bin, "-i", js, "-o", "cli")
+					endpoints, err := parsers.ParseLinkFinderOutput(out)
+					if err == nil {
+						err = runErr
+					}
+					var found []types.URLRecord
+					for _, e := range endpoints {
+						if u, ok := resolveJSEndpoint(js, e); ok {
+							found = append(found, types.URLRecord{URL: u, Source: "linkfinder", Referrer: js})
+						}
+					}
+					mu.Lock()
+					if err != nil {
+						logFn("[!] LinkFinder " + js + ": " + err.Error())
+					}
+					records = append(records, found...)
+					mu.Unlock()
+				})
+			}
+		}()
+	}
+	for _, f := range files {
+		jobs <- f
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Workers finish in any order; keep the output stable.
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].URL != records[j].URL {
+			return records[i].URL < records[j].URL
+		}
+		return records[i].Referrer < records[j].Referrer
+	})
+	seen := make(map[string]bool, len(result.AllURLs))
+	for _, u := range result.AllURLs {
+		seen[urlKey(u)] = true
+	}
+	var lines []string
+	distinct := make(map[string]bool)
+	added, dropped := 0, 0
+	for _, r := range records {
+		lines = append(lines, r.URL+"\t"+r.Referrer)
+		distinct[r.URL] = true
+		if !inScope(r.URL) {
+			dropped++
+			continue
+		}
+		if key := urlKey(r.URL); !seen[key] {
+			seen[key] = true
+			result.AllURLs = append(result.AllURLs, r.URL)
+			result.URLRecords = append(result.URLRecords, r)
+			added++
+		}
+	}
+	if dropped > 0 {
+		logFn(fmt.Sprintf("[*] LinkFinder: dropped %d out-of-scope URL(s)", dropped))
+	}
+	if err := utils.WriteLines(utils.UniqueStrings(lines), filepath.Join(outDir, "linkfinder_endpoints.txt")); err != nil {
+		logFn("[!] LinkFinder error: " + err.Error())
+	}
+	logFn(fmt.Sprintf("[*] LinkFinder found %d endpoint(s), %d new.", len(distinct), added))
+}
@@ -0,0 +1,74 @@
+package scanners
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestResolveJSEndpoint(t *testing.T) {
+	const js = "https://www.example.com/static/js/app.js?v=3"
+	tests := []struct {
+		endpoint string
+		want     string
+		ok       bool
+	}{
+		// Relative endpoints resolve against the script's origin.
+		{"/api/v1/users", "https://www.example.com/api/v1/users", true},
+		{"api/v1/users", "https://www.example.com/api/v1/users", true},
+		{"./partials/header.html", "https://www.example.com/partials/header.html", true},
+		{"user/profile?id=", "https://www.example.com/user/profile?id=", true},
+		{"//cdn.example.com/lib.js", "https://cdn.example.com/lib.js", true},
+		// Absolute URLs are kept as they are.
+		{"https://api.example.com/v2/", "https://api.example.com/v2/", true},
+		{"http://legacy.example.com/x", "http://legacy.example.com/x", true},
+		{"mailto:security@example.com", "mailto:security@example.com", false},
+		{"javascript:void(0)", "javascript:void(0)", false},
+		{"%zz", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := resolveJSEndpoint(js, tt.endpoint)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("resolveJSEndpoint(%q) = %q, %v; want %q, %v", tt.endpoint, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestJSFileURLs(t *testing.T) {
+	outDir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(outDir, "js_files.txt"), []byte("https://www.example.com/a.js\nhttps://other.test/x.js\n/relative.js\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result := &types.ScanResult{AllURLs: []string{
+		"https://www.example.com/a.js",
+		"https://www.example.com/B.JS?v=1",
+		"https://www.example.com/page.html",
+	}}
+	inScope := func(u string) bool { return strings.Contains(u, "example.com") }
+	want := []string{"https://www.example.com/a.js", "https://www.example.com/B.JS?v=1"}
+	if got := jsFileURLs(outDir, result, inScope); !reflect.DeepEqual(got, want) {
+		t.Errorf("jsFileURLs = %q, want %q", got, want)
+	}
+}
+
+func TestLinkFinderSettings(t *testing.T) {
+	for _, tt := range []struct {
+		files, workers         string
+		wantFiles, wantWorkers int
+	}{
+		{"", "", defaultLinkFinderMaxFiles, defaultLinkFinderWorkers},
+		{"20", "2", 20, 2},
+		{"0", "-1", defaultLinkFinderMaxFiles, defaultLinkFinderWorkers},
+		{"many", "x", defaultLinkFinderMaxFiles, defaultLinkFinderWorkers},
+	} {
+		t.Setenv("LINKFINDER_MAX_FILES", tt.files)
+		t.Setenv("LINKFINDER_WORKERS", tt.workers)
+		if files, workers := linkFinderSettings(); files != tt.wantFiles || workers != tt.wantWorkers {
+			t.Errorf("%q, %q: %d files, %d workers; want %d, %d", tt.files, tt.workers, files, workers, tt.wantFiles, tt.wantWorkers)
+		}
+	}
+}
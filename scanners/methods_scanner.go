@@ -0,0 +1,107 @@
+// scanners/methods_scanner.go - Allowed HTTP methods per endpoint via OPTIONS.
+package scanners
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// apiPathRe recognizes API endpoints, where PUT and DELETE are expected.
+var apiPathRe = regexp.MustCompile(`(?i)(^|/)(api|rest|graphql|v[0-9]+)(/|$)`)
+
+// parseMethods collects the methods listed in Allow-style header values.
+func parseMethods(values ...string) []string {
+	seen := make(map[string]bool)
+	var methods []string
+	for _, v := range values {
+		for _, m := range strings.Split(v, ",") {
+			m = strings.ToUpper(strings.TrimSpace(m))
+			if m != "" && !seen[m] {
+				seen[m] = true
+				methods = append(methods, m)
+			}
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// ProbeMethods sends a single OPTIONS request without a body, shaped as a
+// CORS preflight from the endpoint's own origin, and returns the methods
+// the server advertises in its Allow and Access-Control-Allow-Methods
+// headers. No other method is ever sent.
+func ProbeMethods(client *http.Client, target string) ([]string, error) {
+	req, err := http.NewRequest(http.MethodOptions, target, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Origin", req.URL.Scheme+"://"+req.URL.Host)
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return parseMethods(append(resp.Header.Values("Allow"), resp.Header.Values("Access-Control-Allow-Methods")...)...), nil
+}
+
+// allowsMethod reports whether methods include m. A * from
+// Access-Control-Allow-Methods stands for every method but TRACE, which
+// browsers never send cross-origin.
+func allowsMethod(methods []string, m string) bool {
+	return containsString(methods, m) || (m != http.MethodTrace && containsString(methods, "*"))
+}
+
+// RunMethodChecks records the advertised methods of a bounded sample of
+// endpoints per host and files findings for TRACE, and for PUT or DELETE
+// outside API paths.
+func RunMethodChecks(client *http.Client, result *types.ScanResult, inScope func(string) bool, logFn func(string)) {
+	logFn("[*] Checking allowed HTTP methods...")
+	probe := noRedirectClient(client)
+	var mu sync.Mutex
+	sample, cov := sampleEndpoints("HTTP method checks", result, inScope, nil)
+	forEachEndpoint(sample, cov, func(u *url.URL) bool {
+		ep := u.Scheme + "://" + u.Host + u.EscapedPath()
+		methods, err := ProbeMethods(probe, ep)
+		if err != nil {
+			return false
+		}
+		if len(methods) == 0 {
+			return true
+		}
+		var findings []types.VulnerabilityResult
+		if allowsMethod(methods, http.MethodTrace) {
+			findings = append(findings, types.VulnerabilityResult{
+				URL:    ep,
+				Issue:  "HTTP TRACE Enabled",
+				Type:   "http-trace",
+				Detail: "OPTIONS advertises: " + strings.Join(methods, ", "),
+			})
+		}
+		if !apiPathRe.MatchString(u.Path) && (allowsMethod(methods, http.MethodPut) || allowsMethod(methods, http.MethodDelete)) {
+			findings = append(findings, types.VulnerabilityResult{
+				URL:    ep,
+				Issue:  "Risky HTTP Methods Enabled",
+				Type:   "risky-http-methods",
+				Detail: "PUT/DELETE advertised on a non-API path: " + strings.Join(methods, ", "),
+			})
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		result.Methods = append(result.Methods, types.EndpointMethods{URL: ep, Allowed: methods})
+		result.VulnURLs = append(result.VulnURLs, findings...)
+		for _, f := range findings {
+			logFn(fmt.Sprintf("[!] %s: %s", f.Issue, f.URL))
+		}
+		return true
+	})
+	result.Coverage = append(result.Coverage, *cov)
+	logFn(fmt.Sprintf("[*] HTTP method checks complete, %d endpoint(s) advertise methods.", len(result.Methods)))
+}
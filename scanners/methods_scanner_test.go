@@ -0,0 +1,78 @@
+package scanners
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestRunMethodChecks(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		status  int
+		allowed []string
+		issues  []string
+	}{
+		{"no OPTIONS support", "/page", nil, http.StatusMethodNotAllowed, nil, nil},
+		{"Allow", "/upload", map[string]string{"Allow": "GET, HEAD, PUT, TRACE"}, http.StatusNoContent,
+			[]string{"GET", "HEAD", "PUT", "TRACE"}, []string{"HTTP TRACE Enabled", "Risky HTTP Methods Enabled"}},
+		{"preflight only", "/files", map[string]string{"Access-Control-Allow-Methods": "GET, DELETE"}, http.StatusNoContent,
+			[]string{"DELETE", "GET"}, []string{"Risky HTTP Methods Enabled"}},
+		{"both headers", "/doc", map[string]string{"Allow": "GET, OPTIONS", "Access-Control-Allow-Methods": "POST"}, http.StatusOK,
+			[]string{"GET", "OPTIONS", "POST"}, nil},
+		{"preflight wildcard", "/assets", map[string]string{"Access-Control-Allow-Methods": "*"}, http.StatusNoContent,
+			[]string{"*"}, []string{"Risky HTTP Methods Enabled"}},
+		{"API path", "/api/v1/items", map[string]string{"Allow": "GET, PUT, DELETE"}, http.StatusNoContent,
+			[]string{"DELETE", "GET", "PUT"}, nil},
+	}
+	for _, tt := range tests {
+		var (
+			mu       sync.Mutex
+			requests []string
+		)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			mu.Lock()
+			requests = append(requests, r.Method)
+			mu.Unlock()
+			if len(body) > 0 {
+				t.Errorf("%s: request sent a %d-byte body", tt.name, len(body))
+			}
+			if r.Method == http.MethodOptions && r.Header.Get("Origin") == "" {
+				t.Errorf("%s: OPTIONS without Origin", tt.name)
+			}
+			for k, v := range tt.headers {
+				w.Header().Set(k, v)
+			}
+			w.WriteHeader(tt.status)
+		}))
+		result := &types.ScanResult{AllURLs: []string{srv.URL + tt.path + "?q=1"}}
+		RunMethodChecks(srv.Client(), result, func(string) bool { return true }, func(string) {})
+		srv.Close()
+
+		if !reflect.DeepEqual(requests, []string{http.MethodOptions}) {
+			t.Errorf("%s: requests %v, want one OPTIONS", tt.name, requests)
+		}
+		var allowed []string
+		if len(result.Methods) == 1 {
+			allowed = result.Methods[0].Allowed
+		}
+		if !reflect.DeepEqual(allowed, tt.allowed) {
+			t.Errorf("%s: allowed %v, want %v", tt.name, allowed, tt.allowed)
+		}
+		var issues []string
+		for _, v := range result.VulnURLs {
+			issues = append(issues, v.Issue)
+		}
+		if !reflect.DeepEqual(issues, tt.issues) {
+			t.Errorf("%s: findings %v, want %v", tt.name, issues, tt.issues)
+		}
+	}
+}
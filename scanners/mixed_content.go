@@ -0,0 +1,194 @@
+// scanners/mixed_content.go - Mixed content and insecure form posts on HTTPS pages.
+package scanners
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// maxElementEvidence caps the element quoted as a finding's evidence.
+const maxElementEvidence = 300
+
+// pageResource is an element of a page that loads or submits to a URL.
+// Element is the tag as written, URL the address it resolves to against
+// the page and its base href. Active resources (scripts, stylesheets) can
+// act on the page; Password marks a form with a password field.
+type pageResource struct {
+	Tag      string
+	URL      string
+	Element  string
+	Active   bool
+	Password bool
+}
+
+// linkRels are the link relations that make the browser load the target,
+// and whether the resource is active. Other links, such as canonical or
+// alternate, only point elsewhere.
+var linkRels = map[string]bool{
+	"stylesheet":       true,
+	"preload":          true,
+	"modulepreload":    true,
+	"icon":             false,
+	"shortcut":         false,
+	"apple-touch-icon": false,
+}
+
+// pageResources returns the script, img, link and form elements of the
+// HTML body of page. URLs resolve against the first base href, wherever
+// in the document it is, and protocol-relative URLs take the scheme of
+// the page or base; a form without an action submits to the page itself.
+func pageResources(page string, body []byte) []pageResource {
+	pageURL, err := url.Parse(page)
+	if err != nil {
+		return nil
+	}
+	type ref struct {
+		res  pageResource
+		raw  string
+		self bool // an empty form action: the page's own URL
+	}
+	var refs []ref
+	base := pageURL
+	baseSet := false
+	form := -1
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		if tt == html.EndTagToken {
+			if name, _ := z.TagName(); string(name) == "form" {
+				form = -1
+			}
+			continue
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		t := z.Token()
+		attrs := make(map[string]string)
+		for _, a := range t.Attr {
+			if _, ok := attrs[a.Key]; !ok {
+				attrs[a.Key] = strings.TrimSpace(a.Val)
+			}
+		}
+		element := t.String()
+		if len(element) > maxElementEvidence {
+			element = element[:maxElementEvidence] + "..."
+		}
+		res := pageResource{Tag: t.Data, Element: element}
+		switch t.Data {
+		case "base":
+			if href, ok := attrs["href"]; ok && !baseSet {
+				if u, err := pageURL.Parse(href); err == nil {
+					base, baseSet = u, true
+				}
+			}
+		case "script":
+			if src := attrs["src"]; src != "" {
+				res.Active = true
+				refs = append(refs, ref{res: res, raw: src})
+			}
+		case "img":
+			if src := attrs["src"]; src != "" {
+				refs = append(refs, ref{res: res, raw: src})
+			}
+			for _, candidate := range strings.Split(attrs["srcset"], ",") {
+				if fields := strings.Fields(candidate); len(fields) > 0 {
+					refs = append(refs, ref{res: res, raw: fields[0]})
+				}
+			}
+		case "link":
+			href := attrs["href"]
+			if href == "" {
+				continue
+			}
+			for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+				if active, ok := linkRels[rel]; ok {
+					res.Active = active
+					refs = append(refs, ref{res: res, raw: href})
+					break
+				}
+			}
+		case "form":
+			action, ok := attrs["action"]
+			refs = append(refs, ref{res: res, raw: action, self: !ok || action == ""})
+			form = len(refs) - 1
+		case "input":
+			if form >= 0 && strings.EqualFold(attrs["type"], "password") {
+				refs[form].res.Password = true
+			}
+		}
+	}
+	var out []pageResource
+	for _, r := range refs {
+		target := pageURL
+		if !r.self {
+			if target, err = base.Parse(r.raw); err != nil {
+				continue
+			}
+		}
+		r.res.URL = target.String()
+		out = append(out, r.res)
+	}
+	return out
+}
+
+// MixedContentFindings returns the findings for the elements of an HTTPS
+// page that load resources or submit forms over plain HTTP: scripts and
+// stylesheets are medium, images and icons low, and forms medium when
+// they take a password, low otherwise. Other pages have none.
+func MixedContentFindings(page string, body []byte) []types.VulnerabilityResult {
+	if u, err := url.Parse(page); err != nil || u.Scheme != "https" {
+		return nil
+	}
+	var vulns []types.VulnerabilityResult
+	for _, r := range pageResources(page, body) {
+		if u, err := url.Parse(r.URL); err != nil || u.Scheme != "http" {
+			continue
+		}
+		v := types.VulnerabilityResult{URL: page, Confidence: utils.ConfidenceConfirmed}
+		switch {
+		case r.Tag == "form":
+			v.Type, v.Issue, v.Severity = "insecure-form-action", "Insecure Form Action", "low"
+			v.Detail = fmt.Sprintf("%s submits to %s over plain HTTP", r.Element, r.URL)
+			if r.Password {
+				v.Severity = "medium"
+				v.Detail += "; the form takes a password"
+			}
+		case r.Active:
+			v.Type, v.Issue, v.Severity = "mixed-content", "Mixed Content (active)", "medium"
+			v.Detail = fmt.Sprintf("%s loads %s over plain HTTP", r.Element, r.URL)
+		default:
+			v.Type, v.Issue, v.Severity = "mixed-content", "Mixed Content (passive)", "low"
+			v.Detail = fmt.Sprintf("%s loads %s over plain HTTP", r.Element, r.URL)
+		}
+		vulns = append(vulns, v)
+	}
+	return vulns
+}
+
+// MixedContentCounts counts the mixed content and insecure form findings
+// per host, for the report.
+func MixedContentCounts(vulns []types.VulnerabilityResult) map[string]map[string]int {
+	counts := make(map[string]map[string]int)
+	for _, v := range vulns {
+		if v.Type != "mixed-content" && v.Type != "insecure-form-action" {
+			continue
+		}
+		host := utils.NormalizeHostname(v.URL)
+		if counts[host] == nil {
+			counts[host] = make(map[string]int)
+		}
+		counts[host][v.Type]++
+	}
+	return counts
+}
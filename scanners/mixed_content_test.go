@@ -0,0 +1,126 @@
+package scanners
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func readMixedFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "mixed", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestMixedContentFindings(t *testing.T) {
+	const page = "https://example.com/shop/index.html"
+	type want struct{ issue, severity, url string }
+	tests := []struct {
+		fixture string
+		want    []want
+	}{
+		// Protocol-relative and relative scripts take the page's scheme;
+		// markup inside an inline script is not an element.
+		{"script.html", []want{
+			{"Mixed Content (active)", "medium", "http://cdn.example.net/jquery.min.js"},
+		}},
+		// srcset candidates count; <noscript> is text with scripting on,
+		// as browsers load it.
+		{"img.html", []want{
+			{"Mixed Content (passive)", "low", "http://images.example.net/logo.png"},
+			{"Mixed Content (passive)", "low", "http://images.example.net/banner-2x.png"},
+		}},
+		// Only links the browser loads; canonical and alternate do not.
+		{"link.html", []want{
+			{"Mixed Content (active)", "medium", "http://fonts.example.net/css?family=Sans"},
+			{"Mixed Content (passive)", "low", "http://static.example.net/favicon.ico"},
+		}},
+		// A form without an action posts to the page itself.
+		{"form.html", []want{
+			{"Insecure Form Action", "medium", "http://login.example.com/session"},
+			{"Insecure Form Action", "low", "http://newsletter.example.com/subscribe"},
+		}},
+		// The first base href applies to the whole document, elements before
+		// it included, and gives protocol-relative URLs its scheme; an empty
+		// form action still means the page's own URL.
+		{"base.html", []want{
+			{"Mixed Content (active)", "medium", "http://legacy.example.com/app/js/early.js"},
+			{"Mixed Content (active)", "medium", "http://legacy.example.com/app/js/app.js"},
+			{"Mixed Content (active)", "medium", "http://cdn.example.net/lib.js"},
+			{"Mixed Content (active)", "medium", "http://legacy.example.com/css/site.css"},
+			{"Insecure Form Action", "medium", "http://legacy.example.com/app/login"},
+		}},
+	}
+	for _, tt := range tests {
+		vulns := MixedContentFindings(page, readMixedFixture(t, tt.fixture))
+		if len(vulns) != len(tt.want) {
+			t.Errorf("%s: %d finding(s), want %d: %+v", tt.fixture, len(vulns), len(tt.want), vulns)
+			continue
+		}
+		for i, w := range tt.want {
+			v := vulns[i]
+			if v.Issue != w.issue || v.Severity != w.severity || v.URL != page || !strings.Contains(v.Detail, " "+w.url+" over plain HTTP") {
+				t.Errorf("%s: finding %d = %+v, want %+v", tt.fixture, i, v, w)
+			}
+			// The element itself is the evidence.
+			if !strings.HasPrefix(v.Detail, "<") {
+				t.Errorf("%s: detail %q lacks the element", tt.fixture, v.Detail)
+			}
+		}
+	}
+
+	// Pages served over HTTP have no mixed content to speak of.
+	if vulns := MixedContentFindings("http://example.com/", readMixedFixture(t, "script.html")); vulns != nil {
+		t.Errorf("HTTP page: %+v", vulns)
+	}
+	// Long elements are cut short in the evidence.
+	long := fmt.Sprintf(`<script src="http://cdn.example.net/a.js" data-x="%s"></script>`, strings.Repeat("x", 2*maxElementEvidence))
+	if vulns := MixedContentFindings("https://example.com/", []byte(long)); len(vulns) != 1 || len(vulns[0].Detail) > maxElementEvidence+100 {
+		t.Errorf("long element: %+v", vulns)
+	}
+}
+
+func TestMixedContentCounts(t *testing.T) {
+	vulns := []types.VulnerabilityResult{
+		{Type: "mixed-content", URL: "https://a.example.com/"},
+		{Type: "mixed-content", URL: "https://a.example.com/x"},
+		{Type: "insecure-form-action", URL: "https://a.example.com/login"},
+		{Type: "insecure-form-action", URL: "https://b.example.com:8443/"},
+		{Type: "xss", URL: "https://b.example.com/"},
+	}
+	counts := MixedContentCounts(vulns)
+	if len(counts) != 2 || counts["a.example.com"]["mixed-content"] != 2 || counts["a.example.com"]["insecure-form-action"] != 1 || counts["b.example.com"]["insecure-form-action"] != 1 {
+		t.Errorf("counts %v", counts)
+	}
+}
+
+func TestRunAuthStateChecksMixedContent(t *testing.T) {
+	form := readMixedFixture(t, "form.html")
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(form)
+	}))
+	defer srv.Close()
+	result := &types.ScanResult{
+		LiveHosts: []types.LiveHost{{Hostname: "127.0.0.1", URL: srv.URL}},
+		AllURLs:   []string{srv.URL + "/account", srv.URL + "/help"},
+	}
+	var logs []string
+	RunAuthStateChecks(srv.Client(), result, func(string) bool { return true }, nil, func(s string) { logs = append(logs, s) })
+	// The same forms on every page of the host are reported once.
+	if len(result.VulnURLs) != 2 {
+		t.Fatalf("%d finding(s): %+v", len(result.VulnURLs), result.VulnURLs)
+	}
+	if !strings.Contains(strings.Join(logs, "\n"), "2 insecure element(s) on HTTPS pages") {
+		t.Errorf("logs:\n%s", strings.Join(logs, "\n"))
+	}
+}
@@ -0,0 +1,157 @@
+// scanners/native_port_scanner.go - TCP connect port scan in Go, for hosts without nmap, masscan or naabu.
+package scanners
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	defaultNativePortTimeout     = 2 * time.Second
+	defaultNativePortWorkers     = 200
+	defaultNativePortHostWorkers = 20
+)
+
+// defaultNativePorts are nmap's 100 most common TCP ports.
+var defaultNativePorts = []int{
+	7, 9, 13, 21, 22, 23, 25, 26, 37, 53, 79, 80, 81, 88, 106, 110, 111, 113, 119, 135,
+	139, 143, 144, 179, 199, 389, 427, 443, 444, 445, 465, 513, 514, 515, 543, 544, 548, 554, 587, 631,
+	646, 873, 990, 993, 995, 1025, 1026, 1027, 1028, 1029, 1110, 1433, 1720, 1723, 1755, 1900, 2000, 2001, 2049, 2121,
+	2717, 3000, 3128, 3306, 3389, 3986, 4899, 5000, 5009, 5051, 5060, 5101, 5190, 5357, 5432, 5631, 5666, 5800, 5900, 6000,
+	6001, 6646, 7070, 8000, 8008, 8009, 8080, 8081, 8443, 8888, 9100, 9999, 10000, 32768, 49152, 49153, 49154, 49155, 49156, 49157,
+}
+
+// PortScanEnabled reports whether the port scanning stage may run; set
+// PORT_SCAN=false to keep a passive engagement from connecting to any
+// port.
+func PortScanEnabled() bool {
+	return !strings.EqualFold(strings.TrimSpace(os.Getenv("PORT_SCAN")), "false")
+}
+
+// nativePortSettings reads NATIVE_PORTS, the ports to check (comma-
+// separated, ranges such as 8000-8100 allowed), NATIVE_PORT_TIMEOUT, the
+// bound on each connection attempt (a Go duration such as "2s"), and
+// NATIVE_PORT_WORKERS and NATIVE_PORT_HOST_WORKERS, how many connections
+// are attempted at once overall and per host.
+func nativePortSettings() (ports []int, timeout time.Duration, workers, hostWorkers int) {
+	ports, timeout = defaultNativePorts, defaultNativePortTimeout
+	workers, hostWorkers = defaultNativePortWorkers, defaultNativePortHostWorkers
+	if list := parsePortList(os.Getenv("NATIVE_PORTS")); len(list) > 0 {
+		ports = list
+	}
+	if d, err := time.ParseDuration(os.Getenv("NATIVE_PORT_TIMEOUT")); err == nil && d > 0 {
+		timeout = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("NATIVE_PORT_WORKERS")); err == nil && n > 0 {
+		workers = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("NATIVE_PORT_HOST_WORKERS")); err == nil && n > 0 {
+		hostWorkers = n
+	}
+	return ports, timeout, workers, hostWorkers
+}
+
+// parsePortList reads a comma-separated list of ports and port ranges into
+// sorted, deduplicated ports. Entries that are not valid ports are skipped.
+func parsePortList(raw string) []int {
+	seen := make(map[int]bool)
+	var ports []int
+	for _, f := range strings.Split(raw, ",") {
+		lo, hi := strings.TrimSpace(f), ""
+		if i := strings.Index(lo, "-"); i >= 0 {
+			lo, hi = strings.TrimSpace(lo[:i]), strings.TrimSpace(lo[i+1:])
+		}
+		first, err := strconv.Atoi(lo)
+		if err != nil {
+			continue
+		}
+		last := first
+		if hi != "" {
+			if last, err = strconv.Atoi(hi); err != nil {
+				continue
+			}
+		}
+		if first < 1 || last > 65535 || first > last {
+			continue
+		}
+		for p := first; p <= last; p++ {
+			if !seen[p] {
+				seen[p] = true
+				ports = append(ports, p)
+			}
+		}
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+// runNativeScan checks the NATIVE_PORTS of every IP with a TCP connect
+// through DialContext. Jobs are handed out host by host in turn, so the
+// per-host cap rarely holds a worker back while other hosts wait.
+func runNativeScan(ips []string, logFn func(string)) []types.ScannedHost {
+	ports, timeout, workers, hostWorkers := nativePortSettings()
+	logFn(fmt.Sprintf("[*] Native TCP connect scan: %d port(s) on %d address(es), %d connection(s) at once (%d per host)",
+		len(ports), len(ips), workers, hostWorkers))
+	type job struct {
+		ip   string
+		port int
+	}
+	hostSlots := make(map[string]chan struct{}, len(ips))
+	open := make(map[string][]types.PortService, len(ips))
+	for _, ip := range ips {
+		hostSlots[ip] = make(chan struct{}, hostWorkers)
+	}
+	jobs := make(chan job)
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				utils.Guard(func() {
+					slots := hostSlots[j.ip]
+					slots <- struct{}{}
+					defer func() { <-slots }()
+					ctx, cancel := context.WithTimeout(context.Background(), timeout)
+					defer cancel()
+					conn, err := DialContext(ctx, "tcp", net.JoinHostPort(j.ip, strconv.Itoa(j.port)))
+					if err != nil {
+						return
+					}
+					conn.Close()
+					mu.Lock()
+					open[j.ip] = append(open[j.ip], types.PortService{Port: j.port, Protocol: "tcp", State: "open"})
+					mu.Unlock()
+				})
+			}
+		}()
+	}
+	for _, port := range ports {
+		for _, ip := range ips {
+			jobs <- job{ip, port}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	hosts := make([]types.ScannedHost, 0, len(ips))
+	for _, ip := range ips {
+		found := open[ip]
+		sort.Slice(found, func(a, b int) bool { return found[a].Port < found[b].Port })
+		hosts = append(hosts, types.ScannedHost{Address: ip, Ports: append([]types.PortService{}, found...)})
+	}
+	return hosts
+}
@@ -0,0 +1,114 @@
+// scanners/nikto_scanner.go - Optional nikto scans of the hosts that answered HTTP.
+package scanners
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	defaultNiktoWorkers = 3
+	defaultNiktoTimeout = 10 * time.Minute
+)
+
+// niktoSettings reads NIKTO_WORKERS and NIKTO_TIMEOUT (a per-host Go
+// duration such as "10m").
+func niktoSettings() (workers int, timeout time.Duration) {
+	workers, timeout = defaultNiktoWorkers, defaultNiktoTimeout
+	if n, err := strconv.Atoi(os.Getenv("NIKTO_WORKERS")); err == nil && n > 0 {
+		workers = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("NIKTO_TIMEOUT")); err == nil && d > 0 {
+		timeout = d
+	}
+	return workers, timeout
+}
+
+// RunNikto scans every host that answered HTTP with nikto, a few hosts at a
+// time, and merges the findings into result. It only runs when NIKTO=true.
+func RunNikto(outDir string, result *types.ScanResult, logFn func(string)) {
+	if !strings.EqualFold(os.Getenv("NIKTO"), "true") {
+		logFn("[*] nikto disabled; set NIKTO=true to enable it")
+		return
+	}
+	if _, err := exec.LookPath("nikto"); err != nil {
+		logFn("[!] nikto not found in PATH; skipping nikto scanning")
+		return
+	}
+	targets, err := WebTargets(outDir, result)
+	if err != nil {
+		logFn("[!] nikto skipped: " + err.Error())
+		return
+	}
+	workers, timeout := niktoSettings()
+	logFn(fmt.Sprintf("[*] Running nikto against %d host(s), %d at a time, %s each...", len(targets), workers, timeout))
+
+	jobs := make(chan string)
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		findings []types.VulnerabilityResult
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for base := range jobs {
+				utils.Guard(func() {
+					vulns, err := niktoHost(outDir, base, timeout)
+					mu.Lock()
+					if err != nil {
+						logFn("[!] nikto " + base + ": " + err.Error())
+					}
+					findings = append(findings, vulns...)
+					mu.Unlock()
+				})
+			}
+		}()
+	}
+	for _, t := range targets {
+		jobs <- t
+	}
+	close(jobs)
+	wg.Wait()
+
+	result.VulnURLs = append(result.VulnURLs, findings...)
+	logFn(fmt.Sprintf("[*] nikto scanning complete, %d item(s).", len(findings)))
+}
+
+// niktoHost runs nikto against one base URL, bounded by its -maxtime, and
+// parses the JSON report it writes. A scan cut short still yields the items
+// nikto reported before stopping.
+func niktoHost(outDir, base string, timeout time.Duration) ([]types.VulnerabilityResult, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	report := filepath.Join(outDir, "nikto_"+strings.NewReplacer(":", "_", "/", "_").Replace(u.Host)+".json")
+	_, runErr := utils.RunCommand("nikto", "-h", base, "-Format", "json", "-o", report,
+		"-maxtime", strconv.Itoa(int(timeout.Seconds()))+"s", "-ask", "no", "-nointeractive")
+	data, err := ioutil.ReadFile(report)
+	if err != nil {
+		if runErr != nil {
+			return nil, runErr
+		}
+		return nil, err
+	}
+	vulns, err := parsers.ParseNiktoOutput(data, base)
+	if err == nil {
+		err = runErr
+	}
+	return vulns, err
+}
@@ -0,0 +1,68 @@
+// scanners/nuclei_scanner.go - Template-based scanning of live hosts with nuclei.
+package scanners
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// nucleiSeverities returns the lower-cased severities listed in
+// NUCLEI_SEVERITY (e.g. "medium,high,critical"); empty means all.
+func nucleiSeverities() []string {
+	var sevs []string
+	for _, s := range strings.Split(os.Getenv("NUCLEI_SEVERITY"), ",") {
+		if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+			sevs = append(sevs, s)
+		}
+	}
+	return sevs
+}
+
+// RunNuclei runs nuclei against live_hosts.txt and merges its findings into
+// result. The stage is skipped with a warning when nuclei is not installed.
+func RunNuclei(outDir string, result *types.ScanResult, logFn func(string)) {
+	if _, err := exec.LookPath("nuclei"); err != nil {
+		logFn("[!] nuclei not found in PATH; skipping nuclei scanning")
+		return
+	}
+	liveFile := filepath.Join(outDir, "live_hosts.txt")
+	if _, err := os.Stat(liveFile); err != nil {
+		logFn("[!] nuclei skipped: " + err.Error())
+		return
+	}
+	logFn("[*] Running nuclei against live hosts...")
+	args := []string{"-l", liveFile, "-jsonl", "-silent", "-o", filepath.Join(outDir, "nuclei.jsonl")}
+	sevs := nucleiSeverities()
+	if len(sevs) > 0 {
+		args = append(args, "-severity", strings.Join(sevs, ","))
+	}
+	// nuclei reads the denied hosts from the materialized scope itself.
+	if scope, err := utils.ScopeFromFiles(outDir); err == nil && len(scope.Deny) > 0 {
+		args = append(args, "-exclude-hosts", filepath.Join(outDir, "scope_deny.txt"))
+	}
+	out, err := utils.RunCommand("nuclei", args...)
+	if err != nil {
+		logFn("[!] nuclei error: " + err.Error())
+		return
+	}
+	findings, err := parsers.ParseNucleiOutput(out)
+	if err != nil {
+		logFn("[!] " + err.Error())
+	}
+	kept := 0
+	for _, f := range findings {
+		if len(sevs) > 0 && !containsString(sevs, strings.ToLower(f.Severity)) {
+			continue
+		}
+		result.VulnURLs = append(result.VulnURLs, f)
+		kept++
+	}
+	logFn(fmt.Sprintf("[*] nuclei scanning complete, %d finding(s).", kept))
+}
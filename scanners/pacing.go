@@ -0,0 +1,79 @@
+// scanners/pacing.go - Fuzzing pace and budget for slow origins.
+package scanners
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// defaultSlowTTFB is the 95th percentile time to first byte, in
+// milliseconds, from which an origin counts as slow.
+const defaultSlowTTFB = 1000
+
+// slowTTFB reads SLOW_TTFB_MS.
+func slowTTFB() float64 {
+	if n, err := strconv.Atoi(os.Getenv("SLOW_TTFB_MS")); err == nil && n > 0 {
+		return float64(n)
+	}
+	return defaultSlowTTFB
+}
+
+// HostTimingFor returns the request timings recorded for the host of base,
+// if any.
+func HostTimingFor(result *types.ScanResult, base string) *types.HostTiming {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil
+	}
+	for i := range result.HostTimings {
+		if result.HostTimings[i].Host == u.Hostname() {
+			return &result.HostTimings[i]
+		}
+	}
+	return nil
+}
+
+// slowServer reports whether timing shows a server slow to answer. Only the
+// server's wait counts: a slow resolver or a long network path do not make
+// more concurrent requests any harder on the server.
+func slowServer(timing *types.HostTiming) bool {
+	return timing != nil && timing.TTFB.Count > 0 && timing.TTFB.P95MS >= slowTTFB()
+}
+
+// SlowServerArgs returns the extra arguments that lower tool's concurrency
+// against a slow server, or nil when timing shows none.
+func SlowServerArgs(tool string, timing *types.HostTiming) []string {
+	if !slowServer(timing) {
+		return nil
+	}
+	switch tool {
+	case "ffuf":
+		return []string{"-t", "10"}
+	case "gobuster", "dirsearch":
+		return []string{"-t", "5"}
+	}
+	return nil
+}
+
+// fuzzBudget scales a wordlist size cap down for a slow server, in the
+// ratio of the slow threshold to its time to first byte, so a pass takes
+// about as long as against a fast one. It keeps at least a tenth of max.
+func fuzzBudget(max int, timing *types.HostTiming) int {
+	if !slowServer(timing) {
+		return max
+	}
+	budget := int(float64(max) * slowTTFB() / timing.TTFB.P95MS)
+	if floor := max / 10; budget < floor {
+		budget = floor
+	}
+	return budget
+}
+
+// slowServerNote formats the log line for fuzzing base, a slow server.
+func slowServerNote(base string, timing *types.HostTiming) string {
+	return fmt.Sprintf("[*] %s is slow to answer (time to first byte p95 %.0fms): fuzzing it with fewer concurrent requests", base, timing.TTFB.P95MS)
+}
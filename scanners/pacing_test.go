@@ -0,0 +1,53 @@
+package scanners
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestSlowServerPacing(t *testing.T) {
+	result := &types.ScanResult{HostTimings: []types.HostTiming{
+		{Host: "fast.example.com", TTFB: types.PhaseTiming{Count: 20, MedianMS: 40, P95MS: 90}},
+		// Slow to resolve and connect, quick to answer: not a slow server.
+		{Host: "far.example.com", DNS: types.PhaseTiming{Count: 1, MedianMS: 2500, P95MS: 2500},
+			Connect: types.PhaseTiming{Count: 1, MedianMS: 1800, P95MS: 1800}, TTFB: types.PhaseTiming{Count: 20, MedianMS: 50, P95MS: 120}},
+		{Host: "slow.example.com", TTFB: types.PhaseTiming{Count: 20, MedianMS: 900, P95MS: 4000}},
+		{Host: "unanswered.example.com", Connect: types.PhaseTiming{Count: 3, MedianMS: 10, P95MS: 12}},
+	}}
+	tests := []struct {
+		base   string
+		env    string
+		ffuf   []string
+		dirs   []string
+		budget int
+	}{
+		{"https://fast.example.com", "", nil, nil, 5000},
+		{"https://far.example.com", "", nil, nil, 5000},
+		{"https://slow.example.com:8443", "", []string{"-t", "10"}, []string{"-t", "5"}, 1250},
+		{"https://slow.example.com", "8000", nil, nil, 5000},
+		{"https://slow.example.com", "100", []string{"-t", "10"}, []string{"-t", "5"}, 500},
+		{"https://unanswered.example.com", "", nil, nil, 5000},
+		{"https://unknown.example.com", "", nil, nil, 5000},
+	}
+	for _, tt := range tests {
+		t.Setenv("SLOW_TTFB_MS", tt.env)
+		timing := HostTimingFor(result, tt.base)
+		if got := SlowServerArgs("ffuf", timing); !reflect.DeepEqual(got, tt.ffuf) {
+			t.Errorf("%s (SLOW_TTFB_MS=%q): ffuf args %v, want %v", tt.base, tt.env, got, tt.ffuf)
+		}
+		if got := SlowServerArgs("dirsearch", timing); !reflect.DeepEqual(got, tt.dirs) {
+			t.Errorf("%s (SLOW_TTFB_MS=%q): dirsearch args %v, want %v", tt.base, tt.env, got, tt.dirs)
+		}
+		if got := fuzzBudget(5000, timing); got != tt.budget {
+			t.Errorf("%s (SLOW_TTFB_MS=%q): budget %d, want %d", tt.base, tt.env, got, tt.budget)
+		}
+	}
+	if HostTimingFor(result, "https://unknown.example.com") != nil || HostTimingFor(result, "http://[::1") != nil {
+		t.Error("HostTimingFor found timings of a host without any")
+	}
+	if timing := HostTimingFor(result, "http://slow.example.com/app/"); timing == nil || timing.TTFB.P95MS != 4000 {
+		t.Errorf("HostTimingFor = %+v", timing)
+	}
+}
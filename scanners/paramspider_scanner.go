@@ -0,0 +1,92 @@
+// scanners/paramspider_scanner.go - parameterized URLs from web archives with ParamSpider.
+package scanners
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// paramSpiderResults returns the results file ParamSpider wrote for domain
+// since start. It has no option to choose the path: current releases write
+// results/<domain>.txt and the 0.x script output/<domain>.txt, relative to
+// the directory it runs in.
+func paramSpiderResults(domain string, start time.Time) (string, bool) {
+	dir := utils.ToolWorkDir("paramspider")
+	for _, sub := range []string{"results", "output"} {
+		path := filepath.Join(dir, sub, domain+".txt")
+		if fi, err := os.Stat(path); err == nil && !fi.ModTime().Before(start) {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// RunParamSpider mines the web archives for URLs of target that take
+// parameters. The in-scope ones, with ParamSpider's placeholder values
+// replaced, join result.AllURLs and result.ParameterizedURLs, which the
+// vulnerability stage tests first, and are written to
+// parameterized_urls.txt.
+func RunParamSpider(target, outDir string, result *types.ScanResult, inScope func(string) bool, logFn func(string)) {
+	if _, err := exec.LookPath("paramspider"); err != nil {
+		logFn("[!] paramspider not found in PATH; skipping archived parameter discovery")
+		return
+	}
+	domain := utils.NormalizeHostname(target)
+	// File times are kept to the second on some filesystems.
+	start := time.Now().Truncate(time.Second)
+	_, runErr := utils.RunCommand("paramspider", "-d", domain)
+	path, ok := paramSpiderResults(domain, start)
+	if !ok {
+		if runErr != nil {
+			logFn("[!] paramspider error: " + runErr.Error())
+		} else {
+			logFn("[!] paramspider exited cleanly but wrote no results file for " + domain + "; no archived parameterized URLs to test")
+		}
+		return
+	}
+	if runErr != nil {
+		logFn("[!] paramspider error: " + runErr.Error())
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		logFn("[!] paramspider error: " + err.Error())
+		return
+	}
+	urls, err := parsers.ParseParamSpiderOutput(string(data))
+	if err != nil {
+		logFn("[!] " + err.Error())
+	}
+	known := make(map[string]bool, len(result.AllURLs))
+	for _, u := range result.AllURLs {
+		known[u] = true
+	}
+	var kept []string
+	dropped := 0
+	for _, u := range urls {
+		if !inScope(u) {
+			dropped++
+			continue
+		}
+		kept = append(kept, u)
+		if !known[u] {
+			known[u] = true
+			result.AllURLs = append(result.AllURLs, u)
+		}
+	}
+	if dropped > 0 {
+		logFn(fmt.Sprintf("[*] paramspider: dropped %d out-of-scope URL(s)", dropped))
+	}
+	result.ParameterizedURLs = utils.UniqueStrings(append(result.ParameterizedURLs, kept...))
+	if err := utils.WriteLines(result.ParameterizedURLs, filepath.Join(outDir, "parameterized_urls.txt")); err != nil {
+		logFn("[!] paramspider error: " + err.Error())
+	}
+	logFn(fmt.Sprintf("[*] ParamSpider found %d archived URL(s) with parameters", len(kept)))
+}
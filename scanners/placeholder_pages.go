@@ -0,0 +1,159 @@
+// scanners/placeholder_pages.go - Parked domain and default page signatures.
+package scanners
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// Placeholder kinds, which are also the state tag values of the origins
+// serving them.
+const (
+	PlaceholderParked  = "parked"
+	PlaceholderDefault = "default-page"
+)
+
+// PlaceholderSignature recognizes a page that holds a domain's place
+// instead of serving a site: a registrar parking page or for-sale lander
+// (kind parked) or a web server, hosting or CDN default page (kind
+// default-page). A page matches when its title contains one of Title or
+// its body one of Body, ignoring case.
+type PlaceholderSignature struct {
+	Name  string   `json:"name"`
+	Kind  string   `json:"kind"`
+	Title []string `json:"title,omitempty"`
+	Body  []string `json:"body,omitempty"`
+}
+
+// placeholderSignatures are the built-in signatures, kept to text only the
+// placeholder page itself carries.
+var placeholderSignatures = []PlaceholderSignature{
+	{Name: "GoDaddy parking", Kind: PlaceholderParked, Body: []string{"is parked free, courtesy of godaddy", "img1.wsimg.com/parking-lander"}},
+	{Name: "Sedo parking", Kind: PlaceholderParked, Body: []string{"sedoparking.com", "this domain may be for sale"}},
+	{Name: "ParkingCrew", Kind: PlaceholderParked, Body: []string{"parkingcrew.net"}},
+	{Name: "Bodis", Kind: PlaceholderParked, Body: []string{"bodis.com", "parking.bodiscdn.com"}},
+	{Name: "Above.com parking", Kind: PlaceholderParked, Body: []string{"above.com/marketplace"}},
+	{Name: "Namecheap parking", Kind: PlaceholderParked, Title: []string{"parked domain name on namecheap"}, Body: []string{"this domain is registered at namecheap"}},
+	{Name: "Dan.com lander", Kind: PlaceholderParked, Body: []string{"dan.com/buy-domain", "dan.com/domain-seller"}},
+	{Name: "Afternic lander", Kind: PlaceholderParked, Body: []string{"afternic.com/forsale"}},
+	{Name: "Domain for sale", Kind: PlaceholderParked, Title: []string{"domain is for sale", "domain for sale"}, Body: []string{"this domain is for sale", "buy this domain"}},
+	{Name: "cPanel default page", Kind: PlaceholderDefault, Title: []string{"default web site page"}, Body: []string{"future home of something quite cool"}},
+	{Name: "Plesk default page", Kind: PlaceholderDefault, Title: []string{"domain default page"}, Body: []string{"this page is autogenerated by plesk"}},
+	{Name: "nginx default page", Kind: PlaceholderDefault, Title: []string{"welcome to nginx!"}},
+	{Name: "OpenResty default page", Kind: PlaceholderDefault, Title: []string{"welcome to openresty!"}},
+	{Name: "Apache default page", Kind: PlaceholderDefault, Title: []string{"apache2 ubuntu default page", "apache2 debian default page", "test page for the apache http server", "http server test page powered by"}, Body: []string{"<h1>it works!</h1>"}},
+	{Name: "IIS default page", Kind: PlaceholderDefault, Title: []string{"iis windows server", "iis7", "iis8", "internet information services"}},
+	{Name: "Tomcat default page", Kind: PlaceholderDefault, Body: []string{"if you're seeing this, you've successfully installed tomcat"}},
+	{Name: "Azure App Service default page", Kind: PlaceholderDefault, Body: []string{"your web app is running and waiting for your content", "your app service app is up and running"}},
+	{Name: "Heroku default app", Kind: PlaceholderDefault, Title: []string{"heroku | welcome to your new app!"}},
+	{Name: "Cloudflare DNS resolution error", Kind: PlaceholderDefault, Title: []string{"dns resolution error"}, Body: []string{"error code: 1001", "<span class=\"code-label\">error code</span> 1001"}},
+	{Name: "Cloudflare origin DNS error", Kind: PlaceholderDefault, Title: []string{"origin dns error"}, Body: []string{"error code: 1016"}},
+}
+
+// LoadPlaceholderSignatures returns the built-in signatures merged with
+// those in the JSON file at file, a list of PlaceholderSignature: an entry
+// replaces the built-in one of the same name (ignoring case) and any other
+// is added. An empty file returns the built-in table.
+func LoadPlaceholderSignatures(file string) ([]PlaceholderSignature, error) {
+	sigs := append([]PlaceholderSignature(nil), placeholderSignatures...)
+	if file == "" {
+		return sigs, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return sigs, err
+	}
+	var custom []PlaceholderSignature
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return sigs, fmt.Errorf("%s: %v", file, err)
+	}
+	for _, c := range custom {
+		if c.Name == "" || len(c.Title)+len(c.Body) == 0 {
+			return sigs, fmt.Errorf("%s: every signature needs a name and at least one title or body string", file)
+		}
+		if c.Kind != PlaceholderParked && c.Kind != PlaceholderDefault {
+			return sigs, fmt.Errorf("%s: signature %q: kind must be %s or %s", file, c.Name, PlaceholderParked, PlaceholderDefault)
+		}
+		replaced := false
+		for i := range sigs {
+			if strings.EqualFold(sigs[i].Name, c.Name) {
+				sigs[i], replaced = c, true
+				break
+			}
+		}
+		if !replaced {
+			sigs = append(sigs, c)
+		}
+	}
+	return sigs, nil
+}
+
+// placeholderSignaturesFromEnv loads the signatures with
+// PLACEHOLDER_SIGNATURES, logging a bad file and falling back to the
+// built-in table.
+func placeholderSignaturesFromEnv(logFn func(string)) []PlaceholderSignature {
+	sigs, err := LoadPlaceholderSignatures(os.Getenv("PLACEHOLDER_SIGNATURES"))
+	if err != nil {
+		logFn("[!] PLACEHOLDER_SIGNATURES: " + err.Error() + "; using the built-in signatures")
+		return placeholderSignatures
+	}
+	return sigs
+}
+
+// containsFold reports whether s, already lower-cased, contains one of
+// subs in any case.
+func containsFold(s string, subs []string) bool {
+	for _, sub := range subs {
+		if sub != "" && strings.Contains(s, strings.ToLower(sub)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClassifyPlaceholder returns the first signature matching a page with the
+// given title and body.
+func ClassifyPlaceholder(sigs []PlaceholderSignature, title string, body []byte) (PlaceholderSignature, bool) {
+	title, text := strings.ToLower(title), strings.ToLower(string(body))
+	for _, sig := range sigs {
+		if containsFold(title, sig.Title) || containsFold(text, sig.Body) {
+			return sig, true
+		}
+	}
+	return PlaceholderSignature{}, false
+}
+
+// tagPlaceholderHosts gives the subdomains whose default-port origin
+// serves a placeholder page the same state tag, so stages that go by host
+// leave them alone too.
+func tagPlaceholderHosts(result *types.ScanResult) {
+	tags := make(map[string]map[string][]string)
+	for _, lh := range result.LiveHosts {
+		if lh.Placeholder != "" && !isAltPort(lh) {
+			tags[lh.Hostname] = lh.Tags
+		}
+	}
+	for i := range result.Subdomains {
+		s := &result.Subdomains[i]
+		for _, kind := range []string{PlaceholderParked, PlaceholderDefault} {
+			if tag := utils.TagState + ":" + kind; utils.HasTag(tags[s.Hostname], tag) {
+				s.Tags = utils.AddTag(s.Tags, tag)
+			}
+		}
+	}
+}
+
+// placeholderNote formats the log line for an origin serving a placeholder.
+func placeholderNote(lh types.LiveHost) string {
+	note := fmt.Sprintf("[*] %s serves a placeholder page (%s)", lh.URL, lh.Placeholder)
+	if !utils.ProbeOrigin(lh) {
+		note += ": left out of aggressive stages, --include-parked scans it"
+	}
+	return note
+}
@@ -0,0 +1,150 @@
+package scanners
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// TestClassifyPlaceholder matches the built-in signatures against parking,
+// for-sale and default pages as hosts serve them, and against a real site
+// that mentions the same software.
+func TestClassifyPlaceholder(t *testing.T) {
+	tests := []struct {
+		file, name, kind string
+	}{
+		{"godaddy.html", "GoDaddy parking", PlaceholderParked},
+		{"sedo.html", "Sedo parking", PlaceholderParked},
+		{"parkingcrew.html", "ParkingCrew", PlaceholderParked},
+		{"dan.html", "Dan.com lander", PlaceholderParked},
+		{"cpanel.html", "cPanel default page", PlaceholderDefault},
+		{"plesk.html", "Plesk default page", PlaceholderDefault},
+		{"nginx.html", "nginx default page", PlaceholderDefault},
+		{"apache_ubuntu.html", "Apache default page", PlaceholderDefault},
+		{"apache_it_works.html", "Apache default page", PlaceholderDefault},
+		{"iis.html", "IIS default page", PlaceholderDefault},
+		{"tomcat.html", "Tomcat default page", PlaceholderDefault},
+		{"azure_app_service.html", "Azure App Service default page", PlaceholderDefault},
+		{"cloudflare_1001.html", "Cloudflare DNS resolution error", PlaceholderDefault},
+		{"shop.html", "", ""},
+	}
+	for _, tt := range tests {
+		body, err := ioutil.ReadFile(filepath.Join("testdata", "placeholders", tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, ok := ClassifyPlaceholder(placeholderSignatures, pageTitle(body), body)
+		if ok != (tt.name != "") || sig.Name != tt.name || sig.Kind != tt.kind {
+			t.Errorf("%s: classified as %q (%s), want %q (%s)", tt.file, sig.Name, sig.Kind, tt.name, tt.kind)
+		}
+	}
+}
+
+func TestLoadPlaceholderSignatures(t *testing.T) {
+	tests := []struct {
+		name, json string
+		err        string
+	}{
+		{"replace and add", `[{"name": "NGINX default page", "kind": "default-page", "title": ["welcome to nginx"]},
+			{"name": "Acme registrar parking", "kind": "parked", "body": ["parked-by-acme-registrar"]}]`, ""},
+		{"bad kind", `[{"name": "Acme", "kind": "dead", "body": ["x"]}]`, `kind must be parked or default-page`},
+		{"no strings", `[{"name": "Acme", "kind": "parked"}]`, "at least one title or body string"},
+		{"no name", `[{"kind": "parked", "body": ["x"]}]`, "needs a name"},
+		{"not a list", `{"name": "Acme"}`, "cannot unmarshal"},
+	}
+	for _, tt := range tests {
+		file := filepath.Join(t.TempDir(), "placeholders.json")
+		if err := ioutil.WriteFile(file, []byte(tt.json), 0644); err != nil {
+			t.Fatal(err)
+		}
+		sigs, err := LoadPlaceholderSignatures(file)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+			}
+			// A bad file leaves the built-in table in effect.
+			if !reflect.DeepEqual(sigs, placeholderSignatures) {
+				t.Errorf("%s: signatures changed", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(sigs) != len(placeholderSignatures)+1 {
+			t.Fatalf("%s: %d signature(s)", tt.name, len(sigs))
+		}
+		if sig, _ := ClassifyPlaceholder(sigs, "Welcome to nginx on Debian", nil); sig.Name != "NGINX default page" {
+			t.Errorf("%s: replaced signature not used: %+v", tt.name, sig)
+		}
+		if sig, _ := ClassifyPlaceholder(sigs, "", []byte("<p>Parked-by-Acme-Registrar</p>")); sig.Kind != PlaceholderParked {
+			t.Errorf("%s: added signature not used: %+v", tt.name, sig)
+		}
+	}
+	if sigs, err := LoadPlaceholderSignatures(""); err != nil || !reflect.DeepEqual(sigs, placeholderSignatures) {
+		t.Errorf("no file: %d signature(s), %v", len(sigs), err)
+	}
+	if _, err := LoadPlaceholderSignatures(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing file: no error")
+	}
+}
+
+// TestPlaceholderExclusion probes a default page and a real site and
+// checks that only the real one is handed to aggressive stages, unless
+// parked origins are included.
+func TestPlaceholderExclusion(t *testing.T) {
+	defer utils.IncludeParked(false)
+	nginx, err := ioutil.ReadFile(filepath.Join("testdata", "placeholders", "nginx.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parked := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(nginx)
+	}))
+	defer parked.Close()
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<title>Acme Outdoor</title>"))
+	}))
+	defer site.Close()
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	lh := probeOrigin(noRedirect, "127.0.0.1", listenerPort(t, parked), placeholderSignatures, "http")
+	if lh.Placeholder != "nginx default page" || !utils.HasTag(lh.Tags, "state:default-page") {
+		t.Fatalf("probed placeholder %+v", lh)
+	}
+	// Seen by the probe on the default port, as in a real run.
+	lh.Hostname, lh.URL, lh.Port = "dev.example.com", parked.URL, 443
+	result := &types.ScanResult{
+		Subdomains: []types.SubdomainResult{{Hostname: "dev.example.com"}, {Hostname: "www.example.com"}},
+		LiveHosts: []types.LiveHost{lh,
+			probeOrigin(noRedirect, "127.0.0.1", listenerPort(t, site), placeholderSignatures, "http"),
+		},
+	}
+	result.LiveHosts[1].Hostname, result.LiveHosts[1].URL, result.LiveHosts[1].Port = "www.example.com", site.URL, 443
+	tagPlaceholderHosts(result)
+	if !utils.HasTag(result.Subdomains[0].Tags, "state:default-page") || result.Subdomains[1].Tags != nil {
+		t.Errorf("subdomain tags %+v", result.Subdomains)
+	}
+	if note := placeholderNote(lh); !strings.Contains(note, "--include-parked") {
+		t.Errorf("note %q", note)
+	}
+
+	hosts, _ := AnsweredHosts(result)
+	if !reflect.DeepEqual(hosts, map[string]string{"www.example.com": site.URL}) || utils.ProbeHost(result.Subdomains[0]) {
+		t.Errorf("placeholder not left out: %v", hosts)
+	}
+	if _, ok := WebTarget(result, "dev.example.com"); ok {
+		t.Error("WebTarget returned the placeholder origin")
+	}
+	utils.IncludeParked(true)
+	if hosts, _ := AnsweredHosts(result); len(hosts) != 2 || !utils.ProbeHost(result.Subdomains[0]) {
+		t.Errorf("--include-parked: %v", hosts)
+	}
+}
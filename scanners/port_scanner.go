@@ -0,0 +1,336 @@
+// scanners/port_scanner.go - Open ports and services of live hosts with nmap, masscan, naabu or a native scan.
+package scanners
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	portResolveTimeout = 5 * time.Second
+	// nmapHostTimeout gives up on hosts that filter or throttle the scan.
+	nmapHostTimeout = "5m"
+	// defaultMasscanRate is deliberately low: masscan's packet rate can take
+	// down small targets.
+	defaultMasscanRate = 100
+	// defaultNaabuTopPorts matches naabu's own default.
+	defaultNaabuTopPorts = "100"
+)
+
+// portScanners returns the scanners PORT_SCANNER selects: nmap (the
+// default), masscan, naabu, native (the built-in TCP connect scan), or a
+// comma-separated combination such as "naabu,nmap".
+func portScanners() []string {
+	var tools []string
+	for _, t := range strings.Split(os.Getenv("PORT_SCANNER"), ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); (t == "nmap" || t == "masscan" || t == "naabu" || t == "native") && !containsString(tools, t) {
+			tools = append(tools, t)
+		}
+	}
+	if len(tools) == 0 {
+		tools = []string{"nmap"}
+	}
+	return tools
+}
+
+// masscanRate returns the packets per second from MASSCAN_RATE.
+func masscanRate() int {
+	if n, err := strconv.Atoi(os.Getenv("MASSCAN_RATE")); err == nil && n > 0 {
+		return n
+	}
+	return defaultMasscanRate
+}
+
+// naabuTopPorts returns the port set from NAABU_TOP_PORTS: 100, 1000 or
+// full.
+func naabuTopPorts() string {
+	switch v := strings.ToLower(strings.TrimSpace(os.Getenv("NAABU_TOP_PORTS"))); v {
+	case "100", "1000", "full":
+		return v
+	}
+	return defaultNaabuTopPorts
+}
+
+// RunPortScan takes every live host's address from live host checking,
+// resolving hosts added since, scans the deduplicated IPs with the
+// configured port scanners and writes the open ports and service names
+// back onto the matching subdomains and into ports.json. When none of the
+// configured scanners is installed the native TCP connect scan stands in.
+// When several scanners run, their ports are merged per host; hostnames
+// sharing an IP all get its ports. The merged hostname:port list goes to
+// ports.txt for other tools. The banners of the non-HTTP services are
+// grabbed and recorded on their ports. Hosts that fail to resolve, time out or are
+// missing from every scanner's output keep an empty Ports slice.
+func RunPortScan(outDir string, result *types.ScanResult, lookup func(ctx context.Context, host string) ([]string, error), logFn func(string)) {
+	var tools []string
+	for _, t := range portScanners() {
+		if t == "native" {
+			tools = append(tools, t)
+			continue
+		}
+		if _, err := exec.LookPath(t); err != nil {
+			logFn("[!] " + t + " not found in PATH; skipping it")
+			continue
+		}
+		tools = append(tools, t)
+	}
+	if len(tools) == 0 {
+		logFn("[!] No port scanner installed; using the native TCP connect scan")
+		tools = []string{"native"}
+	}
+	data, err := ioutil.ReadFile(filepath.Join(outDir, "live_hosts.txt"))
+	if err != nil {
+		logFn("[!] port scanning skipped: " + err.Error())
+		return
+	}
+	logFn("[*] Running " + strings.Join(tools, " and ") + " against live hosts...")
+
+	resolved := make(map[string]string)
+	for _, s := range result.Subdomains {
+		if s.IP != "" {
+			resolved[s.Hostname] = s.IP
+		}
+	}
+	var scans []types.HostPorts
+	byIP := make(map[string][]int)
+	var ips []string
+	for _, h := range strings.Fields(string(data)) {
+		scan := types.HostPorts{Hostname: h, Ports: []types.PortService{}}
+		var (
+			addrs []string
+			err   error
+		)
+		if ip := resolved[h]; ip != "" {
+			addrs = []string{ip}
+		} else {
+			ctx, cancel := context.WithTimeout(context.Background(), portResolveTimeout)
+			addrs, err = lookup(ctx, h)
+			cancel()
+		}
+		if err != nil || len(addrs) == 0 {
+			scan.Error = "resolution failed"
+			if err != nil {
+				scan.Error = err.Error()
+			}
+		} else {
+			scan.IP = addrs[0]
+			if _, seen := byIP[scan.IP]; !seen {
+				ips = append(ips, scan.IP)
+			}
+			byIP[scan.IP] = append(byIP[scan.IP], len(scans))
+		}
+		scans = append(scans, scan)
+	}
+
+	if len(ips) > 0 {
+		targetFile := filepath.Join(outDir, "port_targets.txt")
+		if err := utils.WriteLines(ips, targetFile); err != nil {
+			logFn("[!] port scanning error: " + err.Error())
+			return
+		}
+		reported := make(map[string]bool)
+		timedOut := make(map[string]bool)
+		for _, tool := range tools {
+			var hosts []types.ScannedHost
+			switch tool {
+			case "masscan":
+				hosts, err = runMasscan(outDir, targetFile)
+			case "naabu":
+				hosts, err = runNaabu(outDir, targetFile)
+			case "native":
+				hosts, err = runNativeScan(ips, logFn), nil
+			default:
+				hosts, err = runNmap(outDir, targetFile)
+			}
+			if err != nil {
+				logFn("[!] " + err.Error())
+			}
+			for _, h := range hosts {
+				if h.TimedOut {
+					timedOut[h.Address] = true
+					continue
+				}
+				reported[h.Address] = true
+				for _, i := range byIP[h.Address] {
+					scans[i].Ports = mergePorts(scans[i].Ports, h.Ports)
+				}
+			}
+		}
+		for ip, idx := range byIP {
+			if reported[ip] {
+				continue
+			}
+			for _, i := range idx {
+				if timedOut[ip] {
+					scans[i].Error = "host timeout"
+				} else {
+					scans[i].Error = "not in port scan output"
+				}
+			}
+		}
+	}
+
+	if enabled, timeout := bannerSettings(); enabled {
+		n := grabBanners(scans, timeout, logFn)
+		logFn(fmt.Sprintf("[*] %d non-HTTP service(s) sent a banner", n))
+	}
+
+	var hostPorts []string
+	for _, scan := range scans {
+		for _, p := range scan.Ports {
+			if hp := net.JoinHostPort(scan.Hostname, strconv.Itoa(p.Port)); !containsString(hostPorts, hp) {
+				hostPorts = append(hostPorts, hp)
+			}
+		}
+		for i := range result.Subdomains {
+			sub := &result.Subdomains[i]
+			if sub.Hostname != scan.Hostname {
+				continue
+			}
+			if scan.IP != "" {
+				sub.IP = scan.IP
+			}
+			sub.Ports = []int{}
+			sub.Services = scan.Ports
+			for _, p := range scan.Ports {
+				if !containsInt(sub.Ports, p.Port) {
+					sub.Ports = append(sub.Ports, p.Port)
+				}
+			}
+		}
+		if scan.Error != "" {
+			logFn(fmt.Sprintf("[!] Ports %s: %s", scan.Hostname, scan.Error))
+		} else {
+			logFn(fmt.Sprintf("[*] Ports %s (%s): %d open", scan.Hostname, scan.IP, len(scan.Ports)))
+		}
+	}
+	out, _ := json.MarshalIndent(scans, "", "  ")
+	_ = ioutil.WriteFile(filepath.Join(outDir, "ports.json"), out, 0644)
+	if err := utils.WriteLines(hostPorts, filepath.Join(outDir, "ports.txt")); err != nil {
+		logFn("[!] Failed to write ports.txt: " + err.Error())
+	}
+	logFn("[*] Port scanning complete.")
+}
+
+// mergePorts adds the open ports of add to ports, skipping ports already
+// present for the same protocol but filling in a missing service name.
+func mergePorts(ports, add []types.PortService) []types.PortService {
+	for _, p := range add {
+		if p.State != "open" {
+			continue
+		}
+		merged := false
+		for i := range ports {
+			if ports[i].Port == p.Port && ports[i].Protocol == p.Protocol {
+				if ports[i].Service == "" {
+					ports[i].Service = p.Service
+				}
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			ports = append(ports, p)
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].Port != ports[j].Port {
+			return ports[i].Port < ports[j].Port
+		}
+		return ports[i].Protocol < ports[j].Protocol
+	})
+	return ports
+}
+
+// containsInt reports whether list contains n.
+func containsInt(list []int, n int) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// runNmap scans the IPs in targetFile with nmap and parses nmap.xml.
+func runNmap(outDir, targetFile string) ([]types.ScannedHost, error) {
+	xmlFile := filepath.Join(outDir, "nmap.xml")
+	_, runErr := utils.RunCommand("nmap", "-Pn", "-T4", "--host-timeout", nmapHostTimeout,
+		"-iL", targetFile, "-oX", xmlFile)
+	// nmap may have been killed after writing part of its output; parse what is there.
+	hosts, err := readNmapXML(xmlFile)
+	if runErr != nil {
+		return hosts, fmt.Errorf("nmap error: %v", runErr)
+	}
+	return hosts, err
+}
+
+// runMasscan scans every TCP port (MASSCAN_PORTS to narrow it) of the IPs
+// in targetFile at MASSCAN_RATE packets per second and parses masscan.json.
+func runMasscan(outDir, targetFile string) ([]types.ScannedHost, error) {
+	jsonFile := filepath.Join(outDir, "masscan.json")
+	ports := os.Getenv("MASSCAN_PORTS")
+	if ports == "" {
+		ports = "1-65535"
+	}
+	_, runErr := utils.RunCommand("masscan", "-iL", targetFile, "-p", ports,
+		"--rate", strconv.Itoa(masscanRate()), "-oJ", jsonFile)
+	data, err := ioutil.ReadFile(jsonFile)
+	if err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("masscan error: %v", runErr)
+		}
+		return nil, fmt.Errorf("failed to read masscan output: %v", err)
+	}
+	hosts, err := parsers.ParseMasscanJSON(data)
+	if runErr != nil {
+		return hosts, fmt.Errorf("masscan error: %v", runErr)
+	}
+	return hosts, err
+}
+
+// runNaabu scans the NAABU_TOP_PORTS ports of the IPs in targetFile with
+// naabu, leaving out NAABU_EXCLUDE_PORTS, and parses naabu.json.
+func runNaabu(outDir, targetFile string) ([]types.ScannedHost, error) {
+	jsonFile := filepath.Join(outDir, "naabu.json")
+	args := []string{"-list", targetFile, "-top-ports", naabuTopPorts(), "-json", "-silent", "-o", jsonFile}
+	if exclude := strings.TrimSpace(os.Getenv("NAABU_EXCLUDE_PORTS")); exclude != "" {
+		args = append(args, "-exclude-ports", exclude)
+	}
+	_, runErr := utils.RunCommand("naabu", args...)
+	data, err := ioutil.ReadFile(jsonFile)
+	if err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("naabu error: %v", runErr)
+		}
+		return nil, fmt.Errorf("failed to read naabu output: %v", err)
+	}
+	hosts, err := parsers.ParseNaabuOutput(string(data))
+	if runErr != nil {
+		return hosts, fmt.Errorf("naabu error: %v", runErr)
+	}
+	return hosts, err
+}
+
+// readNmapXML parses the XML file nmap wrote, if any.
+func readNmapXML(path string) ([]types.ScannedHost, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read nmap output: %v", err)
+	}
+	return parsers.ParseNmapXML(data)
+}
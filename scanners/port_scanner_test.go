@@ -0,0 +1,37 @@
+package scanners
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestMergePorts(t *testing.T) {
+	tcp := func(port int, service string) types.PortService {
+		return types.PortService{Port: port, Protocol: "tcp", State: "open", Service: service}
+	}
+	tests := []struct {
+		name       string
+		ports, add []types.PortService
+		want       []types.PortService
+	}{
+		{"into nothing", nil, []types.PortService{tcp(443, ""), tcp(80, "")}, []types.PortService{tcp(80, ""), tcp(443, "")}},
+		// naabu after nmap: known ports keep nmap's service names.
+		{"naabu after nmap", []types.PortService{tcp(22, "ssh"), tcp(443, "https")}, []types.PortService{tcp(443, ""), tcp(8080, "")},
+			[]types.PortService{tcp(22, "ssh"), tcp(443, "https"), tcp(8080, "")}},
+		// nmap after naabu fills the service names in.
+		{"nmap after naabu", []types.PortService{tcp(443, ""), tcp(8080, "")}, []types.PortService{tcp(443, "https")},
+			[]types.PortService{tcp(443, "https"), tcp(8080, "")}},
+		{"closed and filtered ports are left out", []types.PortService{tcp(80, "http")},
+			[]types.PortService{{Port: 25, Protocol: "tcp", State: "closed"}, {Port: 3306, Protocol: "tcp", State: "filtered"}},
+			[]types.PortService{tcp(80, "http")}},
+		{"same port, other protocol", []types.PortService{tcp(53, "")}, []types.PortService{{Port: 53, Protocol: "udp", State: "open", Service: "domain"}},
+			[]types.PortService{tcp(53, ""), {Port: 53, Protocol: "udp", State: "open", Service: "domain"}}},
+	}
+	for _, tt := range tests {
+		if got := mergePorts(tt.ports, tt.add); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// scanners/reflection.go - Benign markers for detecting reflected input.
+package scanners
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"sort"
+)
+
+// reflectionParam is the parameter a marker is placed in when an endpoint
+// has no query parameters of its own.
+const reflectionParam = "q"
+
+// newReflectionMarker returns a random alphanumeric token that no page
+// contains by accident and that no filter treats as special.
+func newReflectionMarker() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "rcn" + hex.EncodeToString(b)
+}
+
+// withMarker returns u with marker as the value of its first query
+// parameter (alphabetically), or of reflectionParam when it has none, and the parameter used.
+func withMarker(u *url.URL, marker string) (*url.URL, string) {
+	q := u.Query()
+	names := make([]string, 0, len(q))
+	for name := range q {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	param := reflectionParam
+	if len(names) > 0 {
+		param = names[0]
+	}
+	q.Set(param, marker)
+	out := *u
+	out.RawQuery = q.Encode()
+	return &out, param
+}
+
+// reflects reports whether body contains marker verbatim.
+func reflects(body []byte, marker string) bool {
+	return bytes.Contains(body, []byte(marker))
+}
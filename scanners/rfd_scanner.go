@@ -0,0 +1,122 @@
+// scanners/rfd_scanner.go - Reflected file download and content-type mismatch checks.
+package scanners
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// rfdMaxBody caps how much of each response is searched for the marker.
+const rfdMaxBody = 1 << 20
+
+// rfdFilenameParam is the parameter offered as a download filename.
+const rfdFilenameParam = "filename"
+
+// isAPIEndpoint reports whether u looks like an API endpoint, whose
+// responses should never be rendered as HTML.
+func isAPIEndpoint(u *url.URL) bool {
+	return apiPathRe.MatchString(u.Path) || strings.HasSuffix(strings.ToLower(u.Path), ".json")
+}
+
+// responseEvidence formats the headers the checks judge a response by.
+func responseEvidence(h http.Header) string {
+	return fmt.Sprintf("Content-Type: %q, Content-Disposition: %q, X-Content-Type-Options: %q",
+		h.Get("Content-Type"), h.Get("Content-Disposition"), h.Get("X-Content-Type-Options"))
+}
+
+// fetch GETs u and returns the response headers and up to rfdMaxBody of the body.
+func fetch(client *http.Client, u *url.URL) (http.Header, []byte, error) {
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, rfdMaxBody))
+	return resp.Header, body, err
+}
+
+// CheckResponseHandling probes an API endpoint with a benign marker: first
+// in a query parameter, to see whether it is reflected into a response
+// browsers would render or sniff as HTML, then as a download filename, to
+// see whether Content-Disposition honors it. answered is false when neither
+// probe got a response.
+func CheckResponseHandling(client *http.Client, u *url.URL) (findings []types.VulnerabilityResult, answered bool) {
+	endpoint := u.Scheme + "://" + u.Host + u.EscapedPath()
+
+	marker := newReflectionMarker()
+	probe, param := withMarker(u, marker)
+	h, body, err := fetch(client, probe)
+	answered = err == nil
+	if err == nil && reflects(body, marker) && !strings.EqualFold(strings.TrimSpace(h.Get("X-Content-Type-Options")), "nosniff") {
+		mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+		switch {
+		case mediaType == "text/html":
+			findings = append(findings, types.VulnerabilityResult{
+				URL:      endpoint,
+				Issue:    "Content-Type Mismatch",
+				Type:     "content-type-mismatch",
+				Detail:   fmt.Sprintf("Param %s reflected in a text/html response without nosniff (%s)", param, responseEvidence(h)),
+				Severity: "medium",
+			})
+		case mediaType == "":
+			findings = append(findings, types.VulnerabilityResult{
+				URL:      endpoint,
+				Issue:    "Content-Type Mismatch",
+				Type:     "content-type-mismatch",
+				Detail:   fmt.Sprintf("Param %s reflected in a response without Content-Type or nosniff (%s)", param, responseEvidence(h)),
+				Severity: "low",
+			})
+		}
+	}
+
+	time.Sleep(endpointProbePause)
+	marker = newReflectionMarker()
+	probe = &url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery}
+	q := probe.Query()
+	q.Set(rfdFilenameParam, marker+".txt")
+	probe.RawQuery = q.Encode()
+	h, _, err = fetch(client, probe)
+	if err == nil {
+		answered = true
+		if _, params, err := mime.ParseMediaType(h.Get("Content-Disposition")); err == nil && strings.Contains(params["filename"], marker) {
+			findings = append(findings, types.VulnerabilityResult{
+				URL:      endpoint,
+				Issue:    "Reflected File Download",
+				Type:     "reflected-file-download",
+				Detail:   fmt.Sprintf("Download filename taken from the %s parameter (%s)", rfdFilenameParam, responseEvidence(h)),
+				Severity: "medium",
+			})
+		}
+	}
+	return findings, answered
+}
+
+// RunResponseHandlingChecks runs CheckResponseHandling over a bounded
+// sample of in-scope API endpoints per host.
+func RunResponseHandlingChecks(client *http.Client, result *types.ScanResult, inScope func(string) bool, logFn func(string)) {
+	logFn("[*] Checking API endpoints for reflected downloads and content-type mismatches...")
+	probe := noRedirectClient(client)
+	var mu sync.Mutex
+	sample, cov := sampleEndpoints("response handling checks", result, inScope, isAPIEndpoint)
+	forEachEndpoint(sample, cov, func(u *url.URL) bool {
+		findings, answered := CheckResponseHandling(probe, u)
+		mu.Lock()
+		defer mu.Unlock()
+		result.VulnURLs = append(result.VulnURLs, findings...)
+		for _, f := range findings {
+			logFn(fmt.Sprintf("[!] %s: %s", f.Issue, f.URL))
+		}
+		return answered
+	})
+	result.Coverage = append(result.Coverage, *cov)
+	logFn(fmt.Sprintf("[*] Response handling checks complete, %d of %d API endpoint(s) checked.", cov.Completed, cov.Attempted))
+}
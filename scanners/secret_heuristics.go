@@ -0,0 +1,333 @@
+// scanners/secret_heuristics.go - Built-in secret patterns and the layers that weed out their false positives.
+package scanners
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// secretClass is a kind of secret the built-in detector looks for. The
+// value is the pattern's first group, or the whole match when it has none.
+// Classes with a VerifyURL can be checked live: a HEAD request carrying the
+// secret in the Authorization header, built by Auth, answers 2xx for a live
+// secret and 401 or 403 for a dead one.
+type secretClass struct {
+	Name    string
+	Label   string
+	Pattern *regexp.Regexp
+	// MinEntropy is the Shannon entropy, in bits per character, below which
+	// a value is too regular to be a real secret of the class.
+	MinEntropy float64
+	// Structured classes have a provider prefix, so a match is likely a
+	// secret even with nothing around it naming one.
+	Structured bool
+	VerifyURL  string
+	Auth       func(secret string) string
+}
+
+func bearerAuth(secret string) string { return "Bearer " + secret }
+
+// secretClasses are the built-in patterns, most specific first; a value
+// matched by one class is not reported again by a later one.
+var secretClasses = []secretClass{
+	{Name: "aws-access-key", Label: "AWS Access Key ID", Pattern: regexp.MustCompile(`\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`), MinEntropy: 3.0, Structured: true},
+	{Name: "github-token", Label: "GitHub Token", Pattern: regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36})\b`), MinEntropy: 4.0, Structured: true,
+		VerifyURL: "https://api.github.com/user", Auth: func(s string) string { return "token " + s }},
+	{Name: "stripe-secret-key", Label: "Stripe Secret Key", Pattern: regexp.MustCompile(`\b((?:sk|rk)_live_[0-9A-Za-z]{24,99})\b`), MinEntropy: 3.5, Structured: true,
+		VerifyURL: "https://api.stripe.com/v1/balance", Auth: bearerAuth},
+	{Name: "slack-token", Label: "Slack Token", Pattern: regexp.MustCompile(`\b(xox[baprs]-[0-9A-Za-z-]{10,72})\b`), MinEntropy: 3.5, Structured: true},
+	{Name: "google-api-key", Label: "Google API Key", Pattern: regexp.MustCompile(`\b(AIza[0-9A-Za-z_-]{35})`), MinEntropy: 4.0, Structured: true},
+	{Name: "generic-secret", Label: "Hardcoded Secret", Pattern: regexp.MustCompile(`(?i)[A-Za-z0-9_$]*(?:api_?key|apikey|access_?key|secret|token|passw(?:or)?d|credential)[A-Za-z0-9_$]*["']?\s*[:=]\s*["']([A-Za-z0-9+/=_.-]{16,128})["']`),
+		MinEntropy: defaultGenericEntropy},
+}
+
+const (
+	// defaultGenericEntropy is the generic class's entropy threshold unless
+	// SECRET_MIN_ENTROPY sets another.
+	defaultGenericEntropy = 3.5
+	// secretContextBytes is how far before a match the context layer looks
+	// for names; minified bundles keep everything on one line.
+	secretContextBytes = 60
+)
+
+// secretSettings reads SECRET_MIN_ENTROPY, the entropy threshold of the
+// generic class, and SECRET_PLACEHOLDERS, comma-separated markers of
+// placeholder values on top of the built-in ones.
+func secretSettings() (genericEntropy float64, placeholders []string) {
+	genericEntropy = defaultGenericEntropy
+	if v, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv("SECRET_MIN_ENTROPY")), 64); err == nil && v > 0 {
+		genericEntropy = v
+	}
+	for _, p := range strings.Split(os.Getenv("SECRET_PLACEHOLDERS"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			placeholders = append(placeholders, strings.ToLower(p))
+		}
+	}
+	return genericEntropy, placeholders
+}
+
+// SecretCandidate is a value a built-in pattern matched. Source is the URL
+// or path of the scanned file, Before the text just before the value and
+// LineText the line it is on, cut to a window around it.
+type SecretCandidate struct {
+	Class    secretClass
+	Value    string
+	Source   string
+	Line     int
+	Before   string
+	LineText string
+}
+
+// DetectSecrets returns what the built-in patterns match in content.
+func DetectSecrets(source, content string) []SecretCandidate {
+	var found []SecretCandidate
+	seen := make(map[string]bool)
+	for _, class := range secretClasses {
+		for _, m := range class.Pattern.FindAllStringSubmatchIndex(content, -1) {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+			value := content[start:end]
+			if seen[value] {
+				continue
+			}
+			seen[value] = true
+			from := start - secretContextBytes
+			if from < 0 {
+				from = 0
+			}
+			lineStart := strings.LastIndexByte(content[:start], '\n') + 1
+			lineEnd := strings.IndexByte(content[end:], '\n')
+			if lineEnd < 0 {
+				lineEnd = len(content)
+			} else {
+				lineEnd += end
+			}
+			if lineStart < from {
+				lineStart = from
+			}
+			if lineEnd > end+secretContextBytes {
+				lineEnd = end + secretContextBytes
+			}
+			found = append(found, SecretCandidate{
+				Class:    class,
+				Value:    value,
+				Source:   source,
+				Line:     strings.Count(content[:start], "\n") + 1,
+				Before:   content[from:start],
+				LineText: content[lineStart:lineEnd],
+			})
+		}
+	}
+	return found
+}
+
+// ShannonEntropy returns the entropy of s in bits per character.
+func ShannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// secretLayer is one layer's say on a candidate: whether it rules the
+// candidate out, how it moves the score, and a note for the evidence.
+type secretLayer struct {
+	Reject bool
+	Score  int
+	Note   string
+}
+
+// entropyLayer rules out values too regular for their class.
+func entropyLayer(c SecretCandidate, genericEntropy float64) secretLayer {
+	min := c.Class.MinEntropy
+	if c.Class.Name == "generic-secret" {
+		min = genericEntropy
+	}
+	h := ShannonEntropy(c.Value)
+	if h < min {
+		return secretLayer{Reject: true, Note: fmt.Sprintf("entropy %.2f < %.2f", h, min)}
+	}
+	return secretLayer{Note: fmt.Sprintf("entropy %.2f >= %.2f", h, min)}
+}
+
+// placeholderMarkers are substrings of documentation and template values.
+var placeholderMarkers = []string{"example", "xxxxxx", "0000", "placeholder", "changeme", "your_", "your-", "redacted", "dummy", "sample", "${", "{{", "<"}
+
+// placeholderLayer rules out known placeholder values and values made of
+// one character repeated.
+func placeholderLayer(c SecretCandidate, extra []string) secretLayer {
+	lower := strings.ToLower(c.Value)
+	for _, m := range append(placeholderMarkers, extra...) {
+		if strings.Contains(lower, m) {
+			return secretLayer{Reject: true, Note: fmt.Sprintf("placeholder (%q)", m)}
+		}
+	}
+	if run := longestRun(c.Value); run >= 8 {
+		return secretLayer{Reject: true, Note: fmt.Sprintf("placeholder (%d repeated characters)", run)}
+	}
+	return secretLayer{Note: "no placeholder"}
+}
+
+// longestRun returns the length of the longest run of one character in s.
+func longestRun(s string) int {
+	best, run := 0, 0
+	var prev rune
+	for i, r := range s {
+		if i > 0 && r == prev {
+			run++
+		} else {
+			run = 1
+		}
+		prev = r
+		if run > best {
+			best = run
+		}
+	}
+	return best
+}
+
+var (
+	// secretNameRe matches names of variables and keys that hold secrets.
+	secretNameRe = regexp.MustCompile(`(?i)[A-Za-z0-9_$]*(api_?key|apikey|access_?key|secret|token|passw|credential|auth)[A-Za-z0-9_$]*`)
+	// fixturePathRe matches paths of test code.
+	fixturePathRe = regexp.MustCompile(`(?i)(^|[/_.-])(tests?|specs?|__tests__|fixtures?|mocks?|stories)([/_.-]|$)`)
+	// fixtureWordRe matches words around fake values.
+	fixtureWordRe = regexp.MustCompile(`(?i)\b(mock|fixture|fake|dummy|test_?key)`)
+)
+
+// contextLayer scores what surrounds a candidate: a name such as key,
+// token or secret right before it raises the score; a sourcemap comment,
+// a .map file or test code lowers it.
+func contextLayer(c SecretCandidate) secretLayer {
+	var notes []string
+	score := 0
+	if c.Class.Structured {
+		score++
+		notes = append(notes, "provider prefix (+1)")
+	}
+	if names := secretNameRe.FindAllString(c.Before, -1); len(names) > 0 {
+		score++
+		notes = append(notes, fmt.Sprintf("after %q (+1)", names[len(names)-1]))
+	}
+	if strings.Contains(c.LineText, "sourceMappingURL") || strings.Contains(c.LineText, "sourcesContent") || strings.HasSuffix(strings.ToLower(c.Source), ".map") {
+		score--
+		notes = append(notes, "in a sourcemap (-1)")
+	}
+	if fixturePathRe.MatchString(c.Source) || fixtureWordRe.MatchString(c.Before) {
+		score--
+		notes = append(notes, "in test code (-1)")
+	}
+	if len(notes) == 0 {
+		notes = append(notes, "nothing around it")
+	}
+	return secretLayer{Score: score, Note: "context: " + strings.Join(notes, ", ")}
+}
+
+// verifyLayer checks a candidate of a verifiable class with the provider.
+// The outcome is verified, rejected or, when the class cannot be checked
+// or the provider's answer says neither, unverified (see utils.SecretVerified).
+func verifyLayer(client *http.Client, c SecretCandidate) (outcome string, layer secretLayer) {
+	if c.Class.VerifyURL == "" {
+		return utils.SecretUnverified, secretLayer{Note: "not verifiable"}
+	}
+	req, err := http.NewRequest(http.MethodHead, c.Class.VerifyURL, nil)
+	if err != nil {
+		return utils.SecretUnverified, secretLayer{Note: "verification failed: " + err.Error()}
+	}
+	req.Header.Set("Authorization", c.Class.Auth(c.Value))
+	resp, err := client.Do(req)
+	if err != nil {
+		return utils.SecretUnverified, secretLayer{Note: "verification failed: " + err.Error()}
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return utils.SecretVerified, secretLayer{Note: fmt.Sprintf("verified live (HTTP %d)", resp.StatusCode)}
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return utils.SecretRejected, secretLayer{Note: fmt.Sprintf("rejected by the provider (HTTP %d)", resp.StatusCode)}
+	}
+	return utils.SecretUnverified, secretLayer{Note: fmt.Sprintf("verification inconclusive (HTTP %d)", resp.StatusCode)}
+}
+
+// SecretVerifier returns a check of secrets of any detector with their
+// provider: the first built-in class of the secret's class (see
+// utils.SecretClass) that can be verified is used. Secrets of classes
+// that cannot be verified stay unverified.
+func SecretVerifier(client *http.Client) func(class, secret string) string {
+	return func(class, secret string) string {
+		for _, c := range secretClasses {
+			if c.VerifyURL != "" && utils.SecretClass(c.Name) == class {
+				outcome, _ := verifyLayer(client, SecretCandidate{Class: c, Value: secret})
+				return outcome
+			}
+		}
+		return utils.SecretUnverified
+	}
+}
+
+// SecretDecision is the layers' combined verdict on a candidate.
+// Verification is the outcome of the verification layer.
+type SecretDecision struct {
+	Keep         bool
+	Confidence   string
+	Verification string
+	Evidence     string
+}
+
+// EvaluateSecret runs a candidate through the layers in order: entropy
+// and placeholders can rule it out; context sets the confidence, firm at a
+// score of 1 or more and tentative below; a live verification, with client
+// set, makes it confirmed and a rejected one tentative. Evidence records
+// each layer's note and the verdict.
+func EvaluateSecret(c SecretCandidate, client *http.Client) SecretDecision {
+	genericEntropy, placeholders := secretSettings()
+	var notes []string
+	verdict := func(d SecretDecision, result string) SecretDecision {
+		d.Evidence = strings.Join(notes, "; ") + " => " + result
+		return d
+	}
+	for _, l := range []secretLayer{entropyLayer(c, genericEntropy), placeholderLayer(c, placeholders)} {
+		notes = append(notes, l.Note)
+		if l.Reject {
+			return verdict(SecretDecision{}, "dropped")
+		}
+	}
+	ctx := contextLayer(c)
+	notes = append(notes, ctx.Note)
+	d := SecretDecision{Keep: true, Confidence: utils.ConfidenceTentative, Verification: utils.SecretUnverified}
+	if ctx.Score >= 1 {
+		d.Confidence = utils.ConfidenceFirm
+	}
+	if client != nil {
+		outcome, l := verifyLayer(client, c)
+		notes = append(notes, l.Note)
+		d.Verification = outcome
+		switch outcome {
+		case utils.SecretVerified:
+			d.Confidence = utils.ConfidenceConfirmed
+		case utils.SecretRejected:
+			d.Confidence = utils.ConfidenceTentative
+		}
+	}
+	return verdict(d, d.Confidence)
+}
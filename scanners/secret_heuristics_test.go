@@ -0,0 +1,331 @@
+package scanners
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// The corpus values are put together from pieces so the repository itself
+// does not trip secret scanners.
+var (
+	awsKey      = "AKIA" + "Q3TZ7LWN2P5RXK8M"
+	awsDocKey   = "AKIA" + "IOSFODNN7EXAMPLE"
+	awsLookItUp = "AKIA" + "AAAAAAAAAAAAAAAA"
+	githubToken = "gh" + "p_" + "r8Kd2mQx7ZtL4vWb9NcY3hFs6JpG1aUe5oTi"
+	githubRuns  = "gh" + "p_" + strings.Repeat("ab", 18)
+	stripeKey   = "sk" + "_live_" + "4eC39HqLyjWDarjtT1zdp7dc"
+	stripeXs    = "sk" + "_live_" + strings.Repeat("x", 24)
+	genericKey  = "9fK2xLq8ZmR4tVw7Nb3s"
+	genericLow  = "aaaaaaaabbbbbbbb"
+)
+
+func classNamed(t *testing.T, name string) secretClass {
+	t.Helper()
+	for _, c := range secretClasses {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("no secret class %s", name)
+	return secretClass{}
+}
+
+func TestShannonEntropy(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+	}{
+		{"", 0},
+		{"aaaa", 0},
+		{"ab", 1},
+		{"abcd", 2},
+		{"aabb", 1},
+	}
+	for _, tt := range tests {
+		if got := ShannonEntropy(tt.in); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("ShannonEntropy(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDetectSecrets(t *testing.T) {
+	content := `!function(){var e={region:"us-east-1",k:"` + awsKey + `"};` + "\n" +
+		`const cfg={apiToken:"` + githubToken + `",accessKey:'` + genericKey + `'};` + "\n" +
+		`fetch(u,{headers:{"Content-Type":"application/json"}})`
+	found := DetectSecrets("https://app.example.com/main.js", content)
+	if len(found) != 3 {
+		t.Fatalf("found %d candidate(s): %+v", len(found), found)
+	}
+	want := []struct {
+		class, value string
+		line         int
+	}{
+		{"aws-access-key", awsKey, 1},
+		// Also matched by the generic class, but reported once.
+		{"github-token", githubToken, 2},
+		{"generic-secret", genericKey, 2},
+	}
+	for i, w := range want {
+		c := found[i]
+		if c.Class.Name != w.class || c.Value != w.value || c.Line != w.line || c.Source != "https://app.example.com/main.js" {
+			t.Errorf("candidate %d: %s %q line %d, want %s %q line %d", i, c.Class.Name, c.Value, c.Line, w.class, w.value, w.line)
+		}
+	}
+	if !strings.HasSuffix(found[1].Before, `const cfg={apiToken:"`) || strings.Contains(found[1].LineText, "\n") {
+		t.Errorf("context of the token: before %q, line %q", found[1].Before, found[1].LineText)
+	}
+	if len(found[0].Before) > secretContextBytes {
+		t.Errorf("context of %d bytes", len(found[0].Before))
+	}
+}
+
+func TestEntropyLayer(t *testing.T) {
+	tests := []struct {
+		name, class, value string
+		reject             bool
+	}{
+		{"AWS key", "aws-access-key", awsKey, false},
+		{"AWS documentation key", "aws-access-key", awsDocKey, false},
+		{"AWS lookalike in a bundle", "aws-access-key", awsLookItUp, true},
+		{"GitHub token", "github-token", githubToken, false},
+		{"GitHub token of two letters", "github-token", githubRuns, true},
+		{"Stripe key", "stripe-secret-key", stripeKey, false},
+		{"generic random value", "generic-secret", genericKey, false},
+		{"generic regular value", "generic-secret", genericLow, true},
+		{"generic word", "generic-secret", "application_json", true},
+	}
+	for _, tt := range tests {
+		l := entropyLayer(SecretCandidate{Class: classNamed(t, tt.class), Value: tt.value}, defaultGenericEntropy)
+		if l.Reject != tt.reject || !strings.HasPrefix(l.Note, "entropy ") {
+			t.Errorf("%s: reject %v (%s), want %v", tt.name, l.Reject, l.Note, tt.reject)
+		}
+	}
+	// The generic threshold is configurable.
+	if l := entropyLayer(SecretCandidate{Class: classNamed(t, "generic-secret"), Value: genericKey}, 5); !l.Reject || !strings.HasSuffix(l.Note, " < 5.00") {
+		t.Errorf("generic value with a threshold of 5: %+v", l)
+	}
+}
+
+func TestPlaceholderLayer(t *testing.T) {
+	tests := []struct {
+		value  string
+		extra  []string
+		reject string
+	}{
+		{awsKey, nil, ""},
+		{githubToken, nil, ""},
+		{stripeKey, nil, ""},
+		{genericKey, nil, ""},
+		{awsDocKey, nil, `placeholder ("example")`},
+		{stripeXs, nil, `placeholder ("xxxxxx")`},
+		{"AKIA0000ZQ8W4RT2PLMN", nil, `placeholder ("0000")`},
+		{"your_api_key_here_42", nil, `placeholder ("your_")`},
+		{"<insert-token-here>", nil, `placeholder ("<")`},
+		{"ZZZZZZZZ1234abcd", nil, "placeholder (8 repeated characters)"},
+		{genericKey, []string{"lq8zm"}, `placeholder ("lq8zm")`},
+	}
+	for _, tt := range tests {
+		l := placeholderLayer(SecretCandidate{Value: tt.value}, tt.extra)
+		switch {
+		case tt.reject == "" && (l.Reject || l.Note != "no placeholder"):
+			t.Errorf("%q: rejected as %s", tt.value, l.Note)
+		case tt.reject != "" && (!l.Reject || l.Note != tt.reject):
+			t.Errorf("%q: %+v, want rejected as %s", tt.value, l, tt.reject)
+		}
+	}
+}
+
+func TestContextLayer(t *testing.T) {
+	aws, generic := classNamed(t, "aws-access-key"), classNamed(t, "generic-secret")
+	tests := []struct {
+		name  string
+		c     SecretCandidate
+		score int
+		note  string
+	}{
+		{"prefixed key alone", SecretCandidate{Class: aws, Source: "https://a.example.com/app.js", Before: `[1,2,"`}, 1,
+			"context: provider prefix (+1)"},
+		{"prefixed key after a name", SecretCandidate{Class: aws, Source: "https://a.example.com/app.js", Before: `{awsAccessKeyId:"`}, 2,
+			`context: provider prefix (+1), after "awsAccessKeyId" (+1)`},
+		{"generic value after a name", SecretCandidate{Class: generic, Source: "https://a.example.com/app.js", Before: `const clientSecret = "`}, 1,
+			`context: after "clientSecret" (+1)`},
+		{"value with nothing around it", SecretCandidate{Class: generic, Source: "https://a.example.com/app.js", Before: `x = "`}, 0,
+			"context: nothing around it"},
+		{"in a sourcemap comment", SecretCandidate{Class: aws, Source: "https://a.example.com/app.js", Before: `base64,`,
+			LineText: "//# sourceMappingURL=data:application/json;base64," + awsKey}, 0,
+			"context: provider prefix (+1), in a sourcemap (-1)"},
+		{"in a .map file", SecretCandidate{Class: generic, Source: "https://a.example.com/app.js.map", Before: `apiKey = \"`}, 0,
+			`context: after "apiKey" (+1), in a sourcemap (-1)`},
+		{"in a test file", SecretCandidate{Class: aws, Source: "https://a.example.com/static/__tests__/api.js", Before: `"`}, 0,
+			"context: provider prefix (+1), in test code (-1)"},
+		{"next to a mock", SecretCandidate{Class: generic, Source: "https://a.example.com/app.js", Before: `const mockToken = "`}, 0,
+			`context: after "mockToken" (+1), in test code (-1)`},
+		{"sourcemap of a fixture", SecretCandidate{Class: generic, Source: "https://a.example.com/fixtures/keys.js.map", Before: `"`}, -2,
+			"context: in a sourcemap (-1), in test code (-1)"},
+		{"latest is not a test", SecretCandidate{Class: generic, Source: "https://a.example.com/latest/app.js", Before: `token:"`}, 1,
+			`context: after "token" (+1)`},
+	}
+	for _, tt := range tests {
+		if l := contextLayer(tt.c); l.Score != tt.score || l.Note != tt.note || l.Reject {
+			t.Errorf("%s: %+v, want score %d and %q", tt.name, l, tt.score, tt.note)
+		}
+	}
+}
+
+// verifyServer answers 200 to "Bearer live", 401 to "Bearer dead" and 503
+// to anything else.
+func verifyServer(t *testing.T) (secretClass, *httptest.Server) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("verification sent a %s request", r.Method)
+		}
+		switch r.Header.Get("Authorization") {
+		case "Bearer live":
+			w.WriteHeader(http.StatusOK)
+		case "Bearer dead":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	class := classNamed(t, "stripe-secret-key")
+	class.VerifyURL = srv.URL
+	return class, srv
+}
+
+func TestVerifyLayer(t *testing.T) {
+	class, srv := verifyServer(t)
+	defer srv.Close()
+	tests := []struct {
+		c             SecretCandidate
+		outcome, note string
+	}{
+		{SecretCandidate{Class: class, Value: "live"}, utils.SecretVerified, "verified live (HTTP 200)"},
+		{SecretCandidate{Class: class, Value: "dead"}, utils.SecretRejected, "rejected by the provider (HTTP 401)"},
+		{SecretCandidate{Class: class, Value: "flaky"}, utils.SecretUnverified, "verification inconclusive (HTTP 503)"},
+		{SecretCandidate{Class: classNamed(t, "aws-access-key"), Value: awsKey}, utils.SecretUnverified, "not verifiable"},
+	}
+	for _, tt := range tests {
+		if outcome, l := verifyLayer(srv.Client(), tt.c); outcome != tt.outcome || l.Note != tt.note {
+			t.Errorf("%q: %s (%s), want %s (%s)", tt.c.Value, outcome, l.Note, tt.outcome, tt.note)
+		}
+	}
+	down := class
+	down.VerifyURL = "http://127.0.0.1:1/"
+	if outcome, l := verifyLayer(srv.Client(), SecretCandidate{Class: down, Value: "live"}); outcome != utils.SecretUnverified || !strings.HasPrefix(l.Note, "verification failed: ") {
+		t.Errorf("unreachable provider: %s (%s)", outcome, l.Note)
+	}
+}
+
+func TestEvaluateSecret(t *testing.T) {
+	class, srv := verifyServer(t)
+	defer srv.Close()
+	// The live and dead values are short, so their class takes any entropy.
+	class.MinEntropy = 0
+	aws, generic := classNamed(t, "aws-access-key"), classNamed(t, "generic-secret")
+	tests := []struct {
+		name       string
+		c          SecretCandidate
+		verify     bool
+		keep       bool
+		confidence string
+		evidence   []string
+	}{
+		{"AWS lookalike", SecretCandidate{Class: aws, Value: awsLookItUp}, false, false, "",
+			[]string{"entropy ", " < 3.00", "=> dropped"}},
+		{"AWS documentation key", SecretCandidate{Class: aws, Value: awsDocKey}, false, false, "",
+			[]string{" >= 3.00; placeholder (\"example\") => dropped"}},
+		{"AWS key", SecretCandidate{Class: aws, Value: awsKey, Before: `"`}, false, true, utils.ConfidenceFirm,
+			[]string{" >= 3.00; no placeholder; context: provider prefix (+1) => firm"}},
+		{"AWS key in a test file", SecretCandidate{Class: aws, Value: awsKey, Source: "https://a.example.com/test/keys.js"}, false, true, utils.ConfidenceTentative,
+			[]string{"in test code (-1) => tentative"}},
+		{"generic value after a name", SecretCandidate{Class: generic, Value: genericKey, Before: `password:"`}, false, true, utils.ConfidenceFirm,
+			[]string{`context: after "password" (+1) => firm`}},
+		{"verified live", SecretCandidate{Class: class, Value: "live", Before: `"`}, true, true, utils.ConfidenceConfirmed,
+			[]string{"context: provider prefix (+1); verified live (HTTP 200) => confirmed"}},
+		{"rejected by the provider", SecretCandidate{Class: class, Value: "dead", Before: `"`}, true, true, utils.ConfidenceTentative,
+			[]string{"; rejected by the provider (HTTP 401) => tentative"}},
+		{"not verified without the flag", SecretCandidate{Class: class, Value: "dead", Before: `"`}, false, true, utils.ConfidenceFirm,
+			[]string{"context: provider prefix (+1) => firm"}},
+	}
+	for _, tt := range tests {
+		var client *http.Client
+		if tt.verify {
+			client = srv.Client()
+		}
+		d := EvaluateSecret(tt.c, client)
+		if d.Keep != tt.keep || d.Confidence != tt.confidence || (d.Verification == utils.SecretVerified) != (tt.confidence == utils.ConfidenceConfirmed) {
+			t.Errorf("%s: %+v, want keep %v, %s", tt.name, d, tt.keep, tt.confidence)
+		}
+		for _, e := range tt.evidence {
+			if !strings.Contains(d.Evidence, e) {
+				t.Errorf("%s: evidence %q lacks %q", tt.name, d.Evidence, e)
+			}
+		}
+	}
+
+	t.Setenv("SECRET_PLACEHOLDERS", "Q3TZ7")
+	if d := EvaluateSecret(SecretCandidate{Class: aws, Value: awsKey}, nil); d.Keep || !strings.Contains(d.Evidence, `placeholder ("q3tz7")`) {
+		t.Errorf("configured placeholder: %+v", d)
+	}
+	t.Setenv("SECRET_MIN_ENTROPY", "4.5")
+	if d := EvaluateSecret(SecretCandidate{Class: generic, Value: genericKey}, nil); d.Keep || !strings.Contains(d.Evidence, "< 4.50") {
+		t.Errorf("configured generic threshold: %+v", d)
+	}
+}
+
+func TestBuiltinSecretFindings(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"0000-main.js": `var a={k:"` + awsKey + `",doc:"` + awsDocKey + `",n:"` + awsLookItUp + `"};`,
+		"0001-util.js": "const apiToken = '" + genericKey + "';\n",
+	}
+	byFile := map[string]string{
+		"0000-main.js": "https://a.example.com/static/main.js",
+		"0001-util.js": "https://a.example.com/static/__tests__/util.js",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var log []string
+	findings, secrets := builtinSecretFindings(dir, byFile, http.DefaultClient, false, func(s string) { log = append(log, s) })
+	if len(findings) != 2 {
+		t.Fatalf("%d finding(s): %+v", len(findings), findings)
+	}
+	aws, generic := findings[0], findings[1]
+	if aws.URL != byFile["0000-main.js"] || aws.Issue != "Exposed Secret: AWS Access Key ID" || aws.Type != "exposed-secret" ||
+		aws.Severity != "high" || aws.Confidence != utils.ConfidenceFirm ||
+		!strings.HasPrefix(aws.Detail, "built-in aws-access-key pattern matched AKIA********XK8M (20 chars) on line 1; entropy ") ||
+		!strings.HasSuffix(aws.Detail, "=> firm") {
+		t.Errorf("AWS finding %+v", aws)
+	}
+	if generic.URL != byFile["0001-util.js"] || generic.Confidence != utils.ConfidenceTentative || !strings.Contains(generic.Detail, "in test code (-1)") {
+		t.Errorf("generic finding %+v", generic)
+	}
+	for _, v := range findings {
+		for _, raw := range []string{awsKey, genericKey} {
+			if strings.Contains(v.Detail, raw) || strings.Contains(v.Issue, raw) {
+				t.Errorf("finding %+v carries the raw secret", v)
+			}
+		}
+	}
+	if len(secrets) != 2 || secrets[0].Class != "aws" || secrets[0].Masked != "AKIA********XK8M (20 chars)" ||
+		secrets[0].Confidence != utils.ConfidenceFirm || secrets[0].Verification != utils.SecretUnverified ||
+		secrets[0].Locations[0] != (types.SecretLocation{Artifact: byFile["0000-main.js"], Location: "line 1", Detector: "built-in aws-access-key"}) ||
+		secrets[1].Class != "generic" || secrets[1].Masked != "9fK2********Nb3s (20 chars)" || secrets[1].Confidence != utils.ConfidenceTentative {
+		t.Errorf("secrets %+v", secrets)
+	}
+	if len(log) != 1 || log[0] != "[*] Built-in secret patterns: 4 candidate(s), 2 dropped as false positives" {
+		t.Errorf("log %q", log)
+	}
+}
@@ -0,0 +1,244 @@
+// scanners/secrets_scanner.go - Secrets in downloaded JavaScript with trufflehog.
+package scanners
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	secretsMaxJS   = 5 << 20
+	secretsWorkers = 10
+	secretsTimeout = 20 * time.Second
+)
+
+// jsNameRe matches the characters left out of a downloaded file's name.
+var jsNameRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// jsFileName names the i-th downloaded script after the last element of its
+// path; the index keeps same-named scripts of different hosts apart.
+func jsFileName(i int, raw string) string {
+	name := "script.js"
+	if u, err := url.Parse(raw); err == nil {
+		if base := jsNameRe.ReplaceAllString(path.Base(u.Path), "_"); base != "" && base != "." && base != "_" {
+			name = base
+		}
+	}
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	return fmt.Sprintf("%04d-%s", i, name)
+}
+
+// downloadJS saves a 200 response of at most secretsMaxJS bytes to file;
+// larger scripts are truncated, which trufflehog still scans.
+func downloadJS(client *http.Client, raw, file string) error {
+	resp, err := client.Get(raw)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, secretsMaxJS))
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, body, 0644)
+}
+
+// builtinSecretFindings runs the built-in patterns over the downloaded
+// scripts, byFile mapping each file's name to its URL, and keeps what the
+// false-positive layers let through, as findings and as secret findings.
+// Candidates are verified with the providers when verify is set.
+func builtinSecretFindings(jsDir string, byFile map[string]string, client *http.Client, verify bool, logFn func(string)) ([]types.VulnerabilityResult, []types.SecretFinding) {
+	var verifyClient *http.Client
+	if verify {
+		verifyClient = client
+	}
+	names := make([]string, 0, len(byFile))
+	for name := range byFile {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var findings []types.VulnerabilityResult
+	var secrets []types.SecretFinding
+	candidates := 0
+	for _, name := range names {
+		data, err := ioutil.ReadFile(filepath.Join(jsDir, name))
+		if err != nil {
+			continue
+		}
+		for _, c := range DetectSecrets(byFile[name], string(data)) {
+			candidates++
+			d := EvaluateSecret(c, verifyClient)
+			if !d.Keep {
+				continue
+			}
+			v := types.VulnerabilityResult{
+				URL:        byFile[name],
+				Issue:      "Exposed Secret: " + c.Class.Label,
+				Type:       "exposed-secret",
+				Detail:     fmt.Sprintf("built-in %s pattern matched %s on line %d; %s", c.Class.Name, utils.MaskSecret(c.Value), c.Line, d.Evidence),
+				Severity:   "high",
+				Confidence: d.Confidence,
+			}
+			if d.Verification == utils.SecretVerified {
+				v.Severity = "critical"
+				v.Tags = utils.AddTag(v.Tags, utils.TagState+":verified")
+			}
+			findings = append(findings, v)
+			loc := types.SecretLocation{Artifact: byFile[name], Location: fmt.Sprintf("line %d", c.Line), Detector: "built-in " + c.Class.Name}
+			secrets = utils.MergeSecrets(secrets, utils.NewSecretFinding(utils.SecretClass(c.Class.Name), c.Value, loc, d.Confidence, d.Verification))
+		}
+	}
+	if candidates > 0 {
+		logFn(fmt.Sprintf("[*] Built-in secret patterns: %d candidate(s), %d dropped as false positives", candidates, candidates-len(findings)))
+	}
+	return findings, secrets
+}
+
+// RunSecretScan downloads the JavaScript files listed in js_files.txt into
+// outDir/js/ and scans them with the built-in secret patterns and, when it
+// is installed, trufflehog. Each finding is reported at the script's URL
+// with the secret redacted, and the findings are written to secrets.json;
+// the secrets are merged into result.Secrets. Secrets are only sent to
+// their providers to tell live ones from dead ones, by the built-in
+// patterns and by trufflehog, when verify is set.
+func RunSecretScan(client *http.Client, outDir string, verify bool, result *types.ScanResult, logFn func(string)) {
+	_, lookErr := exec.LookPath("trufflehog")
+	if lookErr != nil {
+		logFn("[!] trufflehog not found in PATH; only the built-in secret patterns are used")
+	}
+	data, err := ioutil.ReadFile(filepath.Join(outDir, "js_files.txt"))
+	if err != nil {
+		logFn("[!] secret scanning skipped: " + err.Error())
+		return
+	}
+	scripts := utils.UniqueStrings(utils.ReadLines("js_files", string(data)))
+	if len(scripts) == 0 {
+		logFn("[*] No JavaScript files to scan for secrets.")
+		return
+	}
+	jsDir := filepath.Join(outDir, "js")
+	if err := os.MkdirAll(jsDir, 0755); err != nil {
+		logFn("[!] secret scanning skipped: " + err.Error())
+		return
+	}
+	if client.Timeout == 0 {
+		c := *client
+		c.Timeout = secretsTimeout
+		client = &c
+	}
+	logFn(fmt.Sprintf("[*] Downloading %d JavaScript file(s)...", len(scripts)))
+	// byFile maps each downloaded file's name back to its URL.
+	byFile := make(map[string]string, len(scripts))
+	jobs := make(chan int)
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		failed int
+	)
+	for w := 0; w < secretsWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				utils.Guard(func() {
+					name := jsFileName(i, scripts[i])
+					err := downloadJS(client, scripts[i], filepath.Join(jsDir, name))
+					mu.Lock()
+					if err != nil {
+						failed++
+					} else {
+						byFile[name] = scripts[i]
+					}
+					mu.Unlock()
+				})
+			}
+		}()
+	}
+	for i := range scripts {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if failed > 0 {
+		logFn(fmt.Sprintf("[*] %d JavaScript file(s) could not be downloaded", failed))
+	}
+	if len(byFile) == 0 {
+		logFn("[*] No JavaScript files downloaded; nothing to scan for secrets.")
+		return
+	}
+	var findings []types.VulnerabilityResult
+	var secrets []types.SecretFinding
+	if lookErr == nil {
+		logFn(fmt.Sprintf("[*] Running trufflehog over %d JavaScript file(s)...", len(byFile)))
+		args := []string{"filesystem", "--json", "--no-update"}
+		if !verify {
+			args = append(args, "--no-verification")
+		}
+		out, runErr := utils.RunCommand("trufflehog", append(args, jsDir)...)
+		var err error
+		findings, secrets, err = parsers.ParseTrufflehogOutput(out)
+		if runErr != nil {
+			logFn("[!] trufflehog error: " + runErr.Error())
+		}
+		if err != nil {
+			logFn("[!] " + err.Error())
+		}
+		for i := range findings {
+			if u, ok := byFile[filepath.Base(findings[i].URL)]; ok {
+				findings[i].URL = u
+			}
+		}
+		for i := range secrets {
+			for j, loc := range secrets[i].Locations {
+				if u, ok := byFile[filepath.Base(loc.Artifact)]; ok {
+					secrets[i].Locations[j].Artifact = u
+				}
+			}
+		}
+	}
+	builtin, builtinSecrets := builtinSecretFindings(jsDir, byFile, client, verify, logFn)
+	findings = append(findings, builtin...)
+	result.Secrets = utils.MergeSecrets(result.Secrets, append(secrets, builtinSecrets...)...)
+	verified := 0
+	for i := range findings {
+		f := &findings[i]
+		if utils.IsVerifiedSecret(*f) {
+			verified++
+			logFn(fmt.Sprintf("[!] VERIFIED %s: %s", f.Issue, f.URL))
+		} else {
+			logFn(fmt.Sprintf("[!] %s: %s", f.Issue, f.URL))
+		}
+	}
+	// Verified secrets lead secrets.json.
+	sort.SliceStable(findings, func(a, b int) bool {
+		return utils.IsVerifiedSecret(findings[a]) && !utils.IsVerifiedSecret(findings[b])
+	})
+	result.VulnURLs = append(result.VulnURLs, findings...)
+	if findings == nil {
+		findings = []types.VulnerabilityResult{}
+	}
+	report, _ := json.MarshalIndent(findings, "", "  ")
+	_ = ioutil.WriteFile(filepath.Join(outDir, "secrets.json"), report, 0644)
+	logFn(fmt.Sprintf("[*] Secret scanning complete, %d secret(s), %d verified.", len(findings), verified))
+}
@@ -0,0 +1,438 @@
+// scanners/smuggling_scanner.go - Request smuggling and hop-by-hop header indicators on fronted hosts.
+package scanners
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	defaultSmugglingTimeout  = 10 * time.Second
+	defaultSmugglingMaxHosts = 20
+	smugglingWorkers         = 4
+	// smugglingMaxBody is the most of a response body kept for the
+	// reflection test.
+	smugglingMaxBody = 64 << 10
+	// smugglingProbePath is requested by every probe; the root exists on
+	// nearly every host and is the least likely to have side effects.
+	smugglingProbePath = "/"
+)
+
+// ManualVerificationTag marks findings that are indicators only and must
+// be confirmed by hand before they are reported.
+const ManualVerificationTag = utils.TagState + ":manual-verification"
+
+// smugglingSettings reads SMUGGLING_TIMEOUT, how long a probe waits for a
+// response before it counts as delayed, and SMUGGLING_MAX_HOSTS, how many
+// fronted hosts are checked.
+func smugglingSettings() (timeout time.Duration, maxHosts int) {
+	timeout, maxHosts = defaultSmugglingTimeout, defaultSmugglingMaxHosts
+	if d, err := time.ParseDuration(os.Getenv("SMUGGLING_TIMEOUT")); err == nil && d > 0 {
+		timeout = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("SMUGGLING_MAX_HOSTS")); err == nil && n > 0 {
+		maxHosts = n
+	}
+	return timeout, maxHosts
+}
+
+// rawRequest is an HTTP/1.1 request written exactly as given. net/http
+// drops, merges and canonicalizes the conflicting framing headers the
+// probes depend on, so they are written on the connection directly.
+type rawRequest struct {
+	Method, Path, Host string
+	// Headers are "Name: value" lines, written in order after Host.
+	Headers []string
+	Body    string
+}
+
+// String returns the request as sent on the wire.
+func (r rawRequest) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\nHost: %s\r\n", r.Method, r.Path, r.Host)
+	for _, h := range r.Headers {
+		b.WriteString(h + "\r\n")
+	}
+	b.WriteString("\r\n")
+	b.WriteString(r.Body)
+	return b.String()
+}
+
+// rawResponse is what came back for a rawRequest. TimedOut is set when no
+// response arrived before the timeout, Elapsed being the time waited.
+type rawResponse struct {
+	Status   int
+	Header   http.Header
+	Body     string
+	Elapsed  time.Duration
+	TimedOut bool
+}
+
+// smugglingTarget is a fronted origin: where to connect, the Host header
+// and whether to speak TLS.
+type smugglingTarget struct {
+	URL, Addr, Host string
+	TLS             bool
+	// Front names the WAF or CDN the host was identified behind.
+	Front string
+}
+
+// sendRaw writes req on a new connection to t and reads the response
+// head, and up to smugglingMaxBody of the body, within timeout.
+func sendRaw(t smugglingTarget, req rawRequest, timeout time.Duration) (rawResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn, err := DialContext(ctx, "tcp", t.Addr)
+	if err != nil {
+		return rawResponse{}, err
+	}
+	defer conn.Close()
+	if t.TLS {
+		host, _, _ := net.SplitHostPort(t.Addr)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host, InsecureSkipVerify: true, NextProtos: []string{"http/1.1"}})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return rawResponse{}, fmt.Errorf("TLS handshake: %v", err)
+		}
+		conn = tlsConn
+	}
+	if _, err := io.WriteString(conn, req.String()); err != nil {
+		return rawResponse{}, err
+	}
+	start := time.Now()
+	conn.SetReadDeadline(start.Add(timeout))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: req.Method})
+	if err != nil {
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			return rawResponse{Elapsed: time.Since(start), TimedOut: true}, nil
+		}
+		return rawResponse{Elapsed: time.Since(start)}, err
+	}
+	elapsed := time.Since(start)
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, smugglingMaxBody))
+	resp.Body.Close()
+	return rawResponse{Status: resp.StatusCode, Header: resp.Header, Body: string(body), Elapsed: elapsed}, nil
+}
+
+// desyncProbe is one timing probe: a request whose Content-Length and
+// Transfer-Encoding disagree so that only a back end reading it the other
+// way than the front end waits for bytes that never come.
+type desyncProbe struct {
+	Technique   string
+	Explanation string
+	Headers     []string
+	Body        string
+}
+
+var (
+	// desyncControl frames its body the same way whichever header is
+	// honored; it must answer promptly for the probes to mean anything.
+	desyncControl = desyncProbe{
+		Technique: "control",
+		Headers:   []string{"Content-Type: application/x-www-form-urlencoded", "Content-Length: 5", "Transfer-Encoding: chunked", "Connection: close"},
+		Body:      "0\r\n\r\n",
+	}
+	// The probes are sent CL.TE first: the TE.CL probe leaves a byte on
+	// the back-end connection of a CL.TE pair, poisoning the next request,
+	// so it only runs when the CL.TE probe came back normally.
+	desyncProbes = []desyncProbe{
+		{
+			Technique:   "CL.TE",
+			Explanation: "the front end forwarded the 4 bytes its Content-Length announced and the back end, reading the body as chunked, waited for the rest of the chunk",
+			Headers:     []string{"Content-Type: application/x-www-form-urlencoded", "Content-Length: 4", "Transfer-Encoding: chunked", "Connection: close"},
+			Body:        "1\r\nZ\r\nQ",
+		},
+		{
+			Technique:   "TE.CL",
+			Explanation: "the front end forwarded the chunked body up to its terminating chunk and the back end, reading the body by Content-Length, waited for the byte still missing",
+			Headers:     []string{"Content-Type: application/x-www-form-urlencoded", "Content-Length: 6", "Transfer-Encoding: chunked", "Connection: close"},
+			Body:        "0\r\n\r\nX",
+		},
+	}
+)
+
+func (p desyncProbe) request(t smugglingTarget) rawRequest {
+	return rawRequest{Method: http.MethodPost, Path: smugglingProbePath, Host: t.Host, Headers: p.Headers, Body: p.Body}
+}
+
+// checkDesync sends the control and the timing probes to t. A probe that
+// times out twice while the control answered well within the timeout is
+// classified as possible desync behavior. No request ever follows a probe
+// on its connection.
+func checkDesync(t smugglingTarget, timeout time.Duration) ([]types.VulnerabilityResult, error) {
+	control, err := sendRaw(t, desyncControl.request(t), timeout)
+	if err != nil {
+		return nil, err
+	}
+	if control.TimedOut || control.Elapsed > timeout/2 {
+		// A slow host makes timing meaningless.
+		return nil, nil
+	}
+	var findings []types.VulnerabilityResult
+	for _, p := range desyncProbes {
+		req := p.request(t)
+		first, err := sendRaw(t, req, timeout)
+		if err != nil {
+			break
+		}
+		if !first.TimedOut {
+			continue
+		}
+		// Whatever the repeat shows, probing TE.CL after a delayed CL.TE
+		// probe could poison the back end.
+		if second, err := sendRaw(t, req, timeout); err != nil || !second.TimedOut {
+			break
+		}
+		findings = append(findings, types.VulnerabilityResult{
+			URL:        t.URL,
+			Issue:      "Possible HTTP Request Smuggling (" + p.Technique + ")",
+			Type:       "http-desync",
+			Severity:   "low",
+			Confidence: utils.ConfidenceTentative,
+			Technique:  p.Technique,
+			Payload:    req.String(),
+			Detail: fmt.Sprintf("possible desync behavior behind %s: %s. The probe got no response within %s twice, while the control request %q answered %d in %s. Requires manual verification; no follow-up request was sent.",
+				t.Front, p.Explanation, timeout, desyncControl.request(t).String(), control.Status, control.Elapsed.Round(time.Millisecond)),
+			Tags: utils.AddTag(nil, ManualVerificationTag),
+		})
+		break
+	}
+	return findings, nil
+}
+
+// hopStripHeaders are headers front ends add for the back end; declaring
+// one hop-by-hop in Connection makes a compliant front end remove it.
+var hopStripHeaders = []string{"X-Forwarded-For", "X-Real-IP", "X-Forwarded-Host", "X-Forwarded-Proto"}
+
+// hopToken returns a random marker for the reflection test.
+func hopToken() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "hop" + hex.EncodeToString(b)
+}
+
+func hopRequest(t smugglingTarget, headers ...string) rawRequest {
+	return rawRequest{Method: http.MethodGet, Path: smugglingProbePath, Host: t.Host, Headers: append(headers, "User-Agent: Mozilla/5.0", "Accept: */*")}
+}
+
+// checkHopByHop compares the response of a plain request to t with
+// requests declaring a header hop-by-hop. A front end that then strips a
+// header it adds itself and so changes the back end's answer, confirmed
+// by a second request, is low; one that forwards a header declared
+// hop-by-hop, seen by a random value coming back in the response, is
+// info.
+func checkHopByHop(t smugglingTarget, timeout time.Duration) ([]types.VulnerabilityResult, error) {
+	base, err := sendRaw(t, hopRequest(t, "Connection: close"), timeout)
+	if err != nil {
+		return nil, err
+	}
+	if base.TimedOut {
+		return nil, nil
+	}
+	var findings []types.VulnerabilityResult
+	for _, h := range hopStripHeaders {
+		req := hopRequest(t, "Connection: close, "+h)
+		first, err := sendRaw(t, req, timeout)
+		if err != nil || first.TimedOut || first.Status == base.Status {
+			continue
+		}
+		second, err := sendRaw(t, req, timeout)
+		if err != nil || second.Status != first.Status {
+			continue
+		}
+		findings = append(findings, types.VulnerabilityResult{
+			URL:        t.URL,
+			Issue:      "Hop-by-Hop Header Stripping Changes Response",
+			Type:       "hop-by-hop-header",
+			Severity:   "low",
+			Confidence: utils.ConfidenceTentative,
+			Technique:  h,
+			Payload:    req.String(),
+			Detail: fmt.Sprintf("declaring %s hop-by-hop in Connection changed the response behind %s from %d to %d, twice: the front end strips a header the back end relies on, which can bypass IP or host based rules. Requires manual verification.",
+				h, t.Front, base.Status, first.Status),
+			Tags: utils.AddTag(nil, ManualVerificationTag),
+		})
+	}
+
+	token := hopToken()
+	req := hopRequest(t, "Connection: close, X-Hop-Probe", "X-Hop-Probe: "+token)
+	resp, err := sendRaw(t, req, timeout)
+	if err == nil && !resp.TimedOut && reflectsToken(resp, token) {
+		findings = append(findings, types.VulnerabilityResult{
+			URL:        t.URL,
+			Issue:      "Hop-by-Hop Header Forwarded",
+			Type:       "hop-by-hop-header",
+			Severity:   "info",
+			Confidence: utils.ConfidenceTentative,
+			Technique:  "X-Hop-Probe",
+			Payload:    req.String(),
+			Detail: fmt.Sprintf("X-Hop-Probe was declared hop-by-hop in Connection, yet its value came back in the %d response behind %s: the front end forwards headers it should drop. Requires manual verification.",
+				resp.Status, t.Front),
+			Tags: utils.AddTag(nil, ManualVerificationTag),
+		})
+	}
+	return findings, nil
+}
+
+func reflectsToken(resp rawResponse, token string) bool {
+	if strings.Contains(resp.Body, token) {
+		return true
+	}
+	for _, values := range resp.Header {
+		for _, v := range values {
+			if strings.Contains(v, token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cdnMarkers identify a CDN or load balancer from the Server header or
+// a technology fingerprint.
+var cdnMarkers = []struct{ Marker, Name string }{
+	{"cloudflare", "Cloudflare"},
+	{"cloudfront", "Amazon CloudFront"},
+	{"akamai", "Akamai"},
+	{"fastly", "Fastly"},
+	{"varnish", "Varnish"},
+	{"incapsula", "Imperva Incapsula"},
+	{"sucuri", "Sucuri"},
+	{"awselb", "AWS Elastic Load Balancing"},
+	{"envoy", "Envoy"},
+	{"haproxy", "HAProxy"},
+}
+
+// frontedBy names the WAF or CDN in front of lh, or returns "" when
+// neither the WAF detection nor its fingerprints found one.
+func frontedBy(lh types.LiveHost) string {
+	if lh.WAF != "" {
+		return "WAF " + lh.WAF
+	}
+	seen := []string{strings.ToLower(lh.Server)}
+	for _, t := range lh.Technologies {
+		seen = append(seen, strings.ToLower(t.Name))
+	}
+	for _, m := range cdnMarkers {
+		for _, s := range seen {
+			if strings.Contains(s, m.Marker) {
+				return "CDN " + m.Name
+			}
+		}
+	}
+	return ""
+}
+
+// smugglingTargets returns the in-scope origins of the probed hosts
+// identified as fronted by a WAF or CDN.
+func smugglingTargets(result *types.ScanResult, inScope func(string) bool) []smugglingTarget {
+	var targets []smugglingTarget
+	seen := make(map[string]bool)
+	for _, lh := range result.LiveHosts {
+		front := frontedBy(lh)
+		if front == "" || lh.URL == "" || !inScope(lh.URL) {
+			continue
+		}
+		u, err := url.Parse(lh.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || seen[u.Host] {
+			continue
+		}
+		seen[u.Host] = true
+		port := u.Port()
+		if port == "" {
+			port = "80"
+			if u.Scheme == "https" {
+				port = "443"
+			}
+		}
+		targets = append(targets, smugglingTarget{
+			URL:   u.Scheme + "://" + u.Host + smugglingProbePath,
+			Addr:  net.JoinHostPort(u.Hostname(), port),
+			Host:  u.Host,
+			TLS:   u.Scheme == "https",
+			Front: front,
+		})
+	}
+	return targets
+}
+
+// RunSmugglingChecks sends conservative request smuggling timing probes
+// and hop-by-hop header tests to the hosts behind a WAF or CDN, where a
+// front end and a back end may disagree on where a request ends. The
+// findings are indicators of low confidence, tagged for manual
+// verification.
+func RunSmugglingChecks(result *types.ScanResult, inScope func(string) bool, logFn func(string)) {
+	targets := smugglingTargets(result, inScope)
+	if len(targets) == 0 {
+		logFn("[*] No hosts behind a WAF or CDN to check for request smuggling.")
+		return
+	}
+	timeout, maxHosts := smugglingSettings()
+	if len(targets) > maxHosts {
+		logFn(fmt.Sprintf("[*] Checking %d of %d fronted host(s) for request smuggling (SMUGGLING_MAX_HOSTS)", maxHosts, len(targets)))
+		targets = targets[:maxHosts]
+	}
+	logFn(fmt.Sprintf("[*] Checking %d fronted host(s) for request smuggling and hop-by-hop header issues...", len(targets)))
+	jobs := make(chan smugglingTarget)
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		findings []types.VulnerabilityResult
+		failed   int
+	)
+	for w := 0; w < smugglingWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range jobs {
+				utils.Guard(func() {
+					desync, err := checkDesync(t, timeout)
+					if err != nil {
+						mu.Lock()
+						failed++
+						mu.Unlock()
+						return
+					}
+					hop, _ := checkHopByHop(t, timeout)
+					mu.Lock()
+					findings = append(findings, append(desync, hop...)...)
+					mu.Unlock()
+				})
+			}
+		}()
+	}
+	for _, t := range targets {
+		jobs <- t
+	}
+	close(jobs)
+	wg.Wait()
+	if failed > 0 {
+		logFn(fmt.Sprintf("[*] %d fronted host(s) could not be reached for smuggling checks", failed))
+	}
+	// Workers finish in any order; keep the output stable.
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].URL < findings[j].URL })
+	for _, f := range findings {
+		logFn(fmt.Sprintf("[!] %s (needs manual verification): %s", f.Issue, f.URL))
+	}
+	result.VulnURLs = append(result.VulnURLs, findings...)
+	logFn(fmt.Sprintf("[*] Smuggling checks complete, %d indicator(s).", len(findings)))
+}
@@ -0,0 +1,314 @@
+package scanners
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// chunkedLen returns the length of the complete chunked message at the
+// start of body, or ok false when it is cut short; malformed reports a
+// chunk size that is not hex.
+func chunkedLen(body string) (n int, ok, malformed bool) {
+	for {
+		i := strings.Index(body[n:], "\r\n")
+		if i < 0 {
+			return 0, false, false
+		}
+		size, err := strconv.ParseInt(body[n:n+i], 16, 64)
+		if err != nil {
+			return 0, false, true
+		}
+		n += i + 2 + int(size) + 2
+		if n > len(body) {
+			return 0, false, false
+		}
+		if size == 0 {
+			return n, true, false
+		}
+	}
+}
+
+// scriptedFront is a TCP server playing a front end and back end pair.
+// mode picks how they frame a body with both Content-Length and
+// Transfer-Encoding: "cl.te" forwards by length and reads chunked,
+// "te.cl" the other way round, "normal" agrees and "slow" never answers.
+// For GET requests, "strip" answers 403 when X-Forwarded-For is declared
+// hop-by-hop and "reflect" echoes X-Hop-Probe.
+type scriptedFront struct {
+	mode     string
+	mu       sync.Mutex
+	requests []string
+}
+
+func (f *scriptedFront) start(t *testing.T) smugglingTarget {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	addr := ln.Addr().String()
+	return smugglingTarget{URL: "http://" + addr + "/", Addr: addr, Host: addr, Front: "WAF TestWAF"}
+}
+
+func (f *scriptedFront) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewReader(bufio.NewReader(conn))
+	line, err := tp.ReadLine()
+	if err != nil {
+		return
+	}
+	head, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return
+	}
+	// The client writes the whole request at once.
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	rest, _ := ioutil.ReadAll(tp.R)
+	conn.SetReadDeadline(time.Time{})
+	body := string(rest)
+	f.mu.Lock()
+	f.requests = append(f.requests, line+" CL="+head.Get("Content-Length"))
+	f.mu.Unlock()
+
+	hang := func() { io.Copy(ioutil.Discard, conn) }
+	reply := func(status int, text string) {
+		fmt.Fprintf(conn, "HTTP/1.1 %d X\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s", status, len(text), text)
+	}
+	if strings.HasPrefix(line, "GET ") {
+		switch {
+		case f.mode == "strip" && strings.Contains(head.Get("Connection"), "X-Forwarded-For"):
+			reply(403, "forbidden")
+		case f.mode == "reflect":
+			reply(200, "probe="+head.Get("X-Hop-Probe"))
+		default:
+			reply(200, "home")
+		}
+		return
+	}
+	cl, _ := strconv.Atoi(head.Get("Content-Length"))
+	switch f.mode {
+	case "slow":
+		hang()
+	case "cl.te":
+		if cl > len(body) {
+			cl = len(body)
+		}
+		if _, ok, _ := chunkedLen(body[:cl]); !ok {
+			hang()
+			return
+		}
+		reply(200, "ok")
+	case "te.cl":
+		n, ok, malformed := chunkedLen(body)
+		if malformed || !ok {
+			reply(400, "bad chunk")
+			return
+		}
+		if n < cl {
+			hang()
+			return
+		}
+		reply(200, "ok")
+	default:
+		reply(200, "ok")
+	}
+}
+
+func TestRawRequestString(t *testing.T) {
+	req := rawRequest{Method: "POST", Path: "/", Host: "www.example.com", Headers: []string{"Content-Length: 4", "transfer-encoding: chunked"}, Body: "1\r\nZ\r\nQ"}
+	want := "POST / HTTP/1.1\r\nHost: www.example.com\r\nContent-Length: 4\r\ntransfer-encoding: chunked\r\n\r\n1\r\nZ\r\nQ"
+	if got := req.String(); got != want {
+		t.Errorf("request %q, want %q", got, want)
+	}
+}
+
+func TestCheckDesync(t *testing.T) {
+	const timeout = 300 * time.Millisecond
+	tests := []struct {
+		mode      string
+		technique string
+	}{
+		{"cl.te", "CL.TE"},
+		{"te.cl", "TE.CL"},
+		{"normal", ""},
+		// The control timing out makes the host too slow to judge.
+		{"slow", ""},
+	}
+	for _, tt := range tests {
+		front := &scriptedFront{mode: tt.mode}
+		target := front.start(t)
+		findings, err := checkDesync(target, timeout)
+		if err != nil {
+			t.Errorf("%s: %v", tt.mode, err)
+			continue
+		}
+		if tt.technique == "" {
+			if len(findings) != 0 {
+				t.Errorf("%s: findings %+v", tt.mode, findings)
+			}
+			continue
+		}
+		if len(findings) != 1 {
+			t.Errorf("%s: %d finding(s): %+v", tt.mode, len(findings), findings)
+			continue
+		}
+		f := findings[0]
+		if f.Technique != tt.technique || f.Type != "http-desync" || f.Severity != "low" || f.Confidence != utils.ConfidenceTentative ||
+			!utils.HasTag(f.Tags, ManualVerificationTag) || !strings.Contains(f.Detail, "Requires manual verification") ||
+			!strings.Contains(f.Detail, "no response within 300ms twice") || !strings.Contains(f.Detail, "behind WAF TestWAF") {
+			t.Errorf("%s: finding %+v", tt.mode, f)
+		}
+		if !strings.HasPrefix(f.Payload, "POST / HTTP/1.1\r\nHost: "+target.Host+"\r\n") || !strings.Contains(f.Payload, "Transfer-Encoding: chunked") {
+			t.Errorf("%s: payload %q", tt.mode, f.Payload)
+		}
+	}
+}
+
+func TestCheckDesyncSkipsTECLAfterCLTE(t *testing.T) {
+	front := &scriptedFront{mode: "cl.te"}
+	target := front.start(t)
+	if _, err := checkDesync(target, 200*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	front.mu.Lock()
+	defer front.mu.Unlock()
+	// The control, then the CL.TE probe and its repeat; the TE.CL probe
+	// would poison a CL.TE pair.
+	want := []string{"POST / HTTP/1.1 CL=5", "POST / HTTP/1.1 CL=4", "POST / HTTP/1.1 CL=4"}
+	if strings.Join(front.requests, "|") != strings.Join(want, "|") {
+		t.Errorf("requests %q, want %q", front.requests, want)
+	}
+}
+
+func TestCheckHopByHop(t *testing.T) {
+	tests := []struct {
+		mode  string
+		issue string
+		tech  string
+		sev   string
+	}{
+		{"strip", "Hop-by-Hop Header Stripping Changes Response", "X-Forwarded-For", "low"},
+		{"reflect", "Hop-by-Hop Header Forwarded", "X-Hop-Probe", "info"},
+		{"normal", "", "", ""},
+	}
+	for _, tt := range tests {
+		front := &scriptedFront{mode: tt.mode}
+		findings, err := checkHopByHop(front.start(t), time.Second)
+		if err != nil {
+			t.Errorf("%s: %v", tt.mode, err)
+			continue
+		}
+		if tt.issue == "" {
+			if len(findings) != 0 {
+				t.Errorf("%s: findings %+v", tt.mode, findings)
+			}
+			continue
+		}
+		if len(findings) != 1 {
+			t.Errorf("%s: %d finding(s): %+v", tt.mode, len(findings), findings)
+			continue
+		}
+		f := findings[0]
+		if f.Issue != tt.issue || f.Technique != tt.tech || f.Severity != tt.sev || f.Type != "hop-by-hop-header" ||
+			!utils.HasTag(f.Tags, ManualVerificationTag) || !strings.Contains(f.Payload, "Connection: close, "+tt.tech) {
+			t.Errorf("%s: finding %+v", tt.mode, f)
+		}
+	}
+}
+
+func TestFrontedBy(t *testing.T) {
+	tests := []struct {
+		lh   types.LiveHost
+		want string
+	}{
+		{types.LiveHost{WAF: "Cloudflare (Cloudflare Inc.)", Server: "cloudflare"}, "WAF Cloudflare (Cloudflare Inc.)"},
+		{types.LiveHost{Server: "CloudFront"}, "CDN Amazon CloudFront"},
+		{types.LiveHost{Server: "nginx", Technologies: []types.Technology{{Name: "Varnish"}}}, "CDN Varnish"},
+		{types.LiveHost{Server: "nginx/1.25.3"}, ""},
+	}
+	for _, tt := range tests {
+		if got := frontedBy(tt.lh); got != tt.want {
+			t.Errorf("frontedBy(%+v) = %q, want %q", tt.lh, got, tt.want)
+		}
+	}
+}
+
+func TestSmugglingTargets(t *testing.T) {
+	result := &types.ScanResult{LiveHosts: []types.LiveHost{
+		{Hostname: "www.example.com", URL: "https://www.example.com", WAF: "ModSecurity"},
+		{Hostname: "www.example.com", URL: "https://www.example.com", WAF: "ModSecurity"},
+		{Hostname: "cdn.example.com", URL: "http://cdn.example.com:8080", Server: "AkamaiGHost"},
+		{Hostname: "origin.example.com", URL: "https://origin.example.com", Server: "nginx"},
+		{Hostname: "other.test", URL: "https://other.test", WAF: "ModSecurity"},
+	}}
+	inScope := func(u string) bool { return strings.Contains(u, "example.com") }
+	got := smugglingTargets(result, inScope)
+	want := []smugglingTarget{
+		{URL: "https://www.example.com/", Addr: "www.example.com:443", Host: "www.example.com", TLS: true, Front: "WAF ModSecurity"},
+		{URL: "http://cdn.example.com:8080/", Addr: "cdn.example.com:8080", Host: "cdn.example.com:8080", Front: "CDN Akamai"},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("targets %+v, want %+v", got, want)
+	}
+}
+
+func TestRunSmugglingChecks(t *testing.T) {
+	t.Setenv("SMUGGLING_TIMEOUT", "300ms")
+	front := &scriptedFront{mode: "cl.te"}
+	target := front.start(t)
+	result := &types.ScanResult{LiveHosts: []types.LiveHost{
+		{Hostname: "127.0.0.1", URL: "http://" + target.Addr, WAF: "TestWAF"},
+	}}
+	var logs []string
+	RunSmugglingChecks(result, func(string) bool { return true }, func(s string) { logs = append(logs, s) })
+	if len(result.VulnURLs) != 1 || result.VulnURLs[0].Technique != "CL.TE" {
+		t.Fatalf("findings %+v", result.VulnURLs)
+	}
+	joined := strings.Join(logs, "\n")
+	if !strings.Contains(joined, "[!] Possible HTTP Request Smuggling (CL.TE) (needs manual verification): http://"+target.Addr+"/") ||
+		!strings.Contains(joined, "Smuggling checks complete, 1 indicator(s).") {
+		t.Errorf("log:\n%s", joined)
+	}
+
+	// Hosts without a WAF or CDN are not probed.
+	logs = nil
+	RunSmugglingChecks(&types.ScanResult{LiveHosts: []types.LiveHost{{URL: "http://" + target.Addr}}}, func(string) bool { return true }, func(s string) { logs = append(logs, s) })
+	if len(logs) != 1 || !strings.Contains(logs[0], "No hosts behind a WAF or CDN") {
+		t.Errorf("log %q", logs)
+	}
+}
+
+func TestSmugglingSettings(t *testing.T) {
+	t.Setenv("SMUGGLING_TIMEOUT", "")
+	t.Setenv("SMUGGLING_MAX_HOSTS", "")
+	if timeout, hosts := smugglingSettings(); timeout != defaultSmugglingTimeout || hosts != defaultSmugglingMaxHosts {
+		t.Errorf("defaults %v, %d", timeout, hosts)
+	}
+	t.Setenv("SMUGGLING_TIMEOUT", "3s")
+	t.Setenv("SMUGGLING_MAX_HOSTS", "5")
+	if timeout, hosts := smugglingSettings(); timeout != 3*time.Second || hosts != 5 {
+		t.Errorf("settings %v, %d", timeout, hosts)
+	}
+}
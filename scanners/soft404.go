@@ -0,0 +1,191 @@
+// scanners/soft404.go - Soft-404 fingerprints and the hits they clean up.
+package scanners
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"math/bits"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+const (
+	// soft404MaxDistance is how many bits of the body hashes two pages may
+	// differ in and still count as the same page.
+	soft404MaxDistance = 10
+	// soft404MinSlack is the least a length may stray outside the
+	// fingerprint's band; bands widen by a tenth of their length beyond it.
+	soft404MinSlack = 32
+)
+
+// soft404Volatile matches what changes between loads of one page: numbers
+// such as timestamps and counters, and long hex strings such as request
+// IDs and nonces.
+var soft404Volatile = regexp.MustCompile(`[0-9a-f]{16,}|[0-9]+`)
+
+// soft404Page is one answer to a path, normalized for comparison.
+type soft404Page struct {
+	status      int
+	length      int
+	title       string
+	hash        uint64
+	reflections int
+}
+
+// newSoft404Page normalizes the answer to path: the path, wherever the page
+// echoes it, and volatile content are left out.
+func newSoft404Page(status int, body []byte, path string) soft404Page {
+	path = strings.TrimPrefix(path, "/")
+	text := string(body)
+	p := soft404Page{status: status, length: len(text)}
+	if path != "" {
+		p.reflections = strings.Count(text, path)
+		p.length -= p.reflections * len(path)
+		text = strings.ReplaceAll(text, path, "")
+	}
+	p.title = pageTitle([]byte(text))
+	p.hash = simHash(soft404Volatile.ReplaceAllString(strings.ToLower(text), "0"))
+	return p
+}
+
+// simHash returns the 64-bit similarity hash of the words of text: each
+// bit is set when most words' hashes have it set, so a few changed words
+// flip only a few bits.
+func simHash(text string) uint64 {
+	var weights [64]int
+	for _, word := range strings.Fields(text) {
+		h := fnv.New64a()
+		h.Write([]byte(word))
+		sum := h.Sum64()
+		for i := range weights {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	var hash uint64
+	for i, w := range weights {
+		if w > 0 {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// soft404Slack is how far outside fp's length band a page may be.
+func soft404Slack(fp *types.Soft404) int {
+	if slack := fp.MaxLength / 10; slack > soft404MinSlack {
+		return slack
+	}
+	return soft404MinSlack
+}
+
+// inSoft404Band reports whether length is within fp's band, give or take
+// its slack.
+func inSoft404Band(fp *types.Soft404, length int) bool {
+	slack := soft404Slack(fp)
+	return length >= fp.MinLength-slack && length <= fp.MaxLength+slack
+}
+
+// fetchSoft404Page GETs base+path with client and normalizes the answer.
+func fetchSoft404Page(client *http.Client, base, path string) (soft404Page, error) {
+	resp, err := client.Get(base + path)
+	if err != nil {
+		return soft404Page{}, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, httpProbeMaxBody))
+	if err != nil {
+		return soft404Page{}, err
+	}
+	return newSoft404Page(resp.StatusCode, body, path), nil
+}
+
+// FingerprintSoft404 requests two random paths on base with client, which
+// should not follow redirects, and fingerprints the answer. It returns nil
+// when the origin answers them with 404 or 410, as it should, or when the
+// two answers differ too much to tell a missing page by.
+func FingerprintSoft404(client *http.Client, base string) *types.Soft404 {
+	var pages [2]soft404Page
+	for i := range pages {
+		p, err := fetchSoft404Page(client, base, "/"+newReflectionMarker())
+		if err != nil || p.status == http.StatusNotFound || p.status == http.StatusGone {
+			return nil
+		}
+		pages[i] = p
+	}
+	a, b := pages[0], pages[1]
+	if a.status != b.status || bits.OnesCount64(a.hash^b.hash) > soft404MaxDistance {
+		return nil
+	}
+	fp := &types.Soft404{Status: a.status, MinLength: a.length, MaxLength: b.length, BodyHash: a.hash, Reflections: a.reflections}
+	if fp.MinLength > fp.MaxLength {
+		fp.MinLength, fp.MaxLength = fp.MaxLength, fp.MinLength
+	}
+	if a.title == b.title {
+		fp.Title = a.title
+	}
+	return fp
+}
+
+// MatchesSoft404 reports whether the answer to path looks like fp's page:
+// the same status, a length in its band, the same title and a body hash a
+// few bits from its own.
+func MatchesSoft404(fp *types.Soft404, status int, body []byte, path string) bool {
+	if fp == nil || status != fp.Status {
+		return false
+	}
+	p := newSoft404Page(status, body, path)
+	return inSoft404Band(fp, p.length) && (fp.Title == "" || p.title == fp.Title) &&
+		bits.OnesCount64(p.hash^fp.BodyHash) <= soft404MaxDistance
+}
+
+// soft404Entry reports whether a fuzzing entry looks like fp's page. Only
+// its status and size are known, so the size is compared after allowing
+// for the page echoing the fuzzed path as often as fp's page did.
+func soft404Entry(fp *types.Soft404, e types.FfufResult) bool {
+	if fp == nil || e.Status != fp.Status {
+		return false
+	}
+	return inSoft404Band(fp, e.Size-fp.Reflections*len(strings.TrimPrefix(e.Path, "/")))
+}
+
+// Soft404For returns the soft-404 fingerprint the HTTP probe recorded for
+// the origin at base, if any.
+func Soft404For(result *types.ScanResult, base string) *types.Soft404 {
+	for _, lh := range result.LiveHosts {
+		if lh.URL == base {
+			return lh.Soft404
+		}
+	}
+	return nil
+}
+
+// dropSoft404 removes the entries that look like fp's page: what the
+// fuzzer took for a hit is the origin's way of saying not found.
+func dropSoft404(entries []types.FfufResult, fp *types.Soft404) (kept []types.FfufResult, dropped int) {
+	if fp == nil {
+		return entries, 0
+	}
+	for _, e := range entries {
+		if soft404Entry(fp, e) {
+			dropped++
+			continue
+		}
+		kept = append(kept, e)
+	}
+	return kept, dropped
+}
+
+// soft404Note formats the log line for n hits on base reclassified as not
+// found by what.
+func soft404Note(base, what string, n int) string {
+	return fmt.Sprintf("[*] %s: %d %s hit(s) matched the soft-404 page and were reclassified as not found", base, n, what)
+}
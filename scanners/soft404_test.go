@@ -0,0 +1,166 @@
+package scanners
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// soft404Site answers every path but those in pages with a 200 "not found"
+// page that echoes the path and carries a request counter, a timestamp and
+// a request ID, as framework error pages do.
+func soft404Site(pages map[string]string) *httptest.Server {
+	var requests int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		if body, ok := pages[r.URL.Path]; ok {
+			fmt.Fprint(w, body)
+			return
+		}
+		id := make([]byte, 16)
+		rand.Read(id)
+		fmt.Fprintf(w, `<!doctype html><html><head><title>Page not found | Acme</title></head>
+<body><div class="nav"><a href="/">Home</a> <a href="/shop">Shop</a> <a href="/contact">Contact</a></div>
+<h1>Sorry, we could not find %s</h1><p>The page you are looking for was moved, removed or never existed.</p>
+<footer>Request %d served at %s, id %s</footer></body></html>`,
+			r.URL.Path, n, time.Now().Format(time.RFC3339Nano), hex.EncodeToString(id))
+	}))
+}
+
+const hiddenAdminPage = `<!doctype html><html><head><title>Admin console</title></head>
+<body><form method="post" action="/admin/login"><label>Username <input name="user"></label>
+<label>Password <input type="password" name="pass"></label><button>Sign in</button></form></body></html>`
+
+func TestFingerprintSoft404(t *testing.T) {
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	var calls int32
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		status  int
+		title   string
+	}{
+		{"plain 404", http.NotFound, 0, ""},
+		{"gone", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusGone) }, 0, ""},
+		{"redirect to the home page", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/", http.StatusFound)
+		}, http.StatusFound, ""},
+		// Two unrelated pages say nothing about what a missing page looks like.
+		{"unrelated answers", func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1)%2 == 1 {
+				fmt.Fprint(w, "<title>Blog</title> latest posts about gardening tomatoes soil compost watering schedules and pests")
+			} else {
+				fmt.Fprint(w, hiddenAdminPage)
+			}
+		}, 0, ""},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(tt.handler)
+		fp := FingerprintSoft404(noRedirect, srv.URL)
+		srv.Close()
+		if tt.status == 0 {
+			if fp != nil {
+				t.Errorf("%s: fingerprint %+v", tt.name, fp)
+			}
+			continue
+		}
+		if fp == nil || fp.Status != tt.status || fp.Title != tt.title {
+			t.Errorf("%s: fingerprint %+v", tt.name, fp)
+		}
+	}
+
+	srv := soft404Site(nil)
+	defer srv.Close()
+	fp := FingerprintSoft404(noRedirect, srv.URL)
+	if fp == nil {
+		t.Fatal("no fingerprint of the soft-404 page")
+	}
+	if fp.Status != 200 || fp.Title != "Page not found | Acme" || fp.Reflections != 1 || fp.MinLength > fp.MaxLength || fp.MinLength < 400 {
+		t.Errorf("fingerprint %+v", fp)
+	}
+
+	// The probe records it on the origin.
+	lh := probeOrigin(noRedirect, "127.0.0.1", listenerPort(t, srv), nil, "http")
+	if lh.Soft404 == nil || lh.Soft404.Title != fp.Title {
+		t.Errorf("probed origin %+v", lh)
+	}
+}
+
+// TestSoft404Reclassification fingerprints a soft-404 site with one real
+// hidden page and checks which fuzzing and exposure hits are kept.
+func TestSoft404Reclassification(t *testing.T) {
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	srv := soft404Site(map[string]string{"/admin": hiddenAdminPage})
+	defer srv.Close()
+	fp := FingerprintSoft404(noRedirect, srv.URL)
+	if fp == nil {
+		t.Fatal("no fingerprint of the soft-404 page")
+	}
+
+	// Entries as a fuzzer reports them: status and size of each answer.
+	var entries []types.FfufResult
+	for _, path := range []string{"admin", "backup", "old-site-2019", "wp-content/uploads/2021/03/a-rather-long-file-name.zip"} {
+		resp, err := noRedirect.Get(srv.URL + "/" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want := path == "admin"; MatchesSoft404(fp, resp.StatusCode, body, "/"+path) == want {
+			t.Errorf("/%s: MatchesSoft404 = %v", path, !want)
+		}
+		entries = append(entries, types.FfufResult{Path: path, Status: resp.StatusCode, Size: len(body), URL: srv.URL + "/" + path})
+	}
+	// An answer of the page's size with another status is something else.
+	entries = append(entries, types.FfufResult{Path: "backup", Status: 403, Size: entries[1].Size})
+	kept, dropped := dropSoft404(entries, fp)
+	if dropped != 3 || len(kept) != 2 || kept[0].Path != "admin" || kept[1].Status != 403 {
+		t.Errorf("dropSoft404 kept %+v, dropped %d", kept, dropped)
+	}
+	if kept, dropped := dropSoft404(entries, nil); dropped != 0 || len(kept) != len(entries) {
+		t.Errorf("dropSoft404 without a fingerprint kept %d, dropped %d", len(kept), dropped)
+	}
+
+	result := &types.ScanResult{LiveHosts: []types.LiveHost{{Hostname: "127.0.0.1", URL: srv.URL, Soft404: fp}}}
+	if Soft404For(result, srv.URL) != fp || Soft404For(result, "https://other.example.com") != nil {
+		t.Error("Soft404For did not find the origin's fingerprint")
+	}
+}
+
+// TestCheckExposuresSoft404 checks that a catch-all page that happens to
+// match a signature is reclassified, while a real exposure on a soft-404
+// site is still reported.
+func TestCheckExposuresSoft404(t *testing.T) {
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	catchAll := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ref: refs/heads/main\n")
+	}))
+	defer catchAll.Close()
+	fp := FingerprintSoft404(noRedirect, catchAll.URL)
+	if fp == nil {
+		t.Fatal("no fingerprint of the catch-all page")
+	}
+	if found, _, notFound := CheckExposures(noRedirect, catchAll.URL, fp); len(found) != 0 || notFound != 1 {
+		t.Errorf("catch-all: findings %+v, %d reclassified", found, notFound)
+	}
+	if found, _, _ := CheckExposures(noRedirect, catchAll.URL, nil); len(found) != 1 {
+		t.Errorf("catch-all without a fingerprint: findings %+v", found)
+	}
+
+	site := soft404Site(map[string]string{"/.git/HEAD": "ref: refs/heads/main\n"})
+	defer site.Close()
+	fp = FingerprintSoft404(noRedirect, site.URL)
+	found, _, notFound := CheckExposures(noRedirect, site.URL, fp)
+	if len(found) != 1 || !strings.HasSuffix(found[0].URL, "/.git/HEAD") || notFound != 0 {
+		t.Errorf("soft-404 site: findings %+v, %d reclassified", found, notFound)
+	}
+}
@@ -0,0 +1,360 @@
+// scanners/spa_routes.go - Client-side routes of single-page apps, extracted from their JavaScript bundles.
+package scanners
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	defaultSPAMaxFiles    = 100
+	defaultSPAMaxBundleMB = 5
+	defaultSPAFileTimeout = 5 * time.Second
+	spaWorkers            = 4
+	// spaMaxRoutesPerFile bounds the routes kept from one bundle.
+	spaMaxRoutesPerFile = 500
+	// Bundles are searched spaChunk bytes at a time, the chunks overlapping
+	// by spaOverlap so a route definition across a boundary is still seen,
+	// and the per-file deadline is checked between chunks.
+	spaChunk   = 256 << 10
+	spaOverlap = 1 << 10
+	// spaMinRoute and spaMaxRoute bound the length of a plausible route.
+	spaMinRoute = 2
+	spaMaxRoute = 120
+)
+
+// spaSettings reads SPA_MAX_FILES, how many bundles are searched,
+// SPA_MAX_BUNDLE_MB, how much of each is read, and SPA_FILE_TIMEOUT, how
+// long the search of one bundle may take.
+func spaSettings() (maxFiles int, maxBytes int64, timeout time.Duration) {
+	maxFiles, maxBytes, timeout = defaultSPAMaxFiles, defaultSPAMaxBundleMB<<20, defaultSPAFileTimeout
+	if n, err := strconv.Atoi(os.Getenv("SPA_MAX_FILES")); err == nil && n > 0 {
+		maxFiles = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("SPA_MAX_BUNDLE_MB")); err == nil && n > 0 {
+		maxBytes = int64(n) << 20
+	}
+	if d, err := time.ParseDuration(os.Getenv("SPA_FILE_TIMEOUT")); err == nil && d > 0 {
+		timeout = d
+	}
+	return maxFiles, maxBytes, timeout
+}
+
+// spaRouter is a client-side routing library: the strings that show a
+// bundle uses it and the patterns of its route definitions, whose first
+// group is the route. Relative routes, as Angular writes them, are taken
+// from the root.
+type spaRouter struct {
+	Name     string
+	Markers  []string
+	Patterns []*regexp.Regexp
+	Relative bool
+}
+
+var spaRouters = []spaRouter{
+	{
+		Name:    "React Router",
+		Markers: []string{"react-router", "createBrowserRouter", "createHashRouter", "useNavigate"},
+		// Route objects and compiled <Route path="..."> elements.
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`\bpath\s*:\s*["'](/[^"'\s]*)["']`)},
+	},
+	{
+		Name:     "Angular Router",
+		Markers:  []string{"@angular/router", "RouterModule.forRoot", "RouterModule.forChild", "provideRouter"},
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`\{\s*path\s*:\s*["']([^"'\s]*)["']\s*,\s*(?:component|loadChildren|loadComponent|redirectTo|children|canActivate|pathMatch)\b`)},
+		Relative: true,
+	},
+	{
+		Name:     "Vue Router",
+		Markers:  []string{"vue-router", "createWebHistory", "createWebHashHistory", "VueRouter"},
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`\bpath\s*:\s*["'](/[^"'\s]*)["']\s*,\s*(?:name|component|components|redirect|children|meta|props|alias|beforeEnter)\b`)},
+	},
+	{
+		Name:     "History API",
+		Markers:  []string{"history.pushState", "history.replaceState"},
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`history\.(?:push|replace)State\([^,()]*,\s*[^,()]*,\s*["'](/[^"'\s]*)["']`)},
+	},
+}
+
+var (
+	// spaRouteRe matches the characters a plausible route is made of.
+	spaRouteRe = regexp.MustCompile(`^/[A-Za-z0-9/_.~:%()?+=&\\-]*$`)
+	// spaParamRe matches a route parameter such as :id, :id? or :id(\d+).
+	spaParamRe = regexp.MustCompile(`^:[A-Za-z_][A-Za-z0-9_]*(\([^/]*\))?[?+*]?$`)
+	// spaStaticExts are extensions of assets rather than routes.
+	spaStaticExts = map[string]bool{".js": true, ".mjs": true, ".css": true, ".map": true, ".png": true, ".jpg": true, ".jpeg": true,
+		".gif": true, ".svg": true, ".ico": true, ".webp": true, ".woff": true, ".woff2": true, ".ttf": true, ".eot": true}
+)
+
+// plausibleSPARoute turns a route definition into a path to request, or
+// reports that it does not look like a route: it must start with a slash
+// (relative ones are taken from the root), hold no spaces or template
+// syntax, stay within the length bounds and not name a static asset. The
+// query and fragment are dropped, parameters such as :id filled in with 1
+// and wildcard routes left out.
+func plausibleSPARoute(raw string, relative bool) (string, bool) {
+	route := strings.TrimSpace(raw)
+	if i := strings.Index(route, "#"); i >= 0 {
+		route = route[:i]
+	}
+	if relative && !strings.HasPrefix(route, "/") {
+		route = "/" + route
+	}
+	if len(route) > spaMaxRoute || strings.HasPrefix(route, "//") || !spaRouteRe.MatchString(route) {
+		return "", false
+	}
+	var segments []string
+	for _, seg := range strings.Split(route, "/") {
+		if strings.HasPrefix(seg, ":") {
+			if !spaParamRe.MatchString(seg) {
+				return "", false
+			}
+			segments = append(segments, "1")
+			continue
+		}
+		// Outside a parameter, ? starts the query.
+		if i := strings.Index(seg, "?"); i >= 0 {
+			segments = append(segments, seg[:i])
+			break
+		}
+		if strings.ContainsAny(seg, `*()+\`) {
+			return "", false
+		}
+		segments = append(segments, seg)
+	}
+	route = strings.Join(segments, "/")
+	if len(route) < spaMinRoute || spaStaticExts[strings.ToLower(path.Ext(route))] {
+		return "", false
+	}
+	return route, true
+}
+
+// ExtractSPARoutes finds the routers a bundle uses and the routes they
+// define, searching the bundle in chunks until deadline. timedOut reports
+// that the deadline cut the search short.
+func ExtractSPARoutes(bundle string, deadline time.Time) (routers, routes []string, timedOut bool) {
+	seen := make(map[string]bool)
+	for _, r := range spaRouters {
+		used := false
+		for _, m := range r.Markers {
+			if strings.Contains(bundle, m) {
+				used = true
+				break
+			}
+		}
+		if !used {
+			continue
+		}
+		routers = append(routers, r.Name)
+		for start := 0; start < len(bundle); start += spaChunk {
+			if time.Now().After(deadline) {
+				return routers, routes, true
+			}
+			end := start + spaChunk + spaOverlap
+			if end > len(bundle) {
+				end = len(bundle)
+			}
+			for _, p := range r.Patterns {
+				for _, m := range p.FindAllStringSubmatch(bundle[start:end], -1) {
+					route, ok := plausibleSPARoute(m[1], r.Relative)
+					if !ok || seen[route] {
+						continue
+					}
+					if len(routes) >= spaMaxRoutesPerFile {
+						return routers, routes, false
+					}
+					seen[route] = true
+					routes = append(routes, route)
+				}
+			}
+		}
+	}
+	return routers, routes, false
+}
+
+// spaOrigin returns the origin the routes of the bundle at jsURL belong
+// to: the probed origin of the bundle's host, or else the bundle's own.
+func spaOrigin(result *types.ScanResult, jsURL string) (origin, host string) {
+	js, err := url.Parse(jsURL)
+	if err != nil {
+		return "", ""
+	}
+	for _, lh := range result.LiveHosts {
+		if strings.EqualFold(lh.Hostname, js.Hostname()) && lh.URL != "" && !isAltPort(lh) {
+			if u, err := url.Parse(lh.URL); err == nil && u.Host != "" {
+				return u.Scheme + "://" + u.Host, lh.Hostname
+			}
+		}
+	}
+	return js.Scheme + "://" + js.Host, js.Hostname()
+}
+
+// fetchBundle GETs a 200 response of at most maxBytes bytes; truncated
+// reports that the bundle was larger.
+func fetchBundle(client *http.Client, raw string, maxBytes int64) (body []byte, truncated bool, err error) {
+	resp, err := client.Get(raw)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	body, err = ioutil.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if int64(len(body)) > maxBytes {
+		body, truncated = body[:maxBytes], true
+	}
+	return body, truncated, err
+}
+
+// RunSPARoutes searches the JavaScript bundles the URL scan collected for
+// client-side routers and the routes they define, which crawlers never
+// request. Each route is joined to the origin of the bundle's host; the
+// in-scope URLs not already known join result.AllURLs, where the URL
+// verification and the active checks pick them up, and are recorded in
+// result.URLRecords with source "spa-routes" and the bundle as Referrer.
+// The routers found are added to the technologies of the bundle's host.
+// Every route found is written to spa_routes.txt, tab-separated from its
+// bundle.
+func RunSPARoutes(client *http.Client, outDir string, result *types.ScanResult, inScope func(string) bool, logFn func(string)) {
+	files := jsFileURLs(outDir, result, inScope)
+	if len(files) == 0 {
+		logFn("[*] No JavaScript files to search for client-side routes.")
+		return
+	}
+	maxFiles, maxBytes, timeout := spaSettings()
+	if len(files) > maxFiles {
+		logFn(fmt.Sprintf("[*] Searching %d of %d JavaScript file(s) for client-side routes (SPA_MAX_FILES)", maxFiles, len(files)))
+		files = files[:maxFiles]
+	}
+	if client.Timeout == 0 {
+		c := *client
+		c.Timeout = secretsTimeout
+		client = &c
+	}
+	type bundleRoutes struct {
+		js, origin, host string
+		routers, routes  []string
+	}
+	jobs := make(chan string)
+	var (
+		mu               sync.Mutex
+		wg               sync.WaitGroup
+		found            []bundleRoutes
+		truncated, slow  int
+		failed, searched int
+	)
+	for w := 0; w < spaWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for js := range jobs {
+				utils.Guard(func() {
+					body, cut, err := fetchBundle(client, js, maxBytes)
+					if err != nil {
+						mu.Lock()
+						failed++
+						mu.Unlock()
+						return
+					}
+					routers, routes, timedOut := ExtractSPARoutes(string(body), time.Now().Add(timeout))
+					origin, host := spaOrigin(result, js)
+					mu.Lock()
+					defer mu.Unlock()
+					searched++
+					if cut {
+						truncated++
+					}
+					if timedOut {
+						slow++
+					}
+					if len(routers) > 0 {
+						found = append(found, bundleRoutes{js: js, origin: origin, host: host, routers: routers, routes: routes})
+					}
+				})
+			}
+		}()
+	}
+	for _, f := range files {
+		jobs <- f
+	}
+	close(jobs)
+	wg.Wait()
+	if failed > 0 {
+		logFn(fmt.Sprintf("[*] %d JavaScript file(s) could not be downloaded for route extraction", failed))
+	}
+	if truncated > 0 {
+		logFn(fmt.Sprintf("[*] %d bundle(s) larger than SPA_MAX_BUNDLE_MB were only partly searched", truncated))
+	}
+	if slow > 0 {
+		logFn(fmt.Sprintf("[!] %d bundle(s) hit SPA_FILE_TIMEOUT before their search finished", slow))
+	}
+
+	// Workers finish in any order; keep the output stable.
+	sort.Slice(found, func(i, j int) bool { return found[i].js < found[j].js })
+	seen := make(map[string]bool, len(result.AllURLs))
+	for _, u := range result.AllURLs {
+		seen[urlKey(u)] = true
+	}
+	var lines []string
+	routerHosts := make(map[string][]string)
+	distinct := make(map[string]bool)
+	added, dropped := 0, 0
+	for _, b := range found {
+		routerHosts[b.host] = append(routerHosts[b.host], b.routers...)
+		for _, route := range b.routes {
+			u := b.origin + route
+			lines = append(lines, u+"\t"+b.js)
+			distinct[u] = true
+			if !inScope(u) {
+				dropped++
+				continue
+			}
+			if key := urlKey(u); !seen[key] {
+				seen[key] = true
+				result.AllURLs = append(result.AllURLs, u)
+				result.URLRecords = append(result.URLRecords, types.URLRecord{URL: u, Source: "spa-routes", Referrer: b.js})
+				added++
+			}
+		}
+	}
+	for i := range result.LiveHosts {
+		lh := &result.LiveHosts[i]
+		for _, r := range routerHosts[lh.Hostname] {
+			if !hasTechnology(lh.Technologies, r) {
+				lh.Technologies = append(lh.Technologies, types.Technology{Name: r, String: "client-side routing"})
+			}
+		}
+	}
+	if dropped > 0 {
+		logFn(fmt.Sprintf("[*] SPA routes: dropped %d out-of-scope URL(s)", dropped))
+	}
+	if err := utils.WriteLines(utils.UniqueStrings(lines), filepath.Join(outDir, "spa_routes.txt")); err != nil {
+		logFn("[!] SPA route extraction error: " + err.Error())
+	}
+	logFn(fmt.Sprintf("[*] Searched %d bundle(s): %d use client-side routing, %d route(s) found, %d new.", searched, len(found), len(distinct), added))
+}
+
+func hasTechnology(techs []types.Technology, name string) bool {
+	for _, t := range techs {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
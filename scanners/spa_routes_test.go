@@ -0,0 +1,189 @@
+package scanners
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func readSPAFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "spa", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestExtractSPARoutes(t *testing.T) {
+	tests := []struct {
+		fixture string
+		routers []string
+		routes  []string
+	}{
+		// Wildcards, assets and template strings are left out.
+		{"react.js", []string{"React Router"}, []string{"/dashboard", "/admin/users/1", "/reports/1/1", "/login"}},
+		// Angular routes are relative to the root.
+		{"angular.js", []string{"Angular Router"}, []string{"/orders", "/orders/1", "/admin", "/legacy"}},
+		// A path key outside a route definition is not a route.
+		{"vue.js", []string{"Vue Router"}, []string{"/projects/1", "/settings/billing", "/old-settings"}},
+		// Query and fragment are dropped; other origins are not routes.
+		{"pushstate.js", []string{"History API"}, []string{"/billing/invoices", "/billing/export"}},
+	}
+	for _, tt := range tests {
+		routers, routes, timedOut := ExtractSPARoutes(readSPAFixture(t, tt.fixture), time.Now().Add(time.Minute))
+		if !reflect.DeepEqual(routers, tt.routers) || !reflect.DeepEqual(routes, tt.routes) || timedOut {
+			t.Errorf("%s: routers %q, routes %q, timed out %v; want %q, %q", tt.fixture, routers, routes, timedOut, tt.routers, tt.routes)
+		}
+	}
+
+	// Without a router's markers its patterns are not searched.
+	if routers, routes, _ := ExtractSPARoutes(`var x={path:"/api/items"};`, time.Now().Add(time.Minute)); routers != nil || routes != nil {
+		t.Errorf("unmarked bundle: routers %q, routes %q", routers, routes)
+	}
+}
+
+func TestExtractSPARoutesLargeBundle(t *testing.T) {
+	// Routes spread over many chunks, one across a chunk boundary, and
+	// more of them than are kept from one file.
+	var b strings.Builder
+	b.WriteString("/* react-router */")
+	b.WriteString(strings.Repeat(" ", spaChunk-len(`{path:"/across`)-b.Len()))
+	b.WriteString(`{path:"/across-boundary",element:null}`)
+	for i := 0; i < spaMaxRoutesPerFile+50; i++ {
+		fmt.Fprintf(&b, `{path:"/section-%d",element:null}%s`, i, strings.Repeat(";", 2000))
+	}
+	routers, routes, timedOut := ExtractSPARoutes(b.String(), time.Now().Add(time.Minute))
+	if len(routers) != 1 || timedOut {
+		t.Fatalf("routers %q, timed out %v", routers, timedOut)
+	}
+	if len(routes) != spaMaxRoutesPerFile || routes[0] != "/across-boundary" || routes[len(routes)-1] != fmt.Sprintf("/section-%d", spaMaxRoutesPerFile-2) {
+		t.Errorf("%d route(s), first %q, last %q", len(routes), routes[0], routes[len(routes)-1])
+	}
+
+	// A passed deadline stops the search.
+	if _, routes, timedOut := ExtractSPARoutes(b.String(), time.Now().Add(-time.Second)); !timedOut || len(routes) != 0 {
+		t.Errorf("past deadline: %d route(s), timed out %v", len(routes), timedOut)
+	}
+}
+
+func TestPlausibleSPARoute(t *testing.T) {
+	tests := []struct {
+		raw      string
+		relative bool
+		want     string
+		ok       bool
+	}{
+		{"/users/:id", false, "/users/1", true},
+		{"/users/:id?/edit", false, "/users/1/edit", true},
+		{"/search?q=1#results", false, "/search", true},
+		{"users", true, "/users", true},
+		{"users", false, "", false},
+		{"/", false, "", false},
+		{"", true, "", false},
+		{"//cdn.example.com/app", false, "", false},
+		{"/has space", false, "", false},
+		{"/files/*", false, "", false},
+		{"/{{ route }}", false, "", false},
+		{"/assets/app.css", false, "", false},
+		{"/" + strings.Repeat("a", spaMaxRoute), false, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := plausibleSPARoute(tt.raw, tt.relative)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("plausibleSPARoute(%q, %v) = %q, %v; want %q, %v", tt.raw, tt.relative, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSPASettings(t *testing.T) {
+	t.Setenv("SPA_MAX_FILES", "")
+	t.Setenv("SPA_MAX_BUNDLE_MB", "")
+	t.Setenv("SPA_FILE_TIMEOUT", "")
+	if files, size, timeout := spaSettings(); files != defaultSPAMaxFiles || size != defaultSPAMaxBundleMB<<20 || timeout != defaultSPAFileTimeout {
+		t.Errorf("defaults %d, %d, %v", files, size, timeout)
+	}
+	t.Setenv("SPA_MAX_FILES", "10")
+	t.Setenv("SPA_MAX_BUNDLE_MB", "1")
+	t.Setenv("SPA_FILE_TIMEOUT", "250ms")
+	if files, size, timeout := spaSettings(); files != 10 || size != 1<<20 || timeout != 250*time.Millisecond {
+		t.Errorf("settings %d, %d, %v", files, size, timeout)
+	}
+	t.Setenv("SPA_MAX_FILES", "-1")
+	t.Setenv("SPA_FILE_TIMEOUT", "soon")
+	if files, _, timeout := spaSettings(); files != defaultSPAMaxFiles || timeout != defaultSPAFileTimeout {
+		t.Errorf("invalid settings give %d, %v", files, timeout)
+	}
+}
+
+func TestRunSPARoutes(t *testing.T) {
+	t.Setenv("SPA_MAX_BUNDLE_MB", "1")
+	react := readSPAFixture(t, "react.js")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/static/main.js":
+			fmt.Fprint(w, react)
+		case "/static/vendor.js":
+			// Larger than the cap: the route past it is not seen.
+			fmt.Fprint(w, `/* vue-router */`+strings.Repeat(" ", 1<<20)+`{path:"/past-the-cap",name:"x"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	outDir := t.TempDir()
+	result := &types.ScanResult{
+		AllURLs: []string{srv.URL + "/static/main.js", srv.URL + "/static/vendor.js", srv.URL + "/static/gone.js", srv.URL + "/login"},
+		LiveHosts: []types.LiveHost{
+			{Hostname: "127.0.0.1", URL: srv.URL},
+		},
+	}
+	inScope := func(u string) bool { return !strings.Contains(u, "/admin/") }
+	var logs []string
+	RunSPARoutes(srv.Client(), outDir, result, inScope, func(s string) { logs = append(logs, s) })
+
+	var records []string
+	for _, r := range result.URLRecords {
+		if r.Source != "spa-routes" || r.Referrer != srv.URL+"/static/main.js" {
+			t.Errorf("record %+v", r)
+		}
+		records = append(records, strings.TrimPrefix(r.URL, srv.URL))
+	}
+	// /login was already known and /admin/users/1 is out of scope.
+	if want := []string{"/dashboard", "/reports/1/1"}; !reflect.DeepEqual(records, want) {
+		t.Errorf("recorded %q, want %q", records, want)
+	}
+	if len(result.AllURLs) != 6 {
+		t.Errorf("AllURLs %q", result.AllURLs)
+	}
+	if techs := result.LiveHosts[0].Technologies; len(techs) != 2 || techs[0].Name != "React Router" || techs[1].Name != "Vue Router" {
+		t.Errorf("technologies %+v", techs)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(outDir, "spa_routes.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), srv.URL+"/admin/users/1\t"+srv.URL+"/static/main.js") || strings.Contains(string(data), "past-the-cap") {
+		t.Errorf("spa_routes.txt:\n%s", data)
+	}
+	joined := strings.Join(logs, "\n")
+	for _, want := range []string{
+		"1 JavaScript file(s) could not be downloaded",
+		"1 bundle(s) larger than SPA_MAX_BUNDLE_MB",
+		"Searched 2 bundle(s): 2 use client-side routing, 4 route(s) found, 2 new.",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("log lacks %q:\n%s", want, joined)
+		}
+	}
+}
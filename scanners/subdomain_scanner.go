@@ -1,42 +1,70 @@
-// scanners/subdomain_scanner.go - Subdomain enumeration using assetfinder and amass.
+// scanners/subdomain_scanner.go - Subdomain enumeration using assetfinder, amass and subfinder.
 package scanners
 
 import (
-	"bufio"
-	"os"
+	"fmt"
 	"path/filepath"
-	"strings"
 	"time"
 
-	"recon-tool/main"
-	"recon-tool/utils"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
 )
 
-// EnumerateSubdomains runs assetfinder and amass (passive mode) to enumerate subdomains.
-func EnumerateSubdomains(target, chaosKey, outDir string, result *main.ScanResult, logFn func(string)) {
+// EnumerateSubdomains runs assetfinder, amass (passive mode) and subfinder
+// to enumerate subdomains.
+func EnumerateSubdomains(target, chaosKey, outDir string, result *types.ScanResult, logFn func(string)) {
 	logFn("[*] Starting subdomain enumeration...")
+	found := make(map[string][]string)
+	runtimes := make(map[string]time.Duration)
 
 	// Run assetfinder with default parameters.
-	assetOut, err := utils.RunCommand("assetfinder", target)
+	start := time.Now()
+	assetOut, err := utils.RunCommand("assetfinder", "--subs-only", target)
+	runtimes["assetfinder"] = time.Since(start)
 	if err != nil {
 		logFn("[!] assetfinder error: " + err.Error())
 	}
+	found["assetfinder"] = utils.ReadLines("assetfinder", assetOut)
 	// Run amass in passive mode.
+	start = time.Now()
 	amassOut, err := utils.RunCommand("amass", "enum", "-d", target, "-passive", "-norecursive", "-noalts", "-timeout", "60")
+	runtimes["amass"] = time.Since(start)
 	if err != nil {
 		logFn("[!] amass error: " + err.Error())
 	}
+	found["amass"] = utils.ReadLines("amass", amassOut)
+	// Run subfinder, which names the passive source of every result.
+	start = time.Now()
+	subfinderHosts, subfinderSources, err := RunSubfinder(target, logFn)
+	runtimes["subfinder"] = time.Since(start)
+	if err != nil {
+		logFn("[!] subfinder error: " + err.Error())
+	}
+	found["subfinder"] = subfinderHosts
+	enumSources := []string{"assetfinder", "amass", "subfinder"}
+	// Drop out-of-scope names regardless of what the tools returned.
+	scope := utils.ScopeFromEnv(target)
+	for _, src := range enumSources {
+		kept, dropped := scope.FilterHosts(found[src])
+		found[src] = kept
+		if dropped > 0 {
+			logFn(fmt.Sprintf("[*] %s: dropped %d out-of-scope name(s)", src, dropped))
+		}
+	}
 
-	allSubs := append(strings.Split(assetOut, "\n"), strings.Split(amassOut, "\n")...)
+	var allSubs []string
+	for _, src := range enumSources {
+		allSubs = append(allSubs, found[src]...)
+	}
 	allSubs = utils.UniqueStrings(allSubs)
+	sources := SubdomainSources(enumSources, found, subfinderSources)
+	records := ResolveHosts(utils.ChaosResolver(DNSResolver()), allSubs, logFn)
 	for _, s := range allSubs {
 		if s != "" {
-			// For demonstration, assign a dummy IP and ports.
-			result.Subdomains = append(result.Subdomains, main.SubdomainResult{
-				Hostname: s,
-				IP:       "192.0.2.1",
-				Ports:    []int{80, 443},
-			})
+			// Ports come from the port scan.
+			sub := types.SubdomainResult{Hostname: s, Ports: []int{}, Source: sources[s]}
+			ApplyDNSRecord(&sub, records[utils.NormalizeHostname(s)])
+			result.Subdomains = append(result.Subdomains, sub)
 			logFn("[*] Discovered subdomain: " + s)
 		}
 	}
@@ -45,5 +73,11 @@ func EnumerateSubdomains(target, chaosKey, outDir string, result *main.ScanResul
 	if err != nil {
 		logFn("[!] Failed to write subdomains: " + err.Error())
 	}
+	// Summarize what each source contributed.
+	result.SourceStats = utils.ComputeSourceStats(enumSources, found, runtimes)
+	logFn("[*] Subdomain sources:")
+	for _, line := range utils.FormatSourceStats(result.SourceStats) {
+		logFn("    " + line)
+	}
 	time.Sleep(1 * time.Second)
 }
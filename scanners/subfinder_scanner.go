@@ -0,0 +1,119 @@
+// scanners/subfinder_scanner.go - Passive subdomain enumeration with subfinder.
+package scanners
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// subfinderProviders maps subfinder's keyed sources to the .env settings
+// holding their credentials; sources taking two are given "a:b".
+var subfinderProviders = []struct {
+	Name string
+	Env  []string
+}{
+	{"shodan", []string{"SHODAN_API_KEY"}},
+	{"censys", []string{"CENSYS_API_ID", "CENSYS_API_SECRET"}},
+	{"fofa", []string{"FOFA_EMAIL", "FOFA_KEY"}},
+	{"virustotal", []string{"VIRUSTOTAL_API_KEY"}},
+	{"binaryedge", []string{"BINARYEDGE_API_KEY"}},
+	{"chaos", []string{"PDCHAOS_KEY"}},
+}
+
+// configuredSetting returns the value of the .env setting name, or "" when
+// it is unset or still holds the template's your_..._here placeholder.
+func configuredSetting(name string) string {
+	v := strings.TrimSpace(os.Getenv(name))
+	if strings.HasPrefix(v, "your_") && strings.HasSuffix(v, "_here") {
+		return ""
+	}
+	return v
+}
+
+// subfinderProviderConfig renders a subfinder provider-config for the
+// sources whose credentials are configured, and names them.
+func subfinderProviderConfig() (string, []string) {
+	var b strings.Builder
+	var names []string
+	for _, p := range subfinderProviders {
+		var parts []string
+		for _, env := range p.Env {
+			if v := configuredSetting(env); v != "" {
+				parts = append(parts, v)
+			}
+		}
+		if len(parts) != len(p.Env) {
+			continue
+		}
+		fmt.Fprintf(&b, "%s:\n  - %s\n", p.Name, strconv.Quote(strings.Join(parts, ":")))
+		names = append(names, p.Name)
+	}
+	return b.String(), names
+}
+
+// RunSubfinder enumerates target's subdomains with subfinder and returns
+// them with the passive sources that reported each. Sources needing
+// credentials are given those configured in .env through a provider-config
+// that lives only for the run; without any, subfinder uses its keyless
+// sources.
+func RunSubfinder(target string, logFn func(string)) ([]string, map[string][]string, error) {
+	if _, err := exec.LookPath("subfinder"); err != nil {
+		logFn("[!] subfinder not found in PATH; skipping it")
+		return nil, nil, nil
+	}
+	args := []string{"-d", target, "-oJ", "-cs", "-silent"}
+	if config, names := subfinderProviderConfig(); len(names) > 0 {
+		f, err := ioutil.TempFile("", "recon-subfinder-*.yaml")
+		if err != nil {
+			return nil, nil, err
+		}
+		defer os.Remove(f.Name())
+		_, err = f.WriteString(config)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		args = append(args, "-pc", f.Name())
+		logFn("[*] subfinder keyed sources: " + strings.Join(names, ", "))
+	} else {
+		logFn("[*] subfinder runs with its keyless sources only")
+	}
+	out, runErr := utils.RunCommand("subfinder", args...)
+	hosts, sources, err := parsers.ParseSubfinderOutput(out)
+	if runErr != nil {
+		err = runErr
+	}
+	return hosts, sources, err
+}
+
+// SubdomainSources attributes every name found to the tools that reported
+// it, in the order the tools ran, joined with commas; subfinder is
+// credited through its passive sources, as subfinder/crtsh.
+func SubdomainSources(order []string, found map[string][]string, subfinder map[string][]string) map[string]string {
+	credited := make(map[string][]string)
+	for _, src := range order {
+		for _, name := range found[src] {
+			if src == "subfinder" && len(subfinder[name]) > 0 {
+				for _, s := range subfinder[name] {
+					credited[name] = append(credited[name], "subfinder/"+s)
+				}
+				continue
+			}
+			credited[name] = append(credited[name], src)
+		}
+	}
+	joined := make(map[string]string, len(credited))
+	for name, srcs := range credited {
+		joined[name] = strings.Join(utils.UniqueStrings(srcs), ",")
+	}
+	return joined
+}
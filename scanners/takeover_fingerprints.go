@@ -0,0 +1,261 @@
+// scanners/takeover_fingerprints.go - Subdomain takeover checks from a built-in fingerprint table.
+package scanners
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// takeoverFingerprintWorkers is how many CNAMEs are checked at once.
+const takeoverFingerprintWorkers = 20
+
+// TakeoverFingerprint describes how an unclaimed name looks on a service:
+// the CNAME targets pointing at it (domain suffixes, in which * stands for
+// any one label) and the text its error page for a missing bucket, app or
+// site contains. NXDomain marks services whose unclaimed names do not
+// resolve at all.
+type TakeoverFingerprint struct {
+	Service  string   `json:"service"`
+	CNAME    []string `json:"cname"`
+	Body     []string `json:"body,omitempty"`
+	NXDomain bool     `json:"nxdomain,omitempty"`
+}
+
+// takeoverFingerprints are the built-in fingerprints, kept to services
+// whose error page tells an unclaimed name from a misconfigured one.
+var takeoverFingerprints = []TakeoverFingerprint{
+	{Service: "Amazon S3", CNAME: []string{"s3.amazonaws.com", "s3.*.amazonaws.com", "s3-*.amazonaws.com", "s3-website.*.amazonaws.com"}, Body: []string{"NoSuchBucket", "The specified bucket does not exist"}},
+	{Service: "GitHub Pages", CNAME: []string{"github.io"}, Body: []string{githubPagesMarker}},
+	{Service: "Heroku", CNAME: []string{"herokuapp.com", "herokudns.com", "herokussl.com"}, Body: []string{"No such app", herokuNoSuchApp}},
+	{Service: "Microsoft Azure", CNAME: []string{"azurewebsites.net", "cloudapp.net", "cloudapp.azure.com", "trafficmanager.net", "blob.core.windows.net", "azureedge.net", "azure-api.net", "azurefd.net"}, NXDomain: true},
+	{Service: "AWS Elastic Beanstalk", CNAME: []string{"elasticbeanstalk.com"}, NXDomain: true},
+	{Service: "Fastly", CNAME: []string{"fastly.net"}, Body: []string{"Fastly error: unknown domain"}},
+	{Service: "Shopify", CNAME: []string{"myshopify.com"}, Body: []string{"Sorry, this shop is currently unavailable"}},
+	{Service: "Tumblr", CNAME: []string{"domains.tumblr.com"}, Body: []string{"Whatever you were looking for doesn't currently exist at this address"}},
+	{Service: "Pantheon", CNAME: []string{"pantheonsite.io"}, Body: []string{"The gods are wise, but do not know of the site which you seek"}},
+	{Service: "Ghost", CNAME: []string{"ghost.io"}, Body: []string{"Failed to resolve DNS path for this host"}},
+	{Service: "Surge.sh", CNAME: []string{"surge.sh"}, Body: []string{"project not found"}},
+	{Service: "Bitbucket", CNAME: []string{"bitbucket.io"}, Body: []string{"Repository not found"}},
+	{Service: "Zendesk", CNAME: []string{"zendesk.com"}, Body: []string{"Help Center Closed"}},
+	{Service: "Help Scout", CNAME: []string{"helpscoutdocs.com"}, Body: []string{"No settings were found for this company:"}},
+	{Service: "ReadMe", CNAME: []string{"readme.io"}, Body: []string{"Project doesnt exist... yet!"}},
+	{Service: "Agile CRM", CNAME: []string{"agilecrm.com"}, Body: []string{"Sorry, this page is no longer available."}},
+	{Service: "WordPress.com", CNAME: []string{"wordpress.com"}, Body: []string{"Do you want to register"}},
+}
+
+// LoadTakeoverFingerprints returns the built-in fingerprints merged with
+// those in the JSON file at file, a list of TakeoverFingerprint: an entry
+// replaces the built-in one for the same service (ignoring case) and any
+// other is added. An empty file returns the built-in table.
+func LoadTakeoverFingerprints(file string) ([]TakeoverFingerprint, error) {
+	fps := append([]TakeoverFingerprint(nil), takeoverFingerprints...)
+	if file == "" {
+		return fps, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fps, err
+	}
+	var custom []TakeoverFingerprint
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return fps, fmt.Errorf("%s: %v", file, err)
+	}
+	for _, c := range custom {
+		if c.Service == "" || len(c.CNAME) == 0 {
+			return fps, fmt.Errorf("%s: every fingerprint needs a service and at least one cname", file)
+		}
+		for i, s := range c.CNAME {
+			c.CNAME[i] = strings.ToLower(strings.Trim(strings.TrimSpace(s), "."))
+		}
+		replaced := false
+		for i := range fps {
+			if strings.EqualFold(fps[i].Service, c.Service) {
+				fps[i], replaced = c, true
+				break
+			}
+		}
+		if !replaced {
+			fps = append(fps, c)
+		}
+	}
+	return fps, nil
+}
+
+// cnameMatches reports whether cname ends in the labels of suffix, a *
+// label matching any one label.
+func cnameMatches(cname, suffix string) bool {
+	have, want := strings.Split(cname, "."), strings.Split(suffix, ".")
+	if len(have) < len(want) {
+		return false
+	}
+	have = have[len(have)-len(want):]
+	for i, w := range want {
+		if ok, _ := path.Match(w, have[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// matchFingerprint returns the fingerprint whose CNAME suffixes cover
+// cname, the longest suffix winning.
+func matchFingerprint(fps []TakeoverFingerprint, cname string) (TakeoverFingerprint, bool) {
+	var (
+		best    TakeoverFingerprint
+		bestLen int
+	)
+	for _, fp := range fps {
+		for _, s := range fp.CNAME {
+			if len(s) > bestLen && cnameMatches(cname, s) {
+				best, bestLen = fp, len(s)
+			}
+		}
+	}
+	return best, bestLen > 0
+}
+
+// checkFingerprint checks one subdomain whose CNAME chain is known. A
+// target that does not exist makes a dangling record; otherwise, when the
+// target is on a fingerprinted service, the subdomain's page is fetched
+// and matched against the service's signatures.
+func (tv *TakeoverVerifier) checkFingerprint(fps []TakeoverFingerprint, host string, chain []string, logFn func(string)) (types.VulnerabilityResult, bool) {
+	target := utils.NormalizeHostname(chain[len(chain)-1])
+	fp, known := matchFingerprint(fps, target)
+	ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout)
+	_, err := tv.LookupHost(ctx, target)
+	cancel()
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		detail := fmt.Sprintf("CNAME chain: %s -> %s\n%s: NXDOMAIN", host, strings.Join(chain, " -> "), target)
+		switch {
+		case known && fp.NXDomain:
+			detail += fmt.Sprintf("\nService: %s, whose unclaimed names do not resolve; the name can likely be registered there", fp.Service)
+		case known:
+			detail += "\nService: " + fp.Service
+		}
+		return types.VulnerabilityResult{
+			URL:    utils.OriginURL("http", host, 0),
+			Issue:  "Dangling CNAME Record",
+			Type:   "dangling-cname",
+			Detail: detail,
+		}, true
+	}
+	if err != nil {
+		// A target that could not be looked up may still exist.
+		logFn(fmt.Sprintf("[!] Takeover fingerprint checks: %s: %v", target, err))
+		return types.VulnerabilityResult{}, false
+	}
+	if !known || len(fp.Body) == 0 {
+		return types.VulnerabilityResult{}, false
+	}
+	var transcript []string
+	_, body, ok := fetchTakeover(tv.Client, host, &transcript)
+	if !ok {
+		return types.VulnerabilityResult{}, false
+	}
+	for _, sig := range fp.Body {
+		if strings.Contains(body, sig) {
+			return types.VulnerabilityResult{
+				URL:    utils.OriginURL("http", host, 0),
+				Issue:  "Possible Subdomain Takeover",
+				Type:   "subdomain-takeover",
+				Detail: fmt.Sprintf("Service: %s (fingerprint)\nCNAME: %s\nSignature: %q", fp.Service, target, sig),
+			}, true
+		}
+	}
+	return types.VulnerabilityResult{}, false
+}
+
+// RunTakeoverFingerprints checks the subdomains with a CNAME against the
+// fingerprint table, the built-in one merged with TAKEOVER_FINGERPRINTS
+// when set, without any external tool. Subdomains subzy or subjack
+// already reported are left alone. Signature matches are verified with tv
+// like the tools' candidates; CNAMEs to names that do not exist are
+// reported apart as dangling records. The findings are added to result
+// and written to takeover_fingerprints.json.
+func RunTakeoverFingerprints(outDir string, result *types.ScanResult, tv *TakeoverVerifier, logFn func(string)) {
+	fps, err := LoadTakeoverFingerprints(os.Getenv("TAKEOVER_FINGERPRINTS"))
+	if err != nil {
+		logFn("[!] Takeover fingerprints: " + err.Error() + "; using the built-in table")
+	}
+	reported := make(map[string]bool)
+	for _, v := range result.VulnURLs {
+		if v.Type == "subdomain-takeover" {
+			reported[utils.NormalizeHostname(v.URL)] = true
+		}
+	}
+	type candidate struct {
+		host  string
+		chain []string
+	}
+	var candidates []candidate
+	for _, s := range result.Subdomains {
+		host := utils.NormalizeHostname(s.Hostname)
+		if len(s.CNAME) == 0 || host == "" || reported[host] {
+			continue
+		}
+		reported[host] = true
+		candidates = append(candidates, candidate{host, s.CNAME})
+	}
+	logFn(fmt.Sprintf("[*] Checking %d CNAME(s) against %d takeover fingerprint(s)...", len(candidates), len(fps)))
+
+	found := make([]types.VulnerabilityResult, len(candidates))
+	matched := make([]bool, len(candidates))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < takeoverFingerprintWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				utils.Guard(func() {
+					found[i], matched[i] = tv.checkFingerprint(fps, candidates[i].host, candidates[i].chain, logFn)
+				})
+			}
+		}()
+	}
+	for i := range candidates {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	findings := []types.VulnerabilityResult{}
+	takeovers, confirmed, dangling := 0, 0, 0
+	for i, v := range found {
+		if !matched[i] {
+			continue
+		}
+		if v.Type == "dangling-cname" {
+			dangling++
+			logFn("[!] Dangling CNAME: " + strings.ReplaceAll(v.Detail, "\n", "; "))
+		} else {
+			v = tv.Verify(result, v)
+			state := "possible"
+			if utils.IsConfirmedTakeover(v) {
+				state = "confirmed"
+				confirmed++
+			}
+			takeovers++
+			logFn(fmt.Sprintf("[!] Subdomain takeover (%s, fingerprint): %s (%s)", state, v.URL, parsers.TakeoverService(v)))
+		}
+		findings = append(findings, v)
+	}
+	result.VulnURLs = append(result.VulnURLs, findings...)
+	data, _ := json.MarshalIndent(findings, "", "  ")
+	_ = ioutil.WriteFile(filepath.Join(outDir, "takeover_fingerprints.json"), data, 0644)
+	logFn(fmt.Sprintf("[*] Takeover fingerprint checks complete, %d candidate(s), %d confirmed, %d dangling record(s).", takeovers, confirmed, dangling))
+}
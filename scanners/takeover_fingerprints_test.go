@@ -0,0 +1,209 @@
+package scanners
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCNAMEMatches(t *testing.T) {
+	tests := []struct {
+		cname, suffix string
+		want          bool
+	}{
+		{"acme.github.io", "github.io", true},
+		{"github.io", "github.io", true},
+		{"notgithub.io", "github.io", false},
+		{"github.io.evil.com", "github.io", false},
+		{"io", "github.io", false},
+		{"bucket.s3.amazonaws.com", "s3.amazonaws.com", true},
+		{"bucket.s3.eu-west-1.amazonaws.com", "s3.*.amazonaws.com", true},
+		{"bucket.s3.a.b.amazonaws.com", "s3.*.amazonaws.com", false},
+		{"s3.amazonaws.com", "s3.*.amazonaws.com", false},
+		{"bucket.s3-us-west-2.amazonaws.com", "s3-*.amazonaws.com", true},
+		{"bucket.s3-website.eu-west-1.amazonaws.com", "s3-website.*.amazonaws.com", true},
+		{"app.cloudapp.azure.com", "cloudapp.azure.com", true},
+	}
+	for _, tt := range tests {
+		if got := cnameMatches(tt.cname, tt.suffix); got != tt.want {
+			t.Errorf("cnameMatches(%q, %q) = %v, want %v", tt.cname, tt.suffix, got, tt.want)
+		}
+	}
+}
+
+func TestMatchFingerprint(t *testing.T) {
+	fps := append(append([]TakeoverFingerprint(nil), takeoverFingerprints...),
+		TakeoverFingerprint{Service: "Acme Docs", CNAME: []string{"docs.zendesk.com"}})
+	tests := []struct {
+		cname, want string
+	}{
+		{"acme.github.io", "GitHub Pages"},
+		{"bucket.s3.eu-west-1.amazonaws.com", "Amazon S3"},
+		{"bucket.s3-website.eu-west-1.amazonaws.com", "Amazon S3"},
+		{"shop.myshopify.com", "Shopify"},
+		{"acme.trafficmanager.net", "Microsoft Azure"},
+		{"env.us-east-1.elasticbeanstalk.com", "AWS Elastic Beanstalk"},
+		{"acme.zendesk.com", "Zendesk"},
+		// The longest suffix wins.
+		{"acme.docs.zendesk.com", "Acme Docs"},
+		{"www.example.com", ""},
+		{"ec2-1-2-3-4.compute.amazonaws.com", ""},
+	}
+	for _, tt := range tests {
+		fp, ok := matchFingerprint(fps, tt.cname)
+		if ok != (tt.want != "") || fp.Service != tt.want {
+			t.Errorf("matchFingerprint(%q) = %q, %v; want %q", tt.cname, fp.Service, ok, tt.want)
+		}
+	}
+}
+
+// TestTakeoverFingerprintTable keeps the built-in table usable: every
+// entry has a unique service, lower-case suffixes no other entry claims,
+// and a way to tell an unclaimed name.
+func TestTakeoverFingerprintTable(t *testing.T) {
+	services := make(map[string]bool)
+	suffixes := make(map[string]string)
+	for _, fp := range takeoverFingerprints {
+		if services[strings.ToLower(fp.Service)] {
+			t.Errorf("%s listed twice", fp.Service)
+		}
+		services[strings.ToLower(fp.Service)] = true
+		if len(fp.CNAME) == 0 || (len(fp.Body) == 0 && !fp.NXDomain) {
+			t.Errorf("%s: no CNAME, or neither a body signature nor NXDomain", fp.Service)
+		}
+		for _, s := range fp.CNAME {
+			if s != strings.ToLower(strings.Trim(s, ". ")) || strings.Contains(s, "..") {
+				t.Errorf("%s: malformed suffix %q", fp.Service, s)
+			}
+			if other, ok := suffixes[s]; ok {
+				t.Errorf("%q claimed by %s and %s", s, other, fp.Service)
+			}
+			suffixes[s] = fp.Service
+		}
+		for _, sig := range fp.Body {
+			if strings.TrimSpace(sig) == "" {
+				t.Errorf("%s: empty body signature", fp.Service)
+			}
+		}
+	}
+}
+
+func TestLoadTakeoverFingerprints(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		file := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	builtIn := len(takeoverFingerprints)
+
+	fps, err := LoadTakeoverFingerprints("")
+	if err != nil || len(fps) != builtIn {
+		t.Fatalf("built-in table: %d fingerprints, %v", len(fps), err)
+	}
+
+	file := write("custom.json", `[
+		{"service": "heroku", "cname": ["herokuapp.com"], "body": ["There is nothing here, yet"]},
+		{"service": "Acme Hosting", "cname": [" .Sites.Acme-Hosting.NET. "], "nxdomain": true}
+	]`)
+	fps, err = LoadTakeoverFingerprints(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fps) != builtIn+1 {
+		t.Fatalf("%d fingerprints, want %d", len(fps), builtIn+1)
+	}
+	heroku, _ := matchFingerprint(fps, "app.herokuapp.com")
+	if heroku.Service != "heroku" || len(heroku.Body) != 1 || heroku.Body[0] != "There is nothing here, yet" {
+		t.Errorf("Heroku not replaced: %+v", heroku)
+	}
+	if _, ok := matchFingerprint(fps, "app.herokudns.com"); ok {
+		t.Error("the replaced Heroku entry still matches herokudns.com")
+	}
+	if acme, ok := matchFingerprint(fps, "www.sites.acme-hosting.net"); !ok || !acme.NXDomain {
+		t.Errorf("added fingerprint not matched or normalized: %+v", acme)
+	}
+	if takeoverFingerprints[2].Service != "Heroku" || len(takeoverFingerprints) != builtIn {
+		t.Error("loading changed the built-in table")
+	}
+
+	for name, content := range map[string]string{
+		"broken.json":     `[{"service": "Acme"`,
+		"no-cname.json":   `[{"service": "Acme", "body": ["gone"]}]`,
+		"no-service.json": `[{"cname": ["acme.net"]}]`,
+	} {
+		fps, err := LoadTakeoverFingerprints(write(name, content))
+		if err == nil {
+			t.Errorf("%s: no error", name)
+		}
+		if len(fps) != builtIn {
+			t.Errorf("%s: %d fingerprints returned with the error, want the built-in table", name, len(fps))
+		}
+	}
+	if _, err := LoadTakeoverFingerprints(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("missing file: no error")
+	}
+}
+
+func TestCheckFingerprint(t *testing.T) {
+	pages := map[string]string{
+		"blog.example.com":  "<h1>" + githubPagesMarker + "</h1>",
+		"shop.example.com":  "Welcome to our shop",
+		"files.example.com": "<Code>NoSuchBucket</Code>",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, pages[r.Host])
+	}))
+	defer srv.Close()
+	dialer := &net.Dialer{}
+	tv := &TakeoverVerifier{
+		Client: &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, srv.Listener.Addr().String())
+		}}},
+		LookupHost: func(ctx context.Context, host string) ([]string, error) {
+			switch {
+			case strings.HasPrefix(host, "gone."):
+				return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+			case strings.HasPrefix(host, "flaky."):
+				return nil, &net.DNSError{Err: "timeout", Name: host, IsTimeout: true}
+			}
+			return []string{"192.0.2.1"}, nil
+		},
+	}
+
+	tests := []struct {
+		host  string
+		chain []string
+		typ   string
+		want  string
+	}{
+		{"blog.example.com", []string{"acme.github.io"}, "subdomain-takeover", "Service: GitHub Pages (fingerprint)"},
+		{"files.example.com", []string{"cdn.example.net", "files.s3.eu-west-1.amazonaws.com."}, "subdomain-takeover", `Signature: "NoSuchBucket"`},
+		{"shop.example.com", []string{"shop.myshopify.com"}, "", ""},
+		{"app.example.com", []string{"gone.azurewebsites.net"}, "dangling-cname", "Service: Microsoft Azure, whose unclaimed names do not resolve"},
+		{"old.example.com", []string{"gone.herokuapp.com"}, "dangling-cname", "gone.herokuapp.com: NXDOMAIN\nService: Heroku"},
+		{"www.example.com", []string{"gone.example.org"}, "dangling-cname", "CNAME chain: www.example.com -> gone.example.org"},
+		{"api.example.com", []string{"flaky.github.io"}, "", ""},
+		{"cdn.example.com", []string{"cdn.example.org"}, "", ""},
+		// Azure is judged by NXDOMAIN only; a name that resolves is fine.
+		{"site.example.com", []string{"site.azurewebsites.net"}, "", ""},
+	}
+	for _, tt := range tests {
+		var logged []string
+		v, ok := tv.checkFingerprint(takeoverFingerprints, tt.host, tt.chain, func(s string) { logged = append(logged, s) })
+		if ok != (tt.typ != "") || v.Type != tt.typ || !strings.Contains(v.Detail, tt.want) {
+			t.Errorf("%s -> %v: %v %q %q; want %q containing %q", tt.host, tt.chain, ok, v.Type, v.Detail, tt.typ, tt.want)
+		}
+		if strings.HasPrefix(tt.chain[0], "flaky.") && len(logged) != 1 {
+			t.Errorf("%s: lookup failure not logged: %q", tt.host, logged)
+		}
+	}
+}
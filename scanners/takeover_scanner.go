@@ -0,0 +1,101 @@
+// scanners/takeover_scanner.go - Subdomain takeover checks with subzy or subjack.
+package scanners
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// takeoverTool picks the checker: TAKEOVER_TOOL (subzy or subjack) when
+// installed, otherwise whichever of the two is.
+func takeoverTool() (string, bool) {
+	tools := []string{"subzy", "subjack"}
+	if t := strings.ToLower(os.Getenv("TAKEOVER_TOOL")); t == "subjack" {
+		tools = []string{"subjack", "subzy"}
+	}
+	for _, t := range tools {
+		if _, err := exec.LookPath(t); err == nil {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// RunTakeoverChecks checks every subdomain in subdomains.txt for dangling
+// records that can be claimed, verifies each candidate with tv, adds the
+// findings to result and writes takeovers.json.
+func RunTakeoverChecks(outDir string, result *types.ScanResult, tv *TakeoverVerifier, logFn func(string)) {
+	tool, ok := takeoverTool()
+	if !ok {
+		logFn("[!] Neither subzy nor subjack found in PATH; skipping takeover checks")
+		return
+	}
+	subsFile := filepath.Join(outDir, "subdomains.txt")
+	if _, err := os.Stat(subsFile); err != nil {
+		logFn("[!] takeover checks skipped: " + err.Error())
+		return
+	}
+	logFn("[*] Checking subdomains for takeovers with " + tool + "...")
+	var (
+		takeovers []types.VulnerabilityResult
+		err       error
+	)
+	if tool == "subzy" {
+		var out string
+		out, err = utils.RunCommand("subzy", "run", "--targets", subsFile)
+		takeovers = parsers.ParseSubzyOutput(out)
+	} else {
+		takeovers, err = runSubjack(outDir, subsFile)
+	}
+	if err != nil {
+		logFn("[!] " + tool + " error: " + err.Error())
+	}
+	confirmed := 0
+	for i, t := range takeovers {
+		takeovers[i] = tv.Verify(result, t)
+		state := "possible"
+		if utils.IsConfirmedTakeover(takeovers[i]) {
+			state = "confirmed"
+			confirmed++
+		}
+		logFn(fmt.Sprintf("[!] Subdomain takeover (%s): %s (%s)", state, t.URL, t.Detail))
+	}
+	result.VulnURLs = append(result.VulnURLs, takeovers...)
+	if takeovers == nil {
+		takeovers = []types.VulnerabilityResult{}
+	}
+	data, _ := json.MarshalIndent(takeovers, "", "  ")
+	_ = ioutil.WriteFile(filepath.Join(outDir, "takeovers.json"), data, 0644)
+	logFn(fmt.Sprintf("[*] Takeover checks complete, %d candidate(s), %d confirmed.", len(takeovers), confirmed))
+}
+
+// runSubjack runs subjack over subsFile and parses the JSON it writes.
+// SUBJACK_FINGERPRINTS points it at its fingerprints.json when that is not
+// in subjack's default location.
+func runSubjack(outDir, subsFile string) ([]types.VulnerabilityResult, error) {
+	jsonFile := filepath.Join(outDir, "subjack.json")
+	args := []string{"-w", subsFile, "-t", "20", "-timeout", "30", "-ssl", "-o", jsonFile}
+	if fp := os.Getenv("SUBJACK_FINGERPRINTS"); fp != "" {
+		args = append(args, "-c", fp)
+	}
+	_, runErr := utils.RunCommand("subjack", args...)
+	data, err := ioutil.ReadFile(jsonFile)
+	if err != nil {
+		// subjack writes no file when nothing is vulnerable.
+		return nil, runErr
+	}
+	takeovers, err := parsers.ParseSubjackJSON(data)
+	if err == nil {
+		err = runErr
+	}
+	return takeovers, err
+}
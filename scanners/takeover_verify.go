@@ -0,0 +1,233 @@
+// scanners/takeover_verify.go - Confirmation of takeover candidates per service.
+package scanners
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// takeoverMaxBody is how much of a response the strategies look at; the
+// error pages they match are small.
+const takeoverMaxBody = 64 * 1024
+
+// Takeover candidates are tagged with how far they were verified.
+const (
+	TakeoverConfirmed = utils.TagState + ":confirmed"
+	TakeoverPossible  = utils.TagState + ":possible"
+)
+
+// TakeoverVerifier re-checks the candidates subzy, subjack and the
+// fingerprint table report against the service they point at. The lookups
+// go through the scan's resolver.
+type TakeoverVerifier struct {
+	Client      *http.Client
+	LookupTXT   func(ctx context.Context, name string) ([]string, error)
+	LookupCNAME func(ctx context.Context, host string) (string, error)
+	LookupHost  func(ctx context.Context, host string) ([]string, error)
+}
+
+// takeoverStrategy confirms takeovers on one service. Match tells from the
+// service a tool reported or the CNAME target whether it applies; Verify
+// records every request and lookup it makes in the transcript and passes
+// only when the name is claimable.
+type takeoverStrategy struct {
+	Name   string
+	Match  func(service, cname string) bool
+	Verify func(tv *TakeoverVerifier, host, cname string, transcript *[]string) bool
+}
+
+var takeoverStrategies = []takeoverStrategy{
+	{"GitHub Pages", matchTakeover([]string{"github"}, []string{".github.io"}), verifyGitHubPages},
+	{"Amazon S3", matchS3, verifyS3},
+	{"Heroku", matchTakeover([]string{"heroku"}, []string{".herokuapp.com", ".herokudns.com", ".herokussl.com"}), verifyHeroku},
+}
+
+// matchTakeover matches a service name containing one of names, or a CNAME
+// target ending in one of suffixes.
+func matchTakeover(names, suffixes []string) func(service, cname string) bool {
+	return func(service, cname string) bool {
+		service = strings.ToLower(service)
+		for _, n := range names {
+			if strings.Contains(service, n) {
+				return true
+			}
+		}
+		for _, s := range suffixes {
+			if strings.HasSuffix(cname, s) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// matchS3 matches S3 by name, or a CNAME to a bucket or website endpoint
+// such as s3.amazonaws.com or s3-website.eu-west-1.amazonaws.com.
+func matchS3(service, cname string) bool {
+	if strings.Contains(strings.ToLower(service), "s3") {
+		return true
+	}
+	if !strings.HasSuffix(cname, ".amazonaws.com") {
+		return false
+	}
+	for _, label := range strings.Split(cname, ".") {
+		if label == "s3" || strings.HasPrefix(label, "s3-") {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchTakeover requests host over HTTP, which every service here answers
+// (S3 website endpoints only do HTTP), and notes the status in transcript.
+func fetchTakeover(client *http.Client, host string, transcript *[]string) (int, string, bool) {
+	u := "http://" + host + "/"
+	resp, err := client.Get(u)
+	if err != nil {
+		*transcript = append(*transcript, fmt.Sprintf("GET %s: %v", u, err))
+		return 0, "", false
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, takeoverMaxBody))
+	*transcript = append(*transcript, fmt.Sprintf("GET %s: %s", u, resp.Status))
+	return resp.StatusCode, string(body), true
+}
+
+// bodyMarker notes in transcript whether body contains marker.
+func bodyMarker(body, marker string, transcript *[]string) bool {
+	found := strings.Contains(body, marker)
+	if found {
+		*transcript = append(*transcript, fmt.Sprintf("body contains %q", marker))
+	} else {
+		*transcript = append(*transcript, fmt.Sprintf("body lacks %q", marker))
+	}
+	return found
+}
+
+const githubPagesMarker = "There isn't a GitHub Pages site here"
+
+// verifyGitHubPages passes when GitHub serves its missing-site page for
+// host and the organization the CNAME names has not verified the domain.
+// A verified domain (a _github-pages-challenge-<org> TXT record on host or
+// a parent) cannot be claimed by anyone else, whatever the page says.
+func verifyGitHubPages(tv *TakeoverVerifier, host, cname string, transcript *[]string) bool {
+	status, body, ok := fetchTakeover(tv.Client, host, transcript)
+	if !ok || status != http.StatusNotFound || !bodyMarker(body, githubPagesMarker, transcript) {
+		return false
+	}
+	org := strings.TrimSuffix(cname, ".github.io")
+	if org == cname || org == "" || strings.Contains(org, ".") {
+		*transcript = append(*transcript, "CNAME "+cname+" names no GitHub organization; domain verification not checked")
+		return false
+	}
+	labels := strings.Split(host, ".")
+	for i := 0; i < len(labels)-1; i++ {
+		name := "_github-pages-challenge-" + org + "." + strings.Join(labels[i:], ".")
+		ctx, cancel := context.WithTimeout(context.Background(), txtLookupTimeout)
+		records, err := tv.LookupTXT(ctx, name)
+		cancel()
+		var dnsErr *net.DNSError
+		switch {
+		case err == nil && len(records) > 0:
+			*transcript = append(*transcript, fmt.Sprintf("TXT %s: %s (%s has verified the domain)", name, strings.Join(records, " "), org))
+			return false
+		case err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound):
+			// A record that could not be looked up may still be there.
+			*transcript = append(*transcript, fmt.Sprintf("TXT %s: %v", name, err))
+			return false
+		}
+		*transcript = append(*transcript, "TXT "+name+": none")
+	}
+	return true
+}
+
+// verifyS3 passes on S3's NoSuchBucket error: the bucket named after host
+// does not exist and can be created. AccessDenied and any other answer
+// mean the bucket exists under someone's account.
+func verifyS3(tv *TakeoverVerifier, host, _ string, transcript *[]string) bool {
+	_, body, ok := fetchTakeover(tv.Client, host, transcript)
+	if !ok {
+		return false
+	}
+	if bodyMarker(body, "NoSuchBucket", transcript) {
+		return true
+	}
+	if strings.Contains(body, "AccessDenied") {
+		*transcript = append(*transcript, "AccessDenied: the bucket exists")
+	}
+	return false
+}
+
+const herokuNoSuchApp = "no-such-app"
+
+// verifyHeroku passes on Heroku's "No such app" error page, which links
+// its no-such-app.html; an app that exists but is down shows other pages.
+func verifyHeroku(tv *TakeoverVerifier, host, _ string, transcript *[]string) bool {
+	_, body, ok := fetchTakeover(tv.Client, host, transcript)
+	return ok && bodyMarker(body, herokuNoSuchApp, transcript)
+}
+
+// candidateCNAME returns the end of host's CNAME chain: from the resolved
+// subdomains when known, looked up otherwise.
+func (tv *TakeoverVerifier) candidateCNAME(result *types.ScanResult, host string) string {
+	for _, s := range result.Subdomains {
+		if utils.NormalizeHostname(s.Hostname) == host && len(s.CNAME) > 0 {
+			return utils.NormalizeHostname(s.CNAME[len(s.CNAME)-1])
+		}
+	}
+	if tv.LookupCNAME == nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout)
+	defer cancel()
+	cname, err := tv.LookupCNAME(ctx, host)
+	if err != nil || utils.NormalizeHostname(cname) == host {
+		return ""
+	}
+	return utils.NormalizeHostname(cname)
+}
+
+// Verify tags a takeover candidate confirmed when the strategy for its
+// service passes and possible otherwise, including for services without a
+// strategy. The verification transcript is appended to Detail as
+// evidence.
+func (tv *TakeoverVerifier) Verify(result *types.ScanResult, v types.VulnerabilityResult) types.VulnerabilityResult {
+	host := utils.NormalizeHostname(v.URL)
+	cname := tv.candidateCNAME(result, host)
+	service := parsers.TakeoverService(v)
+	var transcript []string
+	if cname != "" {
+		transcript = append(transcript, "CNAME "+host+": "+cname)
+	}
+	tag, verdict := TakeoverPossible, "no verification strategy for "+service
+	if service == "" {
+		verdict = "no verification strategy for an unnamed service"
+	}
+	for _, s := range takeoverStrategies {
+		if !s.Match(service, cname) {
+			continue
+		}
+		if s.Verify(tv, host, cname, &transcript) {
+			tag, verdict = TakeoverConfirmed, s.Name+" verification passed"
+		} else {
+			verdict = s.Name + " verification failed"
+		}
+		break
+	}
+	v.Tags = utils.AddTag(v.Tags, tag)
+	v.Detail += "\nVerification: " + verdict
+	for _, line := range transcript {
+		v.Detail += "\n  " + line
+	}
+	return v
+}
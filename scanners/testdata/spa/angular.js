@@ -0,0 +1,2 @@
+"use strict";(self.webpackChunkportal=self.webpackChunkportal||[]).push([[792],{3321:(e,t,n)=>{var o=n(6814),r=n(1896);const i=[{path:"",component:o.HomeComponent},{path:"orders",component:o.OrdersComponent},{path:"orders/:id",component:o.OrderComponent,canActivate:[o.AuthGuard]},{path:"admin",loadChildren:()=>n.e(341).then(n.bind(n,341)).then(e=>e.AdminModule)},{path:"legacy",redirectTo:"orders",pathMatch:"full"},{path:"**",component:o.NotFoundComponent}];let s=(()=>{class e{}return e.ɵmod=r.RouterModule.forRoot(i),e})();t.AppRoutingModule=s}}]);
+/* @angular/router */
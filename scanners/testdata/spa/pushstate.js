@@ -0,0 +1 @@
+(function(){function go(p){window.history.pushState({},"",p)}document.querySelector("#tab-invoices").onclick=function(){window.history.pushState({tab:"invoices"},"","/billing/invoices?tab=1")};document.querySelector("#tab-export").onclick=function(){history.replaceState(null, "", "/billing/export#top")};window.history.pushState(null,"","//evil.example.com/x")})();
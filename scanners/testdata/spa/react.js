@@ -0,0 +1,2 @@
+/*! react-router v6 */
+"use strict";(self.webpackChunkapp=self.webpackChunkapp||[]).push([[179],{4821:function(e,t,n){var r=n(7294),o=n(9655);function a(){var e=(0,o.useNavigate)();return r.createElement("button",{onClick:function(){return e("/account/settings")}},"Settings")}var i=(0,o.createBrowserRouter)([{path:"/",element:r.createElement(a,null),children:[{path:"/dashboard",element:null},{path:"/admin/users/:userId",element:null},{path:"/reports/:year(\\d+)/:month?",element:null},{path:"/files/*",element:null},{path:"/static/logo.svg",element:null},{path:"/internal/${tenant}/audit",element:null}]},{path:"/login",element:null},{path:"/dashboard",element:null}]);t.default=i}}]);
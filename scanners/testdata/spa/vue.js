@@ -0,0 +1 @@
+import{createRouter as a,createWebHistory as b}from"/assets/vue-router.js";const c=[{path:"/",name:"home",component:()=>import("/assets/Home.js")},{path:"/projects/:slug",name:"project",component:()=>import("/assets/Project.js")},{path:"/settings/billing",meta:{requiresAuth:!0},component:()=>import("/assets/Billing.js")},{path:"/old-settings",redirect:"/settings/billing"}],d={path:"/not-a-route",value:1};export default a({history:b(),routes:c});
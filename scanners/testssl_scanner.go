@@ -0,0 +1,155 @@
+// scanners/testssl_scanner.go - TLS hygiene checks of HTTPS hosts with testssl.sh.
+package scanners
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	defaultTestsslWorkers = 2
+	defaultTestsslTimeout = 5 * time.Minute
+)
+
+// testsslSettings reads TESTSSL_WORKERS and TESTSSL_TIMEOUT (a per-host Go
+// duration such as "5m").
+func testsslSettings() (workers int, timeout time.Duration) {
+	workers, timeout = defaultTestsslWorkers, defaultTestsslTimeout
+	if n, err := strconv.Atoi(os.Getenv("TESTSSL_WORKERS")); err == nil && n > 0 {
+		workers = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("TESTSSL_TIMEOUT")); err == nil && d > 0 {
+		timeout = d
+	}
+	return workers, timeout
+}
+
+// testsslBinary returns the installed name of testssl.sh; some packages
+// drop the extension.
+func testsslBinary() (string, bool) {
+	for _, name := range []string{"testssl.sh", "testssl"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// httpsAddrs returns the host:port of every origin that answered the HTTP
+// probe over HTTPS or, when no probe ran, port 443 of every host in
+// live_hosts.txt.
+func httpsAddrs(outDir string, result *types.ScanResult) ([]string, error) {
+	if result.LiveHosts != nil {
+		var addrs []string
+		for _, lh := range result.LiveHosts {
+			if strings.HasPrefix(lh.URL, "https://") {
+				addrs = append(addrs, net.JoinHostPort(lh.Hostname, strconv.Itoa(lh.Port)))
+			}
+		}
+		return addrs, nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(outDir, "live_hosts.txt"))
+	if err != nil {
+		return nil, err
+	}
+	var addrs []string
+	for _, h := range strings.Fields(string(data)) {
+		addrs = append(addrs, net.JoinHostPort(h, "443"))
+	}
+	return addrs, nil
+}
+
+// RunTestssl runs testssl.sh against every live HTTPS origin, a few at a
+// time and each bounded by TESTSSL_TIMEOUT, and records its MEDIUM or
+// higher findings on result.TLS and in tls.json. Origins that do not
+// complete a TLS handshake are skipped without a message.
+func RunTestssl(outDir string, result *types.ScanResult, logFn func(string)) {
+	bin, ok := testsslBinary()
+	if !ok {
+		logFn("[!] testssl.sh not found in PATH; skipping TLS checks")
+		return
+	}
+	addrs, err := httpsAddrs(outDir, result)
+	if err != nil {
+		logFn("[!] testssl.sh skipped: " + err.Error())
+		return
+	}
+	workers, timeout := testsslSettings()
+	logFn(fmt.Sprintf("[*] Running testssl.sh against up to %d origin(s), %d at a time, %s each...", len(addrs), workers, timeout))
+
+	jobs := make(chan string)
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		findings = []types.TLSResult{}
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := range jobs {
+				utils.Guard(func() {
+					host, _, _ := net.SplitHostPort(addr)
+					if _, err := tlsHandshakeAddr(addr, &tls.Config{ServerName: host, InsecureSkipVerify: true, MinVersion: tls.VersionTLS10}); err != nil {
+						return
+					}
+					res, err := testsslHost(bin, outDir, addr, timeout)
+					mu.Lock()
+					if err != nil {
+						logFn("[!] testssl.sh " + addr + ": " + err.Error())
+					}
+					findings = append(findings, res...)
+					mu.Unlock()
+				})
+			}
+		}()
+	}
+	for _, a := range utils.UniqueStrings(addrs) {
+		jobs <- a
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, f := range findings {
+		logFn(fmt.Sprintf("[!] TLS %s %s: %s", f.Severity, f.Host, f.Finding))
+	}
+	result.TLS = findings
+	data, _ := json.MarshalIndent(findings, "", "  ")
+	_ = ioutil.WriteFile(filepath.Join(outDir, "tls.json"), data, 0644)
+	logFn(fmt.Sprintf("[*] testssl.sh checks complete, %d finding(s).", len(findings)))
+}
+
+// testsslHost runs testssl.sh against addr (host:port) and parses its JSON
+// report. A run killed at the timeout still yields the findings written so
+// far.
+func testsslHost(bin, outDir, addr string, timeout time.Duration) ([]types.TLSResult, error) {
+	report := filepath.Join(outDir, "testssl_"+strings.NewReplacer(":", "_", "/", "_", "[", "", "]", "").Replace(addr)+".json")
+	_, runErr := utils.RunCommandTimeout(timeout, bin, "--jsonfile", report, "--overwrite",
+		"--quiet", "--warnings", "off", "--color", "0", "--severity", "MEDIUM", addr)
+	data, err := ioutil.ReadFile(report)
+	if err != nil {
+		if runErr != nil {
+			return nil, runErr
+		}
+		return nil, err
+	}
+	res, err := parsers.ParseTestsslJSON(data, addr)
+	if runErr != nil {
+		err = runErr
+	}
+	return res, err
+}
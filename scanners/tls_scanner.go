@@ -0,0 +1,266 @@
+// scanners/tls_scanner.go - TLS protocol and cipher posture per host.
+package scanners
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	tlsDialTimeout = 5 * time.Second
+	tlsWorkers     = 10
+)
+
+// tlsProbePause spaces out handshakes so hosts that rate-limit them are not
+// tripped; tests shorten it.
+var tlsProbePause = 200 * time.Millisecond
+
+// tlsVersions are the protocol versions probed, oldest first.
+var tlsVersions = []struct {
+	id   uint16
+	name string
+	weak bool
+}{
+	{tls.VersionTLS10, "TLS1.0", true},
+	{tls.VersionTLS11, "TLS1.1", true},
+	{tls.VersionTLS12, "TLS1.2", false},
+	{tls.VersionTLS13, "TLS1.3", false},
+}
+
+// DialContext opens the TCP connections probes make themselves. main routes
+// it through the SOCKS5 bastion when one is configured.
+var DialContext = (&net.Dialer{}).DialContext
+
+// tlsHandshakeAddr connects to addr (host:port) and completes a handshake
+// with cfg.
+func tlsHandshakeAddr(addr string, cfg *tls.Config) (tls.ConnectionState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tlsDialTimeout)
+	defer cancel()
+	raw, err := DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	conn := tls.Client(raw, cfg)
+	defer conn.Close()
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return tls.ConnectionState{}, err
+	}
+	return conn.ConnectionState(), nil
+}
+
+// isTimeout reports whether err is a network timeout.
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// insecureSuiteIDs returns the IDs of the cipher suites Go classifies as insecure.
+func insecureSuiteIDs() []uint16 {
+	var ids []uint16
+	for _, cs := range tls.InsecureCipherSuites() {
+		ids = append(ids, cs.ID)
+	}
+	return ids
+}
+
+// tlsPorts are the ports whose services speak TLS from the first byte,
+// for port scanners that name them by protocol alone.
+var tlsPorts = map[int]bool{
+	443: true, 465: true, 636: true, 853: true, 989: true, 990: true, 992: true,
+	993: true, 995: true, 5061: true, 8443: true, 9443: true,
+}
+
+// TLSTarget is a service to probe the TLS posture of.
+type TLSTarget struct {
+	Host string
+	Port int
+}
+
+// TLSTargets returns the TLS services discovered on every subdomain but
+// internal ones (see utils.ProbeHost): the https origins HTTP and
+// alternative port probing found, and the open ports the port scanner
+// named ssl, tls or https or that are TLS by convention. Hosts without any
+// are probed on 443.
+func TLSTargets(result types.ScanResult) []TLSTarget {
+	found := make(map[string]map[int]bool)
+	add := func(host string, port int) {
+		if found[host] == nil {
+			found[host] = make(map[int]bool)
+		}
+		found[host][port] = true
+	}
+	for _, lh := range result.LiveHosts {
+		if strings.HasPrefix(lh.URL, "https://") {
+			port := lh.Port
+			if port == 0 {
+				port = 443
+			}
+			add(utils.NormalizeHostname(lh.Hostname), port)
+		}
+	}
+	var targets []TLSTarget
+	for _, s := range result.Subdomains {
+		if !utils.ProbeHost(s) {
+			continue
+		}
+		host := utils.NormalizeHostname(s.Hostname)
+		for _, ps := range s.Services {
+			name := strings.ToLower(ps.Service)
+			if ps.Protocol == "tcp" && (ps.State == "" || ps.State == "open") && (tlsPorts[ps.Port] || strings.Contains(name, "ssl") || strings.Contains(name, "tls") || strings.Contains(name, "https")) {
+				add(host, ps.Port)
+			}
+		}
+		if len(found[host]) == 0 {
+			add(host, 443)
+		}
+		ports := make([]int, 0, len(found[host]))
+		for p := range found[host] {
+			ports = append(ports, p)
+		}
+		sort.Ints(ports)
+		for _, p := range ports {
+			targets = append(targets, TLSTarget{host, p})
+		}
+		delete(found, host)
+	}
+	return targets
+}
+
+// ProbeTLSPosture determines which protocol versions the service on
+// host:port accepts, the cipher suite of a default handshake and any
+// accepted weak suites.
+func ProbeTLSPosture(host string, port int) types.TLSPosture {
+	posture := types.TLSPosture{Host: host, Port: port}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	state, err := tlsHandshakeAddr(addr, &tls.Config{ServerName: host, InsecureSkipVerify: true})
+	if err != nil {
+		posture.Error = err.Error()
+		return posture
+	}
+	posture.DefaultCipher = tls.CipherSuiteName(state.CipherSuite)
+
+	for _, v := range tlsVersions {
+		time.Sleep(tlsProbePause)
+		_, err := tlsHandshakeAddr(addr, &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: true,
+			MinVersion:         v.id,
+			MaxVersion:         v.id,
+		})
+		if err == nil {
+			posture.Versions = append(posture.Versions, v.name)
+		} else if isTimeout(err) {
+			// Slow or throttling host: keep what we know instead of hammering it.
+			posture.Error = fmt.Sprintf("%s probe timed out", v.name)
+			return posture
+		}
+	}
+
+	// Offer only weak suites (TLS 1.2 and below; TLS 1.3 suites are not configurable).
+	time.Sleep(tlsProbePause)
+	state, err = tlsHandshakeAddr(addr, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS10,
+		MaxVersion:         tls.VersionTLS12,
+		CipherSuites:       insecureSuiteIDs(),
+	})
+	if err == nil {
+		posture.WeakCiphers = append(posture.WeakCiphers, tls.CipherSuiteName(state.CipherSuite))
+	}
+	return posture
+}
+
+// RunTLSPosture probes the TLS posture of targets, writes tls_posture.json
+// and returns the postures with low findings for deprecated protocols and
+// weak ciphers.
+func RunTLSPosture(outDir string, targets []TLSTarget, logFn func(string)) ([]types.TLSPosture, []types.VulnerabilityResult) {
+	logFn(fmt.Sprintf("[*] Checking TLS protocol and cipher posture of %d service(s)...", len(targets)))
+	jobs := make(chan TLSTarget)
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		postures []types.TLSPosture
+	)
+	for i := 0; i < tlsWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range jobs {
+				utils.Guard(func() {
+					p := ProbeTLSPosture(t.Host, t.Port)
+					mu.Lock()
+					postures = append(postures, p)
+					mu.Unlock()
+				})
+			}
+		}()
+	}
+	for _, t := range targets {
+		jobs <- t
+	}
+	close(jobs)
+	wg.Wait()
+	sort.Slice(postures, func(i, j int) bool {
+		if postures[i].Host != postures[j].Host {
+			return postures[i].Host < postures[j].Host
+		}
+		return postures[i].Port < postures[j].Port
+	})
+
+	var findings []types.VulnerabilityResult
+	for _, p := range postures {
+		if p.Error != "" && len(p.Versions) == 0 {
+			continue
+		}
+		for _, v := range tlsVersions {
+			if v.weak && containsString(p.Versions, v.name) {
+				findings = append(findings, types.VulnerabilityResult{
+					URL:        utils.OriginURL("https", p.Host, p.Port),
+					Issue:      "Weak TLS Protocol",
+					Type:       "weak-tls-protocol",
+					Detail:     v.name + " accepted",
+					Confidence: utils.ConfidenceConfirmed,
+				})
+			}
+		}
+		for _, c := range p.WeakCiphers {
+			findings = append(findings, types.VulnerabilityResult{
+				URL:        utils.OriginURL("https", p.Host, p.Port),
+				Issue:      "Weak TLS Cipher",
+				Type:       "weak-tls-cipher",
+				Detail:     c + " accepted",
+				Confidence: utils.ConfidenceConfirmed,
+			})
+		}
+		logFn(fmt.Sprintf("[*] TLS %s:%d: %v (default %s)", p.Host, p.Port, p.Versions, p.DefaultCipher))
+	}
+	data, _ := json.MarshalIndent(postures, "", "  ")
+	_ = ioutil.WriteFile(filepath.Join(outDir, "tls_posture.json"), data, 0644)
+	logFn("[*] TLS posture check complete.")
+	return postures, findings
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package scanners
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// testCertificate returns a self-signed RSA certificate for 127.0.0.1;
+// RSA lets the listeners offer the RSA key exchange suites Go considers
+// insecure.
+func testCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// serveTLS accepts connections on a tls.Listener with cfg until the test
+// ends, completing each handshake, and returns the port.
+func serveTLS(t *testing.T, cfg *tls.Config) int {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(2 * time.Second))
+				conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestProbeTLSPosture(t *testing.T) {
+	saved := tlsProbePause
+	defer func() { tlsProbePause = saved }()
+	tlsProbePause = 0
+	cert := testCertificate(t)
+
+	modern := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+	legacy := serveTLS(t, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS10,
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+			tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
+		},
+	})
+	// A plain TCP service on the port: the handshake fails.
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	go func() {
+		for {
+			conn, err := plain.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+			conn.Close()
+		}
+	}()
+	plainPort := plain.Addr().(*net.TCPAddr).Port
+
+	tests := []struct {
+		port          int
+		versions      []string
+		defaultCipher string
+		weak          []string
+		failed        bool
+	}{
+		{modern, []string{"TLS1.2", "TLS1.3"}, "TLS_AES_128_GCM_SHA256", nil, false},
+		{legacy, []string{"TLS1.0", "TLS1.1", "TLS1.2"}, "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", []string{"TLS_RSA_WITH_AES_128_CBC_SHA256"}, false},
+		{plainPort, nil, "", nil, true},
+	}
+	for _, tt := range tests {
+		p := ProbeTLSPosture("127.0.0.1", tt.port)
+		if !reflect.DeepEqual(p.Versions, tt.versions) || p.DefaultCipher != tt.defaultCipher ||
+			!reflect.DeepEqual(p.WeakCiphers, tt.weak) || (p.Error != "") != tt.failed {
+			t.Errorf("port %d: %+v", tt.port, p)
+		}
+	}
+
+	// The run reports the legacy service's protocols and cipher only.
+	outDir := t.TempDir()
+	targets := []TLSTarget{{"127.0.0.1", modern}, {"127.0.0.1", legacy}, {"127.0.0.1", plainPort}}
+	postures, findings := RunTLSPosture(outDir, targets, func(string) {})
+	if len(postures) != 3 {
+		t.Fatalf("%d posture(s)", len(postures))
+	}
+	origin := "https://127.0.0.1:" + strconv.Itoa(legacy)
+	want := []types.VulnerabilityResult{
+		{URL: origin, Issue: "Weak TLS Protocol", Type: "weak-tls-protocol", Detail: "TLS1.0 accepted", Confidence: "confirmed"},
+		{URL: origin, Issue: "Weak TLS Protocol", Type: "weak-tls-protocol", Detail: "TLS1.1 accepted", Confidence: "confirmed"},
+		{URL: origin, Issue: "Weak TLS Cipher", Type: "weak-tls-cipher", Detail: "TLS_RSA_WITH_AES_128_CBC_SHA256 accepted", Confidence: "confirmed"},
+	}
+	if !reflect.DeepEqual(findings, want) {
+		t.Errorf("findings:\n got %+v\nwant %+v", findings, want)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(outDir, "tls_posture.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written []types.TLSPosture
+	if err := json.Unmarshal(data, &written); err != nil || !reflect.DeepEqual(written, postures) {
+		t.Errorf("tls_posture.json does not hold the postures: %v", err)
+	}
+}
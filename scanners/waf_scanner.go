@@ -0,0 +1,101 @@
+// scanners/waf_scanner.go - WAF detection with wafw00f and gentler settings behind one.
+package scanners
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	// defaultWAFRate is the ffuf request rate per second behind a WAF.
+	defaultWAFRate = 10
+	// defaultWAFDelay is the pause in seconds between the requests of
+	// sqlmap, dalfox, gobuster and dirsearch behind a WAF.
+	defaultWAFDelay = 1
+)
+
+// WAFSettings reads WAF_RATE and WAF_DELAY.
+func WAFSettings() (rate, delay int) {
+	rate, delay = defaultWAFRate, defaultWAFDelay
+	if n, err := strconv.Atoi(os.Getenv("WAF_RATE")); err == nil && n > 0 {
+		rate = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("WAF_DELAY")); err == nil && n >= 0 {
+		delay = n
+	}
+	return rate, delay
+}
+
+// WAFArgs returns the extra arguments that slow tool down when a WAF was
+// detected, or nil when none was.
+func WAFArgs(tool string, wafDetected bool) []string {
+	if !wafDetected {
+		return nil
+	}
+	rate, delay := WAFSettings()
+	switch tool {
+	case "ffuf":
+		return []string{"-rate", strconv.Itoa(rate), "-t", "5"}
+	case "gobuster":
+		return []string{"-t", "1", "--delay", strconv.Itoa(delay) + "s"}
+	case "dirsearch":
+		return []string{"-t", "1", "--delay", strconv.Itoa(delay)}
+	case "sqlmap":
+		return []string{"--delay=" + strconv.Itoa(delay), "--random-agent"}
+	case "dalfox":
+		return []string{"--delay", strconv.Itoa(delay * 1000), "-w", "1"}
+	}
+	return nil
+}
+
+// RunWafw00f checks every web origin the probes found with wafw00f,
+// records the WAF in front of each on its LiveHost and sets
+// result.WAFDetected when there is any.
+func RunWafw00f(outDir string, result *types.ScanResult, logFn func(string)) {
+	if _, err := exec.LookPath("wafw00f"); err != nil {
+		logFn("[!] wafw00f not found in PATH; skipping WAF detection")
+		return
+	}
+	var targets []string
+	for _, lh := range result.LiveHosts {
+		if lh.URL != "" {
+			targets = append(targets, lh.URL)
+		}
+	}
+	if len(targets) == 0 {
+		logFn("[*] No web services to check for a WAF.")
+		return
+	}
+	logFn(fmt.Sprintf("[*] Running wafw00f against %d web service(s)...", len(targets)))
+	input := filepath.Join(outDir, "wafw00f_targets.txt")
+	if err := utils.WriteLines(targets, input); err != nil {
+		logFn("[!] wafw00f skipped: " + err.Error())
+		return
+	}
+	out, err := utils.RunCommand("wafw00f", "-i", input)
+	if err != nil {
+		logFn("[!] wafw00f error: " + err.Error())
+	}
+	wafs := parsers.ParseWafw00fOutput(out)
+	found := 0
+	for i := range result.LiveHosts {
+		lh := &result.LiveHosts[i]
+		waf, ok := wafs[strings.TrimSuffix(lh.URL, "/")]
+		if lh.URL == "" || !ok {
+			continue
+		}
+		lh.WAF = waf
+		found++
+		result.WAFDetected = true
+		logFn("[!] WAF DETECTED: " + lh.URL + " is behind " + waf + "; fuzzing and injection tests will slow down")
+	}
+	logFn(fmt.Sprintf("[*] WAF detection complete, %d of %d web service(s) behind a WAF.", found, len(targets)))
+}
@@ -0,0 +1,193 @@
+// scanners/wayback_cdx.go - Archived URLs straight from the Wayback Machine CDX API.
+package scanners
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+const (
+	cdxEndpoint = "http://web.archive.org/cdx/search/cdx"
+	// cdxInterval spaces requests out: the archive blocks clients that
+	// query the CDX server much faster than a few times a minute.
+	cdxInterval = 4 * time.Second
+	cdxPageSize = 5000
+	cdxAttempts = 4
+	cdxTimeout  = 2 * time.Minute
+	cdxMaxBody  = 32 << 20
+)
+
+// errCDXHTML is returned when the archive answers with an HTML error page,
+// which it does under load even with a 200 status.
+var errCDXHTML = errors.New("wayback CDX server returned an HTML page")
+
+// CDXQuery selects the captures of Domain and its subdomains. From and To
+// are CDX timestamp prefixes (yyyy[MM[dd]]); Status and MIME are regular
+// expressions the status code and MIME type must match. Empty fields do
+// not filter.
+type CDXQuery struct {
+	Domain   string
+	From, To string
+	Status   string
+	MIME     string
+}
+
+// WaybackQuery builds the query for domain over the given capture range,
+// filtered by WAYBACK_STATUS and WAYBACK_MIME.
+func WaybackQuery(domain, from, to string) CDXQuery {
+	return CDXQuery{
+		Domain: domain,
+		From:   from,
+		To:     to,
+		Status: strings.TrimSpace(os.Getenv("WAYBACK_STATUS")),
+		MIME:   strings.TrimSpace(os.Getenv("WAYBACK_MIME")),
+	}
+}
+
+// CDXDate turns a date such as 2021, 2021-06 or 2021-06-30 into a CDX
+// timestamp prefix.
+func CDXDate(s string) (string, error) {
+	d := strings.ReplaceAll(strings.TrimSpace(s), "-", "")
+	if len(d) < 4 || len(d) > 14 || len(d)%2 != 0 {
+		return "", fmt.Errorf("wayback date %q: want yyyy, yyyy-mm or yyyy-mm-dd", s)
+	}
+	if _, err := strconv.ParseUint(d, 10, 64); err != nil {
+		return "", fmt.Errorf("wayback date %q: want yyyy, yyyy-mm or yyyy-mm-dd", s)
+	}
+	return d, nil
+}
+
+// CDXClient pages through CDX results, one request per cdxInterval at most.
+type CDXClient struct {
+	Client   *http.Client
+	Endpoint string
+	Interval time.Duration
+	last     time.Time
+}
+
+// NewCDXClient returns a client for the public CDX server.
+func NewCDXClient(client *http.Client) *CDXClient {
+	return &CDXClient{Client: client, Endpoint: cdxEndpoint, Interval: cdxInterval}
+}
+
+// Fetch streams the captures matching q to emit a page at a time, one
+// record per distinct URL (collapse=urlkey), following resume keys until
+// the last page. Malformed lines are logged; a page that still fails after
+// retries ends the fetch with the pages already emitted kept.
+func (c *CDXClient) Fetch(q CDXQuery, emit func([]types.URLRecord), logFn func(string)) error {
+	resume := ""
+	for {
+		records, next, err := c.page(q, resume, logFn)
+		if err != nil {
+			return err
+		}
+		emit(records)
+		if next == "" || next == resume {
+			return nil
+		}
+		resume = next
+	}
+}
+
+// page requests one page, retrying throttling, server errors and HTML
+// error pages with a growing pause.
+func (c *CDXClient) page(q CDXQuery, resume string, logFn func(string)) ([]types.URLRecord, string, error) {
+	params := url.Values{}
+	params.Set("url", q.Domain)
+	params.Set("matchType", "domain")
+	params.Set("fl", "timestamp,original")
+	params.Set("collapse", "urlkey")
+	params.Set("limit", strconv.Itoa(cdxPageSize))
+	params.Set("showResumeKey", "true")
+	if q.From != "" {
+		params.Set("from", q.From)
+	}
+	if q.To != "" {
+		params.Set("to", q.To)
+	}
+	if q.Status != "" {
+		params.Add("filter", "statuscode:"+q.Status)
+	}
+	if q.MIME != "" {
+		params.Add("filter", "mimetype:"+q.MIME)
+	}
+	if resume != "" {
+		params.Set("resumeKey", resume)
+	}
+	reqURL := c.Endpoint + "?" + params.Encode()
+
+	var lastErr error
+	for attempt := 1; attempt <= cdxAttempts; attempt++ {
+		c.wait()
+		body, retryAfter, err := c.get(reqURL)
+		if err == nil {
+			records, next, err := parsers.ParseCDXPage(body)
+			if err != nil {
+				logFn("[!] " + err.Error())
+			}
+			return records, next, nil
+		}
+		lastErr = err
+		if retryAfter < 0 {
+			break
+		}
+		if retryAfter == 0 {
+			retryAfter = time.Duration(attempt) * c.Interval
+		}
+		time.Sleep(retryAfter)
+	}
+	return nil, "", lastErr
+}
+
+// wait blocks until Interval has passed since the previous request.
+func (c *CDXClient) wait() {
+	if d := c.Interval - time.Since(c.last); !c.last.IsZero() && d > 0 {
+		time.Sleep(d)
+	}
+	c.last = time.Now()
+}
+
+// get fetches reqURL. On failure retryAfter is how long the server asked
+// to wait (0 when it did not say), or negative when retrying is pointless.
+func (c *CDXClient) get(reqURL string) (body string, retryAfter time.Duration, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cdxTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return "", -1, err
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, cdxMaxBody))
+	if err != nil {
+		return "", 0, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			retryAfter = time.Duration(secs) * time.Second
+		}
+		return "", retryAfter, fmt.Errorf("wayback CDX server: %s", resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return "", -1, fmt.Errorf("wayback CDX server: %s", resp.Status)
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") ||
+		strings.HasPrefix(strings.TrimSpace(string(data)), "<") {
+		return "", 0, errCDXHTML
+	}
+	return string(data), 0, nil
+}
@@ -0,0 +1,195 @@
+package scanners
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// cdxResponse is one canned answer of the fake CDX server.
+type cdxResponse struct {
+	status int
+	html   bool
+	body   string
+}
+
+// cdxServer answers each resume key (empty for the first page) with its
+// responses in turn, repeating the last, and records every query.
+func cdxServer(t *testing.T, pages map[string][]cdxResponse) (*httptest.Server, func() []string) {
+	var (
+		mu      sync.Mutex
+		queries []string
+		served  = make(map[string]int)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("resumeKey")
+		mu.Lock()
+		queries = append(queries, r.URL.RawQuery)
+		responses, ok := pages[key]
+		i := served[key]
+		served[key]++
+		mu.Unlock()
+		if !ok {
+			t.Errorf("unexpected resume key %q", key)
+			http.NotFound(w, r)
+			return
+		}
+		if i >= len(responses) {
+			i = len(responses) - 1
+		}
+		resp := responses[i]
+		if resp.html {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		}
+		if resp.status != 0 {
+			w.WriteHeader(resp.status)
+		}
+		fmt.Fprint(w, resp.body)
+	}))
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), queries...)
+	}
+}
+
+func cdxRecord(ts, u string) types.URLRecord {
+	captured, _ := time.Parse("20060102150405", ts)
+	return types.URLRecord{URL: u, Source: "wayback", Captured: captured}
+}
+
+func TestCDXClientFetch(t *testing.T) {
+	page1 := "20190101000000 http://example.com/\n20200315120000 https://www.example.com/login?next=/admin\n\nexample,www)/login?next=/admin 20200315120000\n"
+	page2 := "20210601000000 https://api.example.com/v1/users\ngarbage\n\nexample,api)/v1/users 20210601000000\n"
+	page3 := "20220101000000 https://example.com/robots.txt\n"
+	tests := []struct {
+		name     string
+		pages    map[string][]cdxResponse
+		want     []types.URLRecord
+		requests int
+		err      string
+	}{
+		{"three pages", map[string][]cdxResponse{
+			"": {{body: page1}},
+			"example,www)/login?next=/admin 20200315120000": {{body: page2}},
+			"example,api)/v1/users 20210601000000":          {{body: page3}},
+		}, []types.URLRecord{
+			cdxRecord("20190101000000", "http://example.com/"),
+			cdxRecord("20200315120000", "https://www.example.com/login?next=/admin"),
+			cdxRecord("20210601000000", "https://api.example.com/v1/users"),
+			cdxRecord("20220101000000", "https://example.com/robots.txt"),
+		}, 3, ""},
+		// Throttling and the HTML page served under load are retried.
+		{"retries", map[string][]cdxResponse{
+			"": {{status: http.StatusTooManyRequests}, {html: true, body: "<html>Service Unavailable</html>"}, {status: http.StatusBadGateway}, {body: page3}},
+		}, []types.URLRecord{cdxRecord("20220101000000", "https://example.com/robots.txt")}, 4, ""},
+		{"gives up", map[string][]cdxResponse{
+			"": {{body: page1}},
+			"example,www)/login?next=/admin 20200315120000": {{status: http.StatusServiceUnavailable}},
+		}, []types.URLRecord{
+			cdxRecord("20190101000000", "http://example.com/"),
+			cdxRecord("20200315120000", "https://www.example.com/login?next=/admin"),
+		}, 1 + cdxAttempts, "503 Service Unavailable"},
+		{"not retried", map[string][]cdxResponse{
+			"": {{status: http.StatusForbidden}},
+		}, nil, 1, "403 Forbidden"},
+		// A server handing out the same resume key again would loop forever.
+		{"repeated resume key", map[string][]cdxResponse{
+			"":         {{body: page3 + "\nsame-key\n"}},
+			"same-key": {{body: page3 + "\nsame-key\n"}},
+		}, []types.URLRecord{
+			cdxRecord("20220101000000", "https://example.com/robots.txt"),
+			cdxRecord("20220101000000", "https://example.com/robots.txt"),
+		}, 2, ""},
+	}
+	for _, tt := range tests {
+		srv, queries := cdxServer(t, tt.pages)
+		c := &CDXClient{Client: srv.Client(), Endpoint: srv.URL, Interval: time.Millisecond}
+		var got []types.URLRecord
+		var logged []string
+		err := c.Fetch(CDXQuery{Domain: "example.com"}, func(records []types.URLRecord) {
+			got = append(got, records...)
+		}, func(msg string) { logged = append(logged, msg) })
+		srv.Close()
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: records %+v", tt.name, got)
+		}
+		if n := len(queries()); n != tt.requests {
+			t.Errorf("%s: %d request(s), want %d", tt.name, n, tt.requests)
+		}
+		if tt.name == "three pages" && (len(logged) != 1 || !strings.Contains(logged[0], "1 malformed line")) {
+			t.Errorf("%s: logged %q", tt.name, logged)
+		}
+	}
+}
+
+func TestCDXClientQuery(t *testing.T) {
+	srv, queries := cdxServer(t, map[string][]cdxResponse{"": {{body: ""}}})
+	defer srv.Close()
+	c := &CDXClient{Client: srv.Client(), Endpoint: srv.URL, Interval: time.Millisecond}
+	q := CDXQuery{Domain: "example.com", From: "2019", To: "202106", Status: "200|301", MIME: "text/html"}
+	if err := c.Fetch(q, func([]types.URLRecord) {}, func(string) {}); err != nil {
+		t.Fatal(err)
+	}
+	sent := queries()
+	if len(sent) != 1 {
+		t.Fatalf("%d request(s)", len(sent))
+	}
+	for _, param := range []string{"url=example.com", "matchType=domain", "collapse=urlkey", "showResumeKey=true", "from=2019", "to=202106",
+		"filter=statuscode%3A200%7C301", "filter=mimetype%3Atext%2Fhtml", fmt.Sprintf("limit=%d", cdxPageSize)} {
+		if !strings.Contains("&"+sent[0]+"&", "&"+param+"&") {
+			t.Errorf("query %s lacks %s", sent[0], param)
+		}
+	}
+	if strings.Contains(sent[0], "resumeKey=") {
+		t.Errorf("first page sent a resume key: %s", sent[0])
+	}
+}
+
+// TestCDXClientInterval checks that pages are requested no faster than
+// the client's interval.
+func TestCDXClientInterval(t *testing.T) {
+	srv, _ := cdxServer(t, map[string][]cdxResponse{
+		"":   {{body: "20220101000000 https://example.com/\n\nk1\n"}},
+		"k1": {{body: "20220101000000 https://example.com/a\n\nk2\n"}},
+		"k2": {{body: "20220101000000 https://example.com/b\n"}},
+	})
+	defer srv.Close()
+	const interval = 50 * time.Millisecond
+	c := &CDXClient{Client: srv.Client(), Endpoint: srv.URL, Interval: interval}
+	start := time.Now()
+	if err := c.Fetch(CDXQuery{Domain: "example.com"}, func([]types.URLRecord) {}, func(string) {}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 2*interval {
+		t.Errorf("three pages in %v, want at least %v", elapsed, 2*interval)
+	}
+}
+
+func TestCDXDate(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"2021", "2021"},
+		{"2021-06", "202106"},
+		{" 2021-06-30 ", "20210630"},
+		{"21", ""},
+		{"2021-6", ""},
+		{"June 2021", ""},
+		{"2021-06-30T10:00", ""},
+	}
+	for _, tt := range tests {
+		got, err := CDXDate(tt.in)
+		if got != tt.want || (err != nil) != (tt.want == "") {
+			t.Errorf("CDXDate(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// scanners/web_ports.go - HTTP probing of web services on alternative ports.
+package scanners
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// defaultWebPorts are common alternative HTTP ports, probed when open
+// whatever service the port scanner named.
+var defaultWebPorts = []int{3000, 5000, 8000, 8008, 8080, 8081, 8443, 8888, 9000, 9443}
+
+// webPorts reads WEB_PORTS, a comma-separated port list replacing
+// defaultWebPorts.
+func webPorts() []int {
+	raw := os.Getenv("WEB_PORTS")
+	if raw == "" {
+		return defaultWebPorts
+	}
+	var ports []int
+	for _, f := range strings.Split(raw, ",") {
+		if p, err := strconv.Atoi(strings.TrimSpace(f)); err == nil && p > 0 && p < 65536 {
+			ports = append(ports, p)
+		}
+	}
+	return ports
+}
+
+// httpService reports whether a port scanner's service name suggests HTTP:
+// http, https, http-alt, http-proxy, ssl/http and the like.
+func httpService(name string) bool {
+	name = strings.ToLower(name)
+	return strings.Contains(name, "http") || name == "ssl" || name == "www"
+}
+
+// altWebPorts returns the open TCP ports of sub, other than 80 and 443,
+// that likely carry HTTP: their service looks like HTTP or they are in
+// ports.
+func altWebPorts(sub types.SubdomainResult, ports []int) []int {
+	var alt []int
+	for _, s := range sub.Services {
+		if s.Port == 80 || s.Port == 443 || (s.Protocol != "" && s.Protocol != "tcp") {
+			continue
+		}
+		if (httpService(s.Service) || containsInt(ports, s.Port)) && !containsInt(alt, s.Port) {
+			alt = append(alt, s.Port)
+		}
+	}
+	sort.Ints(alt)
+	return alt
+}
+
+// probeAltOrigin infers the scheme of host:port from a TLS handshake and
+// probes it over HTTP.
+func probeAltOrigin(client *http.Client, host string, port int, sigs []PlaceholderSignature) types.LiveHost {
+	scheme := "http"
+	cfg := &tls.Config{ServerName: host, InsecureSkipVerify: true, MinVersion: tls.VersionTLS10}
+	if _, err := tlsHandshakeAddr(net.JoinHostPort(host, strconv.Itoa(port)), cfg); err == nil {
+		scheme = "https"
+	}
+	return probeOrigin(client, host, port, sigs, scheme)
+}
+
+// RunAltPortProbe probes the alternative ports the port scan found open on
+// each subdomain and adds the origins that answered to result.LiveHosts, so
+// later stages scan them like any other web host.
+func RunAltPortProbe(client *http.Client, outDir string, result *types.ScanResult, logFn func(string)) {
+	type origin struct {
+		host string
+		port int
+	}
+	var origins []origin
+	ports := webPorts()
+	for _, sub := range result.Subdomains {
+		for _, p := range altWebPorts(sub, ports) {
+			origins = append(origins, origin{sub.Hostname, p})
+		}
+	}
+	if len(origins) == 0 {
+		logFn("[*] No alternative web ports to probe.")
+		return
+	}
+	if result.LiveHosts == nil {
+		logFn("[!] Alternative port probing skipped: HTTP probing did not run")
+		return
+	}
+	logFn(fmt.Sprintf("[*] Probing %d alternative port(s) over HTTP...", len(origins)))
+	probe := noRedirectClient(client)
+	sigs := placeholderSignaturesFromEnv(logFn)
+	jobs := make(chan origin)
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		found []types.LiveHost
+	)
+	for i := 0; i < httpProbeWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for o := range jobs {
+				utils.Guard(func() {
+					lh := probeAltOrigin(probe, o.host, o.port, sigs)
+					if lh.URL == "" {
+						logFn(fmt.Sprintf("[*] No HTTP answer from %s port %d: %s", o.host, o.port, lh.Error))
+						return
+					}
+					mu.Lock()
+					found = append(found, lh)
+					mu.Unlock()
+				})
+			}
+		}()
+	}
+	for _, o := range origins {
+		jobs <- o
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(found, func(i, j int) bool { return found[i].URL < found[j].URL })
+	for _, lh := range found {
+		logFn(fmt.Sprintf("[*] HTTP %s [%d] %q %s", lh.URL, lh.StatusCode, lh.Title, lh.Server))
+		if lh.Placeholder != "" {
+			logFn(placeholderNote(lh))
+		}
+	}
+	result.LiveHosts = append(result.LiveHosts, found...)
+	writeProbeFiles(outDir, result)
+	logFn(fmt.Sprintf("[*] Alternative port probing complete, %d web service(s) found.", len(found)))
+}
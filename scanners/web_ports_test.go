@@ -0,0 +1,113 @@
+package scanners
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestAltWebPorts(t *testing.T) {
+	t.Setenv("WEB_PORTS", "")
+	sub := types.SubdomainResult{Hostname: "app.example.com", Services: []types.PortService{
+		{Port: 80, Protocol: "tcp", Service: "http"},
+		{Port: 443, Protocol: "tcp", Service: "https"},
+		{Port: 22, Protocol: "tcp", Service: "ssh"},
+		{Port: 9200, Protocol: "tcp", Service: "http-alt"},
+		{Port: 8443, Protocol: "tcp", Service: "ssl/https-alt"},
+		{Port: 8080, Protocol: "tcp"},
+		{Port: 5000, Protocol: "tcp", Service: "upnp"},
+		{Port: 5432, Protocol: "tcp", Service: "postgresql"},
+		{Port: 3000, Protocol: "udp", Service: "http"},
+		{Port: 8080, Protocol: "tcp", Service: "http-proxy"},
+	}}
+	if got := altWebPorts(sub, webPorts()); !reflect.DeepEqual(got, []int{5000, 8080, 8443, 9200}) {
+		t.Errorf("altWebPorts = %v", got)
+	}
+
+	t.Setenv("WEB_PORTS", "5432, 99999, x,7001")
+	if got := webPorts(); !reflect.DeepEqual(got, []int{5432, 7001}) {
+		t.Errorf("webPorts with WEB_PORTS = %v", got)
+	}
+	if got := altWebPorts(sub, webPorts()); !reflect.DeepEqual(got, []int{5432, 8080, 8443, 9200}) {
+		t.Errorf("altWebPorts with WEB_PORTS = %v", got)
+	}
+}
+
+// listenerPort returns the port srv listens on.
+func listenerPort(t *testing.T, srv *httptest.Server) int {
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+// TestRunAltPortProbe probes a plain HTTP and a TLS service on open ports
+// and a closed one, then checks the origins later stages scan.
+func TestRunAltPortProbe(t *testing.T) {
+	page := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<title>Admin Console</title>")
+	})
+	plain := httptest.NewServer(page)
+	defer plain.Close()
+	secure := httptest.NewTLSServer(page)
+	defer secure.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	plainPort, securePort := listenerPort(t, plain), listenerPort(t, secure)
+	result := &types.ScanResult{
+		Subdomains: []types.SubdomainResult{{Hostname: "127.0.0.1", Services: []types.PortService{
+			{Port: plainPort, Protocol: "tcp", Service: "http-alt"},
+			{Port: securePort, Protocol: "tcp", Service: "ssl/http"},
+			{Port: closedPort, Protocol: "tcp", Service: "http"},
+		}}},
+		LiveHosts: []types.LiveHost{{Hostname: "127.0.0.1", URL: "http://127.0.0.1", Port: 80, StatusCode: 200}},
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	var logged []string
+	RunAltPortProbe(client, t.TempDir(), result, func(msg string) { logged = append(logged, msg) })
+
+	want := []string{
+		fmt.Sprintf("http://127.0.0.1:%d", plainPort),
+		fmt.Sprintf("https://127.0.0.1:%d", securePort),
+	}
+	if want[0] > want[1] {
+		want[0], want[1] = want[1], want[0]
+	}
+	if got := AltOrigins(result); !reflect.DeepEqual(got, want) {
+		t.Errorf("AltOrigins = %v, want %v", got, want)
+	}
+	for _, lh := range result.LiveHosts[1:] {
+		if lh.Title != "Admin Console" || lh.StatusCode != 200 {
+			t.Errorf("probed origin %+v", lh)
+		}
+	}
+	if !strings.Contains(strings.Join(logged, "\n"), fmt.Sprintf("No HTTP answer from 127.0.0.1 port %d", closedPort)) {
+		t.Errorf("closed port not logged: %q", logged)
+	}
+	// The default-port origin stays the host's main target.
+	if u, ok := WebTarget(result, "127.0.0.1"); !ok || u != "http://127.0.0.1" {
+		t.Errorf("WebTarget = %q, %v", u, ok)
+	}
+
+	// Without an HTTP probe there is nothing to add the origins to.
+	result.LiveHosts = nil
+	RunAltPortProbe(client, t.TempDir(), result, func(string) {})
+	if result.LiveHosts != nil {
+		t.Errorf("probed without an HTTP probe: %+v", result.LiveHosts)
+	}
+}
@@ -0,0 +1,94 @@
+// scanners/whatweb_scanner.go - Technology fingerprints of web origins with whatweb.
+package scanners
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// whatwebAggression reads WHATWEB_AGGRESSION: 1 (one request per origin,
+// the default) or 3 (extra requests for plugins that probe further).
+func whatwebAggression() int {
+	if n, err := strconv.Atoi(os.Getenv("WHATWEB_AGGRESSION")); err == nil && n == 3 {
+		return n
+	}
+	return 1
+}
+
+// RunWhatweb fingerprints every web origin the probes found with whatweb,
+// records each one's stack on its LiveHost and writes technologies.json.
+// Aggression 3 is only used when allowAggressive is set.
+func RunWhatweb(outDir string, result *types.ScanResult, allowAggressive bool, logFn func(string)) {
+	if _, err := exec.LookPath("whatweb"); err != nil {
+		logFn("[!] whatweb not found in PATH; skipping technology fingerprinting")
+		return
+	}
+	var targets []string
+	for _, lh := range result.LiveHosts {
+		if lh.URL != "" {
+			targets = append(targets, lh.URL)
+		}
+	}
+	if len(targets) == 0 {
+		logFn("[*] No web services to fingerprint.")
+		return
+	}
+	level := whatwebAggression()
+	if level > 1 && !allowAggressive {
+		logFn("[*] WHATWEB_AGGRESSION=3 is not authorized for this run; using 1")
+		level = 1
+	}
+	logFn(fmt.Sprintf("[*] Running whatweb against %d web service(s)...", len(targets)))
+	input := filepath.Join(outDir, "whatweb_targets.txt")
+	if err := utils.WriteLines(targets, input); err != nil {
+		logFn("[!] whatweb skipped: " + err.Error())
+		return
+	}
+	report := filepath.Join(outDir, "whatweb.json")
+	_, runErr := utils.RunCommand("whatweb", "-i", input, "--log-json="+report,
+		"-a", strconv.Itoa(level), "--no-errors", "-q", "--colour=never")
+	data, err := ioutil.ReadFile(report)
+	if err != nil {
+		if runErr != nil {
+			err = runErr
+		}
+		logFn("[!] whatweb error: " + err.Error())
+		return
+	}
+	if runErr != nil {
+		logFn("[!] whatweb error: " + runErr.Error())
+	}
+	stacks, err := parsers.ParseWhatwebJSON(data)
+	if err != nil {
+		logFn("[!] " + err.Error())
+	}
+	// whatweb reports targets as given, give or take a trailing slash.
+	byURL := make(map[string][]types.Technology)
+	for _, s := range stacks {
+		byURL[strings.TrimSuffix(s.URL, "/")] = s.Technologies
+	}
+	found := 0
+	for i := range result.LiveHosts {
+		lh := &result.LiveHosts[i]
+		techs, ok := byURL[strings.TrimSuffix(lh.URL, "/")]
+		if lh.URL == "" || !ok || len(techs) == 0 {
+			continue
+		}
+		lh.Technologies = techs
+		found++
+		logFn(fmt.Sprintf("[*] Stack of %s: %s", lh.URL, utils.FormatTechnologies(techs)))
+	}
+	out, _ := json.MarshalIndent(stacks, "", "  ")
+	_ = ioutil.WriteFile(filepath.Join(outDir, "technologies.json"), out, 0644)
+	logFn(fmt.Sprintf("[*] Technology fingerprinting complete, %d of %d web service(s) identified.", found, len(targets)))
+}
@@ -0,0 +1,101 @@
+// scanners/wpscan_scanner.go - wpscan runs against hosts that look like WordPress.
+package scanners
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/parsers"
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// LooksLikeWordPress reports whether the site at base is WordPress: its
+// home page references wp-content or /wp-login.php answers 200.
+func LooksLikeWordPress(client *http.Client, base string) bool {
+	if resp, err := client.Get(base + "/"); err == nil {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, httpProbeMaxBody))
+		resp.Body.Close()
+		if strings.Contains(string(body), "wp-content") {
+			return true
+		}
+	}
+	resp, err := noRedirectClient(client).Get(base + "/wp-login.php")
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// RunWPScan runs wpscan against every host that answered HTTP and looks like
+// WordPress, and merges its findings into result. Without WPSCAN_API_KEY
+// wpscan still enumerates the site but cannot report known vulnerabilities.
+func RunWPScan(client *http.Client, outDir string, result *types.ScanResult, logFn func(string)) {
+	if _, err := exec.LookPath("wpscan"); err != nil {
+		logFn("[!] wpscan not found in PATH; skipping WordPress scanning")
+		return
+	}
+	targets, err := WebTargets(outDir, result)
+	if err != nil {
+		logFn("[!] WordPress scanning skipped: " + err.Error())
+		return
+	}
+	var sites []string
+	for _, base := range targets {
+		if LooksLikeWordPress(client, base) {
+			sites = append(sites, base)
+		}
+	}
+	if len(sites) == 0 {
+		logFn("[*] No WordPress sites found.")
+		return
+	}
+	token := os.Getenv("WPSCAN_API_KEY")
+	if token == "" {
+		logFn("[!] WPSCAN_API_KEY not set; wpscan will not report known vulnerabilities")
+	}
+	var found int
+	for _, base := range sites {
+		logFn("[*] Running wpscan against " + base + "...")
+		vulns, err := wpscanSite(outDir, base, token)
+		if err != nil {
+			logFn("[!] wpscan " + base + ": " + err.Error())
+		}
+		result.VulnURLs = append(result.VulnURLs, vulns...)
+		found += len(vulns)
+	}
+	logFn(fmt.Sprintf("[*] WordPress scanning complete, %d site(s), %d finding(s).", len(sites), found))
+}
+
+// wpscanSite runs wpscan against one site and parses the JSON report it
+// writes. wpscan exits non-zero when it finds vulnerabilities, so the
+// report is parsed whatever the exit status.
+func wpscanSite(outDir, base, token string) ([]types.VulnerabilityResult, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	report := filepath.Join(outDir, "wpscan_"+strings.NewReplacer(":", "_", "/", "_").Replace(u.Host)+".json")
+	args := []string{"--url", base, "--format", "json", "--output", report,
+		"--enumerate", "vp,vt,u", "--no-banner", "--request-timeout", "30"}
+	if token != "" {
+		args = append(args, "--api-token", token)
+	}
+	_, runErr := utils.RunCommand("wpscan", args...)
+	data, err := ioutil.ReadFile(report)
+	if err != nil {
+		if runErr != nil {
+			return nil, runErr
+		}
+		return nil, err
+	}
+	return parsers.ParseWPScanJSON(data, base)
+}
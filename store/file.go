@@ -0,0 +1,151 @@
+// store/file.go - The run directories themselves as a Store.
+package store
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// FileStore keeps each run in the summary.json of its run directory under
+// Root, the layout every run has always had.
+type FileStore struct {
+	Root string
+}
+
+// NewFileStore returns the store of the run directories under root.
+func NewFileStore(root string) *FileStore {
+	return &FileStore{Root: root}
+}
+
+func (s *FileStore) dir(id string) (string, error) {
+	if err := checkRunID(id); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Root, filepath.FromSlash(id)), nil
+}
+
+// load reads the summary of the run id. A damaged summary is an error
+// here: saving over it would drop what could not be salvaged, so it is left
+// to LoadSummary's callers to report and reprocess.
+func (s *FileStore) load(id string) (types.ScanResult, string, error) {
+	dir, err := s.dir(id)
+	if err != nil {
+		return types.ScanResult{}, "", err
+	}
+	result, rec, err := utils.LoadSummary(dir)
+	if os.IsNotExist(err) {
+		return result, dir, fmt.Errorf("%w: %s", ErrUnknownRun, id)
+	}
+	if err != nil {
+		return result, dir, err
+	}
+	if rec != nil {
+		return result, dir, fmt.Errorf("%s is damaged (%v)", rec.File, rec.Err)
+	}
+	return result, dir, nil
+}
+
+// SaveRun writes result to the summary.json of the run directory id,
+// creating the directory when needed. The target is the one the directory
+// is named after.
+func (s *FileStore) SaveRun(id, target string, result types.ScanResult) error {
+	dir, err := s.dir(id)
+	if err != nil {
+		return err
+	}
+	if t := RunTarget(id); t != target {
+		return fmt.Errorf("run %s is named after %s, not %s", id, t, target)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return utils.PersistResults(result, dir)
+}
+
+func (s *FileStore) SaveAssets(id string, assets []types.SubdomainResult) error {
+	result, dir, err := s.load(id)
+	if err != nil {
+		return err
+	}
+	result.Subdomains = mergeAssets(result.Subdomains, assets)
+	return utils.PersistResults(result, dir)
+}
+
+func (s *FileStore) SaveFindings(id string, findings []types.VulnerabilityResult) error {
+	result, dir, err := s.load(id)
+	if err != nil {
+		return err
+	}
+	result.VulnURLs = mergeFindings(result.VulnURLs, findings)
+	return utils.PersistResults(result, dir)
+}
+
+func (s *FileStore) LoadRun(id string) (types.ScanResult, error) {
+	result, _, err := s.load(id)
+	return result, err
+}
+
+// runIDs returns the IDs of the runs under Root: the directories holding
+// a summary.json, and those one level down (reprocessed runs).
+func (s *FileStore) runIDs() ([]string, error) {
+	top, err := ioutil.ReadDir(s.Root)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, d := range top {
+		if !d.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(s.Root, d.Name(), "summary.json")); err == nil {
+			ids = append(ids, d.Name())
+		}
+		nested, _ := ioutil.ReadDir(filepath.Join(s.Root, d.Name()))
+		for _, n := range nested {
+			if _, err := os.Stat(filepath.Join(s.Root, d.Name(), n.Name(), "summary.json")); n.IsDir() && err == nil {
+				ids = append(ids, d.Name()+"/"+n.Name())
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Query reads the runs under Root whose summary.json is intact.
+func (s *FileStore) Query(q Query) ([]StoredFinding, error) {
+	maxRank, err := q.minRank()
+	if err != nil {
+		return nil, err
+	}
+	ids, err := s.runIDs()
+	if err != nil {
+		return nil, err
+	}
+	var found []StoredFinding
+	for _, id := range ids {
+		target := RunTarget(id)
+		if (q.RunID != "" && id != q.RunID) || (q.Target != "" && target != q.Target) {
+			continue
+		}
+		result, _, err := s.load(id)
+		if err != nil {
+			// Not every summary.json under Root is a run's.
+			continue
+		}
+		for _, v := range result.VulnURLs {
+			if r, _ := utils.SeverityRank(utils.FindingSeverity(v)); r > maxRank || (q.Type != "" && v.Type != q.Type) {
+				continue
+			}
+			found = append(found, StoredFinding{RunID: id, Target: target, Finding: v})
+		}
+	}
+	return found, nil
+}
+
+func (s *FileStore) Close() error { return nil }
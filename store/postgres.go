@@ -0,0 +1,390 @@
+// store/postgres.go - Runs, assets and findings in a shared Postgres database.
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// postgresTimeout bounds each store call, so a database gone away cannot
+// hold up the end of a run.
+const postgresTimeout = 30 * time.Second
+
+// pgQuerier is what the store runs its statements with: the connection or
+// a transaction on it.
+type pgQuerier interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) error
+	Query(ctx context.Context, sql string, args ...interface{}) (pgRows, error)
+}
+
+// pgRows is the part of pgx.Rows the store reads.
+type pgRows interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+	Close()
+}
+
+// pgDB is a database connection. InTx runs fn in a transaction, committed
+// when fn returns nil and rolled back otherwise.
+type pgDB interface {
+	pgQuerier
+	InTx(ctx context.Context, fn func(pgQuerier) error) error
+	Close() error
+}
+
+// pgxDB is a pgDB on a pgx connection.
+type pgxDB struct {
+	conn *pgx.Conn
+}
+
+func (d pgxDB) Exec(ctx context.Context, sql string, args ...interface{}) error {
+	_, err := d.conn.Exec(ctx, sql, args...)
+	return err
+}
+
+func (d pgxDB) Query(ctx context.Context, sql string, args ...interface{}) (pgRows, error) {
+	return d.conn.Query(ctx, sql, args...)
+}
+
+func (d pgxDB) InTx(ctx context.Context, fn func(pgQuerier) error) error {
+	return pgx.BeginFunc(ctx, d.conn, func(tx pgx.Tx) error { return fn(pgxTx{tx}) })
+}
+
+func (d pgxDB) Close() error {
+	return d.conn.Close(context.Background())
+}
+
+// pgxTx is a pgQuerier on a pgx transaction.
+type pgxTx struct {
+	tx pgx.Tx
+}
+
+func (t pgxTx) Exec(ctx context.Context, sql string, args ...interface{}) error {
+	_, err := t.tx.Exec(ctx, sql, args...)
+	return err
+}
+
+func (t pgxTx) Query(ctx context.Context, sql string, args ...interface{}) (pgRows, error) {
+	return t.tx.Query(ctx, sql, args...)
+}
+
+// migrations create and evolve the schema, migrations[i] taking it to
+// version i+1. Applied versions are recorded in schema_migrations; append
+// to the list, never edit an entry already released.
+var migrations = []string{
+	`CREATE TABLE runs (
+		id       text PRIMARY KEY,
+		target   text NOT NULL,
+		summary  jsonb NOT NULL,
+		saved_at timestamptz NOT NULL DEFAULT now()
+	);
+	CREATE INDEX runs_target ON runs (target);
+	CREATE TABLE assets (
+		run_id      text NOT NULL REFERENCES runs (id) ON DELETE CASCADE,
+		fingerprint text NOT NULL,
+		seq         bigserial,
+		data        jsonb NOT NULL,
+		PRIMARY KEY (run_id, fingerprint)
+	);
+	CREATE TABLE findings (
+		run_id        text NOT NULL REFERENCES runs (id) ON DELETE CASCADE,
+		fingerprint   text NOT NULL,
+		seq           bigserial,
+		type          text NOT NULL,
+		severity_rank integer NOT NULL,
+		data          jsonb NOT NULL,
+		PRIMARY KEY (run_id, fingerprint)
+	);
+	CREATE INDEX findings_type ON findings (type);`,
+}
+
+const (
+	sqlCreateMigrations = `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    integer PRIMARY KEY,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`
+	// Concurrent migrations wait for the first to finish.
+	sqlLockMigrations   = `LOCK TABLE schema_migrations IN EXCLUSIVE MODE`
+	sqlSchemaVersion    = `SELECT coalesce(max(version), 0) FROM schema_migrations`
+	sqlRecordMigration  = `INSERT INTO schema_migrations (version) VALUES ($1)`
+	sqlUpsertRun        = `INSERT INTO runs (id, target, summary) VALUES ($1, $2, $3) ON CONFLICT (id) DO UPDATE SET target = EXCLUDED.target, summary = EXCLUDED.summary, saved_at = now()`
+	sqlDeleteRunAssets  = `DELETE FROM assets WHERE run_id = $1`
+	sqlDeleteRunFinding = `DELETE FROM findings WHERE run_id = $1`
+	sqlRunExists        = `SELECT count(*) FROM runs WHERE id = $1`
+	sqlUpsertAsset      = `INSERT INTO assets (run_id, fingerprint, data) VALUES ($1, $2, $3) ON CONFLICT (run_id, fingerprint) DO UPDATE SET data = EXCLUDED.data`
+	sqlUpsertFinding    = `INSERT INTO findings (run_id, fingerprint, type, severity_rank, data) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (run_id, fingerprint) DO UPDATE SET type = EXCLUDED.type, severity_rank = EXCLUDED.severity_rank, data = EXCLUDED.data`
+	sqlLoadRun          = `SELECT summary FROM runs WHERE id = $1`
+	sqlLoadAssets       = `SELECT data FROM assets WHERE run_id = $1 ORDER BY seq`
+	sqlLoadFindings     = `SELECT data FROM findings WHERE run_id = $1 ORDER BY seq`
+	sqlQueryFindings    = `SELECT f.run_id, r.target, f.data FROM findings f JOIN runs r ON r.id = f.run_id
+		WHERE ($1 = '' OR r.target = $1) AND ($2 = '' OR f.run_id = $2) AND ($3 = '' OR f.type = $3) AND f.severity_rank <= $4
+		ORDER BY f.run_id COLLATE "C", f.seq`
+)
+
+// PostgresStore keeps runs in a Postgres database: the summary of each in
+// runs, its subdomains in assets and its findings in findings, the latter
+// two keyed on the run ID and the asset or finding fingerprint. Findings
+// sharing a fingerprint within a run are kept once, the last saved.
+type PostgresStore struct {
+	db pgDB
+}
+
+// OpenPostgres connects to the database at dsn, a postgres:// URL or a
+// key=value connection string (empty uses the PG* environment variables),
+// and migrates its schema to the current version.
+func OpenPostgres(dsn string) (*PostgresStore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), postgresTimeout)
+	defer cancel()
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: %v", err)
+	}
+	s, err := newPostgresStore(ctx, pgxDB{conn})
+	if err != nil {
+		conn.Close(context.Background())
+		return nil, err
+	}
+	return s, nil
+}
+
+// newPostgresStore migrates db and returns the store on it.
+func newPostgresStore(ctx context.Context, db pgDB) (*PostgresStore, error) {
+	if err := migrate(ctx, db); err != nil {
+		return nil, fmt.Errorf("postgres: migrating: %v", err)
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// migrate applies the migrations the database lacks, in one transaction.
+func migrate(ctx context.Context, db pgDB) error {
+	if err := db.Exec(ctx, sqlCreateMigrations); err != nil {
+		return err
+	}
+	return db.InTx(ctx, func(q pgQuerier) error {
+		if err := q.Exec(ctx, sqlLockMigrations); err != nil {
+			return err
+		}
+		var version int
+		if err := queryRow(ctx, q, sqlSchemaVersion, nil, &version); err != nil {
+			return err
+		}
+		if version > len(migrations) {
+			return fmt.Errorf("schema version %d is newer than this build (%d)", version, len(migrations))
+		}
+		for v := version + 1; v <= len(migrations); v++ {
+			if err := q.Exec(ctx, migrations[v-1]); err != nil {
+				return fmt.Errorf("version %d: %v", v, err)
+			}
+			if err := q.Exec(ctx, sqlRecordMigration, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// queryRow scans the single row sql returns into dest; no row is
+// ErrUnknownRun.
+func queryRow(ctx context.Context, q pgQuerier, sql string, args []interface{}, dest ...interface{}) error {
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return ErrUnknownRun
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
+// queryJSON decodes the single JSON column of each row sql returns with fn.
+func queryJSON(ctx context.Context, q pgQuerier, sql string, args []interface{}, fn func(data []byte) error) error {
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		if err := fn([]byte(data)); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// requireRun fails with ErrUnknownRun when no run id was saved.
+func requireRun(ctx context.Context, q pgQuerier, id string) error {
+	var n int
+	if err := queryRow(ctx, q, sqlRunExists, []interface{}{id}, &n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownRun, id)
+	}
+	return nil
+}
+
+func upsertAssets(ctx context.Context, q pgQuerier, id string, assets []types.SubdomainResult) error {
+	for _, a := range assets {
+		data, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		if err := q.Exec(ctx, sqlUpsertAsset, id, assetFingerprint(a), string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func upsertFindings(ctx context.Context, q pgQuerier, id string, findings []types.VulnerabilityResult) error {
+	for _, v := range findings {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		rank, _ := utils.SeverityRank(utils.FindingSeverity(v))
+		if err := q.Exec(ctx, sqlUpsertFinding, id, utils.FindingFingerprint(v), v.Type, rank, string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *PostgresStore) SaveRun(id, target string, result types.ScanResult) error {
+	if err := checkRunID(id); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), postgresTimeout)
+	defer cancel()
+	// Subdomains and findings have their own tables.
+	summary := result
+	summary.Subdomains, summary.VulnURLs = nil, nil
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	return s.db.InTx(ctx, func(q pgQuerier) error {
+		if err := q.Exec(ctx, sqlUpsertRun, id, target, string(data)); err != nil {
+			return err
+		}
+		for _, sql := range []string{sqlDeleteRunAssets, sqlDeleteRunFinding} {
+			if err := q.Exec(ctx, sql, id); err != nil {
+				return err
+			}
+		}
+		if err := upsertAssets(ctx, q, id, result.Subdomains); err != nil {
+			return err
+		}
+		return upsertFindings(ctx, q, id, result.VulnURLs)
+	})
+}
+
+func (s *PostgresStore) SaveAssets(id string, assets []types.SubdomainResult) error {
+	ctx, cancel := context.WithTimeout(context.Background(), postgresTimeout)
+	defer cancel()
+	return s.db.InTx(ctx, func(q pgQuerier) error {
+		if err := requireRun(ctx, q, id); err != nil {
+			return err
+		}
+		return upsertAssets(ctx, q, id, assets)
+	})
+}
+
+func (s *PostgresStore) SaveFindings(id string, findings []types.VulnerabilityResult) error {
+	ctx, cancel := context.WithTimeout(context.Background(), postgresTimeout)
+	defer cancel()
+	return s.db.InTx(ctx, func(q pgQuerier) error {
+		if err := requireRun(ctx, q, id); err != nil {
+			return err
+		}
+		return upsertFindings(ctx, q, id, findings)
+	})
+}
+
+func (s *PostgresStore) LoadRun(id string) (types.ScanResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), postgresTimeout)
+	defer cancel()
+	var (
+		result  types.ScanResult
+		summary string
+	)
+	err := s.db.InTx(ctx, func(q pgQuerier) error {
+		if err := queryRow(ctx, q, sqlLoadRun, []interface{}{id}, &summary); err != nil {
+			if err == ErrUnknownRun {
+				return fmt.Errorf("%w: %s", ErrUnknownRun, id)
+			}
+			return err
+		}
+		if err := json.Unmarshal([]byte(summary), &result); err != nil {
+			return err
+		}
+		err := queryJSON(ctx, q, sqlLoadAssets, []interface{}{id}, func(data []byte) error {
+			var a types.SubdomainResult
+			err := json.Unmarshal(data, &a)
+			result.Subdomains = append(result.Subdomains, a)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return queryJSON(ctx, q, sqlLoadFindings, []interface{}{id}, func(data []byte) error {
+			var v types.VulnerabilityResult
+			err := json.Unmarshal(data, &v)
+			result.VulnURLs = append(result.VulnURLs, v)
+			return err
+		})
+	})
+	return result, err
+}
+
+func (s *PostgresStore) Query(q Query) ([]StoredFinding, error) {
+	maxRank, err := q.minRank()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), postgresTimeout)
+	defer cancel()
+	rows, err := s.db.Query(ctx, sqlQueryFindings, q.Target, q.RunID, q.Type, maxRank)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var found []StoredFinding
+	for rows.Next() {
+		var (
+			f    StoredFinding
+			data string
+		)
+		if err := rows.Scan(&f.RunID, &f.Target, &data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &f.Finding); err != nil {
+			return nil, err
+		}
+		found = append(found, f)
+	}
+	return found, rows.Err()
+}
+
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,349 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// fakePG is a pgDB that understands the store's own statements and keeps
+// the tables in memory, with Postgres' constraints: primary keys, the
+// foreign keys to runs and jsonb validity. Any other statement fails, so a
+// statement added to the store without the fake learning it is caught.
+type fakePG struct {
+	tables fakeTables
+	// failOn makes the statement it names fail, to check rollbacks.
+	failOn string
+	closed bool
+}
+
+type fakeTables struct {
+	migrationsTable bool
+	versions        []int
+	schema          bool
+	runs            map[string][2]string // id -> target, summary
+	assets          map[string][]fakeRow
+	findings        map[string][]fakeRow
+}
+
+// fakeRow is an asset or finding row; rows stay in insertion order, which
+// is the order of their seq.
+type fakeRow struct {
+	fingerprint, typ string
+	rank             int
+	data             string
+}
+
+func newFakePG() *fakePG {
+	return &fakePG{tables: fakeTables{runs: map[string][2]string{}, assets: map[string][]fakeRow{}, findings: map[string][]fakeRow{}}}
+}
+
+func (t fakeTables) clone() fakeTables {
+	c := t
+	c.versions = append([]int(nil), t.versions...)
+	c.runs = make(map[string][2]string, len(t.runs))
+	for k, v := range t.runs {
+		c.runs[k] = v
+	}
+	c.assets, c.findings = make(map[string][]fakeRow), make(map[string][]fakeRow)
+	for k, v := range t.assets {
+		c.assets[k] = append([]fakeRow(nil), v...)
+	}
+	for k, v := range t.findings {
+		c.findings[k] = append([]fakeRow(nil), v...)
+	}
+	return c
+}
+
+func upsertRow(rows []fakeRow, r fakeRow) []fakeRow {
+	for i := range rows {
+		if rows[i].fingerprint == r.fingerprint {
+			rows[i] = r
+			return rows
+		}
+	}
+	return append(rows, r)
+}
+
+func (f *fakePG) Exec(ctx context.Context, sql string, args ...interface{}) error {
+	if f.failOn == sql {
+		return errors.New("injected failure")
+	}
+	t := &f.tables
+	needSchema := func() error {
+		if !t.schema {
+			return errors.New(`relation "runs" does not exist`)
+		}
+		return nil
+	}
+	jsonArg := func(i int) (string, error) {
+		s := args[i].(string)
+		if !json.Valid([]byte(s)) {
+			return "", errors.New("invalid input syntax for type json")
+		}
+		return s, nil
+	}
+	switch sql {
+	case sqlCreateMigrations:
+		t.migrationsTable = true
+	case sqlLockMigrations:
+		if !t.migrationsTable {
+			return errors.New(`relation "schema_migrations" does not exist`)
+		}
+	case sqlRecordMigration:
+		for _, v := range t.versions {
+			if v == args[0].(int) {
+				return errors.New("duplicate key value violates unique constraint")
+			}
+		}
+		t.versions = append(t.versions, args[0].(int))
+	case migrations[0]:
+		if t.schema {
+			return errors.New(`relation "runs" already exists`)
+		}
+		t.schema = true
+	case sqlUpsertRun:
+		if err := needSchema(); err != nil {
+			return err
+		}
+		summary, err := jsonArg(2)
+		if err != nil {
+			return err
+		}
+		t.runs[args[0].(string)] = [2]string{args[1].(string), summary}
+	case sqlDeleteRunAssets:
+		delete(t.assets, args[0].(string))
+	case sqlDeleteRunFinding:
+		delete(t.findings, args[0].(string))
+	case sqlUpsertAsset, sqlUpsertFinding:
+		if err := needSchema(); err != nil {
+			return err
+		}
+		id := args[0].(string)
+		if _, ok := t.runs[id]; !ok {
+			return errors.New("insert violates foreign key constraint")
+		}
+		if sql == sqlUpsertAsset {
+			data, err := jsonArg(2)
+			if err != nil {
+				return err
+			}
+			t.assets[id] = upsertRow(t.assets[id], fakeRow{fingerprint: args[1].(string), data: data})
+			break
+		}
+		data, err := jsonArg(4)
+		if err != nil {
+			return err
+		}
+		t.findings[id] = upsertRow(t.findings[id], fakeRow{fingerprint: args[1].(string), typ: args[2].(string), rank: args[3].(int), data: data})
+	default:
+		return fmt.Errorf("fake: unexpected statement %q", sql)
+	}
+	return nil
+}
+
+func (f *fakePG) Query(ctx context.Context, sql string, args ...interface{}) (pgRows, error) {
+	if f.failOn == sql {
+		return nil, errors.New("injected failure")
+	}
+	t := &f.tables
+	rows := &fakeRows{}
+	switch sql {
+	case sqlSchemaVersion:
+		max := 0
+		for _, v := range t.versions {
+			if v > max {
+				max = v
+			}
+		}
+		rows.add(max)
+	case sqlRunExists:
+		_, ok := t.runs[args[0].(string)]
+		n := 0
+		if ok {
+			n = 1
+		}
+		rows.add(n)
+	case sqlLoadRun:
+		if r, ok := t.runs[args[0].(string)]; ok {
+			rows.add(r[1])
+		}
+	case sqlLoadAssets, sqlLoadFindings:
+		table := t.assets
+		if sql == sqlLoadFindings {
+			table = t.findings
+		}
+		for _, r := range table[args[0].(string)] {
+			rows.add(r.data)
+		}
+	case sqlQueryFindings:
+		target, runID, typ, maxRank := args[0].(string), args[1].(string), args[2].(string), args[3].(int)
+		var ids []string
+		for id := range t.findings {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			run := t.runs[id]
+			for _, r := range t.findings[id] {
+				if (target == "" || run[0] == target) && (runID == "" || id == runID) && (typ == "" || r.typ == typ) && r.rank <= maxRank {
+					rows.add(id, run[0], r.data)
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("fake: unexpected query %q", sql)
+	}
+	return rows, nil
+}
+
+func (f *fakePG) InTx(ctx context.Context, fn func(pgQuerier) error) error {
+	saved := f.tables.clone()
+	if err := fn(f); err != nil {
+		f.tables = saved
+		return err
+	}
+	return nil
+}
+
+func (f *fakePG) Close() error {
+	f.closed = true
+	return nil
+}
+
+// fakeRows are the rows of a fake query.
+type fakeRows struct {
+	rows [][]interface{}
+	at   int
+}
+
+func (r *fakeRows) add(values ...interface{}) { r.rows = append(r.rows, values) }
+
+func (r *fakeRows) Next() bool {
+	r.at++
+	return r.at <= len(r.rows)
+}
+
+func (r *fakeRows) Scan(dest ...interface{}) error {
+	row := r.rows[r.at-1]
+	if len(dest) != len(row) {
+		return fmt.Errorf("fake: %d scan targets for %d columns", len(dest), len(row))
+	}
+	for i, d := range dest {
+		switch d := d.(type) {
+		case *int:
+			*d = row[i].(int)
+		case *string:
+			*d = row[i].(string)
+		default:
+			return fmt.Errorf("fake: cannot scan into %T", d)
+		}
+	}
+	return nil
+}
+
+func (r *fakeRows) Err() error { return nil }
+func (r *fakeRows) Close()     {}
+
+func TestPostgresStoreFake(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		s, err := newPostgresStore(context.Background(), newFakePG())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
+
+func TestPostgresMigrate(t *testing.T) {
+	db := newFakePG()
+	for i := 0; i < 2; i++ {
+		if _, err := newPostgresStore(context.Background(), db); err != nil {
+			t.Fatalf("open %d: %v", i+1, err)
+		}
+	}
+	if len(db.tables.versions) != len(migrations) || !db.tables.schema {
+		t.Errorf("versions %v recorded for %d migration(s)", db.tables.versions, len(migrations))
+	}
+
+	// A database migrated by a newer build is left alone.
+	db.tables.versions = append(db.tables.versions, len(migrations)+1)
+	if _, err := newPostgresStore(context.Background(), db); err == nil || !strings.Contains(err.Error(), "newer than this build") {
+		t.Errorf("newer schema: err = %v", err)
+	}
+
+	// A failed migration leaves nothing half applied.
+	db = newFakePG()
+	db.failOn = sqlRecordMigration
+	if _, err := newPostgresStore(context.Background(), db); err == nil {
+		t.Fatal("migration with a failing statement succeeded")
+	}
+	if db.tables.schema || len(db.tables.versions) != 0 {
+		t.Errorf("failed migration left schema %v, versions %v", db.tables.schema, db.tables.versions)
+	}
+}
+
+func TestPostgresSaveRunRollsBack(t *testing.T) {
+	db := newFakePG()
+	s, err := newPostgresStore(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const id = "example.com_20261001_120000"
+	result := func(report string) types.ScanResult {
+		return types.ScanResult{
+			Subdomains:  []types.SubdomainResult{{Hostname: "www.example.com"}},
+			VulnURLs:    []types.VulnerabilityResult{{URL: "https://www.example.com/", Issue: report, Type: "exposed-panel"}},
+			FinalReport: report,
+		}
+	}
+	if err := s.SaveRun(id, "example.com", result("first")); err != nil {
+		t.Fatal(err)
+	}
+	db.failOn = sqlUpsertFinding
+	if err := s.SaveRun(id, "example.com", result("second")); err == nil {
+		t.Fatal("SaveRun with a failing statement succeeded")
+	}
+	db.failOn = ""
+	got, err := s.LoadRun(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.FinalReport != "first" || len(got.VulnURLs) != 1 || len(got.Subdomains) != 1 {
+		t.Errorf("failed save was not rolled back: %+v", got)
+	}
+	if err := s.Close(); err != nil || !db.closed {
+		t.Errorf("Close: %v, closed %v", err, db.closed)
+	}
+}
+
+// TestPostgresStore runs the conformance suite against the database at
+// POSTGRES_TEST_DSN. It drops the store's tables first, so point it at a
+// throwaway database.
+func TestPostgresStore(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN not set")
+	}
+	testStore(t, func(t *testing.T) Store {
+		s, err := OpenPostgres(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.db.Exec(context.Background(), `DROP TABLE IF EXISTS findings, assets, runs, schema_migrations`); err != nil {
+			t.Fatal(err)
+		}
+		s.Close()
+		if s, err = OpenPostgres(dsn); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
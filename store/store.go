@@ -0,0 +1,138 @@
+// store/store.go - Where finished runs are persisted: run directories, and Postgres.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// ErrUnknownRun is returned for a run ID nothing was saved under.
+var ErrUnknownRun = errors.New("unknown run")
+
+// Store persists finished runs: the summary, the assets (subdomains) and
+// the findings of each, keyed on the run ID. A run ID is the run
+// directory's path relative to the directory runs are created in, such as
+// example.com_20261015_120000, or example.com_20261015_120000/reprocess_20261016_090000
+// for a reprocessed run.
+type Store interface {
+	// SaveRun creates or replaces the run id of target with result, its
+	// subdomains and findings included.
+	SaveRun(id, target string, result types.ScanResult) error
+	// SaveAssets adds assets to the saved run id, replacing one with the
+	// same fingerprint (its normalized hostname).
+	SaveAssets(id string, assets []types.SubdomainResult) error
+	// SaveFindings adds findings to the saved run id, replacing one with
+	// the same fingerprint.
+	SaveFindings(id string, findings []types.VulnerabilityResult) error
+	// LoadRun returns the saved run id.
+	LoadRun(id string) (types.ScanResult, error)
+	// Query returns the saved findings matching q, ordered by run ID and
+	// then as they were saved.
+	Query(q Query) ([]StoredFinding, error)
+	Close() error
+}
+
+// Query selects saved findings; empty fields match everything.
+type Query struct {
+	Target string
+	RunID  string
+	Type   string
+	// MinSeverity keeps findings at least this severe (Critical, High,
+	// Medium, Low or Info), rated by utils.FindingSeverity when saved.
+	MinSeverity string
+}
+
+// StoredFinding is a finding with the run it was saved in.
+type StoredFinding struct {
+	RunID   string
+	Target  string
+	Finding types.VulnerabilityResult
+}
+
+// minRank returns the highest severity rank q keeps.
+func (q Query) minRank() (int, error) {
+	if q.MinSeverity == "" {
+		return 4, nil
+	}
+	r, ok := utils.SeverityRank(q.MinSeverity)
+	if !ok {
+		return 0, fmt.Errorf("unknown severity %q", q.MinSeverity)
+	}
+	return r, nil
+}
+
+// runDirRe splits a run directory name into target and timestamp.
+var runDirRe = regexp.MustCompile(`^(.+)_\d{8}_\d{6}$`)
+
+// RunTarget returns the target a run ID or directory was created for, from
+// the name of its top-level directory (target_yyyymmdd_hhmmss); a name
+// without a timestamp is returned as it is.
+func RunTarget(id string) string {
+	name := strings.SplitN(filepath.ToSlash(filepath.Clean(id)), "/", 2)[0]
+	if m := runDirRe.FindStringSubmatch(name); m != nil {
+		return m[1]
+	}
+	return name
+}
+
+// IsRunDir reports whether name is named like a run directory.
+func IsRunDir(name string) bool {
+	return runDirRe.MatchString(name)
+}
+
+// checkRunID rejects IDs that are not a relative path below the store.
+func checkRunID(id string) error {
+	clean := filepath.ToSlash(filepath.Clean(id))
+	if id == "" || clean == "." || strings.HasPrefix(clean, "/") || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("invalid run ID %q", id)
+	}
+	return nil
+}
+
+// assetFingerprint identifies an asset across saves.
+func assetFingerprint(a types.SubdomainResult) string {
+	return utils.NormalizeHostname(a.Hostname)
+}
+
+// mergeAssets replaces the assets in have with the same fingerprint as one
+// in add and appends the others, keeping the order of have.
+func mergeAssets(have, add []types.SubdomainResult) []types.SubdomainResult {
+	at := make(map[string]int, len(have))
+	for i, a := range have {
+		at[assetFingerprint(a)] = i
+	}
+	for _, a := range add {
+		fp := assetFingerprint(a)
+		if i, ok := at[fp]; ok {
+			have[i] = a
+			continue
+		}
+		at[fp] = len(have)
+		have = append(have, a)
+	}
+	return have
+}
+
+// mergeFindings is mergeAssets for findings.
+func mergeFindings(have, add []types.VulnerabilityResult) []types.VulnerabilityResult {
+	at := make(map[string]int, len(have))
+	for i, v := range have {
+		at[utils.FindingFingerprint(v)] = i
+	}
+	for _, v := range add {
+		fp := utils.FindingFingerprint(v)
+		if i, ok := at[fp]; ok {
+			have[i] = v
+			continue
+		}
+		at[fp] = len(have)
+		have = append(have, v)
+	}
+	return have
+}
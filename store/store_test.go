@@ -0,0 +1,206 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// testStore is the conformance suite every Store implementation passes;
+// open returns an empty store.
+func testStore(t *testing.T, open func(t *testing.T) Store) {
+	const (
+		run1   = "example.com_20261001_120000"
+		run2   = "example.com_20261008_120000"
+		rerun  = run2 + "/reprocess_20261009_090000"
+		other  = "example.org_20261002_120000"
+		target = "example.com"
+	)
+	www := types.SubdomainResult{Hostname: "www.example.com", IP: "192.0.2.10", Ports: []int{443}}
+	app := types.SubdomainResult{Hostname: "app.example.com", IP: "192.0.2.11", Ports: []int{80, 443}}
+	sqli := types.VulnerabilityResult{URL: "https://app.example.com/item?id=1", Issue: "SQL Injection", Type: "sqli", Severity: "high"}
+	xss := types.VulnerabilityResult{URL: "https://app.example.com/search?q=x", Issue: "Reflected XSS", Type: "xss", Severity: "medium"}
+	panel := types.VulnerabilityResult{URL: "https://www.example.com/admin", Issue: "Exposed Panel", Type: "exposed-panel", Severity: "low"}
+
+	s := open(t)
+	defer s.Close()
+
+	if _, err := s.LoadRun(run1); !errors.Is(err, ErrUnknownRun) {
+		t.Errorf("LoadRun of an unsaved run: err = %v, want ErrUnknownRun", err)
+	}
+	if err := s.SaveAssets(run1, []types.SubdomainResult{www}); !errors.Is(err, ErrUnknownRun) {
+		t.Errorf("SaveAssets to an unsaved run: err = %v, want ErrUnknownRun", err)
+	}
+	if err := s.SaveFindings(run1, []types.VulnerabilityResult{sqli}); !errors.Is(err, ErrUnknownRun) {
+		t.Errorf("SaveFindings to an unsaved run: err = %v, want ErrUnknownRun", err)
+	}
+	for _, id := range []string{"", "../escape_20261001_120000", "/abs_20261001_120000"} {
+		if err := s.SaveRun(id, target, types.ScanResult{}); err == nil {
+			t.Errorf("SaveRun(%q) accepted an invalid ID", id)
+		}
+	}
+
+	saved := types.ScanResult{
+		Subdomains:  []types.SubdomainResult{www, app},
+		VulnURLs:    []types.VulnerabilityResult{sqli, xss},
+		AllURLs:     []string{"https://www.example.com/", "https://app.example.com/item?id=1"},
+		FinalReport: "Final report for example.com",
+	}
+	if err := s.SaveRun(run1, target, saved); err != nil {
+		t.Fatal(err)
+	}
+	checkRun(t, s, run1, saved)
+
+	// Assets and findings are upserted on their fingerprints, in place.
+	www2 := www
+	www2.IP = "192.0.2.20"
+	api := types.SubdomainResult{Hostname: "api.example.com", IP: "192.0.2.12"}
+	if err := s.SaveAssets(run1, []types.SubdomainResult{www2, api}); err != nil {
+		t.Fatal(err)
+	}
+	sqli2 := sqli
+	sqli2.Detail = "parameter id, time-based blind"
+	if err := s.SaveFindings(run1, []types.VulnerabilityResult{sqli2, panel}); err != nil {
+		t.Fatal(err)
+	}
+	upserted := saved
+	upserted.Subdomains = []types.SubdomainResult{www2, app, api}
+	upserted.VulnURLs = []types.VulnerabilityResult{sqli2, xss, panel}
+	checkRun(t, s, run1, upserted)
+
+	// Saving the run again replaces it.
+	replaced := types.ScanResult{Subdomains: []types.SubdomainResult{app}, VulnURLs: []types.VulnerabilityResult{xss}, FinalReport: "again"}
+	if err := s.SaveRun(run1, target, replaced); err != nil {
+		t.Fatal(err)
+	}
+	checkRun(t, s, run1, replaced)
+
+	for _, r := range []struct {
+		id, target string
+		vulns      []types.VulnerabilityResult
+	}{
+		{run2, target, []types.VulnerabilityResult{sqli, panel}},
+		{rerun, target, []types.VulnerabilityResult{sqli2}},
+		{other, "example.org", []types.VulnerabilityResult{{URL: "https://example.org/", Issue: "Exposed Panel", Type: "exposed-panel", Severity: "critical"}}},
+	} {
+		if err := s.SaveRun(r.id, r.target, types.ScanResult{VulnURLs: r.vulns}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type hit struct{ run, target, issue string }
+	tests := []struct {
+		name string
+		q    Query
+		want []hit
+	}{
+		{"everything", Query{}, []hit{
+			{run1, target, "Reflected XSS"},
+			{run2, target, "SQL Injection"}, {run2, target, "Exposed Panel"},
+			{rerun, target, "SQL Injection"},
+			{other, "example.org", "Exposed Panel"},
+		}},
+		{"target", Query{Target: "example.org"}, []hit{{other, "example.org", "Exposed Panel"}}},
+		{"run", Query{RunID: rerun}, []hit{{rerun, target, "SQL Injection"}}},
+		{"type", Query{Target: target, Type: "exposed-panel"}, []hit{{run2, target, "Exposed Panel"}}},
+		{"severity", Query{MinSeverity: "High"}, []hit{
+			{run2, target, "SQL Injection"}, {rerun, target, "SQL Injection"}, {other, "example.org", "Exposed Panel"},
+		}},
+		{"nothing", Query{Target: "example.net"}, nil},
+	}
+	for _, tt := range tests {
+		found, err := s.Query(tt.q)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var got []hit
+		for _, f := range found {
+			got = append(got, hit{f.RunID, f.Target, f.Finding.Issue})
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if found, _ := s.Query(Query{RunID: rerun}); len(found) == 1 && !reflect.DeepEqual(found[0].Finding, sqli2) {
+		t.Errorf("queried finding %+v, want %+v", found[0].Finding, sqli2)
+	}
+	if _, err := s.Query(Query{MinSeverity: "Severe"}); err == nil {
+		t.Error("Query accepted an unknown severity")
+	}
+}
+
+// checkRun compares the saved run id with want.
+func checkRun(t *testing.T, s Store, id string, want types.ScanResult) {
+	t.Helper()
+	got, err := s.LoadRun(id)
+	if err != nil {
+		t.Fatalf("LoadRun(%s): %v", id, err)
+	}
+	if !reflect.DeepEqual(got.Subdomains, want.Subdomains) {
+		t.Errorf("%s subdomains: %+v, want %+v", id, got.Subdomains, want.Subdomains)
+	}
+	if !reflect.DeepEqual(got.VulnURLs, want.VulnURLs) {
+		t.Errorf("%s findings: %+v, want %+v", id, got.VulnURLs, want.VulnURLs)
+	}
+	if !reflect.DeepEqual(got.AllURLs, want.AllURLs) || got.FinalReport != want.FinalReport {
+		t.Errorf("%s summary: URLs %q, report %q; want %q, %q", id, got.AllURLs, got.FinalReport, want.AllURLs, want.FinalReport)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store { return NewFileStore(t.TempDir()) })
+}
+
+func TestFileStoreLayout(t *testing.T) {
+	root := t.TempDir()
+	s := NewFileStore(root)
+	if err := s.SaveRun("example.com_20261001_120000", "example.org", types.ScanResult{}); err == nil {
+		t.Error("saved a run under another target's name")
+	}
+	if err := s.SaveRun("example.com_20261001_120000", "example.com", types.ScanResult{FinalReport: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "example.com_20261001_120000", "summary.json")); err != nil {
+		t.Errorf("run not kept in its directory: %v", err)
+	}
+	// A damaged summary is not saved over.
+	file := filepath.Join(root, "example.com_20261001_120000", "summary.json")
+	if err := os.WriteFile(file, []byte(`{"subdomains": [`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveFindings("example.com_20261001_120000", []types.VulnerabilityResult{{Issue: "x"}}); err == nil {
+		t.Error("saved findings over a damaged summary")
+	}
+}
+
+func TestRunTarget(t *testing.T) {
+	for _, tt := range []struct{ id, want string }{
+		{"example.com_20261001_120000", "example.com"},
+		{"example.com_20261001_120000/reprocess_20261002_080000", "example.com"},
+		{"my_site.example.com_20261001_120000", "my_site.example.com"},
+		{"scratch", "scratch"},
+	} {
+		if got := RunTarget(tt.id); got != tt.want {
+			t.Errorf("RunTarget(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		name string
+		want bool
+	}{
+		{"example.com_20261001_120000", true},
+		{"my_site.example.com_20261001_120000", true},
+		{"example.com_20261001", false},
+		{"reprocess_2026100_120000", false},
+		{"example.com_kb.tar.gz", false},
+	} {
+		if got := IsRunDir(tt.name); got != tt.want {
+			t.Errorf("IsRunDir(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
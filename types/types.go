@@ -0,0 +1,516 @@
+// Package types holds the data structures shared by the scan pipeline and
+// the packages that fill them in: the scan result, its hosts and findings.
+package types
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type ScanResult struct {
+	Subdomains     []SubdomainResult     `json:"subdomains"`
+	VulnURLs       []VulnerabilityResult `json:"vuln_urls"`
+	FfufEntries    []FfufResult          `json:"ffuf_entries"`
+	AllURLs        []string              `json:"all_urls"`
+	LogLines       []string              `json:"log_lines"`
+	FinalReport    string                `json:"final_report"`
+	Running        bool                  `json:"running"`
+	ProxyEnabled   bool                  `json:"proxy_enabled"`
+	SourceStats    []SourceStats         `json:"source_stats"`
+	Stages         []StageStatus         `json:"stages"`
+	Reconciliation *Reconciliation       `json:"reconciliation,omitempty"`
+	TLSPosture     []TLSPosture          `json:"tls_posture"`
+	Authorization  *Authorization        `json:"authorization"`
+	APIUsage       []ProviderUsage       `json:"api_usage"`
+	Methods        []EndpointMethods     `json:"methods"`
+	LiveHosts      []LiveHost            `json:"live_hosts"`
+	Coverage       []StageCoverage       `json:"coverage"`
+	TLS            []TLSResult           `json:"tls"`
+	Parameters     []EndpointParameters  `json:"parameters"`
+	URLRecords     []URLRecord           `json:"url_records"`
+	// WAFDetected is set when any web service is behind a WAF; fuzzing and
+	// injection testing then run with gentler settings.
+	WAFDetected bool `json:"waf_detected"`
+	// Preflight is the summary of the run shown before it started.
+	Preflight string `json:"preflight,omitempty"`
+	// SecurityTxt, HumansTxt and SaaS are the disclosure and ownership
+	// metadata the target publishes.
+	SecurityTxt []SecurityTxt `json:"security_txt"`
+	HumansTxt   []HumansTxt   `json:"humans_txt"`
+	SaaS        []SaaSService `json:"saas"`
+	// ParameterizedURLs are the URLs with parameters ParamSpider mined from
+	// the web archives; sqlmap and dalfox test them first.
+	ParameterizedURLs []string `json:"parameterized_urls"`
+	// ResponseAnomalies are hosts answering a path unlike the other hosts
+	// serving the same application.
+	ResponseAnomalies []ResponseAnomaly `json:"response_anomalies,omitempty"`
+	// HostTimings are the request phase timings per host.
+	HostTimings []HostTiming `json:"host_timings,omitempty"`
+	// Secrets are the exposed secrets of every detector, merged.
+	Secrets []SecretFinding `json:"secrets,omitempty"`
+	// ScanDefinition is the effective scan.yaml of a run started with
+	// `recon run -f`.
+	ScanDefinition *ScanDefinition `json:"scan_definition,omitempty"`
+}
+
+// ResponseSample is one host's answer for a path. Group names the
+// application the host serves, as fingerprinted by HTTP probing and
+// whatweb; Path is normalized so the same path matches across hosts.
+type ResponseSample struct {
+	Group  string `json:"group"`
+	Path   string `json:"path"`
+	Host   string `json:"host"`
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+	Size   int64  `json:"size"`
+}
+
+// ResponseAnomaly is a sample that stands out from its group's answers for
+// the same path. Peers are those answers, the anomaly included.
+type ResponseAnomaly struct {
+	Sample ResponseSample   `json:"sample"`
+	Reason string           `json:"reason"`
+	Peers  []ResponseSample `json:"peers"`
+}
+
+// StageCoverage counts how much of the known surface a stage exercised.
+// Known is the number of distinct candidates, and always equals Attempted
+// plus the Skipped counts, which are keyed by reason. Completed counts the
+// attempts that got an answer.
+type StageCoverage struct {
+	Stage     string         `json:"stage"`
+	Known     int            `json:"known"`
+	Attempted int            `json:"attempted"`
+	Completed int            `json:"completed"`
+	Skipped   map[string]int `json:"skipped,omitempty"`
+}
+
+// LiveHost is the HTTP probe result for one origin of a live host. URL is
+// empty when the host did not answer on port 443 or 80; origins on other
+// ports are only recorded when they answered.
+type LiveHost struct {
+	Hostname      string `json:"hostname"`
+	Port          int    `json:"port,omitempty"`
+	URL           string `json:"url,omitempty"`
+	StatusCode    int    `json:"status_code,omitempty"`
+	ContentLength int64  `json:"content_length,omitempty"`
+	Title         string `json:"title,omitempty"`
+	Server        string `json:"server,omitempty"`
+	// Protocols are the HTTP versions the origin was seen to speak: h3, h2
+	// and http/1.1.
+	Protocols []string `json:"protocols,omitempty"`
+	// WAF names the web application firewall wafw00f found in front of the origin.
+	WAF string `json:"waf,omitempty"`
+	// Technologies is the stack whatweb fingerprinted on the origin.
+	Technologies []Technology        `json:"technologies,omitempty"`
+	Error        string              `json:"error,omitempty"`
+	Tags         map[string][]string `json:"tags,omitempty"`
+	// Soft404 is what the origin answers for paths that do not exist, when
+	// that is not a plain 404.
+	Soft404 *Soft404 `json:"soft_404,omitempty"`
+	// Placeholder names the parked domain or default page signature the
+	// origin's page matched.
+	Placeholder string `json:"placeholder,omitempty"`
+	// Auth is the authentication state of the origin's root page.
+	Auth string `json:"auth,omitempty"`
+}
+
+// SecretFinding is an exposed secret as every detector reports it. The
+// value is only ever kept masked; the same masked value found by several
+// detectors or in several places is one finding with several locations.
+type SecretFinding struct {
+	// Class is the provider or kind of secret, such as aws or github (see
+	// utils.SecretClass).
+	Class  string `json:"class"`
+	Masked string `json:"masked"`
+	// Hash identifies the masked value across sources.
+	Hash      string           `json:"hash"`
+	Locations []SecretLocation `json:"locations"`
+	// Confidence is confirmed, firm or tentative and Verification verified,
+	// rejected or unverified, the strongest any detector reported.
+	Confidence   string `json:"confidence"`
+	Verification string `json:"verification"`
+}
+
+// SecretLocation is where a detector found a secret: the artifact (a
+// script, repository or file URL), the place in it such as a line or
+// commit, and the detector and rule that matched.
+type SecretLocation struct {
+	Artifact string `json:"artifact"`
+	Location string `json:"location,omitempty"`
+	Detector string `json:"detector"`
+}
+
+// HostTiming aggregates where the time of the requests sent to one host
+// went, phase by phase.
+type HostTiming struct {
+	Host     string      `json:"host"`
+	Requests int         `json:"requests"`
+	DNS      PhaseTiming `json:"dns"`
+	Connect  PhaseTiming `json:"connect"`
+	TLS      PhaseTiming `json:"tls"`
+	// TTFB is the server's wait: from the request written to the first
+	// byte of the response.
+	TTFB PhaseTiming `json:"ttfb"`
+}
+
+// PhaseTiming is the median and 95th percentile, in milliseconds, of one
+// phase over the Count requests it took place in. Reused connections skip
+// DNS, connect and TLS.
+type PhaseTiming struct {
+	Count    int     `json:"count"`
+	MedianMS float64 `json:"median_ms"`
+	P95MS    float64 `json:"p95_ms"`
+}
+
+// Soft404 fingerprints an origin's answer to nonexistent paths, such as a
+// 200 "page not found" page or a redirect to the home page, from two
+// random paths. Lengths leave out where the page echoes the path back.
+type Soft404 struct {
+	Status    int    `json:"status"`
+	MinLength int    `json:"min_length"`
+	MaxLength int    `json:"max_length"`
+	Title     string `json:"title,omitempty"`
+	// BodyHash is a similarity hash of the normalized body: pages that
+	// differ in a few words hash a few bits apart.
+	BodyHash uint64 `json:"body_hash,string"`
+	// Reflections is how often the page contains the requested path.
+	Reflections int `json:"reflections,omitempty"`
+}
+
+// Technology is one whatweb plugin match, such as nginx 1.18.0 or
+// WordPress 5.9. String holds what the plugin matched when it reports no
+// version.
+type Technology struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	String  string `json:"string,omitempty"`
+}
+
+// WebTechnologies is the stack fingerprinted on one URL (technologies.json).
+type WebTechnologies struct {
+	URL          string       `json:"url"`
+	Technologies []Technology `json:"technologies"`
+}
+
+// SecurityTxt is a security.txt file (RFC 9116) served by a main origin.
+// Signed is set when the file was wrapped in an OpenPGP cleartext
+// signature; the signature itself is not verified.
+type SecurityTxt struct {
+	URL                string   `json:"url"`
+	Contacts           []string `json:"contacts"`
+	Policy             []string `json:"policy,omitempty"`
+	Encryption         []string `json:"encryption,omitempty"`
+	Acknowledgments    []string `json:"acknowledgments,omitempty"`
+	Hiring             []string `json:"hiring,omitempty"`
+	Canonical          []string `json:"canonical,omitempty"`
+	PreferredLanguages string   `json:"preferred_languages,omitempty"`
+	Expires            string   `json:"expires,omitempty"`
+	Expired            bool     `json:"expired"`
+	Signed             bool     `json:"signed"`
+}
+
+// HumansTxt is the start of a humans.txt file, which often names the team
+// and the software behind a site.
+type HumansTxt struct {
+	URL   string   `json:"url"`
+	Lines []string `json:"lines"`
+}
+
+// SaaSService is a third-party service the target's DNS TXT records show
+// it uses: a domain verification token or an SPF include.
+type SaaSService struct {
+	Service string `json:"service"`
+	Domain  string `json:"domain"`
+	Record  string `json:"record"`
+}
+
+// ProviderUsage accounts for the queries a metered API provider was sent.
+// Credit figures are omitted when the provider's quota could not be read.
+type ProviderUsage struct {
+	Provider      string   `json:"provider"`
+	Estimated     int      `json:"estimated_queries"`
+	Queries       int      `json:"queries"`
+	Budget        int      `json:"budget,omitempty"`
+	CreditsBefore *int     `json:"credits_before,omitempty"`
+	CreditsAfter  *int     `json:"credits_after,omitempty"`
+	Warnings      []string `json:"warnings,omitempty"`
+}
+
+// Authorization is the engagement authorization active stages require.
+// Source is config (the AUTH_* settings) or interactive (--i-am-authorized).
+// Downgraded explains why an authorized run was limited to passive stages.
+type Authorization struct {
+	EngagementRef string    `json:"engagement_ref,omitempty"`
+	AuthorizedBy  string    `json:"authorized_by,omitempty"`
+	ValidFrom     string    `json:"valid_from,omitempty"`
+	ValidUntil    string    `json:"valid_until,omitempty"`
+	Source        string    `json:"source"`
+	ConfirmedAt   time.Time `json:"confirmed_at"`
+	Downgraded    string    `json:"downgraded,omitempty"`
+}
+
+// ScanDefinition is a scan declared in a scan.yaml for `recon run -f`.
+// As recorded in a run it is the effective definition: File and SHA256
+// identify the file, Profile is the one loaded after --profile, Overridden
+// names the flags that won over the file, and interpolated values keep
+// their ${VAR} reference so secrets stay out of the run.
+type ScanDefinition struct {
+	File         string            `yaml:"-" json:"file"`
+	SHA256       string            `yaml:"-" json:"sha256"`
+	Targets      []string          `yaml:"targets" json:"targets"`
+	Profile      string            `yaml:"profile" json:"profile,omitempty"`
+	Scope        ScanScope         `yaml:"scope" json:"scope"`
+	Stages       ScanStages        `yaml:"stages" json:"stages"`
+	Severity     SeverityPolicy    `yaml:"severity" json:"severity"`
+	Suppressions []Suppression     `yaml:"suppressions" json:"suppressions,omitempty"`
+	Notify       []NotifyRoute     `yaml:"notify" json:"notify,omitempty"`
+	Settings     map[string]string `yaml:"settings" json:"settings,omitempty"`
+	Overridden   []string          `yaml:"-" json:"overridden,omitempty"`
+}
+
+// ScanScope is a scan definition's scope: the SCOPE_ALLOW, SCOPE_DENY and
+// SCOPE_DENY_TAGS settings as lists.
+type ScanScope struct {
+	Allow    []string `yaml:"allow" json:"allow,omitempty"`
+	Deny     []string `yaml:"deny" json:"deny,omitempty"`
+	DenyTags []string `yaml:"deny_tags" json:"deny_tags,omitempty"`
+}
+
+// ScanStages skips pipeline stages by name, or with Only runs just the
+// stages named.
+type ScanStages struct {
+	Skip []string `yaml:"skip" json:"skip,omitempty"`
+	Only []string `yaml:"only" json:"only,omitempty"`
+}
+
+// SeverityPolicy sets SEVERITY_FROM_CVSS and NUCLEI_SEVERITY, and rates
+// the findings of the issue types in Overrides as given.
+type SeverityPolicy struct {
+	FromCVSS  *bool             `yaml:"from_cvss" json:"from_cvss,omitempty"`
+	Nuclei    []string          `yaml:"nuclei" json:"nuclei,omitempty"`
+	Overrides map[string]string `yaml:"overrides" json:"overrides,omitempty"`
+}
+
+// Suppression marks the findings matching every one of its set fields a
+// false positive: Type is a taxonomy ID, Host a hostname glob and URL a
+// URL prefix. Reason is required and becomes the triage note.
+type Suppression struct {
+	Type   string `yaml:"type" json:"type,omitempty"`
+	Host   string `yaml:"host" json:"host,omitempty"`
+	URL    string `yaml:"url" json:"url,omitempty"`
+	Reason string `yaml:"reason" json:"reason"`
+}
+
+// NotifyRoute posts the run's findings of at least MinSeverity, and of
+// one of Types when set, to a webhook once the scan completes.
+type NotifyRoute struct {
+	Webhook     string   `yaml:"webhook" json:"webhook"`
+	MinSeverity string   `yaml:"min_severity" json:"min_severity,omitempty"`
+	Types       []string `yaml:"types" json:"types,omitempty"`
+}
+
+// TLSPosture records the TLS protocol versions a host accepts and its ciphers.
+type TLSPosture struct {
+	Host          string   `json:"host"`
+	Port          int      `json:"port,omitempty"`
+	Versions      []string `json:"versions"`
+	DefaultCipher string   `json:"default_cipher"`
+	WeakCiphers   []string `json:"weak_ciphers,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// TLSResult is one testssl.sh finding of MEDIUM severity or higher.
+type TLSResult struct {
+	Host     string `json:"host"`
+	ID       string `json:"id"`
+	Severity string `json:"severity"`
+	Finding  string `json:"finding"`
+}
+
+// EndpointMethods records the methods an endpoint advertises to OPTIONS.
+type EndpointMethods struct {
+	URL     string   `json:"url"`
+	Allowed []string `json:"allowed"`
+}
+
+// EndpointParameters records the parameter names Arjun found an endpoint
+// to accept.
+type EndpointParameters struct {
+	URL    string   `json:"url"`
+	Params []string `json:"params"`
+}
+
+// URLRecord attributes an archived URL to the source that returned it and,
+// for the Wayback Machine, the time it was captured. URLs extracted from
+// JavaScript name the script they were found in as Referrer.
+type URLRecord struct {
+	URL      string    `json:"url"`
+	Source   string    `json:"source"`
+	Captured time.Time `json:"captured,omitempty"`
+	Referrer string    `json:"referrer,omitempty"`
+	// Auth is the page's authentication state: login-wall, public or
+	// authenticated (see scanners.ClassifyAuthPage).
+	Auth string `json:"auth,omitempty"`
+}
+
+// APIRequestTemplate is a captured API request (--api-templates). The JSON
+// body injection checks fill in its string fields one at a time.
+type APIRequestTemplate struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is the JSON request body, as a JSON value or a string holding
+	// one.
+	Body json.RawMessage `json:"body"`
+}
+
+// InventoryEntry is one row of the expected-asset inventory (--inventory).
+// Hostname may be a "*.example.com" wildcard.
+type InventoryEntry struct {
+	Hostname    string `json:"hostname"`
+	Owner       string `json:"owner,omitempty"`
+	Environment string `json:"environment,omitempty"`
+}
+
+// InventoryMatch is a discovered host together with the inventory row covering it.
+type InventoryMatch struct {
+	Hostname     string `json:"hostname"`
+	InventoryRow string `json:"inventory_row"`
+	Owner        string `json:"owner,omitempty"`
+	Environment  string `json:"environment,omitempty"`
+}
+
+// Reconciliation compares discovered assets against the inventory.
+type Reconciliation struct {
+	Matched []InventoryMatch `json:"matched"`
+	Unknown []string         `json:"discovered_unknown"`
+	Unseen  []InventoryEntry `json:"inventoried_unseen"`
+}
+
+// StageStatus records how a pipeline stage finished and why.
+// Status is one of completed, completed-with-warnings, completed-with-errors,
+// failed-validation, failed (the stage panicked) or skipped.
+type StageStatus struct {
+	Stage    string   `json:"stage"`
+	Status   string   `json:"status"`
+	Messages []string `json:"messages,omitempty"`
+}
+
+type SubdomainResult struct {
+	Hostname string `json:"hostname"`
+	// IP is the address shown for the host: the first of IPs, or of IPv6
+	// without any, and empty when the name did not resolve.
+	IP string `json:"ip"`
+	// IPs are the host's A records, IPv6 its AAAA records and CNAME the
+	// chain of names it resolves through, nearest first.
+	IPs      []string      `json:"ips,omitempty"`
+	IPv6     []string      `json:"ipv6,omitempty"`
+	CNAME    []string      `json:"cname,omitempty"`
+	Ports    []int         `json:"ports"`
+	Services []PortService `json:"services,omitempty"`
+	// Tags are the asset's labels by namespace (see utils/tags.go).
+	Tags map[string][]string `json:"tags,omitempty"`
+	// Source lists the enumeration tools that found the host, with
+	// subfinder's passive sources as subfinder/<source>.
+	Source string `json:"source,omitempty"`
+}
+
+// DNSRecord is the resolution of one hostname: its A and AAAA records and
+// CNAME chain.
+type DNSRecord struct {
+	Host  string   `json:"host"`
+	A     []string `json:"a,omitempty"`
+	AAAA  []string `json:"aaaa,omitempty"`
+	CNAME []string `json:"cname,omitempty"`
+}
+
+// PortService is one port nmap reported for a host. Banner is what the
+// service sent when connected to, sanitized, and Product and Version what
+// the banner names.
+type PortService struct {
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+	State    string `json:"state"`
+	Service  string `json:"service,omitempty"`
+	Banner   string `json:"banner,omitempty"`
+	Product  string `json:"product,omitempty"`
+	Version  string `json:"version,omitempty"`
+}
+
+// ScannedHost is one host reported by a port scanner (nmap, masscan, naabu
+// or the native connect scan).
+type ScannedHost struct {
+	Address   string        `json:"address"`
+	Hostnames []string      `json:"hostnames,omitempty"`
+	TimedOut  bool          `json:"timed_out,omitempty"`
+	Ports     []PortService `json:"ports"`
+}
+
+// HostPorts is the port scan outcome for one live host (ports.json).
+type HostPorts struct {
+	Hostname string        `json:"hostname"`
+	IP       string        `json:"ip,omitempty"`
+	Ports    []PortService `json:"ports"`
+	Error    string        `json:"error,omitempty"`
+}
+
+type VulnerabilityResult struct {
+	URL   string `json:"url"`
+	Issue string `json:"issue"`
+	// Type is the taxonomy ID of the issue (see utils/taxonomy.go) and CWE
+	// the weakness it maps to.
+	Type   string  `json:"type"`
+	CWE    int     `json:"cwe,omitempty"`
+	Detail string  `json:"detail"`
+	Vector string  `json:"cvss_vector,omitempty"`
+	Score  float64 `json:"cvss_score,omitempty"`
+	// Severity is the tool-reported severity, when the tool rates its findings.
+	Severity string `json:"severity,omitempty"`
+	// Confidence is how sure the check is that the issue exists: confirmed,
+	// firm or tentative (see utils.FindingConfidence).
+	Confidence string `json:"confidence,omitempty"`
+	// Disposition and TriageNote are carried over from the triage store.
+	Disposition string `json:"disposition,omitempty"`
+	TriageNote  string `json:"triage_note,omitempty"`
+	// Tags are inherited from the affected host.
+	Tags map[string][]string `json:"tags,omitempty"`
+	// Source names the external scanner a finding was imported from, such
+	// as nessus; empty for findings of this tool's own stages.
+	Source string `json:"source,omitempty"`
+	// Parameter, Technique and Payload describe an injection point: the
+	// parameter with its place, the techniques that worked and an example
+	// payload.
+	Parameter string `json:"parameter,omitempty"`
+	Technique string `json:"technique,omitempty"`
+	Payload   string `json:"payload,omitempty"`
+}
+
+// SourceStats records how much a single enumeration source contributed.
+type SourceStats struct {
+	Source         string  `json:"source"`
+	Total          int     `json:"total"`
+	Unique         int     `json:"unique"`
+	RuntimeSeconds float64 `json:"runtime_seconds"`
+}
+
+// ShodanHost is the subset of Shodan's host response the tool uses.
+type ShodanHost struct {
+	IP        string   `json:"ip_str"`
+	Ports     []int    `json:"ports"`
+	Hostnames []string `json:"hostnames"`
+	Org       string   `json:"org"`
+	ISP       string   `json:"isp"`
+	Vulns     []string `json:"vulns"`
+}
+
+type FfufResult struct {
+	Path   string `json:"path"`
+	Status int    `json:"status"`
+	Size   int    `json:"size"`
+	Words  int    `json:"words"`
+	Lines  int    `json:"lines"`
+	URL    string `json:"url,omitempty"`
+	// RedirectLocation is the target of a 3xx answer.
+	RedirectLocation string `json:"redirect_location,omitempty"`
+}
@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// statusShare is how much of a group must answer a path with one status
+// before the hosts answering otherwise stand out.
+const statusShare = 0.8
+
+// maxAnomalyPeers bounds the comparison table in a finding's detail.
+const maxAnomalyPeers = 40
+
+// AnomalyThresholds decide when a response stands out. Groups of fewer
+// than MinGroup hosts are too small to judge. A size is an outlier when
+// its modified z-score (deviation from the median over the median
+// absolute deviation) reaches ZScore and it is at least MinBytes off the
+// median; ZScore 0 leaves only the absolute deviation.
+type AnomalyThresholds struct {
+	MinGroup int
+	ZScore   float64
+	MinBytes int64
+}
+
+// AnomalyThresholdsFromEnv reads ANOMALY_MIN_GROUP (default 5),
+// ANOMALY_ZSCORE (default 3.5) and ANOMALY_MIN_BYTES (default 1024).
+func AnomalyThresholdsFromEnv() AnomalyThresholds {
+	th := AnomalyThresholds{MinGroup: 5, ZScore: 3.5, MinBytes: 1024}
+	if n, err := strconv.Atoi(os.Getenv("ANOMALY_MIN_GROUP")); err == nil && n >= 3 {
+		th.MinGroup = n
+	}
+	if f, err := strconv.ParseFloat(os.Getenv("ANOMALY_ZSCORE"), 64); err == nil && f >= 0 {
+		th.ZScore = f
+	}
+	if n, err := strconv.ParseInt(os.Getenv("ANOMALY_MIN_BYTES"), 10, 64); err == nil && n >= 0 {
+		th.MinBytes = n
+	}
+	return th
+}
+
+// ApplicationGroup names the application an origin serves by its Server
+// header and the names of the technologies whatweb found, versions left
+// out so that differently patched instances still compare. It is empty
+// when neither is known.
+func ApplicationGroup(lh types.LiveHost) string {
+	var techs []string
+	for _, t := range lh.Technologies {
+		techs = append(techs, t.Name)
+	}
+	techs = UniqueStrings(techs)
+	sort.Strings(techs)
+	var parts []string
+	if server := strings.TrimSpace(lh.Server); server != "" {
+		parts = append(parts, server)
+	}
+	if len(techs) > 0 {
+		parts = append(parts, strings.Join(techs, ", "))
+	}
+	return strings.Join(parts, " | ")
+}
+
+// NormalizeResponsePath returns the path of rawURL without query, fragment,
+// repeated or trailing slashes, so the same path matches across hosts.
+func NormalizeResponsePath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Path == "" {
+		return "/"
+	}
+	return path.Clean("/" + u.Path)
+}
+
+// ResponseSamples collects every origin's answer for / from HTTP probing
+// and for each fuzzed path, grouped by the application the origin serves.
+// Origins without a fingerprint have no group to compare against and are
+// left out.
+func ResponseSamples(result types.ScanResult) []types.ResponseSample {
+	groups := make(map[string]string)
+	var samples []types.ResponseSample
+	for _, lh := range result.LiveHosts {
+		u, err := url.Parse(lh.URL)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		group := ApplicationGroup(lh)
+		if group == "" {
+			continue
+		}
+		groups[u.Host] = group
+		if lh.StatusCode > 0 {
+			samples = append(samples, types.ResponseSample{Group: group, Path: "/", Host: u.Host, URL: lh.URL + "/", Status: lh.StatusCode, Size: lh.ContentLength})
+		}
+	}
+	for _, f := range result.FfufEntries {
+		u, err := url.Parse(f.URL)
+		if err != nil || groups[u.Host] == "" {
+			continue
+		}
+		samples = append(samples, types.ResponseSample{Group: groups[u.Host], Path: NormalizeResponsePath(f.URL), Host: u.Host, URL: f.URL, Status: f.Status, Size: int64(f.Size)})
+	}
+	return samples
+}
+
+// FindResponseAnomalies compares the hosts of each group path by path.
+// When at least 80% of them answer with one status, the others are
+// anomalies; the sizes of the answers with that status are then checked
+// against th. Each host counts once per path. Paths answered by fewer than
+// th.MinGroup hosts are skipped and counted in skipped. The result does
+// not depend on the order of samples.
+func FindResponseAnomalies(samples []types.ResponseSample, th AnomalyThresholds) (anomalies []types.ResponseAnomaly, skipped int) {
+	sorted := append([]types.ResponseSample(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Status != b.Status {
+			return a.Status < b.Status
+		}
+		return a.Size < b.Size
+	})
+	for start := 0; start < len(sorted); {
+		end := start
+		for end < len(sorted) && sorted[end].Group == sorted[start].Group && sorted[end].Path == sorted[start].Path {
+			end++
+		}
+		var peers []types.ResponseSample
+		for i := start; i < end; i++ {
+			if i == start || sorted[i].Host != sorted[i-1].Host {
+				peers = append(peers, sorted[i])
+			}
+		}
+		start = end
+		if len(peers) < th.MinGroup {
+			skipped++
+			continue
+		}
+		anomalies = append(anomalies, judgeGroup(peers, th)...)
+	}
+	return anomalies, skipped
+}
+
+// judgeGroup returns the anomalies among one group's answers for a path.
+func judgeGroup(peers []types.ResponseSample, th AnomalyThresholds) []types.ResponseAnomaly {
+	counts := make(map[int]int)
+	for _, p := range peers {
+		counts[p.Status]++
+	}
+	mode := 0
+	for status, n := range counts {
+		if n > counts[mode] || (n == counts[mode] && status < mode) {
+			mode = status
+		}
+	}
+	if float64(counts[mode]) < statusShare*float64(len(peers)) {
+		return nil
+	}
+	var (
+		anomalies []types.ResponseAnomaly
+		same      []types.ResponseSample
+	)
+	for _, p := range peers {
+		if p.Status != mode {
+			anomalies = append(anomalies, types.ResponseAnomaly{
+				Sample: p,
+				Reason: fmt.Sprintf("status %d where %d of %d hosts answer %d", p.Status, counts[mode], len(peers), mode),
+				Peers:  peers,
+			})
+		} else if p.Size >= 0 {
+			same = append(same, p)
+		}
+	}
+	if len(same) < th.MinGroup {
+		return anomalies
+	}
+	sizes := make([]float64, len(same))
+	for i, p := range same {
+		sizes[i] = float64(p.Size)
+	}
+	median := medianOf(sizes)
+	deviations := make([]float64, len(sizes))
+	for i, s := range sizes {
+		deviations[i] = math.Abs(s - median)
+	}
+	mad := medianOf(deviations)
+	for i, p := range same {
+		if deviations[i] < float64(th.MinBytes) || deviations[i] == 0 {
+			continue
+		}
+		// 0.6745 scales the MAD to the standard deviation of normal data.
+		z := math.Inf(1)
+		if mad > 0 {
+			z = 0.6745 * deviations[i] / mad
+		}
+		if z < th.ZScore {
+			continue
+		}
+		score := "most hosts identical"
+		if mad > 0 {
+			score = fmt.Sprintf("modified z-score %.1f", z)
+		}
+		anomalies = append(anomalies, types.ResponseAnomaly{
+			Sample: p,
+			Reason: fmt.Sprintf("%d bytes where the median of %d hosts answering %d is %.0f (%s)", p.Size, len(same), mode, median, score),
+			Peers:  peers,
+		})
+	}
+	return anomalies
+}
+
+// medianOf returns the median of values, leaving them in place.
+func medianOf(values []float64) float64 {
+	values = append([]float64(nil), values...)
+	sort.Float64s(values)
+	n := len(values)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+// AnomalyFindings turns anomalies into info findings whose detail holds
+// the comparison table they were judged on.
+func AnomalyFindings(anomalies []types.ResponseAnomaly) []types.VulnerabilityResult {
+	var vulns []types.VulnerabilityResult
+	for _, a := range anomalies {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s on %s: %s\nHosts serving %s:\n", a.Sample.Path, a.Sample.Host, a.Reason, a.Sample.Group)
+		for i, p := range a.Peers {
+			if i == maxAnomalyPeers {
+				fmt.Fprintf(&b, "  ... and %d more\n", len(a.Peers)-i)
+				break
+			}
+			marker := " "
+			if p.Host == a.Sample.Host {
+				marker = ">"
+			}
+			fmt.Fprintf(&b, "%s %-40s %3d %10d\n", marker, p.Host, p.Status, p.Size)
+		}
+		vulns = append(vulns, types.VulnerabilityResult{
+			URL:        a.Sample.URL,
+			Issue:      "Per-Host Response Anomaly",
+			Type:       "response-anomaly",
+			Detail:     strings.TrimRight(b.String(), "\n"),
+			Severity:   "info",
+			Confidence: ConfidenceTentative,
+		})
+	}
+	return vulns
+}
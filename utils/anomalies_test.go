@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// answers returns one sample per size for path on hosts h0, h1, ... of
+// group, all answering status.
+func answers(group, path string, status int, sizes ...int64) []types.ResponseSample {
+	var samples []types.ResponseSample
+	for i, size := range sizes {
+		host := fmt.Sprintf("h%d.example.com", i)
+		samples = append(samples, types.ResponseSample{Group: group, Path: path, Host: host, URL: "https://" + host + path, Status: status, Size: size})
+	}
+	return samples
+}
+
+// withStatus sets the status of the samples of the given hosts.
+func withStatus(samples []types.ResponseSample, status int, hosts ...int) []types.ResponseSample {
+	for _, h := range hosts {
+		samples[h].Status = status
+	}
+	return samples
+}
+
+// normalSizes returns n sizes drawn around mean with the given spread.
+func normalSizes(seed int64, n int, mean, spread float64) []int64 {
+	r := rand.New(rand.NewSource(seed))
+	sizes := make([]int64, n)
+	for i := range sizes {
+		sizes[i] = int64(mean + r.NormFloat64()*spread)
+	}
+	return sizes
+}
+
+// flagged returns the hosts of anomalies, in order.
+func flagged(anomalies []types.ResponseAnomaly) []string {
+	var hosts []string
+	for _, a := range anomalies {
+		hosts = append(hosts, a.Sample.Host)
+	}
+	return hosts
+}
+
+func TestFindResponseAnomalies(t *testing.T) {
+	th := AnomalyThresholds{MinGroup: 5, ZScore: 3.5, MinBytes: 1024}
+	spread := normalSizes(1, 50, 10000, 200)
+	tests := []struct {
+		name    string
+		samples []types.ResponseSample
+		th      AnomalyThresholds
+		want    []string
+		reason  string
+		skipped int
+	}{
+		{"identical answers", answers("nginx", "/", 200, 5120, 5120, 5120, 5120, 5120, 5120), th, nil, "", 0},
+		{"one size among identical ones", answers("nginx", "/", 200, 5120, 5120, 5120, 48000, 5120, 5120), th,
+			[]string{"h3.example.com"}, "48000 bytes where the median of 6 hosts answering 200 is 5120 (most hosts identical)", 0},
+		{"outlier in a spread", answers("nginx", "/", 200, append(spread, 20000)...), th,
+			[]string{"h50.example.com"}, "20000 bytes where the median of 51 hosts", 0},
+		{"spread alone", answers("nginx", "/", 200, spread...), th, nil, "", 0},
+		{"deviation under MinBytes", answers("nginx", "/", 200, 5120, 5120, 5120, 6000, 5120, 5120), th, nil, "", 0},
+		{"ZScore 0 leaves MinBytes", answers("nginx", "/", 200, 5000, 5100, 4900, 6200, 5050, 4950),
+			AnomalyThresholds{MinGroup: 5, MinBytes: 1024}, []string{"h3.example.com"}, "modified z-score", 0},
+		{"odd status", withStatus(answers("nginx", "/admin", 403, 120, 120, 120, 120, 120, 120, 120, 120, 120, 120), 200, 7), th,
+			[]string{"h7.example.com"}, "status 200 where 9 of 10 hosts answer 403", 0},
+		{"no status dominates", withStatus(answers("nginx", "/admin", 403, 120, 120, 120, 120, 120), 200, 1, 3), th, nil, "", 0},
+		{"unknown sizes are not compared", answers("nginx", "/", 200, -1, -1, -1, 90000, 5120, 5120), th, nil, "", 0},
+		{"group too small", answers("nginx", "/", 200, 5120, 5120, 5120, 48000), th, nil, "", 1},
+		{"hosts count once", append(answers("nginx", "/", 200, 5120, 5120, 5120, 48000), answers("nginx", "/", 200, 5120, 5120)...), th, nil, "", 1},
+		{"paths and groups judged apart", append(append(
+			answers("nginx", "/", 200, 5120, 5120, 5120, 48000, 5120),
+			answers("nginx", "/login", 200, 48000, 48000, 48000)...),
+			answers("IIS", "/", 200, 5120, 5120, 5120, 5120, 5120)...), th,
+			[]string{"h3.example.com"}, "", 1},
+	}
+	for _, tt := range tests {
+		anomalies, skipped := FindResponseAnomalies(tt.samples, tt.th)
+		if got := flagged(anomalies); !reflect.DeepEqual(got, tt.want) || skipped != tt.skipped {
+			t.Errorf("%s: flagged %q, skipped %d; want %q, %d", tt.name, got, skipped, tt.want, tt.skipped)
+			continue
+		}
+		if len(anomalies) > 0 && !strings.Contains(anomalies[0].Reason, tt.reason) {
+			t.Errorf("%s: reason %q, want %q", tt.name, anomalies[0].Reason, tt.reason)
+		}
+	}
+}
+
+func TestFindResponseAnomaliesOrder(t *testing.T) {
+	th := AnomalyThresholds{MinGroup: 5, ZScore: 3.5, MinBytes: 1024}
+	samples := append(answers("nginx", "/", 200, append(normalSizes(2, 30, 8000, 150), 30000, 500)...),
+		withStatus(answers("nginx", "/admin", 403, normalSizes(3, 20, 300, 5)...), 200, 4)...)
+	want, _ := FindResponseAnomalies(samples, th)
+	if len(want) != 3 {
+		t.Fatalf("flagged %q, want three hosts", flagged(want))
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10; i++ {
+		shuffled := append([]types.ResponseSample(nil), samples...)
+		r.Shuffle(len(shuffled), func(a, b int) { shuffled[a], shuffled[b] = shuffled[b], shuffled[a] })
+		if got, _ := FindResponseAnomalies(shuffled, th); !reflect.DeepEqual(got, want) {
+			t.Fatalf("shuffle %d: flagged %q, want %q", i, flagged(got), flagged(want))
+		}
+	}
+}
+
+func TestAnomalyThresholdsFromEnv(t *testing.T) {
+	tests := []struct {
+		group, z, bytes string
+		want            AnomalyThresholds
+	}{
+		{"", "", "", AnomalyThresholds{MinGroup: 5, ZScore: 3.5, MinBytes: 1024}},
+		{"8", "2.5", "0", AnomalyThresholds{MinGroup: 8, ZScore: 2.5, MinBytes: 0}},
+		{"2", "-1", "lots", AnomalyThresholds{MinGroup: 5, ZScore: 3.5, MinBytes: 1024}},
+	}
+	for _, tt := range tests {
+		t.Setenv("ANOMALY_MIN_GROUP", tt.group)
+		t.Setenv("ANOMALY_ZSCORE", tt.z)
+		t.Setenv("ANOMALY_MIN_BYTES", tt.bytes)
+		if got := AnomalyThresholdsFromEnv(); got != tt.want {
+			t.Errorf("%q %q %q: %+v, want %+v", tt.group, tt.z, tt.bytes, got, tt.want)
+		}
+	}
+}
+
+func TestResponseSamples(t *testing.T) {
+	techs := []types.Technology{{Name: "PHP", Version: "8.1"}, {Name: "WordPress", Version: "6.2"}, {Name: "PHP"}}
+	result := types.ScanResult{
+		LiveHosts: []types.LiveHost{
+			{URL: "https://a.example.com", StatusCode: 200, ContentLength: 900, Server: "nginx", Technologies: techs},
+			{URL: "https://b.example.com", StatusCode: 200, ContentLength: 950, Server: " nginx ", Technologies: techs[1:2]},
+			{URL: "https://c.example.com", Server: "Apache"},
+			{URL: "https://d.example.com", StatusCode: 200},
+		},
+		FfufEntries: []types.FfufResult{
+			{URL: "https://a.example.com//admin/?x=1", Status: 403, Size: 120},
+			{URL: "https://c.example.com/admin#top", Status: 200, Size: 4000},
+			{URL: "https://d.example.com/admin", Status: 200, Size: 4000},
+		},
+	}
+	want := []types.ResponseSample{
+		{Group: "nginx | PHP, WordPress", Path: "/", Host: "a.example.com", URL: "https://a.example.com/", Status: 200, Size: 900},
+		{Group: "nginx | WordPress", Path: "/", Host: "b.example.com", URL: "https://b.example.com/", Status: 200, Size: 950},
+		{Group: "nginx | PHP, WordPress", Path: "/admin", Host: "a.example.com", URL: "https://a.example.com//admin/?x=1", Status: 403, Size: 120},
+		{Group: "Apache", Path: "/admin", Host: "c.example.com", URL: "https://c.example.com/admin#top", Status: 200, Size: 4000},
+	}
+	if got := ResponseSamples(result); !reflect.DeepEqual(got, want) {
+		t.Errorf("ResponseSamples:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestAnomalyFindings(t *testing.T) {
+	peers := answers("nginx", "/", 200, make([]int64, maxAnomalyPeers+5)...)
+	vulns := AnomalyFindings([]types.ResponseAnomaly{{Sample: peers[2], Reason: "odd", Peers: peers}})
+	if len(vulns) != 1 {
+		t.Fatalf("%d findings", len(vulns))
+	}
+	v := vulns[0]
+	if v.Type != "response-anomaly" || v.Severity != "info" || v.URL != peers[2].URL {
+		t.Errorf("finding %+v", v)
+	}
+	lines := strings.Split(v.Detail, "\n")
+	if lines[0] != "/ on h2.example.com: odd" || !strings.HasPrefix(lines[4], "> h2.example.com") {
+		t.Errorf("detail starts %q", lines[:5])
+	}
+	if len(lines) != 2+maxAnomalyPeers+1 || lines[len(lines)-1] != "  ... and 5 more" {
+		t.Errorf("detail has %d lines, ending %q", len(lines), lines[len(lines)-1])
+	}
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// authDateLayout is the format of the authorization date range.
+const authDateLayout = "2006-01-02"
+
+// AuthorizationFromEnv reads the engagement authorization from
+// AUTH_ENGAGEMENT_REF, AUTH_AUTHORIZED_BY, AUTH_VALID_FROM and
+// AUTH_VALID_UNTIL (YYYY-MM-DD, inclusive). ok is false when none of them
+// is set; setting some without both the engagement reference and the
+// authorizing party, a malformed date or a range that ends before it
+// starts is an error.
+func AuthorizationFromEnv() (auth types.Authorization, ok bool, err error) {
+	auth = types.Authorization{
+		EngagementRef: strings.TrimSpace(os.Getenv("AUTH_ENGAGEMENT_REF")),
+		AuthorizedBy:  strings.TrimSpace(os.Getenv("AUTH_AUTHORIZED_BY")),
+		ValidFrom:     strings.TrimSpace(os.Getenv("AUTH_VALID_FROM")),
+		ValidUntil:    strings.TrimSpace(os.Getenv("AUTH_VALID_UNTIL")),
+		Source:        "config",
+	}
+	if auth.EngagementRef == "" && auth.AuthorizedBy == "" && auth.ValidFrom == "" && auth.ValidUntil == "" {
+		return auth, false, nil
+	}
+	if auth.EngagementRef == "" || auth.AuthorizedBy == "" {
+		return auth, false, fmt.Errorf("authorization incomplete: AUTH_ENGAGEMENT_REF and AUTH_AUTHORIZED_BY are both required")
+	}
+	for _, d := range []string{auth.ValidFrom, auth.ValidUntil} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse(authDateLayout, d); err != nil {
+			return auth, false, fmt.Errorf("authorization date %q: want YYYY-MM-DD", d)
+		}
+	}
+	// The layout sorts as a string, as AuthorizationCurrent relies on.
+	if auth.ValidFrom != "" && auth.ValidUntil != "" && auth.ValidFrom > auth.ValidUntil {
+		return auth, false, fmt.Errorf("authorization valid from %s until %s: the range ends before it starts", auth.ValidFrom, auth.ValidUntil)
+	}
+	return auth, true, nil
+}
+
+// AuthorizationCurrent reports whether now falls within the authorized date
+// range and, if not, why. Either bound may be empty.
+func AuthorizationCurrent(auth types.Authorization, now time.Time) (bool, string) {
+	day := now.Format(authDateLayout)
+	if auth.ValidFrom != "" && day < auth.ValidFrom {
+		return false, "authorization starts " + auth.ValidFrom
+	}
+	if auth.ValidUntil != "" && day > auth.ValidUntil {
+		return false, "authorization expired " + auth.ValidUntil
+	}
+	return true, ""
+}
+
+// ConfirmTarget reads one line from r and reports whether it names target.
+func ConfirmTarget(r io.Reader, target string) bool {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false
+	}
+	return NormalizeHostname(line) == NormalizeHostname(target)
+}
+
+// FormatAuthorization renders the authorization statement for reports.
+func FormatAuthorization(auth *types.Authorization) string {
+	if auth == nil {
+		return "NOT AUTHORIZED: passive stages only"
+	}
+	var b strings.Builder
+	if auth.Source == "interactive" {
+		b.WriteString(fmt.Sprintf("confirmed interactively by the operator at %s", auth.ConfirmedAt.Format(time.RFC3339)))
+	} else {
+		b.WriteString(fmt.Sprintf("engagement %s, authorized by %s", auth.EngagementRef, auth.AuthorizedBy))
+		if auth.ValidFrom != "" || auth.ValidUntil != "" {
+			b.WriteString(fmt.Sprintf(", valid %s to %s", orOpen(auth.ValidFrom), orOpen(auth.ValidUntil)))
+		}
+	}
+	if auth.Downgraded != "" {
+		b.WriteString("; DOWNGRADED TO PASSIVE: " + auth.Downgraded)
+	}
+	return b.String()
+}
+
+// orOpen renders an empty date bound as open-ended.
+func orOpen(d string) string {
+	if d == "" {
+		return "(open)"
+	}
+	return d
+}
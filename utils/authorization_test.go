@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestAuthorizationFromEnv(t *testing.T) {
+	tests := []struct {
+		name                 string
+		ref, by, from, until string
+		ok, wantErr          bool
+	}{
+		{"no block", "", "", "", "", false, false},
+		{"whitespace only", " ", "\t", "", "", false, false},
+		{"open range", "ENG-42", "J. Doe, CISO", "", "", true, false},
+		{"closed range", "ENG-42", "J. Doe, CISO", "2026-10-01", "2026-10-31", true, false},
+		{"single day", "ENG-42", "J. Doe, CISO", "2026-10-15", "2026-10-15", true, false},
+		{"no authorizing party", "ENG-42", "", "", "", false, true},
+		{"no engagement reference", "", "J. Doe, CISO", "", "", false, true},
+		{"dates only", "", "", "2026-10-01", "2026-10-31", false, true},
+		{"bad start date", "ENG-42", "J. Doe, CISO", "01/10/2026", "", false, true},
+		{"bad end date", "ENG-42", "J. Doe, CISO", "", "2026-10-32", false, true},
+		{"inverted range", "ENG-42", "J. Doe, CISO", "2026-10-31", "2026-10-01", false, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("AUTH_ENGAGEMENT_REF", tt.ref)
+		t.Setenv("AUTH_AUTHORIZED_BY", tt.by)
+		t.Setenv("AUTH_VALID_FROM", tt.from)
+		t.Setenv("AUTH_VALID_UNTIL", tt.until)
+		auth, ok, err := AuthorizationFromEnv()
+		if ok != tt.ok || (err != nil) != tt.wantErr {
+			t.Errorf("%s: ok %v, err %v", tt.name, ok, err)
+		}
+		if ok && (auth.EngagementRef != tt.ref || auth.AuthorizedBy != tt.by || auth.ValidFrom != tt.from || auth.ValidUntil != tt.until || auth.Source != "config") {
+			t.Errorf("%s: %+v", tt.name, auth)
+		}
+	}
+}
+
+func TestAuthorizationCurrent(t *testing.T) {
+	at := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	october := types.Authorization{ValidFrom: "2026-10-01", ValidUntil: "2026-10-31"}
+	tests := []struct {
+		name string
+		auth types.Authorization
+		now  string
+		ok   bool
+		why  string
+	}{
+		{"before", october, "2026-09-30 23:59", false, "authorization starts 2026-10-01"},
+		{"first day", october, "2026-10-01 00:00", true, ""},
+		{"inside", october, "2026-10-15 12:00", true, ""},
+		{"last day", october, "2026-10-31 23:59", true, ""},
+		{"after", october, "2026-11-01 00:00", false, "authorization expired 2026-10-31"},
+		{"open start", types.Authorization{ValidUntil: "2026-10-31"}, "2020-01-01 00:00", true, ""},
+		{"open end", types.Authorization{ValidFrom: "2026-10-01"}, "2030-01-01 00:00", true, ""},
+		{"open end, before", types.Authorization{ValidFrom: "2026-10-01"}, "2026-09-01 00:00", false, "authorization starts 2026-10-01"},
+		{"no range", types.Authorization{}, "2026-10-15 12:00", true, ""},
+	}
+	for _, tt := range tests {
+		ok, why := AuthorizationCurrent(tt.auth, at(tt.now))
+		if ok != tt.ok || why != tt.why {
+			t.Errorf("%s: %v, %q; want %v, %q", tt.name, ok, why, tt.ok, tt.why)
+		}
+	}
+}
+
+func TestFormatAuthorization(t *testing.T) {
+	confirmed := time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		auth *types.Authorization
+		want string
+	}{
+		{"none", nil, "NOT AUTHORIZED: passive stages only"},
+		{"config, open range", &types.Authorization{EngagementRef: "ENG-42", AuthorizedBy: "J. Doe", Source: "config"},
+			"engagement ENG-42, authorized by J. Doe"},
+		{"config, closed range", &types.Authorization{EngagementRef: "ENG-42", AuthorizedBy: "J. Doe", ValidFrom: "2026-10-01", ValidUntil: "2026-10-31", Source: "config"},
+			"engagement ENG-42, authorized by J. Doe, valid 2026-10-01 to 2026-10-31"},
+		{"config, open end", &types.Authorization{EngagementRef: "ENG-42", AuthorizedBy: "J. Doe", ValidFrom: "2026-10-01", Source: "config"},
+			"engagement ENG-42, authorized by J. Doe, valid 2026-10-01 to (open)"},
+		{"interactive", &types.Authorization{Source: "interactive", ConfirmedAt: confirmed},
+			"confirmed interactively by the operator at 2026-10-15T09:30:00Z"},
+		{"downgraded", &types.Authorization{EngagementRef: "ENG-42", AuthorizedBy: "J. Doe", ValidUntil: "2026-09-30", Source: "config", Downgraded: "authorization expired 2026-09-30"},
+			"engagement ENG-42, authorized by J. Doe, valid (open) to 2026-09-30; DOWNGRADED TO PASSIVE: authorization expired 2026-09-30"},
+	}
+	for _, tt := range tests {
+		if got := FormatAuthorization(tt.auth); got != tt.want {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// The statement heads the pre-flight summary of the run.
+	p := Preflight{Target: "example.com", Authorization: FormatAuthorization(tests[2].auth)}
+	if out := p.Render(); !strings.Contains(out, "Authorization: engagement ENG-42, authorized by J. Doe, valid 2026-10-01 to 2026-10-31\n") {
+		t.Errorf("pre-flight summary lacks the authorization:\n%s", out)
+	}
+}
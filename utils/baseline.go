@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// Baseline is what an earlier run of the same target found, so the current
+// run can mark what is new as it comes in. Items are kept as 64-bit hashes:
+// a baseline with hundreds of thousands of URLs takes a few megabytes, and
+// a collision that hides a new item is vanishingly unlikely at that size.
+// A nil Baseline treats nothing as new.
+type Baseline struct {
+	Dir string
+	// Recovery is set when the baseline's summary.json was damaged and
+	// only what LoadSummary salvaged is compared against.
+	Recovery *SummaryRecovery
+	hosts    map[uint64]struct{}
+	urls     map[uint64]struct{}
+	findings map[uint64]struct{}
+}
+
+// baselineKey hashes one item.
+func baselineKey(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// LoadBaseline reads the summary.json of the run directory dir, salvaging
+// what it can from a damaged one.
+func LoadBaseline(dir string) (*Baseline, error) {
+	sum, rec, err := LoadSummary(dir)
+	if err != nil {
+		return nil, fmt.Errorf("baseline: %v", err)
+	}
+	b := &Baseline{
+		Dir:      dir,
+		Recovery: rec,
+		hosts:    make(map[uint64]struct{}, len(sum.Subdomains)),
+		urls:     make(map[uint64]struct{}, len(sum.AllURLs)),
+		findings: make(map[uint64]struct{}, len(sum.VulnURLs)),
+	}
+	for _, s := range sum.Subdomains {
+		b.hosts[baselineKey(NormalizeHostname(s.Hostname))] = struct{}{}
+	}
+	for _, u := range sum.AllURLs {
+		b.urls[baselineKey(u)] = struct{}{}
+	}
+	for _, v := range sum.VulnURLs {
+		b.findings[baselineKey(FindingFingerprint(v))] = struct{}{}
+	}
+	return b, nil
+}
+
+// Size returns how many hosts, URLs and findings the baseline holds.
+func (b *Baseline) Size() (hosts, urls, findings int) {
+	if b == nil {
+		return 0, 0, 0
+	}
+	return len(b.hosts), len(b.urls), len(b.findings)
+}
+
+// NewHost reports whether the baseline run did not find host.
+func (b *Baseline) NewHost(host string) bool {
+	if b == nil {
+		return false
+	}
+	_, seen := b.hosts[baselineKey(NormalizeHostname(host))]
+	return !seen
+}
+
+// NewURL reports whether the baseline run did not find u.
+func (b *Baseline) NewURL(u string) bool {
+	if b == nil {
+		return false
+	}
+	_, seen := b.urls[baselineKey(u)]
+	return !seen
+}
+
+// NewFinding reports whether the baseline run did not report v.
+func (b *Baseline) NewFinding(v types.VulnerabilityResult) bool {
+	if b == nil {
+		return false
+	}
+	_, seen := b.findings[baselineKey(FindingFingerprint(v))]
+	return !seen
+}
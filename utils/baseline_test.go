@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestBaseline(t *testing.T) {
+	result, _ := summaryFixture(t)
+	result.Subdomains = append(result.Subdomains, types.SubdomainResult{Hostname: "API.Example.com."})
+	result.VulnURLs = append(result.VulnURLs, types.VulnerabilityResult{URL: "https://app.example.com/item?id=1&cat=2", Issue: "SQL Injection", Type: "sqli", Parameter: "id (GET)"})
+	dir := t.TempDir()
+	if err := PersistResults(result, dir); err != nil {
+		t.Fatal(err)
+	}
+	b, err := LoadBaseline(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hosts, urls, findings := b.Size(); hosts != 3 || urls != 3 || findings != 3 {
+		t.Errorf("Size() = %d, %d, %d", hosts, urls, findings)
+	}
+
+	hosts := []struct {
+		host  string
+		isNew bool
+	}{
+		{"www.example.com", false},
+		{"WWW.example.com.", false},
+		{"api.example.com", false},
+		{"dev.example.com", true},
+		{"example.com", true},
+	}
+	for _, tt := range hosts {
+		if got := b.NewHost(tt.host); got != tt.isNew {
+			t.Errorf("NewHost(%q) = %v", tt.host, got)
+		}
+	}
+	urls := []struct {
+		url   string
+		isNew bool
+	}{
+		{"https://app.example.com/item?id=1", false},
+		{"https://app.example.com/item?id=2", true},
+		{"https://www.example.com", true},
+	}
+	for _, tt := range urls {
+		if got := b.NewURL(tt.url); got != tt.isNew {
+			t.Errorf("NewURL(%q) = %v", tt.url, got)
+		}
+	}
+	findings := []struct {
+		name  string
+		v     types.VulnerabilityResult
+		isNew bool
+	}{
+		{"same finding", types.VulnerabilityResult{URL: "https://app.example.com/search?q=x", Issue: "Reflected XSS", Detail: "new payload"}, false},
+		{"other URL", types.VulnerabilityResult{URL: "https://app.example.com/search?q=y", Issue: "Reflected XSS"}, true},
+		{"same parameter", types.VulnerabilityResult{URL: "https://app.example.com/item?id=1&cat=2", Issue: "SQL Injection", Parameter: "id (GET)"}, false},
+		{"other parameter on the URL", types.VulnerabilityResult{URL: "https://app.example.com/item?id=1&cat=2", Issue: "SQL Injection", Parameter: "cat (GET)"}, true},
+	}
+	for _, tt := range findings {
+		if got := b.NewFinding(tt.v); got != tt.isNew {
+			t.Errorf("NewFinding, %s: %v", tt.name, got)
+		}
+	}
+
+	// Without a baseline nothing is new.
+	var none *Baseline
+	if none.NewHost("dev.example.com") || none.NewURL("https://dev.example.com/") || none.NewFinding(findings[1].v) {
+		t.Error("a nil baseline marked something new")
+	}
+	if _, err := LoadBaseline(t.TempDir()); err == nil {
+		t.Error("LoadBaseline of a directory without summary.json: no error")
+	}
+}
+
+// baselineURL is the i-th URL of largeBaseline.
+func baselineURL(i int) string {
+	return fmt.Sprintf("https://host%d.example.com/path/to/some/resource?id=%d&session=%08x", i%1000, i, i*2654435761)
+}
+
+// largeBaseline writes a run with n subdomains, n URLs and n/10 findings.
+func largeBaseline(dir string, n int) error {
+	result := types.ScanResult{
+		Subdomains: make([]types.SubdomainResult, n),
+		AllURLs:    make([]string, n),
+		VulnURLs:   make([]types.VulnerabilityResult, n/10),
+	}
+	for i := 0; i < n; i++ {
+		result.Subdomains[i].Hostname = fmt.Sprintf("host%d.example.com", i)
+		result.AllURLs[i] = baselineURL(i)
+	}
+	for i := range result.VulnURLs {
+		result.VulnURLs[i] = types.VulnerabilityResult{URL: result.AllURLs[i*10], Issue: "Reflected Parameter", Parameter: "id"}
+	}
+	return PersistResults(result, dir)
+}
+
+// TestBaselineMemory checks that a large baseline keeps hashes only, not
+// the hosts, URLs and findings it was loaded from.
+func TestBaselineMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("writes a large baseline")
+	}
+	const n = 200000
+	dir := t.TempDir()
+	if err := largeBaseline(dir, n); err != nil {
+		t.Fatal(err)
+	}
+	b, err := LoadBaseline(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts, urls, findings := b.Size()
+	if hosts != n || urls != n || findings != n/10 {
+		t.Fatalf("Size() = %d, %d, %d", hosts, urls, findings)
+	}
+	var held, released runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&held)
+	runtime.KeepAlive(b)
+	b = nil
+	runtime.GC()
+	runtime.ReadMemStats(&released)
+	// A hash set entry takes a few tens of bytes; the URLs alone average
+	// over 80.
+	perItem := (float64(held.HeapAlloc) - float64(released.HeapAlloc)) / float64(hosts+urls+findings)
+	if perItem > 48 {
+		t.Errorf("%.0f bytes retained per baseline item", perItem)
+	}
+}
+
+// BenchmarkBaselineIngest measures the membership checks made as a run's
+// URLs come in, against a baseline of 500k URLs.
+func BenchmarkBaselineIngest(b *testing.B) {
+	const n = 500000
+	dir := b.TempDir()
+	if err := largeBaseline(dir, n); err != nil {
+		b.Fatal(err)
+	}
+	base, err := LoadBaseline(dir)
+	if err != nil {
+		b.Fatal(err)
+	}
+	incoming := make([]string, 1000)
+	for i := range incoming {
+		// Half of them were in the baseline.
+		incoming[i] = baselineURL(i*n/1000 + i%2*n)
+	}
+	b.ResetTimer()
+	seen := 0
+	for i := 0; i < b.N; i++ {
+		if !base.NewURL(incoming[i%len(incoming)]) {
+			seen++
+		}
+	}
+	if seen != (b.N+1)/2 {
+		b.Fatalf("%d of %d URLs in the baseline, want half", seen, b.N)
+	}
+}
+
+// BenchmarkLoadBaseline measures loading a baseline of 500k hosts and URLs.
+func BenchmarkLoadBaseline(b *testing.B) {
+	dir := b.TempDir()
+	if err := largeBaseline(dir, 500000); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := LoadBaseline(dir); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
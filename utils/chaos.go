@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrChaos is returned by every failure chaos mode injects.
+var ErrChaos = errors.New("chaos: injected failure")
+
+// Operations chaos mode can delay and fail.
+const (
+	ChaosCommand = "cmd"
+	ChaosHTTP    = "http"
+	ChaosDNS     = "dns"
+	ChaosWrite   = "write"
+)
+
+// ChaosConfig sets how often each operation fails and how long it may be
+// held up first. Rates are probabilities between 0 and 1; a run is
+// reproducible for a given Seed as far as goroutine scheduling allows.
+type ChaosConfig struct {
+	Seed  int64
+	Rates map[string]float64
+	// Delay is the upper bound of the random pause before each operation.
+	Delay time.Duration
+}
+
+// ParseChaosSpec parses a spec such as
+// "seed=42,cmd=0.1,http=0.05,dns=0.05,write=0.02,delay=500ms". Without a
+// seed the current time is used.
+func ParseChaosSpec(spec string) (ChaosConfig, error) {
+	cfg := ChaosConfig{Seed: time.Now().UnixNano(), Rates: make(map[string]float64)}
+	for _, raw := range strings.Split(spec, ",") {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		parts := strings.SplitN(raw, "=", 2)
+		if len(parts) != 2 {
+			return cfg, fmt.Errorf("chaos spec %q: want key=value", raw)
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		switch key {
+		case "seed":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return cfg, fmt.Errorf("chaos seed %q: %v", value, err)
+			}
+			cfg.Seed = n
+		case "delay":
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return cfg, fmt.Errorf("chaos delay %q: want a duration such as 500ms", value)
+			}
+			cfg.Delay = d
+		case ChaosCommand, ChaosHTTP, ChaosDNS, ChaosWrite:
+			p, err := strconv.ParseFloat(value, 64)
+			if err != nil || p < 0 || p > 1 {
+				return cfg, fmt.Errorf("chaos rate %s=%q: want a number between 0 and 1", key, value)
+			}
+			cfg.Rates[key] = p
+		default:
+			return cfg, fmt.Errorf("chaos spec: unknown key %q", key)
+		}
+	}
+	return cfg, nil
+}
+
+// String renders cfg in the form ParseChaosSpec accepts.
+func (c ChaosConfig) String() string {
+	parts := []string{"seed=" + strconv.FormatInt(c.Seed, 10)}
+	for _, op := range []string{ChaosCommand, ChaosHTTP, ChaosDNS, ChaosWrite} {
+		if p, ok := c.Rates[op]; ok {
+			parts = append(parts, op+"="+strconv.FormatFloat(p, 'g', -1, 64))
+		}
+	}
+	if c.Delay > 0 {
+		parts = append(parts, "delay="+c.Delay.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+var (
+	chaosMu  sync.Mutex
+	chaosCfg *ChaosConfig
+	chaosRng *rand.Rand
+)
+
+// EnableChaos turns on failure injection for the rest of the process. It is
+// only reachable through the --chaos flag of builds tagged chaos.
+func EnableChaos(cfg ChaosConfig) {
+	chaosMu.Lock()
+	defer chaosMu.Unlock()
+	chaosCfg = &cfg
+	chaosRng = rand.New(rand.NewSource(cfg.Seed))
+}
+
+// DisableChaos turns failure injection off again, so runs under several
+// seeds can follow one another in one process.
+func DisableChaos() {
+	chaosMu.Lock()
+	defer chaosMu.Unlock()
+	chaosCfg, chaosRng = nil, nil
+}
+
+// chaosEnabled reports whether EnableChaos was called.
+func chaosEnabled() bool {
+	chaosMu.Lock()
+	defer chaosMu.Unlock()
+	return chaosCfg != nil
+}
+
+// chaosInject pauses op for a random part of the configured delay and then
+// decides whether it fails. It returns nil outside chaos mode.
+func chaosInject(op string) error {
+	chaosMu.Lock()
+	if chaosCfg == nil {
+		chaosMu.Unlock()
+		return nil
+	}
+	var pause time.Duration
+	if chaosCfg.Delay > 0 {
+		pause = time.Duration(chaosRng.Int63n(int64(chaosCfg.Delay)))
+	}
+	fail := chaosRng.Float64() < chaosCfg.Rates[op]
+	chaosMu.Unlock()
+	time.Sleep(pause)
+	if fail {
+		return fmt.Errorf("%s: %w", op, ErrChaos)
+	}
+	return nil
+}
+
+// chaosTransport injects failures into the requests of an HTTP client.
+type chaosTransport struct {
+	base http.RoundTripper
+}
+
+func (t *chaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := chaosInject(ChaosHTTP); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// ChaosClient returns client with failure injection on its requests; outside
+// chaos mode client is returned unchanged.
+func ChaosClient(client *http.Client) *http.Client {
+	if !chaosEnabled() {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c := *client
+	c.Transport = &chaosTransport{base: base}
+	return &c
+}
+
+// ChaosResolver returns r with failure injection on its lookups; outside
+// chaos mode r is returned unchanged.
+func ChaosResolver(r *net.Resolver) *net.Resolver {
+	if !chaosEnabled() {
+		return r
+	}
+	return &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if err := chaosInject(ChaosDNS); err != nil {
+			return nil, err
+		}
+		if r.Dial != nil {
+			return r.Dial(ctx, network, addr)
+		}
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	}}
+}
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseChaosSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    ChaosConfig
+		wantErr bool
+	}{
+		{"seed=42,cmd=0.1,http=0.05,dns=0.05,write=0.02,delay=500ms", ChaosConfig{Seed: 42,
+			Rates: map[string]float64{ChaosCommand: 0.1, ChaosHTTP: 0.05, ChaosDNS: 0.05, ChaosWrite: 0.02}, Delay: 500 * time.Millisecond}, false},
+		{" seed = 7 , http = 1 ,", ChaosConfig{Seed: 7, Rates: map[string]float64{ChaosHTTP: 1}}, false},
+		{"seed=1,cmd=0", ChaosConfig{Seed: 1, Rates: map[string]float64{ChaosCommand: 0}}, false},
+		{"seed=x", ChaosConfig{}, true},
+		{"cmd=1.5", ChaosConfig{}, true},
+		{"cmd=-0.1", ChaosConfig{}, true},
+		{"delay=soon", ChaosConfig{}, true},
+		{"delay=-1s", ChaosConfig{}, true},
+		{"disk=0.1", ChaosConfig{}, true},
+		{"cmd", ChaosConfig{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseChaosSpec(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseChaosSpec(%q): err = %v", tt.spec, err)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseChaosSpec(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+
+	// String gives back a spec that parses to the same configuration.
+	cfg, _ := ParseChaosSpec("write=0.02,seed=42,delay=500ms,cmd=0.1")
+	if s := cfg.String(); s != "seed=42,cmd=0.1,write=0.02,delay=500ms" {
+		t.Errorf("String() = %q", s)
+	}
+	if again, err := ParseChaosSpec(cfg.String()); err != nil || !reflect.DeepEqual(again, cfg) {
+		t.Errorf("round trip: %+v, %v; want %+v", again, err, cfg)
+	}
+	if cfg, _ := ParseChaosSpec(""); cfg.Seed == 0 {
+		t.Error("no seed chosen for a spec without one")
+	}
+}
+
+// chaosOutcomes returns which of n injections of op fail under cfg.
+func chaosOutcomes(cfg ChaosConfig, op string, n int) []bool {
+	EnableChaos(cfg)
+	defer DisableChaos()
+	out := make([]bool, n)
+	for i := range out {
+		out[i] = chaosInject(op) != nil
+	}
+	return out
+}
+
+func TestChaosInjectSeeded(t *testing.T) {
+	if err := chaosInject(ChaosCommand); err != nil {
+		t.Fatalf("injected outside chaos mode: %v", err)
+	}
+	cfg := ChaosConfig{Seed: 42, Rates: map[string]float64{ChaosCommand: 0.3}}
+	first, second := chaosOutcomes(cfg, ChaosCommand, 200), chaosOutcomes(cfg, ChaosCommand, 200)
+	if !reflect.DeepEqual(first, second) {
+		t.Error("the same seed injected different failures")
+	}
+	failed := 0
+	for _, f := range first {
+		if f {
+			failed++
+		}
+	}
+	if failed < 30 || failed > 90 {
+		t.Errorf("%d of 200 failed at a rate of 0.3", failed)
+	}
+	cfg.Seed = 43
+	if reflect.DeepEqual(first, chaosOutcomes(cfg, ChaosCommand, 200)) {
+		t.Error("different seeds injected the same failures")
+	}
+	for _, tt := range []struct {
+		op   string
+		rate float64
+		want bool
+	}{
+		{ChaosCommand, 1, true},
+		{ChaosCommand, 0, false},
+		// Operations without a rate never fail.
+		{ChaosWrite, -1, false},
+	} {
+		cfg := ChaosConfig{Seed: 1, Rates: map[string]float64{}}
+		if tt.rate >= 0 {
+			cfg.Rates[tt.op] = tt.rate
+		}
+		for _, failed := range chaosOutcomes(cfg, tt.op, 20) {
+			if failed != tt.want {
+				t.Errorf("%s at rate %v: failed %v", tt.op, tt.rate, failed)
+				break
+			}
+		}
+	}
+}
+
+func TestChaosDelay(t *testing.T) {
+	EnableChaos(ChaosConfig{Seed: 1, Rates: map[string]float64{}, Delay: 20 * time.Millisecond})
+	defer DisableChaos()
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if err := chaosInject(ChaosHTTP); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d > 10*20*time.Millisecond+100*time.Millisecond {
+		t.Errorf("10 injections with a 20ms delay took %v", d)
+	}
+}
+
+func TestChaosHooks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	client := srv.Client()
+	resolver := &net.Resolver{}
+	if ChaosClient(client) != client || ChaosResolver(resolver) != resolver {
+		t.Error("client or resolver wrapped outside chaos mode")
+	}
+
+	EnableChaos(ChaosConfig{Seed: 1, Rates: map[string]float64{ChaosHTTP: 1, ChaosDNS: 1, ChaosWrite: 1, ChaosCommand: 1}})
+	defer DisableChaos()
+	if _, err := ChaosClient(client).Get(srv.URL); !errors.Is(err, ErrChaos) {
+		t.Errorf("HTTP request: err = %v, want ErrChaos", err)
+	}
+	if _, err := ChaosResolver(resolver).LookupHost(context.Background(), "example.com"); err == nil {
+		t.Error("DNS lookup succeeded at a failure rate of 1")
+	}
+	dir := t.TempDir()
+	if err := WriteLines([]string{"a"}, filepath.Join(dir, "out.txt")); !errors.Is(err, ErrChaos) {
+		t.Errorf("WriteLines: err = %v, want ErrChaos", err)
+	}
+	if _, err := RunCommand("true"); !errors.Is(err, ErrChaos) {
+		t.Errorf("RunCommand: err = %v, want ErrChaos", err)
+	}
+
+	// The original client still works: chaos wraps, it does not modify.
+	if resp, err := client.Get(srv.URL); err != nil {
+		t.Errorf("unwrapped client: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+}
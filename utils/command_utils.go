@@ -0,0 +1,298 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultEnvAllow lists the variables every tool inherits besides PATH and HOME.
+var defaultEnvAllow = []string{
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "ALL_PROXY",
+	"http_proxy", "https_proxy", "no_proxy", "all_proxy",
+}
+
+// ToolSandbox isolates external tools: each one runs in RawDir/<tool> with
+// HOME pointed at a per-run temporary directory and a whitelisted environment.
+// Every invocation is recorded in Manifest (commands.jsonl).
+type ToolSandbox struct {
+	RawDir   string
+	HomeDir  string
+	EnvAllow []string
+	Manifest string
+
+	manifestMu sync.Mutex
+	binHashes  map[string]string
+}
+
+// CommandRecord is one line of commands.jsonl: enough to re-run a tool
+// invocation and tell whether the tool, its inputs or its output changed.
+type CommandRecord struct {
+	Time       time.Time         `json:"time"`
+	Tool       string            `json:"tool"`
+	Path       string            `json:"path"`
+	BinarySHA  string            `json:"binary_sha256,omitempty"`
+	Args       []string          `json:"args"`
+	Dir        string            `json:"dir"`
+	Env        []string          `json:"env"`
+	Inputs     map[string]string `json:"inputs,omitempty"`
+	StdinSHA   string            `json:"stdin_sha256,omitempty"`
+	ExitCode   int               `json:"exit_code"`
+	DurationMs int64             `json:"duration_ms"`
+	OutputSHA  string            `json:"output_sha256"`
+}
+
+var (
+	sandbox   *ToolSandbox
+	sandboxMu sync.Mutex
+)
+
+// SetupToolSandbox creates outDir/raw and a temporary HOME and makes
+// RunCommand use them for every subsequent tool invocation.
+// extraEnv names additional variables to pass through to the tools.
+func SetupToolSandbox(outDir string, extraEnv []string) (*ToolSandbox, error) {
+	rawDir := filepath.Join(outDir, "raw")
+	if err := os.MkdirAll(rawDir, 0755); err != nil {
+		return nil, err
+	}
+	home, err := ioutil.TempDir("", "recon-home-")
+	if err != nil {
+		return nil, err
+	}
+	s := &ToolSandbox{
+		RawDir:    rawDir,
+		HomeDir:   home,
+		EnvAllow:  append([]string{}, defaultEnvAllow...),
+		Manifest:  filepath.Join(outDir, "commands.jsonl"),
+		binHashes: make(map[string]string),
+	}
+	for _, name := range extraEnv {
+		if name = strings.TrimSpace(name); name != "" {
+			s.EnvAllow = append(s.EnvAllow, name)
+		}
+	}
+	sandboxMu.Lock()
+	sandbox = s
+	sandboxMu.Unlock()
+	return s, nil
+}
+
+// Cleanup removes the temporary HOME and stops isolating further commands.
+// Tool working directories under RawDir are part of the run and are kept.
+func (s *ToolSandbox) Cleanup() error {
+	sandboxMu.Lock()
+	if sandbox == s {
+		sandbox = nil
+	}
+	sandboxMu.Unlock()
+	return os.RemoveAll(s.HomeDir)
+}
+
+// toolDir returns (creating it if needed) the working directory for a tool.
+func (s *ToolSandbox) toolDir(name string) (string, error) {
+	dir := filepath.Join(s.RawDir, filepath.Base(name))
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+// ToolWorkDir returns the directory the tool name runs in, where it leaves
+// any files it writes to relative paths: its directory under RawDir when a
+// ToolSandbox is active, the current directory otherwise.
+func ToolWorkDir(name string) string {
+	sandboxMu.Lock()
+	s := sandbox
+	sandboxMu.Unlock()
+	if s == nil {
+		return "."
+	}
+	return filepath.Join(s.RawDir, filepath.Base(name))
+}
+
+// envNames lists the variables actually passed to tools, without values.
+func (s *ToolSandbox) envNames() []string {
+	names := []string{"PATH", "HOME"}
+	for _, name := range s.EnvAllow {
+		if _, ok := os.LookupEnv(name); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// binaryHash returns the SHA-256 of the tool binary, cached per path.
+func (s *ToolSandbox) binaryHash(path string) string {
+	s.manifestMu.Lock()
+	defer s.manifestMu.Unlock()
+	if h, ok := s.binHashes[path]; ok {
+		return h
+	}
+	h, _ := HashFile(path)
+	s.binHashes[path] = h
+	return h
+}
+
+// record appends an invocation to commands.jsonl.
+func (s *ToolSandbox) record(rec CommandRecord) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	s.manifestMu.Lock()
+	defer s.manifestMu.Unlock()
+	f, err := os.OpenFile(s.Manifest, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(append(data, '\n'))
+}
+
+// HashFile returns the hex SHA-256 of a file's contents.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashInputs hashes every argument that names an existing regular file.
+func hashInputs(args []string) map[string]string {
+	inputs := make(map[string]string)
+	for _, a := range args {
+		// Tools take "path:KEYWORD" forms too (ffuf -w list.txt:FUZZ).
+		path := strings.SplitN(a, ":", 2)[0]
+		if fi, err := os.Stat(path); err == nil && fi.Mode().IsRegular() {
+			if h, err := HashFile(path); err == nil {
+				inputs[path] = h
+			}
+		}
+	}
+	if len(inputs) == 0 {
+		return nil
+	}
+	return inputs
+}
+
+// redactArgs masks secrets, such as API tokens from the environment or
+// the SOCKS5 proxy credentials passed on the command line, before an
+// invocation is recorded.
+func redactArgs(args []string) []string {
+	secrets := SecretValues()
+	redacted := make([]string, len(args))
+	for i, a := range args {
+		redacted[i] = string(Redact([]byte(a), secrets))
+	}
+	return redacted
+}
+
+// env builds the sanitized environment handed to tools.
+func (s *ToolSandbox) env() []string {
+	env := []string{"PATH=" + os.Getenv("PATH"), "HOME=" + s.HomeDir}
+	for _, name := range s.EnvAllow {
+		if v, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+v)
+		}
+	}
+	return env
+}
+
+// RunCommand executes an external command and returns its combined output.
+// When a ToolSandbox is active the command runs isolated inside it and the
+// invocation is recorded in commands.jsonl. In SOCKS5 mode tools are routed
+// through the bastion or refused. Every tool runs under its ToolLimits, and
+// waits for the scan window during aggressive stages.
+func RunCommand(name string, args ...string) (string, error) {
+	return runCommand(context.Background(), name, nil, args)
+}
+
+// RunCommandInput is RunCommand for tools that read their targets from stdin.
+func RunCommandInput(name, stdin string, args ...string) (string, error) {
+	return runCommand(context.Background(), name, &stdin, args)
+}
+
+// RunCommandTimeout is RunCommand for tools without a runtime limit of
+// their own: the command is killed once timeout elapses.
+func RunCommandTimeout(timeout time.Duration, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return runCommand(ctx, name, nil, args)
+}
+
+// RunCommandContext is RunCommand for tools the caller may need to stop
+// early: the command is killed when ctx is done.
+func RunCommandContext(ctx context.Context, name string, args ...string) (string, error) {
+	return runCommand(ctx, name, nil, args)
+}
+
+func runCommand(ctx context.Context, name string, stdin *string, args []string) (string, error) {
+	if err := chaosInject(ChaosCommand); err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	WaitScanWindow(name)
+	extra, err := socksArgs(name)
+	if err != nil {
+		return "", err
+	}
+	args = append(args, extra...)
+	limits, caps := ToolLimitsFor(name), DetectLimitCapabilities()
+	// A missing tool is reported by exec itself, not by a wrapper.
+	path, argv := name, args
+	if _, err := exec.LookPath(name); err == nil {
+		path, argv = limits.wrap(caps, name, args)
+	}
+	cmd := exec.CommandContext(ctx, path, argv...)
+	if stdin != nil {
+		cmd.Stdin = strings.NewReader(*stdin)
+	}
+	sandboxMu.Lock()
+	s := sandbox
+	sandboxMu.Unlock()
+	if s == nil {
+		out, err := runLimited(cmd, name, limits, caps)
+		return string(out), err
+	}
+	dir, err := s.toolDir(name)
+	if err != nil {
+		return "", err
+	}
+	cmd.Dir = dir
+	cmd.Env = s.env()
+	rec := CommandRecord{
+		Time:   time.Now(),
+		Tool:   name,
+		Args:   redactArgs(args),
+		Dir:    dir,
+		Env:    s.envNames(),
+		Inputs: hashInputs(args),
+	}
+	if stdin != nil {
+		sum := sha256.Sum256([]byte(*stdin))
+		rec.StdinSHA = hex.EncodeToString(sum[:])
+	}
+	if path, err := exec.LookPath(name); err == nil {
+		rec.Path = path
+		rec.BinarySHA = s.binaryHash(path)
+	}
+	out, err := runLimited(cmd, name, limits, caps)
+	rec.DurationMs = time.Since(rec.Time).Milliseconds()
+	rec.ExitCode = cmd.ProcessState.ExitCode()
+	sum := sha256.Sum256(out)
+	rec.OutputSHA = hex.EncodeToString(sum[:])
+	s.record(rec)
+	return string(out), err
+}
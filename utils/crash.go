@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// crashLogLines is how many of the latest log lines a crash report keeps.
+const crashLogLines = 200
+
+// Panic is a recovered panic and the stack of the goroutine that raised it.
+type Panic struct {
+	Value string
+	Stack []byte
+}
+
+var (
+	panicMu sync.Mutex
+	panics  []Panic
+)
+
+// RecoverPanic records a panic of the calling goroutine for the running
+// stage instead of letting it end the process. It must be deferred
+// directly: recover only stops a panic from a deferred call.
+func RecoverPanic() {
+	if r := recover(); r != nil {
+		RecordPanic(r, debug.Stack())
+	}
+}
+
+// Guard runs fn, recording rather than raising any panic in it. Worker
+// pools guard each job, so one bad input does not stop a worker and leave
+// the rest of the jobs unconsumed. Each job first waits for the scan
+// window, so worker pools pause between jobs when it closes.
+func Guard(fn func()) {
+	WaitScanWindow("")
+	defer RecoverPanic()
+	fn()
+}
+
+// RecordPanic records a recovered panic value and its stack.
+func RecordPanic(value interface{}, stack []byte) {
+	panicMu.Lock()
+	panics = append(panics, Panic{Value: fmt.Sprint(value), Stack: stack})
+	panicMu.Unlock()
+}
+
+// TakePanics returns the panics recorded since the last call and forgets
+// them, so each stage reports its own.
+func TakePanics() []Panic {
+	panicMu.Lock()
+	defer panicMu.Unlock()
+	taken := panics
+	panics = nil
+	return taken
+}
+
+// WriteCrashReport writes the panics a stage raised, their stacks and the
+// last crashLogLines of the scan log to a file under runDir/debug, with
+// secrets redacted, and returns its path.
+func WriteCrashReport(runDir, stage string, recovered []Panic, logLines []string) (string, error) {
+	dir := filepath.Join(runDir, "debug")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	slug := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(stage))
+	path := filepath.Join(dir, "crash_"+now.Format("20060102_150405")+"_"+slug+".txt")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Stage %q panicked at %s\n", stage, now.Format(time.RFC3339))
+	for i, p := range recovered {
+		fmt.Fprintf(&b, "\n=== Panic %d of %d: %s\n\n%s", i+1, len(recovered), p.Value, p.Stack)
+	}
+	if len(logLines) > crashLogLines {
+		logLines = logLines[len(logLines)-crashLogLines:]
+	}
+	fmt.Fprintf(&b, "\n=== Last %d log line(s)\n\n", len(logLines))
+	for _, line := range logLines {
+		b.WriteString(line + "\n")
+	}
+	if err := ioutil.WriteFile(path, Redact([]byte(b.String()), SecretValues()), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
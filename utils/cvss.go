@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// cvssTemplates holds the default CVSS 3.1 vector per issue type, keyed
+// by taxonomy ID.
+var cvssTemplates = map[string]string{
+	"sqli":                       "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H",
+	"xss":                        "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N",
+	"reflected-parameter":        "CVSS:3.1/AV:N/AC:H/PR:N/UI:R/S:C/C:L/I:L/A:N",
+	"crlf-injection":             "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N",
+	"cors-misconfiguration":      "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:U/C:H/I:N/A:N",
+	"exposed-git":                "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N",
+	"exposed-svn":                "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:L/I:N/A:N",
+	"exposed-hg":                 "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:L/I:N/A:N",
+	"exposed-jenkinsfile":        "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:L/I:N/A:N",
+	"exposed-gitlab-ci":          "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:L/I:N/A:N",
+	"exposed-circleci":           "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:L/I:N/A:N",
+	"weak-tls-protocol":          "CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:U/C:L/I:N/A:N",
+	"weak-tls-cipher":            "CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:U/C:L/I:N/A:N",
+	"http-trace":                 "CVSS:3.1/AV:N/AC:H/PR:N/UI:R/S:U/C:L/I:N/A:N",
+	"risky-http-methods":         "CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:U/C:N/I:L/A:L",
+	"content-type-mismatch":      "CVSS:3.1/AV:N/AC:H/PR:N/UI:R/S:C/C:L/I:L/A:N",
+	"reflected-file-download":    "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:U/C:L/I:L/A:N",
+	"subdomain-takeover":         "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:L/I:H/A:N",
+	"dangling-cname":             "CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:U/C:L/I:H/A:N",
+	"wordpress-user-enumeration": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:L/I:N/A:N",
+	"exposed-secret":             "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:N",
+}
+
+// cvssUnscored lists the registered types without a template: the weakness
+// and its score vary per finding, or the finding is informational.
+var cvssUnscored = map[string]string{
+	"dalfox-grep":                    "varies per match",
+	"testssl-finding":                "varies per testssl.sh check",
+	"nuclei-template":                "defined by the template",
+	"nikto-finding":                  "varies per nikto item",
+	"nikto-informational":            "informational",
+	"nessus-plugin":                  "scored by the Nessus plugin",
+	"wordpress-vulnerable-component": "scored by the component's CVE",
+	"wordpress-interesting-finding":  "informational",
+	"expired-security-txt":           "informational",
+	"response-anomaly":               "informational",
+	"http-desync":                    "an unconfirmed indicator",
+	"hop-by-hop-header":              "an unconfirmed indicator",
+	"mixed-content":                  "varies per element",
+	"insecure-form-action":           "varies per element",
+}
+
+// authenticatedTag marks hosts, and through them findings, only reachable
+// after logging in; their templates require low privileges.
+const authenticatedTag = TagAccess + ":authenticated"
+
+// cvssWeights maps each base metric value onto its CVSS 3.1 weight.
+// PR is handled separately because its weight depends on Scope.
+var cvssWeights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// ParseCVSSVector splits a CVSS 3.x base vector into its metrics.
+// The "CVSS:3.x/" prefix is optional; every base metric must appear exactly once.
+func ParseCVSSVector(vector string) (map[string]string, error) {
+	v := strings.TrimPrefix(strings.TrimPrefix(vector, "CVSS:3.1/"), "CVSS:3.0/")
+	metrics := make(map[string]string)
+	for _, part := range strings.Split(v, "/") {
+		kv := strings.SplitN(part, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("malformed CVSS metric %q in %q", part, vector)
+		}
+		if _, dup := metrics[kv[0]]; dup {
+			return nil, fmt.Errorf("duplicate CVSS metric %q in %q", kv[0], vector)
+		}
+		metrics[kv[0]] = kv[1]
+	}
+	for _, m := range []string{"AV", "AC", "PR", "UI", "S", "C", "I", "A"} {
+		val, ok := metrics[m]
+		if !ok {
+			return nil, fmt.Errorf("missing CVSS metric %s in %q", m, vector)
+		}
+		valid := false
+		switch m {
+		case "PR":
+			valid = val == "N" || val == "L" || val == "H"
+		case "S":
+			valid = val == "U" || val == "C"
+		default:
+			_, valid = cvssWeights[m][val]
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid value %q for CVSS metric %s", val, m)
+		}
+	}
+	return metrics, nil
+}
+
+// cvssRoundup implements the CVSS 3.1 Roundup function (Appendix A of the spec).
+func cvssRoundup(x float64) float64 {
+	i := int64(math.Round(x * 100000))
+	if i%10000 == 0 {
+		return float64(i) / 100000.0
+	}
+	return float64(i/10000+1) / 10.0
+}
+
+// CVSSBaseScore computes the CVSS 3.1 base score of a vector.
+func CVSSBaseScore(vector string) (float64, error) {
+	m, err := ParseCVSSVector(vector)
+	if err != nil {
+		return 0, err
+	}
+	changed := m["S"] == "C"
+	pr := map[string]float64{"N": 0.85, "L": 0.62, "H": 0.27}[m["PR"]]
+	if changed && m["PR"] == "L" {
+		pr = 0.68
+	} else if changed && m["PR"] == "H" {
+		pr = 0.5
+	}
+
+	iss := 1 - (1-cvssWeights["C"][m["C"]])*(1-cvssWeights["I"][m["I"]])*(1-cvssWeights["A"][m["A"]])
+	var impact float64
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	} else {
+		impact = 6.42 * iss
+	}
+	exploitability := 8.22 * cvssWeights["AV"][m["AV"]] * cvssWeights["AC"][m["AC"]] * pr * cvssWeights["UI"][m["UI"]]
+	if impact <= 0 {
+		return 0, nil
+	}
+	if changed {
+		return cvssRoundup(math.Min(1.08*(impact+exploitability), 10)), nil
+	}
+	return cvssRoundup(math.Min(impact+exploitability, 10)), nil
+}
+
+// CVSSSeverity maps a base score onto the CVSS 3.1 qualitative rating.
+func CVSSSeverity(score float64) string {
+	switch {
+	case score == 0:
+		return "None"
+	case score < 4.0:
+		return "Low"
+	case score < 7.0:
+		return "Medium"
+	case score < 9.0:
+		return "High"
+	default:
+		return "Critical"
+	}
+}
+
+// requirePrivileges raises a vector's PR:N to PR:L, for findings an
+// attacker must be logged in to reach.
+func requirePrivileges(vector string) string {
+	return strings.Replace(vector, "/PR:N/", "/PR:L/", 1)
+}
+
+// ApplyCVSS attaches the template vector and base score for the finding's
+// type, requiring low privileges when it is tagged access:authenticated.
+// Vectors the tool supplied, and types without a template, are left
+// untouched; template vectors are recomputed, so calling it again after
+// tagging applies the adjustment.
+func ApplyCVSS(v *types.VulnerabilityResult) {
+	tmpl, ok := cvssTemplates[v.Type]
+	if !ok {
+		return
+	}
+	if v.Vector != "" && v.Vector != tmpl && v.Vector != requirePrivileges(tmpl) {
+		return
+	}
+	vector := tmpl
+	if HasTag(v.Tags, authenticatedTag) {
+		vector = requirePrivileges(tmpl)
+	}
+	score, err := CVSSBaseScore(vector)
+	if err != nil {
+		return
+	}
+	v.Vector = vector
+	v.Score = score
+}
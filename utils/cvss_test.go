@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// TestCVSSBaseScoreReference checks the scoring against published base
+// scores.
+func TestCVSSBaseScoreReference(t *testing.T) {
+	tests := []struct {
+		name   string
+		vector string
+		score  float64
+	}{
+		{"CVE-2014-0160 Heartbleed", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N", 7.5},
+		{"CVE-2014-6271 Shellshock", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", 9.8},
+		{"CVE-2013-1937 phpMyAdmin XSS", "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N", 6.1},
+		{"CVE-2014-3566 POODLE", "CVSS:3.0/AV:N/AC:H/PR:N/UI:R/S:U/C:L/I:N/A:N", 3.1},
+		{"CVE-2016-5195 Dirty COW", "CVSS:3.1/AV:L/AC:L/PR:L/UI:N/S:U/C:H/I:H/A:H", 7.8},
+		{"CVE-2017-5753 Spectre", "CVSS:3.1/AV:L/AC:H/PR:L/UI:N/S:C/C:H/I:N/A:N", 5.6},
+		{"CVE-2021-44228 Log4Shell", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H", 10.0},
+		{"scope changed, low privileges", "AV:N/AC:L/PR:L/UI:N/S:C/C:H/I:H/A:H", 9.9},
+		{"no impact", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:N", 0},
+	}
+	for _, tt := range tests {
+		got, err := CVSSBaseScore(tt.vector)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.score {
+			t.Errorf("%s: %s scores %.1f, want %.1f", tt.name, tt.vector, got, tt.score)
+		}
+	}
+}
+
+func TestParseCVSSVectorErrors(t *testing.T) {
+	for _, vector := range []string{
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H",
+		"CVSS:3.1/AV:X/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H",
+		"CVSS:3.1/AV:N/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H",
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A",
+		"",
+	} {
+		if _, err := ParseCVSSVector(vector); err == nil {
+			t.Errorf("ParseCVSSVector(%q) accepted", vector)
+		}
+	}
+}
+
+func TestCVSSRoundup(t *testing.T) {
+	for in, want := range map[float64]float64{4.0: 4.0, 4.000002: 4.0, 4.02: 4.1, 4.1: 4.1, 0.0: 0.0} {
+		if got := cvssRoundup(in); got != want {
+			t.Errorf("cvssRoundup(%v) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+// TestCVSSTemplatesCoverRegistry keeps every registered type either
+// templated or explicitly unscored, and every template valid.
+func TestCVSSTemplatesCoverRegistry(t *testing.T) {
+	for _, it := range issueTypes {
+		_, templated := cvssTemplates[it.ID]
+		_, unscored := cvssUnscored[it.ID]
+		if templated == unscored {
+			t.Errorf("type %q: templated %v, unscored %v; want exactly one", it.ID, templated, unscored)
+		}
+	}
+	for _, m := range []map[string]string{cvssTemplates, cvssUnscored} {
+		for id := range m {
+			if _, ok := LookupIssueType(id); !ok {
+				t.Errorf("CVSS entry for unregistered type %q", id)
+			}
+		}
+	}
+	for id, vector := range cvssTemplates {
+		if _, err := CVSSBaseScore(vector); err != nil {
+			t.Errorf("template for %q: %v", id, err)
+		}
+	}
+}
+
+func TestApplyCVSS(t *testing.T) {
+	authenticated := map[string][]string{"access": {"authenticated"}}
+	tests := []struct {
+		name   string
+		in     types.VulnerabilityResult
+		vector string
+		score  float64
+	}{
+		{"template by type", types.VulnerabilityResult{Type: "sqli", Issue: "SQL Injection (boolean-based blind)"},
+			"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", 9.8},
+		{"authenticated only", types.VulnerabilityResult{Type: "sqli", Tags: authenticated},
+			"CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:U/C:H/I:H/A:H", 8.8},
+		{"authenticated, scope changed", types.VulnerabilityResult{Type: "xss", Tags: authenticated},
+			"CVSS:3.1/AV:N/AC:L/PR:L/UI:R/S:C/C:L/I:L/A:N", 5.4},
+		{"tagged after a first pass", types.VulnerabilityResult{Type: "sqli", Tags: authenticated, Vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", Score: 9.8},
+			"CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:U/C:H/I:H/A:H", 8.8},
+		{"tool vector kept", types.VulnerabilityResult{Type: "nessus-plugin", Vector: "CVSS:3.0/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N", Score: 7.5},
+			"CVSS:3.0/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N", 7.5},
+		{"tool vector on a templated type kept", types.VulnerabilityResult{Type: "xss", Vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:U/C:L/I:L/A:N", Score: 5.4},
+			"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:U/C:L/I:L/A:N", 5.4},
+		{"unscored type", types.VulnerabilityResult{Type: "nuclei-template"}, "", 0},
+		{"unregistered type", types.VulnerabilityResult{Type: "open-redirect", Issue: "Open Redirect"}, "", 0},
+	}
+	for _, tt := range tests {
+		v := tt.in
+		ApplyCVSS(&v)
+		if v.Vector != tt.vector || v.Score != tt.score {
+			t.Errorf("%s: %s %.1f, want %s %.1f", tt.name, v.Vector, v.Score, tt.vector, tt.score)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	rpprof "runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxDebugSnapshots bounds how many threshold-triggered snapshots a run writes.
+const maxDebugSnapshots = 10
+
+// DebugThresholds are the goroutine and heap levels above which --debug
+// snapshots the process.
+type DebugThresholds struct {
+	Goroutines int
+	HeapBytes  uint64
+}
+
+// DebugThresholdsFromEnv reads DEBUG_GOROUTINE_THRESHOLD (default 1000) and
+// DEBUG_HEAP_MB (default 1024).
+func DebugThresholdsFromEnv() DebugThresholds {
+	th := DebugThresholds{Goroutines: 1000, HeapBytes: 1024 << 20}
+	if n, err := strconv.Atoi(os.Getenv("DEBUG_GOROUTINE_THRESHOLD")); err == nil && n > 0 {
+		th.Goroutines = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("DEBUG_HEAP_MB")); err == nil && n > 0 {
+		th.HeapBytes = uint64(n) << 20
+	}
+	return th
+}
+
+// DebugSample is one line of debug/metrics.jsonl.
+type DebugSample struct {
+	Time       time.Time `json:"time"`
+	Goroutines int       `json:"goroutines"`
+	HeapBytes  uint64    `json:"heap_bytes"`
+	LogQueue   int       `json:"log_queue"`
+	LogDropped uint64    `json:"log_dropped"`
+}
+
+// Crossed lists the thresholds s exceeds.
+func (th DebugThresholds) Crossed(s DebugSample) []string {
+	var reasons []string
+	if s.Goroutines > th.Goroutines {
+		reasons = append(reasons, fmt.Sprintf("%d goroutines > %d", s.Goroutines, th.Goroutines))
+	}
+	if s.HeapBytes > th.HeapBytes {
+		reasons = append(reasons, fmt.Sprintf("heap %d MB > %d MB", s.HeapBytes>>20, th.HeapBytes>>20))
+	}
+	return reasons
+}
+
+// DebugMonitor samples the process while --debug is set. It appends every
+// sample to debug/metrics.jsonl and writes goroutine and heap profiles when
+// a threshold is first crossed; it re-arms once the process drops below.
+type DebugMonitor struct {
+	Dir        string
+	Thresholds DebugThresholds
+	Logger     *Logger
+	snapshots  int
+	above      bool
+}
+
+// Observe records s and snapshots the process if it just crossed a
+// threshold. It returns the path prefix of the snapshot, if one was written.
+func (m *DebugMonitor) Observe(s DebugSample) (string, error) {
+	if err := appendJSONLine(filepath.Join(m.Dir, "metrics.jsonl"), s); err != nil {
+		return "", err
+	}
+	reasons := m.Thresholds.Crossed(s)
+	if len(reasons) == 0 {
+		m.above = false
+		return "", nil
+	}
+	if m.above || m.snapshots >= maxDebugSnapshots {
+		return "", nil
+	}
+	m.above = true
+	m.snapshots++
+	prefix := filepath.Join(m.Dir, s.Time.Format("20060102_150405")+"_"+strconv.Itoa(m.snapshots))
+	if err := ioutil.WriteFile(prefix+"_reason.txt", []byte(strings.Join(reasons, "\n")+"\n"), 0644); err != nil {
+		return "", err
+	}
+	if err := writeProfile("goroutine", prefix+"_goroutines.txt", 2); err != nil {
+		return "", err
+	}
+	if err := writeProfile("heap", prefix+"_heap.pprof", 0); err != nil {
+		return "", err
+	}
+	return prefix, nil
+}
+
+// Run samples the process every interval until stop is closed.
+func (m *DebugMonitor) Run(interval time.Duration, stop <-chan struct{}, logFn func(string)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		s := DebugSample{Time: time.Now(), Goroutines: runtime.NumGoroutine(), HeapBytes: ms.HeapAlloc}
+		if m.Logger != nil {
+			s.LogQueue = m.Logger.QueueDepth()
+			s.LogDropped = m.Logger.Dropped()
+		}
+		prefix, err := m.Observe(s)
+		if err != nil {
+			logFn("[!] Debug snapshot failed: " + err.Error())
+		} else if prefix != "" {
+			logFn("[!] Debug threshold crossed; snapshot written to " + prefix + "_*")
+		}
+	}
+}
+
+// writeProfile writes the named runtime profile to path.
+func writeProfile(name, path string, debug int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return rpprof.Lookup(name).WriteTo(f, debug)
+}
+
+// appendJSONLine appends v as one JSON line to path.
+func appendJSONLine(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// StartPprof serves the pprof endpoints on addr from a dedicated mux, so
+// nothing is exposed unless --debug starts it.
+func StartPprof(addr string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	go http.Serve(ln, mux)
+	return ln, nil
+}
+
+// secretEnvSuffixes mark environment variables whose values are redacted
+// from debug bundles.
+var secretEnvSuffixes = []string{"_KEY", "_TOKEN", "_SECRET", "_PASSWORD", "_PASS"}
+
+// extraSecrets are secrets that come from flags rather than the
+// environment, such as the --socks5 password.
+var (
+	extraSecrets   []string
+	extraSecretsMu sync.Mutex
+)
+
+// RegisterSecret adds value to the secrets redacted from recorded commands,
+// crash reports and debug bundles. Values shorter than four characters are
+// ignored, as they are for the environment.
+func RegisterSecret(value string) {
+	if len(value) < 4 {
+		return
+	}
+	extraSecretsMu.Lock()
+	extraSecrets = append(extraSecrets, value)
+	extraSecretsMu.Unlock()
+}
+
+// SecretValues returns the values of secret-looking environment variables
+// and registered secrets, longest first so overlapping values are redacted
+// whole.
+func SecretValues() []string {
+	extraSecretsMu.Lock()
+	values := append([]string(nil), extraSecrets...)
+	extraSecretsMu.Unlock()
+	for _, kv := range os.Environ() {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || len(parts[1]) < 4 {
+			continue
+		}
+		name := strings.ToUpper(parts[0])
+		for _, suffix := range secretEnvSuffixes {
+			if strings.HasSuffix(name, suffix) {
+				values = append(values, parts[1])
+				break
+			}
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	return values
+}
+
+// urlUserinfoRe matches the user:password@ part of a URL.
+var urlUserinfoRe = regexp.MustCompile(`([A-Za-z][A-Za-z0-9+.-]*://)[^/?#@\s]+@`)
+
+// Redact replaces every occurrence of the given secrets in data, and the
+// credentials of any URL in it.
+func Redact(data []byte, secrets []string) []byte {
+	s := urlUserinfoRe.ReplaceAllString(string(data), "${1}[REDACTED]@")
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, "[REDACTED]")
+		}
+	}
+	return []byte(s)
+}
+
+// debugBundleFiles are the run artifacts packaged besides the debug directory.
+var debugBundleFiles = []string{"scan.log", "commands.jsonl", "summary.json", "scope_allow.txt", "scope_deny.txt"}
+
+// WriteDebugBundle packages runDir's debug snapshots, scan log, command
+// manifest and summary into a .tar.gz at dest with secrets redacted from
+// every file. Missing artifacts are skipped.
+func WriteDebugBundle(runDir, dest string, secrets []string) ([]string, error) {
+	var names []string
+	for _, name := range debugBundleFiles {
+		if _, err := os.Stat(filepath.Join(runDir, name)); err == nil {
+			names = append(names, name)
+		}
+	}
+	entries, _ := ioutil.ReadDir(filepath.Join(runDir, "debug"))
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, filepath.Join("debug", e.Name()))
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s contains no run artifacts", runDir)
+	}
+	tmp := dest + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+	err = writeBundle(f, runDir, names, secrets)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return names, os.Rename(tmp, dest)
+}
+
+// writeBundle writes the named files of runDir to w as a gzipped tar.
+func writeBundle(w io.Writer, runDir string, names, secrets []string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		data, err := ioutil.ReadFile(filepath.Join(runDir, name))
+		if err != nil {
+			return err
+		}
+		// Heap profiles are compressed stack samples: redaction cannot see
+		// into them and they hold no memory contents.
+		if !strings.HasSuffix(name, ".pprof") {
+			data = Redact(data, secrets)
+		}
+		hdr := &tar.Header{Name: filepath.ToSlash(name), Mode: 0644, Size: int64(len(data)), ModTime: time.Now()}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// Kinds of run events.
+const (
+	EventStage   = "stage"
+	EventCounts  = "counts"
+	EventFinding = "finding"
+)
+
+// RunEvent is one line of a run's events.jsonl: a stage finished, the
+// counts after it, or a new finding.
+type RunEvent struct {
+	Time    time.Time                  `json:"time"`
+	Kind    string                     `json:"kind"`
+	Stage   *types.StageStatus         `json:"stage,omitempty"`
+	Counts  *RunCounts                 `json:"counts,omitempty"`
+	Finding *types.VulnerabilityResult `json:"finding,omitempty"`
+}
+
+// RunCounts are the totals of a run so far.
+type RunCounts struct {
+	Subdomains int `json:"subdomains"`
+	LiveHosts  int `json:"live_hosts"`
+	URLs       int `json:"urls"`
+	Findings   int `json:"findings"`
+}
+
+// EventLog appends run events to a JSON lines file as they happen, so
+// other processes can follow the run from its directory.
+type EventLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenEventLog opens the event log at path for appending.
+func OpenEventLog(path string) (*EventLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &EventLog{f: f}, nil
+}
+
+// Write appends ev, stamped with the current time when it has none, as one
+// line written in one call. A nil log discards it.
+func (e *EventLog) Write(ev RunEvent) error {
+	if e == nil {
+		return nil
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = e.f.Write(append(data, '\n'))
+	return err
+}
+
+// Close closes the log file.
+func (e *EventLog) Close() error {
+	if e == nil {
+		return nil
+	}
+	return e.f.Close()
+}
+
+// RunCompleted reports whether the run in dir has finished: its
+// summary.json, written once the run is over, reads as not running. A
+// summary.json caught half written does not count.
+func RunCompleted(dir string) bool {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "summary.json"))
+	if err != nil {
+		return false
+	}
+	var summary struct {
+		Running *bool `json:"running"`
+	}
+	if err := json.Unmarshal(data, &summary); err != nil || summary.Running == nil {
+		return false
+	}
+	return !*summary.Running
+}
+
+// WatchState is what a watcher knows of a run, rebuilt from its
+// events.jsonl and scan.log alone.
+type WatchState struct {
+	Stages   []types.StageStatus
+	Counts   RunCounts
+	Findings []types.VulnerabilityResult
+	Log      []string
+	// BadEvents counts event lines that did not parse.
+	BadEvents int
+
+	maxFindings, maxLog int
+}
+
+// NewWatchState returns an empty state keeping the latest maxFindings
+// findings and maxLog log lines.
+func NewWatchState(maxFindings, maxLog int) *WatchState {
+	return &WatchState{maxFindings: maxFindings, maxLog: maxLog}
+}
+
+// AddEvents applies event lines.
+func (w *WatchState) AddEvents(lines []string) {
+	for _, line := range lines {
+		var ev RunEvent
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			w.BadEvents++
+			continue
+		}
+		switch {
+		case ev.Kind == EventStage && ev.Stage != nil:
+			w.Stages = append(w.Stages, *ev.Stage)
+		case ev.Kind == EventCounts && ev.Counts != nil:
+			w.Counts = *ev.Counts
+		case ev.Kind == EventFinding && ev.Finding != nil:
+			w.Findings = append(w.Findings, *ev.Finding)
+			if len(w.Findings) > w.maxFindings {
+				w.Findings = w.Findings[len(w.Findings)-w.maxFindings:]
+			}
+		}
+	}
+}
+
+// AddLog appends log lines.
+func (w *WatchState) AddLog(lines []string) {
+	w.Log = append(w.Log, lines...)
+	if len(w.Log) > w.maxLog {
+		w.Log = append([]string(nil), w.Log[len(w.Log)-w.maxLog:]...)
+	}
+}
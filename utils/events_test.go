@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestRunCompleted(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary string
+		done    bool
+	}{
+		{"no summary yet", "", false},
+		{"running", `{"subdomains":[],"running":true}`, false},
+		{"half written", `{"subdomains":[],"running":fal`, false},
+		{"truncated to nothing", " ", false},
+		{"not a run summary", `{"hosts":3}`, false},
+		{"completed", `{"subdomains":[],"running":false,"final_report":"done"}`, true},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		if tt.summary != "" {
+			if err := ioutil.WriteFile(filepath.Join(dir, "summary.json"), []byte(tt.summary), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := RunCompleted(dir); got != tt.done {
+			t.Errorf("%s: RunCompleted = %v", tt.name, got)
+		}
+	}
+
+	// What PersistResults writes at the end of a run counts.
+	dir := t.TempDir()
+	if err := PersistResults(types.ScanResult{Running: true}, dir); err != nil {
+		t.Fatal(err)
+	}
+	if RunCompleted(dir) {
+		t.Error("a running scan's summary counted as completed")
+	}
+	PersistResults(types.ScanResult{}, dir)
+	if !RunCompleted(dir) {
+		t.Error("a finished scan's summary did not count as completed")
+	}
+}
+
+func TestEventLogWatchState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	log, err := OpenEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nilLog *EventLog
+	if err := nilLog.Write(RunEvent{Kind: EventStage}); err != nil {
+		t.Errorf("nil log: %v", err)
+	}
+	events := []RunEvent{
+		{Kind: EventStage, Stage: &types.StageStatus{Stage: "subdomain enumeration", Status: "completed"}},
+		{Kind: EventCounts, Counts: &RunCounts{Subdomains: 12}},
+		{Kind: EventStage, Stage: &types.StageStatus{Stage: "HTTP probing", Status: "completed-with-warnings", Messages: []string{"2 timeouts"}}},
+		{Kind: EventCounts, Counts: &RunCounts{Subdomains: 12, LiveHosts: 7, URLs: 140, Findings: 3}},
+	}
+	for i := 0; i < 3; i++ {
+		events = append(events, RunEvent{Kind: EventFinding, Finding: &types.VulnerabilityResult{Issue: "XSS", URL: "https://a.example.com/?q=" + string(rune('0'+i))}})
+	}
+	for _, ev := range events {
+		if err := log.Write(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	log.Close()
+
+	tail := NewTailer(path)
+	defer tail.Close()
+	lines, err := tail.Poll()
+	if err != nil || len(lines) != len(events) {
+		t.Fatalf("%d line(s), %v", len(lines), err)
+	}
+	w := NewWatchState(2, 3)
+	w.AddEvents(append(lines, `{"kind":"stage"`, `not json`, `{"kind":"stage"}`, `{"kind":"unknown"}`))
+	if len(w.Stages) != 2 || w.Stages[1].Stage != "HTTP probing" || w.Stages[1].Messages[0] != "2 timeouts" {
+		t.Errorf("stages %+v", w.Stages)
+	}
+	if w.Counts != (RunCounts{Subdomains: 12, LiveHosts: 7, URLs: 140, Findings: 3}) {
+		t.Errorf("counts %+v", w.Counts)
+	}
+	if len(w.Findings) != 2 || !strings.HasSuffix(w.Findings[1].URL, "q=2") {
+		t.Errorf("findings %+v", w.Findings)
+	}
+	if w.BadEvents != 2 {
+		t.Errorf("%d bad event(s), want 2", w.BadEvents)
+	}
+	w.AddLog([]string{"a", "b"})
+	w.AddLog([]string{"c", "d"})
+	if strings.Join(w.Log, ",") != "b,c,d" {
+		t.Errorf("log %q", w.Log)
+	}
+	if _, err := OpenEventLog(filepath.Join(t.TempDir(), "missing", "events.jsonl")); !os.IsNotExist(err) {
+		t.Errorf("OpenEventLog in a missing directory: %v", err)
+	}
+}
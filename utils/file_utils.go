@@ -2,17 +2,52 @@ package utils
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 
-	"recon-tool/main" // adjust import if needed
+	"github.com/MKlolbullen/Goforgold2/types"
 )
 
-func PersistResults(result main.ScanResult, outDir string) error {
+// WriteLines writes a slice of strings to a file, one per line.
+func WriteLines(lines []string, filePath string) error {
+	if err := chaosInject(ChaosWrite); err != nil {
+		return fmt.Errorf("%s: %w", filePath, err)
+	}
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, l := range lines {
+		if _, err := f.WriteString(l + "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UniqueStrings returns the unique elements of a slice, preserving order.
+func UniqueStrings(input []string) []string {
+	seen := make(map[string]bool)
+	var res []string
+	for _, s := range input {
+		if !seen[s] {
+			seen[s] = true
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+func PersistResults(result types.ScanResult, outDir string) error {
 	summaryFile := filepath.Join(outDir, "summary.json")
 	data, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
 		return err
 	}
+	if err := chaosInject(ChaosWrite); err != nil {
+		return fmt.Errorf("%s: %w", summaryFile, err)
+	}
 	return os.WriteFile(summaryFile, data, 0644)
 }
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// FindingFingerprint returns a stable identifier for a finding. Exports use
+// it to deduplicate repeated imports and triage uses it to carry
+// dispositions over to later runs. The parameter, when known, is part of
+// it, so injection points on one URL stay apart; findings without one keep
+// the identifier earlier runs recorded.
+func FindingFingerprint(v types.VulnerabilityResult) string {
+	key := strings.ToLower(v.Issue) + "|" + v.URL
+	if v.Parameter != "" {
+		key += "|" + v.Parameter
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Confidence levels of a finding: confirmed when the check proved the issue,
+// for example with an executed payload; firm when a reliable signature
+// matched; tentative when it only hints at one and needs a closer look.
+const (
+	ConfidenceConfirmed = "confirmed"
+	ConfidenceFirm      = "firm"
+	ConfidenceTentative = "tentative"
+)
+
+// FindingConfidence returns the confidence of v. Findings whose check does
+// not rate them, such as imported ones, are firm.
+func FindingConfidence(v types.VulnerabilityResult) string {
+	switch v.Confidence {
+	case ConfidenceConfirmed, ConfidenceTentative:
+		return v.Confidence
+	}
+	return ConfidenceFirm
+}
+
+// FindingSeverity rates a finding Critical, High, Medium, Low or Info. With
+// SEVERITY_FROM_CVSS=true the CVSS base score decides when one is known;
+// otherwise a tool-reported severity wins over the taxonomy default for the
+// finding's type.
+func FindingSeverity(v types.VulnerabilityResult) string {
+	if v.Score > 0 && os.Getenv("SEVERITY_FROM_CVSS") == "true" {
+		return CVSSSeverity(v.Score)
+	}
+	switch strings.ToLower(v.Severity) {
+	case "critical":
+		return "Critical"
+	case "high":
+		return "High"
+	case "medium":
+		return "Medium"
+	case "low":
+		return "Low"
+	case "info", "informational", "unknown":
+		return "Info"
+	}
+	if t, ok := LookupIssueType(v.Type); ok {
+		return t.Severity
+	}
+	return "Info"
+}
+
+// IsVerifiedSecret reports whether v is an exposed secret that trufflehog or
+// --verify-secrets confirmed is live.
+func IsVerifiedSecret(v types.VulnerabilityResult) bool {
+	return v.Type == "exposed-secret" && HasTag(v.Tags, TagState+":verified")
+}
+
+// IsConfirmedTakeover reports whether v is a subdomain takeover that the
+// verification strategy for its service confirmed.
+func IsConfirmedTakeover(v types.VulnerabilityResult) bool {
+	return v.Type == "subdomain-takeover" && HasTag(v.Tags, TagState+":confirmed")
+}
+
+// severityRank orders severities from most to least severe.
+var severityRank = map[string]int{"Critical": 0, "High": 1, "Medium": 2, "Low": 3, "Info": 4}
+
+// SeverityRank returns the rank of severity, 0 for Critical to 4 for Info,
+// and whether it is one of the five.
+func SeverityRank(severity string) (int, bool) {
+	r, ok := severityRank[severity]
+	return r, ok
+}
+
+// SeverityLess orders findings most severe first, then by CVSS score,
+// issue and URL.
+func SeverityLess(a, b types.VulnerabilityResult) bool {
+	if ra, rb := severityRank[FindingSeverity(a)], severityRank[FindingSeverity(b)]; ra != rb {
+		return ra < rb
+	}
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	if a.Issue != b.Issue {
+		return a.Issue < b.Issue
+	}
+	return a.URL < b.URL
+}
+
+// SortBySeverity returns a copy of vulns ordered by SeverityLess.
+func SortBySeverity(vulns []types.VulnerabilityResult) []types.VulnerabilityResult {
+	sorted := append([]types.VulnerabilityResult(nil), vulns...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return SeverityLess(sorted[i], sorted[j])
+	})
+	return sorted
+}
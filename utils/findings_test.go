@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestFindingFingerprint(t *testing.T) {
+	sqli := func(param string) types.VulnerabilityResult {
+		return types.VulnerabilityResult{URL: "http://shop.example.com/item.php?id=4&cat=2", Issue: "SQL Injection", Type: "sqli", Parameter: param}
+	}
+	reflected := func(param, detail string) types.VulnerabilityResult {
+		return types.VulnerabilityResult{URL: "https://example.com/search?q=1&p=2", Issue: "Reflected Parameter", Type: "reflected-parameter", Parameter: param, Detail: detail}
+	}
+	tests := []struct {
+		name string
+		a, b types.VulnerabilityResult
+		same bool
+	}{
+		{"two parameters on one URL", sqli("id (GET)"), sqli("cat (GET)"), false},
+		{"same parameter, different place", sqli("id (GET)"), sqli("id (POST)"), false},
+		{"kxss parameters on one URL", reflected("q", "Param: q Unfiltered: [<]"), reflected("p", "Param: p Unfiltered: [<]"), false},
+		{"same injection point seen again", sqli("id (GET)"), sqli("id (GET)"), true},
+		{"detail and issue case do not count", reflected("q", "Param: q Unfiltered: [<]"),
+			types.VulnerabilityResult{URL: "https://example.com/search?q=1&p=2", Issue: "reflected parameter", Parameter: "q", Detail: `Param: q Unfiltered: [< > "]`}, true},
+		{"with and without a parameter", sqli("id (GET)"), sqli(""), false},
+	}
+	for _, tt := range tests {
+		if same := FindingFingerprint(tt.a) == FindingFingerprint(tt.b); same != tt.same {
+			t.Errorf("%s: same fingerprint %v, want %v", tt.name, same, tt.same)
+		}
+	}
+
+	// Findings without a parameter keep the fingerprint earlier runs
+	// recorded, so their triage and baselines still match.
+	v := types.VulnerabilityResult{URL: "https://www.example.com/.git/config", Issue: "Exposed Git Metadata"}
+	sum := sha256.Sum256([]byte("exposed git metadata|https://www.example.com/.git/config"))
+	if got := FindingFingerprint(v); got != hex.EncodeToString(sum[:]) {
+		t.Errorf("fingerprint without a parameter changed: %s", got)
+	}
+}
+
+func TestFindingConfidence(t *testing.T) {
+	for _, tt := range []struct{ confidence, want string }{
+		{ConfidenceConfirmed, ConfidenceConfirmed},
+		{ConfidenceFirm, ConfidenceFirm},
+		{ConfidenceTentative, ConfidenceTentative},
+		// Unrated findings, such as imported ones, are firm.
+		{"", ConfidenceFirm},
+		{"certain", ConfidenceFirm},
+	} {
+		if got := FindingConfidence(types.VulnerabilityResult{Confidence: tt.confidence}); got != tt.want {
+			t.Errorf("FindingConfidence(%q) = %q, want %q", tt.confidence, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// NormalizeHostname canonicalizes a hostname for comparisons: surrounding
+// whitespace, a URL scheme, any path or port and the trailing dot are
+// removed and the result is lower-cased.
+func NormalizeHostname(host string) string {
+	h := strings.ToLower(strings.TrimSpace(host))
+	if i := strings.Index(h, "://"); i >= 0 {
+		h = h[i+3:]
+	}
+	if i := strings.IndexAny(h, "/?#"); i >= 0 {
+		h = h[:i]
+	}
+	if i := strings.LastIndex(h, ":"); i >= 0 && !strings.Contains(h[:i], ":") {
+		h = h[:i]
+	}
+	return strings.TrimSuffix(h, ".")
+}
+
+// LoadInventory reads an inventory CSV. The first column is the hostname
+// (optionally a "*.example.com" wildcard), followed by optional owner and
+// environment columns. A header row naming the columns is recognized and
+// may reorder them. Blank lines and lines starting with # are ignored.
+func LoadInventory(path string) ([]types.InventoryEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+
+	cols := map[string]int{"hostname": 0, "owner": 1, "environment": 2}
+	var entries []types.InventoryEntry
+	for row := 1; ; row++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("inventory %s: %v", path, err)
+		}
+		if row == 1 && containsFold(rec, "hostname") {
+			cols = map[string]int{"hostname": -1, "owner": -1, "environment": -1}
+			for i, name := range rec {
+				name = strings.ToLower(strings.TrimSpace(name))
+				if _, ok := cols[name]; ok {
+					cols[name] = i
+				}
+			}
+			continue
+		}
+		field := func(name string) string {
+			if i := cols[name]; i >= 0 && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		host := NormalizeHostname(field("hostname"))
+		if host == "" {
+			continue
+		}
+		entries = append(entries, types.InventoryEntry{
+			Hostname:    host,
+			Owner:       field("owner"),
+			Environment: field("environment"),
+		})
+	}
+	return entries, nil
+}
+
+// containsFold reports whether any field of rec equals s, ignoring case.
+func containsFold(rec []string, s string) bool {
+	for _, v := range rec {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchInventory returns the inventory row covering host. Exact rows win
+// over wildcards; among wildcards the most specific suffix wins.
+func matchInventory(host string, entries []types.InventoryEntry) (types.InventoryEntry, bool) {
+	var best types.InventoryEntry
+	found := false
+	for _, e := range entries {
+		if e.Hostname == host {
+			return e, true
+		}
+		if strings.HasPrefix(e.Hostname, "*.") && strings.HasSuffix(host, e.Hostname[1:]) {
+			if !found || len(e.Hostname) > len(best.Hostname) {
+				best, found = e, true
+			}
+		}
+	}
+	return best, found
+}
+
+// Reconcile compares discovered hostnames against the inventory and returns
+// the matched assets, discovered assets missing from the inventory (shadow
+// assets) and inventory rows no discovered host corresponds to.
+func Reconcile(entries []types.InventoryEntry, discovered []string) types.Reconciliation {
+	rec := types.Reconciliation{
+		Matched: []types.InventoryMatch{},
+		Unknown: []string{},
+		Unseen:  []types.InventoryEntry{},
+	}
+	seenRows := make(map[string]bool)
+	seenHosts := make(map[string]bool)
+	for _, d := range discovered {
+		host := NormalizeHostname(d)
+		if host == "" || seenHosts[host] {
+			continue
+		}
+		seenHosts[host] = true
+		if e, ok := matchInventory(host, entries); ok {
+			seenRows[e.Hostname] = true
+			rec.Matched = append(rec.Matched, types.InventoryMatch{
+				Hostname:     host,
+				InventoryRow: e.Hostname,
+				Owner:        e.Owner,
+				Environment:  e.Environment,
+			})
+		} else {
+			rec.Unknown = append(rec.Unknown, host)
+		}
+	}
+	for _, e := range entries {
+		if !seenRows[e.Hostname] {
+			rec.Unseen = append(rec.Unseen, e)
+		}
+	}
+	sort.Strings(rec.Unknown)
+	return rec
+}
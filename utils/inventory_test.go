@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestLoadInventory(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		want []types.InventoryEntry
+		err  string
+	}{
+		{"positional columns", "# exported from the CMDB\nwww.example.com, web, production\n\nAPI.Example.com.,platform\n*.stg.example.com,,staging\nhttps://shop.example.com:8443/cart,ecommerce,production\n", []types.InventoryEntry{
+			{Hostname: "www.example.com", Owner: "web", Environment: "production"},
+			{Hostname: "api.example.com", Owner: "platform"},
+			{Hostname: "*.stg.example.com", Environment: "staging"},
+			{Hostname: "shop.example.com", Owner: "ecommerce", Environment: "production"},
+		}, ""},
+		{"header reorders columns", "Environment,Team,HOSTNAME,Owner\nproduction,blue,www.example.com,web\nstaging,green,dev.example.com\n", []types.InventoryEntry{
+			{Hostname: "www.example.com", Owner: "web", Environment: "production"},
+			{Hostname: "dev.example.com", Environment: "staging"},
+		}, ""},
+		{"rows without a hostname", ",orphan,production\n   \nmail.example.com\n", []types.InventoryEntry{
+			{Hostname: "mail.example.com"},
+		}, ""},
+		{"quoted fields", "\"vpn.example.com\",\"IT, networking\",production\n", []types.InventoryEntry{
+			{Hostname: "vpn.example.com", Owner: "IT, networking", Environment: "production"},
+		}, ""},
+		{"broken quoting", "\"vpn.example.com,IT\n", nil, "inventory"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "inventory.csv")
+		if err := ioutil.WriteFile(path, []byte(tt.csv), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := LoadInventory(path)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %+v", tt.name, got)
+		}
+	}
+	if _, err := LoadInventory(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Error("missing inventory: no error")
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	entries := []types.InventoryEntry{
+		{Hostname: "www.example.com", Owner: "web", Environment: "production"},
+		{Hostname: "*.example.com", Owner: "it"},
+		{Hostname: "*.stg.example.com", Owner: "platform", Environment: "staging"},
+		{Hostname: "api.stg.example.com", Owner: "api", Environment: "staging"},
+		{Hostname: "legacy.example.com", Owner: "web"},
+		{Hostname: "*.old.example.com", Owner: "web"},
+	}
+	discovered := []string{
+		"WWW.example.com.",
+		"api.stg.example.com",
+		"web.stg.example.com",
+		"mail.example.com",
+		"www.example.com",
+		"example.com",
+		"shop.partner.io",
+		"",
+	}
+	got := Reconcile(entries, discovered)
+	want := types.Reconciliation{
+		Matched: []types.InventoryMatch{
+			{Hostname: "www.example.com", InventoryRow: "www.example.com", Owner: "web", Environment: "production"},
+			// An exact row wins over wildcards, the longest wildcard over shorter ones.
+			{Hostname: "api.stg.example.com", InventoryRow: "api.stg.example.com", Owner: "api", Environment: "staging"},
+			{Hostname: "web.stg.example.com", InventoryRow: "*.stg.example.com", Owner: "platform", Environment: "staging"},
+			{Hostname: "mail.example.com", InventoryRow: "*.example.com", Owner: "it"},
+		},
+		// A wildcard does not cover the domain itself.
+		Unknown: []string{"example.com", "shop.partner.io"},
+		Unseen: []types.InventoryEntry{
+			{Hostname: "legacy.example.com", Owner: "web"},
+			{Hostname: "*.old.example.com", Owner: "web"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Reconcile =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// Nothing on either side still gives empty lists, not null, in JSON.
+	empty := Reconcile(nil, nil)
+	if empty.Matched == nil || empty.Unknown == nil || empty.Unseen == nil {
+		t.Errorf("Reconcile(nil, nil) = %+v", empty)
+	}
+}
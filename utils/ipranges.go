@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// Classes of special-purpose addresses.
+const (
+	IPPrivate       = "private"
+	IPLoopback      = "loopback"
+	IPLinkLocal     = "link-local"
+	IPCGNAT         = "cgnat"
+	IPUnspecified   = "unspecified"
+	IPDocumentation = "documentation"
+	IPBenchmarking  = "benchmarking"
+	IPMulticast     = "multicast"
+	IPBroadcast     = "broadcast"
+	IPReserved      = "reserved"
+)
+
+// specialRange is one special-purpose block of the IANA IPv4 and IPv6
+// registries (RFC 6890 and its updates) that is not globally reachable.
+type specialRange struct {
+	CIDR  string
+	Class string
+}
+
+// specialRanges lists the blocks most specific first, so a smaller block
+// inside a larger one wins.
+var specialRanges = []specialRange{
+	// IPv4.
+	{"255.255.255.255/32", IPBroadcast},
+	{"0.0.0.0/8", IPUnspecified},
+	{"10.0.0.0/8", IPPrivate},
+	{"100.64.0.0/10", IPCGNAT},
+	{"127.0.0.0/8", IPLoopback},
+	{"169.254.0.0/16", IPLinkLocal},
+	{"172.16.0.0/12", IPPrivate},
+	{"192.0.0.0/24", IPReserved},
+	{"192.0.2.0/24", IPDocumentation},
+	{"192.88.99.0/24", IPReserved},
+	{"192.168.0.0/16", IPPrivate},
+	{"198.18.0.0/15", IPBenchmarking},
+	{"198.51.100.0/24", IPDocumentation},
+	{"203.0.113.0/24", IPDocumentation},
+	{"224.0.0.0/4", IPMulticast},
+	{"240.0.0.0/4", IPReserved},
+	// IPv6.
+	{"::/128", IPUnspecified},
+	{"::1/128", IPLoopback},
+	{"64:ff9b:1::/48", IPPrivate},
+	{"100::/64", IPReserved},
+	{"2001:2::/48", IPBenchmarking},
+	{"2001:10::/28", IPReserved},
+	{"2001:db8::/32", IPDocumentation},
+	{"3fff::/20", IPDocumentation},
+	{"5f00::/16", IPReserved},
+	{"fc00::/7", IPPrivate},
+	{"fe80::/10", IPLinkLocal},
+	{"fec0::/10", IPPrivate},
+	{"ff00::/8", IPMulticast},
+}
+
+var (
+	specialNets     []*net.IPNet
+	specialNetsOnce sync.Once
+	sixToFour       = mustCIDR("2002::/16")
+)
+
+func mustCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// ClassifyIP returns the class of the special-purpose block ip falls in, or
+// "" for a globally reachable or unparsable address. IPv4-mapped and 6to4
+// addresses are classified by the IPv4 address they embed.
+func ClassifyIP(ip string) string {
+	addr := net.ParseIP(strings.TrimSpace(ip))
+	if addr == nil {
+		return ""
+	}
+	if v4 := addr.To4(); v4 != nil {
+		addr = v4
+	} else if sixToFour.Contains(addr) {
+		addr = net.IP(addr[2:6])
+	}
+	specialNetsOnce.Do(func() {
+		for _, r := range specialRanges {
+			specialNets = append(specialNets, mustCIDR(r.CIDR))
+		}
+	})
+	for i, n := range specialNets {
+		if n.Contains(addr) {
+			return specialRanges[i].Class
+		}
+	}
+	return ""
+}
+
+// InternalAddresses reports whether addrs is non-empty and every address in
+// it is special-purpose, returning the class of the first. A host with any
+// public address is reachable from outside and not internal.
+func InternalAddresses(addrs []string) (class string, internal bool) {
+	for _, a := range addrs {
+		c := ClassifyIP(a)
+		if c == "" {
+			return "", false
+		}
+		if class == "" {
+			class = c
+		}
+	}
+	return class, class != ""
+}
+
+// IsInternalHost reports whether s was tagged as resolving only to
+// special-purpose addresses.
+func IsInternalHost(s types.SubdomainResult) bool {
+	return HasTag(s.Tags, TagState+":internal")
+}
+
+// IsPlaceholder reports whether tags mark a parked domain or a web
+// server, hosting or CDN default page.
+func IsPlaceholder(tags map[string][]string) bool {
+	return HasTag(tags, TagState+":parked") || HasTag(tags, TagState+":default-page")
+}
+
+var (
+	includeInternal bool
+	includeParked   bool
+	includeMu       sync.Mutex
+)
+
+// IncludeInternal makes ProbeHost accept internal hosts, for engagements
+// run from inside the network (--include-private).
+func IncludeInternal(include bool) {
+	includeMu.Lock()
+	includeInternal = include
+	includeMu.Unlock()
+}
+
+// IncludeParked makes ProbeHost and ProbeOrigin accept parked domains and
+// default pages (--include-parked).
+func IncludeParked(include bool) {
+	includeMu.Lock()
+	includeParked = include
+	includeMu.Unlock()
+}
+
+// ProbeHost reports whether stages may send traffic to s: internal hosts
+// are left alone unless IncludeInternal was set, and parked or default
+// pages unless IncludeParked was.
+func ProbeHost(s types.SubdomainResult) bool {
+	includeMu.Lock()
+	defer includeMu.Unlock()
+	return (includeInternal || !IsInternalHost(s)) && (includeParked || !IsPlaceholder(s.Tags))
+}
+
+// ProbeOrigin reports whether aggressive stages may scan the web origin
+// lh: one serving a parked or default page is a dead end unless
+// IncludeParked was set.
+func ProbeOrigin(lh types.LiveHost) bool {
+	includeMu.Lock()
+	defer includeMu.Unlock()
+	return includeParked || !IsPlaceholder(lh.Tags)
+}
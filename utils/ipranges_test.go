@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"net"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestClassifyIP(t *testing.T) {
+	tests := []struct{ ip, want string }{
+		// IPv4, with the addresses just outside each block.
+		{"0.0.0.0", IPUnspecified},
+		{"0.255.255.255", IPUnspecified},
+		{"1.0.0.0", ""},
+		{"9.255.255.255", ""},
+		{"10.0.0.0", IPPrivate},
+		{"10.255.255.255", IPPrivate},
+		{"11.0.0.0", ""},
+		{"100.63.255.255", ""},
+		{"100.64.0.0", IPCGNAT},
+		{"100.127.255.255", IPCGNAT},
+		{"100.128.0.0", ""},
+		{"126.255.255.255", ""},
+		{"127.0.0.1", IPLoopback},
+		{"127.255.255.255", IPLoopback},
+		{"128.0.0.0", ""},
+		{"169.253.255.255", ""},
+		{"169.254.169.254", IPLinkLocal},
+		{"169.255.0.0", ""},
+		{"172.15.255.255", ""},
+		{"172.16.0.0", IPPrivate},
+		{"172.31.255.255", IPPrivate},
+		{"172.32.0.0", ""},
+		{"192.0.0.8", IPReserved},
+		{"192.0.1.0", ""},
+		{"192.0.2.1", IPDocumentation},
+		{"192.0.3.0", ""},
+		{"192.88.99.1", IPReserved},
+		{"192.167.255.255", ""},
+		{"192.168.1.1", IPPrivate},
+		{"192.169.0.0", ""},
+		{"198.17.255.255", ""},
+		{"198.18.0.0", IPBenchmarking},
+		{"198.19.255.255", IPBenchmarking},
+		{"198.20.0.0", ""},
+		{"198.51.100.7", IPDocumentation},
+		{"203.0.113.255", IPDocumentation},
+		{"223.255.255.255", ""},
+		{"224.0.0.1", IPMulticast},
+		{"239.255.255.255", IPMulticast},
+		{"240.0.0.1", IPReserved},
+		{"255.255.255.254", IPReserved},
+		{"255.255.255.255", IPBroadcast},
+		{"8.8.8.8", ""},
+		{" 10.1.2.3 ", IPPrivate},
+		// IPv6.
+		{"::", IPUnspecified},
+		{"::1", IPLoopback},
+		{"::2", ""},
+		{"64:ff9b:1::1", IPPrivate},
+		{"64:ff9b::808:808", ""},
+		{"100::1", IPReserved},
+		{"100:0:0:1::", ""},
+		{"2001:2::1", IPBenchmarking},
+		{"2001:10::1", IPReserved},
+		{"2001:db8::1", IPDocumentation},
+		{"2001:db9::1", ""},
+		{"3fff:fff::1", IPDocumentation},
+		{"3fff:1000::", ""},
+		{"5f00::1", IPReserved},
+		{"fc00::1", IPPrivate},
+		{"fdff:ffff::1", IPPrivate},
+		{"fe80::1", IPLinkLocal},
+		{"febf::1", IPLinkLocal},
+		{"fec0::1", IPPrivate},
+		{"ff02::1", IPMulticast},
+		{"2606:4700:4700::1111", ""},
+		// Embedded IPv4 addresses decide.
+		{"::ffff:10.0.0.1", IPPrivate},
+		{"::ffff:8.8.8.8", ""},
+		{"2002:0a00:0001::1", IPPrivate},
+		{"2002:c000:0201::1", IPDocumentation},
+		{"2002:0808:0808::1", ""},
+		// Not addresses.
+		{"", ""},
+		{"example.com", ""},
+		{"10.0.0.0/8", ""},
+		{"300.1.1.1", ""},
+	}
+	for _, tt := range tests {
+		if got := ClassifyIP(tt.ip); got != tt.want {
+			t.Errorf("ClassifyIP(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+// TestClassifyIPRangeEdges checks the first and last address of every
+// listed block, unless a block listed before it owns that address.
+func TestClassifyIPRangeEdges(t *testing.T) {
+	for i, r := range specialRanges {
+		n := mustCIDR(r.CIDR)
+		first := n.IP
+		last := make(net.IP, len(first))
+		for j := range first {
+			last[j] = first[j] | ^n.Mask[j]
+		}
+		for _, ip := range []net.IP{first, last} {
+			want := r.Class
+			for _, earlier := range specialRanges[:i] {
+				if mustCIDR(earlier.CIDR).Contains(ip) {
+					want = earlier.Class
+					break
+				}
+			}
+			if got := ClassifyIP(ip.String()); got != want {
+				t.Errorf("%s: ClassifyIP(%s) = %q, want %q", r.CIDR, ip, got, want)
+			}
+		}
+	}
+}
+
+func TestInternalAddresses(t *testing.T) {
+	tests := []struct {
+		addrs    []string
+		class    string
+		internal bool
+	}{
+		{nil, "", false},
+		{[]string{"10.0.0.5"}, IPPrivate, true},
+		{[]string{"127.0.0.1", "10.0.0.5"}, IPLoopback, true},
+		{[]string{"10.0.0.5", "8.8.8.8"}, "", false},
+		{[]string{"8.8.8.8", "10.0.0.5"}, "", false},
+		{[]string{"fd00::1", "100.64.1.1"}, IPPrivate, true},
+	}
+	for _, tt := range tests {
+		class, internal := InternalAddresses(tt.addrs)
+		if class != tt.class || internal != tt.internal {
+			t.Errorf("InternalAddresses(%q) = %q, %v; want %q, %v", tt.addrs, class, internal, tt.class, tt.internal)
+		}
+	}
+}
+
+func TestProbeHost(t *testing.T) {
+	defer IncludeInternal(false)
+	internal := types.SubdomainResult{Hostname: "intranet.example.com", Tags: map[string][]string{TagState: {"internal"}}}
+	public := types.SubdomainResult{Hostname: "www.example.com"}
+	if !IsInternalHost(internal) || IsInternalHost(public) {
+		t.Fatal("IsInternalHost does not follow the state:internal tag")
+	}
+	for _, include := range []bool{false, true} {
+		IncludeInternal(include)
+		if got := ProbeHost(internal); got != include {
+			t.Errorf("include %v: ProbeHost(internal) = %v", include, got)
+		}
+		if !ProbeHost(public) {
+			t.Errorf("include %v: public host not probed", include)
+		}
+	}
+}
+
+func TestProbeParked(t *testing.T) {
+	defer IncludeParked(false)
+	parked := types.SubdomainResult{Hostname: "old.example.com", Tags: map[string][]string{TagState: {"parked"}}}
+	origin := types.LiveHost{Hostname: "www.example.com", Tags: map[string][]string{TagState: {"default-page"}, TagEnv: {"production"}}}
+	shadow := types.LiveHost{Hostname: "app.example.com", Tags: map[string][]string{TagState: {"shadow"}}}
+	if !IsPlaceholder(parked.Tags) || !IsPlaceholder(origin.Tags) || IsPlaceholder(shadow.Tags) {
+		t.Fatal("IsPlaceholder does not follow the state:parked and state:default-page tags")
+	}
+	for _, include := range []bool{false, true} {
+		IncludeParked(include)
+		if got := ProbeHost(parked); got != include {
+			t.Errorf("include %v: ProbeHost(parked) = %v", include, got)
+		}
+		if got := ProbeOrigin(origin); got != include {
+			t.Errorf("include %v: ProbeOrigin(default page) = %v", include, got)
+		}
+		if !ProbeOrigin(shadow) {
+			t.Errorf("include %v: shadow origin not probed", include)
+		}
+	}
+}
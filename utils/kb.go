@@ -0,0 +1,415 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Stores a knowledge base holds, one JSON file each.
+const (
+	KBTriage = "triage.json"
+	KBRuns   = "runs.json"
+	// kbManifest names the target in an exported knowledge base.
+	kbManifest = "kb.json"
+)
+
+const (
+	lockRetry   = 50 * time.Millisecond
+	lockTimeout = 10 * time.Second
+	// lockStale is the age past which a lock file is taken to be left
+	// behind by a crashed run; stores are only locked while being saved.
+	lockStale = time.Minute
+	// kbMaxImportBytes bounds each file read from an imported archive.
+	kbMaxImportBytes = 64 << 20
+)
+
+// KnowledgeBase is the per-target directory that owns what is kept across
+// runs: triage dispositions and the index of past runs. Concurrent runs
+// against one target share it; every save goes through Update, which
+// serializes writers with a lock file.
+type KnowledgeBase struct {
+	Target string
+	Dir    string
+}
+
+// KBRun is one run recorded in the knowledge base.
+type KBRun struct {
+	Dir        string    `json:"dir"`
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished"`
+	Subdomains int       `json:"subdomains"`
+	URLs       int       `json:"urls"`
+	Findings   int       `json:"findings"`
+}
+
+// KBRoot returns the directory holding every target's knowledge base:
+// KB_DIR, or recon under $XDG_DATA_HOME, by default ~/.local/share/recon.
+func KBRoot() (string, error) {
+	if dir := strings.TrimSpace(os.Getenv("KB_DIR")); dir != "" {
+		return dir, nil
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "recon"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "recon"), nil
+}
+
+// OpenKnowledgeBase opens target's knowledge base, creating it on first use.
+// A triage store left in the user config directory by earlier releases is
+// moved into it.
+func OpenKnowledgeBase(target string) (*KnowledgeBase, error) {
+	name := NormalizeHostname(target)
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return nil, fmt.Errorf("invalid target %q", target)
+	}
+	root, err := KBRoot()
+	if err != nil {
+		return nil, err
+	}
+	kb := &KnowledgeBase{Target: name, Dir: filepath.Join(root, name)}
+	if err := os.MkdirAll(kb.Dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := kb.migrateTriage(); err != nil {
+		return nil, fmt.Errorf("migrating triage store: %v", err)
+	}
+	return kb, nil
+}
+
+// migrateTriage moves the pre-knowledge-base triage store into kb unless kb
+// already has one.
+func (kb *KnowledgeBase) migrateTriage() error {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil
+	}
+	legacy := filepath.Join(dir, "recon-tool", "triage", kb.Target+".json")
+	data, err := ioutil.ReadFile(legacy)
+	if err != nil {
+		return nil
+	}
+	moved := false
+	err = kb.Update(KBTriage, func(cur []byte) ([]byte, error) {
+		if cur != nil {
+			return cur, nil
+		}
+		moved = true
+		return data, nil
+	})
+	if err == nil && moved {
+		os.Remove(legacy)
+	}
+	return err
+}
+
+// Path returns the location of the named store.
+func (kb *KnowledgeBase) Path(store string) string {
+	return filepath.Join(kb.Dir, store)
+}
+
+// LockFile takes an exclusive lock on path by creating path.lock, waiting
+// up to lockTimeout for another holder. A lock older than lockStale is
+// broken. The returned function releases the lock.
+func LockFile(path string) (func(), error) {
+	lock := path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > lockStale {
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is locked by another run", path)
+		}
+		time.Sleep(lockRetry)
+	}
+}
+
+// Read returns the named store's contents, or nil when it does not exist.
+func (kb *KnowledgeBase) Read(store string) ([]byte, error) {
+	data, err := ioutil.ReadFile(kb.Path(store))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// Update replaces the named store with what fn returns for its current
+// contents (nil when missing), holding the store's lock throughout so
+// concurrent runs merge instead of overwriting each other.
+func (kb *KnowledgeBase) Update(store string, fn func(cur []byte) ([]byte, error)) error {
+	return UpdateFile(kb.Path(store), fn)
+}
+
+// UpdateFile is Update for a store at any path.
+func UpdateFile(path string, fn func(cur []byte) ([]byte, error)) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	unlock, err := LockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	cur, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		cur, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	data, err := fn(cur)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic writes data through a temporary file so an interrupted
+// save never leaves a truncated store behind.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Runs returns the recorded runs, oldest first.
+func (kb *KnowledgeBase) Runs() ([]KBRun, error) {
+	data, err := kb.Read(KBRuns)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var runs []KBRun
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, fmt.Errorf("%s: %v", kb.Path(KBRuns), err)
+	}
+	return runs, nil
+}
+
+// RecordRun adds run to the index, replacing an entry for the same
+// directory.
+func (kb *KnowledgeBase) RecordRun(run KBRun) error {
+	return kb.Update(KBRuns, func(cur []byte) ([]byte, error) {
+		runs, err := decodeRuns(cur)
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(mergeRuns(runs, []KBRun{run}), "", "  ")
+	})
+}
+
+func decodeRuns(data []byte) ([]KBRun, error) {
+	var runs []KBRun
+	if data == nil {
+		return runs, nil
+	}
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, fmt.Errorf("runs index: %v", err)
+	}
+	return runs, nil
+}
+
+// mergeRuns combines two run indexes keyed on directory, b winning, oldest
+// run first.
+func mergeRuns(a, b []KBRun) []KBRun {
+	byDir := make(map[string]KBRun)
+	for _, r := range append(a, b...) {
+		byDir[r.Dir] = r
+	}
+	runs := []KBRun{}
+	for _, r := range byDir {
+		runs = append(runs, r)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		if !runs[i].Started.Equal(runs[j].Started) {
+			return runs[i].Started.Before(runs[j].Started)
+		}
+		return runs[i].Dir < runs[j].Dir
+	})
+	return runs
+}
+
+// kbStores are the stores export and import carry, with how an imported
+// copy is merged into an existing one.
+var kbStores = map[string]func(cur, imported []byte) ([]byte, error){
+	KBTriage: func(cur, imported []byte) ([]byte, error) {
+		a, err := decodeTriageRecords(cur)
+		if err != nil {
+			return nil, err
+		}
+		b, err := decodeTriageRecords(imported)
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(mergeTriageRecords(a, b), "", "  ")
+	},
+	KBRuns: func(cur, imported []byte) ([]byte, error) {
+		a, err := decodeRuns(cur)
+		if err != nil {
+			return nil, err
+		}
+		b, err := decodeRuns(imported)
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(mergeRuns(a, b), "", "  ")
+	},
+}
+
+// Export writes the knowledge base's stores to dest as a .tar.gz, with a
+// manifest naming the target, and returns the stores written.
+func (kb *KnowledgeBase) Export(dest string) ([]string, error) {
+	manifest, _ := json.MarshalIndent(map[string]interface{}{
+		"target":   kb.Target,
+		"exported": time.Now().UTC(),
+	}, "", "  ")
+	tmpDir, err := ioutil.TempDir("", "recon-kb-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, kbManifest), manifest, 0644); err != nil {
+		return nil, err
+	}
+	names := []string{kbManifest}
+	var stores []string
+	for store := range kbStores {
+		stores = append(stores, store)
+	}
+	sort.Strings(stores)
+	for _, store := range stores {
+		// Copy under the lock so a run saving meanwhile is not caught half-way.
+		unlock, err := LockFile(kb.Path(store))
+		if err != nil {
+			return nil, err
+		}
+		data, err := kb.Read(store)
+		unlock()
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(tmpDir, store), data, 0644); err != nil {
+			return nil, err
+		}
+		names = append(names, store)
+	}
+	tmp := dest + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+	err = writeBundle(f, tmpDir, names, nil)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return names[1:], os.Rename(tmp, dest)
+}
+
+// ImportKnowledgeBase merges an archive written by Export into the
+// knowledge base of the target it names: dispositions recorded on both
+// sides keep the most recent, and the run indexes are combined. It returns
+// the knowledge base and the stores merged.
+func ImportKnowledgeBase(src string) (*KnowledgeBase, []string, error) {
+	files, err := readKBArchive(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	var manifest struct {
+		Target string `json:"target"`
+	}
+	if err := json.Unmarshal(files[kbManifest], &manifest); err != nil || manifest.Target == "" {
+		return nil, nil, fmt.Errorf("%s: not a knowledge base export", src)
+	}
+	kb, err := OpenKnowledgeBase(manifest.Target)
+	if err != nil {
+		return nil, nil, err
+	}
+	var merged []string
+	for store, merge := range kbStores {
+		imported, ok := files[store]
+		if !ok {
+			continue
+		}
+		err := kb.Update(store, func(cur []byte) ([]byte, error) {
+			return merge(cur, imported)
+		})
+		if err != nil {
+			return kb, merged, fmt.Errorf("%s: %v", store, err)
+		}
+		merged = append(merged, store)
+	}
+	sort.Strings(merged)
+	return kb, merged, nil
+}
+
+// readKBArchive returns the manifest and known stores of an exported
+// archive by name; anything else in it is ignored.
+func readKBArchive(src string) (map[string][]byte, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", src, err)
+	}
+	tr := tar.NewReader(gz)
+	files := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", src, err)
+		}
+		if _, known := kbStores[hdr.Name]; !known && hdr.Name != kbManifest {
+			continue
+		}
+		if hdr.Size > kbMaxImportBytes {
+			return nil, fmt.Errorf("%s: %s is too large", src, hdr.Name)
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(tr, kbMaxImportBytes))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", src, err)
+		}
+		files[hdr.Name] = data
+	}
+	if _, ok := files[kbManifest]; !ok {
+		return nil, errors.New(src + ": not a knowledge base export")
+	}
+	return files, nil
+}
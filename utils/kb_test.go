@@ -0,0 +1,290 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// kbFixture points the knowledge base root and the user config directory
+// at fresh temporary directories and returns the root.
+func kbFixture(t *testing.T) string {
+	root := t.TempDir()
+	t.Setenv("KB_DIR", root)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	return root
+}
+
+func TestOpenKnowledgeBase(t *testing.T) {
+	root := kbFixture(t)
+	kb, err := OpenKnowledgeBase("WWW.Example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kb.Target != "www.example.com" || kb.Dir != filepath.Join(root, "www.example.com") {
+		t.Errorf("OpenKnowledgeBase = %+v", kb)
+	}
+	// A URL names its host; the path never reaches the file system.
+	if kb, err := OpenKnowledgeBase("https://example.com/../../etc"); err != nil || kb.Dir != filepath.Join(root, "example.com") {
+		t.Errorf("OpenKnowledgeBase of a URL = %+v, %v", kb, err)
+	}
+	for _, target := range []string{"", "..", `a\b`} {
+		if _, err := OpenKnowledgeBase(target); err == nil {
+			t.Errorf("OpenKnowledgeBase(%q): no error", target)
+		}
+	}
+
+	// A triage store from before the knowledge base is moved into it.
+	legacy := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "recon-tool", "triage", "legacy.example.com.json")
+	if err := os.MkdirAll(filepath.Dir(legacy), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(legacy, []byte(`[{"fingerprint":"abc","disposition":"confirmed"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	kb, err = OpenKnowledgeBase("legacy.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := kb.Read(KBTriage); string(data) != `[{"fingerprint":"abc","disposition":"confirmed"}]` {
+		t.Errorf("migrated triage store: %q", data)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("legacy store left behind: %v", err)
+	}
+}
+
+// TestKnowledgeBaseConcurrentWriters has many runs record themselves and
+// their dispositions at once; every write must survive.
+func TestKnowledgeBaseConcurrentWriters(t *testing.T) {
+	kbFixture(t)
+	kb, err := OpenKnowledgeBase("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const writers = 16
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- kb.RecordRun(KBRun{Dir: fmt.Sprintf("run-%02d", i), Started: start.Add(time.Duration(i) * time.Hour), Findings: i})
+			// Each run keeps its own copy of the store, as separate processes would.
+			store, err := LoadTriageStore(kb.Path(KBTriage))
+			if err != nil {
+				errs <- err
+				return
+			}
+			v := types.VulnerabilityResult{URL: fmt.Sprintf("https://host%d.example.com/", i), Issue: "Open Redirect"}
+			errs <- store.Record(v, DispositionConfirmed, "")
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	runs, err := kb.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != writers {
+		t.Fatalf("%d run(s) recorded, want %d", len(runs), writers)
+	}
+	for i, r := range runs {
+		if r.Dir != fmt.Sprintf("run-%02d", i) || r.Findings != i {
+			t.Errorf("runs[%d] = %+v", i, r)
+		}
+	}
+	store, err := LoadTriageStore(kb.Path(KBTriage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := store.Counts()[DispositionConfirmed]; n != writers {
+		t.Errorf("%d disposition(s) saved, want %d", n, writers)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(kb.Dir, "*.*.*"))
+	if len(leftovers) > 0 {
+		t.Errorf("lock or temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runs.json")
+	unlock, err := LockFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- UpdateFile(path, func([]byte) ([]byte, error) { return []byte("[]"), nil })
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("update went ahead of the lock holder: %v", err)
+	case <-time.After(4 * lockRetry):
+	}
+	unlock()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// A lock left behind by a crashed run is broken.
+	if err := ioutil.WriteFile(path+".lock", []byte("1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * lockStale)
+	if err := os.Chtimes(path+".lock", old, old); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err = LockFile(path)
+	if err != nil {
+		t.Fatalf("stale lock: %v", err)
+	}
+	unlock()
+}
+
+// TestKnowledgeBaseExportImport moves a knowledge base to another machine
+// that already holds some of the same target's state.
+func TestKnowledgeBaseExportImport(t *testing.T) {
+	kbFixture(t)
+	src, err := OpenKnowledgeBase("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	older := TriageRecord{Fingerprint: "shared", Disposition: DispositionNeedsRetest, Updated: start}
+	newer := TriageRecord{Fingerprint: "shared", Disposition: DispositionConfirmed, Note: "retested", Updated: start.Add(time.Hour)}
+	onlySrc := TriageRecord{Fingerprint: "exported", Disposition: DispositionFalsePositive, Updated: start}
+	onlyDest := TriageRecord{Fingerprint: "local", Disposition: DispositionDuplicate, Updated: start}
+	write := func(kb *KnowledgeBase, store string, v interface{}) {
+		data, _ := json.Marshal(v)
+		if err := kb.Update(store, func([]byte) ([]byte, error) { return data, nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(src, KBTriage, []TriageRecord{newer, onlySrc})
+	write(src, KBRuns, []KBRun{{Dir: "runs/a", Started: start, Findings: 3}, {Dir: "runs/b", Started: start.Add(2 * time.Hour)}})
+
+	archive := filepath.Join(t.TempDir(), "example.com.tar.gz")
+	stores, err := src.Export(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(stores, []string{KBRuns, KBTriage}) {
+		t.Errorf("Export wrote %v", stores)
+	}
+
+	// The other machine.
+	destRoot := kbFixture(t)
+	dest, err := OpenKnowledgeBase("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(dest, KBTriage, []TriageRecord{older, onlyDest})
+	write(dest, KBRuns, []KBRun{{Dir: "runs/a", Started: start, Findings: 1}, {Dir: "runs/c", Started: start.Add(time.Hour)}})
+
+	for round := 1; round <= 2; round++ {
+		kb, merged, err := ImportKnowledgeBase(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kb.Dir != filepath.Join(destRoot, "example.com") || !reflect.DeepEqual(merged, []string{KBRuns, KBTriage}) {
+			t.Errorf("import %d: %s, merged %v", round, kb.Dir, merged)
+		}
+		runs, err := kb.Runs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dirs []string
+		for _, r := range runs {
+			dirs = append(dirs, r.Dir)
+		}
+		if !reflect.DeepEqual(dirs, []string{"runs/a", "runs/c", "runs/b"}) || runs[0].Findings != 3 {
+			t.Errorf("import %d: runs %+v", round, runs)
+		}
+		data, _ := kb.Read(KBTriage)
+		records, err := decodeTriageRecords(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byFP := make(map[string]TriageRecord)
+		for _, r := range records {
+			byFP[r.Fingerprint] = r
+		}
+		if len(byFP) != 3 || byFP["shared"].Disposition != DispositionConfirmed || byFP["shared"].Note != "retested" ||
+			byFP["exported"].Disposition != DispositionFalsePositive || byFP["local"].Disposition != DispositionDuplicate {
+			t.Errorf("import %d: triage %+v", round, records)
+		}
+	}
+}
+
+func TestImportKnowledgeBaseRejects(t *testing.T) {
+	kbFixture(t)
+	dir := t.TempDir()
+	archive := func(name string, files map[string]string) string {
+		path := filepath.Join(dir, name)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		gz := gzip.NewWriter(f)
+		tw := tar.NewWriter(gz)
+		for name, body := range files {
+			tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(body))})
+			tw.Write([]byte(body))
+		}
+		tw.Close()
+		gz.Close()
+		return path
+	}
+	notGzip := filepath.Join(dir, "plain.tar.gz")
+	if err := ioutil.WriteFile(notGzip, []byte("not an archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"missing file", filepath.Join(dir, "missing.tar.gz")},
+		{"not gzip", notGzip},
+		{"no manifest", archive("nomanifest.tar.gz", map[string]string{KBRuns: "[]"})},
+		{"manifest without target", archive("notarget.tar.gz", map[string]string{kbManifest: `{}`})},
+		{"target escaping the root", archive("escape.tar.gz", map[string]string{kbManifest: `{"target":".."}`})},
+		{"corrupt store", archive("corrupt.tar.gz", map[string]string{kbManifest: `{"target":"example.com"}`, KBRuns: `[{"dir":`})},
+	}
+	for _, tt := range tests {
+		if _, _, err := ImportKnowledgeBase(tt.path); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+
+	// Entries other than the manifest and known stores are ignored.
+	path := archive("extra.tar.gz", map[string]string{kbManifest: `{"target":"example.com"}`, "../../evil": "x", KBRuns: `[{"dir":"runs/a"}]`})
+	kb, merged, err := ImportKnowledgeBase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(merged, []string{KBRuns}) {
+		t.Errorf("merged %v", merged)
+	}
+	if _, err := os.Stat(filepath.Join(kb.Dir, "..", "..", "evil")); !os.IsNotExist(err) {
+		t.Errorf("unknown entry written: %v", err)
+	}
+}
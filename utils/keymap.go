@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KeyBinding is one entry of the TUI keymap. Keys are single characters or
+// the names of special keys ("Esc", "Enter").
+type KeyBinding struct {
+	ID      string
+	Context string
+	Keys    []string
+	Help    string
+	action  func()
+}
+
+// Keymap is the single source of the TUI's keybindings: input handlers
+// dispatch through it and the help overlay is generated from it.
+type Keymap struct {
+	bindings []*KeyBinding
+	contexts []string
+}
+
+// NewKeymap returns an empty keymap.
+func NewKeymap() *Keymap {
+	return &Keymap{}
+}
+
+// Bind registers action under id for keys in context.
+func (k *Keymap) Bind(id, context, help string, keys []string, action func()) {
+	if !containsString(k.contexts, context) {
+		k.contexts = append(k.contexts, context)
+	}
+	k.bindings = append(k.bindings, &KeyBinding{ID: id, Context: context, Keys: keys, Help: help, action: action})
+}
+
+// Dispatch runs the first binding for key in the given contexts, searched
+// in order, and reports whether one matched.
+func (k *Keymap) Dispatch(contexts []string, key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, ctx := range contexts {
+		for _, b := range k.bindings {
+			if b.Context == ctx && containsString(b.Keys, key) {
+				b.action()
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Remap applies user overrides of the form "id=key[/key...],..." (the
+// KEYMAP setting). A key already bound to another action in the same
+// context is rejected so remapping cannot shadow a binding.
+func (k *Keymap) Remap(spec string) error {
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return fmt.Errorf("keymap entry %q: want id=key", entry)
+		}
+		target := k.binding(strings.TrimSpace(parts[0]))
+		if target == nil {
+			return fmt.Errorf("keymap entry %q: unknown binding", entry)
+		}
+		keys := strings.Split(parts[1], "/")
+		for i, key := range keys {
+			if keys[i] = strings.TrimSpace(key); keys[i] == "" {
+				return fmt.Errorf("keymap entry %q: empty key", entry)
+			}
+		}
+		for _, b := range k.bindings {
+			if b == target || b.Context != target.Context {
+				continue
+			}
+			for _, key := range keys {
+				if containsString(b.Keys, key) {
+					return fmt.Errorf("keymap entry %q: %s is already bound to %s", entry, key, b.ID)
+				}
+			}
+		}
+		target.Keys = keys
+	}
+	return nil
+}
+
+// binding returns the binding registered under id.
+func (k *Keymap) binding(id string) *KeyBinding {
+	for _, b := range k.bindings {
+		if b.ID == id {
+			return b
+		}
+	}
+	return nil
+}
+
+// Keys returns the keys currently bound to id, joined for display.
+func (k *Keymap) Keys(id string) string {
+	if b := k.binding(id); b != nil {
+		return strings.Join(b.Keys, "/")
+	}
+	return ""
+}
+
+// HelpText lists every binding with its keys, description and id (for
+// KEYMAP overrides), grouped by context in registration order.
+func (k *Keymap) HelpText() string {
+	var b strings.Builder
+	for i, ctx := range k.contexts {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(ctx + "\n")
+		for _, kb := range k.bindings {
+			if kb.Context == ctx {
+				b.WriteString(fmt.Sprintf("  %-10s %-38s %s\n", strings.Join(kb.Keys, "/"), kb.Help, kb.ID))
+			}
+		}
+	}
+	return b.String()
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
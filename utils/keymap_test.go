@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+// testKeymap binds a few actions the way the TUI does and records which
+// ran.
+func testKeymap(ran *[]string) *Keymap {
+	k := NewKeymap()
+	bind := func(id, context string, keys ...string) {
+		k.Bind(id, context, "Help for "+id, keys, func() { *ran = append(*ran, id) })
+	}
+	bind("tab.vulns", "Global", "2")
+	bind("proxy.toggle", "Global", "p", "P")
+	bind("help.show", "Global", "?")
+	bind("list.sort", "Lists", "s")
+	bind("list.down", "Lists", "Down")
+	bind("triage.start", "Vulnerabilities", "t", "T")
+	bind("triage.confirm", "Triage", "c")
+	bind("triage.leave", "Triage", "Esc")
+	bind("help.close", "Help", "?", "Esc")
+	return k
+}
+
+func TestKeymapDispatch(t *testing.T) {
+	var ran []string
+	k := testKeymap(&ran)
+	tests := []struct {
+		contexts []string
+		key      string
+		want     string
+	}{
+		{[]string{"Global"}, "2", "tab.vulns"},
+		{[]string{"Global"}, "P", "proxy.toggle"},
+		{[]string{"Lists", "Global"}, "Down", "list.down"},
+		{[]string{"Vulnerabilities", "Lists", "Global"}, "t", "triage.start"},
+		// The same key means different things in different contexts.
+		{[]string{"Global"}, "?", "help.show"},
+		{[]string{"Help"}, "?", "help.close"},
+		{[]string{"Triage"}, "Esc", "triage.leave"},
+		// Keys of contexts not searched do nothing.
+		{[]string{"Global"}, "t", ""},
+		{[]string{"Triage"}, "2", ""},
+		{[]string{"Lists", "Global"}, "x", ""},
+		{[]string{"Global"}, "", ""},
+		{nil, "2", ""},
+	}
+	for _, tt := range tests {
+		ran = nil
+		matched := k.Dispatch(tt.contexts, tt.key)
+		if matched != (tt.want != "") || strings.Join(ran, ",") != tt.want {
+			t.Errorf("Dispatch(%v, %q) = %v, ran %v, want %q", tt.contexts, tt.key, matched, ran, tt.want)
+		}
+	}
+
+	// The first context searched wins when two bind a key.
+	k.Bind("list.filter", "Lists", "Filter", []string{"p"}, func() { ran = append(ran, "list.filter") })
+	ran = nil
+	if !k.Dispatch([]string{"Lists", "Global"}, "p") || strings.Join(ran, ",") != "list.filter" {
+		t.Errorf("Lists before Global ran %v", ran)
+	}
+}
+
+func TestKeymapRemap(t *testing.T) {
+	var ran []string
+	k := testKeymap(&ran)
+	if err := k.Remap(" triage.confirm = y/Y , list.sort=o,"); err != nil {
+		t.Fatal(err)
+	}
+	if k.Keys("triage.confirm") != "y/Y" || k.Keys("list.sort") != "o" {
+		t.Errorf("remapped keys %q, %q", k.Keys("triage.confirm"), k.Keys("list.sort"))
+	}
+	ran = nil
+	k.Dispatch([]string{"Triage"}, "c")
+	k.Dispatch([]string{"Triage"}, "Y")
+	k.Dispatch([]string{"Lists"}, "o")
+	if strings.Join(ran, ",") != "triage.confirm,list.sort" {
+		t.Errorf("after remapping ran %v", ran)
+	}
+	// A key free in the binding's own context may be used elsewhere.
+	if err := k.Remap("triage.confirm=2"); err != nil {
+		t.Errorf("remapping onto a key of another context: %v", err)
+	}
+
+	for spec, want := range map[string]string{
+		"triage.confirm=Esc":  "already bound to triage.leave",
+		"help.show=p":         "already bound to proxy.toggle",
+		"nosuch.action=x":     "unknown binding",
+		"triage.confirm":      "want id=key",
+		"triage.confirm=":     "want id=key",
+		"list.sort=q/":        "empty key",
+		"list.sort=q,bogus=z": "unknown binding",
+	} {
+		if err := k.Remap(spec); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Remap(%q) = %v, want %q", spec, err, want)
+		}
+	}
+	if k.Keys("help.show") != "?" || k.Keys("nosuch.action") != "" {
+		t.Errorf("rejected remaps changed keys: %q", k.Keys("help.show"))
+	}
+}
+
+func TestKeymapHelpText(t *testing.T) {
+	var ran []string
+	k := testKeymap(&ran)
+	help := k.HelpText()
+	var contexts []string
+	for _, line := range strings.Split(help, "\n") {
+		if line != "" && !strings.HasPrefix(line, " ") {
+			contexts = append(contexts, line)
+		}
+	}
+	if strings.Join(contexts, ",") != "Global,Lists,Vulnerabilities,Triage,Help" {
+		t.Errorf("contexts in help: %v", contexts)
+	}
+	if !strings.Contains(help, "  p/P        Help for proxy.toggle") || !strings.HasSuffix(strings.TrimSpace(strings.Split(help, "\n")[2]), "proxy.toggle") {
+		t.Errorf("help text:\n%s", help)
+	}
+	// The overlay follows remapping.
+	if err := k.Remap("proxy.toggle=x"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(k.HelpText(), "  x          Help for proxy.toggle") {
+		t.Errorf("help after remapping:\n%s", k.HelpText())
+	}
+}
@@ -0,0 +1,416 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultToolNice     = 10
+	defaultToolOutputMB = 256
+)
+
+// ErrOutputCap is returned for a tool killed for writing more output than
+// its cap allows.
+var ErrOutputCap = errors.New("output cap exceeded")
+
+// ErrMemoryLimit is returned for a tool the kernel killed at its memory
+// ceiling.
+var ErrMemoryLimit = errors.New("memory limit exceeded")
+
+// ToolLimits caps the resources one external tool may use. Zero values
+// leave the resource unlimited.
+type ToolLimits struct {
+	// Nice is added to the tool's scheduling niceness.
+	Nice int
+	// IOClass is the ionice scheduling class: "best-effort" (at the lowest
+	// priority), "idle" or "" for none.
+	IOClass  string
+	MemoryMB int
+	OutputMB int
+}
+
+// limitKey turns a tool name into the suffix of its per-tool settings:
+// testssl.sh becomes TESTSSL_SH.
+func limitKey(tool string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, filepath.Base(tool))
+}
+
+// limitSetting reads <env>_<TOOL>, falling back to env.
+func limitSetting(env, tool string) (string, bool) {
+	if tool != "" {
+		if v := strings.TrimSpace(os.Getenv(env + "_" + limitKey(tool))); v != "" {
+			return v, true
+		}
+	}
+	v := strings.TrimSpace(os.Getenv(env))
+	return v, v != ""
+}
+
+// limitInt is limitSetting for non-negative numbers; 0 disables the limit.
+func limitInt(env, tool string, def int) int {
+	if v, ok := limitSetting(env, tool); ok {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return def
+}
+
+// ToolLimitsFor reads the limits for tool from TOOL_NICE, TOOL_IONICE,
+// TOOL_MEMORY_MB and TOOL_OUTPUT_MB, each overridable per tool by
+// appending its name (TOOL_MEMORY_MB_SQLMAP). Tools are niced by 10 and
+// get the lowest best-effort I/O priority. Their output is capped at 256MB.
+// Memory is capped at half the machine's RAM where cgroups v2 can enforce it;
+// the ulimit fallback limits address space rather than resident memory,
+// which Go and Java tools reserve far more of than they use, so it only
+// applies to an explicitly configured ceiling. An empty tool gives the
+// limits of tools without settings of their own.
+func ToolLimitsFor(tool string) ToolLimits {
+	caps := DetectLimitCapabilities()
+	l := ToolLimits{
+		Nice:     limitInt("TOOL_NICE", tool, defaultToolNice),
+		IOClass:  "best-effort",
+		OutputMB: limitInt("TOOL_OUTPUT_MB", tool, defaultToolOutputMB),
+	}
+	if v, ok := limitSetting("TOOL_IONICE", tool); ok {
+		switch v = strings.ToLower(v); v {
+		case "best-effort", "idle":
+			l.IOClass = v
+		default:
+			l.IOClass = ""
+		}
+	}
+	def := 0
+	if caps.Cgroup != nil {
+		def = caps.MemoryMB / 2
+	}
+	l.MemoryMB = limitInt("TOOL_MEMORY_MB", tool, def)
+	return l
+}
+
+// LimitCapabilities is what this machine can enforce.
+type LimitCapabilities struct {
+	Nice   bool
+	IONice bool
+	// Cgroup is set when tools can be placed in cgroups v2 with a memory
+	// controller; Ulimit when the shell fallback is available instead.
+	Cgroup *CgroupV2
+	Ulimit bool
+	// MemoryMB is the machine's RAM, 0 when unknown.
+	MemoryMB int
+	// CgroupErr says why cgroups v2 is not used.
+	CgroupErr error
+}
+
+var (
+	limitCapsOnce sync.Once
+	limitCaps     LimitCapabilities
+)
+
+// DetectLimitCapabilities probes the machine once. nice and ionice are
+// used when found in PATH. Memory ceilings need Linux: cgroups v2 when the
+// recon process's cgroup has (or can enable) the memory controller for its
+// children, otherwise ulimit -v through sh. Elsewhere memory limits are
+// not enforced, and on Windows neither is niceness.
+func DetectLimitCapabilities() LimitCapabilities {
+	limitCapsOnce.Do(func() {
+		limitCaps = detectLimitCapabilities(runtime.GOOS, exec.LookPath, osCgroupFS{})
+	})
+	return limitCaps
+}
+
+func detectLimitCapabilities(goos string, lookPath func(string) (string, error), fs CgroupFS) LimitCapabilities {
+	var c LimitCapabilities
+	_, err := lookPath("nice")
+	c.Nice = err == nil && goos != "windows"
+	if goos != "linux" {
+		c.CgroupErr = fmt.Errorf("cgroups need Linux, not %s", goos)
+		return c
+	}
+	_, err = lookPath("ionice")
+	c.IONice = err == nil
+	c.MemoryMB = physicalMemoryMB(fs)
+	c.Cgroup, c.CgroupErr = DetectCgroupV2(fs)
+	if c.Cgroup == nil {
+		_, err = lookPath("sh")
+		c.Ulimit = err == nil
+	}
+	return c
+}
+
+// physicalMemoryMB reads MemTotal from /proc/meminfo.
+func physicalMemoryMB(fs CgroupFS) int {
+	data, err := fs.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		f := strings.Fields(line)
+		if len(f) >= 2 && f[0] == "MemTotal:" {
+			kb, _ := strconv.Atoi(f[1])
+			return kb / 1024
+		}
+	}
+	return 0
+}
+
+// Describe summarizes the limits and how each is enforced, for the log.
+func (l ToolLimits) Describe(caps LimitCapabilities) string {
+	var parts []string
+	if l.Nice > 0 && caps.Nice {
+		parts = append(parts, fmt.Sprintf("nice +%d", l.Nice))
+	}
+	if l.IOClass != "" && caps.IONice {
+		parts = append(parts, "ionice "+l.IOClass)
+	}
+	switch {
+	case l.MemoryMB == 0:
+	case caps.Cgroup != nil:
+		parts = append(parts, fmt.Sprintf("memory %dMB (cgroup v2)", l.MemoryMB))
+	case caps.Ulimit:
+		parts = append(parts, fmt.Sprintf("memory %dMB (ulimit -v)", l.MemoryMB))
+	default:
+		parts = append(parts, fmt.Sprintf("memory %dMB not enforced: %v", l.MemoryMB, caps.CgroupErr))
+	}
+	if l.OutputMB > 0 {
+		parts = append(parts, fmt.Sprintf("output %dMB", l.OutputMB))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// wrap returns the command line that runs name with args under the limits
+// the wrappers enforce: nice, ionice and, without cgroups, ulimit -v. Each
+// wrapper execs the next, so the tool keeps the process ID that is waited
+// on and killed.
+func (l ToolLimits) wrap(caps LimitCapabilities, name string, args []string) (string, []string) {
+	var argv []string
+	if l.Nice > 0 && caps.Nice {
+		argv = append(argv, "nice", "-n", strconv.Itoa(l.Nice))
+	}
+	switch {
+	case l.IOClass == "" || !caps.IONice:
+	case l.IOClass == "idle":
+		argv = append(argv, "ionice", "-c", "3")
+	default:
+		argv = append(argv, "ionice", "-c", "2", "-n", "7")
+	}
+	if l.MemoryMB > 0 && caps.Cgroup == nil && caps.Ulimit {
+		// $0 and $@ keep the tool's arguments away from the shell.
+		argv = append(argv, "sh", "-c", fmt.Sprintf(`ulimit -v %d && exec "$0" "$@"`, l.MemoryMB*1024))
+	}
+	if len(argv) == 0 {
+		return name, args
+	}
+	return argv[0], append(append(argv[1:], name), args...)
+}
+
+// cappedOutput collects combined output up to max bytes (0 for no cap) and
+// calls exceeded once when more arrives. exec.Cmd never calls Write from
+// two goroutines at once when Stdout and Stderr are the same writer.
+type cappedOutput struct {
+	buf      []byte
+	max      int
+	over     bool
+	exceeded func()
+}
+
+func (c *cappedOutput) Write(p []byte) (int, error) {
+	if c.over {
+		return len(p), nil
+	}
+	if c.max > 0 && len(c.buf)+len(p) > c.max {
+		c.buf = append(c.buf, p[:c.max-len(c.buf)]...)
+		c.over = true
+		c.exceeded()
+		return len(p), nil
+	}
+	c.buf = append(c.buf, p...)
+	return len(p), nil
+}
+
+// runLimited runs cmd, built by runCommand with the wrappers already in
+// place, inside a memory cgroup when one applies and with its output capped.
+// A tool killed at a limit returns ErrOutputCap or ErrMemoryLimit, and the
+// breach is recorded for the stage.
+func runLimited(cmd *exec.Cmd, name string, l ToolLimits, caps LimitCapabilities) ([]byte, error) {
+	out := &cappedOutput{max: l.OutputMB << 20}
+	out.exceeded = func() {
+		cmd.Process.Kill()
+	}
+	cmd.Stdout, cmd.Stderr = out, out
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	var leave func() bool
+	if l.MemoryMB > 0 && caps.Cgroup != nil {
+		var err error
+		if leave, err = caps.Cgroup.Enter(cmd.Process.Pid, l.MemoryMB); err != nil {
+			RecordLimitBreach(name, "memory limit not applied: "+err.Error())
+		}
+	}
+	err := cmd.Wait()
+	if leave != nil && leave() {
+		RecordLimitBreach(name, fmt.Sprintf("%v at %dMB", ErrMemoryLimit, l.MemoryMB))
+		err = fmt.Errorf("%s: %w (%dMB)", name, ErrMemoryLimit, l.MemoryMB)
+	}
+	if out.over {
+		RecordLimitBreach(name, fmt.Sprintf("%v at %dMB, tool killed", ErrOutputCap, l.OutputMB))
+		err = fmt.Errorf("%s: %w (%dMB)", name, ErrOutputCap, l.OutputMB)
+	}
+	return out.buf, err
+}
+
+var (
+	breachMu sync.Mutex
+	breaches []string
+)
+
+// RecordLimitBreach notes that tool hit one of its resource limits.
+func RecordLimitBreach(tool, msg string) {
+	breachMu.Lock()
+	breaches = append(breaches, tool+": "+msg)
+	breachMu.Unlock()
+}
+
+// TakeLimitBreaches returns the limit breaches since the last call and
+// resets them, so each stage reports its own.
+func TakeLimitBreaches() []string {
+	breachMu.Lock()
+	defer breachMu.Unlock()
+	msgs := UniqueStrings(breaches)
+	breaches = nil
+	sort.Strings(msgs)
+	return msgs
+}
+
+// CgroupFS is the part of the filesystem cgroups v2 is driven through.
+type CgroupFS interface {
+	ReadFile(path string) ([]byte, error)
+	WriteFile(path string, data []byte) error
+	Mkdir(path string) error
+	Remove(path string) error
+}
+
+type osCgroupFS struct{}
+
+func (osCgroupFS) ReadFile(path string) ([]byte, error) { return ioutil.ReadFile(path) }
+func (osCgroupFS) WriteFile(path string, data []byte) error {
+	return ioutil.WriteFile(path, data, 0644)
+}
+func (osCgroupFS) Mkdir(path string) error  { return os.Mkdir(path, 0755) }
+func (osCgroupFS) Remove(path string) error { return os.Remove(path) }
+
+// cgroupRoot is where the cgroup v2 unified hierarchy is mounted.
+const cgroupRoot = "/sys/fs/cgroup"
+
+// CgroupV2 creates a child cgroup with a memory ceiling per tool invocation
+// under the recon process's own cgroup.
+type CgroupV2 struct {
+	fs   CgroupFS
+	Base string
+
+	mu sync.Mutex
+	n  int
+}
+
+// DetectCgroupV2 finds the process's cgroup on the unified hierarchy and
+// makes sure the memory controller is enabled for its children. That
+// needs a delegated cgroup: root in a container, or a systemd unit with
+// Delegate=yes. A cgroup that holds processes of its own cannot enable
+// controllers for children unless it is the root.
+func DetectCgroupV2(fs CgroupFS) (*CgroupV2, error) {
+	data, err := fs.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return nil, err
+	}
+	path := ""
+	found := false
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "0::") {
+			path, found = strings.TrimSpace(strings.TrimPrefix(line, "0::")), true
+		}
+	}
+	if !found {
+		return nil, errors.New("not on the cgroup v2 unified hierarchy")
+	}
+	base := filepath.Join(cgroupRoot, path)
+	controllers, err := fs.ReadFile(filepath.Join(base, "cgroup.subtree_control"))
+	if err != nil {
+		return nil, err
+	}
+	if !containsField(string(controllers), "memory") {
+		if err := fs.WriteFile(filepath.Join(base, "cgroup.subtree_control"), []byte("+memory")); err != nil {
+			return nil, fmt.Errorf("cannot enable the memory controller in %s: %w", base, err)
+		}
+	}
+	probe := filepath.Join(base, fmt.Sprintf("recon-%d-probe", os.Getpid()))
+	if err := fs.Mkdir(probe); err != nil {
+		return nil, fmt.Errorf("cannot create cgroups under %s: %w", base, err)
+	}
+	fs.Remove(probe)
+	return &CgroupV2{fs: fs, Base: base}, nil
+}
+
+func containsField(s, field string) bool {
+	for _, f := range strings.Fields(s) {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Enter moves pid into a new cgroup capped at memoryMB, without swap.
+// leave removes the cgroup once the process has exited and reports
+// whether the kernel killed anything in it for running out of memory.
+func (c *CgroupV2) Enter(pid, memoryMB int) (leave func() bool, err error) {
+	c.mu.Lock()
+	c.n++
+	dir := filepath.Join(c.Base, fmt.Sprintf("recon-%d-%d", os.Getpid(), c.n))
+	c.mu.Unlock()
+	if err := c.fs.Mkdir(dir); err != nil {
+		return nil, err
+	}
+	leave = func() bool {
+		events, _ := c.fs.ReadFile(filepath.Join(dir, "memory.events"))
+		c.fs.Remove(dir)
+		for _, line := range strings.Split(string(events), "\n") {
+			if f := strings.Fields(line); len(f) == 2 && f[0] == "oom_kill" && f[1] != "0" {
+				return true
+			}
+		}
+		return false
+	}
+	if err := c.fs.WriteFile(filepath.Join(dir, "memory.max"), []byte(strconv.Itoa(memoryMB<<20))); err != nil {
+		leave()
+		return nil, err
+	}
+	// Swap is not always accounted; the ceiling holds without it.
+	_ = c.fs.WriteFile(filepath.Join(dir, "memory.swap.max"), []byte("0"))
+	if err := c.fs.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(strconv.Itoa(pid))); err != nil {
+		leave()
+		return nil, err
+	}
+	return leave, nil
+}
@@ -0,0 +1,290 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeCgroupFS is an in-memory CgroupFS. Writes to paths in deny fail.
+type fakeCgroupFS struct {
+	files map[string]string
+	dirs  map[string]bool
+	deny  map[string]bool
+}
+
+func newFakeCgroupFS(files map[string]string) *fakeCgroupFS {
+	return &fakeCgroupFS{files: files, dirs: make(map[string]bool), deny: make(map[string]bool)}
+}
+
+func (f *fakeCgroupFS) ReadFile(path string) ([]byte, error) {
+	data, ok := f.files[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return []byte(data), nil
+}
+
+func (f *fakeCgroupFS) WriteFile(path string, data []byte) error {
+	if f.deny[path] {
+		return os.ErrPermission
+	}
+	f.files[path] = string(data)
+	return nil
+}
+
+func (f *fakeCgroupFS) Mkdir(path string) error {
+	if f.deny[path] {
+		return os.ErrPermission
+	}
+	f.dirs[path] = true
+	return nil
+}
+
+func (f *fakeCgroupFS) Remove(path string) error {
+	delete(f.dirs, path)
+	return nil
+}
+
+func TestDetectLimitCapabilities(t *testing.T) {
+	const meminfo = "MemTotal:       16384000 kB\nMemFree:         1024000 kB\n"
+	const base = "/sys/fs/cgroup/system.slice/recon.service"
+	all := func(string) (string, error) { return "/usr/bin/x", nil }
+	without := func(missing string) func(string) (string, error) {
+		return func(name string) (string, error) {
+			if name == missing {
+				return "", exec.ErrNotFound
+			}
+			return "/usr/bin/" + name, nil
+		}
+	}
+	v2 := func(subtree string) *fakeCgroupFS {
+		return newFakeCgroupFS(map[string]string{
+			"/proc/meminfo":                         meminfo,
+			"/proc/self/cgroup":                     "0::/system.slice/recon.service\n",
+			base + "/cgroup.subtree_control":        subtree,
+			"/sys/fs/cgroup/cgroup.subtree_control": "cpu memory pids",
+		})
+	}
+	busy := v2("cpu")
+	busy.deny[base+"/cgroup.subtree_control"] = true
+	noChildren := v2("memory")
+	noChildren.deny[base+"/recon-"+strconv.Itoa(os.Getpid())+"-probe"] = true
+
+	tests := []struct {
+		name     string
+		goos     string
+		lookPath func(string) (string, error)
+		fs       *fakeCgroupFS
+		nice     bool
+		ionice   bool
+		cgroup   bool
+		ulimit   bool
+		memoryMB int
+		err      string
+	}{
+		{"windows", "windows", all, newFakeCgroupFS(nil), false, false, false, false, 0, "need Linux, not windows"},
+		{"darwin", "darwin", all, newFakeCgroupFS(nil), true, false, false, false, 0, "need Linux, not darwin"},
+		{"cgroup v1", "linux", all, newFakeCgroupFS(map[string]string{
+			"/proc/meminfo":     meminfo,
+			"/proc/self/cgroup": "12:memory:/user.slice\n1:name=systemd:/user.slice\n",
+		}), true, true, false, true, 16000, "unified hierarchy"},
+		{"v2, controller busy", "linux", all, busy, true, true, false, true, 16000, "cannot enable the memory controller"},
+		{"v2, no child cgroups", "linux", all, noChildren, true, true, false, true, 16000, "cannot create cgroups"},
+		{"v2, controller enabled", "linux", all, v2("cpu"), true, true, true, false, 16000, ""},
+		{"v2, delegated", "linux", all, v2("cpu io memory"), true, true, true, false, 16000, ""},
+		{"no ionice or sh", "linux", func(name string) (string, error) {
+			if name == "nice" {
+				return "/usr/bin/nice", nil
+			}
+			return "", exec.ErrNotFound
+		}, newFakeCgroupFS(map[string]string{}), true, false, false, false, 0, "not exist"},
+		{"no nice", "linux", without("nice"), v2("memory"), false, true, true, false, 16000, ""},
+	}
+	for _, tt := range tests {
+		c := detectLimitCapabilities(tt.goos, tt.lookPath, tt.fs)
+		if c.Nice != tt.nice || c.IONice != tt.ionice || (c.Cgroup != nil) != tt.cgroup || c.Ulimit != tt.ulimit || c.MemoryMB != tt.memoryMB {
+			t.Errorf("%s: %+v", tt.name, c)
+		}
+		if tt.err == "" && c.CgroupErr != nil || tt.err != "" && (c.CgroupErr == nil || !strings.Contains(c.CgroupErr.Error(), tt.err)) {
+			t.Errorf("%s: CgroupErr %v, want %q", tt.name, c.CgroupErr, tt.err)
+		}
+		if c.Cgroup != nil {
+			if c.Cgroup.Base != base {
+				t.Errorf("%s: cgroup base %s", tt.name, c.Cgroup.Base)
+			}
+			if !containsField(tt.fs.files[base+"/cgroup.subtree_control"], "memory") && tt.fs.files[base+"/cgroup.subtree_control"] != "+memory" {
+				t.Errorf("%s: memory controller not enabled: %q", tt.name, tt.fs.files[base+"/cgroup.subtree_control"])
+			}
+			if len(tt.fs.dirs) != 0 {
+				t.Errorf("%s: probe cgroup left behind: %v", tt.name, tt.fs.dirs)
+			}
+		}
+	}
+}
+
+func TestCgroupV2Enter(t *testing.T) {
+	const base = "/sys/fs/cgroup/recon"
+	fs := newFakeCgroupFS(map[string]string{})
+	c := &CgroupV2{fs: fs, Base: base}
+	dir := base + "/recon-" + strconv.Itoa(os.Getpid()) + "-1"
+
+	leave, err := c.Enter(4242, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fs.dirs[dir] || fs.files[dir+"/memory.max"] != strconv.Itoa(512<<20) || fs.files[dir+"/memory.swap.max"] != "0" || fs.files[dir+"/cgroup.procs"] != "4242" {
+		t.Errorf("cgroup %s: dirs %v, files %v", dir, fs.dirs, fs.files)
+	}
+	fs.files[dir+"/memory.events"] = "low 0\nhigh 0\nmax 3\noom 1\noom_kill 0\n"
+	if leave() {
+		t.Error("reported an OOM kill for oom_kill 0")
+	}
+	if fs.dirs[dir] {
+		t.Error("cgroup not removed")
+	}
+
+	leave, err = c.Enter(4243, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.files[base+"/recon-"+strconv.Itoa(os.Getpid())+"-2/memory.events"] = "oom 1\noom_kill 1\n"
+	if !leave() {
+		t.Error("OOM kill not reported")
+	}
+
+	// A process that cannot be moved in leaves no cgroup behind.
+	third := base + "/recon-" + strconv.Itoa(os.Getpid()) + "-3"
+	fs.deny[third+"/cgroup.procs"] = true
+	if _, err := c.Enter(4244, 512); err == nil {
+		t.Error("Enter with cgroup.procs unwritable: no error")
+	}
+	if fs.dirs[third] {
+		t.Error("cgroup of a failed Enter not removed")
+	}
+}
+
+func TestToolLimitsFor(t *testing.T) {
+	DetectLimitCapabilities()
+	saved := limitCaps
+	defer func() { limitCaps = saved }()
+	for _, key := range []string{"TOOL_NICE", "TOOL_IONICE", "TOOL_MEMORY_MB", "TOOL_OUTPUT_MB", "TOOL_MEMORY_MB_SQLMAP", "TOOL_NICE_TESTSSL_SH"} {
+		t.Setenv(key, "")
+	}
+
+	cgroup := LimitCapabilities{Cgroup: &CgroupV2{}, MemoryMB: 16000}
+	ulimit := LimitCapabilities{Ulimit: true, MemoryMB: 16000}
+	tests := []struct {
+		name string
+		caps LimitCapabilities
+		env  map[string]string
+		tool string
+		want ToolLimits
+	}{
+		{"defaults with cgroups", cgroup, nil, "amass", ToolLimits{Nice: 10, IOClass: "best-effort", MemoryMB: 8000, OutputMB: 256}},
+		// ulimit -v limits address space, so there is no default ceiling.
+		{"defaults with ulimit", ulimit, nil, "amass", ToolLimits{Nice: 10, IOClass: "best-effort", OutputMB: 256}},
+		{"global settings", ulimit, map[string]string{"TOOL_NICE": "5", "TOOL_IONICE": "Idle", "TOOL_MEMORY_MB": "2048", "TOOL_OUTPUT_MB": "0"}, "amass",
+			ToolLimits{Nice: 5, IOClass: "idle", MemoryMB: 2048}},
+		{"per-tool override", cgroup, map[string]string{"TOOL_MEMORY_MB": "2048", "TOOL_MEMORY_MB_SQLMAP": "4096"}, "/usr/local/bin/sqlmap",
+			ToolLimits{Nice: 10, IOClass: "best-effort", MemoryMB: 4096, OutputMB: 256}},
+		{"override of another tool", cgroup, map[string]string{"TOOL_MEMORY_MB_SQLMAP": "4096"}, "amass",
+			ToolLimits{Nice: 10, IOClass: "best-effort", MemoryMB: 8000, OutputMB: 256}},
+		{"tool name with a dot", cgroup, map[string]string{"TOOL_NICE_TESTSSL_SH": "19"}, "testssl.sh",
+			ToolLimits{Nice: 19, IOClass: "best-effort", MemoryMB: 8000, OutputMB: 256}},
+		// Unusable numbers keep the default; an unknown I/O class turns ionice off.
+		{"invalid values", cgroup, map[string]string{"TOOL_NICE": "-5", "TOOL_MEMORY_MB": "lots", "TOOL_IONICE": "realtime"}, "amass",
+			ToolLimits{Nice: 10, MemoryMB: 8000, OutputMB: 256}},
+	}
+	for _, tt := range tests {
+		limitCaps = tt.caps
+		for k, v := range tt.env {
+			os.Setenv(k, v)
+		}
+		if got := ToolLimitsFor(tt.tool); got != tt.want {
+			t.Errorf("%s: ToolLimitsFor(%q) = %+v, want %+v", tt.name, tt.tool, got, tt.want)
+		}
+		for k := range tt.env {
+			os.Setenv(k, "")
+		}
+	}
+}
+
+func TestToolLimitsWrap(t *testing.T) {
+	full := ToolLimits{Nice: 10, IOClass: "best-effort", MemoryMB: 64, OutputMB: 256}
+	ulimit := LimitCapabilities{Nice: true, IONice: true, Ulimit: true}
+	cgroup := LimitCapabilities{Nice: true, IONice: true, Cgroup: &CgroupV2{}}
+	tests := []struct {
+		name   string
+		limits ToolLimits
+		caps   LimitCapabilities
+		argv   []string
+		desc   string
+	}{
+		{"every wrapper", full, ulimit,
+			[]string{"nice", "-n", "10", "ionice", "-c", "2", "-n", "7", "sh", "-c", `ulimit -v 65536 && exec "$0" "$@"`, "sqlmap", "-u", "http://x/?id=1"},
+			"nice +10, ionice best-effort, memory 64MB (ulimit -v), output 256MB"},
+		// The cgroup takes care of memory once the process has started.
+		{"cgroup", full, cgroup,
+			[]string{"nice", "-n", "10", "ionice", "-c", "2", "-n", "7", "sqlmap", "-u", "http://x/?id=1"},
+			"nice +10, ionice best-effort, memory 64MB (cgroup v2), output 256MB"},
+		{"idle I/O", ToolLimits{IOClass: "idle"}, ulimit,
+			[]string{"ionice", "-c", "3", "sqlmap", "-u", "http://x/?id=1"}, "ionice idle"},
+		{"nothing available", full, LimitCapabilities{CgroupErr: errors.New("cgroups need Linux, not darwin")},
+			[]string{"sqlmap", "-u", "http://x/?id=1"}, "memory 64MB not enforced: cgroups need Linux, not darwin, output 256MB"},
+		{"no limits", ToolLimits{}, ulimit, []string{"sqlmap", "-u", "http://x/?id=1"}, "none"},
+	}
+	for _, tt := range tests {
+		name, args := tt.limits.wrap(tt.caps, "sqlmap", []string{"-u", "http://x/?id=1"})
+		if got := append([]string{name}, args...); !reflect.DeepEqual(got, tt.argv) {
+			t.Errorf("%s: wrap = %q", tt.name, got)
+		}
+		if got := tt.limits.Describe(tt.caps); got != tt.desc {
+			t.Errorf("%s: Describe = %q", tt.name, got)
+		}
+	}
+}
+
+// TestToolLimitsApplied runs real processes under the nice and ulimit
+// wrappers and the output cap.
+func TestToolLimitsApplied(t *testing.T) {
+	for _, tool := range []string{"sh", "nice", "yes"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not installed", tool)
+		}
+	}
+	TakeLimitBreaches()
+	caps := LimitCapabilities{Nice: true, Ulimit: true}
+	run := func(l ToolLimits, name string, args ...string) (string, error) {
+		path, argv := l.wrap(caps, name, args)
+		out, err := runLimited(exec.Command(path, argv...), name, l, caps)
+		return strings.TrimSpace(string(out)), err
+	}
+
+	base, err := run(ToolLimits{}, "nice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, _ := strconv.Atoi(base)
+	if got, err := run(ToolLimits{Nice: 5}, "nice"); err != nil || got != strconv.Itoa(n+5) {
+		t.Errorf("niceness under nice +5: %q, %v (was %d)", got, err, n)
+	}
+	if got, err := run(ToolLimits{MemoryMB: 512}, "sh", "-c", "ulimit -v"); err != nil || got != strconv.Itoa(512*1024) {
+		t.Errorf("ulimit -v under a 512MB ceiling: %q, %v", got, err)
+	}
+	if breaches := TakeLimitBreaches(); len(breaches) != 0 {
+		t.Errorf("breaches within the limits: %v", breaches)
+	}
+
+	out, err := run(ToolLimits{OutputMB: 1}, "yes")
+	if !errors.Is(err, ErrOutputCap) || len(out) > 1<<20 || len(out) < 1<<20-1 {
+		t.Errorf("yes under a 1MB output cap: %d bytes, %v", len(out), err)
+	}
+	if breaches := TakeLimitBreaches(); len(breaches) != 1 || !strings.HasPrefix(breaches[0], "yes: output cap exceeded at 1MB") {
+		t.Errorf("breaches = %q", breaches)
+	}
+}
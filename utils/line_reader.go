@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MaxLineBytes caps a single line of tool output (MAX_LINE_BYTES). Longer
+// lines are kept truncated to the cap and counted instead of being dropped.
+var MaxLineBytes = 10 << 20
+
+// LineReader reads lines of any length up to a cap. Unlike bufio.Scanner it
+// never stops at an oversized line: the line is truncated, flagged and
+// reading continues with the next one.
+type LineReader struct {
+	r         *bufio.Reader
+	max       int
+	line      []byte
+	truncated bool
+	count     int
+	eof       bool
+	err       error
+}
+
+// NewLineReader returns a reader yielding lines of at most max bytes.
+func NewLineReader(r io.Reader, max int) *LineReader {
+	return &LineReader{r: bufio.NewReaderSize(r, 64*1024), max: max}
+}
+
+// Scan advances to the next line, reporting false at the end of input or on error.
+func (lr *LineReader) Scan() bool {
+	if lr.eof || lr.err != nil {
+		return false
+	}
+	lr.line = lr.line[:0]
+	lr.truncated = false
+	for {
+		chunk, err := lr.r.ReadSlice('\n')
+		data := chunk
+		if err == nil {
+			data = chunk[:len(chunk)-1]
+		}
+		if room := lr.max - len(lr.line); len(data) > room {
+			data = data[:room]
+			lr.truncated = true
+		}
+		lr.line = append(lr.line, data...)
+		switch err {
+		case nil:
+		case bufio.ErrBufferFull:
+			continue
+		case io.EOF:
+			lr.eof = true
+			if len(lr.line) == 0 && !lr.truncated {
+				return false
+			}
+		default:
+			lr.err = err
+			return false
+		}
+		break
+	}
+	if n := len(lr.line); n > 0 && lr.line[n-1] == '\r' {
+		lr.line = lr.line[:n-1]
+	}
+	if lr.truncated {
+		lr.count++
+	}
+	return true
+}
+
+// Text returns the current line.
+func (lr *LineReader) Text() string { return string(lr.line) }
+
+// Bytes returns the current line; the slice is reused by the next Scan.
+func (lr *LineReader) Bytes() []byte { return lr.line }
+
+// Truncated reports whether the current line exceeded the cap.
+func (lr *LineReader) Truncated() bool { return lr.truncated }
+
+// TruncatedCount returns how many lines have been truncated so far.
+func (lr *LineReader) TruncatedCount() int { return lr.count }
+
+// Err returns the first read error other than io.EOF.
+func (lr *LineReader) Err() error { return lr.err }
+
+// ForEachLine calls fn for every line of a tool's output, truncating lines
+// over MaxLineBytes and recording how many were truncated for the tool.
+func ForEachLine(tool, output string, fn func(line string, truncated bool)) {
+	lr := NewLineReader(strings.NewReader(output), MaxLineBytes)
+	for lr.Scan() {
+		fn(lr.Text(), lr.Truncated())
+	}
+	RecordTruncations(tool, lr.TruncatedCount())
+}
+
+// ReadLines splits a tool's output into lines via ForEachLine.
+func ReadLines(tool, output string) []string {
+	var lines []string
+	ForEachLine(tool, output, func(line string, _ bool) {
+		lines = append(lines, line)
+	})
+	return lines
+}
+
+var (
+	truncMu     sync.Mutex
+	truncations = make(map[string]int)
+)
+
+// RecordTruncations adds n truncated lines to tool's count.
+func RecordTruncations(tool string, n int) {
+	if n == 0 {
+		return
+	}
+	truncMu.Lock()
+	truncations[tool] += n
+	truncMu.Unlock()
+}
+
+// TakeTruncations returns one message per tool with truncated lines since
+// the last call and resets the counts, so each stage reports its own.
+func TakeTruncations() []string {
+	truncMu.Lock()
+	defer truncMu.Unlock()
+	var msgs []string
+	for tool, n := range truncations {
+		msgs = append(msgs, fmt.Sprintf("%s: %d line(s) truncated at %d bytes", tool, n, MaxLineBytes))
+	}
+	truncations = make(map[string]int)
+	sort.Strings(msgs)
+	return msgs
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLineReader(t *testing.T) {
+	// Lines well past the reader's 64KB buffer, so they span many reads.
+	big := strings.Repeat("a", 3<<20)
+	tests := []struct {
+		name      string
+		input     string
+		max       int
+		lines     []string
+		truncated []bool
+	}{
+		{"short lines", "one\ntwo\r\nthree", 16, []string{"one", "two", "three"}, []bool{false, false, false}},
+		{"blank lines", "\n\nx\n", 16, []string{"", "", "x"}, []bool{false, false, false}},
+		{"at the cap", "abcd\nabcde\n", 4, []string{"abcd", "abcd"}, []bool{false, true}},
+		{"multi-MB line kept", "before\n" + big + "\nafter\n", 4 << 20, []string{"before", big, "after"}, []bool{false, false, false}},
+		{"multi-MB line truncated", "before\n" + big + "\nafter\n", 1 << 20, []string{"before", big[:1<<20], "after"}, []bool{false, true, false}},
+		{"truncated last line without newline", "before\n" + big, 1 << 20, []string{"before", big[:1<<20]}, []bool{false, true}},
+		{"two truncated lines", big + "\n" + big + "\n", 1 << 20, []string{big[:1<<20], big[:1<<20]}, []bool{true, true}},
+	}
+	for _, tt := range tests {
+		lr := NewLineReader(strings.NewReader(tt.input), tt.max)
+		var lines []string
+		var truncated []bool
+		for lr.Scan() {
+			lines = append(lines, lr.Text())
+			truncated = append(truncated, lr.Truncated())
+		}
+		if lr.Err() != nil {
+			t.Errorf("%s: %v", tt.name, lr.Err())
+		}
+		if !reflect.DeepEqual(lines, tt.lines) || !reflect.DeepEqual(truncated, tt.truncated) {
+			t.Errorf("%s: %d line(s) of lengths %v, truncated %v", tt.name, len(lines), lengths(lines), truncated)
+		}
+		n := 0
+		for _, tr := range tt.truncated {
+			if tr {
+				n++
+			}
+		}
+		if lr.TruncatedCount() != n {
+			t.Errorf("%s: TruncatedCount() = %d, want %d", tt.name, lr.TruncatedCount(), n)
+		}
+	}
+}
+
+func lengths(lines []string) []int {
+	var n []int
+	for _, l := range lines {
+		n = append(n, len(l))
+	}
+	return n
+}
+
+// TestTruncationCounts checks that truncated lines are counted per tool
+// and reported once per stage.
+func TestTruncationCounts(t *testing.T) {
+	saved := MaxLineBytes
+	defer func() { MaxLineBytes = saved }()
+	MaxLineBytes = 1 << 20
+	TakeTruncations()
+
+	big := string(bytes.Repeat([]byte("x"), 5<<20))
+	if lines := ReadLines("katana", "https://a.example.com/\n"+big+"\nhttps://b.example.com/\n"); len(lines) != 3 || len(lines[1]) != 1<<20 {
+		t.Errorf("katana: %v", lengths(lines))
+	}
+	ForEachLine("gospider", big+"\n"+big, func(string, bool) {})
+	ReadLines("katana", big)
+	ReadLines("subfinder", "www.example.com\n")
+
+	want := []string{
+		"gospider: 2 line(s) truncated at 1048576 bytes",
+		"katana: 2 line(s) truncated at 1048576 bytes",
+	}
+	if got := TakeTruncations(); !reflect.DeepEqual(got, want) {
+		t.Errorf("TakeTruncations() = %q, want %q", got, want)
+	}
+	if got := TakeTruncations(); len(got) != 0 {
+		t.Errorf("counts not reset: %q", got)
+	}
+}
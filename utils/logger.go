@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// logMsg is either a log line or, when done is set, a flush marker.
+type logMsg struct {
+	line string
+	done chan struct{}
+}
+
+// Logger is a log sink that never blocks its callers. Lines travel through a
+// buffered channel to a single writer goroutine that appends them to an
+// in-memory ring buffer and, once SetOutput is called, to a log file. The
+// ring only bounds what the TUI shows; the file keeps every line. When the
+// channel is full, lines are dropped and counted instead of waiting.
+type Logger struct {
+	ch        chan logMsg
+	highWater int
+	dropped   uint64
+	reported  uint64
+
+	mu    sync.Mutex
+	ring  []string
+	next  int
+	full  bool
+	file  *os.File
+	path  string
+	out   *bufio.Writer
+	echo  io.Writer
+	alert bool
+}
+
+// NewLogger starts a logger with a channel of bufSize lines and an in-memory
+// ring holding the most recent maxLines lines.
+func NewLogger(bufSize, maxLines int) *Logger {
+	l := &Logger{
+		ch:        make(chan logMsg, bufSize),
+		highWater: bufSize * 3 / 4,
+		ring:      make([]string, maxLines),
+	}
+	go l.run()
+	return l
+}
+
+// SetOutput additionally writes every line to the file at path.
+func (l *Logger) SetOutput(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		l.out.Flush()
+		l.file.Close()
+	}
+	l.file = f
+	l.path = path
+	l.out = bufio.NewWriter(f)
+	return nil
+}
+
+// Echo additionally writes every line to w as it is logged, for runs
+// without the TUI.
+func (l *Logger) Echo(w io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.echo = w
+}
+
+// Log queues a line. It never blocks: if the buffer is saturated the line is dropped.
+func (l *Logger) Log(line string) {
+	select {
+	case l.ch <- logMsg{line: line}:
+	default:
+		atomic.AddUint64(&l.dropped, 1)
+	}
+}
+
+// Flush waits until every line queued before the call has been written.
+// Call it at stage boundaries and before shutdown so nothing is lost.
+func (l *Logger) Flush() {
+	done := make(chan struct{})
+	l.ch <- logMsg{done: done}
+	<-done
+}
+
+// Dropped returns how many lines were discarded because the buffer was full.
+func (l *Logger) Dropped() uint64 {
+	return atomic.LoadUint64(&l.dropped)
+}
+
+// QueueDepth returns how many lines are waiting to be written.
+func (l *Logger) QueueDepth() int {
+	return len(l.ch)
+}
+
+// Lines returns a snapshot of the buffered lines, oldest first.
+func (l *Logger) Lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.full {
+		return append([]string{}, l.ring[:l.next]...)
+	}
+	return append(append([]string{}, l.ring[l.next:]...), l.ring[:l.next]...)
+}
+
+// History flushes the logger and returns every line of the log file, for
+// records that must not lose what scrolled out of the ring. Without a log
+// file it returns the ring, as Lines does.
+func (l *Logger) History() ([]string, error) {
+	l.Flush()
+	l.mu.Lock()
+	path := l.path
+	l.mu.Unlock()
+	if path == "" {
+		return l.Lines(), nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return l.Lines(), err
+	}
+	if len(data) == 0 {
+		return []string{}, nil
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
+
+// run is the single writer goroutine.
+func (l *Logger) run() {
+	for msg := range l.ch {
+		l.mu.Lock()
+		if msg.done != nil {
+			if d := atomic.LoadUint64(&l.dropped); d > l.reported {
+				l.write(fmt.Sprintf("[!] Log buffer full: dropped %d line(s)", d-l.reported))
+				l.reported = d
+			}
+			if l.out != nil {
+				l.out.Flush()
+			}
+			l.mu.Unlock()
+			close(msg.done)
+			continue
+		}
+		if depth := len(l.ch); depth >= l.highWater && !l.alert {
+			l.alert = true
+			l.write(fmt.Sprintf("[!] Log buffer above high-water mark (%d/%d queued)", depth, cap(l.ch)))
+		} else if depth < l.highWater/2 {
+			l.alert = false
+		}
+		l.write(msg.line)
+		// Flush once the queue drains so the file can be tailed live.
+		if len(l.ch) == 0 && l.out != nil {
+			l.out.Flush()
+		}
+		l.mu.Unlock()
+	}
+}
+
+// write stores a line in the ring and the log file. The caller holds l.mu.
+func (l *Logger) write(line string) {
+	l.ring[l.next] = line
+	l.next++
+	if l.next == len(l.ring) {
+		l.next = 0
+		l.full = true
+	}
+	if l.out != nil {
+		l.out.WriteString(line + "\n")
+	}
+	if l.echo != nil {
+		io.WriteString(l.echo, line+"\n")
+	}
+}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestLoggerHistoryOutlivesRing(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   bool
+		lines  int
+		ring   int
+		want   int
+		oldest string
+	}{
+		{"no file, under the ring", false, 3, 5, 3, "line 0"},
+		{"no file, past the ring", false, 12, 5, 5, "line 7"},
+		{"file, past the ring", true, 12, 5, 12, "line 0"},
+		{"file, nothing logged", true, 0, 5, 0, ""},
+	}
+	for _, tt := range tests {
+		l := NewLogger(64, tt.ring)
+		if tt.file {
+			if err := l.SetOutput(filepath.Join(t.TempDir(), "scan.log")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := 0; i < tt.lines; i++ {
+			l.Log(fmt.Sprintf("line %d", i))
+			if i%32 == 31 {
+				l.Flush()
+			}
+		}
+		got, err := l.History()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(got) != tt.want {
+			t.Errorf("%s: %d line(s), want %d", tt.name, len(got), tt.want)
+			continue
+		}
+		if tt.want > 0 && got[0] != tt.oldest {
+			t.Errorf("%s: oldest line %q, want %q", tt.name, got[0], tt.oldest)
+		}
+		if ring := l.Lines(); len(ring) > tt.ring {
+			t.Errorf("%s: ring holds %d line(s), more than %d", tt.name, len(ring), tt.ring)
+		}
+	}
+}
+
+// burstLines is the size of the synthetic burst a verbose tool produces.
+const burstLines = 50000
+
+// mutexLog is the old path: every line appended to the shared scan result
+// under the mutex that result updates and TUI reads also take.
+type mutexLog struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (m *mutexLog) Log(line string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lines = append(m.lines, line)
+}
+
+// burst logs burstLines lines from 8 tool goroutines while a reader takes
+// the lock like the TUI refresh does, and returns when all are written.
+func burst(log func(string), read func(), flush func()) {
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				read()
+			}
+		}
+	}()
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < burstLines/8; i++ {
+				log(fmt.Sprintf("[ffuf] worker %d: /admin/%d [Status: 403, Size: 1234, Words: 56, Lines: 7]", w, i))
+			}
+		}(w)
+	}
+	wg.Wait()
+	flush()
+	close(stop)
+}
+
+func BenchmarkLogBurst(b *testing.B) {
+	b.Run("mutex", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			m := &mutexLog{}
+			burst(m.Log, func() {
+				m.mu.Lock()
+				_ = len(m.lines)
+				m.mu.Unlock()
+			}, func() {})
+		}
+	})
+	b.Run("channel", func(b *testing.B) {
+		dir := b.TempDir()
+		for i := 0; i < b.N; i++ {
+			l := NewLogger(4096, 5000)
+			path := filepath.Join(dir, fmt.Sprintf("scan%d.log", i))
+			if err := l.SetOutput(path); err != nil {
+				b.Fatal(err)
+			}
+			burst(l.Log, func() { _ = l.QueueDepth() }, l.Flush)
+			b.ReportMetric(float64(l.Dropped()), "dropped/op")
+			l.mu.Lock()
+			l.file.Close()
+			l.mu.Unlock()
+			os.Remove(path)
+		}
+	})
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultPort returns the port a scheme implies: 443 for https, 80 otherwise.
+func DefaultPort(scheme string) int {
+	if strings.EqualFold(scheme, "https") {
+		return 443
+	}
+	return 80
+}
+
+// OriginURL builds scheme://host[:port]. The port is left out when it is 0
+// or the scheme's default, and IPv6 literals are bracketed.
+func OriginURL(scheme, host string, port int) string {
+	u := url.URL{Scheme: scheme, Host: host}
+	if port > 0 && port != DefaultPort(scheme) {
+		u.Host = net.JoinHostPort(host, strconv.Itoa(port))
+	} else if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	}
+	return u.String()
+}
@@ -0,0 +1,26 @@
+package utils
+
+import "testing"
+
+func TestOriginURL(t *testing.T) {
+	tests := []struct {
+		scheme, host string
+		port         int
+		want         string
+	}{
+		{"https", "www.example.com", 0, "https://www.example.com"},
+		{"https", "www.example.com", 443, "https://www.example.com"},
+		{"http", "www.example.com", 80, "http://www.example.com"},
+		{"http", "www.example.com", 443, "http://www.example.com:443"},
+		{"https", "www.example.com", 8443, "https://www.example.com:8443"},
+		{"HTTPS", "www.example.com", 443, "HTTPS://www.example.com"},
+		{"http", "192.0.2.10", 8080, "http://192.0.2.10:8080"},
+		{"https", "2001:db8::1", 0, "https://[2001:db8::1]"},
+		{"https", "2001:db8::1", 9443, "https://[2001:db8::1]:9443"},
+	}
+	for _, tt := range tests {
+		if got := OriginURL(tt.scheme, tt.host, tt.port); got != tt.want {
+			t.Errorf("OriginURL(%q, %q, %d) = %q, want %q", tt.scheme, tt.host, tt.port, got, tt.want)
+		}
+	}
+}
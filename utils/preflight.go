@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// PlannedStage is one pipeline stage as a run is about to execute it.
+// Skipped gives the reason when the stage will not run.
+type PlannedStage struct {
+	Name       string
+	Aggressive bool
+	Skipped    string
+}
+
+// Preflight summarizes what a run is about to do, so a mistyped target is
+// caught before anything is sent to it.
+type Preflight struct {
+	Target        string
+	Scope         *ScopeFilter
+	Stages        []PlannedStage
+	Estimates     []string
+	Proxy         string
+	Rate          string
+	Authorization string
+}
+
+// Render formats the summary as plain text, one section per line group.
+func (p Preflight) Render() string {
+	var b strings.Builder
+	b.WriteString("Pre-flight summary\n")
+	b.WriteString("  Target:        " + p.Target + "\n")
+	if p.Scope != nil {
+		b.WriteString("  Scope allow:   " + strings.Join(p.Scope.Allow, ", ") + " (and their subdomains)\n")
+		if len(p.Scope.Deny) > 0 {
+			b.WriteString("  Scope deny:    " + strings.Join(p.Scope.Deny, ", ") + "\n")
+		}
+		if len(p.Scope.DenyTags) > 0 {
+			b.WriteString("  Deny tags:     " + strings.Join(p.Scope.DenyTags, ", ") + "\n")
+		}
+	}
+	b.WriteString("  Authorization: " + p.Authorization + "\n")
+	b.WriteString("  Proxy:         " + p.Proxy + "\n")
+	b.WriteString("  Rate:          " + p.Rate + "\n")
+	runs := 0
+	for _, s := range p.Stages {
+		if s.Skipped == "" {
+			runs++
+		}
+	}
+	b.WriteString(fmt.Sprintf("  Stages (%d of %d run):\n", runs, len(p.Stages)))
+	for _, s := range p.Stages {
+		kind := "passive"
+		if s.Aggressive {
+			kind = "AGGRESSIVE"
+		}
+		line := fmt.Sprintf("    %-28s %-10s", s.Name, kind)
+		if s.Skipped != "" {
+			line += " skipped: " + s.Skipped
+		}
+		b.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	if len(p.Estimates) > 0 {
+		b.WriteString("  Estimated request volume:\n")
+		for _, e := range p.Estimates {
+			b.WriteString("    " + e + "\n")
+		}
+	}
+	return b.String()
+}
+
+// IsTerminal reports whether f is an interactive terminal rather than a
+// pipe, file or /dev/null. /dev/null is a character device too, so the
+// file mode alone cannot tell them apart.
+func IsTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// ConfirmProceed reads one line from r and reports whether it is "y" or
+// "yes", in any case.
+func ConfirmProceed(r io.Reader) bool {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPreflightRender(t *testing.T) {
+	p := Preflight{
+		Target:        "example.com",
+		Scope:         &ScopeFilter{Allow: []string{"example.com", "example.net"}, Deny: []string{"vpn.example.com"}, DenyTags: []string{"third-party"}},
+		Authorization: "engagement ENG-42, authorized by J. Doe",
+		Proxy:         "none, direct connections",
+		Rate:          "tool defaults",
+		Stages: []PlannedStage{
+			{Name: "subdomain enumeration"},
+			{Name: "fuzzing", Aggressive: true},
+			{Name: "Shodan enrichment", Skipped: "no SHODAN_API_KEY"},
+		},
+		Estimates: []string{"fuzzing: about 200 request(s) to the target's web root"},
+	}
+	want := `Pre-flight summary
+  Target:        example.com
+  Scope allow:   example.com, example.net (and their subdomains)
+  Scope deny:    vpn.example.com
+  Deny tags:     third-party
+  Authorization: engagement ENG-42, authorized by J. Doe
+  Proxy:         none, direct connections
+  Rate:          tool defaults
+  Stages (2 of 3 run):
+    subdomain enumeration        passive
+    fuzzing                      AGGRESSIVE
+    Shodan enrichment            passive    skipped: no SHODAN_API_KEY
+  Estimated request volume:
+    fuzzing: about 200 request(s) to the target's web root
+`
+	if got := p.Render(); got != want {
+		t.Errorf("Render =\n%s\nwant\n%s", got, want)
+	}
+
+	// Without a scope, deny rules or estimates their lines are left out.
+	p.Scope = &ScopeFilter{Allow: []string{"example.com"}}
+	p.Estimates = nil
+	got := p.Render()
+	for _, absent := range []string{"Scope deny:", "Deny tags:", "Estimated request volume:"} {
+		if strings.Contains(got, absent) {
+			t.Errorf("Render contains %q:\n%s", absent, got)
+		}
+	}
+	p.Scope = nil
+	if got := p.Render(); strings.Contains(got, "Scope allow:") {
+		t.Errorf("Render without a scope:\n%s", got)
+	}
+}
+
+func TestConfirmProceed(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{"  yes  \r\n", true},
+		{"y", true},
+		{"n\n", false},
+		{"yes please\n", false},
+		{"\n", false},
+		{"", false},
+		// Only the first line is the answer.
+		{"\ny\n", false},
+	}
+	for _, tt := range tests {
+		if got := ConfirmProceed(strings.NewReader(tt.answer)); got != tt.want {
+			t.Errorf("ConfirmProceed(%q) = %v", tt.answer, got)
+		}
+	}
+}
+
+// TestIsTerminal checks that what a headless run has on stdin and stdout
+// is not taken for a terminal, so it never waits for an answer.
+func TestIsTerminal(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	file, err := os.Create(filepath.Join(t.TempDir(), "out.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer null.Close()
+	for name, f := range map[string]*os.File{"pipe reader": r, "pipe writer": w, "file": file, os.DevNull: null} {
+		if IsTerminal(f) {
+			t.Errorf("%s taken for a terminal", name)
+		}
+	}
+	closed, _ := os.Open(file.Name())
+	closed.Close()
+	if IsTerminal(closed) {
+		t.Error("closed file taken for a terminal")
+	}
+}
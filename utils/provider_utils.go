@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+)
+
+// ProviderError describes a failed or malformed response from an external API provider.
+type ProviderError struct {
+	Provider string
+	Err      error
+}
+
+func (e *ProviderError) Error() string {
+	return e.Provider + ": " + e.Err.Error()
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// RequestError wraps a request to provider that could not be built or
+// sent. The request URL is dropped from a *url.Error: providers such as
+// Shodan take the API key as a query parameter, and the error text ends up
+// in the scan log and summary.json.
+func RequestError(provider string, err error) *ProviderError {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = fmt.Errorf("%s: %w", urlErr.Op, urlErr.Err)
+	}
+	return &ProviderError{Provider: provider, Err: err}
+}
+
+// DecodeProviderJSON decodes a provider response into the typed struct v.
+// Bodies larger than maxBytes are rejected rather than buffered, and type
+// mismatches are reported as a ProviderError naming the offending field.
+func DecodeProviderJSON(provider string, body io.Reader, maxBytes int64, v interface{}) error {
+	data, err := ioutil.ReadAll(io.LimitReader(body, maxBytes+1))
+	if err != nil {
+		return &ProviderError{Provider: provider, Err: err}
+	}
+	if int64(len(data)) > maxBytes {
+		return &ProviderError{Provider: provider, Err: fmt.Errorf("response exceeds %d bytes", maxBytes)}
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			err = fmt.Errorf("unexpected %s for field %q (want %s)", typeErr.Value, typeErr.Field, typeErr.Type)
+		}
+		return &ProviderError{Provider: provider, Err: err}
+	}
+	return nil
+}
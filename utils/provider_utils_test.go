@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestDecodeProviderJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want types.ShodanHost
+		err  string
+	}{
+		{"host", `{"ip_str":"192.0.2.10","ports":[22,443],"hostnames":["www.example.com"],"org":"Example","vulns":["CVE-2021-44228"],"data":[{"port":22}]}`,
+			types.ShodanHost{IP: "192.0.2.10", Ports: []int{22, 443}, Hostnames: []string{"www.example.com"}, Org: "Example", Vulns: []string{"CVE-2021-44228"}}, ""},
+		{"type mismatch", `{"ip_str":"192.0.2.10","ports":"22,443"}`, types.ShodanHost{IP: "192.0.2.10"}, `unexpected string for field "ports" (want []int)`},
+		{"truncated", `{"ip_str":"192.0.2.10","ports":[22,`, types.ShodanHost{}, "unexpected end of JSON input"},
+		{"HTML error page", `<html><body>502 Bad Gateway</body></html>`, types.ShodanHost{}, "invalid character"},
+		{"too large", `{"ip_str":"` + strings.Repeat("9", 600) + `"}`, types.ShodanHost{}, "response exceeds 512 bytes"},
+	}
+	for _, tt := range tests {
+		var got types.ShodanHost
+		err := DecodeProviderJSON("shodan", strings.NewReader(tt.body), 512, &got)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+		var pe *ProviderError
+		if err != nil && (!errors.As(err, &pe) || pe.Provider != "shodan") {
+			t.Errorf("%s: %T is not a shodan ProviderError", tt.name, err)
+		}
+		if tt.name != "too large" && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %+v", tt.name, got)
+		}
+	}
+}
+
+// FuzzShodanDecode feeds arbitrary Shodan host responses to the decoder:
+// it must never panic, must fail only with a ProviderError, must refuse
+// bodies over the cap, and what it accepts must survive a round trip.
+func FuzzShodanDecode(f *testing.F) {
+	const max = 4096
+	for _, seed := range []string{
+		`{"ip_str":"192.0.2.10","ports":[22,443],"hostnames":["www.example.com"],"org":"Example","isp":"Example ISP","vulns":["CVE-2021-44228"]}`,
+		`{"ip_str":"192.0.2.10","ports":"22"}`,
+		`{"ports":[1e309]}`,
+		`{"ports":[-1, 65536, 3.5]}`,
+		`{"hostnames":[null, "\u0000", "\ud800"]}`,
+		`{"ip_str":{"nested":true}}`,
+		`[]`,
+		`null`,
+		`{"error": "Invalid API key"}`,
+		`{"ip_str":"192.0.2.10"`,
+		"",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		var host types.ShodanHost
+		err := DecodeProviderJSON("shodan", bytes.NewReader(body), max, &host)
+		if err != nil {
+			var pe *ProviderError
+			if !errors.As(err, &pe) || pe.Provider != "shodan" {
+				t.Fatalf("error %T %v is not a shodan ProviderError", err, err)
+			}
+			return
+		}
+		if len(body) > max {
+			t.Fatalf("accepted a %d-byte body over the %d-byte cap", len(body), max)
+		}
+		data, err := json.Marshal(host)
+		if err != nil {
+			t.Fatalf("re-encoding %+v: %v", host, err)
+		}
+		var again types.ShodanHost
+		if err := DecodeProviderJSON("shodan", bytes.NewReader(data), int64(len(data)), &again); err != nil {
+			t.Fatalf("decoding the re-encoded host %s: %v", data, err)
+		}
+		if !reflect.DeepEqual(host, again) {
+			t.Fatalf("round trip changed %+v into %+v", host, again)
+		}
+	})
+}
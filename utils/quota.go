@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// providerCredits is the number of metered credits each provider charges
+// per host lookup. Shodan's /shodan/host/{ip} spends no query credits
+// (only searches do), so its lookups are limited by the run budget alone.
+// Providers not listed are assumed to charge one credit per lookup.
+var providerCredits = map[string]int{
+	"shodan": 0,
+}
+
+// lookupCredits returns the credits provider charges per host lookup.
+func lookupCredits(provider string) int {
+	cost, ok := providerCredits[provider]
+	if !ok {
+		cost = 1
+	}
+	return cost
+}
+
+// EstimateQueries returns the requests provider needs for hosts lookups,
+// one per host.
+func EstimateQueries(provider string, hosts int) int {
+	return hosts
+}
+
+// EstimateCredits returns the metered credits provider spends on hosts
+// lookups.
+func EstimateCredits(provider string, hosts int) int {
+	return hosts * lookupCredits(provider)
+}
+
+// QueryBudget reads the per-run query budget for provider from
+// <PROVIDER>_QUERY_BUDGET; -1 means no budget is configured.
+func QueryBudget(envName string) int {
+	n, err := strconv.Atoi(os.Getenv(envName))
+	if err != nil || n <= 0 {
+		return -1
+	}
+	return n
+}
+
+// PlanQueries decides how many of hosts lookups may run without exceeding
+// the run budget, in lookups, or the provider's remaining credits (either
+// -1 when unknown) and explains every cut it makes. Remaining credits only
+// limit providers that charge for a lookup.
+func PlanQueries(provider string, hosts, budget, remaining int) (allowed int, warnings []string) {
+	allowed = hosts
+	if budget >= 0 && hosts > budget {
+		allowed = budget
+		warnings = append(warnings, fmt.Sprintf("%s: %d lookups exceed the run budget of %d; limiting to %d host(s)", provider, hosts, budget, allowed))
+	}
+	cost := lookupCredits(provider)
+	if cost > 0 && remaining >= 0 && EstimateCredits(provider, allowed) > remaining {
+		allowed = remaining / cost
+		warnings = append(warnings, fmt.Sprintf("%s: estimated %d credits exceed the %d remaining; limiting to %d host(s)", provider, EstimateCredits(provider, hosts), remaining, allowed))
+	}
+	return allowed, warnings
+}
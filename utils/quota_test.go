@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEstimates(t *testing.T) {
+	for _, tt := range []struct {
+		provider       string
+		hosts          int
+		queries, spent int
+	}{
+		{"shodan", 40, 40, 0},
+		{"securitytrails", 40, 40, 40},
+		{"censys", 0, 0, 0},
+	} {
+		if got := EstimateQueries(tt.provider, tt.hosts); got != tt.queries {
+			t.Errorf("EstimateQueries(%s, %d) = %d, want %d", tt.provider, tt.hosts, got, tt.queries)
+		}
+		if got := EstimateCredits(tt.provider, tt.hosts); got != tt.spent {
+			t.Errorf("EstimateCredits(%s, %d) = %d, want %d", tt.provider, tt.hosts, got, tt.spent)
+		}
+	}
+}
+
+func TestQueryBudget(t *testing.T) {
+	for _, tt := range []struct {
+		value string
+		want  int
+	}{
+		{"", -1},
+		{"25", 25},
+		{"0", -1},
+		{"-3", -1},
+		{"lots", -1},
+	} {
+		t.Setenv("TEST_QUERY_BUDGET", tt.value)
+		if got := QueryBudget("TEST_QUERY_BUDGET"); got != tt.want {
+			t.Errorf("QueryBudget with %q = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPlanQueries(t *testing.T) {
+	tests := []struct {
+		name                     string
+		provider                 string
+		hosts, budget, remaining int
+		want                     int
+		warnings                 []string
+	}{
+		{"no limits", "shodan", 50, -1, -1, 50, nil},
+		{"within budget", "shodan", 50, 100, -1, 50, nil},
+		{"over budget", "shodan", 50, 20, -1, 20, []string{"shodan: 50 lookups exceed the run budget of 20; limiting to 20 host(s)"}},
+		// Host lookups spend no Shodan query credits.
+		{"no query credits", "shodan", 50, -1, 0, 50, nil},
+		{"no query credits, over budget", "shodan", 50, 10, 0, 10, []string{"shodan: 50 lookups exceed the run budget of 10; limiting to 10 host(s)"}},
+		{"metered within credits", "securitytrails", 30, -1, 30, 30, nil},
+		{"metered over credits", "securitytrails", 30, -1, 12, 12, []string{"securitytrails: estimated 30 credits exceed the 12 remaining; limiting to 12 host(s)"}},
+		{"metered, credits unknown", "securitytrails", 30, -1, -1, 30, nil},
+		{"metered over both", "censys", 30, 20, 5, 5, []string{
+			"censys: 30 lookups exceed the run budget of 20; limiting to 20 host(s)",
+			"censys: estimated 30 credits exceed the 5 remaining; limiting to 5 host(s)",
+		}},
+		{"budget already within credits", "censys", 30, 5, 10, 5, []string{"censys: 30 lookups exceed the run budget of 5; limiting to 5 host(s)"}},
+		{"nothing to look up", "censys", 0, 5, 0, 0, nil},
+	}
+	for _, tt := range tests {
+		got, warnings := PlanQueries(tt.provider, tt.hosts, tt.budget, tt.remaining)
+		if got != tt.want || !reflect.DeepEqual(warnings, tt.warnings) {
+			t.Errorf("%s: allowed %d, warnings %q; want %d, %q", tt.name, got, warnings, tt.want, tt.warnings)
+		}
+	}
+}
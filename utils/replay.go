@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ReplayResult is the verdict for one recorded command.
+// Status is identical, drifted or missing-tool.
+type ReplayResult struct {
+	Record CommandRecord
+	Status string
+	Notes  []string
+}
+
+// LoadCommandRecords reads a run's commands.jsonl.
+func LoadCommandRecords(path string) ([]CommandRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []CommandRecord
+	lr := NewLineReader(f, MaxLineBytes)
+	for line := 1; lr.Scan(); line++ {
+		if strings.TrimSpace(lr.Text()) == "" {
+			continue
+		}
+		if lr.Truncated() {
+			return nil, fmt.Errorf("%s line %d: longer than %d bytes", path, line, MaxLineBytes)
+		}
+		var rec CommandRecord
+		if err := json.Unmarshal(lr.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", path, line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, lr.Err()
+}
+
+// recordedRunDir returns the run directory rec was recorded in: tools run
+// in <rundir>/raw/<tool>.
+func recordedRunDir(rec CommandRecord) string {
+	raw := filepath.Dir(rec.Dir)
+	if filepath.Base(raw) != "raw" {
+		return ""
+	}
+	return filepath.Dir(raw)
+}
+
+// reroot rewrites every path under the directory from in s, a path or an
+// argument embedding one (--output=path, list.txt:FUZZ), to the same path
+// under to.
+func reroot(s, from, to string) string {
+	if from == "" || from == to {
+		return s
+	}
+	if s == from {
+		return to
+	}
+	return strings.ReplaceAll(s, from+string(filepath.Separator), to+string(filepath.Separator))
+}
+
+// ReplayCommand compares a recorded command with the current environment:
+// tool presence and binary hash, and input file hashes. Paths recorded
+// under the original run directory are looked up under runDir, so a run
+// that was moved or copied can be replayed. With outDir set the command is
+// re-run with its paths under the run directory re-rooted into outDir,
+// after copying its input files there, so the original artifacts are left
+// alone; its exit code and output hash are compared as well.
+func ReplayCommand(rec CommandRecord, runDir, outDir string) ReplayResult {
+	res := ReplayResult{Record: rec, Status: "identical"}
+	drift := func(format string, a ...interface{}) {
+		res.Status = "drifted"
+		res.Notes = append(res.Notes, fmt.Sprintf(format, a...))
+	}
+	path, err := exec.LookPath(rec.Tool)
+	if err != nil {
+		res.Status = "missing-tool"
+		res.Notes = append(res.Notes, rec.Tool+" not found in PATH")
+		return res
+	}
+	if rec.BinarySHA != "" {
+		if h, _ := HashFile(path); h != rec.BinarySHA {
+			drift("tool binary differs (%s): different version or build", path)
+		}
+	}
+	from := recordedRunDir(rec)
+	for input, want := range rec.Inputs {
+		got, err := HashFile(reroot(input, from, runDir))
+		if err != nil {
+			drift("input %s missing", input)
+		} else if got != want {
+			drift("input %s changed", input)
+		}
+	}
+	if outDir == "" {
+		return res
+	}
+
+	for input := range rec.Inputs {
+		if dst := reroot(input, from, outDir); dst != input {
+			if err := copyReplayInput(reroot(input, from, runDir), dst); err != nil {
+				drift("cannot copy input %s: %v", input, err)
+			}
+		}
+	}
+	dir := reroot(rec.Dir, from, outDir)
+	if dir == rec.Dir {
+		// Not recorded in a run directory: run in a scratch one.
+		scratch, err := ioutil.TempDir("", "recon-replay-")
+		if err != nil {
+			drift("cannot create scratch dir: %v", err)
+			return res
+		}
+		defer os.RemoveAll(scratch)
+		dir = scratch
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		drift("cannot create %s: %v", dir, err)
+		return res
+	}
+	home, err := ioutil.TempDir("", "recon-replay-home-")
+	if err != nil {
+		drift("cannot create scratch HOME: %v", err)
+		return res
+	}
+	defer os.RemoveAll(home)
+	if rec.StdinSHA != "" {
+		// Stdin is only hashed, so the command re-runs without its input.
+		drift("recorded stdin not replayable; command re-run with empty input")
+	}
+	args := make([]string, len(rec.Args))
+	for i, a := range rec.Args {
+		args[i] = reroot(a, from, outDir)
+	}
+	cmd := exec.Command(rec.Tool, args...)
+	cmd.Dir = dir
+	cmd.Env = []string{"HOME=" + home}
+	for _, name := range rec.Env {
+		if v, ok := os.LookupEnv(name); ok && name != "HOME" {
+			cmd.Env = append(cmd.Env, name+"="+v)
+		}
+	}
+	out, _ := cmd.CombinedOutput()
+	if code := cmd.ProcessState.ExitCode(); code != rec.ExitCode {
+		drift("exit code %d, recorded %d", code, rec.ExitCode)
+	}
+	sum := sha256.Sum256(out)
+	if hex.EncodeToString(sum[:]) != rec.OutputSHA {
+		drift("output hash differs (expected for tools querying live data)")
+	}
+	return res
+}
+
+// copyReplayInput copies an input file of a replayed command to dst.
+func copyReplayInput(src, dst string) error {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, data, 0644)
+}
+
+// WriteReplayReport writes the replay verdicts as Markdown. Secrets are
+// redacted again, for manifests recorded before they were redacted at
+// record time.
+func WriteReplayReport(path string, results []ReplayResult, execute bool) error {
+	var b strings.Builder
+	mode := "plan-only (tools and inputs checked, nothing executed)"
+	if execute {
+		mode = "verify (commands re-executed)"
+	}
+	counts := make(map[string]int)
+	for _, r := range results {
+		counts[r.Status]++
+	}
+	b.WriteString("# Replay report\n\n")
+	b.WriteString("Mode: " + mode + "\n\n")
+	b.WriteString(fmt.Sprintf("%d command(s): %d identical, %d drifted, %d missing tool\n\n",
+		len(results), counts["identical"], counts["drifted"], counts["missing-tool"]))
+	b.WriteString("| # | Tool | Status | Notes |\n|---|------|--------|-------|\n")
+	for i, r := range results {
+		cmdline := strings.ReplaceAll(r.Record.Tool+" "+strings.Join(r.Record.Args, " "), "|", "\\|")
+		b.WriteString(fmt.Sprintf("| %d | `%s` | %s | %s |\n", i+1, cmdline, r.Status, strings.Join(r.Notes, "; ")))
+	}
+	return ioutil.WriteFile(path, Redact([]byte(b.String()), SecretValues()), 0644)
+}
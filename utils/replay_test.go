@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestReroot(t *testing.T) {
+	from, to := filepath.FromSlash("/runs/a"), filepath.FromSlash("/runs/a/replay_1")
+	tests := []struct{ in, want string }{
+		{"/runs/a", "/runs/a/replay_1"},
+		{"/runs/a/ffuf.json", "/runs/a/replay_1/ffuf.json"},
+		{"--output=/runs/a/out.txt", "--output=/runs/a/replay_1/out.txt"},
+		{"/runs/a/words.txt:FUZZ", "/runs/a/replay_1/words.txt:FUZZ"},
+		{"/runs/ab/other.txt", "/runs/ab/other.txt"},
+		{"https://example.com/runs/a", "https://example.com/runs/a"},
+		{"-silent", "-silent"},
+	}
+	for _, tt := range tests {
+		if got := reroot(filepath.FromSlash(tt.in), from, to); got != filepath.FromSlash(tt.want) {
+			t.Errorf("reroot(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if got := reroot("/runs/a/x", "", to); got != "/runs/a/x" {
+		t.Errorf("reroot without a run directory = %q", got)
+	}
+}
+
+// TestReplayCommandVerifyKeepsArtifacts re-executes a recorded command that
+// reads an input and writes an output in its run directory, and checks that
+// the output lands in the replay directory and the original is untouched.
+func TestReplayCommandVerifyKeepsArtifacts(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	runDir := t.TempDir()
+	input := filepath.Join(runDir, "targets.txt")
+	output := filepath.Join(runDir, "out.txt")
+	if err := ioutil.WriteFile(input, []byte("example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(output, []byte("original\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	inputSHA, _ := HashFile(input)
+	rec := CommandRecord{
+		Tool:   "sh",
+		Args:   []string{"-c", `cat "$0" > "$1"`, input, output},
+		Dir:    filepath.Join(runDir, "raw", "sh"),
+		Inputs: map[string]string{input: inputSHA},
+	}
+
+	if res := ReplayCommand(rec, runDir, ""); res.Status != "identical" {
+		t.Errorf("plan-only: %s %v", res.Status, res.Notes)
+	}
+	replayDir := filepath.Join(runDir, "replay_1")
+	res := ReplayCommand(rec, runDir, replayDir)
+	for _, n := range res.Notes {
+		if n != "output hash differs (expected for tools querying live data)" {
+			t.Errorf("verify: unexpected note %q", n)
+		}
+	}
+	if data, _ := ioutil.ReadFile(output); string(data) != "original\n" {
+		t.Errorf("original output overwritten: %q", data)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(replayDir, "out.txt")); err != nil || string(data) != "example.com\n" {
+		t.Errorf("replayed output = %q, %v", data, err)
+	}
+}
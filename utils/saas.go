@@ -0,0 +1,92 @@
+package utils
+
+import "strings"
+
+// txtToken maps the prefix of a domain verification TXT record to the
+// service that asked for it.
+type txtToken struct {
+	Prefix  string
+	Service string
+}
+
+var txtTokens = []txtToken{
+	{"google-site-verification=", "Google Workspace / Search Console"},
+	{"ms=", "Microsoft 365"},
+	{"facebook-domain-verification=", "Meta Business"},
+	{"apple-domain-verification=", "Apple"},
+	{"atlassian-domain-verification=", "Atlassian"},
+	{"docusign=", "DocuSign"},
+	{"adobe-idp-site-verification=", "Adobe"},
+	{"adobe-sign-verification=", "Adobe Acrobat Sign"},
+	{"amazonses:", "Amazon SES"},
+	{"stripe-verification=", "Stripe"},
+	{"zoom_verify_", "Zoom"},
+	{"slack-domain-verification=", "Slack"},
+	{"dropbox-domain-verification=", "Dropbox"},
+	{"cisco-ci-domain-verification=", "Cisco Webex"},
+	{"globalsign-domain-verification=", "GlobalSign"},
+	{"_globalsign-domain-verification=", "GlobalSign"},
+	{"knowbe4-site-verification=", "KnowBe4"},
+	{"miro-verification=", "Miro"},
+	{"onetrust-domain-verification=", "OneTrust"},
+	{"logmein-verification-code=", "LogMeIn"},
+	{"citrix-verification-code=", "Citrix"},
+	{"twilio-domain-verification=", "Twilio"},
+	{"openai-domain-verification=", "OpenAI"},
+	{"yandex-verification:", "Yandex"},
+	{"have-i-been-pwned-verification=", "Have I Been Pwned"},
+}
+
+// spfInclude maps the domain of an SPF include: mechanism to the mail
+// service it authorizes.
+type spfInclude struct {
+	Domain  string
+	Service string
+}
+
+var spfIncludes = []spfInclude{
+	{"_spf.google.com", "Google Workspace"},
+	{"spf.protection.outlook.com", "Microsoft 365"},
+	{"amazonses.com", "Amazon SES"},
+	{"sendgrid.net", "SendGrid"},
+	{"mailgun.org", "Mailgun"},
+	{"servers.mcsv.net", "Mailchimp"},
+	{"spf.mandrillapp.com", "Mailchimp Transactional"},
+	{"_spf.salesforce.com", "Salesforce"},
+	{"mail.zendesk.com", "Zendesk"},
+	{"mktomail.com", "Marketo"},
+	{"spf.mtasv.net", "Postmark"},
+	{"_spf.atlassian.net", "Atlassian"},
+	{"zoho.com", "Zoho Mail"},
+}
+
+// ClassifyTXT returns the third-party services a DNS TXT record reveals:
+// the service behind a domain verification token, or the mail services an
+// SPF record includes. Matching ignores case; an SPF include matches its
+// domain and any subdomain of it.
+func ClassifyTXT(record string) []string {
+	rec := strings.ToLower(strings.Trim(strings.TrimSpace(record), `"`))
+	if strings.HasPrefix(rec, "v=spf1 ") {
+		var services []string
+		for _, f := range strings.Fields(rec) {
+			f = strings.TrimLeft(f, "+~?")
+			if !strings.HasPrefix(f, "include:") {
+				continue
+			}
+			domain := strings.TrimPrefix(f, "include:")
+			for _, s := range spfIncludes {
+				if domain == s.Domain || strings.HasSuffix(domain, "."+s.Domain) {
+					services = append(services, s.Service)
+					break
+				}
+			}
+		}
+		return UniqueStrings(services)
+	}
+	for _, t := range txtTokens {
+		if strings.HasPrefix(rec, t.Prefix) {
+			return []string{t.Service}
+		}
+	}
+	return nil
+}
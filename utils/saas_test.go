@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClassifyTXT(t *testing.T) {
+	tests := []struct {
+		record string
+		want   []string
+	}{
+		{"google-site-verification=rXOxyZounnZasA8Z7oaD3c14JdjS9aKSWvsR1EbUSIQ", []string{"Google Workspace / Search Console"}},
+		{`"MS=ms12345678"`, []string{"Microsoft 365"}},
+		{"v=spf1 include:_spf.google.com include:eu.mailgun.org ~include:sendgrid.net -all", []string{"Google Workspace", "Mailgun", "SendGrid"}},
+		// Same service twice, and an include of an unknown sender.
+		{"v=spf1 include:spf.protection.outlook.com include:mail.example.net include:spf.protection.outlook.com -all", []string{"Microsoft 365"}},
+		// A lookalike domain is not a subdomain.
+		{"v=spf1 include:evilsendgrid.net -all", nil},
+		{"v=DMARC1; p=reject; rua=mailto:dmarc@example.com", nil},
+		{"some-unknown-verification=abc", nil},
+	}
+	for _, tt := range tests {
+		if got := ClassifyTXT(tt.record); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ClassifyTXT(%q) = %q, want %q", tt.record, got, tt.want)
+		}
+	}
+}
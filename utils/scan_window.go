@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScanWindow is the daily time range in which aggressive stages may run,
+// in the target's time zone. Start and End are minutes after midnight; a
+// window with End before Start crosses midnight.
+type ScanWindow struct {
+	Start, End int
+	Loc        *time.Location
+}
+
+// parseClock parses an "HH:MM" wall-clock time into minutes after midnight.
+func parseClock(s string) (int, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), ":", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("%q: want HH:MM", s)
+	}
+	h, err1 := strconv.Atoi(parts[0])
+	m, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("%q: want HH:MM", s)
+	}
+	return h*60 + m, nil
+}
+
+// ScanWindowFromEnv reads SCAN_WINDOW ("22:00-06:00") and SCAN_WINDOW_TZ,
+// the target's IANA time zone (default: local time). It returns nil when
+// no window is configured.
+func ScanWindowFromEnv() (*ScanWindow, error) {
+	raw := strings.TrimSpace(os.Getenv("SCAN_WINDOW"))
+	if raw == "" {
+		return nil, nil
+	}
+	bounds := strings.SplitN(raw, "-", 2)
+	if len(bounds) != 2 {
+		return nil, fmt.Errorf("scan window %q: want HH:MM-HH:MM", raw)
+	}
+	start, err := parseClock(bounds[0])
+	if err != nil {
+		return nil, fmt.Errorf("scan window start %v", err)
+	}
+	end, err := parseClock(bounds[1])
+	if err != nil {
+		return nil, fmt.Errorf("scan window end %v", err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("scan window %q is empty", raw)
+	}
+	loc := time.Local
+	if tz := strings.TrimSpace(os.Getenv("SCAN_WINDOW_TZ")); tz != "" {
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("scan window time zone: %v", err)
+		}
+	}
+	return &ScanWindow{Start: start, End: end, Loc: loc}, nil
+}
+
+// Open reports whether t falls within the window.
+func (w *ScanWindow) Open(t time.Time) bool {
+	t = t.In(w.Loc)
+	m := t.Hour()*60 + t.Minute()
+	if w.Start < w.End {
+		return m >= w.Start && m < w.End
+	}
+	return m >= w.Start || m < w.End
+}
+
+// NextOpen returns when the window next opens after t, or t itself when it
+// is open. Opening times are built from the wall clock in w.Loc, so they
+// stay put across DST changes.
+func (w *ScanWindow) NextOpen(t time.Time) time.Time {
+	if w.Open(t) {
+		return t
+	}
+	local := t.In(w.Loc)
+	y, mo, d := local.Date()
+	next := time.Date(y, mo, d, w.Start/60, w.Start%60, 0, 0, w.Loc)
+	if !next.After(t) {
+		next = time.Date(y, mo, d+1, w.Start/60, w.Start%60, 0, 0, w.Loc)
+	}
+	return next
+}
+
+// String renders the window as "22:00-06:00 Europe/Oslo".
+func (w *ScanWindow) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d %s", w.Start/60, w.Start%60, w.End/60, w.End%60, w.Loc)
+}
+
+// Clock is the time source the scan window gate waits on; tests replace it.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// realClock is the wall clock.
+type realClock struct{}
+
+func (realClock) Now() time.Time        { return time.Now() }
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// WindowPause is one wait for the scan window: the stage that waited, what
+// it was about to run (a tool, or empty for a worker's next job), when the
+// wait began, when the window was projected to open and when it did.
+type WindowPause struct {
+	Window  string
+	Stage   string
+	Before  string
+	Since   time.Time
+	Resume  time.Time
+	Resumed time.Time
+}
+
+// String describes the pause for stage statuses and the scan log.
+func (p WindowPause) String() string {
+	what := p.Stage
+	if p.Before != "" && p.Before != p.Stage {
+		what += " before " + p.Before
+	}
+	if p.Resumed.IsZero() {
+		return fmt.Sprintf("%s paused outside the scan window %s since %s; resuming at %s",
+			what, p.Window, p.Since.Format(time.RFC3339), p.Resume.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%s paused outside the scan window %s from %s to %s",
+		what, p.Window, p.Since.Format(time.RFC3339), p.Resumed.Format(time.RFC3339))
+}
+
+// Wait blocks until w is open on clock and returns the pause, or nil when
+// w was already open. onPause is called once when the wait begins. The
+// clock is polled at least every minute rather than slept through, since it
+// may jump while the machine is suspended.
+func (w *ScanWindow) Wait(clock Clock, onPause func(WindowPause)) *WindowPause {
+	now := clock.Now()
+	if w.Open(now) {
+		return nil
+	}
+	p := WindowPause{Window: w.String(), Since: now, Resume: w.NextOpen(now)}
+	if onPause != nil {
+		onPause(p)
+	}
+	for ; !w.Open(now); now = clock.Now() {
+		d := w.NextOpen(now).Sub(now)
+		if d <= 0 || d > time.Minute {
+			d = time.Minute
+		}
+		clock.Sleep(d)
+	}
+	p.Resumed = now
+	return &p
+}
+
+// The scan window gate. While an aggressive stage runs, the window it must
+// keep to is set here and every tool invocation and worker-pool job waits
+// for it, so a stage that outlasts the window pauses between tools instead
+// of running on.
+var (
+	windowMu     sync.Mutex
+	windowActive *ScanWindow
+	windowStage  string
+	windowLog    func(string)
+	windowClock  Clock = realClock{}
+	windowPause  *WindowPause
+	windowPauses []WindowPause
+)
+
+// EnterScanWindow makes WaitScanWindow keep stage within w, logging pauses
+// to logFn, until LeaveScanWindow.
+func EnterScanWindow(w *ScanWindow, stage string, logFn func(string)) {
+	windowMu.Lock()
+	windowActive, windowStage, windowLog = w, stage, logFn
+	windowMu.Unlock()
+}
+
+// LeaveScanWindow ends the stage EnterScanWindow began.
+func LeaveScanWindow() {
+	windowMu.Lock()
+	windowActive = nil
+	windowMu.Unlock()
+}
+
+// WaitScanWindow blocks while the running aggressive stage is outside its
+// window. before names what the caller is about to run. Concurrent callers
+// share one pause: the first logs it and the first to resume records it.
+func WaitScanWindow(before string) {
+	windowMu.Lock()
+	w, stage, logFn, clock := windowActive, windowStage, windowLog, windowClock
+	windowMu.Unlock()
+	if w == nil {
+		return
+	}
+	p := w.Wait(clock, func(p WindowPause) {
+		p.Stage, p.Before = stage, before
+		windowMu.Lock()
+		defer windowMu.Unlock()
+		if windowPause == nil {
+			windowPause = &p
+			logFn("[*] Paused: " + p.String())
+		}
+	})
+	if p == nil {
+		return
+	}
+	windowMu.Lock()
+	defer windowMu.Unlock()
+	if windowPause != nil {
+		windowPause.Resumed = p.Resumed
+		windowPauses = append(windowPauses, *windowPause)
+		logFn("[*] Scan window open: resuming " + stage)
+		windowPause = nil
+	}
+}
+
+// ScanWindowPause returns the pause in progress, or nil.
+func ScanWindowPause() *WindowPause {
+	windowMu.Lock()
+	defer windowMu.Unlock()
+	if windowPause == nil {
+		return nil
+	}
+	p := *windowPause
+	return &p
+}
+
+// TakeScanWindowPauses returns the pauses that ended since the last call
+// and forgets them, so each stage reports its own.
+func TakeScanWindowPauses() []WindowPause {
+	windowMu.Lock()
+	defer windowMu.Unlock()
+	p := windowPauses
+	windowPauses = nil
+	return p
+}
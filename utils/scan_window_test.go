@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose Sleep advances Now instead of waiting; jump,
+// when set, is added on the first Sleep, as if the machine was suspended.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	jump   time.Duration
+	sleeps int
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d + c.jump)
+	c.jump = 0
+	c.sleeps++
+}
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s unavailable: %v", name, err)
+	}
+	return loc
+}
+
+func TestScanWindowFromEnv(t *testing.T) {
+	tests := []struct {
+		window, tz string
+		want       string
+		wantErr    bool
+	}{
+		{"", "", "", false},
+		{"22:00-06:00", "UTC", "22:00-06:00 UTC", false},
+		{" 09:30 - 17:00 ", "Europe/Oslo", "09:30-17:00 Europe/Oslo", false},
+		{"22:00", "", "", true},
+		{"24:00-06:00", "", "", true},
+		{"10:00-10:00", "", "", true},
+		{"22:00-06:00", "Mars/Olympus", "", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("SCAN_WINDOW", tt.window)
+		t.Setenv("SCAN_WINDOW_TZ", tt.tz)
+		w, err := ScanWindowFromEnv()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q %q: err = %v, want error %v", tt.window, tt.tz, err, tt.wantErr)
+			continue
+		}
+		got := ""
+		if w != nil {
+			got = w.String()
+		}
+		if got != tt.want {
+			t.Errorf("%q %q: window %q, want %q", tt.window, tt.tz, got, tt.want)
+		}
+	}
+}
+
+func TestScanWindowOpenNextOpen(t *testing.T) {
+	oslo := mustLocation(t, "Europe/Oslo")
+	at := func(y int, mo time.Month, d, h, m int) time.Time { return time.Date(y, mo, d, h, m, 0, 0, oslo) }
+	night := &ScanWindow{Start: 22 * 60, End: 6 * 60, Loc: oslo}
+	early := &ScanWindow{Start: 2*60 + 30, End: 4 * 60, Loc: oslo}
+	tests := []struct {
+		name     string
+		w        *ScanWindow
+		t        time.Time
+		open     bool
+		nextOpen time.Time
+	}{
+		{"before midnight", night, at(2026, 10, 15, 23, 30), true, at(2026, 10, 15, 23, 30)},
+		{"after midnight", night, at(2026, 10, 16, 5, 59), true, at(2026, 10, 16, 5, 59)},
+		{"end is exclusive", night, at(2026, 10, 16, 6, 0), false, at(2026, 10, 16, 22, 0)},
+		{"start is inclusive", night, at(2026, 10, 16, 22, 0), true, at(2026, 10, 16, 22, 0)},
+		{"afternoon", night, at(2026, 10, 16, 14, 0), false, at(2026, 10, 16, 22, 0)},
+		// The clocks go back at 03:00 CEST on 2026-10-25.
+		{"fall back day", night, at(2026, 10, 25, 12, 0), false, at(2026, 10, 25, 22, 0)},
+		{"fall back, first 02:45", early, time.Date(2026, 10, 25, 0, 45, 0, 0, time.UTC), true, time.Date(2026, 10, 25, 0, 45, 0, 0, time.UTC)},
+		{"fall back, second 02:45", early, time.Date(2026, 10, 25, 1, 45, 0, 0, time.UTC), true, time.Date(2026, 10, 25, 1, 45, 0, 0, time.UTC)},
+		{"fall back, after the window", early, time.Date(2026, 10, 25, 3, 0, 0, 0, time.UTC), false, at(2026, 10, 26, 2, 30)},
+		// The clocks go forward at 02:00 CET on 2026-03-29: 02:30 does not
+		// exist and normalizes to 03:30 CEST, which is inside the window.
+		{"spring forward, skipped start", early, at(2026, 3, 29, 1, 0), false, time.Date(2026, 3, 29, 1, 30, 0, 0, time.UTC)},
+		{"spring forward day", night, at(2026, 3, 29, 12, 0), false, at(2026, 3, 29, 22, 0)},
+	}
+	for _, tt := range tests {
+		if got := tt.w.Open(tt.t); got != tt.open {
+			t.Errorf("%s: Open(%s) = %v, want %v", tt.name, tt.t, got, tt.open)
+		}
+		got := tt.w.NextOpen(tt.t)
+		if !got.Equal(tt.nextOpen) {
+			t.Errorf("%s: NextOpen(%s) = %s, want %s", tt.name, tt.t, got, tt.nextOpen)
+		}
+		if !tt.w.Open(got) {
+			t.Errorf("%s: window closed at NextOpen %s", tt.name, got)
+		}
+	}
+}
+
+func TestScanWindowWait(t *testing.T) {
+	oslo := mustLocation(t, "Europe/Oslo")
+	at := func(y int, mo time.Month, d, h, m int) time.Time { return time.Date(y, mo, d, h, m, 0, 0, oslo) }
+	tests := []struct {
+		name    string
+		w       *ScanWindow
+		start   time.Time
+		jump    time.Duration
+		resumed time.Time // zero when the window is open at start
+	}{
+		{"open across midnight", &ScanWindow{Start: 23 * 60, End: 60, Loc: oslo}, at(2026, 10, 16, 0, 30), 0, time.Time{}},
+		{"closed, opens before midnight", &ScanWindow{Start: 23 * 60, End: 60, Loc: oslo}, at(2026, 10, 16, 1, 30), 0, at(2026, 10, 16, 23, 0)},
+		{"closed, opens after midnight", &ScanWindow{Start: 60, End: 5 * 60, Loc: oslo}, at(2026, 10, 15, 22, 0), 0, at(2026, 10, 16, 1, 0)},
+		{"suspended past the start", &ScanWindow{Start: 22 * 60, End: 6 * 60, Loc: oslo}, at(2026, 10, 15, 21, 0), 2 * time.Hour, at(2026, 10, 15, 23, 1)},
+		{"across spring forward", &ScanWindow{Start: 22 * 60, End: 6 * 60, Loc: oslo}, at(2026, 3, 28, 12, 0), 0, at(2026, 3, 28, 22, 0)},
+		{"through the fall back night", &ScanWindow{Start: 4 * 60, End: 5 * 60, Loc: oslo}, at(2026, 10, 24, 23, 0), 0, at(2026, 10, 25, 4, 0)},
+	}
+	for _, tt := range tests {
+		clock := &fakeClock{now: tt.start, jump: tt.jump}
+		var notified int
+		p := tt.w.Wait(clock, func(WindowPause) { notified++ })
+		if tt.resumed.IsZero() {
+			if p != nil || notified != 0 || clock.sleeps != 0 {
+				t.Errorf("%s: paused %v (%d notifications, %d sleeps) in an open window", tt.name, p, notified, clock.sleeps)
+			}
+			continue
+		}
+		if p == nil {
+			t.Errorf("%s: no pause", tt.name)
+			continue
+		}
+		if notified != 1 {
+			t.Errorf("%s: %d notifications, want 1", tt.name, notified)
+		}
+		if !p.Resumed.Equal(tt.resumed) {
+			t.Errorf("%s: resumed %s, want %s", tt.name, p.Resumed, tt.resumed)
+		}
+		if tt.jump == 0 && !p.Resume.Equal(tt.resumed) {
+			t.Errorf("%s: projected resume %s, want %s", tt.name, p.Resume, tt.resumed)
+		}
+		// Sleeps are capped at a minute, so the waits poll.
+		if limit := int(tt.resumed.Sub(tt.start)/time.Minute) + 1; clock.sleeps > limit {
+			t.Errorf("%s: %d sleeps, want at most %d", tt.name, clock.sleeps, limit)
+		}
+	}
+}
+
+func TestWaitScanWindowSharesPause(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)}
+	windowMu.Lock()
+	saved := windowClock
+	windowClock = clock
+	windowMu.Unlock()
+	defer func() {
+		windowMu.Lock()
+		windowClock = saved
+		windowMu.Unlock()
+	}()
+
+	var (
+		logMu sync.Mutex
+		logs  []string
+	)
+	logFn := func(line string) {
+		logMu.Lock()
+		logs = append(logs, line)
+		logMu.Unlock()
+	}
+	w := &ScanWindow{Start: 22 * 60, End: 6 * 60, Loc: time.UTC}
+	EnterScanWindow(w, "nuclei scanning", logFn)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			WaitScanWindow("nuclei")
+		}()
+	}
+	wg.Wait()
+	LeaveScanWindow()
+
+	pauses := TakeScanWindowPauses()
+	if len(pauses) != 1 {
+		t.Fatalf("%d pauses recorded, want 1: %v", len(pauses), pauses)
+	}
+	p := pauses[0]
+	if p.Stage != "nuclei scanning" || p.Before != "nuclei" || !w.Open(p.Resumed) || p.Resumed.Before(p.Since) {
+		t.Errorf("pause = %+v", p)
+	}
+	if !strings.Contains(p.String(), "nuclei scanning before nuclei paused outside the scan window 22:00-06:00 UTC from ") {
+		t.Errorf("pause text %q", p.String())
+	}
+	if len(logs) != 2 || !strings.HasPrefix(logs[0], "[*] Paused: ") || !strings.Contains(logs[0], "resuming at 2026-10-15T22:00:00Z") {
+		t.Errorf("logs = %q", logs)
+	}
+	if ScanWindowPause() != nil || len(TakeScanWindowPauses()) != 0 {
+		t.Error("pause state left behind")
+	}
+	// Outside an aggressive stage nothing waits.
+	clock.now = time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	WaitScanWindow("subfinder")
+	if len(TakeScanWindowPauses()) != 0 {
+		t.Error("paused outside an aggressive stage")
+	}
+}
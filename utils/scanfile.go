@@ -0,0 +1,541 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// ScanFileError lists every problem found in a scan definition, in the
+// order of the lines they are on.
+type ScanFileError struct {
+	File     string
+	Problems []ScanFileProblem
+}
+
+// ScanFileProblem is one problem in a scan definition; Line is 0 when it
+// has no single place in the file.
+type ScanFileProblem struct {
+	Line    int
+	Message string
+}
+
+func (p ScanFileProblem) String() string {
+	if p.Line == 0 {
+		return p.Message
+	}
+	return fmt.Sprintf("line %d: %s", p.Line, p.Message)
+}
+
+func (e *ScanFileError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = "  " + p.String()
+	}
+	return fmt.Sprintf("scan definition %s is invalid:\n%s", e.File, strings.Join(lines, "\n"))
+}
+
+// scanFileSettings are the settings the sections of a scan definition set;
+// its settings section may not set them as well.
+var scanFileSettings = map[string]string{
+	"SCOPE_ALLOW":        "scope.allow",
+	"SCOPE_DENY":         "scope.deny",
+	"SCOPE_DENY_TAGS":    "scope.deny_tags",
+	"SEVERITY_FROM_CVSS": "severity.from_cvss",
+	"NUCLEI_SEVERITY":    "severity.nuclei",
+}
+
+// scanFileSeverities are the severities a scan definition may name.
+var scanFileSeverities = []string{"critical", "high", "medium", "low", "info"}
+
+var (
+	interpolationRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	settingNameRe   = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+)
+
+// ScanFile is a scan definition read from a scan.yaml. Definition is the
+// file as written, with its ${VAR} references; Resolve interpolates them.
+type ScanFile struct {
+	Definition *types.ScanDefinition
+	root       *yaml.Node
+}
+
+// ReadScanFile reads the scan definition at file. Keys the format does not
+// know and values of the wrong kind are reported together as a
+// *ScanFileError, with the likely intended key where there is one. The
+// profile is read before any settings are loaded, so it cannot reference
+// a variable.
+func ReadScanFile(file string) (*ScanFile, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	fail := func(problems ...ScanFileProblem) (*ScanFile, error) {
+		sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+		return nil, &ScanFileError{File: file, Problems: problems}
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fail(ScanFileProblem{Message: strings.TrimPrefix(err.Error(), "yaml: ")})
+	}
+	if len(doc.Content) == 0 {
+		return fail(ScanFileProblem{Message: "the file is empty"})
+	}
+	root := doc.Content[0]
+	var problems []ScanFileProblem
+	checkScanFileKeys(root, reflect.TypeOf(types.ScanDefinition{}), "", &problems)
+	if len(problems) > 0 {
+		return fail(problems...)
+	}
+	def := &types.ScanDefinition{}
+	if err := root.Decode(def); err != nil {
+		return fail(decodeProblems(err)...)
+	}
+	if p := mappingValue(root, "profile"); p != nil && interpolationRe.MatchString(p.Value) {
+		return fail(ScanFileProblem{p.Line, "profile is read before the settings are loaded and cannot reference a variable"})
+	}
+	sum := sha256.Sum256(data)
+	def.File = file
+	def.SHA256 = hex.EncodeToString(sum[:])
+	return &ScanFile{Definition: def, root: root}, nil
+}
+
+// Resolve returns the definition with every ${VAR} replaced by the
+// variable's value. Unset variables, stages not in stages, unregistered
+// issue types and other invalid values are reported together as a
+// *ScanFileError, suggesting the name meant where there is one close.
+func (f *ScanFile) Resolve(stages []string) (*types.ScanDefinition, error) {
+	// Interpolate a copy; Definition keeps the references.
+	root := copyNode(f.root, make(map[*yaml.Node]*yaml.Node))
+	var problems []ScanFileProblem
+	interpolateScanFile(root, &problems)
+	def := &types.ScanDefinition{}
+	if len(problems) == 0 {
+		if err := root.Decode(def); err != nil {
+			problems = append(problems, decodeProblems(err)...)
+		}
+	}
+	if len(problems) == 0 {
+		checkScanFile(root, stages, &problems)
+	}
+	if len(problems) > 0 {
+		sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+		return nil, &ScanFileError{File: f.Definition.File, Problems: problems}
+	}
+	def.File, def.SHA256 = f.Definition.File, f.Definition.SHA256
+	return def, nil
+}
+
+// copyNode returns a deep copy of n. copies maps the nodes copied so far
+// to their copy, so aliases point into the copy as well.
+func copyNode(n *yaml.Node, copies map[*yaml.Node]*yaml.Node) *yaml.Node {
+	if c, ok := copies[n]; ok {
+		return c
+	}
+	c := *n
+	copies[n] = &c
+	c.Content = make([]*yaml.Node, len(n.Content))
+	for i, child := range n.Content {
+		c.Content[i] = copyNode(child, copies)
+	}
+	if n.Alias != nil {
+		c.Alias = copyNode(n.Alias, copies)
+	}
+	return &c
+}
+
+// decodeProblems splits a decoding error into its per-line problems.
+func decodeProblems(err error) []ScanFileProblem {
+	te, ok := err.(*yaml.TypeError)
+	if !ok {
+		return []ScanFileProblem{{Message: strings.TrimPrefix(err.Error(), "yaml: ")}}
+	}
+	var problems []ScanFileProblem
+	for _, msg := range te.Errors {
+		var p ScanFileProblem
+		if n, _ := fmt.Sscanf(msg, "line %d: ", &p.Line); n == 1 {
+			msg = msg[strings.Index(msg, ": ")+2:]
+		}
+		p.Message = msg
+		problems = append(problems, p)
+	}
+	return problems
+}
+
+// checkScanFileKeys reports the keys of n that t, the Go type n decodes
+// into, has no field for. where is the dotted path of n in the file.
+func checkScanFileKeys(n *yaml.Node, t reflect.Type, where string, problems *[]ScanFileProblem) {
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			return // Decoding reports the type.
+		}
+		fields := make(map[string]reflect.Type)
+		var names []string
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			fields[name] = t.Field(i).Type
+			names = append(names, name)
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i]
+			ft, ok := fields[key.Value]
+			if ok {
+				checkScanFileKeys(n.Content[i+1], ft, joinKey(where, key.Value), problems)
+				continue
+			}
+			msg := fmt.Sprintf("unknown key %q", key.Value)
+			if where != "" {
+				msg += " in " + where
+			}
+			if s := suggest(key.Value, names); s != "" {
+				msg += fmt.Sprintf("; did you mean %q?", s)
+			} else {
+				msg += "; expected one of " + strings.Join(names, ", ")
+			}
+			*problems = append(*problems, ScanFileProblem{key.Line, msg})
+		}
+	case reflect.Slice:
+		if n.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range n.Content {
+			checkScanFileKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", where, i), problems)
+		}
+	}
+}
+
+func joinKey(where, key string) string {
+	if where == "" {
+		return key
+	}
+	return where + "." + key
+}
+
+// interpolateScanFile replaces the ${VAR} references in the values under n
+// with the variables' values. Keys are left alone.
+func interpolateScanFile(n *yaml.Node, problems *[]ScanFileProblem) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		n.Value = interpolationRe.ReplaceAllStringFunc(n.Value, func(ref string) string {
+			name := interpolationRe.FindStringSubmatch(ref)[1]
+			v, ok := os.LookupEnv(name)
+			if !ok {
+				*problems = append(*problems, ScanFileProblem{n.Line, fmt.Sprintf("%s is not set in the environment", ref)})
+			}
+			return v
+		})
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			interpolateScanFile(n.Content[i], problems)
+		}
+	case yaml.SequenceNode, yaml.DocumentNode:
+		for _, c := range n.Content {
+			interpolateScanFile(c, problems)
+		}
+	}
+}
+
+// mappingValue returns the value of key in the mapping n, or nil.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// sequenceItems returns the items of the sequence n, or nil.
+func sequenceItems(n *yaml.Node) []*yaml.Node {
+	if n == nil || n.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return n.Content
+}
+
+// checkScanFile checks the values of the decoded definition root: the
+// targets, stage names, severities, issue types, suppressions, webhooks
+// and settings.
+func checkScanFile(root *yaml.Node, stages []string, problems *[]ScanFileProblem) {
+	add := func(n *yaml.Node, format string, args ...interface{}) {
+		*problems = append(*problems, ScanFileProblem{n.Line, fmt.Sprintf(format, args...)})
+	}
+	severity := func(n *yaml.Node, where string) {
+		if !containsString(scanFileSeverities, strings.ToLower(n.Value)) {
+			add(n, "%s %q is not one of %s", where, n.Value, strings.Join(scanFileSeverities, ", "))
+		}
+	}
+	issueType := func(n *yaml.Node, where string) {
+		if _, ok := LookupIssueType(n.Value); ok {
+			return
+		}
+		msg := fmt.Sprintf("%s %q is not a registered issue type", where, n.Value)
+		if s := suggest(n.Value, issueTypeIDs()); s != "" {
+			msg += fmt.Sprintf("; did you mean %q?", s)
+		}
+		add(n, "%s", msg)
+	}
+
+	targets := sequenceItems(mappingValue(root, "targets"))
+	if len(targets) == 0 {
+		*problems = append(*problems, ScanFileProblem{root.Line, "targets: at least one target is required"})
+	}
+	for _, t := range targets {
+		if t.Value == "" || NormalizeHostname(t.Value) != strings.ToLower(t.Value) {
+			add(t, "target %q is not a domain name", t.Value)
+		}
+	}
+
+	stageSection := mappingValue(root, "stages")
+	skip, only := mappingValue(stageSection, "skip"), mappingValue(stageSection, "only")
+	if len(sequenceItems(skip)) > 0 && len(sequenceItems(only)) > 0 {
+		add(only, "stages.skip and stages.only cannot both be set")
+	}
+	for _, list := range []struct {
+		name  string
+		items []*yaml.Node
+	}{{"stages.skip", sequenceItems(skip)}, {"stages.only", sequenceItems(only)}} {
+		for _, n := range list.items {
+			if containsString(stages, n.Value) {
+				continue
+			}
+			msg := fmt.Sprintf("unknown stage %q in %s", n.Value, list.name)
+			if s := suggest(n.Value, stages); s != "" {
+				msg += fmt.Sprintf("; did you mean %q?", s)
+			}
+			add(n, "%s", msg)
+		}
+	}
+
+	sev := mappingValue(root, "severity")
+	for _, n := range sequenceItems(mappingValue(sev, "nuclei")) {
+		severity(n, "severity.nuclei:")
+	}
+	if overrides := mappingValue(sev, "overrides"); overrides != nil && overrides.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(overrides.Content); i += 2 {
+			issueType(overrides.Content[i], "severity.overrides:")
+			severity(overrides.Content[i+1], "severity.overrides:")
+		}
+	}
+
+	for i, s := range sequenceItems(mappingValue(root, "suppressions")) {
+		where := fmt.Sprintf("suppressions[%d]", i)
+		typ, host, u := mappingValue(s, "type"), mappingValue(s, "host"), mappingValue(s, "url")
+		if typ == nil && host == nil && u == nil {
+			add(s, "%s matches every finding; set type, host or url", where)
+		}
+		if typ != nil {
+			issueType(typ, where+".type:")
+		}
+		if host != nil {
+			if _, err := path.Match(host.Value, ""); err != nil {
+				add(host, "%s.host %q is not a valid glob", where, host.Value)
+			}
+		}
+		if r := mappingValue(s, "reason"); r == nil || strings.TrimSpace(r.Value) == "" {
+			add(s, "%s needs a reason", where)
+		}
+	}
+
+	for i, r := range sequenceItems(mappingValue(root, "notify")) {
+		where := fmt.Sprintf("notify[%d]", i)
+		if hook := mappingValue(r, "webhook"); hook == nil {
+			add(r, "%s needs a webhook", where)
+		} else if u, err := url.Parse(hook.Value); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			// The value may be an interpolated secret; keep it out of the message.
+			add(hook, "%s.webhook is not an http(s) URL", where)
+		}
+		if min := mappingValue(r, "min_severity"); min != nil {
+			severity(min, where+".min_severity:")
+		}
+		for _, t := range sequenceItems(mappingValue(r, "types")) {
+			issueType(t, where+".types:")
+		}
+	}
+
+	if settings := mappingValue(root, "settings"); settings != nil && settings.Kind == yaml.MappingNode {
+		for i := 0; i < len(settings.Content); i += 2 {
+			key := settings.Content[i]
+			if section, ok := scanFileSettings[key.Value]; ok {
+				add(key, "settings.%s is set by %s; set it there instead", key.Value, section)
+			} else if !settingNameRe.MatchString(key.Value) {
+				add(key, "settings.%s is not a setting name (UPPER_CASE)", key.Value)
+			}
+		}
+	}
+}
+
+func issueTypeIDs() []string {
+	ids := make([]string, len(issueTypes))
+	for i, t := range issueTypes {
+		ids[i] = t.ID
+	}
+	return ids
+}
+
+// suggest returns the candidate closest to word, ignoring case, when it is
+// near enough to be what a typo meant: within two edits, or else the one
+// candidate word is the start of.
+func suggest(word string, candidates []string) string {
+	word = strings.ToLower(word)
+	best, bestDist := "", 3
+	var prefixed []string
+	for _, c := range candidates {
+		lc := strings.ToLower(c)
+		if d := editDistance(word, lc); d < bestDist && d < len(word) {
+			best, bestDist = c, d
+		}
+		if len(word) >= 4 && strings.HasPrefix(lc, word) {
+			prefixed = append(prefixed, c)
+		}
+	}
+	if best == "" && len(prefixed) == 1 {
+		best = prefixed[0]
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b, counting an
+// adjacent transposition as one edit.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, minInt(d[i][j-1]+1, d[i-1][j-1]+cost))
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// ScanFileEnv returns the settings def sets, by environment variable: its
+// scope and severity sections, then its settings section. Sections left
+// empty set nothing.
+func ScanFileEnv(def *types.ScanDefinition) map[string]string {
+	env := make(map[string]string)
+	set := func(key string, values []string) {
+		if len(values) > 0 {
+			env[key] = strings.Join(values, ",")
+		}
+	}
+	set("SCOPE_ALLOW", def.Scope.Allow)
+	set("SCOPE_DENY", def.Scope.Deny)
+	set("SCOPE_DENY_TAGS", def.Scope.DenyTags)
+	set("NUCLEI_SEVERITY", def.Severity.Nuclei)
+	if def.Severity.FromCVSS != nil {
+		env["SEVERITY_FROM_CVSS"] = fmt.Sprint(*def.Severity.FromCVSS)
+	}
+	for k, v := range def.Settings {
+		env[k] = v
+	}
+	return env
+}
+
+// ApplySeverityOverrides rates the findings of each issue type in
+// overrides with the severity given, and returns how many it changed.
+func ApplySeverityOverrides(vulns []types.VulnerabilityResult, overrides map[string]string) int {
+	n := 0
+	for i := range vulns {
+		if sev, ok := overrides[vulns[i].Type]; ok && !strings.EqualFold(vulns[i].Severity, sev) {
+			vulns[i].Severity = strings.ToLower(sev)
+			n++
+		}
+	}
+	return n
+}
+
+// ApplySuppressions marks the findings a suppression matches a false
+// positive, with its reason as the triage note, and returns how many it
+// marked. Findings already triaged keep their disposition.
+func ApplySuppressions(vulns []types.VulnerabilityResult, suppressions []types.Suppression) int {
+	n := 0
+	for i, v := range vulns {
+		if v.Disposition != "" {
+			continue
+		}
+		for _, s := range suppressions {
+			if suppressionMatches(s, v) {
+				vulns[i].Disposition = DispositionFalsePositive
+				vulns[i].TriageNote = "suppressed by scan definition: " + s.Reason
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+func suppressionMatches(s types.Suppression, v types.VulnerabilityResult) bool {
+	if s.Type != "" && s.Type != v.Type {
+		return false
+	}
+	if s.Host != "" {
+		if ok, _ := path.Match(strings.ToLower(s.Host), NormalizeHostname(v.URL)); !ok {
+			return false
+		}
+	}
+	return s.URL == "" || strings.HasPrefix(v.URL, s.URL)
+}
+
+// NotifyFindings returns the findings route is to be notified of: those of
+// at least its minimum severity (any when unset) and of one of its types
+// (any when unset), leaving out false positives and duplicates.
+func NotifyFindings(route types.NotifyRoute, vulns []types.VulnerabilityResult) []types.VulnerabilityResult {
+	min := 4
+	if sev := strings.ToLower(route.MinSeverity); sev != "" {
+		min, _ = SeverityRank(strings.ToUpper(sev[:1]) + sev[1:])
+	}
+	var out []types.VulnerabilityResult
+	for _, v := range vulns {
+		if v.Disposition == DispositionFalsePositive || v.Disposition == DispositionDuplicate {
+			continue
+		}
+		if len(route.Types) > 0 && !containsString(route.Types, v.Type) {
+			continue
+		}
+		if rank, _ := SeverityRank(FindingSeverity(v)); rank <= min {
+			out = append(out, v)
+		}
+	}
+	return out
+}
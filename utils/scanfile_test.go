@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+var testStages = []string{"subdomain enumeration", "fuzzing", "nikto scanning", "nuclei scanning"}
+
+func loadTestScanFile(name string) (*ScanFile, *types.ScanDefinition, error) {
+	f, err := ReadScanFile(filepath.Join("testdata", "scanfile", name))
+	if err != nil {
+		return nil, nil, err
+	}
+	def, err := f.Resolve(testStages)
+	return f, def, err
+}
+
+// TestScanFileErrorGolden pins the messages an invalid scan definition
+// gets: every problem at once, on its line, with the key or name meant.
+func TestScanFileErrorGolden(t *testing.T) {
+	for _, name := range []string{"unknown_keys", "invalid_values"} {
+		_, _, err := loadTestScanFile(name + ".yaml")
+		if err == nil {
+			t.Errorf("%s: no error", name)
+			continue
+		}
+		if _, ok := err.(*ScanFileError); !ok {
+			t.Errorf("%s: %T %v, want a *ScanFileError", name, err, err)
+		}
+		golden, gerr := ioutil.ReadFile(filepath.Join("testdata", "scanfile", name+".golden"))
+		if gerr != nil {
+			t.Fatal(gerr)
+		}
+		if got := err.Error() + "\n"; got != string(golden) {
+			t.Errorf("%s: error message\n%s\nwant\n%s", name, got, golden)
+		}
+	}
+}
+
+func TestReadScanFile(t *testing.T) {
+	t.Setenv("SCANFILE_TEST_WEBHOOK", "https://hooks.example.com/T000/B000/xyz")
+	t.Setenv("SCANFILE_TEST_DD_KEY", "dd-key")
+	f, def, err := loadTestScanFile("valid.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(def.Targets, []string{"example.com", "example.org"}) || def.Profile != "internal" || len(def.SHA256) != 64 {
+		t.Errorf("definition %+v", def)
+	}
+	if def.Notify[0].Webhook != "https://hooks.example.com/T000/B000/xyz" || def.Settings["DEFECTDOJO_API_KEY"] != "dd-key" {
+		t.Errorf("interpolated %+v, %v", def.Notify, def.Settings)
+	}
+	// The definition as read keeps the references, for the run to record.
+	if f.Definition.Notify[0].Webhook != "${SCANFILE_TEST_WEBHOOK}" || f.Definition.Settings["DEFECTDOJO_API_KEY"] != "${SCANFILE_TEST_DD_KEY}" {
+		t.Errorf("recorded %+v, %v", f.Definition.Notify, f.Definition.Settings)
+	}
+
+	// An unset variable is an error naming it, not its absence silently.
+	t.Setenv("SCANFILE_TEST_WEBHOOK", "")
+	os.Unsetenv("SCANFILE_TEST_WEBHOOK")
+	if _, err := f.Resolve(testStages); err == nil || !strings.Contains(err.Error(), "line 23: ${SCANFILE_TEST_WEBHOOK} is not set in the environment") {
+		t.Errorf("unset variable: %v", err)
+	}
+
+	dir := t.TempDir()
+	tests := []struct {
+		content, want string
+	}{
+		{"", "the file is empty"},
+		{"targets: [example.com\n", "did not find expected ',' or ']'"},
+		{"targets: example.com\n", "line 1: cannot unmarshal !!str `example...` into []string"},
+		{"targets: [example.com]\nprofile: ${PROFILE}\n", "line 2: profile is read before the settings are loaded"},
+		{"profile: internal\n", "targets: at least one target is required"},
+		{"targets: [example.com]\nstages: {skip: [fuzzing], only: [nikto scanning]}\n", "stages.skip and stages.only cannot both be set"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, "scan.yaml")
+		if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var err error
+		if f, err = ReadScanFile(path); err == nil {
+			_, err = f.Resolve(testStages)
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: error %v, want %q", tt.content, err, tt.want)
+		}
+	}
+}
+
+func TestScanFileEnv(t *testing.T) {
+	t.Setenv("SCANFILE_TEST_WEBHOOK", "https://hooks.example.com/x")
+	t.Setenv("SCANFILE_TEST_DD_KEY", "dd-key")
+	_, def, err := loadTestScanFile("valid.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"SCOPE_ALLOW":        "api.example.net",
+		"SCOPE_DENY":         "legacy.example.com",
+		"SCOPE_DENY_TAGS":    "env:prod",
+		"SEVERITY_FROM_CVSS": "true",
+		"NUCLEI_SEVERITY":    "high,critical",
+		"MAX_LINE_BYTES":     "1048576",
+		"DEFECTDOJO_API_KEY": "dd-key",
+	}
+	if got := ScanFileEnv(def); !reflect.DeepEqual(got, want) {
+		t.Errorf("ScanFileEnv = %v, want %v", got, want)
+	}
+	// Sections left out set nothing, leaving the environment to decide.
+	if got := ScanFileEnv(&types.ScanDefinition{Targets: []string{"example.com"}}); len(got) != 0 {
+		t.Errorf("empty definition sets %v", got)
+	}
+}
+
+func TestApplySuppressions(t *testing.T) {
+	suppressions := []types.Suppression{
+		{Type: "xss", Host: "*.staging.example.com", Reason: "staging WAF"},
+		{URL: "https://example.com/health", Reason: "monitoring"},
+	}
+	vulns := []types.VulnerabilityResult{
+		{Type: "xss", URL: "https://app.staging.example.com/search?q=1"},
+		{Type: "xss", URL: "https://example.com/search?q=1"},
+		{Type: "sqli", URL: "https://app.staging.example.com/item?id=1"},
+		{Type: "exposed-secret", URL: "https://example.com/health/debug"},
+		{Type: "xss", URL: "https://www.staging.example.com/", Disposition: DispositionConfirmed},
+	}
+	if n := ApplySuppressions(vulns, suppressions); n != 2 {
+		t.Errorf("suppressed %d, want 2", n)
+	}
+	for i, want := range []string{DispositionFalsePositive, "", "", DispositionFalsePositive, DispositionConfirmed} {
+		if vulns[i].Disposition != want {
+			t.Errorf("finding %d: disposition %q, want %q", i, vulns[i].Disposition, want)
+		}
+	}
+	if vulns[0].TriageNote != "suppressed by scan definition: staging WAF" {
+		t.Errorf("note %q", vulns[0].TriageNote)
+	}
+
+	if n := ApplySeverityOverrides(vulns, map[string]string{"xss": "High"}); n != 3 || vulns[1].Severity != "high" {
+		t.Errorf("overrode %d, severity %q", n, vulns[1].Severity)
+	}
+}
+
+func TestNotifyFindings(t *testing.T) {
+	vulns := []types.VulnerabilityResult{
+		{Type: "sqli", URL: "https://example.com/a"},
+		{Type: "xss", URL: "https://example.com/b"},
+		{Type: "exposed-secret", URL: "https://example.com/c", Severity: "critical"},
+		{Type: "sqli", URL: "https://example.com/d", Disposition: DispositionFalsePositive},
+	}
+	urls := func(vs []types.VulnerabilityResult) []string {
+		var out []string
+		for _, v := range vs {
+			out = append(out, v.URL)
+		}
+		return out
+	}
+	tests := []struct {
+		route types.NotifyRoute
+		want  []string
+	}{
+		{types.NotifyRoute{}, []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"}},
+		{types.NotifyRoute{MinSeverity: "high"}, []string{"https://example.com/a", "https://example.com/c"}},
+		{types.NotifyRoute{MinSeverity: "Critical"}, []string{"https://example.com/c"}},
+		{types.NotifyRoute{Types: []string{"xss"}}, []string{"https://example.com/b"}},
+	}
+	for _, tt := range tests {
+		if got := urls(NotifyFindings(tt.route, vulns)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: %q, want %q", tt.route, got, tt.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	names := []string{"targets", "profile", "scope", "stages", "severity", "suppressions", "notify", "settings"}
+	tests := []struct{ word, want string }{
+		{"taregts", "targets"},
+		{"Profile", "profile"},
+		{"stage", "stages"},
+		{"supressions", "suppressions"},
+		{"schedule", ""},
+		{"x", ""},
+	}
+	for _, tt := range tests {
+		if got := suggest(tt.word, names); got != tt.want {
+			t.Errorf("suggest(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
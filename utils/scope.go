@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// ScopeFilter decides whether hosts and URLs belong to the engagement.
+// A host is in scope when it equals or is a subdomain of an allowed domain
+// and matches no denied domain; deny rules always win. Hosts that TagRules
+// give one of the DenyTags are denied as well.
+type ScopeFilter struct {
+	Allow    []string
+	Deny     []string
+	DenyTags []string
+	TagRules []TagRule
+}
+
+// NewScopeFilter builds a filter allowing target plus extraAllow.
+func NewScopeFilter(target string, extraAllow, deny []string) *ScopeFilter {
+	s := &ScopeFilter{Allow: []string{NormalizeHostname(target)}}
+	for _, d := range extraAllow {
+		if d = NormalizeHostname(d); d != "" {
+			s.Allow = append(s.Allow, d)
+		}
+	}
+	for _, d := range deny {
+		if d = NormalizeHostname(d); d != "" {
+			s.Deny = append(s.Deny, d)
+		}
+	}
+	return s
+}
+
+// ScopeFromEnv builds the filter for target with the comma-separated
+// SCOPE_ALLOW and SCOPE_DENY domain lists and SCOPE_DENY_TAGS tag patterns
+// from the environment. Tag rules are left for the caller to set.
+func ScopeFromEnv(target string) *ScopeFilter {
+	s := NewScopeFilter(target, strings.Split(os.Getenv("SCOPE_ALLOW"), ","), strings.Split(os.Getenv("SCOPE_DENY"), ","))
+	for _, t := range strings.Split(os.Getenv("SCOPE_DENY_TAGS"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			s.DenyTags = append(s.DenyTags, t)
+		}
+	}
+	return s
+}
+
+// domainMatch reports whether host equals domain or is one of its subdomains.
+func domainMatch(host, domain string) bool {
+	domain = strings.TrimPrefix(domain, "*.")
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// HostInScope reports whether host is in scope.
+func (s *ScopeFilter) HostInScope(host string) bool {
+	h := NormalizeHostname(host)
+	if h == "" {
+		return false
+	}
+	for _, d := range s.Deny {
+		if domainMatch(h, d) {
+			return false
+		}
+	}
+	if len(s.DenyTags) > 0 {
+		tags := RuleTags(s.TagRules, h)
+		for _, t := range s.DenyTags {
+			if HasTag(tags, t) {
+				return false
+			}
+		}
+	}
+	for _, d := range s.Allow {
+		if domainMatch(h, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// URLInScope reports whether the host of an absolute URL is in scope.
+func (s *ScopeFilter) URLInScope(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+	return s.HostInScope(u.Hostname())
+}
+
+// FindingInScope reports whether the location of a finding is in scope. It
+// takes an absolute URL or, as some checks report, a bare host or
+// host:port. A finding without a location is kept.
+func (s *ScopeFilter) FindingInScope(location string) bool {
+	location = strings.TrimSpace(location)
+	if location == "" {
+		return true
+	}
+	if strings.Contains(location, "://") {
+		return s.URLInScope(location)
+	}
+	host := location
+	if i := strings.IndexAny(host, "/?#"); i >= 0 {
+		host = host[:i]
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return s.HostInScope(host)
+}
+
+// FilterFindings keeps the in-scope findings and counts the dropped ones
+// per type.
+func (s *ScopeFilter) FilterFindings(vulns []types.VulnerabilityResult) (kept []types.VulnerabilityResult, dropped map[string]int) {
+	kept = make([]types.VulnerabilityResult, 0, len(vulns))
+	for _, v := range vulns {
+		if s.FindingInScope(v.URL) {
+			kept = append(kept, v)
+			continue
+		}
+		if dropped == nil {
+			dropped = make(map[string]int)
+		}
+		dropped[v.Type]++
+	}
+	return kept, dropped
+}
+
+// DropSummary describes the per-type counts FilterFindings returned, such
+// as "3 out-of-scope finding(s) dropped: sqli 2, xss 1"; "" when nothing
+// was dropped.
+func DropSummary(dropped map[string]int) string {
+	if len(dropped) == 0 {
+		return ""
+	}
+	total := 0
+	var parts []string
+	for t, n := range dropped {
+		total += n
+		if t == "" {
+			t = "untyped"
+		}
+		parts = append(parts, fmt.Sprintf("%s %d", t, n))
+	}
+	sort.Strings(parts)
+	return fmt.Sprintf("%d out-of-scope finding(s) dropped: %s", total, strings.Join(parts, ", "))
+}
+
+// FilterHosts keeps the in-scope hostnames and counts the dropped ones.
+func (s *ScopeFilter) FilterHosts(hosts []string) (kept []string, dropped int) {
+	for _, h := range hosts {
+		if strings.TrimSpace(h) == "" {
+			continue
+		}
+		if s.HostInScope(h) {
+			kept = append(kept, h)
+		} else {
+			dropped++
+		}
+	}
+	return kept, dropped
+}
+
+// FilterURLs keeps the in-scope URLs and counts the dropped ones.
+func (s *ScopeFilter) FilterURLs(urls []string) (kept []string, dropped int) {
+	for _, u := range urls {
+		if strings.TrimSpace(u) == "" {
+			continue
+		}
+		if s.URLInScope(u) {
+			kept = append(kept, u)
+		} else {
+			dropped++
+		}
+	}
+	return kept, dropped
+}
+
+// WriteFiles materializes the scope as scope_allow.txt and scope_deny.txt
+// in outDir so tools and operators can consume it.
+func (s *ScopeFilter) WriteFiles(outDir string) error {
+	if err := WriteLines(s.Allow, filepath.Join(outDir, "scope_allow.txt")); err != nil {
+		return err
+	}
+	return WriteLines(s.Deny, filepath.Join(outDir, "scope_deny.txt"))
+}
+
+// ScopeFromFiles reads back the scope WriteFiles materialized in outDir,
+// for stages that only get the run directory. It fails when outDir has no
+// scope_allow.txt; a missing scope_deny.txt denies nothing.
+func ScopeFromFiles(outDir string) (*ScopeFilter, error) {
+	allow, err := ioutil.ReadFile(filepath.Join(outDir, "scope_allow.txt"))
+	if err != nil {
+		return nil, err
+	}
+	s := &ScopeFilter{Allow: strings.Fields(string(allow))}
+	if deny, err := ioutil.ReadFile(filepath.Join(outDir, "scope_deny.txt")); err == nil {
+		s.Deny = strings.Fields(string(deny))
+	}
+	return s, nil
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestFindingInScope(t *testing.T) {
+	s := NewScopeFilter("example.com", []string{"example.net"}, []string{"legacy.example.com"})
+	tests := []struct {
+		location string
+		want     bool
+	}{
+		{"https://www.example.com/login?next=/", true},
+		{"http://example.net:8080/", true},
+		{"https://evil.com/?redirect=example.com", false},
+		{"https://legacy.example.com/", false},
+		{"https://a.legacy.example.com/", false},
+		{"api.example.com", true},
+		{"api.example.com:8443", true},
+		{"api.example.com/admin", true},
+		{"cdn.other.org:443", false},
+		{"[2001:db8::1]:443", false},
+		{"", true},
+	}
+	for _, tt := range tests {
+		if got := s.FindingInScope(tt.location); got != tt.want {
+			t.Errorf("FindingInScope(%q) = %v, want %v", tt.location, got, tt.want)
+		}
+	}
+}
+
+func TestFilterFindings(t *testing.T) {
+	s := NewScopeFilter("example.com", nil, nil)
+	vulns := []types.VulnerabilityResult{
+		{URL: "https://example.com/item?id=1", Type: "sqli"},
+		{URL: "https://cdn.thirdparty.io/x.js?q=<script>", Type: "xss"},
+		{URL: "https://tracker.thirdparty.io/?q=1", Type: "xss"},
+		{URL: "https://api.example.com/", Type: "nuclei-finding"},
+		{URL: "https://other.org/.git/config", Type: "sqli"},
+	}
+	kept, dropped := s.FilterFindings(vulns)
+	if len(kept) != 2 || kept[0].Type != "sqli" || kept[1].Type != "nuclei-finding" {
+		t.Errorf("kept %+v", kept)
+	}
+	if want := map[string]int{"xss": 2, "sqli": 1}; !reflect.DeepEqual(dropped, want) {
+		t.Errorf("dropped %v, want %v", dropped, want)
+	}
+	if got, want := DropSummary(dropped), "3 out-of-scope finding(s) dropped: sqli 1, xss 2"; got != want {
+		t.Errorf("DropSummary = %q, want %q", got, want)
+	}
+	if _, dropped := s.FilterFindings(kept); DropSummary(dropped) != "" {
+		t.Errorf("in-scope findings dropped: %v", dropped)
+	}
+}
+
+func TestScopeFromFiles(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := ScopeFromFiles(dir); err == nil {
+		t.Error("no error without scope_allow.txt")
+	}
+	want := NewScopeFilter("example.com", []string{"example.net"}, []string{"legacy.example.com"})
+	if err := want.WriteFiles(dir); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ScopeFromFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Allow, want.Allow) || !reflect.DeepEqual(got.Deny, want.Deny) {
+		t.Errorf("read back allow %v deny %v, want %v %v", got.Allow, got.Deny, want.Allow, want.Deny)
+	}
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// Verification states of a secret: a provider accepted it, refused it, or
+// it was not checked.
+const (
+	SecretVerified   = "verified"
+	SecretRejected   = "rejected"
+	SecretUnverified = "unverified"
+)
+
+// MaskSecret keeps the first and last four characters of a secret, enough
+// to tell keys apart, and its length. Secrets of twelve characters or
+// fewer are masked entirely. Every detector masks with it, so the same
+// secret masks the same wherever it is found.
+func MaskSecret(secret string) string {
+	r := []rune(strings.TrimSpace(secret))
+	if len(r) <= 12 {
+		return fmt.Sprintf("******** (%d chars)", len(r))
+	}
+	return fmt.Sprintf("%s********%s (%d chars)", string(r[:4]), string(r[len(r)-4:]), len(r))
+}
+
+// secretClasses maps words in detector and rule names to the class of
+// secret they find, most severe first.
+var secretClasses = []struct {
+	Class, Severity string
+	Words           []string
+}{
+	{"private-key", "Critical", []string{"privatekey", "private-key", "private_key"}},
+	{"aws", "Critical", []string{"aws", "amazon"}},
+	{"stripe", "Critical", []string{"stripe"}},
+	{"github", "High", []string{"github"}},
+	{"gitlab", "High", []string{"gitlab"}},
+	{"slack", "High", []string{"slack"}},
+	{"google", "Medium", []string{"google", "gcp"}},
+}
+
+// SecretClass returns the class of secret a detector or rule name such as
+// "AWS", "aws-access-token" or "github.com" finds, or "generic".
+func SecretClass(name string) string {
+	name = strings.ToLower(name)
+	for _, c := range secretClasses {
+		for _, w := range c.Words {
+			if strings.Contains(name, w) {
+				return c.Class
+			}
+		}
+	}
+	return "generic"
+}
+
+// SecretClassSeverity returns how severe an exposed secret of class is;
+// generic secrets are Medium.
+func SecretClassSeverity(class string) string {
+	for _, c := range secretClasses {
+		if c.Class == class {
+			return c.Severity
+		}
+	}
+	return "Medium"
+}
+
+// NewSecretFinding returns the finding of secret found at loc. The secret
+// itself is only used to mask it and is not kept.
+func NewSecretFinding(class, secret string, loc types.SecretLocation, confidence, verification string) types.SecretFinding {
+	masked := MaskSecret(secret)
+	hashed := masked
+	// Fully masked values all look alike; they are only the same secret
+	// when found by the same class at the same place.
+	if strings.HasPrefix(masked, "*") {
+		hashed = class + "|" + loc.Artifact + "|" + loc.Location + "|" + masked
+	}
+	sum := sha256.Sum256([]byte(hashed))
+	if verification == "" {
+		verification = SecretUnverified
+	}
+	return types.SecretFinding{
+		Class:        class,
+		Masked:       masked,
+		Hash:         hex.EncodeToString(sum[:8]),
+		Locations:    []types.SecretLocation{loc},
+		Confidence:   confidence,
+		Verification: verification,
+	}
+}
+
+var (
+	confidenceStrength   = map[string]int{ConfidenceTentative: 1, ConfidenceFirm: 2, ConfidenceConfirmed: 3}
+	verificationStrength = map[string]int{SecretUnverified: 1, SecretRejected: 2, SecretVerified: 3}
+)
+
+// MergeSecrets adds found to secrets, merging findings of the same masked
+// value: their locations are combined, the stronger confidence and
+// verification kept, and a specific class preferred to generic. A
+// verified secret is confirmed.
+func MergeSecrets(secrets []types.SecretFinding, found ...types.SecretFinding) []types.SecretFinding {
+	index := make(map[string]int, len(secrets))
+	for i, s := range secrets {
+		index[s.Hash] = i
+	}
+	for _, f := range found {
+		i, seen := index[f.Hash]
+		if !seen {
+			index[f.Hash] = len(secrets)
+			f.Locations = append([]types.SecretLocation(nil), f.Locations...)
+			secrets = append(secrets, f)
+			i = len(secrets) - 1
+		} else {
+			s := &secrets[i]
+			for _, loc := range f.Locations {
+				if !hasSecretLocation(s.Locations, loc) {
+					s.Locations = append(s.Locations, loc)
+				}
+			}
+			if confidenceStrength[f.Confidence] > confidenceStrength[s.Confidence] {
+				s.Confidence = f.Confidence
+			}
+			if verificationStrength[f.Verification] > verificationStrength[s.Verification] {
+				s.Verification = f.Verification
+			}
+			if s.Class == "generic" {
+				s.Class = f.Class
+			}
+		}
+		if secrets[i].Verification == SecretVerified {
+			secrets[i].Confidence = ConfidenceConfirmed
+		}
+	}
+	return secrets
+}
+
+func hasSecretLocation(locs []types.SecretLocation, loc types.SecretLocation) bool {
+	for _, l := range locs {
+		if l == loc {
+			return true
+		}
+	}
+	return false
+}
+
+// SortSecrets orders secrets by the severity of their class, verified
+// ones first within a severity, then by class and masked value.
+func SortSecrets(secrets []types.SecretFinding) {
+	sort.SliceStable(secrets, func(i, j int) bool {
+		a, b := secrets[i], secrets[j]
+		ra, _ := SeverityRank(SecretClassSeverity(a.Class))
+		rb, _ := SeverityRank(SecretClassSeverity(b.Class))
+		if ra != rb {
+			return ra < rb
+		}
+		if va, vb := a.Verification == SecretVerified, b.Verification == SecretVerified; va != vb {
+			return va
+		}
+		if a.Class != b.Class {
+			return a.Class < b.Class
+		}
+		return a.Masked < b.Masked
+	})
+}
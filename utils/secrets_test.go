@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestMaskSecret(t *testing.T) {
+	tests := []struct {
+		secret, want string
+	}{
+		{"", "******** (0 chars)"},
+		{"hunter2", "******** (7 chars)"},
+		{"abcdefghijkl", "******** (12 chars)"},
+		{"abcdefghijklm", "abcd********jklm (13 chars)"},
+		{"  AKIAQ3TZ7LWN2P5RXK8M\n", "AKIA********XK8M (20 chars)"},
+		{"пароль-пароль-пароль", "паро********роль (20 chars)"},
+	}
+	for _, tt := range tests {
+		got := MaskSecret(tt.secret)
+		if got != tt.want {
+			t.Errorf("MaskSecret(%q) = %q, want %q", tt.secret, got, tt.want)
+		}
+		if s := strings.TrimSpace(tt.secret); len(s) > 0 && strings.Contains(got, s) {
+			t.Errorf("MaskSecret(%q) keeps the secret", tt.secret)
+		}
+	}
+}
+
+func TestSecretClass(t *testing.T) {
+	tests := []struct {
+		name, class, severity string
+	}{
+		{"AWS", "aws", "Critical"},
+		{"aws-access-token", "aws", "Critical"},
+		{"built-in aws-access-key", "aws", "Critical"},
+		{"PrivateKey", "private-key", "Critical"},
+		{"private-key", "private-key", "Critical"},
+		{"Stripe", "stripe", "Critical"},
+		{"github-pat", "github", "High"},
+		{"github.com", "github", "High"},
+		{"gitlab.example.com", "gitlab", "High"},
+		{"slack-bot-token", "slack", "High"},
+		{"gcp-api-key", "google", "Medium"},
+		{"generic-api-key", "generic", "Medium"},
+		{"backup.example.com", "generic", "Medium"},
+		// Words that merely contain a class's letters do not count.
+		{"parsable-token", "generic", "Medium"},
+	}
+	for _, tt := range tests {
+		class := SecretClass(tt.name)
+		if class != tt.class || SecretClassSeverity(class) != tt.severity {
+			t.Errorf("SecretClass(%q) = %s (%s), want %s (%s)", tt.name, class, SecretClassSeverity(class), tt.class, tt.severity)
+		}
+	}
+}
+
+func TestMergeSecrets(t *testing.T) {
+	key := "AKIA" + "Q3TZ7LWN2P5RXK8M"
+	js := types.SecretLocation{Artifact: "https://a.example.com/main.js", Location: "line 3", Detector: "built-in aws-access-key"}
+	truffle := types.SecretLocation{Artifact: "https://a.example.com/main.js", Location: "line 3", Detector: "trufflehog AWS"}
+	repo := types.SecretLocation{Artifact: "https://b.example.com/.git/#deploy.sh", Location: "line 9 at commit 3f786850e387", Detector: "gitleaks generic-api-key"}
+
+	secrets := MergeSecrets(nil, NewSecretFinding("aws", key, js, ConfidenceTentative, SecretUnverified))
+	// The same key from other detectors and sources merges into it.
+	secrets = MergeSecrets(secrets,
+		NewSecretFinding("aws", key, truffle, ConfidenceFirm, SecretVerified),
+		NewSecretFinding("generic", key, repo, ConfidenceFirm, SecretRejected),
+		NewSecretFinding("aws", key, js, ConfidenceTentative, ""))
+	if len(secrets) != 1 {
+		t.Fatalf("%d secret(s): %+v", len(secrets), secrets)
+	}
+	s := secrets[0]
+	if s.Class != "aws" || s.Masked != "AKIA********XK8M (20 chars)" || s.Confidence != ConfidenceConfirmed || s.Verification != SecretVerified {
+		t.Errorf("merged secret %+v", s)
+	}
+	if !reflect.DeepEqual(s.Locations, []types.SecretLocation{js, truffle, repo}) {
+		t.Errorf("locations %+v", s.Locations)
+	}
+
+	// A generic class gives way to a specific one; confidence and
+	// verification only ever get stronger.
+	other := "sk" + "_live_" + "4eC39HqLyjWDarjtT1zdp7dc"
+	secrets = MergeSecrets(secrets,
+		NewSecretFinding("generic", other, repo, ConfidenceFirm, SecretRejected),
+		NewSecretFinding("stripe", other, js, ConfidenceTentative, SecretUnverified))
+	if len(secrets) != 2 || secrets[1].Class != "stripe" || secrets[1].Confidence != ConfidenceFirm || secrets[1].Verification != SecretRejected {
+		t.Errorf("second secret %+v", secrets[1:])
+	}
+
+	// Fully masked values only merge when found by the same class at the
+	// same place.
+	sameLine := repo
+	sameLine.Detector = "gitleaks generic-password"
+	nextLine := repo
+	nextLine.Location = "line 10 at commit 3f786850e387"
+	short := MergeSecrets(nil,
+		NewSecretFinding("generic", "hunter2", repo, ConfidenceFirm, ""),
+		NewSecretFinding("generic", "hunter2", sameLine, ConfidenceFirm, ""),
+		NewSecretFinding("generic", "letmein", nextLine, ConfidenceFirm, ""),
+		NewSecretFinding("generic", "hunter2", js, ConfidenceFirm, ""))
+	if len(short) != 3 || len(short[0].Locations) != 2 || short[1].Locations[0] != nextLine || short[2].Locations[0] != js {
+		t.Errorf("short secrets %+v", short)
+	}
+
+	// Merging leaves the findings passed in alone.
+	f := NewSecretFinding("github", "gh"+"p_"+"r8Kd2mQx7ZtL4vWb9NcY3hFs6JpG1aUe5oTi", js, ConfidenceFirm, "")
+	merged := MergeSecrets(nil, f)
+	MergeSecrets(merged, NewSecretFinding("github", "gh"+"p_"+"r8Kd2mQx7ZtL4vWb9NcY3hFs6JpG1aUe5oTi", repo, ConfidenceFirm, ""))
+	if len(f.Locations) != 1 {
+		t.Errorf("the finding passed in gained locations: %+v", f.Locations)
+	}
+}
+
+func TestSortSecrets(t *testing.T) {
+	secrets := []types.SecretFinding{
+		{Class: "generic", Masked: "b"},
+		{Class: "github", Masked: "a"},
+		{Class: "slack", Masked: "a", Verification: SecretVerified},
+		{Class: "aws", Masked: "z"},
+		{Class: "google", Masked: "a"},
+		{Class: "aws", Masked: "y"},
+		{Class: "stripe", Masked: "a", Verification: SecretVerified},
+	}
+	SortSecrets(secrets)
+	var got []string
+	for _, s := range secrets {
+		got = append(got, s.Class+" "+s.Masked)
+	}
+	want := []string{"stripe a", "aws y", "aws z", "slack a", "github a", "generic b", "google a"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("order %q, want %q", got, want)
+	}
+}
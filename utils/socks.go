@@ -0,0 +1,280 @@
+package utils
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SOCKS5Dialer opens TCP connections through a SOCKS5 proxy (RFC 1928),
+// optionally authenticating with a username and password (RFC 1929).
+// With RemoteDNS the proxy resolves hostnames, which matters behind
+// split-horizon DNS; otherwise they are resolved locally first.
+type SOCKS5Dialer struct {
+	Addr      string
+	Username  string
+	Password  string
+	RemoteDNS bool
+	Timeout   time.Duration
+}
+
+// ParseSOCKS5 accepts host:port or socks5://[user:pass@]host:port.
+func ParseSOCKS5(raw string, remoteDNS bool) (*SOCKS5Dialer, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "socks5://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "socks5" && u.Scheme != "socks5h" {
+		return nil, fmt.Errorf("unsupported SOCKS scheme %q", u.Scheme)
+	}
+	if u.Port() == "" {
+		return nil, fmt.Errorf("SOCKS5 proxy %s has no port", u.Host)
+	}
+	d := &SOCKS5Dialer{Addr: u.Host, RemoteDNS: remoteDNS, Timeout: 10 * time.Second}
+	if u.User != nil {
+		d.Username = u.User.Username()
+		d.Password, _ = u.User.Password()
+	}
+	return d, nil
+}
+
+// URL returns the proxy as a URL for external tools.
+func (d *SOCKS5Dialer) URL() string {
+	u := url.URL{Scheme: "socks5", Host: d.Addr}
+	if d.Username != "" {
+		u.User = url.UserPassword(d.Username, d.Password)
+	}
+	return u.String()
+}
+
+// socksReplies describes the SOCKS5 reply codes.
+var socksReplies = map[byte]string{
+	1: "general failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// DialContext connects to addr through the proxy. Only TCP is supported.
+func (d *SOCKS5Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("socks5: network %s not supported", network)
+	}
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return nil, fmt.Errorf("socks5: bad port in %s", addr)
+	}
+	if net.ParseIP(host) == nil && !d.RemoteDNS {
+		ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+		if err != nil {
+			return nil, err
+		}
+		host = ips[0].String()
+	}
+
+	dialer := &net.Dialer{Timeout: d.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", d.Addr)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(d.Timeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	conn.SetDeadline(deadline)
+	if err := d.handshake(conn, host, port); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("socks5 %s: %v", d.Addr, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// handshake negotiates authentication and issues the CONNECT request.
+func (d *SOCKS5Dialer) handshake(conn net.Conn, host string, port int) error {
+	methods := []byte{0x00}
+	if d.Username != "" {
+		methods = []byte{0x00, 0x02}
+	}
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	resp := make([]byte, 2)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return err
+	}
+	if resp[0] != 0x05 {
+		return errors.New("not a SOCKS5 server")
+	}
+	switch resp[1] {
+	case 0x00:
+	case 0x02:
+		if len(d.Username) > 255 || len(d.Password) > 255 {
+			return errors.New("credentials too long")
+		}
+		auth := []byte{0x01, byte(len(d.Username))}
+		auth = append(auth, d.Username...)
+		auth = append(auth, byte(len(d.Password)))
+		auth = append(auth, d.Password...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, resp); err != nil {
+			return err
+		}
+		if resp[1] != 0x00 {
+			return errors.New("authentication rejected")
+		}
+	default:
+		return errors.New("no acceptable authentication method")
+	}
+
+	req := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(append(req, 0x01), ip4...)
+		} else {
+			req = append(append(req, 0x04), ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return errors.New("hostname too long")
+		}
+		req = append(append(req, 0x03, byte(len(host))), host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return err
+	}
+	if head[1] != 0x00 {
+		if msg, ok := socksReplies[head[1]]; ok {
+			return errors.New(msg)
+		}
+		return fmt.Errorf("reply code %d", head[1])
+	}
+	// Discard the bound address.
+	var skip int
+	switch head[3] {
+	case 0x01:
+		skip = net.IPv4len + 2
+	case 0x04:
+		skip = net.IPv6len + 2
+	case 0x03:
+		if _, err := io.ReadFull(conn, head[:1]); err != nil {
+			return err
+		}
+		skip = int(head[0]) + 2
+	default:
+		return fmt.Errorf("unknown address type %d in reply", head[3])
+	}
+	_, err := io.ReadFull(conn, make([]byte, skip))
+	return err
+}
+
+// socksToolArgs is the SOCKS5 capability matrix for external tools that
+// contact the target: the extra arguments routing them through the proxy,
+// or nil when they cannot be routed and must be disabled. Tools absent from
+// the matrix only query third-party archives and APIs.
+var socksToolArgs = map[string]func(proxyURL string) []string{
+	"ffuf":          func(p string) []string { return []string{"-x", p} },
+	"gobuster":      func(p string) []string { return []string{"--proxy", p} },
+	"dirsearch":     func(p string) []string { return []string{"--proxy", p} },
+	"sqlmap":        func(p string) []string { return []string{"--proxy=" + p} },
+	"crlfuzz":       func(p string) []string { return []string{"-x", p} },
+	"nuclei":        func(p string) []string { return []string{"-proxy", p} },
+	"wpscan":        func(p string) []string { return []string{"--proxy", p} },
+	"gospider":      func(p string) []string { return []string{"-p", p} },
+	"hakrawler":     nil,
+	"katana":        func(p string) []string { return []string{"-proxy", p} },
+	"dalfox":        nil,
+	"kxss":          nil,
+	"corsy":         nil,
+	"JSFinder":      nil,
+	"linkfinder":    nil,
+	"linkfinder.py": nil,
+	"paramwizard":   nil,
+	"arjun":         nil,
+	"dnsx":          nil,
+	"nmap":          nil,
+	"masscan":       nil,
+	"naabu":         nil,
+	"nikto":         nil,
+	"whatweb":       nil,
+	"wafw00f":       func(p string) []string { return []string{"-p", p} },
+	"git":           func(p string) []string { return []string{"--config", "http.proxy=" + p} },
+	"git-dumper":    func(p string) []string { return []string{"--proxy", p} },
+	"subzy":         nil,
+	"subjack":       nil,
+	"testssl.sh":    nil,
+	"testssl":       nil,
+}
+
+var (
+	socksProxy   *SOCKS5Dialer
+	socksProxyMu sync.Mutex
+)
+
+// EnableSOCKS makes RunCommand route capable tools through d and refuse
+// the others. It returns the names of the disabled tools. The proxy
+// password is registered as a secret, since the tools get it on their
+// command line.
+func EnableSOCKS(d *SOCKS5Dialer) []string {
+	RegisterSecret(d.Password)
+	socksProxyMu.Lock()
+	socksProxy = d
+	socksProxyMu.Unlock()
+	var disabled []string
+	for tool, args := range socksToolArgs {
+		if args == nil {
+			disabled = append(disabled, tool)
+		}
+	}
+	sort.Strings(disabled)
+	return disabled
+}
+
+// socksArgs returns the arguments to append for name in SOCKS5 mode, or an
+// error when the tool would bypass the bastion.
+func socksArgs(name string) ([]string, error) {
+	socksProxyMu.Lock()
+	d := socksProxy
+	socksProxyMu.Unlock()
+	if d == nil {
+		return nil, nil
+	}
+	args, known := socksToolArgs[name]
+	if !known {
+		return nil, nil
+	}
+	if args == nil {
+		return nil, fmt.Errorf("%s disabled: it cannot be routed through the SOCKS5 bastion", name)
+	}
+	return args(d.URL()), nil
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// ComputeSourceStats summarizes what each enumeration source contributed.
+// Total is the number of distinct names a source emitted. Unique uses
+// exclusive attribution: a name counts for a source only if no other source
+// found it, so the numbers do not depend on the order the sources ran in.
+func ComputeSourceStats(order []string, found map[string][]string, runtimes map[string]time.Duration) []types.SourceStats {
+	owners := make(map[string]int)
+	perSource := make(map[string]map[string]bool)
+	for _, src := range order {
+		names := make(map[string]bool)
+		for _, n := range found[src] {
+			n = strings.ToLower(strings.TrimSpace(n))
+			if n != "" {
+				names[n] = true
+			}
+		}
+		perSource[src] = names
+		for n := range names {
+			owners[n]++
+		}
+	}
+	var stats []types.SourceStats
+	for _, src := range order {
+		st := types.SourceStats{
+			Source:         src,
+			Total:          len(perSource[src]),
+			RuntimeSeconds: runtimes[src].Seconds(),
+		}
+		for n := range perSource[src] {
+			if owners[n] == 1 {
+				st.Unique++
+			}
+		}
+		stats = append(stats, st)
+	}
+	return stats
+}
+
+// FormatSourceStats renders the per-source statistics as a plain-text table.
+func FormatSourceStats(stats []types.SourceStats) []string {
+	lines := []string{fmt.Sprintf("%-14s %7s %7s %9s", "Source", "Total", "Unique", "Runtime")}
+	for _, st := range stats {
+		lines = append(lines, fmt.Sprintf("%-14s %7d %7d %8.1fs", st.Source, st.Total, st.Unique, st.RuntimeSeconds))
+	}
+	return lines
+}
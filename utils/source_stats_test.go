@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestComputeSourceStats(t *testing.T) {
+	found := map[string][]string{
+		"subfinder":   {"www.example.com", "api.example.com", "dev.example.com", "dev.example.com"},
+		"assetfinder": {"WWW.example.com", " api.example.com ", "mail.example.com", ""},
+		"amass":       {"www.example.com", "vpn.example.com", "legacy.example.com"},
+		"crt.sh":      nil,
+		// Not in the order: not reported, and not an owner either.
+		"chaos": {"vpn.example.com"},
+	}
+	runtimes := map[string]time.Duration{
+		"subfinder":   12500 * time.Millisecond,
+		"assetfinder": 3 * time.Second,
+		"amass":       2 * time.Minute,
+	}
+	want := map[string]types.SourceStats{
+		// dev is subfinder's alone; www and api are shared.
+		"subfinder": {Source: "subfinder", Total: 3, Unique: 1, RuntimeSeconds: 12.5},
+		// Names are compared case and space insensitively; blanks are dropped.
+		"assetfinder": {Source: "assetfinder", Total: 3, Unique: 1, RuntimeSeconds: 3},
+		"amass":       {Source: "amass", Total: 3, Unique: 2, RuntimeSeconds: 120},
+		"crt.sh":      {Source: "crt.sh"},
+	}
+
+	// Attribution is exclusive: first-come attribution would credit www to
+	// whichever source ran first, so the numbers would change with the
+	// order. Every order gives the same stats here.
+	orders := [][]string{
+		{"subfinder", "assetfinder", "amass", "crt.sh"},
+		{"amass", "crt.sh", "assetfinder", "subfinder"},
+		{"crt.sh", "assetfinder", "subfinder", "amass"},
+	}
+	for _, order := range orders {
+		stats := ComputeSourceStats(order, found, runtimes)
+		if len(stats) != len(order) {
+			t.Fatalf("order %v: %d stat(s)", order, len(stats))
+		}
+		for i, st := range stats {
+			if st.Source != order[i] {
+				t.Errorf("order %v: stat %d is %s", order, i, st.Source)
+			}
+			if !reflect.DeepEqual(st, want[st.Source]) {
+				t.Errorf("order %v: %+v, want %+v", order, st, want[st.Source])
+			}
+		}
+	}
+
+	// A source alone owns everything it found.
+	stats := ComputeSourceStats([]string{"amass"}, found, nil)
+	if stats[0].Total != 3 || stats[0].Unique != 3 || stats[0].RuntimeSeconds != 0 {
+		t.Errorf("single source: %+v", stats[0])
+	}
+	// Two sources finding the same names leave neither any unique ones.
+	same := map[string][]string{"a": {"x.example.com", "y.example.com"}, "b": {"Y.example.com", "x.example.com"}}
+	for _, st := range ComputeSourceStats([]string{"a", "b"}, same, nil) {
+		if st.Total != 2 || st.Unique != 0 {
+			t.Errorf("identical sources: %+v", st)
+		}
+	}
+}
+
+func TestFormatSourceStats(t *testing.T) {
+	lines := FormatSourceStats([]types.SourceStats{
+		{Source: "subfinder", Total: 1234, Unique: 56, RuntimeSeconds: 12.46},
+		{Source: "crt.sh"},
+	})
+	want := []string{
+		"Source           Total  Unique   Runtime",
+		"subfinder         1234      56     12.5s",
+		"crt.sh               0       0      0.0s",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("got\n%q\nwant\n%q", lines, want)
+	}
+}
@@ -0,0 +1,235 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// SummaryRecovery is what LoadSummary salvaged from a summary.json that
+// did not parse, typically one truncated by a run killed while writing it.
+// Sections name the top-level keys of the summary.
+type SummaryRecovery struct {
+	File string
+	// Err is why the strict parse failed.
+	Err error
+	// Recovered are the sections read intact from the summary that hold
+	// something, Rebuilt those read from the run's other artifacts instead
+	// and Lost those neither had.
+	Recovered []string
+	Rebuilt   []string
+	Lost      []string
+}
+
+// Lines is the report shown before a command goes on with a salvaged run,
+// one line each for the damage and the sections recovered, rebuilt and
+// lost.
+func (r *SummaryRecovery) Lines() []string {
+	lines := []string{fmt.Sprintf("%s is damaged (%v); going on with what could be salvaged", r.File, r.Err)}
+	for _, l := range []struct {
+		label    string
+		sections []string
+	}{{"recovered", r.Recovered}, {"rebuilt", r.Rebuilt}, {"lost", r.Lost}} {
+		if len(l.sections) > 0 {
+			lines = append(lines, l.label+": "+strings.Join(l.sections, ", "))
+		}
+	}
+	return lines
+}
+
+// String is the report of Lines, the section lines indented.
+func (r *SummaryRecovery) String() string {
+	return strings.Join(r.Lines(), "\n    ") + "\n"
+}
+
+// summarySection is one top-level key of summary.json.
+type summarySection struct {
+	name      string
+	field     int
+	omitEmpty bool
+}
+
+// summarySections lists the keys of summary.json in the order
+// PersistResults writes them.
+func summarySections() []summarySection {
+	t := reflect.TypeOf(types.ScanResult{})
+	var sections []summarySection
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")
+		if tag[0] == "" || tag[0] == "-" {
+			continue
+		}
+		sections = append(sections, summarySection{tag[0], i, len(tag) > 1 && tag[1] == "omitempty"})
+	}
+	return sections
+}
+
+// summaryRebuilders rebuild a section missing from summary.json from the
+// artifact the run writes it to as well.
+var summaryRebuilders = map[string]struct {
+	file    string
+	rebuild func(data []byte, result *types.ScanResult) (n int, complete bool)
+}{
+	"subdomains": {"subdomains.txt", func(data []byte, result *types.ScanResult) (int, bool) {
+		for _, h := range textLines(data) {
+			result.Subdomains = append(result.Subdomains, types.SubdomainResult{Hostname: h, Ports: []int{}})
+		}
+		return len(result.Subdomains), true
+	}},
+	"all_urls": {"urls.txt", func(data []byte, result *types.ScanResult) (int, bool) {
+		result.AllURLs = textLines(data)
+		return len(result.AllURLs), true
+	}},
+	"vuln_urls": {"vulnerabilities.json", func(data []byte, result *types.ScanResult) (int, bool) {
+		complete := salvageArray(data, func(raw json.RawMessage) bool {
+			var v types.VulnerabilityResult
+			if json.Unmarshal(raw, &v) != nil {
+				return false
+			}
+			result.VulnURLs = append(result.VulnURLs, v)
+			return true
+		})
+		return len(result.VulnURLs), complete
+	}},
+}
+
+// textLines returns the non-empty lines of data.
+func textLines(data []byte) []string {
+	var lines []string
+	for _, l := range strings.Split(string(data), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// salvageArray calls fn with each complete element of the JSON array in
+// data, stopping at the first that is cut off or that fn rejects. It
+// reports whether the whole array was read.
+func salvageArray(data []byte, fn func(json.RawMessage) bool) bool {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return false
+	}
+	for dec.More() {
+		var raw json.RawMessage
+		if dec.Decode(&raw) != nil || !fn(raw) {
+			return false
+		}
+	}
+	_, err := dec.Token()
+	return err == nil
+}
+
+// salvageSections returns the top-level sections of the JSON object in
+// data that are complete, and the key of the one cut off, if any.
+func salvageSections(data []byte) (map[string]json.RawMessage, string) {
+	sections := make(map[string]json.RawMessage)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return sections, ""
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return sections, ""
+		}
+		key, _ := tok.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return sections, key
+		}
+		sections[key] = raw
+	}
+	return sections, ""
+}
+
+// sectionSize describes how much a recovered section holds, "" for one
+// holding nothing.
+func sectionSize(v reflect.Value) (string, bool) {
+	switch {
+	case v.Kind() == reflect.Slice:
+		return fmt.Sprintf(" (%d)", v.Len()), v.Len() > 0
+	case v.IsZero():
+		return "", false
+	}
+	return "", true
+}
+
+// LoadSummary reads the summary.json of the run directory dir. When it
+// does not parse, the complete top-level sections are kept, the subdomains,
+// URLs and findings are rebuilt from subdomains.txt, urls.txt and
+// vulnerabilities.json when their section is missing, and the returned
+// recovery says what was salvaged and what was lost. The recovery is nil
+// for an intact summary; a summary nothing could be salvaged from is an
+// error.
+func LoadSummary(dir string) (types.ScanResult, *SummaryRecovery, error) {
+	var result types.ScanResult
+	file := filepath.Join(dir, "summary.json")
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return result, nil, err
+	}
+	strictErr := json.Unmarshal(data, &result)
+	if strictErr == nil {
+		return result, nil, nil
+	}
+
+	result = types.ScanResult{}
+	rec := &SummaryRecovery{File: file, Err: strictErr}
+	sections, cut := salvageSections(data)
+	rv := reflect.ValueOf(&result).Elem()
+	for _, s := range summarySections() {
+		raw, ok := sections[s.name]
+		if ok {
+			// Decode the section alone so one of the wrong shape does not
+			// take the others with it.
+			field := rv.Field(s.field)
+			if err := json.Unmarshal(raw, field.Addr().Interface()); err == nil {
+				// Empty sections are not worth a mention.
+				if size, ok := sectionSize(field); ok {
+					rec.Recovered = append(rec.Recovered, s.name+size)
+				}
+				continue
+			}
+			field.Set(reflect.Zero(field.Type()))
+		}
+		why := ""
+		switch {
+		case ok:
+			why = "malformed"
+		case s.name == cut:
+			why = "truncated"
+		case s.omitEmpty:
+			// Absent when empty, so nothing is known to be lost.
+			continue
+		}
+		if r, ok := summaryRebuilders[s.name]; ok {
+			if sibling, err := ioutil.ReadFile(filepath.Join(dir, r.file)); err == nil {
+				n, complete := r.rebuild(sibling, &result)
+				note := fmt.Sprintf("%s (%d, from %s", s.name, n, r.file)
+				if !complete {
+					note += ", itself cut off"
+				}
+				rec.Rebuilt = append(rec.Rebuilt, note+")")
+				continue
+			}
+		}
+		if why != "" {
+			rec.Lost = append(rec.Lost, s.name+" ("+why+")")
+		} else {
+			rec.Lost = append(rec.Lost, s.name)
+		}
+	}
+	if len(rec.Recovered) == 0 && len(rec.Rebuilt) == 0 {
+		return result, nil, fmt.Errorf("%s: %v; nothing could be salvaged", file, strictErr)
+	}
+	return result, rec, nil
+}
@@ -0,0 +1,268 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// summaryFixture is a finished run's summary with something in each of
+// the sections the recovery rebuilds.
+func summaryFixture(t *testing.T) (types.ScanResult, []byte) {
+	t.Helper()
+	result := types.ScanResult{
+		Subdomains: []types.SubdomainResult{
+			{Hostname: "www.example.com", IP: "192.0.2.10", Ports: []int{443}},
+			{Hostname: "app.example.com", IP: "192.0.2.11", Ports: []int{80, 443}},
+		},
+		VulnURLs: []types.VulnerabilityResult{
+			{URL: "https://app.example.com/item?id=1", Issue: "SQL Injection", Type: "sqli"},
+			{URL: "https://app.example.com/search?q=x", Issue: "Reflected XSS", Type: "xss"},
+		},
+		AllURLs:     []string{"https://www.example.com/", "https://app.example.com/item?id=1", "https://app.example.com/search?q=x"},
+		LogLines:    []string{"[*] Scan started"},
+		FinalReport: "Final report for example.com",
+	}
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result, data
+}
+
+// writeRun writes a run directory holding summary and, by name, siblings.
+func writeRun(t *testing.T, summary []byte, siblings map[string][]byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string][]byte{"summary.json": summary}
+	for name, data := range siblings {
+		files[name] = data
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// offsetOf returns the offset of s in data, failing the test without it.
+func offsetOf(t *testing.T, data []byte, s string) int {
+	t.Helper()
+	i := bytes.Index(data, []byte(s))
+	if i < 0 {
+		t.Fatalf("fixture lacks %q", s)
+	}
+	return i
+}
+
+func TestLoadSummarySalvage(t *testing.T) {
+	want, data := summaryFixture(t)
+	vulns, _ := json.MarshalIndent(want.VulnURLs, "", "  ")
+	siblings := map[string][]byte{
+		"subdomains.txt":       []byte("www.example.com\napp.example.com\n"),
+		"urls.txt":             []byte(strings.Join(want.AllURLs, "\n") + "\n"),
+		"vulnerabilities.json": vulns,
+	}
+	inVulns := offsetOf(t, data, `"vuln_urls"`) + 40
+	afterSubdomains := offsetOf(t, data, `"vuln_urls"`)
+	inSubdomains := offsetOf(t, data, `"app.example.com"`)
+	malformed := bytes.Replace(data, []byte(`"final_report": "Final report for example.com"`), []byte(`"final_report": 42`), 1)
+
+	tests := []struct {
+		name      string
+		summary   []byte
+		siblings  map[string][]byte
+		recovered []string
+		rebuilt   []string
+		lost      []string // a subset; the unreached sections are many
+		notLost   []string
+		subs      int
+		vulns     int
+		urls      int
+	}{
+		{
+			name:      "cut in the findings, artifacts present",
+			summary:   data[:inVulns],
+			siblings:  siblings,
+			recovered: []string{"subdomains (2)"},
+			rebuilt:   []string{"vuln_urls (2, from vulnerabilities.json)", "all_urls (3, from urls.txt)"},
+			lost:      []string{"ffuf_entries", "log_lines", "final_report"},
+			notLost:   []string{"vuln_urls", "all_urls"},
+			subs:      2, vulns: 2, urls: 3,
+		},
+		{
+			name:      "cut in the findings, no artifacts",
+			summary:   data[:inVulns],
+			recovered: []string{"subdomains (2)"},
+			lost:      []string{"vuln_urls (truncated)", "all_urls", "log_lines"},
+			subs:      2,
+		},
+		{
+			name:      "cut between sections",
+			summary:   data[:afterSubdomains],
+			recovered: []string{"subdomains (2)"},
+			lost:      []string{"vuln_urls", "all_urls"},
+			notLost:   []string{"vuln_urls (truncated)"},
+			subs:      2,
+		},
+		{
+			name:     "cut in the subdomains",
+			summary:  data[:inSubdomains],
+			siblings: map[string][]byte{"subdomains.txt": siblings["subdomains.txt"]},
+			rebuilt:  []string{"subdomains (2, from subdomains.txt)"},
+			lost:     []string{"vuln_urls", "all_urls"},
+			subs:     2,
+		},
+		{
+			name:      "findings artifact cut too",
+			summary:   data[:inVulns],
+			siblings:  map[string][]byte{"vulnerabilities.json": vulns[:bytes.LastIndex(vulns, []byte(`"Reflected XSS"`))]},
+			recovered: []string{"subdomains (2)"},
+			rebuilt:   []string{"vuln_urls (1, from vulnerabilities.json, itself cut off)"},
+			subs:      2, vulns: 1,
+		},
+		{
+			name:      "malformed section",
+			summary:   malformed,
+			recovered: []string{"subdomains (2)", "vuln_urls (2)", "all_urls (3)", "log_lines (1)"},
+			lost:      []string{"final_report (malformed)"},
+			subs:      2, vulns: 2, urls: 3,
+		},
+	}
+	for _, tt := range tests {
+		result, rec, err := LoadSummary(writeRun(t, tt.summary, tt.siblings))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if rec == nil {
+			t.Errorf("%s: no recovery reported", tt.name)
+			continue
+		}
+		if !reflect.DeepEqual(rec.Recovered, tt.recovered) {
+			t.Errorf("%s: recovered %q, want %q", tt.name, rec.Recovered, tt.recovered)
+		}
+		if !reflect.DeepEqual(rec.Rebuilt, tt.rebuilt) {
+			t.Errorf("%s: rebuilt %q, want %q", tt.name, rec.Rebuilt, tt.rebuilt)
+		}
+		lost := make(map[string]bool)
+		for _, s := range rec.Lost {
+			lost[s] = true
+		}
+		for _, s := range tt.lost {
+			if !lost[s] {
+				t.Errorf("%s: %q not reported lost: %q", tt.name, s, rec.Lost)
+			}
+		}
+		for _, s := range tt.notLost {
+			if lost[s] {
+				t.Errorf("%s: %q reported lost", tt.name, s)
+			}
+		}
+		if len(result.Subdomains) != tt.subs || len(result.VulnURLs) != tt.vulns || len(result.AllURLs) != tt.urls {
+			t.Errorf("%s: %d subdomain(s), %d finding(s), %d URL(s); want %d, %d, %d", tt.name,
+				len(result.Subdomains), len(result.VulnURLs), len(result.AllURLs), tt.subs, tt.vulns, tt.urls)
+		}
+		for i, v := range result.VulnURLs {
+			if !reflect.DeepEqual(v, want.VulnURLs[i]) {
+				t.Errorf("%s: finding %d = %+v, want %+v", tt.name, i, v, want.VulnURLs[i])
+			}
+		}
+	}
+}
+
+// TestLoadSummaryEveryOffset truncates the fixture at every byte and checks
+// that each section holding something is accounted for exactly once and
+// that what is kept is what the run found.
+func TestLoadSummaryEveryOffset(t *testing.T) {
+	want, data := summaryFixture(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "summary.json")
+	for n := 0; n < len(data); n++ {
+		if err := ioutil.WriteFile(file, data[:n], 0644); err != nil {
+			t.Fatal(err)
+		}
+		result, rec, err := LoadSummary(dir)
+		if err != nil {
+			// Only a cut before the first section completes loses it all.
+			if n > offsetOf(t, data, `"vuln_urls"`) {
+				t.Errorf("offset %d: %v", n, err)
+			}
+			continue
+		}
+		if rec == nil {
+			t.Fatalf("offset %d: truncated summary parsed strictly", n)
+		}
+		accounted := make(map[string]int)
+		for _, list := range [][]string{rec.Recovered, rec.Rebuilt, rec.Lost} {
+			for _, s := range list {
+				accounted[strings.Fields(s)[0]]++
+			}
+		}
+		for _, s := range summarySections() {
+			if accounted[s.name] > 1 {
+				t.Errorf("offset %d: section %s accounted for %d times", n, s.name, accounted[s.name])
+			}
+		}
+		for _, s := range []string{"subdomains", "vuln_urls", "all_urls", "log_lines", "final_report"} {
+			if accounted[s] != 1 {
+				t.Errorf("offset %d: section %s not accounted for", n, s)
+			}
+		}
+		if !reflect.DeepEqual(result.Subdomains, want.Subdomains) {
+			t.Errorf("offset %d: subdomains %+v", n, result.Subdomains)
+		}
+		for i, u := range result.AllURLs {
+			if u != want.AllURLs[i] {
+				t.Errorf("offset %d: URL %d = %q", n, i, u)
+			}
+		}
+	}
+}
+
+func TestLoadSummaryReport(t *testing.T) {
+	_, data := summaryFixture(t)
+	if _, rec, err := LoadSummary(writeRun(t, data, nil)); err != nil || rec != nil {
+		t.Errorf("intact summary: recovery %v, err %v", rec, err)
+	}
+
+	if _, _, err := LoadSummary(writeRun(t, data[:1], nil)); err == nil || !strings.HasSuffix(err.Error(), "nothing could be salvaged") {
+		t.Errorf("summary cut after its brace: err = %v", err)
+	}
+	if _, _, err := LoadSummary(t.TempDir()); !os.IsNotExist(err) {
+		t.Errorf("missing summary: err = %v", err)
+	}
+
+	dir := writeRun(t, data[:offsetOf(t, data, `"ffuf_entries"`)], map[string][]byte{"urls.txt": []byte("https://www.example.com/\n")})
+	_, rec, err := LoadSummary(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := rec.String()
+	for _, want := range []string{
+		filepath.Join(dir, "summary.json") + " is damaged (unexpected end of JSON input); going on with what could be salvaged\n",
+		"\n    recovered: subdomains (2), vuln_urls (2)\n",
+		"\n    rebuilt: all_urls (1, from urls.txt)\n",
+		"\n    lost: ffuf_entries, log_lines, final_report, running, ",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report lacks %q:\n%s", want, report)
+		}
+	}
+
+	b, err := LoadBaseline(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hosts, urls, findings := b.Size(); b.Recovery == nil || hosts != 2 || urls != 1 || findings != 2 {
+		t.Errorf("baseline from a damaged summary: %d host(s), %d URL(s), %d finding(s), recovery %v", hosts, urls, findings, b.Recovery)
+	}
+}
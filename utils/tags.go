@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// Tag namespaces shared by every classifier. A tag is written
+// "namespace:value", such as env:staging, party:third, state:parked,
+// owner:ecommerce or access:authenticated.
+const (
+	TagEnv    = "env"
+	TagParty  = "party"
+	TagState  = "state"
+	TagOwner  = "owner"
+	TagAccess = "access"
+)
+
+// splitTag splits "namespace:value" and lower-cases both parts.
+func splitTag(tag string) (ns, value string, ok bool) {
+	parts := strings.SplitN(strings.ToLower(strings.TrimSpace(tag)), ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// AddTag adds tag ("namespace:value") to tags, allocating the map when it
+// is nil, and returns it. Malformed and repeated tags are ignored.
+func AddTag(tags map[string][]string, tag string) map[string][]string {
+	ns, value, ok := splitTag(tag)
+	if !ok {
+		return tags
+	}
+	if tags == nil {
+		tags = make(map[string][]string)
+	}
+	for _, v := range tags[ns] {
+		if v == value {
+			return tags
+		}
+	}
+	tags[ns] = append(tags[ns], value)
+	sort.Strings(tags[ns])
+	return tags
+}
+
+// HasTag reports whether tags carries tag. "namespace:*" matches any value
+// in the namespace.
+func HasTag(tags map[string][]string, tag string) bool {
+	return len(TagsMatching(tags, tag)) > 0
+}
+
+// TagsMatching returns the tags, as "namespace:value", that pattern
+// matches: "*" matches every tag, "namespace:*" every tag in the
+// namespace, and "namespace:value" only itself.
+func TagsMatching(tags map[string][]string, pattern string) []string {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	var out []string
+	for ns, values := range tags {
+		for _, v := range values {
+			tag := ns + ":" + v
+			if pattern == "*" || pattern == ns+":*" || pattern == tag {
+				out = append(out, tag)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// TagRule tags every hostname matching Pattern, a glob such as
+// "*.staging.example.com", with Tags.
+type TagRule struct {
+	Pattern string
+	Tags    []string
+}
+
+// TagRulesFromEnv reads TAG_RULES, semicolon-separated rules of the form
+// "pattern=tag,tag", e.g. "*.stg.example.com=env:staging;shop.example.com=owner:ecommerce".
+func TagRulesFromEnv() ([]TagRule, error) {
+	var rules []TagRule
+	for _, raw := range strings.Split(os.Getenv("TAG_RULES"), ";") {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		parts := strings.SplitN(raw, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("tag rule %q: want pattern=tag,tag", raw)
+		}
+		rule := TagRule{Pattern: strings.ToLower(strings.TrimSpace(parts[0]))}
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("tag rule %q: %v", raw, err)
+		}
+		for _, t := range strings.Split(parts[1], ",") {
+			if _, _, ok := splitTag(t); !ok {
+				return nil, fmt.Errorf("tag rule %q: tag %q is not namespace:value", raw, strings.TrimSpace(t))
+			}
+			rule.Tags = append(rule.Tags, strings.TrimSpace(t))
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// RuleTags returns the tags the rules give host.
+func RuleTags(rules []TagRule, host string) map[string][]string {
+	var tags map[string][]string
+	host = NormalizeHostname(host)
+	for _, r := range rules {
+		if ok, _ := path.Match(r.Pattern, host); ok {
+			for _, t := range r.Tags {
+				tags = AddTag(tags, t)
+			}
+		}
+	}
+	return tags
+}
+
+// mergeTags adds every tag of src to dst and returns dst.
+func mergeTags(dst, src map[string][]string) map[string][]string {
+	for _, t := range TagsMatching(src, "*") {
+		dst = AddTag(dst, t)
+	}
+	return dst
+}
+
+// TagAssets tags the subdomains, web origins and findings of result. Hosts
+// get the tags of the rules matching them and, when the inventory was
+// reconciled, owner and env tags from their inventory row or state:shadow
+// when it does not list them. Origins and findings inherit the tags of
+// their host.
+func TagAssets(result *types.ScanResult, rules []TagRule) {
+	byHost := make(map[string]map[string][]string)
+	hostTags := func(host string) map[string][]string {
+		host = NormalizeHostname(host)
+		if t, ok := byHost[host]; ok {
+			return t
+		}
+		t := RuleTags(rules, host)
+		byHost[host] = t
+		return t
+	}
+	if rec := result.Reconciliation; rec != nil {
+		for _, m := range rec.Matched {
+			t := hostTags(m.Hostname)
+			if m.Owner != "" {
+				t = AddTag(t, TagOwner+":"+m.Owner)
+			}
+			if m.Environment != "" {
+				t = AddTag(t, TagEnv+":"+m.Environment)
+			}
+			byHost[NormalizeHostname(m.Hostname)] = t
+		}
+		for _, h := range rec.Unknown {
+			byHost[NormalizeHostname(h)] = AddTag(hostTags(h), TagState+":shadow")
+		}
+	}
+	for i := range result.Subdomains {
+		s := &result.Subdomains[i]
+		s.Tags = mergeTags(s.Tags, hostTags(s.Hostname))
+	}
+	for i := range result.LiveHosts {
+		lh := &result.LiveHosts[i]
+		lh.Tags = mergeTags(lh.Tags, hostTags(lh.Hostname))
+	}
+	for i := range result.VulnURLs {
+		v := &result.VulnURLs[i]
+		if u, err := url.Parse(v.URL); err == nil && u.Hostname() != "" {
+			v.Tags = mergeTags(v.Tags, hostTags(u.Hostname()))
+		}
+	}
+}
+
+// FormatTags renders tags as a sorted, comma-separated list.
+func FormatTags(tags map[string][]string) string {
+	return strings.Join(TagsMatching(tags, "*"), ", ")
+}
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestTags(t *testing.T) {
+	var tags map[string][]string
+	for _, tag := range []string{"env:staging", "Owner:ECommerce", "env:qa", "env:staging", "malformed", "env:", ":x"} {
+		tags = AddTag(tags, tag)
+	}
+	want := map[string][]string{"env": {"qa", "staging"}, "owner": {"ecommerce"}}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("AddTag built %v", tags)
+	}
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"*", []string{"env:qa", "env:staging", "owner:ecommerce"}},
+		{"env:*", []string{"env:qa", "env:staging"}},
+		{" ENV:Staging ", []string{"env:staging"}},
+		{"env:prod", nil},
+		{"party:*", nil},
+		// Globs inside values are not patterns.
+		{"env:s*", nil},
+	}
+	for _, tt := range tests {
+		if got := TagsMatching(tags, tt.pattern); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("TagsMatching(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+		if got := HasTag(tags, tt.pattern); got != (tt.want != nil) {
+			t.Errorf("HasTag(%q) = %v", tt.pattern, got)
+		}
+	}
+	if got := FormatTags(tags); got != "env:qa, env:staging, owner:ecommerce" {
+		t.Errorf("FormatTags = %q", got)
+	}
+}
+
+func TestTagRulesFromEnv(t *testing.T) {
+	tests := []struct {
+		env  string
+		want []TagRule
+		err  string
+	}{
+		{"", nil, ""},
+		{"*.STG.example.com = env:staging, party:first ; shop.example.com=owner:ecommerce;", []TagRule{
+			{Pattern: "*.stg.example.com", Tags: []string{"env:staging", "party:first"}},
+			{Pattern: "shop.example.com", Tags: []string{"owner:ecommerce"}},
+		}, ""},
+		{"*.example.com", nil, "want pattern=tag,tag"},
+		{"*.example.com=staging", nil, `tag "staging" is not namespace:value`},
+		{"[.example.com=env:qa", nil, "syntax error"},
+	}
+	for _, tt := range tests {
+		t.Setenv("TAG_RULES", tt.env)
+		got, err := TagRulesFromEnv()
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("TAG_RULES=%q: error %v, want %q", tt.env, err, tt.err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("TAG_RULES=%q: %+v", tt.env, got)
+		}
+	}
+}
+
+func TestTagAssets(t *testing.T) {
+	rules := []TagRule{
+		{Pattern: "*.stg.example.com", Tags: []string{"env:staging"}},
+		{Pattern: "*.example.com", Tags: []string{"party:first"}},
+		{Pattern: "status.example.com", Tags: []string{"party:third"}},
+	}
+	result := types.ScanResult{
+		Subdomains: []types.SubdomainResult{
+			{Hostname: "api.stg.example.com"},
+			{Hostname: "Shop.Example.com."},
+			{Hostname: "status.example.com", Tags: map[string][]string{"access": {"public"}}},
+			{Hostname: "forgotten.example.com"},
+			{Hostname: "partner.io"},
+		},
+		LiveHosts: []types.LiveHost{{Hostname: "api.stg.example.com", URL: "https://api.stg.example.com"}},
+		VulnURLs: []types.VulnerabilityResult{
+			{URL: "https://shop.example.com:8443/cart?id=1", Issue: "SQL Injection"},
+			{URL: "not a url", Issue: "Anomaly"},
+		},
+		Reconciliation: &types.Reconciliation{
+			Matched: []types.InventoryMatch{{Hostname: "shop.example.com", Owner: "ecommerce", Environment: "production"}},
+			Unknown: []string{"forgotten.example.com"},
+		},
+	}
+	TagAssets(&result, rules)
+	want := []string{
+		"env:staging, party:first",
+		"env:production, owner:ecommerce, party:first",
+		"access:public, party:first, party:third",
+		"party:first, state:shadow",
+		"",
+	}
+	for i, s := range result.Subdomains {
+		if got := FormatTags(s.Tags); got != want[i] {
+			t.Errorf("%s: tags %q, want %q", s.Hostname, got, want[i])
+		}
+	}
+	if got := FormatTags(result.LiveHosts[0].Tags); got != want[0] {
+		t.Errorf("origin tags %q", got)
+	}
+	if got := FormatTags(result.VulnURLs[0].Tags); got != want[1] {
+		t.Errorf("finding tags %q", got)
+	}
+	if result.VulnURLs[1].Tags != nil {
+		t.Errorf("finding without a host tagged %v", result.VulnURLs[1].Tags)
+	}
+}
+
+func TestScopeDenyTags(t *testing.T) {
+	t.Setenv("SCOPE_ALLOW", "")
+	t.Setenv("SCOPE_DENY", "")
+	t.Setenv("SCOPE_DENY_TAGS", " party:third , env:* ")
+	s := ScopeFromEnv("example.com")
+	if !reflect.DeepEqual(s.DenyTags, []string{"party:third", "env:*"}) {
+		t.Fatalf("DenyTags = %q", s.DenyTags)
+	}
+	s.TagRules = []TagRule{
+		{Pattern: "status.example.com", Tags: []string{"party:third"}},
+		{Pattern: "*.stg.example.com", Tags: []string{"env:staging"}},
+		{Pattern: "www.example.com", Tags: []string{"owner:web"}},
+	}
+	for host, in := range map[string]bool{
+		"www.example.com":     true,
+		"status.example.com":  false,
+		"api.stg.example.com": false,
+		"example.com":         true,
+		"example.org":         false,
+	} {
+		if got := s.HostInScope(host); got != in {
+			t.Errorf("HostInScope(%q) = %v", host, got)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// tailChunk is how much a Tailer reads at a time.
+const tailChunk = 64 << 10
+
+// Tailer follows a file another process appends to, returning its lines as
+// they are completed. A line still being written is held back until its
+// newline arrives. When the file is replaced (rotated), what the old file
+// gained is read before following the new one from its beginning; when it
+// is truncated, it is followed from its beginning again.
+type Tailer struct {
+	path    string
+	f       *os.File
+	info    os.FileInfo
+	offset  int64
+	partial []byte
+}
+
+// NewTailer returns a Tailer of the file at path, which need not exist yet.
+func NewTailer(path string) *Tailer {
+	return &Tailer{path: path}
+}
+
+// Poll returns the lines completed since the last call. A missing file has
+// no lines.
+func (t *Tailer) Poll() ([]string, error) {
+	var lines []string
+	if t.f != nil {
+		var err error
+		if lines, err = t.read(); err != nil {
+			return lines, err
+		}
+	}
+	info, err := os.Stat(t.path)
+	if os.IsNotExist(err) {
+		// Not created yet, or rotated away and not recreated yet.
+		return lines, nil
+	}
+	if err != nil {
+		return lines, err
+	}
+	if t.f != nil && os.SameFile(info, t.info) {
+		if info.Size() >= t.offset {
+			return lines, nil
+		}
+		t.offset, t.partial = 0, nil
+	} else {
+		f, err := os.Open(t.path)
+		if os.IsNotExist(err) {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+		opened, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return lines, err
+		}
+		if t.f != nil {
+			// The old file will not be written again: its unterminated
+			// last line is complete.
+			if len(t.partial) > 0 {
+				lines = append(lines, string(t.partial))
+			}
+			t.f.Close()
+		}
+		t.f, t.info, t.offset, t.partial = f, opened, 0, nil
+	}
+	more, err := t.read()
+	return append(lines, more...), err
+}
+
+// read returns the lines completed in the open file since offset, holding
+// back an unterminated last line.
+func (t *Tailer) read() ([]string, error) {
+	var lines []string
+	buf := make([]byte, tailChunk)
+	for {
+		n, err := t.f.ReadAt(buf, t.offset)
+		t.offset += int64(n)
+		data := append(t.partial, buf[:n]...)
+		for {
+			i := bytes.IndexByte(data, '\n')
+			if i < 0 {
+				break
+			}
+			lines = append(lines, string(bytes.TrimSuffix(data[:i], []byte("\r"))))
+			data = data[i+1:]
+		}
+		t.partial = append([]byte(nil), data...)
+		if err == io.EOF || n == 0 {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+	}
+}
+
+// Close closes the file being followed.
+func (t *Tailer) Close() error {
+	if t.f == nil {
+		return nil
+	}
+	return t.f.Close()
+}
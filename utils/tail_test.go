@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.log")
+	tail := NewTailer(path)
+	defer tail.Close()
+
+	var f *os.File
+	write := func(s string) {
+		t.Helper()
+		if _, err := f.WriteString(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	create := func() {
+		t.Helper()
+		var err error
+		if f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	steps := []struct {
+		name string
+		act  func()
+		want []string
+	}{
+		{"missing file", func() {}, nil},
+		{"created empty", create, nil},
+		{"whole lines", func() { write("[*] one\n[*] two\n") }, []string{"[*] one", "[*] two"}},
+		{"nothing new", func() {}, nil},
+		{"partial line held back", func() { write("[*] thr") }, nil},
+		{"partial line completed", func() { write("ee\n[*] fo") }, []string{"[*] three"}},
+		{"CRLF", func() { write("ur\r\n") }, []string{"[*] four"}},
+		{"empty line", func() { write("\n") }, []string{""}},
+		{"long line", func() { write(strings.Repeat("x", tailChunk+10) + "\n") }, []string{strings.Repeat("x", tailChunk+10)}},
+		{"truncated", func() {
+			f.Truncate(0)
+			write("[*] after truncation\n")
+		}, []string{"[*] after truncation"}},
+		// Rotation: the old file gains a line and an unterminated one
+		// before it is renamed away and a new one is created.
+		{"rotated", func() {
+			write("[*] last of old\n[*] unterminated")
+			f.Close()
+			if err := os.Rename(path, path+".1"); err != nil {
+				t.Fatal(err)
+			}
+			create()
+			write("[*] first of new\n")
+		}, []string{"[*] last of old", "[*] unterminated", "[*] first of new"}},
+		{"rotated away, not recreated", func() {
+			write("[*] before removal\n")
+			f.Close()
+			os.Rename(path, path+".2")
+		}, []string{"[*] before removal"}},
+		{"recreated", func() {
+			create()
+			write("[*] new again\n")
+		}, []string{"[*] new again"}},
+	}
+	for _, st := range steps {
+		st.act()
+		got, err := tail.Poll()
+		if err != nil {
+			t.Fatalf("%s: %v", st.name, err)
+		}
+		if !reflect.DeepEqual(got, st.want) {
+			t.Errorf("%s: lines %q, want %q", st.name, got, st.want)
+		}
+	}
+	f.Close()
+}
+
+// TestTailerConcurrentWriter tails a file while another goroutine appends
+// lines in small, unaligned writes.
+func TestTailerConcurrentWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	var data strings.Builder
+	for i := 0; i < 500; i++ {
+		line := strings.Repeat(string(rune('a'+i%26)), i%37+1)
+		want = append(want, line)
+		data.WriteString(line + "\n")
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s := data.String()
+		for i := 0; i < len(s); i += 7 {
+			end := i + 7
+			if end > len(s) {
+				end = len(s)
+			}
+			f.WriteString(s[i:end])
+		}
+	}()
+	tail := NewTailer(path)
+	defer tail.Close()
+	var got []string
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+		lines, err := tail.Poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, lines...)
+	}
+	f.Close()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %d line(s), want %d; first difference at %d", len(got), len(want), firstDifference(got, want))
+	}
+}
+
+func firstDifference(a, b []string) int {
+	for i := range a {
+		if i >= len(b) || a[i] != b[i] {
+			return i
+		}
+	}
+	return len(a)
+}
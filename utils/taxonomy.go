@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// IssueType is one entry of the finding taxonomy. ID is the stable,
+// machine-readable identifier emitters set on VulnerabilityResult.Type;
+// CWE is 0 where the weakness varies per finding.
+type IssueType struct {
+	ID          string
+	Name        string
+	CWE         int
+	Severity    string
+	Description string
+	Remediation string
+}
+
+// issueTypes is the registry of every issue type the tool emits.
+var issueTypes = []IssueType{
+	{"sqli", "SQL Injection", 89, "High",
+		"User input reaches a SQL query unescaped.",
+		"Use parameterized queries or prepared statements for every database access."},
+	{"xss", "XSS", 79, "Medium",
+		"User input is reflected into a page as executable script.",
+		"Encode output for its context and adopt a restrictive Content-Security-Policy."},
+	{"reflected-parameter", "Reflected Parameter", 79, "Low",
+		"A parameter is reflected with HTML special characters left unfiltered.",
+		"Encode reflected values for their output context."},
+	{"dalfox-grep", "Dalfox Grep Match", 0, "Low",
+		"A response matched one of dalfox's patterns for errors, secrets or injection points; no payload was confirmed.",
+		"Review the matched response and confirm the issue by hand."},
+	{"crlf-injection", "CRLF Injection", 93, "Medium",
+		"Encoded line breaks in the URL are written into response headers, allowing header injection and response splitting.",
+		"Reject or strip CR and LF characters from any input copied into response headers."},
+	{"cors-misconfiguration", "CORS Misconfiguration", 942, "Medium",
+		"Cross-origin requests are allowed from untrusted origins.",
+		"Allow only an explicit list of trusted origins; never reflect Origin with credentials."},
+	{"exposed-git", "Exposed Git Repository", 527, "High",
+		"The .git directory is served, exposing source and history.",
+		"Deny web access to .git and deploy build artifacts instead of checkouts."},
+	{"exposed-svn", "Exposed SVN Metadata", 527, "Medium",
+		"Subversion metadata is served, exposing file listings and source.",
+		"Deny web access to .svn and deploy exports instead of working copies."},
+	{"exposed-hg", "Exposed Mercurial Repository", 527, "Medium",
+		"The .hg directory is served, exposing source and history.",
+		"Deny web access to .hg and deploy build artifacts instead of checkouts."},
+	{"exposed-jenkinsfile", "Exposed Jenkinsfile", 538, "Low",
+		"The Jenkins pipeline definition is served.",
+		"Remove CI definitions from the web root."},
+	{"exposed-gitlab-ci", "Exposed GitLab CI Config", 538, "Low",
+		"The GitLab CI configuration is served.",
+		"Remove CI definitions from the web root."},
+	{"exposed-circleci", "Exposed CircleCI Config", 538, "Low",
+		"The CircleCI configuration is served.",
+		"Remove CI definitions from the web root."},
+	{"weak-tls-protocol", "Weak TLS Protocol", 327, "Low",
+		"A deprecated TLS protocol version is accepted.",
+		"Disable TLS 1.0 and 1.1; accept TLS 1.2 and 1.3 only."},
+	{"weak-tls-cipher", "Weak TLS Cipher", 327, "Low",
+		"A cipher suite with known weaknesses is accepted.",
+		"Restrict the server to AEAD cipher suites with forward secrecy."},
+	{"testssl-finding", "testssl.sh Finding", 0, "Medium",
+		"testssl.sh rated a TLS protocol, cipher or implementation issue MEDIUM or higher.",
+		"Disable the protocol, cipher or feature testssl.sh names, or update the TLS library."},
+	{"http-trace", "HTTP TRACE Enabled", 693, "Low",
+		"The TRACE method is advertised, enabling cross-site tracing.",
+		"Disable the TRACE method on the server."},
+	{"risky-http-methods", "Risky HTTP Methods Enabled", 650, "Medium",
+		"PUT or DELETE is advertised on a path that is not an API.",
+		"Allow only the methods each path needs and require authorization for writes."},
+	{"content-type-mismatch", "Content-Type Mismatch", 693, "Low",
+		"An API response reflecting input can be rendered or sniffed as HTML.",
+		"Serve API responses as application/json with X-Content-Type-Options: nosniff."},
+	{"reflected-file-download", "Reflected File Download", 116, "Medium",
+		"The download filename is taken from a request parameter.",
+		"Use a fixed filename in Content-Disposition and never derive it from input."},
+	{"subdomain-takeover", "Subdomain Takeover", 284, "High",
+		"A DNS record points at an unclaimed third-party resource.",
+		"Remove the dangling record or reclaim the resource it points to."},
+	{"dangling-cname", "Dangling CNAME Record", 672, "Medium",
+		"A CNAME record points at a name that does not exist.",
+		"Remove the record, or register the name it points to if it is still needed."},
+	{"nuclei-template", "Nuclei Template Match", 0, "Info",
+		"A nuclei template matched; the template defines the weakness.",
+		"Follow the remediation in the matched template's references."},
+	{"nessus-plugin", "Nessus Plugin Result", 0, "Info",
+		"A Nessus plugin reported an issue on the host; the plugin defines the weakness and its severity.",
+		"Follow the solution in the Nessus plugin's description."},
+	{"nikto-finding", "Nikto Finding", 0, "Low",
+		"nikto reported a potential weakness.",
+		"Review the nikto message and references for the affected path."},
+	{"nikto-informational", "Nikto Informational", 200, "Info",
+		"nikto reported information about the server.",
+		"Remove version banners and unnecessary headers where practical."},
+	{"wordpress-vulnerable-component", "WordPress Vulnerable Component", 1395, "Medium",
+		"The WordPress core, a plugin or a theme has known vulnerabilities.",
+		"Update the component to the fixed version or remove it."},
+	{"wordpress-user-enumeration", "WordPress User Enumeration", 200, "Low",
+		"WordPress usernames can be enumerated.",
+		"Restrict the REST users endpoint and author archives to authenticated users."},
+	{"wordpress-interesting-finding", "WordPress Interesting Finding", 200, "Info",
+		"wpscan found a file, header or feature worth reviewing.",
+		"Remove files and features the site does not need from public access."},
+	{"expired-security-txt", "Expired security.txt", 0, "Info",
+		"The security.txt file is past its Expires date, so its contacts may be stale.",
+		"Review the disclosure contacts and publish security.txt with a new Expires date."},
+	{"response-anomaly", "Per-Host Response Anomaly", 0, "Info",
+		"One host answers a path unlike the other hosts serving the same application, which often marks a misconfigured or outdated instance.",
+		"Compare the host's configuration and deployment with its peers and bring it in line."},
+	{"http-desync", "Possible HTTP Request Smuggling", 444, "Low",
+		"A front end and its back end appear to disagree on where a request ends, the timing indicator of request smuggling.",
+		"Have the front end normalize ambiguous requests, reject those with both Content-Length and Transfer-Encoding, and use HTTP/2 to the back end."},
+	{"hop-by-hop-header", "Hop-by-Hop Header Handling", 444, "Low",
+		"A front end strips or forwards headers declared hop-by-hop in Connection in a way that changes what the back end sees.",
+		"Do not let clients declare the forwarding headers the front end adds hop-by-hop, and drop every header listed in Connection."},
+	{"mixed-content", "Mixed Content", 319, "Low",
+		"A page served over HTTPS loads a script, stylesheet or image over plain HTTP, which an attacker on the network can read or replace.",
+		"Load every resource over HTTPS, or send Content-Security-Policy: upgrade-insecure-requests."},
+	{"insecure-form-action", "Insecure Form Action", 319, "Low",
+		"A form on a page served over HTTPS submits to a plain HTTP URL, sending what is entered in cleartext.",
+		"Point the form's action at an HTTPS URL, or leave it relative to the page."},
+	{"exposed-secret", "Exposed Secret", 798, "High",
+		"A credential or API key is embedded in a file the site serves.",
+		"Revoke and rotate the secret, then keep it server-side out of served files."},
+}
+
+// LookupIssueType returns the registered issue type with id.
+func LookupIssueType(id string) (IssueType, bool) {
+	for _, t := range issueTypes {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return IssueType{}, false
+}
+
+// unclassifiedTag marks findings whose Type is not registered.
+const unclassifiedTag = TagState + ":unclassified"
+
+// IngestFindings enforces the taxonomy on vulns: registered types get the
+// type's CWE unless the tool supplied a more specific one, and findings
+// whose Type is not registered are kept, tagged state:unclassified, with a
+// warning per type the first time they are seen.
+func IngestFindings(vulns []types.VulnerabilityResult) ([]types.VulnerabilityResult, []string) {
+	unclassified := make(map[string]int)
+	for i, v := range vulns {
+		t, ok := LookupIssueType(v.Type)
+		if !ok {
+			if !HasTag(v.Tags, unclassifiedTag) {
+				vulns[i].Tags = AddTag(v.Tags, unclassifiedTag)
+				unclassified[v.Type+"|"+v.Issue]++
+			}
+			continue
+		}
+		if v.CWE == 0 {
+			vulns[i].CWE = t.CWE
+		}
+	}
+	var warnings []string
+	for key, n := range unclassified {
+		warnings = append(warnings, fmt.Sprintf("%d finding(s) with unregistered type %q kept as %s", n, key, unclassifiedTag))
+	}
+	sort.Strings(warnings)
+	return vulns, warnings
+}
@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// emittedTypes returns the finding types the module's source sets, with
+// the position of each: string literals given for a Type field, keyed or
+// positional, assigned to one, or assigned to a local named typ.
+func emittedTypes(t *testing.T, root string) map[string]string {
+	t.Helper()
+	fset := token.NewFileSet()
+	var files []*ast.File
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && strings.HasPrefix(info.Name(), ".") && path != root {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// typeField is the index of the Type field of the structs that have
+	// one, for their positional literals.
+	typeField := make(map[string]int)
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			spec, ok := n.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+			st, ok := spec.Type.(*ast.StructType)
+			if !ok {
+				return true
+			}
+			i := 0
+			for _, field := range st.Fields.List {
+				for _, name := range field.Names {
+					if name.Name == "Type" {
+						typeField[spec.Name.Name] = i
+					}
+					i++
+				}
+			}
+			return true
+		})
+	}
+
+	found := make(map[string]string)
+	add := func(e ast.Expr) {
+		if lit, ok := e.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			if s, err := strconv.Unquote(lit.Value); err == nil {
+				if _, dup := found[s]; !dup {
+					found[s] = fset.Position(lit.Pos()).String()
+				}
+			}
+		}
+	}
+	positional := func(typ ast.Expr, lit *ast.CompositeLit) {
+		id, ok := typ.(*ast.Ident)
+		if !ok {
+			return
+		}
+		if i, ok := typeField[id.Name]; ok && i < len(lit.Elts) {
+			if _, keyed := lit.Elts[0].(*ast.KeyValueExpr); !keyed {
+				add(lit.Elts[i])
+			}
+		}
+	}
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CompositeLit:
+				positional(n.Type, n)
+				if at, ok := n.Type.(*ast.ArrayType); ok {
+					for _, e := range n.Elts {
+						if lit, ok := e.(*ast.CompositeLit); ok && lit.Type == nil {
+							positional(at.Elt, lit)
+						}
+					}
+				}
+			case *ast.KeyValueExpr:
+				if id, ok := n.Key.(*ast.Ident); ok && id.Name == "Type" {
+					add(n.Value)
+				}
+			case *ast.AssignStmt:
+				if len(n.Lhs) != len(n.Rhs) {
+					break
+				}
+				for i, lhs := range n.Lhs {
+					switch l := lhs.(type) {
+					case *ast.SelectorExpr:
+						if l.Sel.Name == "Type" {
+							add(n.Rhs[i])
+						}
+					case *ast.Ident:
+						if l.Name == "typ" {
+							add(n.Rhs[i])
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+	return found
+}
+
+// TestTaxonomyComplete checks that every type an emitter sets is
+// registered and every registered type has an emitter.
+func TestTaxonomyComplete(t *testing.T) {
+	emitted := emittedTypes(t, "..")
+	var missing []string
+	for id, pos := range emitted {
+		if _, ok := LookupIssueType(id); !ok {
+			missing = append(missing, id+" ("+pos+")")
+		}
+	}
+	sort.Strings(missing)
+	for _, m := range missing {
+		t.Errorf("emitted type %s is not registered", m)
+	}
+	seen := make(map[string]bool)
+	for _, it := range issueTypes {
+		if seen[it.ID] {
+			t.Errorf("type %q registered twice", it.ID)
+		}
+		seen[it.ID] = true
+		if it.Name == "" || it.Description == "" || it.Remediation == "" {
+			t.Errorf("type %q lacks a name, description or remediation", it.ID)
+		}
+		if _, ok := emitted[it.ID]; !ok {
+			t.Errorf("registered type %q is never emitted", it.ID)
+		}
+	}
+}
+
+func TestIngestFindings(t *testing.T) {
+	tests := []struct {
+		name         string
+		in           types.VulnerabilityResult
+		wantCWE      int
+		unclassified bool
+	}{
+		{"registered", types.VulnerabilityResult{Type: "sqli"}, 89, false},
+		{"tool CWE wins", types.VulnerabilityResult{Type: "nuclei-template", CWE: 918}, 918, false},
+		{"registered without CWE", types.VulnerabilityResult{Type: "nuclei-template"}, 0, false},
+		{"unregistered", types.VulnerabilityResult{Type: "open-redirect", Issue: "Open Redirect"}, 0, true},
+		{"untyped", types.VulnerabilityResult{Issue: "Legacy Finding"}, 0, true},
+	}
+	for _, tt := range tests {
+		out, warnings := IngestFindings([]types.VulnerabilityResult{tt.in})
+		if len(out) != 1 {
+			t.Errorf("%s: %d finding(s) out, want the one in", tt.name, len(out))
+			continue
+		}
+		if out[0].CWE != tt.wantCWE {
+			t.Errorf("%s: CWE %d, want %d", tt.name, out[0].CWE, tt.wantCWE)
+		}
+		if got := HasTag(out[0].Tags, "state:unclassified"); got != tt.unclassified {
+			t.Errorf("%s: unclassified tag %v, want %v", tt.name, got, tt.unclassified)
+		}
+		if (len(warnings) == 1) != tt.unclassified {
+			t.Errorf("%s: warnings %q", tt.name, warnings)
+		}
+		// A later stage ingesting the same findings does not warn again.
+		if _, again := IngestFindings(out); len(again) != 0 {
+			t.Errorf("%s: warned again: %q", tt.name, again)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// FormatTechnologies renders a stack as "nginx 1.18.0, PHP 7.4.3,
+// WordPress 5.9", names without a version standing alone.
+func FormatTechnologies(techs []types.Technology) string {
+	parts := make([]string, 0, len(techs))
+	for _, t := range techs {
+		if t.Version != "" {
+			parts = append(parts, t.Name+" "+t.Version)
+		} else {
+			parts = append(parts, t.Name)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"crypto/tls"
+	"math"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// Request phases a TimingRecorder times.
+const (
+	PhaseDNS = iota
+	PhaseConnect
+	PhaseTLS
+	PhaseTTFB
+	phaseCount
+)
+
+// TimingRecorder collects the phase timings of the requests sent through
+// the clients it wraps, per host.
+type TimingRecorder struct {
+	mu    sync.Mutex
+	hosts map[string]*hostSamples
+}
+
+type hostSamples struct {
+	requests int
+	phases   [phaseCount][]time.Duration
+}
+
+// NewTimingRecorder returns an empty recorder.
+func NewTimingRecorder() *TimingRecorder {
+	return &TimingRecorder{hosts: make(map[string]*hostSamples)}
+}
+
+// Reset forgets every timing recorded so far.
+func (r *TimingRecorder) Reset() {
+	r.mu.Lock()
+	r.hosts = make(map[string]*hostSamples)
+	r.mu.Unlock()
+}
+
+// Client returns client with the phases of its requests timed into r.
+func (r *TimingRecorder) Client(client *http.Client) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c := *client
+	c.Transport = &timingTransport{base: base, rec: r}
+	return &c
+}
+
+// record adds the phases a request to host went through.
+func (r *TimingRecorder) record(host string, phases [phaseCount]time.Duration, seen [phaseCount]bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h := r.hosts[host]
+	if h == nil {
+		h = &hostSamples{}
+		r.hosts[host] = h
+	}
+	h.requests++
+	for i := range phases {
+		if seen[i] {
+			h.phases[i] = append(h.phases[i], phases[i])
+		}
+	}
+}
+
+// Summary returns the median and 95th percentile of each phase per host,
+// sorted by host.
+func (r *TimingRecorder) Summary() []types.HostTiming {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]types.HostTiming, 0, len(r.hosts))
+	for host, h := range r.hosts {
+		out = append(out, types.HostTiming{
+			Host:     host,
+			Requests: h.requests,
+			DNS:      phaseTiming(h.phases[PhaseDNS]),
+			Connect:  phaseTiming(h.phases[PhaseConnect]),
+			TLS:      phaseTiming(h.phases[PhaseTLS]),
+			TTFB:     phaseTiming(h.phases[PhaseTTFB]),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+	return out
+}
+
+// phaseTiming aggregates the samples of one phase.
+func phaseTiming(samples []time.Duration) types.PhaseTiming {
+	if len(samples) == 0 {
+		return types.PhaseTiming{}
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return types.PhaseTiming{
+		Count:    len(sorted),
+		MedianMS: durationMS(percentile(sorted, 50)),
+		P95MS:    durationMS(percentile(sorted, 95)),
+	}
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// durationMS returns d in milliseconds, to the microsecond.
+func durationMS(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
+
+// timingTransport times the phases of each request with httptrace.
+type timingTransport struct {
+	base http.RoundTripper
+	rec  *TimingRecorder
+}
+
+func (t *timingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var (
+		mu     sync.Mutex
+		starts [phaseCount]time.Time
+		phases [phaseCount]time.Duration
+		seen   [phaseCount]bool
+	)
+	start := func(phase int) {
+		mu.Lock()
+		// A dial to several addresses starts connecting more than once;
+		// the phase runs from the first attempt.
+		if starts[phase].IsZero() {
+			starts[phase] = time.Now()
+		}
+		mu.Unlock()
+	}
+	done := func(phase int, ok bool) {
+		mu.Lock()
+		if ok && !seen[phase] && !starts[phase].IsZero() {
+			phases[phase], seen[phase] = time.Since(starts[phase]), true
+		}
+		mu.Unlock()
+	}
+	trace := &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { start(PhaseDNS) },
+		DNSDone:              func(info httptrace.DNSDoneInfo) { done(PhaseDNS, info.Err == nil) },
+		ConnectStart:         func(string, string) { start(PhaseConnect) },
+		ConnectDone:          func(_, _ string, err error) { done(PhaseConnect, err == nil) },
+		TLSHandshakeStart:    func() { start(PhaseTLS) },
+		TLSHandshakeDone:     func(_ tls.ConnectionState, err error) { done(PhaseTLS, err == nil) },
+		WroteRequest:         func(info httptrace.WroteRequestInfo) { start(PhaseTTFB) },
+		GotFirstResponseByte: func() { done(PhaseTTFB, true) },
+	}
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	mu.Lock()
+	t.rec.record(req.URL.Hostname(), phases, seen)
+	mu.Unlock()
+	return resp, err
+}
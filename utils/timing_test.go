@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPhaseTiming(t *testing.T) {
+	ms := func(n ...int) []time.Duration {
+		var out []time.Duration
+		for _, v := range n {
+			out = append(out, time.Duration(v)*time.Millisecond)
+		}
+		return out
+	}
+	twenty := ms(20, 19, 18, 17, 16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1)
+	tests := []struct {
+		name        string
+		samples     []time.Duration
+		median, p95 float64
+	}{
+		{"no samples", nil, 0, 0},
+		{"one sample", ms(7), 7, 7},
+		{"two samples", ms(30, 10), 10, 30},
+		{"odd count", ms(5, 1, 3), 3, 5},
+		{"twenty samples", twenty, 10, 19},
+		{"outlier", append(twenty, 2000*time.Millisecond), 11, 20},
+		{"sub-millisecond", []time.Duration{1500 * time.Microsecond}, 1.5, 1.5},
+	}
+	for _, tt := range tests {
+		got := phaseTiming(tt.samples)
+		if got.Count != len(tt.samples) || got.MedianMS != tt.median || got.P95MS != tt.p95 {
+			t.Errorf("%s: %+v, want median %v p95 %v", tt.name, got, tt.median, tt.p95)
+		}
+	}
+	// The samples are left in the order they were recorded.
+	if twenty[0] != 20*time.Millisecond {
+		t.Error("phaseTiming sorted the samples in place")
+	}
+}
+
+// slowServer answers after delay, before writing anything.
+func slowServer(delay time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		fmt.Fprint(w, "slow answer")
+	}
+}
+
+// get sends n requests with client, reading each body so the connection
+// is reused.
+func get(t *testing.T, client *http.Client, url string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+func TestTimingRecorder(t *testing.T) {
+	const delay = 60 * time.Millisecond
+	srv := httptest.NewServer(slowServer(delay))
+	defer srv.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fast.Close()
+
+	rec := NewTimingRecorder()
+	client := rec.Client(&http.Client{Transport: &http.Transport{}})
+	get(t, client, srv.URL, 3)
+	// A name is resolved before connecting.
+	get(t, client, fmt.Sprintf("http://localhost:%d", fast.Listener.Addr().(*net.TCPAddr).Port), 1)
+
+	got := rec.Summary()
+	if len(got) != 2 || got[0].Host != "127.0.0.1" || got[1].Host != "localhost" {
+		t.Fatalf("summary %+v", got)
+	}
+	slow := got[0]
+	// The connection is reused: one dial, no DNS or TLS, three waits.
+	if slow.Requests != 3 || slow.Connect.Count != 1 || slow.DNS.Count != 0 || slow.TLS.Count != 0 || slow.TTFB.Count != 3 {
+		t.Errorf("slow host %+v", slow)
+	}
+	if min := float64(delay / time.Millisecond); slow.TTFB.MedianMS < min || slow.TTFB.P95MS < slow.TTFB.MedianMS {
+		t.Errorf("slow host TTFB %+v, want at least %vms", slow.TTFB, min)
+	}
+	if slow.Connect.MedianMS >= slow.TTFB.MedianMS {
+		t.Errorf("slow host connect %+v slower than its TTFB %+v", slow.Connect, slow.TTFB)
+	}
+	if local := got[1]; local.DNS.Count != 1 || local.TTFB.Count != 1 || local.TTFB.MedianMS >= slow.TTFB.MedianMS {
+		t.Errorf("fast host %+v", local)
+	}
+
+	rec.Reset()
+	if got := rec.Summary(); len(got) != 0 {
+		t.Errorf("summary after Reset %+v", got)
+	}
+	// A failed request counts, with the phases it went through.
+	srv.Close()
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Fatal("request to a closed server succeeded")
+	}
+	if got := rec.Summary(); len(got) != 1 || got[0].Requests != 1 || got[0].Connect.Count != 0 || got[0].TTFB.Count != 0 {
+		t.Errorf("summary after a failed request %+v", got)
+	}
+}
+
+func TestTimingRecorderTLS(t *testing.T) {
+	const delay = 40 * time.Millisecond
+	srv := httptest.NewTLSServer(slowServer(delay))
+	defer srv.Close()
+	rec := NewTimingRecorder()
+	get(t, rec.Client(srv.Client()), srv.URL, 2)
+	got := rec.Summary()
+	if len(got) != 1 || got[0].TLS.Count != 1 || got[0].TLS.MedianMS <= 0 || got[0].TTFB.Count != 2 ||
+		got[0].TTFB.MedianMS < float64(delay/time.Millisecond) {
+		t.Errorf("summary %+v", got)
+	}
+}
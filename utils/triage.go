@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// Triage dispositions an operator can assign to a finding.
+const (
+	DispositionConfirmed     = "confirmed"
+	DispositionFalsePositive = "false-positive"
+	DispositionDuplicate     = "duplicate"
+	DispositionNeedsRetest   = "needs-retest"
+)
+
+// TriageRecord is the disposition of one finding, keyed by its fingerprint.
+type TriageRecord struct {
+	Fingerprint string    `json:"fingerprint"`
+	Issue       string    `json:"issue"`
+	URL         string    `json:"url"`
+	Disposition string    `json:"disposition"`
+	Note        string    `json:"note,omitempty"`
+	Updated     time.Time `json:"updated"`
+}
+
+// TriageStore holds the dispositions for a target. It is kept in the
+// target's knowledge base so dispositions carry over to later runs, and
+// runs sharing it merge their dispositions on every save.
+type TriageStore struct {
+	path    string
+	mu      sync.Mutex
+	records map[string]TriageRecord
+}
+
+// TriageStorePath returns the default triage store location for target,
+// in its knowledge base.
+func TriageStorePath(target string) (string, error) {
+	kb, err := OpenKnowledgeBase(target)
+	if err != nil {
+		return "", err
+	}
+	return kb.Path(KBTriage), nil
+}
+
+// LoadTriageStore reads the store at path. A missing file yields an empty store.
+func LoadTriageStore(path string) (*TriageStore, error) {
+	t := &TriageStore{path: path, records: make(map[string]TriageRecord)}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	records, err := decodeTriageRecords(data)
+	if err != nil {
+		return nil, fmt.Errorf("triage store %s: %v", path, err)
+	}
+	for _, r := range records {
+		t.records[r.Fingerprint] = r
+	}
+	return t, nil
+}
+
+func decodeTriageRecords(data []byte) ([]TriageRecord, error) {
+	var records []TriageRecord
+	if data == nil {
+		return records, nil
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// mergeTriageRecords combines two sets of dispositions; where both hold
+// one for a finding the more recently updated wins.
+func mergeTriageRecords(a, b []TriageRecord) []TriageRecord {
+	byFP := make(map[string]TriageRecord)
+	for _, r := range append(a, b...) {
+		if cur, ok := byFP[r.Fingerprint]; !ok || r.Updated.After(cur.Updated) {
+			byFP[r.Fingerprint] = r
+		}
+	}
+	t := &TriageStore{records: byFP}
+	return t.sortedLocked(nil)
+}
+
+// Lookup returns the recorded disposition for v, if any.
+func (t *TriageStore) Lookup(v types.VulnerabilityResult) (TriageRecord, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.records[FindingFingerprint(v)]
+	return r, ok
+}
+
+// Record sets the disposition and note for v and saves the store, merging
+// in what other runs saved meanwhile.
+func (t *TriageStore) Record(v types.VulnerabilityResult, disposition, note string) error {
+	switch disposition {
+	case DispositionConfirmed, DispositionFalsePositive, DispositionDuplicate, DispositionNeedsRetest:
+	default:
+		return fmt.Errorf("unknown disposition %q", disposition)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fp := FindingFingerprint(v)
+	rec := TriageRecord{
+		Fingerprint: fp,
+		Issue:       v.Issue,
+		URL:         v.URL,
+		Disposition: disposition,
+		Note:        note,
+		Updated:     time.Now(),
+	}
+	t.records[fp] = rec
+	return UpdateFile(t.path, func(cur []byte) ([]byte, error) {
+		saved, err := decodeTriageRecords(cur)
+		if err != nil {
+			return nil, fmt.Errorf("triage store %s: %v", t.path, err)
+		}
+		merged := mergeTriageRecords(saved, []TriageRecord{rec})
+		for _, r := range merged {
+			t.records[r.Fingerprint] = r
+		}
+		return json.MarshalIndent(merged, "", "  ")
+	})
+}
+
+// Apply copies recorded dispositions onto matching findings and returns how
+// many findings were already triaged.
+func (t *TriageStore) Apply(vulns []types.VulnerabilityResult) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for i := range vulns {
+		if r, ok := t.records[FindingFingerprint(vulns[i])]; ok {
+			vulns[i].Disposition = r.Disposition
+			vulns[i].TriageNote = r.Note
+			n++
+		}
+	}
+	return n
+}
+
+// Counts returns how many findings carry each disposition.
+func (t *TriageStore) Counts() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts := make(map[string]int)
+	for _, r := range t.records {
+		counts[r.Disposition]++
+	}
+	return counts
+}
+
+// WriteRun writes the records covering vulns to path, usually the run's triage.json.
+func (t *TriageStore) WriteRun(path string, vulns []types.VulnerabilityResult) error {
+	keep := make(map[string]bool)
+	for _, v := range vulns {
+		keep[FindingFingerprint(v)] = true
+	}
+	t.mu.Lock()
+	records := t.sortedLocked(keep)
+	t.mu.Unlock()
+	return writeTriageRecords(path, records)
+}
+
+// sortedLocked returns the records (restricted to keep when non-nil) in a
+// stable order. The caller must hold t.mu.
+func (t *TriageStore) sortedLocked(keep map[string]bool) []TriageRecord {
+	records := []TriageRecord{}
+	for fp, r := range t.records {
+		if keep == nil || keep[fp] {
+			records = append(records, r)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Issue != records[j].Issue {
+			return records[i].Issue < records[j].Issue
+		}
+		return records[i].URL < records[j].URL
+	})
+	return records
+}
+
+// writeTriageRecords writes records through a temporary file so an
+// interrupted save never leaves a truncated store behind.
+func writeTriageRecords(path string, records []TriageRecord) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+func TestTriageStoreCarriesForward(t *testing.T) {
+	dir := t.TempDir()
+	store := filepath.Join(dir, "kb", "triage.json")
+	sqli := types.VulnerabilityResult{URL: "https://shop.example.com/item?id=4&cat=2", Issue: "SQL Injection", Parameter: "id (GET)", Detail: "boolean-based blind"}
+	xss := types.VulnerabilityResult{URL: "https://www.example.com/search?q=1", Issue: "Reflected XSS"}
+
+	// First run: the operator triages two findings.
+	first, err := LoadTriageStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Record(sqli, DispositionConfirmed, "dumped the users table"); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Record(xss, DispositionFalsePositive, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Record(xss, "wontfix", ""); err == nil {
+		t.Error("unknown disposition recorded")
+	}
+
+	// Second run: the same injection point, reported with other details,
+	// and findings the operator has not seen.
+	second, err := LoadTriageStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vulns := []types.VulnerabilityResult{
+		{URL: sqli.URL, Issue: "sql injection", Parameter: "id (GET)", Detail: "time-based blind"},
+		{URL: sqli.URL, Issue: "SQL Injection", Parameter: "cat (GET)"},
+		{URL: "https://www.example.com/search?q=2", Issue: "Reflected XSS"},
+	}
+	if n := second.Apply(vulns); n != 1 {
+		t.Errorf("Apply carried %d disposition(s), want 1", n)
+	}
+	if vulns[0].Disposition != DispositionConfirmed || vulns[0].TriageNote != "dumped the users table" {
+		t.Errorf("carried forward: %+v", vulns[0])
+	}
+	for _, v := range vulns[1:] {
+		if v.Disposition != "" {
+			t.Errorf("untriaged finding got %q: %+v", v.Disposition, v)
+		}
+	}
+	if counts := second.Counts(); counts[DispositionConfirmed] != 1 || counts[DispositionFalsePositive] != 1 {
+		t.Errorf("Counts() = %v", counts)
+	}
+
+	// The run's triage.json covers only its own findings.
+	runFile := filepath.Join(dir, "run", "triage.json")
+	if err := second.WriteRun(runFile, vulns); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(runFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written []TriageRecord
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 1 || written[0].Fingerprint != FindingFingerprint(sqli) || written[0].Disposition != DispositionConfirmed {
+		t.Errorf("run triage.json: %+v", written)
+	}
+	if err := second.WriteRun(filepath.Join(dir, "empty", "triage.json"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dir, "empty", "triage.json")); string(data) != "[]" {
+		t.Errorf("run without triaged findings wrote %q", data)
+	}
+}
+
+// TestTriageStoreMerge checks that runs sharing a store keep each other's
+// dispositions, the latest winning for a finding both triaged.
+func TestTriageStoreMerge(t *testing.T) {
+	store := filepath.Join(t.TempDir(), "triage.json")
+	a, _ := LoadTriageStore(store)
+	b, _ := LoadTriageStore(store)
+	f1 := types.VulnerabilityResult{URL: "https://a.example.com/", Issue: "Open Redirect"}
+	f2 := types.VulnerabilityResult{URL: "https://b.example.com/", Issue: "Open Redirect"}
+
+	if err := a.Record(f1, DispositionNeedsRetest, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Record(f2, DispositionDuplicate, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Record(f1, DispositionConfirmed, "retested"); err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := b.Lookup(f1); !ok || r.Disposition != DispositionConfirmed {
+		t.Errorf("b: %+v", r)
+	}
+
+	reloaded, err := LoadTriageStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r1, ok1 := reloaded.Lookup(f1)
+	r2, ok2 := reloaded.Lookup(f2)
+	if !ok1 || r1.Disposition != DispositionConfirmed || r1.Note != "retested" || !ok2 || r2.Disposition != DispositionDuplicate {
+		t.Errorf("saved store: %+v, %+v", r1, r2)
+	}
+
+	if err := ioutil.WriteFile(store, []byte(`[{"fingerprint":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTriageStore(store); err == nil {
+		t.Error("corrupt store: no error")
+	}
+	if err := a.Record(f2, DispositionConfirmed, ""); err == nil {
+		t.Error("recording over a corrupt store: no error")
+	}
+}
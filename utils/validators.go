@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// StageValidator checks what a stage produced. Warnings mark the stage
+// completed-with-warnings; an error marks it failed-validation.
+type StageValidator func(result *types.ScanResult, outDir string) (warnings []string, err error)
+
+// ValidateEnumeration requires at least one subdomain and warns when the
+// apex itself is missing from the results.
+func ValidateEnumeration(target string) StageValidator {
+	return func(result *types.ScanResult, outDir string) ([]string, error) {
+		if len(result.Subdomains) == 0 {
+			return nil, fmt.Errorf("enumeration produced no subdomains for %s", target)
+		}
+		for _, s := range result.Subdomains {
+			if strings.EqualFold(s.Hostname, target) {
+				return nil, nil
+			}
+		}
+		return []string{"apex " + target + " missing from enumeration results"}, nil
+	}
+}
+
+// ValidateURLs checks that every line of urls.txt parses as an absolute URL.
+func ValidateURLs(result *types.ScanResult, outDir string) ([]string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(outDir, "urls.txt"))
+	if err != nil {
+		return nil, fmt.Errorf("urls.txt unreadable: %v", err)
+	}
+	bad := 0
+	var example string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		u, err := url.Parse(line)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			if bad == 0 {
+				example = line
+			}
+			bad++
+		}
+	}
+	if bad > 0 {
+		return []string{fmt.Sprintf("%d line(s) in urls.txt are not valid URLs (e.g. %q)", bad, example)}, nil
+	}
+	return nil, nil
+}
+
+// ValidateFuzzing warns about ffuf entries that carry no usable data.
+func ValidateFuzzing(result *types.ScanResult, outDir string) ([]string, error) {
+	var warnings []string
+	for _, f := range result.FfufEntries {
+		if f.Path == "" || f.Status == 0 {
+			warnings = append(warnings, fmt.Sprintf("ffuf entry without path or status: %+v", f))
+		}
+	}
+	return warnings, nil
+}
+
+// ValidateVulnerabilities checks that vulnerabilities.json round-trips
+// through the VulnerabilityResult struct and matches the in-memory results.
+func ValidateVulnerabilities(result *types.ScanResult, outDir string) ([]string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(outDir, "vulnerabilities.json"))
+	if err != nil {
+		return nil, fmt.Errorf("vulnerabilities.json unreadable: %v", err)
+	}
+	var vulns []types.VulnerabilityResult
+	if err := json.Unmarshal(data, &vulns); err != nil {
+		return nil, fmt.Errorf("vulnerabilities.json is corrupt: %v", err)
+	}
+	if len(vulns) != len(result.VulnURLs) {
+		return []string{fmt.Sprintf("vulnerabilities.json has %d entries, expected %d", len(vulns), len(result.VulnURLs))}, nil
+	}
+	return nil, nil
+}
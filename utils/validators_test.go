@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// validatorCase runs a validator over result with the given files in the
+// run directory and expects an error containing wantErr, or warnings
+// containing each of wantWarn.
+type validatorCase struct {
+	name     string
+	result   types.ScanResult
+	files    map[string]string
+	wantErr  string
+	wantWarn []string
+}
+
+func runValidatorCases(t *testing.T, v StageValidator, tests []validatorCase) {
+	t.Helper()
+	for _, tt := range tests {
+		dir := t.TempDir()
+		for name, content := range tt.files {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		warnings, err := v(&tt.result, dir)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: error %v, want one containing %q", tt.name, err, tt.wantErr)
+		}
+		if len(warnings) != len(tt.wantWarn) {
+			t.Errorf("%s: warnings %q, want %d", tt.name, warnings, len(tt.wantWarn))
+			continue
+		}
+		for i, w := range tt.wantWarn {
+			if !strings.Contains(warnings[i], w) {
+				t.Errorf("%s: warning %q, want one containing %q", tt.name, warnings[i], w)
+			}
+		}
+	}
+}
+
+func TestValidateEnumeration(t *testing.T) {
+	runValidatorCases(t, ValidateEnumeration("example.com"), []validatorCase{
+		{name: "apex found", result: types.ScanResult{Subdomains: []types.SubdomainResult{{Hostname: "www.example.com"}, {Hostname: "Example.com"}}}},
+		{name: "apex missing", result: types.ScanResult{Subdomains: []types.SubdomainResult{{Hostname: "www.example.com"}}},
+			wantWarn: []string{"apex example.com missing"}},
+		{name: "nothing found", wantErr: "no subdomains for example.com"},
+	})
+}
+
+func TestValidateURLs(t *testing.T) {
+	runValidatorCases(t, ValidateURLs, []validatorCase{
+		{name: "valid", files: map[string]string{"urls.txt": "https://example.com/\nhttp://www.example.com/a?b=c\n"}},
+		{name: "empty", files: map[string]string{"urls.txt": ""}},
+		{name: "relative and bare lines", files: map[string]string{"urls.txt": "https://example.com/\n/login\nwww.example.com\n"},
+			wantWarn: []string{`2 line(s) in urls.txt are not valid URLs (e.g. "/login")`}},
+		{name: "missing", wantErr: "urls.txt unreadable"},
+	})
+}
+
+func TestValidateFuzzing(t *testing.T) {
+	runValidatorCases(t, ValidateFuzzing, []validatorCase{
+		{name: "complete entries", result: types.ScanResult{FfufEntries: []types.FfufResult{{Path: "/admin", Status: 403}}}},
+		{name: "no entries"},
+		{name: "demo data", result: types.ScanResult{FfufEntries: []types.FfufResult{{Path: "/admin", Status: 403}, {Path: "", Status: 200}, {Path: "/x"}}},
+			wantWarn: []string{"ffuf entry without path or status", "ffuf entry without path or status"}},
+	})
+}
+
+func TestValidateVulnerabilities(t *testing.T) {
+	two := types.ScanResult{VulnURLs: []types.VulnerabilityResult{{URL: "https://example.com/?id=1", Type: "sqli"}, {URL: "https://example.com/?q=1", Type: "xss"}}}
+	runValidatorCases(t, ValidateVulnerabilities, []validatorCase{
+		{name: "round trip", result: two, files: map[string]string{"vulnerabilities.json": `[{"url":"https://example.com/?id=1","type":"sqli"},{"url":"https://example.com/?q=1","type":"xss"}]`}},
+		{name: "none", files: map[string]string{"vulnerabilities.json": "null"}},
+		{name: "stale file", result: two, files: map[string]string{"vulnerabilities.json": `[{"url":"https://example.com/?id=1","type":"sqli"}]`},
+			wantWarn: []string{"vulnerabilities.json has 1 entries, expected 2"}},
+		{name: "corrupt", result: two, files: map[string]string{"vulnerabilities.json": `[{"url":`}, wantErr: "vulnerabilities.json is corrupt"},
+		{name: "wrong shape", files: map[string]string{"vulnerabilities.json": `{"url":"https://example.com/"}`}, wantErr: "vulnerabilities.json is corrupt"},
+		{name: "missing", wantErr: "vulnerabilities.json unreadable"},
+	})
+}
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/MKlolbullen/Goforgold2/types"
+)
+
+// baseWordlist is the small generic list merged into every generated
+// wordlist, so paths the run's own data never mentions are still tried.
+var baseWordlist = []string{
+	".env", ".git", "admin", "api", "app", "assets", "auth", "backup",
+	"config", "console", "dashboard", "debug", "dev", "docs", "graphql",
+	"health", "internal", "login", "logout", "metrics", "old", "portal",
+	"private", "register", "server-status", "setup", "static", "status",
+	"swagger", "test", "upload", "uploads", "v1", "v2",
+}
+
+// titleStopwords are title words too common to be worth a request.
+var titleStopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "you": true,
+	"your": true, "our": true, "from": true, "this": true, "that": true,
+	"are": true, "not": true, "welcome": true, "page": true, "home": true,
+}
+
+var (
+	// wordTokenRe is what a wordlist entry may consist of.
+	wordTokenRe = regexp.MustCompile(`^[a-z0-9][a-z0-9._~-]*$`)
+	// hexRunRe and digitsRe catch hashes, ids and cache busters.
+	hexRunRe = regexp.MustCompile(`[0-9a-f]{12,}`)
+	digitsRe = regexp.MustCompile(`^[0-9._-]+$`)
+	uuidRe   = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	// titleSplitRe splits page titles into words.
+	titleSplitRe = regexp.MustCompile(`[^a-z0-9]+`)
+	// jsRouteRe matches quoted absolute paths in JavaScript, such as
+	// "/api/v2/orders"; protocol-relative URLs start with two slashes.
+	jsRouteRe = regexp.MustCompile(`["'` + "`" + `](/[A-Za-z0-9_\-./{}:]+)["'` + "`" + `]`)
+)
+
+// WordlistSources is the run data a wordlist is mined from.
+type WordlistSources struct {
+	URLs      []string
+	JSRoutes  []string
+	Params    []string
+	Hostnames []string
+	Titles    []string
+}
+
+// WordlistSourcesFrom collects the crawled and archived URLs, parameter
+// names, hostnames and page titles of result. JavaScript routes are not
+// part of the result and are added by the caller.
+func WordlistSourcesFrom(result types.ScanResult) WordlistSources {
+	src := WordlistSources{URLs: result.AllURLs}
+	for _, p := range result.Parameters {
+		src.Params = append(src.Params, p.Params...)
+	}
+	for _, s := range result.Subdomains {
+		src.Hostnames = append(src.Hostnames, s.Hostname)
+	}
+	for _, lh := range result.LiveHosts {
+		if lh.Title != "" {
+			src.Titles = append(src.Titles, lh.Title)
+		}
+	}
+	return src
+}
+
+// ExtractJSRoutes returns the distinct absolute paths quoted in a script.
+func ExtractJSRoutes(script []byte) []string {
+	var routes []string
+	for _, m := range jsRouteRe.FindAllSubmatch(script, -1) {
+		if r := string(m[1]); len(r) > 1 && !strings.HasPrefix(r, "//") {
+			routes = append(routes, r)
+		}
+	}
+	return UniqueStrings(routes)
+}
+
+// wordlistToken normalizes a candidate entry, returning "" for junk:
+// anything shorter than three or longer than forty characters, numbers,
+// long hex runs such as hashes and UUIDs, and characters a path segment
+// would need encoded.
+func wordlistToken(s string) string {
+	if u, err := url.PathUnescape(s); err == nil {
+		s = u
+	}
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) < 3 || len(s) > 40 || !wordTokenRe.MatchString(s) {
+		return ""
+	}
+	if digitsRe.MatchString(s) || hexRunRe.MatchString(s) || uuidRe.MatchString(s) {
+		return ""
+	}
+	return s
+}
+
+// pathSegments splits a URL or absolute path into its path segments.
+func pathSegments(raw string) []string {
+	p := raw
+	if u, err := url.Parse(raw); err == nil {
+		p = u.EscapedPath()
+	}
+	return strings.Split(p, "/")
+}
+
+// GenerateWordlist mines src for path segments, parameter names, subdomain
+// labels left of domain, and page title words, ranks them by how often they
+// occur, then alphabetically, and appends the generic base list. The result
+// holds at most max entries, base list included, and is the same for the
+// same sources. Under a cap too small for both, mined words win.
+func GenerateWordlist(src WordlistSources, domain string, max int) []string {
+	counts := make(map[string]int)
+	add := func(s string) {
+		if t := wordlistToken(s); t != "" {
+			counts[t]++
+		}
+	}
+	for _, list := range [][]string{src.URLs, src.JSRoutes} {
+		for _, raw := range list {
+			for _, seg := range pathSegments(raw) {
+				add(seg)
+			}
+		}
+	}
+	for _, p := range src.Params {
+		add(p)
+	}
+	suffix := "." + NormalizeHostname(domain)
+	for _, h := range src.Hostnames {
+		h = NormalizeHostname(h)
+		if !strings.HasSuffix(h, suffix) {
+			continue
+		}
+		for _, label := range strings.Split(strings.TrimSuffix(h, suffix), ".") {
+			add(label)
+			if strings.Contains(label, "-") {
+				for _, part := range strings.Split(label, "-") {
+					add(part)
+				}
+			}
+		}
+	}
+	for _, title := range src.Titles {
+		for _, w := range titleSplitRe.Split(strings.ToLower(title), -1) {
+			if !titleStopwords[w] {
+				add(w)
+			}
+		}
+	}
+	base := make(map[string]bool, len(baseWordlist))
+	for _, w := range baseWordlist {
+		base[w] = true
+	}
+	mined := make([]string, 0, len(counts))
+	for w := range counts {
+		if !base[w] {
+			mined = append(mined, w)
+		}
+	}
+	sort.Slice(mined, func(i, j int) bool {
+		if counts[mined[i]] != counts[mined[j]] {
+			return counts[mined[i]] > counts[mined[j]]
+		}
+		return mined[i] < mined[j]
+	})
+	// The base list keeps its room unless the cap is too small to share.
+	room := max
+	if max >= 2*len(baseWordlist) {
+		room = max - len(baseWordlist)
+	}
+	if len(mined) > room {
+		mined = mined[:room]
+	}
+	words := append(mined, baseWordlist...)
+	if len(words) > max {
+		words = words[:max]
+	}
+	return words
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestGenerateWordlist(t *testing.T) {
+	src := WordlistSources{
+		URLs: []string{
+			"https://shop.example.com/api/v3/orders?id=5",
+			"https://shop.example.com/api/v3/orders/1234",
+			"https://shop.example.com/static/app.3f9a8b7c6d5e4f3a.js",
+			"https://shop.example.com/account%20settings/",
+			"https://shop.example.com/files/6f1c2a4e-8b3d-4c2e-9a1f-0d2b3c4e5f6a",
+			"https://shop.example.com/checkout/",
+		},
+		JSRoutes:  []string{"/api/internal/billing", "/checkout/confirm"},
+		Params:    []string{"orderId", "q", "return_url"},
+		Hostnames: []string{"staging-payments.shop.example.com", "WWW.Example.com.", "mail.other.org"},
+		Titles:    []string{"Welcome to the Payments Portal"},
+	}
+	// Most frequent first, then alphabetical; the base list after them.
+	mined := []string{"checkout", "orders", "payments", "billing", "confirm", "files", "orderid", "return_url", "shop", "staging", "staging-payments", "www"}
+	want := append(append([]string(nil), mined...), baseWordlist...)
+	if got := GenerateWordlist(src, "example.com", 1000); !reflect.DeepEqual(got, want) {
+		t.Errorf("GenerateWordlist =\n%q\nwant\n%q", got, want)
+	}
+	if a, b := GenerateWordlist(src, "example.com", 1000), GenerateWordlist(src, "example.com", 1000); !reflect.DeepEqual(a, b) {
+		t.Error("the same sources gave different wordlists")
+	}
+
+	// Under a cap too small for both lists the mined words win.
+	if got := GenerateWordlist(src, "example.com", 40); len(got) != 40 || !reflect.DeepEqual(got[:len(mined)], mined) {
+		t.Errorf("capped at 40: %q", got)
+	}
+	if got := GenerateWordlist(src, "example.com", 5); !reflect.DeepEqual(got, mined[:5]) {
+		t.Errorf("capped at 5: %q", got)
+	}
+
+	// With room for both, the base list keeps its share.
+	var many WordlistSources
+	for i := 0; i < 100; i++ {
+		many.Params = append(many.Params, fmt.Sprintf("field%03d", i))
+	}
+	max := 2*len(baseWordlist) + 2
+	got := GenerateWordlist(many, "example.com", max)
+	if len(got) != max || got[0] != "field000" || !reflect.DeepEqual(got[max-len(baseWordlist):], baseWordlist) {
+		t.Errorf("capped at %d: %q", max, got)
+	}
+}
+
+func TestWordlistToken(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Admin", "admin"},
+		{"user%2dprofile", "user-profile"},
+		{"wp-content", "wp-content"},
+		{"ab", ""},
+		{"2023-01-15", ""},
+		{"d41d8cd98f00b204e9800998ecf8427e", ""},
+		{"bundle.a1b2c3d4e5f6a7b8.js", ""},
+		{"{id}", ""},
+		{"index.php", "index.php"},
+		{"_next", ""},
+	}
+	for _, tt := range tests {
+		if got := wordlistToken(tt.in); got != tt.want {
+			t.Errorf("wordlistToken(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExtractJSRoutes(t *testing.T) {
+	script := []byte("fetch(\"/api/v2/orders\");const u='/users/{id}/profile',cdn=\"//cdn.example.com/lib.js\";" +
+		"router.get(`/admin/reports`);x=\"/\";y=\"/api/v2/orders\";z='relative/path'")
+	want := []string{"/api/v2/orders", "/users/{id}/profile", "/admin/reports"}
+	if got := ExtractJSRoutes(script); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractJSRoutes = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,180 @@
+// watch.go - recon watch: a read-only view of a run, rebuilt from its directory.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+const (
+	// watchInterval is how often recon watch reads the run's files.
+	watchInterval = 500 * time.Millisecond
+	// watchFindings and watchLogLines are how many recent findings and log
+	// lines the view keeps.
+	watchFindings = 20
+	watchLogLines = 500
+)
+
+// runWatcher follows a run directory through its events.jsonl and
+// scan.log; nothing is shared with the process running the scan.
+type runWatcher struct {
+	dir    string
+	events *utils.Tailer
+	log    *utils.Tailer
+	state  *utils.WatchState
+}
+
+func newRunWatcher(dir string) *runWatcher {
+	return &runWatcher{
+		dir:    dir,
+		events: utils.NewTailer(filepath.Join(dir, "events.jsonl")),
+		log:    utils.NewTailer(filepath.Join(dir, "scan.log")),
+		state:  utils.NewWatchState(watchFindings, watchLogLines),
+	}
+}
+
+// poll reads what the run wrote since the last poll and reports whether
+// the run has completed. Completion is checked first, so the files read
+// after it hold everything the run wrote.
+func (w *runWatcher) poll() (newLog []string, done bool, err error) {
+	done = utils.RunCompleted(w.dir)
+	events, err := w.events.Poll()
+	w.state.AddEvents(events)
+	if err != nil {
+		return nil, done, err
+	}
+	newLog, err = w.log.Poll()
+	w.state.AddLog(newLog)
+	return newLog, done, err
+}
+
+func (w *runWatcher) close() {
+	w.events.Close()
+	w.log.Close()
+}
+
+// progress is the progress strip: stages done, the last one and the
+// counts so far.
+func (w *runWatcher) progress(done bool) string {
+	s := w.state
+	line := fmt.Sprintf("Stages %d/%d", len(s.Stages), len(pipelineStages))
+	if n := len(s.Stages); n > 0 {
+		last := s.Stages[n-1]
+		line += fmt.Sprintf(" | last: %s (%s)", last.Stage, last.Status)
+	}
+	if done {
+		line += " | run complete"
+	}
+	return line + fmt.Sprintf("\nSubdomains %d | live hosts %d | URLs %d | findings %d",
+		s.Counts.Subdomains, s.Counts.LiveHosts, s.Counts.URLs, s.Counts.Findings)
+}
+
+// recentFindings lists the latest findings, newest first.
+func (w *runWatcher) recentFindings() string {
+	var b strings.Builder
+	for i := len(w.state.Findings) - 1; i >= 0; i-- {
+		v := w.state.Findings[i]
+		fmt.Fprintf(&b, "%-8s %s at %s\n", utils.FindingSeverity(v), v.Issue, v.URL)
+	}
+	return b.String()
+}
+
+// runWatch shows the run in dir until it completes or the user quits: a
+// read-only TUI on a terminal, the log as it grows otherwise.
+func runWatch(dir string) {
+	if !dirExists(dir) {
+		fmt.Println("No run directory", dir)
+		return
+	}
+	w := newRunWatcher(dir)
+	defer w.close()
+	if !utils.IsTerminal(os.Stdout) {
+		watchHeadless(w)
+		return
+	}
+	app := tview.NewApplication()
+	progressView := tview.NewTextView()
+	progressView.SetBorder(true).SetTitle("recon watch " + dir + " (read-only, q quits)")
+	findingsView := tview.NewTextView()
+	findingsView.SetBorder(true).SetTitle("Recent findings")
+	consoleView := tview.NewTextView().SetDynamicColors(true)
+	consoleView.SetBorder(true).SetTitle("Console Output")
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(progressView, 4, 0, false).
+		AddItem(findingsView, 10, 0, false).
+		AddItem(consoleView, 0, 1, false)
+	app.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		if ev.Key() == tcell.KeyEscape || ev.Rune() == 'q' {
+			app.Stop()
+			return nil
+		}
+		return ev
+	})
+	// The poller owns the watcher; the app only gets the rendered text.
+	stop, finished := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+		for {
+			_, done, err := w.poll()
+			progress, findings := w.progress(done), w.recentFindings()
+			console := tview.Escape(strings.Join(w.state.Log, "\n"))
+			app.QueueUpdateDraw(func() {
+				progressView.SetText(progress)
+				findingsView.SetText(findings)
+				consoleView.SetText(console)
+				consoleView.ScrollToEnd()
+				if err != nil {
+					consoleView.SetTitle("Console Output ([!] " + err.Error() + ")")
+				}
+			})
+			if done {
+				app.Stop()
+				return
+			}
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	err := app.SetRoot(layout, true).Run()
+	close(stop)
+	<-finished
+	if err != nil {
+		fmt.Println("recon watch:", err)
+		return
+	}
+	if utils.RunCompleted(dir) {
+		fmt.Println(w.progress(true))
+	}
+}
+
+// watchHeadless prints the run's new log lines as they are written and the
+// progress once it completes.
+func watchHeadless(w *runWatcher) {
+	for {
+		lines, done, err := w.poll()
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+		if err != nil {
+			fmt.Println("[!] recon watch:", err)
+		}
+		if done {
+			fmt.Println(w.progress(true))
+			return
+		}
+		time.Sleep(watchInterval)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MKlolbullen/Goforgold2/utils"
+)
+
+// TestRunWatcher runs a simulated scan that writes its log in pieces and
+// records stages as the pipeline does, while a watcher follows the run
+// directory until the summary shows the run completed.
+func TestRunWatcher(t *testing.T) {
+	savedResult, savedEvents, savedEmitted := scanResult, runEvents, emittedFindings
+	defer func() { scanResult, runEvents, emittedFindings = savedResult, savedEvents, savedEmitted }()
+
+	dir := t.TempDir()
+	var err error
+	if runEvents, err = utils.OpenEventLog(filepath.Join(dir, "events.jsonl")); err != nil {
+		t.Fatal(err)
+	}
+	defer runEvents.Close()
+	emittedFindings = make(map[string]bool)
+	scanResult = ScanResult{Running: true}
+
+	logFile, err := os.Create(filepath.Join(dir, "scan.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+	stages := []string{"subdomain enumeration", "HTTP probing", "vulnerability scanning"}
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		for i, stage := range stages {
+			line := fmt.Sprintf("[*] Running %s...\n", stage)
+			// A line written in two halves.
+			logFile.WriteString(line[:5])
+			time.Sleep(5 * time.Millisecond)
+			logFile.WriteString(line[5:])
+			scanMu.Lock()
+			scanResult.Subdomains = append(scanResult.Subdomains, SubdomainResult{Hostname: fmt.Sprintf("h%d.example.com", i)})
+			if stage == "vulnerability scanning" {
+				scanResult.VulnURLs = append(scanResult.VulnURLs,
+					VulnerabilityResult{Issue: "SQL Injection", URL: "https://h0.example.com/item?id=1", Parameter: "id (GET)"},
+					VulnerabilityResult{Issue: "XSS", URL: "https://h1.example.com/?q=x", Parameter: "q (GET)"})
+			}
+			scanMu.Unlock()
+			recordStage(StageStatus{Stage: stage, Status: "completed"})
+			time.Sleep(10 * time.Millisecond)
+		}
+		logFile.WriteString("========== Scan Complete ==========\n")
+		scanMu.Lock()
+		scanResult.Running = false
+		final := scanResult
+		scanMu.Unlock()
+		utils.PersistResults(final, dir)
+	}()
+
+	w := newRunWatcher(dir)
+	defer w.close()
+	var log []string
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		lines, done, err := w.poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		log = append(log, lines...)
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the watcher never saw the run complete")
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	<-writerDone
+
+	if len(log) != 4 || log[0] != "[*] Running subdomain enumeration..." || log[3] != "========== Scan Complete ==========" {
+		t.Errorf("log %q", log)
+	}
+	if got := w.progress(true); got != "Stages 3/"+fmt.Sprint(len(pipelineStages))+" | last: vulnerability scanning (completed) | run complete\n"+
+		"Subdomains 3 | live hosts 0 | URLs 0 | findings 2" {
+		t.Errorf("progress %q", got)
+	}
+	// Newest first.
+	if findings := strings.Split(w.recentFindings(), "\n"); len(findings) != 3 || !strings.HasSuffix(findings[0], " XSS at https://h1.example.com/?q=x") ||
+		!strings.HasSuffix(findings[1], " SQL Injection at https://h0.example.com/item?id=1") {
+		t.Errorf("recent findings %q", findings)
+	}
+	if len(w.state.Findings) != 2 || w.state.Findings[1].Issue != "XSS" || w.state.BadEvents != 0 {
+		t.Errorf("state %+v", w.state)
+	}
+
+	// A finding already sent is not sent again with the next stage.
+	recordStage(StageStatus{Stage: "Shodan enrichment", Status: "completed"})
+	w.poll()
+	if len(w.state.Findings) != 2 || len(w.state.Stages) != 4 {
+		t.Errorf("after another stage: %d finding(s), %d stage(s)", len(w.state.Findings), len(w.state.Stages))
+	}
+}